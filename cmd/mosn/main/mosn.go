@@ -27,12 +27,29 @@ import (
 	_ "mosn.io/mosn/pkg/buffer"
 	_ "mosn.io/mosn/pkg/filter/listener/originaldst"
 	_ "mosn.io/mosn/pkg/filter/network/connectionmanager"
+	_ "mosn.io/mosn/pkg/filter/network/faultinject"
+	_ "mosn.io/mosn/pkg/filter/network/mqttproxy"
+	_ "mosn.io/mosn/pkg/filter/network/mysqlsniffer"
 	_ "mosn.io/mosn/pkg/filter/network/proxy"
+	_ "mosn.io/mosn/pkg/filter/network/rbac"
+	_ "mosn.io/mosn/pkg/filter/network/redisproxy"
+	_ "mosn.io/mosn/pkg/filter/network/tap"
 	_ "mosn.io/mosn/pkg/filter/network/tcpproxy"
+	_ "mosn.io/mosn/pkg/filter/stream/compression"
+	_ "mosn.io/mosn/pkg/filter/stream/dubboinvocation"
+	_ "mosn.io/mosn/pkg/filter/stream/extauthz"
 	_ "mosn.io/mosn/pkg/filter/stream/faultinject"
+	_ "mosn.io/mosn/pkg/filter/stream/healthcheck"
+	_ "mosn.io/mosn/pkg/filter/stream/jwtauthn"
 	_ "mosn.io/mosn/pkg/filter/stream/mixer"
 	_ "mosn.io/mosn/pkg/filter/stream/payloadlimit"
+	_ "mosn.io/mosn/pkg/filter/stream/proxywasm"
+	_ "mosn.io/mosn/pkg/filter/stream/rbac"
+	_ "mosn.io/mosn/pkg/filter/stream/requestid"
+	_ "mosn.io/mosn/pkg/filter/stream/tap"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2bolt"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2grpc"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2rpc"
 	_ "mosn.io/mosn/pkg/metrics/sink"
 	_ "mosn.io/mosn/pkg/metrics/sink/prometheus"
 	_ "mosn.io/mosn/pkg/network"
@@ -44,6 +61,7 @@ import (
 	_ "mosn.io/mosn/pkg/protocol/xprotocol/boltv2"
 	_ "mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
 	_ "mosn.io/mosn/pkg/protocol/xprotocol/tars"
+	_ "mosn.io/mosn/pkg/protocol/xprotocol/thrift"
 	_ "mosn.io/mosn/pkg/router"
 	_ "mosn.io/mosn/pkg/stream/http"
 	_ "mosn.io/mosn/pkg/stream/http2"
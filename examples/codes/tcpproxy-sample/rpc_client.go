@@ -44,7 +44,7 @@ func NewClient(addr string, proto types.ProtocolName) *Client {
 func (c *Client) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
 	fmt.Printf("[Xprotocol RPC Client] Receive Data:")
 	if cmd, ok := headers.(xprotocol.XFrame); ok {
-		streamID := protocol.StreamIDConv(cmd.GetRequestId())
+		streamID := protocol.StreamIDConv(cmd.(xprotocol.Multiplexing).GetRequestId())
 
 		if resp, ok := cmd.(xprotocol.XRespFrame); ok {
 			fmt.Println("stream:", streamID, " status:", resp.GetStatusCode())
@@ -23,12 +23,17 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"mosn.io/mosn/pkg/admin/store"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/metrics/sink/console"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/plugin"
+	mserver "mosn.io/mosn/pkg/server"
+	"mosn.io/mosn/pkg/tap"
 	"mosn.io/mosn/pkg/types"
 )
 
@@ -193,6 +198,105 @@ func getState(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, msg)
 }
 
+// drain starts draining the connections gracefully, the connections still alive are closed after the drain timeout
+func drain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "drain", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !mserver.Drain() {
+		log.DefaultLogger.Warnf("[admin api] [drain] connections are draining already")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "connections are draining already\n")
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [drain] start draining connections")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "start draining connections\n")
+}
+
+// overloadStatus returns the pressure of the resources and the state of the overload actions
+func overloadStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "overload", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	buf, err := json.Marshal(overload.GetStatus())
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "overload", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, fmt.Sprintf(errMsgFmt, "internal error"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
+
+// tapTraces streams the traces of the tap filters as json lines, until the client disconnects.
+// http://ip:port/api/v1/tap?id=tapid&count=10&timeout=30s
+// the traces of all taps are streamed if the id is empty
+func tapTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "tap", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	var count int
+	if v := query.Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, fmt.Sprintf(errMsgFmt, "invalid count"))
+			return
+		}
+		count = n
+	}
+	var timeout <-chan time.Time
+	if v := query.Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, fmt.Sprintf(errMsgFmt, "invalid timeout"))
+			return
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	flusher, _ := w.(http.Flusher)
+
+	id := query.Get("id")
+	subscriber := tap.Subscribe(id)
+	log.DefaultLogger.Infof("[admin api] [tap] subscribe tap %s", id)
+	defer func() {
+		tap.Unsubscribe(subscriber)
+		log.DefaultLogger.Infof("[admin api] [tap] unsubscribe tap %s, %d traces dropped", id, subscriber.Dropped())
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for n := 0; count == 0 || n < count; n++ {
+		select {
+		case trace := <-subscriber.Traces():
+			w.Write(trace)
+			w.Write([]byte("\n"))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-timeout:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
 // http://ip:port/plugin?enable=pluginname
 // http://ip:port/plugin?disable=pluginname
 // http://ip:port/plugin?status=pluginname
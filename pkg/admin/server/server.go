@@ -48,6 +48,9 @@ func init() {
 		"/api/v1/disbale_log":     disableLogger,
 		"/api/v1/states":          getState,
 		"/api/v1/plugin":          pluginApi,
+		"/api/v1/drain":           drain,
+		"/api/v1/overload":        overloadStatus,
+		"/api/v1/tap":             tapTraces,
 		"/":                       help,
 	}
 }
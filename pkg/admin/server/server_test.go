@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
 	"strings"
@@ -36,6 +37,7 @@ import (
 	mv2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/tap"
 )
 
 func getEffectiveConfig(port uint32) (string, error) {
@@ -448,3 +450,43 @@ func readLines(path string) ([]string, error) {
 	}
 	return lines, scanner.Err()
 }
+
+func TestTapTraces(t *testing.T) {
+	tp, err := tap.NewTap(&tap.Config{ID: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		tapTraces(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/tap?id=admin&count=2&timeout=3s", nil))
+		close(done)
+	}()
+	// submit the traces until the subscriber receives enough traces
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+SUBMIT:
+	for {
+		select {
+		case <-done:
+			break SUBMIT
+		case <-ticker.C:
+			if tp.Active() {
+				tp.Submit(&tap.Trace{Protocol: "tcp"})
+			}
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if recorder.Code != http.StatusOK || len(lines) != 2 || !strings.Contains(lines[0], `"tap_id":"admin"`) {
+		t.Fatalf("unexpected tap response: %d, %s", recorder.Code, recorder.Body.String())
+	}
+	if tp.Active() {
+		t.Fatal("the subscriber should be unsubscribed")
+	}
+
+	recorder = httptest.NewRecorder()
+	tapTraces(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/tap?count=-1", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, but got %d", recorder.Code)
+	}
+}
@@ -74,6 +74,7 @@ func SetMosnConfig(cfg *v2.MOSNConfig) {
 				GlobalLogRoller: srv.GlobalLogRoller,
 				UseNetpollMode:  srv.UseNetpollMode,
 				GracefulTimeout: srv.GracefulTimeout,
+				DrainTimeout:    srv.DrainTimeout,
 			},
 		}
 	}
@@ -23,6 +23,7 @@ import (
 	"github.com/c2h5oh/datasize"
 	xdsboot "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v2"
 	"github.com/gogo/protobuf/jsonpb"
+	"mosn.io/api"
 )
 
 // MOSNConfig make up mosn to start the mosn project
@@ -41,6 +42,9 @@ type MOSNConfig struct {
 	Debug               PProfConfig     `json:"pprof,omitempty"`
 	Pid                 string          `json:"pid,omitempty"`    // pid file
 	Plugin              PluginConfig    `json:"plugin,omitempty"` // plugin config
+	Overload            OverloadConfig  `json:"overload_manager,omitempty"`
+	// XProtocolCodecs are the xprotocol sub-protocols described by config
+	XProtocolCodecs []XProtocolCodecConfig `json:"xprotocol_codecs,omitempty"`
 }
 
 // PProfConfig is used to start a pprof server for debug
@@ -70,6 +74,34 @@ type PluginConfig struct {
 	LogBase string `json:"log_base"`
 }
 
+// OverloadConfig configures the overload manager, the actions are triggered
+// when the pressure of the resources reaches the thresholds
+type OverloadConfig struct {
+	RefreshInterval  api.DurationConfig      `json:"refresh_interval,omitempty"`
+	ResourceMonitors []ResourceMonitorConfig `json:"resource_monitors,omitempty"`
+	Actions          []OverloadActionConfig  `json:"actions,omitempty"`
+}
+
+// ResourceMonitorConfig configures a monitored resource, the pressure of the resource is the usage divided by the max
+type ResourceMonitorConfig struct {
+	Name string `json:"name"`
+	Max  uint64 `json:"max"`
+}
+
+// OverloadActionConfig configures an action, the action is active if any of the triggers fires
+type OverloadActionConfig struct {
+	Name     string                  `json:"name"`
+	Triggers []OverloadTriggerConfig `json:"triggers"`
+	// BufferLimitBytes is the per connection buffer limit when the buffer limits are shrunk
+	BufferLimitBytes uint32 `json:"buffer_limit_bytes,omitempty"`
+}
+
+// OverloadTriggerConfig fires when the pressure of the resource is not less than the threshold
+type OverloadTriggerConfig struct {
+	Name      string  `json:"name"`
+	Threshold float64 `json:"threshold"`
+}
+
 // StatsMatcher is a configuration for disabling stat instantiation.
 // TODO: support inclusion_list
 // TODO: support exclusion list/inclusion_list as pattern
@@ -29,12 +29,36 @@ type HealthCheckFilterConfig struct {
 	PassThrough                 bool               `json:"passthrough,omitempty"`
 	CacheTimeConfig             api.DurationConfig `json:"cache_time,omitempty"`
 	Endpoint                    string             `json:"endpoint,omitempty"`
+	Paths                       []string           `json:"paths,omitempty"`
 	ClusterMinHealthyPercentage map[string]float32 `json:"cluster_min_healthy_percentages,omitempty"`
 }
 
 type FaultInjectConfig struct {
-	DelayPercent        uint32             `json:"delay_percent,omitempty"`
-	DelayDurationConfig api.DurationConfig `json:"delay_duration,omitempty"`
+	DelayPercent        uint32                 `json:"delay_percent,omitempty"`
+	DelayDurationConfig api.DurationConfig     `json:"delay_duration,omitempty"`
+	Abort               *ConnectionAbortInject `json:"abort,omitempty"`
+	ReadBandwidth       *BandwidthInject       `json:"read_bandwidth,omitempty"`
+	WriteBandwidth      *BandwidthInject       `json:"write_bandwidth,omitempty"`
+	PartialWrite        *PartialWriteInject    `json:"partial_write,omitempty"`
+}
+
+// ConnectionAbortInject closes the connection when it is accepted,
+// or after the AfterBytes bytes are received
+type ConnectionAbortInject struct {
+	Percent    uint32 `json:"percentage,omitempty"`
+	AfterBytes uint64 `json:"after_bytes,omitempty"`
+}
+
+// BandwidthInject throttles the connection to KiBPerSecond KiB/s
+type BandwidthInject struct {
+	Percent      uint32 `json:"percentage,omitempty"`
+	KiBPerSecond uint64 `json:"kib_per_second,omitempty"`
+}
+
+// PartialWriteInject writes the first Bytes bytes to the connection, and then closes it
+type PartialWriteInject struct {
+	Percent uint32 `json:"percentage,omitempty"`
+	Bytes   uint64 `json:"bytes,omitempty"`
 }
 
 type DelayInjectConfig struct {
@@ -56,13 +80,27 @@ const (
 	RPC_PROXY                   = "rpc_proxy"
 	X_PROXY                     = "x_proxy"
 	Transcoder                  = "transcoder"
+	RBAC_NETWORK_FILTER         = "rbac"
+	TAP_NETWORK_FILTER          = "tap"
+	REDIS_PROXY                 = "redis_proxy"
+	MQTT_PROXY                  = "mqtt_proxy"
+	MYSQL_SNIFFER               = "mysql_sniffer"
 )
 
 // Stream Filter's Type
 const (
-	MIXER        = "mixer"
-	FaultStream  = "fault"
-	PayloadLimit = "payload_limit"
+	MIXER             = "mixer"
+	FaultStream       = "fault"
+	PayloadLimit      = "payload_limit"
+	Compression       = "compression"
+	JwtAuthn          = "jwt_authn"
+	ExtAuthz          = "ext_authz"
+	RBACStream        = "rbac"
+	ProxyWasm         = "proxywasm"
+	HealthCheckStream = "health_check"
+	RequestID         = "request_id"
+	TapStream         = "tap"
+	DubboInvocation   = "dubbo_invocation"
 )
 
 // HealthCheckFilter
@@ -120,6 +158,8 @@ type StreamFaultInject struct {
 	Abort           *AbortInject    `json:"abort,omitempty"`
 	UpstreamCluster string          `json:"upstream_cluster,omitempty"`
 	Headers         []HeaderMatcher `json:"headers,omitempty"`
+	// HeaderOverrides allows the request headers to override the delay and the abort
+	HeaderOverrides bool `json:"header_overrides,omitempty"`
 }
 
 type DelayInject struct {
@@ -848,3 +848,33 @@ func TestListenerUnmarshal(t *testing.T) {
 		t.Fatalf("mashal json unexpected, got : %s", string(b))
 	}
 }
+
+func TestStreamTimeoutConfigUnmarshal(t *testing.T) {
+	listenerConfig := `{
+		"name": "test_listener",
+		"stream_idle_timeout": "30s",
+		"max_stream_duration": "10m"
+	}`
+	ln := &Listener{}
+	if err := json.Unmarshal([]byte(listenerConfig), ln); err != nil {
+		t.Fatal(err)
+	}
+	if !(ln.StreamIdleTimeout.Duration == 30*time.Second &&
+		ln.MaxStreamDuration.Duration == 10*time.Minute) {
+		t.Fatalf("listener stream timeout unexpected, got: %+v", ln.StreamTimeoutConfig)
+	}
+
+	routeConfig := `{
+		"cluster_name": "test",
+		"stream_idle_timeout": "0s"
+	}`
+	action := &RouteAction{}
+	if err := json.Unmarshal([]byte(routeConfig), action); err != nil {
+		t.Fatal(err)
+	}
+	if !(action.StreamIdleTimeout != nil &&
+		action.StreamIdleTimeout.Duration == 0 &&
+		action.MaxStreamDuration == nil) {
+		t.Fatalf("route stream timeout unexpected, got: %+v", action.StreamTimeoutConfig)
+	}
+}
@@ -49,3 +49,9 @@ type Proxy struct {
 type XProxyExtendConfig struct {
 	SubProtocol string `json:"sub_protocol,omitempty"`
 }
+
+// ProxyGeneralExtendConfig is the extend config of the proxy for all protocols
+type ProxyGeneralExtendConfig struct {
+	// Http1UseStream forwards the http1 body incrementally instead of buffering the whole body
+	Http1UseStream bool `json:"http1_use_stream,omitempty"`
+}
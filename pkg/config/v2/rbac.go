@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v2
+
+// RBAC actions
+const (
+	RBACActionAllow = "ALLOW"
+	RBACActionDeny  = "DENY"
+)
+
+// RBAC is the config of the rbac network filter and stream filter.
+// The rules are enforced, and the shadow rules are only evaluated and logged,
+// so a policy can be tried out in the shadow mode before it is enforced.
+type RBAC struct {
+	Rules       *RBACRules `json:"rules,omitempty"`
+	ShadowRules *RBACRules `json:"shadow_rules,omitempty"`
+}
+
+// RBACRules contains the policies and the action taken if any one of them matches.
+// If the action is ALLOW, a request is allowed only if it matches a policy.
+// If the action is DENY, a request is denied if it matches a policy.
+type RBACRules struct {
+	Action   string                 `json:"action,omitempty"`
+	Policies map[string]*RBACPolicy `json:"policies,omitempty"`
+}
+
+// RBACPolicy matches if any one of the permissions and any one of the principals match
+type RBACPolicy struct {
+	Permissions []*RBACPermission `json:"permissions,omitempty"`
+	Principals  []*RBACPrincipal  `json:"principals,omitempty"`
+}
+
+// RBACPermission describes the actions, exactly one of the fields should be set
+type RBACPermission struct {
+	Any             bool               `json:"any,omitempty"`
+	AndRules        []*RBACPermission  `json:"and_rules,omitempty"`
+	OrRules         []*RBACPermission  `json:"or_rules,omitempty"`
+	NotRule         *RBACPermission    `json:"not_rule,omitempty"`
+	Header          *RBACHeaderMatcher `json:"header,omitempty"`
+	Path            *RBACStringMatcher `json:"path,omitempty"`
+	Method          string             `json:"method,omitempty"`
+	DestinationIP   *RBACCidrRange     `json:"destination_ip,omitempty"`
+	DestinationPort uint32             `json:"destination_port,omitempty"`
+	// Service and RPCMethod match the service name and the method name of the rpc requests,
+	// the protocols that provide them are supported, such as bolt and dubbo.
+	Service   *RBACStringMatcher `json:"service,omitempty"`
+	RPCMethod *RBACStringMatcher `json:"rpc_method,omitempty"`
+}
+
+// RBACPrincipal describes the downstream identities, exactly one of the fields should be set
+type RBACPrincipal struct {
+	Any           bool                 `json:"any,omitempty"`
+	AndIDs        []*RBACPrincipal     `json:"and_ids,omitempty"`
+	OrIDs         []*RBACPrincipal     `json:"or_ids,omitempty"`
+	NotID         *RBACPrincipal       `json:"not_id,omitempty"`
+	Authenticated *RBACAuthenticated   `json:"authenticated,omitempty"`
+	SourceIP      *RBACCidrRange       `json:"source_ip,omitempty"`
+	Header        *RBACHeaderMatcher   `json:"header,omitempty"`
+	JwtClaim      *RBACJwtClaimMatcher `json:"jwt_claim,omitempty"`
+}
+
+// RBACAuthenticated matches the peer certificate of the mTLS connection,
+// the URI SAN (such as the SPIFFE ID) is used if it exists, otherwise the subject is used.
+// If the principal name is not set, any authenticated connection matches.
+type RBACAuthenticated struct {
+	PrincipalName *RBACStringMatcher `json:"principal_name,omitempty"`
+}
+
+// RBACJwtClaimMatcher matches a claim of the jwt verified by the jwt_authn filter,
+// the claim should be forwarded to the variable by the claim_to_variables config.
+type RBACJwtClaimMatcher struct {
+	Variable string             `json:"variable"`
+	Value    *RBACStringMatcher `json:"value,omitempty"`
+}
+
+// RBACCidrRange is an ip address prefix
+type RBACCidrRange struct {
+	AddressPrefix string `json:"address_prefix"`
+	PrefixLen     uint32 `json:"prefix_len"`
+}
+
+// RBACStringMatcher matches a string, exactly one of exact, prefix, suffix and regex should be set
+type RBACStringMatcher struct {
+	Exact      string `json:"exact,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	Suffix     string `json:"suffix,omitempty"`
+	Regex      string `json:"regex,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+}
+
+// RBACHeaderMatcher matches a header, the header only needs to be present if no value matcher is set.
+// The pseudo headers :path, :method and :authority are supported.
+type RBACHeaderMatcher struct {
+	Name         string `json:"name"`
+	ExactMatch   string `json:"exact_match,omitempty"`
+	PrefixMatch  string `json:"prefix_match,omitempty"`
+	SuffixMatch  string `json:"suffix_match,omitempty"`
+	RegexMatch   string `json:"regex_match,omitempty"`
+	PresentMatch bool   `json:"present_match,omitempty"`
+	InvertMatch  bool   `json:"invert_match,omitempty"`
+}
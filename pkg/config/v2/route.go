@@ -61,6 +61,8 @@ type RouterActionConfig struct {
 	RequestHeadersToAdd     []*HeaderValueOption `json:"request_headers_to_add,omitempty"`
 	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
 	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
+	ConnectConfig           *ConnectConfig       `json:"connect_config,omitempty"`
+	StreamTimeoutConfig
 }
 
 type ClusterWeightConfig struct {
@@ -269,10 +271,43 @@ type VirtualHost struct {
 
 // RouterMatch represents the route matching parameters
 type RouterMatch struct {
-	Prefix  string          `json:"prefix,omitempty"`  // Match request's Path with Prefix Comparing
-	Path    string          `json:"path,omitempty"`    // Match request's Path with Exact Comparing
-	Regex   string          `json:"regex,omitempty"`   // Match request's Path with Regex Comparing
-	Headers []HeaderMatcher `json:"headers,omitempty"` // Match request's Headers
+	Prefix         string          `json:"prefix,omitempty"`          // Match request's Path with Prefix Comparing
+	Path           string          `json:"path,omitempty"`            // Match request's Path with Exact Comparing
+	Regex          string          `json:"regex,omitempty"`           // Match request's Path with Regex Comparing
+	Headers        []HeaderMatcher `json:"headers,omitempty"`         // Match request's Headers
+	ConnectMatcher *ConnectMatcher `json:"connect_matcher,omitempty"` // Match HTTP CONNECT requests
+}
+
+// ConnectMatcher matches the HTTP CONNECT requests, the virtual host's domains
+// are the allowed authorities (host:port) of the CONNECT requests.
+type ConnectMatcher struct {
+	// Ports is the allowed ports list of the authority, empty means any port
+	Ports []uint32 `json:"ports,omitempty"`
+}
+
+// ConnectConfig represents how a matched HTTP CONNECT request is tunneled.
+type ConnectConfig struct {
+	// DynamicHost makes the tunnel connects to the CONNECT request's authority directly
+	// instead of choosing a host from the route's cluster, the authority should be allowed
+	// by AllowedHosts or AllowedCIDRs, otherwise the request is rejected
+	DynamicHost bool `json:"dynamic_host,omitempty"`
+	// AllowedHosts is the host names that the dynamic host tunnel can connect to
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	// AllowedCIDRs is the ip ranges that the dynamic host tunnel can connect to, such as 10.0.0.0/8.
+	// It is matched with the ip authorities only, the host names are not resolved.
+	AllowedCIDRs []string `json:"allowed_cidrs,omitempty"`
+	// UpstreamProxy means the hosts in the route's cluster are forward proxies too,
+	// a CONNECT request is sent to the chosen host before tunneling
+	UpstreamProxy bool `json:"upstream_proxy,omitempty"`
+}
+
+// StreamTimeoutConfig represents the timeouts of the proxy streams, it is configured in the listener and the route,
+// the route's config overrides the listener's. The timeout is disabled if it is not configured or zero.
+type StreamTimeoutConfig struct {
+	// StreamIdleTimeout resets the stream if there is no data received or sent within the duration
+	StreamIdleTimeout *api.DurationConfig `json:"stream_idle_timeout,omitempty"`
+	// MaxStreamDuration resets the stream if it is not finished within the duration
+	MaxStreamDuration *api.DurationConfig `json:"max_stream_duration,omitempty"`
 }
 
 // DirectResponseAction represents the direct response parameters
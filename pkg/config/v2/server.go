@@ -35,6 +35,8 @@ type ServerConfig struct {
 	UseNetpollMode bool `json:"use_netpoll_mode,omitempty"`
 	//graceful shutdown config
 	GracefulTimeout api.DurationConfig `json:"graceful_timeout,omitempty"`
+	//drain connections config
+	DrainTimeout api.DurationConfig `json:"drain_timeout,omitempty"`
 
 	//go processor number
 	Processor int `json:"processor,omitempty"`
@@ -62,6 +64,7 @@ type ListenerConfig struct {
 	StreamFilters         []Filter            `json:"stream_filters,omitempty"`
 	Inspector             bool                `json:"inspector,omitempty"`
 	ConnectionIdleTimeout *api.DurationConfig `json:"connection_idle_timeout,omitempty"`
+	StreamTimeoutConfig
 }
 
 // Listener contains the listener's information
@@ -87,6 +87,26 @@ type Cluster struct {
 	Hosts                []Host              `json:"hosts,omitempty"`
 	ConnectTimeout       *api.DurationConfig `json:"connect_timeout,omitempty"`
 	LbConfig             IsCluster_LbConfig  `json:"lbconfig,omitempty"`
+	ConnPool             ConnPoolConfig      `json:"conn_pool,omitempty"`
+}
+
+// ConnPoolAssignment is how the streams are assigned to the connections of a host
+type ConnPoolAssignment string
+
+// The assignments of the connection pool
+const (
+	LeastStreams ConnPoolAssignment = "least_streams"
+	RoundRobin   ConnPoolAssignment = "round_robin"
+)
+
+// ConnPoolConfig is a configuration of the multiplexed connections to each host of the cluster
+type ConnPoolConfig struct {
+	// ConnectionsPerHost is the number of connections to each host, 1 by default
+	ConnectionsPerHost uint32 `json:"connections_per_host,omitempty"`
+	// Assignment is least_streams by default
+	Assignment ConnPoolAssignment `json:"assignment,omitempty"`
+	// MaxStreamsPerConnection is the max active streams of a connection, 0 means no limit
+	MaxStreamsPerConnection uint32 `json:"max_streams_per_connection,omitempty"`
 }
 
 // HealthCheck is a configuration of health check
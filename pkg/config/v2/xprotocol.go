@@ -0,0 +1,98 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v2
+
+// XProtocolCodecConfig describes a length-prefixed xprotocol sub-protocol, which is registered at config load.
+// A frame is made up of a fixed header containing all the fields, an optional header block and the body.
+type XProtocolCodecConfig struct {
+	// Name is the sub protocol name used by the proxy and the clusters
+	Name string `json:"name"`
+	// ByteOrder is the default byte order of the fields, big or little, big by default
+	ByteOrder string `json:"byte_order,omitempty"`
+	// HeaderSize is the size of the fixed header
+	HeaderSize int `json:"header_size"`
+	// MaxFrameSize limits the size of a frame, 16MB by default
+	MaxFrameSize int `json:"max_frame_size,omitempty"`
+	// Magic is used to recognize the protocol, it is compared with the fixed header
+	Magic XProtocolMagicConfig `json:"magic"`
+	// Length is the length field, the frame size is the end offset of the field plus the length and the adjustment
+	Length           XProtocolFieldConfig `json:"length"`
+	LengthAdjustment int                  `json:"length_adjustment,omitempty"`
+	// RequestID is the request id field, the requests are sent one at a time on a connection if it is not set
+	RequestID *XProtocolFieldConfig `json:"request_id,omitempty"`
+	// Response marks the responses, the frames not marked are requests
+	Response XProtocolMarkerConfig `json:"response"`
+	// Heartbeat and Oneway mark the heartbeats and the oneway requests, they are optional
+	Heartbeat *XProtocolMarkerConfig `json:"heartbeat,omitempty"`
+	Oneway    *XProtocolMarkerConfig `json:"oneway,omitempty"`
+	// Status is the status field of the responses
+	Status *XProtocolStatusConfig `json:"status,omitempty"`
+	// Headers is the header block following the fixed header
+	Headers *XProtocolHeadersConfig `json:"headers,omitempty"`
+	// ServiceKey and MethodKey are the header keys of the service name and the method name
+	ServiceKey string `json:"service_key,omitempty"`
+	MethodKey  string `json:"method_key,omitempty"`
+}
+
+// XProtocolFieldConfig is an unsigned integer field of the fixed header
+type XProtocolFieldConfig struct {
+	Offset int `json:"offset"`
+	// Width is the size of the field, 1, 2, 4 or 8
+	Width int `json:"width"`
+	// ByteOrder overrides the default byte order of the codec
+	ByteOrder string `json:"byte_order,omitempty"`
+}
+
+// XProtocolMagicConfig is the magic bytes at the offset of the fixed header
+type XProtocolMagicConfig struct {
+	Offset int `json:"offset"`
+	// Value is the magic bytes in hex
+	Value string `json:"value"`
+}
+
+// XProtocolMarkerConfig marks a kind of frames, a frame is marked if the field masked equals to the value.
+// The unset value is written to the frames built by mosn that are not marked.
+type XProtocolMarkerConfig struct {
+	XProtocolFieldConfig
+	// Mask is all the bits of the field by default
+	Mask       uint64 `json:"mask,omitempty"`
+	Value      uint64 `json:"value"`
+	UnsetValue uint64 `json:"unset_value,omitempty"`
+}
+
+// XProtocolStatusConfig is the status field of the responses
+type XProtocolStatusConfig struct {
+	XProtocolFieldConfig
+	// Success is the status of the successful responses
+	Success uint64 `json:"success"`
+	// Codes maps the http status codes used by mosn to the statuses, e.g. "404": 4, the others are mapped to the error
+	Codes map[string]uint64 `json:"codes,omitempty"`
+	// Error is the status of the errors not in the codes
+	Error uint64 `json:"error"`
+}
+
+// XProtocolHeadersConfig is the header block following the fixed header
+type XProtocolHeadersConfig struct {
+	// Length is the field of the header block size
+	Length XProtocolFieldConfig `json:"length"`
+	// Encoding is the encoding of the key value pairs, length_prefixed or text, length_prefixed by default.
+	// The keys and the values are prefixed by their lengths in length_prefixed encoding,
+	// and they are encoded as "key: value\n" lines in text encoding.
+	Encoding string `json:"encoding,omitempty"`
+	// StringLengthWidth is the width of the length prefixes, 1, 2 or 4, 2 by default
+	StringLengthWidth int `json:"string_length_width,omitempty"`
+}
@@ -36,6 +36,9 @@ type faultInjectConfigFactory struct {
 func (f *faultInjectConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
 	rf := NewFaultInjector(f.FaultInject)
 	callbacks.AddReadFilter(rf)
+	if f.FaultInject.WriteBandwidth != nil || f.FaultInject.PartialWrite != nil {
+		callbacks.AddWriteFilter(rf)
+	}
 }
 
 func CreateFaultInjectFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
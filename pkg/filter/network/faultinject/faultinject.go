@@ -19,12 +19,15 @@ package faultinject
 
 import (
 	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/utils"
 )
 
@@ -34,17 +37,78 @@ type faultInjector struct {
 	delayDuration uint64
 	delaying      uint32
 	readCallbacks api.ReadFilterCallbacks
+
+	// the connection faults are decided when the connection is created
+	abort        bool
+	abortAfter   uint64
+	bytesRead    uint64
+	readLimiter  *bandwidthLimiter
+	throttling   uint32
+	writeLimiter *bandwidthLimiter
+	partialWrite bool
+	writeLimit   uint64
+	bytesWritten uint64
+	bytesSent    uint64
+	// pendingRead is the bytes read but not checked by the filter
+	pendingRead uint64
+
+	// writeMux keeps the order of the throttled writes, the writes are held in pendingWrite
+	// until the bandwidth allows, and resumed as releasedWrite which passes the filter
+	writeMux      sync.Mutex
+	pendingWrite  buffer.IoBuffer
+	writeReadyAt  time.Time
+	releasedWrite atomic.Value
 }
 
-// NewFaultInjector makes a fault injector as types.ReadFilter
-func NewFaultInjector(config *v2.FaultInject) api.ReadFilter {
-	return &faultInjector{
+// FaultInjector is both the read filter and the write filter
+type FaultInjector interface {
+	api.ReadFilter
+	api.WriteFilter
+}
+
+// NewFaultInjector makes a fault injector as types.ReadFilter and types.WriteFilter
+func NewFaultInjector(config *v2.FaultInject) FaultInjector {
+	fi := &faultInjector{
 		delayPercent:  config.DelayPercent,
 		delayDuration: config.DelayDuration,
 	}
+	if abort := config.Abort; abort != nil && hit(abort.Percent) {
+		fi.abort = true
+		fi.abortAfter = abort.AfterBytes
+	}
+	if bw := config.ReadBandwidth; bw != nil && bw.KiBPerSecond > 0 && hit(bw.Percent) {
+		fi.readLimiter = newBandwidthLimiter(bw.KiBPerSecond)
+	}
+	if bw := config.WriteBandwidth; bw != nil && bw.KiBPerSecond > 0 && hit(bw.Percent) {
+		fi.writeLimiter = newBandwidthLimiter(bw.KiBPerSecond)
+	}
+	if pw := config.PartialWrite; pw != nil && hit(pw.Percent) {
+		fi.partialWrite = true
+		fi.writeLimit = pw.Bytes
+	}
+	return fi
+}
+
+// hit returns true in percent(1~100) of the calls
+func hit(percent uint32) bool {
+	return percent > 0 && uint32(rand.Intn(100))+1 <= percent
 }
 
 func (fi *faultInjector) OnData(buffer types.IoBuffer) api.FilterStatus {
+	if fi.abort {
+		fi.bytesRead += atomic.SwapUint64(&fi.pendingRead, 0)
+		if fi.bytesRead >= fi.abortAfter {
+			log.DefaultLogger.Infof("[network filter][fault inject] abort connection after %d bytes read", fi.bytesRead)
+			buffer.Drain(buffer.Len())
+			fi.readCallbacks.Connection().Close(api.NoFlush, api.LocalClose)
+			return api.Stop
+		}
+	}
+
+	if fi.tryThrottleRead() {
+		return api.Stop
+	}
+
 	fi.tryInjectDelay()
 
 	if atomic.LoadUint32(&fi.delaying) > 0 {
@@ -55,11 +119,164 @@ func (fi *faultInjector) OnData(buffer types.IoBuffer) api.FilterStatus {
 }
 
 func (fi *faultInjector) OnNewConnection() api.FilterStatus {
+	if fi.abort && fi.abortAfter == 0 {
+		log.DefaultLogger.Infof("[network filter][fault inject] abort connection before reading")
+		fi.readCallbacks.Connection().Close(api.NoFlush, api.LocalClose)
+		return api.Stop
+	}
 	return api.Continue
 }
 
 func (fi *faultInjector) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
 	fi.readCallbacks = cb
+	conn := cb.Connection()
+	if fi.abort || fi.readLimiter != nil {
+		conn.AddBytesReadListener(func(bytesRead uint64) {
+			atomic.AddUint64(&fi.pendingRead, bytesRead)
+		})
+	}
+	if fi.partialWrite {
+		conn.AddBytesSentListener(fi.onBytesSent)
+	}
+}
+
+// releasedWrite is the throttled data written again by the filter
+type releasedWrite struct {
+	buf buffer.IoBuffer
+}
+
+// OnWrite truncates the data for the partial write, and holds the data until the bytes written are allowed
+// by the bandwidth without blocking the writer
+func (fi *faultInjector) OnWrite(buffers []buffer.IoBuffer) api.FilterStatus {
+	if len(buffers) == 1 && fi.isReleasedWrite(buffers[0]) {
+		return api.Continue
+	}
+	if fi.partialWrite && fi.truncateWrite(buffers) {
+		return api.Stop
+	}
+	if fi.writeLimiter != nil && fi.tryThrottleWrite(buffers) {
+		return api.Stop
+	}
+	return api.Continue
+}
+
+func (fi *faultInjector) isReleasedWrite(buf buffer.IoBuffer) bool {
+	released, _ := fi.releasedWrite.Load().(releasedWrite)
+	return released.buf != nil && released.buf == buf
+}
+
+// tryThrottleWrite holds the data if the bytes written exceed the bandwidth, the data written after
+// the held data is held too, so the order of the data is kept
+func (fi *faultInjector) tryThrottleWrite(buffers []buffer.IoBuffer) bool {
+	var size int
+	for _, buf := range buffers {
+		if buf != nil {
+			size += buf.Len()
+		}
+	}
+	fi.writeMux.Lock()
+	defer fi.writeMux.Unlock()
+	wait := fi.writeLimiter.take(size)
+	if fi.pendingWrite == nil {
+		if wait <= 0 {
+			return false
+		}
+		fi.pendingWrite = buffer.GetIoBuffer(size)
+		utils.GoWithRecover(func() {
+			fi.releaseWrite(wait)
+		}, nil)
+	}
+	// the buffers are owned by the writer, so the data is copied
+	for _, buf := range buffers {
+		if buf != nil {
+			fi.pendingWrite.Write(buf.Bytes())
+		}
+	}
+	fi.writeReadyAt = time.Now().Add(wait)
+	return true
+}
+
+// releaseWrite writes the held data after the wait, it waits more if more data is held during the wait
+func (fi *faultInjector) releaseWrite(wait time.Duration) {
+	for wait > 0 {
+		time.Sleep(wait)
+		fi.writeMux.Lock()
+		wait = time.Until(fi.writeReadyAt)
+		fi.writeMux.Unlock()
+	}
+	fi.writeMux.Lock()
+	defer fi.writeMux.Unlock()
+	buf := fi.pendingWrite
+	fi.pendingWrite = nil
+	// the lock is held during the writing, so the data written meanwhile is held after this
+	fi.releasedWrite.Store(releasedWrite{buf: buf})
+	if err := fi.readCallbacks.Connection().Write(buf); err != nil {
+		log.DefaultLogger.Debugf("[network filter][fault inject] write the throttled data failed: %v", err)
+	}
+	fi.releasedWrite.Store(releasedWrite{})
+}
+
+// tryThrottleRead stops reading the connection until the bytes read are allowed by the bandwidth
+func (fi *faultInjector) tryThrottleRead() bool {
+	if fi.readLimiter == nil {
+		return false
+	}
+	if atomic.LoadUint32(&fi.throttling) > 0 {
+		return true
+	}
+	wait := fi.readLimiter.take(int(atomic.SwapUint64(&fi.pendingRead, 0)))
+	if wait <= 0 {
+		return false
+	}
+	conn := fi.readCallbacks.Connection()
+	atomic.StoreUint32(&fi.throttling, 1)
+	conn.SetReadDisable(true)
+	utils.GoWithRecover(func() {
+		time.Sleep(wait)
+		atomic.StoreUint32(&fi.throttling, 0)
+		fi.readCallbacks.ContinueReading()
+		conn.SetReadDisable(false)
+	}, nil)
+	return true
+}
+
+// truncateWrite keeps the first writeLimit bytes of the writing, and returns true if nothing should be written
+func (fi *faultInjector) truncateWrite(buffers []buffer.IoBuffer) bool {
+	left := int64(fi.writeLimit) - int64(atomic.LoadUint64(&fi.bytesWritten))
+	if left <= 0 {
+		return true
+	}
+	var written int64
+	for i, buf := range buffers {
+		if buf == nil {
+			continue
+		}
+		n := int64(buf.Len())
+		if n > left {
+			n = left
+			// the buffer may be shared, so it is replaced instead of modified
+			buffers[i] = buffer.NewIoBufferBytes(buf.Bytes()[:n])
+		}
+		left -= n
+		written += n
+	}
+	atomic.AddUint64(&fi.bytesWritten, uint64(written))
+	return false
+}
+
+// onBytesSent closes the connection when the partial write is sent
+func (fi *faultInjector) onBytesSent(bytesSent uint64) {
+	if atomic.AddUint64(&fi.bytesSent, bytesSent) < fi.writeLimit {
+		return
+	}
+	if atomic.LoadUint64(&fi.bytesWritten) < fi.writeLimit {
+		return
+	}
+	conn := fi.readCallbacks.Connection()
+	log.DefaultLogger.Infof("[network filter][fault inject] close connection after partial write %d bytes", fi.writeLimit)
+	utils.GoWithRecover(func() {
+		conn.Close(api.NoFlush, api.LocalClose)
+	}, nil)
 }
 
 func (fi *faultInjector) tryInjectDelay() {
@@ -93,3 +310,29 @@ func (fi *faultInjector) getDelayDuration() uint64 {
 
 	return fi.delayDuration
 }
+
+// bandwidthLimiter computes the wait of the bytes by the bandwidth
+type bandwidthLimiter struct {
+	mux sync.Mutex
+	// bytes per second
+	rate    float64
+	readyAt time.Time
+}
+
+func newBandwidthLimiter(kibPerSecond uint64) *bandwidthLimiter {
+	return &bandwidthLimiter{
+		rate: float64(kibPerSecond * 1024),
+	}
+}
+
+// take takes the bytes, and returns the wait until the bytes are transferred
+func (l *bandwidthLimiter) take(n int) time.Duration {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	now := time.Now()
+	if l.readyAt.Before(now) {
+		l.readyAt = now
+	}
+	l.readyAt = l.readyAt.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	return l.readyAt.Sub(now)
+}
@@ -0,0 +1,216 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package faultinject
+
+import (
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/config/v2"
+	"mosn.io/pkg/buffer"
+)
+
+func newInjector(t *testing.T, conf map[string]interface{}) (FaultInjector, *mockReadFilterCallbacks) {
+	cfg, err := ParseFaultInjectFilter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi := NewFaultInjector(cfg)
+	cb := newMockReadFilterCallbacks()
+	fi.InitializeReadFilterCallbacks(cb)
+	cb.conn.filter = fi
+	return fi, cb
+}
+
+func isClosed(c *mockConnection, timeout time.Duration) bool {
+	if timeout == 0 {
+		select {
+		case <-c.closed:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case <-c.closed:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestAbortImmediately(t *testing.T) {
+	fi, cb := newInjector(t, map[string]interface{}{
+		"abort": map[string]interface{}{"percentage": 100},
+	})
+	if fi.OnNewConnection() != api.Stop || !isClosed(cb.conn, 0) {
+		t.Fatal("expected connection aborted before reading")
+	}
+	buf := buffer.NewIoBufferString("hello")
+	cb.conn.onRead(5)
+	if fi.OnData(buf) != api.Stop || buf.Len() != 0 || !isClosed(cb.conn, 0) {
+		t.Fatal("expected connection aborted")
+	}
+}
+
+func TestAbortAfterBytes(t *testing.T) {
+	fi, cb := newInjector(t, map[string]interface{}{
+		"abort": map[string]interface{}{"percentage": 100, "after_bytes": 10},
+	})
+	buf := buffer.NewIoBufferString("hello")
+	cb.conn.onRead(5)
+	if fi.OnData(buf) != api.Continue || isClosed(cb.conn, 0) {
+		t.Fatal("expected connection not aborted")
+	}
+	buf.WriteString("world")
+	cb.conn.onRead(5)
+	if fi.OnData(buf) != api.Stop || !isClosed(cb.conn, 0) {
+		t.Fatal("expected connection aborted")
+	}
+}
+
+func TestAbortPercent(t *testing.T) {
+	fi, cb := newInjector(t, map[string]interface{}{
+		"abort": map[string]interface{}{"percentage": 0},
+	})
+	cb.conn.onRead = func(uint64) {}
+	if fi.OnData(buffer.NewIoBufferString("hello")) != api.Continue || isClosed(cb.conn, 0) {
+		t.Fatal("expected connection not aborted")
+	}
+}
+
+func TestReadBandwidth(t *testing.T) {
+	fi, cb := newInjector(t, map[string]interface{}{
+		"read_bandwidth": map[string]interface{}{"percentage": 100, "kib_per_second": 10},
+	})
+	// 2KiB takes 200ms with 10KiB/s
+	buf := buffer.NewIoBufferBytes(make([]byte, 2048))
+	cb.conn.onRead(2048)
+	start := time.Now()
+	if fi.OnData(buf) != api.Stop || !cb.conn.isReadDisabled() {
+		t.Fatal("expected reading throttled")
+	}
+	// stopped until the throttling ends
+	if fi.OnData(buf) != api.Stop {
+		t.Fatal("expected reading throttled")
+	}
+	select {
+	case <-cb.continued:
+	case <-time.After(time.Second):
+		t.Fatal("expected continue reading")
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("unexpected throttling: %v", elapsed)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if cb.conn.isReadDisabled() {
+		t.Fatal("expected reading enabled")
+	}
+	if fi.OnData(buf) != api.Continue {
+		t.Fatal("expected the throttled bytes continued")
+	}
+}
+
+func TestWriteBandwidth(t *testing.T) {
+	_, cb := newInjector(t, map[string]interface{}{
+		"write_bandwidth": map[string]interface{}{"percentage": 100, "kib_per_second": 1},
+	})
+	// 1KiB takes 1s with 1KiB/s, the writer is not blocked
+	start := time.Now()
+	for _, data := range []string{"a", "b", "c"} {
+		if err := cb.conn.Write(buffer.NewIoBufferString(data), buffer.NewIoBufferBytes(make([]byte, 255)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected the writer not blocked, but got %v", elapsed)
+	}
+	// the data is written in order after 768B are transferred
+	select {
+	case data := <-cb.conn.written:
+		if len(data) != 768 || data[0] != 'a' || data[256] != 'b' || data[512] != 'c' {
+			t.Fatalf("unexpected data written: %d bytes", len(data))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the throttled data written")
+	}
+	if elapsed := time.Since(start); elapsed < 700*time.Millisecond {
+		t.Fatalf("unexpected throttling: %v", elapsed)
+	}
+	// the data written later is held by its own size only
+	if err := cb.conn.Write(buffer.NewIoBufferString("d")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-cb.conn.written:
+		if data != "d" {
+			t.Fatalf("unexpected data written: %s", data)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("expected the data written")
+	}
+}
+
+func TestPartialWrite(t *testing.T) {
+	fi, cb := newInjector(t, map[string]interface{}{
+		"partial_write": map[string]interface{}{"percentage": 100, "bytes": 8},
+	})
+	first := buffer.NewIoBufferString("hello")
+	buffers := []buffer.IoBuffer{first, buffer.NewIoBufferString("world")}
+	if fi.OnWrite(buffers) != api.Continue {
+		t.Fatal("expected the partial data written")
+	}
+	if buffers[0] != first || buffers[1].String() != "wor" {
+		t.Fatalf("unexpected partial write: %s%s", buffers[0].String(), buffers[1].String())
+	}
+	// nothing is written after the partial write
+	if fi.OnWrite([]buffer.IoBuffer{buffer.NewIoBufferString("!")}) != api.Stop {
+		t.Fatal("expected writing stopped")
+	}
+	cb.conn.onSent(5)
+	if isClosed(cb.conn, 50*time.Millisecond) {
+		t.Fatal("expected connection not closed before the partial data sent")
+	}
+	cb.conn.onSent(3)
+	if !isClosed(cb.conn, time.Second) {
+		t.Fatal("expected connection closed after the partial data sent")
+	}
+}
+
+func TestParseConnectionFaults(t *testing.T) {
+	cfg, err := ParseFaultInjectFilter(map[string]interface{}{
+		"abort":           map[string]interface{}{"percentage": 50, "after_bytes": 100},
+		"read_bandwidth":  map[string]interface{}{"percentage": 100, "kib_per_second": 1},
+		"write_bandwidth": map[string]interface{}{"percentage": 100, "kib_per_second": 2},
+		"partial_write":   map[string]interface{}{"percentage": 10, "bytes": 64},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := v2.FaultInjectConfig{
+		Abort:          &v2.ConnectionAbortInject{Percent: 50, AfterBytes: 100},
+		ReadBandwidth:  &v2.BandwidthInject{Percent: 100, KiBPerSecond: 1},
+		WriteBandwidth: &v2.BandwidthInject{Percent: 100, KiBPerSecond: 2},
+		PartialWrite:   &v2.PartialWriteInject{Percent: 10, Bytes: 64},
+	}
+	if *cfg.Abort != *expected.Abort || *cfg.ReadBandwidth != *expected.ReadBandwidth ||
+		*cfg.WriteBandwidth != *expected.WriteBandwidth || *cfg.PartialWrite != *expected.PartialWrite {
+		t.Fatalf("unexpected config: %+v", cfg.FaultInjectConfig)
+	}
+}
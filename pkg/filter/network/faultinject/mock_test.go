@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package faultinject
+
+import (
+	"sync"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockReadFilterCallbacks struct {
+	api.ReadFilterCallbacks
+	conn      *mockConnection
+	continued chan struct{}
+}
+
+func newMockReadFilterCallbacks() *mockReadFilterCallbacks {
+	return &mockReadFilterCallbacks{
+		conn:      &mockConnection{closed: make(chan struct{}), written: make(chan string, 16)},
+		continued: make(chan struct{}, 1),
+	}
+}
+
+func (cb *mockReadFilterCallbacks) Connection() api.Connection {
+	return cb.conn
+}
+
+func (cb *mockReadFilterCallbacks) ContinueReading() {
+	cb.continued <- struct{}{}
+}
+
+type mockConnection struct {
+	api.Connection
+	mux          sync.Mutex
+	onRead       func(bytesRead uint64)
+	onSent       func(bytesSent uint64)
+	readDisabled bool
+	closeOnce    sync.Once
+	closed       chan struct{}
+	// the data written passes the filter, and the data not stopped is sent to written
+	filter  api.WriteFilter
+	written chan string
+}
+
+func (c *mockConnection) Write(buffers ...buffer.IoBuffer) error {
+	if c.filter != nil && c.filter.OnWrite(buffers) == api.Stop {
+		return nil
+	}
+	var data string
+	for _, buf := range buffers {
+		if buf != nil {
+			data += buf.String()
+		}
+	}
+	c.written <- data
+	return nil
+}
+
+func (c *mockConnection) AddBytesReadListener(listener func(bytesRead uint64)) {
+	c.onRead = listener
+}
+
+func (c *mockConnection) AddBytesSentListener(listener func(bytesSent uint64)) {
+	c.onSent = listener
+}
+
+func (c *mockConnection) SetReadDisable(disable bool) {
+	c.mux.Lock()
+	c.readDisabled = disable
+	c.mux.Unlock()
+}
+
+func (c *mockConnection) isReadDisabled() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.readDisabled
+}
+
+func (c *mockConnection) Close(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
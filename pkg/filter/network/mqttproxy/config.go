@@ -0,0 +1,172 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqttproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"mosn.io/mosn/pkg/protocol/mqtt"
+)
+
+const defaultMaxTopicStats = 1000
+
+// acl actions
+const (
+	actionPublish   = "publish"
+	actionSubscribe = "subscribe"
+)
+
+type config struct {
+	// StatPrefix is the label of the stats
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// Cluster is the cluster of the connections that match no routes
+	Cluster string `json:"cluster,omitempty"`
+	// Routes are matched in order by the CONNECT packet, the first matched route is used
+	Routes []*routeConfig `json:"routes,omitempty"`
+	// ACLs are matched in order by the publications and subscriptions, the first matched acl is used
+	ACLs []*aclConfig `json:"acls,omitempty"`
+	// ACLDefaultDeny denies the publications and subscriptions that match no ACLs
+	ACLDefaultDeny bool `json:"acl_default_deny,omitempty"`
+	// MaxTopicStats limits the number of topics that have their own stats, the stats of the
+	// other topics are merged, 1000 by default
+	MaxTopicStats int `json:"max_topic_stats,omitempty"`
+}
+
+// principal matches the client id and the username of the CONNECT packet by regular expressions,
+// the empty one matches everything
+type principal struct {
+	ClientID string `json:"client_id,omitempty"`
+	Username string `json:"username,omitempty"`
+
+	clientID *regexp.Regexp
+	username *regexp.Regexp
+}
+
+type routeConfig struct {
+	principal
+	Cluster string `json:"cluster"`
+}
+
+type aclConfig struct {
+	principal
+	// Topic is the topic filter of the acl, the wildcards are supported
+	Topic string `json:"topic"`
+	// Action is publish or subscribe, the acl applies to both if it is empty
+	Action string `json:"action,omitempty"`
+	Allow  bool   `json:"allow,omitempty"`
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.Cluster == "" && len(filterConfig.Routes) == 0 {
+		return nil, errors.New("cluster or routes of mqtt proxy is required")
+	}
+	for _, route := range filterConfig.Routes {
+		if route.Cluster == "" {
+			return nil, errors.New("cluster of mqtt proxy route is required")
+		}
+		if err := route.compile(); err != nil {
+			return nil, err
+		}
+	}
+	for _, acl := range filterConfig.ACLs {
+		if acl.Topic == "" {
+			return nil, errors.New("topic of mqtt proxy acl is required")
+		}
+		if acl.Action != "" && acl.Action != actionPublish && acl.Action != actionSubscribe {
+			return nil, fmt.Errorf("unknown action of mqtt proxy acl: %s", acl.Action)
+		}
+		if err := acl.compile(); err != nil {
+			return nil, err
+		}
+	}
+	if filterConfig.MaxTopicStats <= 0 {
+		filterConfig.MaxTopicStats = defaultMaxTopicStats
+	}
+	return filterConfig, nil
+}
+
+func (p *principal) compile() (err error) {
+	if p.ClientID != "" {
+		if p.clientID, err = regexp.Compile(p.ClientID); err != nil {
+			return err
+		}
+	}
+	if p.Username != "" {
+		if p.username, err = regexp.Compile(p.Username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *principal) match(connect *mqtt.Connect) bool {
+	if p.clientID != nil && !p.clientID.MatchString(connect.ClientID) {
+		return false
+	}
+	if p.username != nil && !p.username.MatchString(connect.Username) {
+		return false
+	}
+	return true
+}
+
+// route returns the cluster of the connection
+func (c *config) route(connect *mqtt.Connect) string {
+	for _, route := range c.Routes {
+		if route.match(connect) {
+			return route.Cluster
+		}
+	}
+	return c.Cluster
+}
+
+// allowPublish checks the acls of the topic name
+func (c *config) allowPublish(connect *mqtt.Connect, topic string) bool {
+	for _, acl := range c.ACLs {
+		if acl.Action != actionSubscribe && acl.match(connect) && mqtt.MatchTopic(acl.Topic, topic) {
+			return acl.Allow
+		}
+	}
+	return !c.ACLDefaultDeny
+}
+
+// allowSubscribe checks the acls of the topic filter, an allowing acl applies if it covers all
+// the topics of the filter, and a denying acl applies if it matches any topic of the filter
+func (c *config) allowSubscribe(connect *mqtt.Connect, filter string) bool {
+	for _, acl := range c.ACLs {
+		if acl.Action == actionPublish || !acl.match(connect) {
+			continue
+		}
+		if acl.Allow && mqtt.CoverFilter(acl.Topic, filter) {
+			return true
+		}
+		if !acl.Allow && mqtt.OverlapFilter(acl.Topic, filter) {
+			return false
+		}
+	}
+	return !c.ACLDefaultDeny
+}
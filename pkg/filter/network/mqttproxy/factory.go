@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqttproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+func init() {
+	api.RegisterNetwork(v2.MQTT_PROXY, CreateMQTTProxyFactory)
+}
+
+type mqttProxyFilterConfigFactory struct {
+	config *config
+	stats  *proxyStats
+}
+
+func (f *mqttProxyFilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	callbacks.AddReadFilter(newMQTTProxy(f))
+}
+
+// CreateMQTTProxyFactory creates the factory of the mqtt proxy filter
+func CreateMQTTProxyFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &mqttProxyFilterConfigFactory{
+		config: cfg,
+		stats:  newProxyStats(cfg.StatPrefix, cfg.MaxTopicStats),
+	}, nil
+}
+
+// selectHost returns the host of the client, the clients with the same client id always connect to
+// the same healthy host, so that the persistent sessions on the broker are resumed after reconnecting
+func selectHost(snapshot types.ClusterSnapshot, clientID string) (types.Host, error) {
+	var hosts []types.Host
+	for _, host := range snapshot.HostSet().Hosts() {
+		if host.Health() {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("no healthy host in cluster " + snapshot.ClusterInfo().Name())
+	}
+	if clientID == "" {
+		// the client id is assigned by the broker
+		return hosts[rand.Intn(len(hosts))], nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(clientID))
+	return hosts[h.Sum32()%uint32(len(hosts))], nil
+}
+
+func getClusterSnapshot(name string) (types.ClusterSnapshot, error) {
+	snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), name)
+	if snapshot == nil {
+		return nil, fmt.Errorf("cluster %s not found", name)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,301 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqttproxy
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol/mqtt"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockReadFilterCallbacks struct {
+	api.ReadFilterCallbacks
+	conn *mockConnection
+}
+
+func (cb *mockReadFilterCallbacks) Connection() api.Connection {
+	return cb.conn
+}
+
+func (cb *mockReadFilterCallbacks) SetUpstreamHost(upstreamHost api.HostInfo) {}
+
+type mockConnection struct {
+	api.Connection
+	mux       sync.Mutex
+	written   []byte
+	closed    bool
+	listeners []api.ConnectionEventListener
+}
+
+func (c *mockConnection) AddConnectionEventListener(listener api.ConnectionEventListener) {
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *mockConnection) Write(buffers ...buffer.IoBuffer) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for _, buf := range buffers {
+		c.written = append(c.written, buf.Bytes()...)
+	}
+	return nil
+}
+
+func (c *mockConnection) Close(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mux.Unlock()
+	for _, listener := range c.listeners {
+		listener.OnEvent(eventType)
+	}
+	return nil
+}
+
+func (c *mockConnection) isClosed() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.closed
+}
+
+// readPackets waits for n packets written to the connection
+func (c *mockConnection) readPackets(t *testing.T, n int) []*mqtt.Packet {
+	var packets []*mqtt.Packet
+	for i := 0; i < 100; i++ {
+		c.mux.Lock()
+		data := c.written
+		packets = packets[:0]
+		for len(data) > 0 {
+			p, err := mqtt.Decode(data)
+			if err != nil {
+				c.mux.Unlock()
+				t.Fatalf("decode packet failed: %v", err)
+			}
+			if p == nil {
+				break
+			}
+			packets = append(packets, p)
+			data = data[len(p.Raw):]
+		}
+		c.mux.Unlock()
+		if len(packets) >= n {
+			return packets
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+	t.Fatalf("expected %d packets, but got %d", n, len(packets))
+	return nil
+}
+
+// fakeBroker is a in-process broker that acknowledges the packets, and publishes a message
+// to each subscribed topic filter after the SUBACK.
+type fakeBroker struct {
+	ln net.Listener
+
+	mux        sync.Mutex
+	conns      []net.Conn
+	clients    []string
+	published  []string
+	subscribed []string
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	b := &fakeBroker{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mux.Lock()
+			b.conns = append(b.conns, conn)
+			b.mux.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) Addr() string {
+	return b.ln.Addr().String()
+}
+
+func (b *fakeBroker) Close() {
+	b.ln.Close()
+	b.closeClients()
+}
+
+func (b *fakeBroker) closeClients() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+func (b *fakeBroker) record(list *[]string, value string) {
+	b.mux.Lock()
+	*list = append(*list, value)
+	b.mux.Unlock()
+}
+
+func (b *fakeBroker) snapshot(list *[]string) []string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return append([]string{}, *list...)
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	var version byte
+	var data []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		data = append(data, buf[:n]...)
+		for {
+			p, err := mqtt.Decode(data)
+			if err != nil {
+				return
+			}
+			if p == nil {
+				break
+			}
+			data = data[len(p.Raw):]
+			var reply []byte
+			switch p.Type {
+			case mqtt.CONNECT:
+				c, err := mqtt.ParseConnect(p.Body)
+				if err != nil {
+					return
+				}
+				version = c.ProtocolLevel
+				b.record(&b.clients, c.ClientID)
+				reply = mqtt.EncodeConnAck(version, false, 0)
+			case mqtt.PUBLISH:
+				pub, err := mqtt.ParsePublish(p, version)
+				if err != nil {
+					return
+				}
+				b.record(&b.published, pub.Topic)
+				if pub.QoS == 1 {
+					reply = mqtt.EncodeAck(mqtt.PUBACK, pub.PacketID, version, 0)
+				} else if pub.QoS == 2 {
+					reply = mqtt.EncodeAck(mqtt.PUBREC, pub.PacketID, version, 0)
+				}
+			case mqtt.PUBREL:
+				id, _ := mqtt.PacketID(p.Body)
+				reply = mqtt.EncodeAck(mqtt.PUBCOMP, id, version, 0)
+			case mqtt.SUBSCRIBE:
+				sub, err := mqtt.ParseSubscribe(p.Body, version)
+				if err != nil {
+					return
+				}
+				ack := &mqtt.SubAck{PacketID: sub.PacketID}
+				var messages []byte
+				for _, s := range sub.Subscriptions {
+					b.record(&b.subscribed, s.Filter)
+					ack.ReasonCodes = append(ack.ReasonCodes, s.Options&0x03)
+					messages = append(messages, encodePublish(version, s.Filter, 0, 0, "hello")...)
+				}
+				reply = append(ack.Encode(version), messages...)
+			case mqtt.PINGREQ:
+				reply = mqtt.Encode(mqtt.PINGRESP, 0, nil)
+			case mqtt.DISCONNECT:
+				return
+			}
+			if len(reply) > 0 {
+				conn.Write(reply)
+			}
+		}
+	}
+}
+
+func setupCluster(t *testing.T, name string, brokers ...*fakeBroker) {
+	cluster.NewClusterManagerSingleton(nil, nil)
+	var hosts []v2.Host
+	for _, b := range brokers {
+		hosts = append(hosts, v2.Host{HostConfig: v2.HostConfig{Address: b.Addr()}})
+	}
+	err := cluster.GetClusterMngAdapterInstance().TriggerClusterAndHostsAddOrUpdate(v2.Cluster{
+		Name:        name,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	}, hosts)
+	if err != nil {
+		t.Fatalf("add cluster failed: %v", err)
+	}
+}
+
+func encodeString(dst []byte, s string) []byte {
+	dst = append(dst, byte(len(s)>>8), byte(len(s)))
+	return append(dst, s...)
+}
+
+func encodeConnect(version byte, clientID string) []byte {
+	body := encodeString(nil, "MQTT")
+	body = append(body, version, 0x02, 0, 30)
+	if version == mqtt.Version5 {
+		body = append(body, 0)
+	}
+	body = encodeString(body, clientID)
+	return mqtt.Encode(mqtt.CONNECT, 0, body)
+}
+
+// encodePublish encodes the PUBLISH packet, the topic alias is only set for MQTT 5.0 if it is not zero
+func encodePublish(version byte, topic string, qos byte, packetID uint16, payload string, alias ...uint16) []byte {
+	body := encodeString(nil, topic)
+	if qos > 0 {
+		body = append(body, byte(packetID>>8), byte(packetID))
+	}
+	if version == mqtt.Version5 {
+		if len(alias) > 0 {
+			body = append(body, 3, mqtt.PropertyTopicAlias, byte(alias[0]>>8), byte(alias[0]))
+		} else {
+			body = append(body, 0)
+		}
+	}
+	body = append(body, payload...)
+	return mqtt.Encode(mqtt.PUBLISH, qos<<1, body)
+}
+
+func encodeSubscribe(version byte, packetID uint16, filters ...string) []byte {
+	sub := &mqtt.Subscribe{PacketID: packetID}
+	for _, filter := range filters {
+		sub.Subscriptions = append(sub.Subscriptions, mqtt.Subscription{Filter: filter, Options: 1})
+	}
+	return sub.Encode(version)
+}
@@ -0,0 +1,459 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqttproxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/mqtt"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// mqttProxy is the read filter of the downstream connection. Each client connection is proxied to
+// a dedicated broker connection after the CONNECT packet is routed, the CONNECT and the following
+// packets are forwarded as they are, including the keepalive packets, so the session semantics are
+// kept by the broker. The publications and subscriptions denied by the acls are replied locally.
+// api.ReadFilter
+// api.ConnectionEventListener
+type mqttProxy struct {
+	factory       *mqttProxyFilterConfigFactory
+	readCallbacks api.ReadFilterCallbacks
+
+	// connect is set once the CONNECT packet is received
+	connect *mqtt.Connect
+	// inAliases are the topic aliases of MQTT 5.0 from the client
+	inAliases map[uint16]string
+	// deniedQoS2 are the packet ids of the denied QoS 2 publications, whose PUBREL are replied locally
+	deniedQoS2 map[uint16]struct{}
+
+	mux      sync.Mutex
+	upstream *upstreamFilter
+	closed   bool
+	// pendingSubscribes are the partially denied subscriptions waiting for the SUBACK of the broker,
+	// the reason codes of the denied subscriptions are kept and the forwarded ones are zero
+	pendingSubscribes map[uint16][]byte
+}
+
+// upstreamFilter is the read filter of the broker connection
+// api.ReadFilter
+// api.ConnectionEventListener
+type upstreamFilter struct {
+	proxy *mqttProxy
+	host  types.Host
+	conn  types.ClientConnection
+	// outAliases are the topic aliases of MQTT 5.0 from the broker
+	outAliases map[uint16]string
+	released   uint32
+}
+
+func newMQTTProxy(factory *mqttProxyFilterConfigFactory) *mqttProxy {
+	return &mqttProxy{
+		factory:           factory,
+		inAliases:         make(map[uint16]string),
+		deniedQoS2:        make(map[uint16]struct{}),
+		pendingSubscribes: make(map[uint16][]byte),
+	}
+}
+
+func (p *mqttProxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	var forward []byte
+	for buf.Len() > 0 {
+		packet, err := mqtt.Decode(buf.Bytes())
+		if err != nil {
+			buf.Drain(buf.Len())
+			p.onProtocolError(err)
+			return api.Stop
+		}
+		if packet == nil {
+			break
+		}
+		if p.connect == nil {
+			// the first packet must be CONNECT
+			if packet.Type != mqtt.CONNECT {
+				buf.Drain(buf.Len())
+				p.onProtocolError(mqtt.ErrMalformed)
+				return api.Stop
+			}
+			if !p.onConnect(packet) {
+				buf.Drain(buf.Len())
+				return api.Stop
+			}
+			forward = append(forward, packet.Raw...)
+		} else if forward, err = p.onDownstreamPacket(packet, forward); err != nil {
+			buf.Drain(buf.Len())
+			p.onProtocolError(err)
+			return api.Stop
+		}
+		buf.Drain(len(packet.Raw))
+	}
+	if len(forward) > 0 {
+		p.mux.Lock()
+		upstream := p.upstream
+		p.mux.Unlock()
+		if upstream != nil {
+			upstream.conn.Write(buffer.NewIoBufferBytes(forward))
+		}
+	}
+	return api.Stop
+}
+
+func (p *mqttProxy) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (p *mqttProxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	p.readCallbacks = cb
+	p.readCallbacks.Connection().AddConnectionEventListener(p)
+}
+
+// api.ConnectionEventListener
+func (p *mqttProxy) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	p.mux.Lock()
+	p.closed = true
+	upstream := p.upstream
+	p.mux.Unlock()
+	if upstream == nil {
+		return
+	}
+	if event == api.RemoteClose {
+		// flush the DISCONNECT and the acks to the broker
+		upstream.conn.Close(api.FlushWrite, api.LocalClose)
+	} else {
+		upstream.conn.Close(api.NoFlush, api.LocalClose)
+	}
+}
+
+// onConnect routes the connection and connects to the broker, false is returned if the connection is rejected
+func (p *mqttProxy) onConnect(packet *mqtt.Packet) bool {
+	connect, err := mqtt.ParseConnect(packet.Body)
+	if err != nil {
+		p.onProtocolError(err)
+		return false
+	}
+	p.connect = connect
+	p.factory.stats.connect.Inc(1)
+
+	clusterName := p.factory.config.route(connect)
+	if clusterName == "" {
+		log.DefaultLogger.Errorf("[mqtt proxy] no route for client %s", connect.ClientID)
+		p.reject()
+		return false
+	}
+	snapshot, err := getClusterSnapshot(clusterName)
+	if err != nil {
+		log.DefaultLogger.Errorf("[mqtt proxy] route client %s failed: %v", connect.ClientID, err)
+		p.reject()
+		return false
+	}
+	resource := snapshot.ClusterInfo().ResourceManager().Connections()
+	if !resource.CanCreate() {
+		log.DefaultLogger.Errorf("[mqtt proxy] connections of cluster %s overflow", clusterName)
+		p.reject()
+		return false
+	}
+	host, err := selectHost(snapshot, connect.ClientID)
+	if err != nil {
+		log.DefaultLogger.Errorf("[mqtt proxy] route client %s failed: %v", connect.ClientID, err)
+		p.reject()
+		return false
+	}
+
+	upstream := &upstreamFilter{
+		proxy:      p,
+		host:       host,
+		outAliases: make(map[uint16]string),
+	}
+	conn := host.CreateConnection(context.Background()).Connection
+	upstream.conn = conn
+	conn.AddConnectionEventListener(upstream)
+	conn.FilterManager().AddReadFilter(upstream)
+	resource.Increase()
+	if err := conn.Connect(); err != nil {
+		log.DefaultLogger.Errorf("[mqtt proxy] connect to broker %s failed: %v", host.AddressString(), err)
+		upstream.release()
+		p.reject()
+		return false
+	}
+	conn.SetNoDelay(true)
+
+	p.mux.Lock()
+	closed := p.closed
+	if !closed {
+		p.upstream = upstream
+	}
+	p.mux.Unlock()
+	if closed {
+		conn.Close(api.NoFlush, api.LocalClose)
+		return false
+	}
+	p.readCallbacks.SetUpstreamHost(host)
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[mqtt proxy] client %s is proxied to broker %s", connect.ClientID, host.AddressString())
+	}
+	return true
+}
+
+// reject replies the CONNACK of server unavailable and closes the connection
+func (p *mqttProxy) reject() {
+	p.factory.stats.connectRejected.Inc(1)
+	code := mqtt.ConnAckServerUnavailable
+	if p.connect.ProtocolLevel == mqtt.Version5 {
+		code = mqtt.ConnAckServerUnavailableV5
+	}
+	conn := p.readCallbacks.Connection()
+	conn.Write(buffer.NewIoBufferBytes(mqtt.EncodeConnAck(p.connect.ProtocolLevel, false, code)))
+	conn.Close(api.FlushWrite, api.LocalClose)
+}
+
+func (p *mqttProxy) onProtocolError(err error) {
+	log.DefaultLogger.Errorf("[mqtt proxy] decode downstream packet failed: %v", err)
+	p.factory.stats.protocolError.Inc(1)
+	p.readCallbacks.Connection().Close(api.NoFlush, api.LocalClose)
+}
+
+// reply writes the locally replied packet to the client
+func (p *mqttProxy) reply(data []byte) {
+	p.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(data))
+}
+
+// onDownstreamPacket appends the packet to forward if it is not replied locally
+func (p *mqttProxy) onDownstreamPacket(packet *mqtt.Packet, forward []byte) ([]byte, error) {
+	version := p.connect.ProtocolLevel
+	switch packet.Type {
+	case mqtt.PUBLISH:
+		pub, err := mqtt.ParsePublish(packet, version)
+		if err != nil {
+			return forward, err
+		}
+		topic, err := resolveTopic(pub, p.inAliases)
+		if err != nil {
+			return forward, err
+		}
+		stats := p.factory.stats.topic(topic)
+		if !p.factory.config.allowPublish(p.connect, topic) {
+			stats.publishDenied.Inc(1)
+			p.denyPublish(pub)
+			return forward, nil
+		}
+		stats.publishIn.Inc(1)
+		stats.publishInBytes.Inc(int64(pub.PayloadSize))
+
+	case mqtt.PUBREL:
+		id, err := mqtt.PacketID(packet.Body)
+		if err != nil {
+			return forward, err
+		}
+		if _, ok := p.deniedQoS2[id]; ok {
+			delete(p.deniedQoS2, id)
+			p.reply(mqtt.EncodeAck(mqtt.PUBCOMP, id, version, 0))
+			return forward, nil
+		}
+
+	case mqtt.SUBSCRIBE:
+		sub, err := mqtt.ParseSubscribe(packet.Body, version)
+		if err != nil {
+			return forward, err
+		}
+		return p.onSubscribe(packet, sub, forward), nil
+
+	case mqtt.PINGREQ:
+		p.factory.stats.ping.Inc(1)
+	}
+	return append(forward, packet.Raw...), nil
+}
+
+// denyPublish acknowledges the denied publication without forwarding it. MQTT 5.0 clients are
+// acknowledged with the reason code of not authorized, and the former clients are acknowledged
+// positively as the specification allows.
+func (p *mqttProxy) denyPublish(pub *mqtt.Publish) {
+	version := p.connect.ProtocolLevel
+	switch pub.QoS {
+	case 1:
+		p.reply(mqtt.EncodeAck(mqtt.PUBACK, pub.PacketID, version, mqtt.ReasonNotAuthorized))
+	case 2:
+		if version != mqtt.Version5 {
+			// the flow is completed by PUBREL, which is not required after a failed PUBREC of MQTT 5.0
+			p.deniedQoS2[pub.PacketID] = struct{}{}
+		}
+		p.reply(mqtt.EncodeAck(mqtt.PUBREC, pub.PacketID, version, mqtt.ReasonNotAuthorized))
+	}
+}
+
+// onSubscribe forwards the allowed subscriptions, the SUBACK is replied locally if all the subscriptions are denied
+func (p *mqttProxy) onSubscribe(packet *mqtt.Packet, sub *mqtt.Subscribe, forward []byte) []byte {
+	stats := p.factory.stats
+	stats.subscribe.Inc(int64(len(sub.Subscriptions)))
+	deniedCode := mqtt.SubAckFailure
+	if p.connect.ProtocolLevel == mqtt.Version5 {
+		deniedCode = mqtt.ReasonNotAuthorized
+	}
+
+	codes := make([]byte, len(sub.Subscriptions))
+	allowed := make([]mqtt.Subscription, 0, len(sub.Subscriptions))
+	for i, s := range sub.Subscriptions {
+		if p.factory.config.allowSubscribe(p.connect, s.Filter) {
+			allowed = append(allowed, s)
+		} else {
+			codes[i] = deniedCode
+		}
+	}
+	switch {
+	case len(allowed) == len(sub.Subscriptions):
+		return append(forward, packet.Raw...)
+	case len(allowed) == 0:
+		stats.subscribeDenied.Inc(int64(len(codes)))
+		ack := &mqtt.SubAck{
+			PacketID:    sub.PacketID,
+			ReasonCodes: codes,
+		}
+		p.reply(ack.Encode(p.connect.ProtocolLevel))
+		return forward
+	default:
+		stats.subscribeDenied.Inc(int64(len(codes) - len(allowed)))
+		p.mux.Lock()
+		p.pendingSubscribes[sub.PacketID] = codes
+		p.mux.Unlock()
+		sub.Subscriptions = allowed
+		return append(forward, sub.Encode(p.connect.ProtocolLevel)...)
+	}
+}
+
+// mergeSubAck merges the reason codes of the denied subscriptions into the SUBACK of the broker
+func (p *mqttProxy) mergeSubAck(packet *mqtt.Packet) ([]byte, error) {
+	version := p.connect.ProtocolLevel
+	ack, err := mqtt.ParseSubAck(packet.Body, version)
+	if err != nil {
+		return nil, err
+	}
+	p.mux.Lock()
+	codes, ok := p.pendingSubscribes[ack.PacketID]
+	delete(p.pendingSubscribes, ack.PacketID)
+	p.mux.Unlock()
+	if !ok {
+		return packet.Raw, nil
+	}
+	merged := make([]byte, 0, len(codes))
+	forwarded := ack.ReasonCodes
+	for _, code := range codes {
+		if code == 0 {
+			if len(forwarded) == 0 {
+				return nil, mqtt.ErrMalformed
+			}
+			code, forwarded = forwarded[0], forwarded[1:]
+		}
+		merged = append(merged, code)
+	}
+	ack.ReasonCodes = merged
+	return ack.Encode(version), nil
+}
+
+// resolveTopic returns the topic name of the publication, the topic aliases are updated if it is set
+func resolveTopic(pub *mqtt.Publish, aliases map[uint16]string) (string, error) {
+	if pub.TopicAlias == 0 {
+		return pub.Topic, nil
+	}
+	if pub.Topic != "" {
+		aliases[pub.TopicAlias] = pub.Topic
+		return pub.Topic, nil
+	}
+	topic, ok := aliases[pub.TopicAlias]
+	if !ok {
+		return "", mqtt.ErrMalformed
+	}
+	return topic, nil
+}
+
+// api.ReadFilter
+func (u *upstreamFilter) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	var forward []byte
+	for buf.Len() > 0 {
+		packet, err := mqtt.Decode(buf.Bytes())
+		if err == nil && packet == nil {
+			break
+		}
+		if err == nil {
+			forward, err = u.onUpstreamPacket(packet, forward)
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[mqtt proxy] decode packet of broker %s failed: %v", u.host.AddressString(), err)
+			buf.Drain(buf.Len())
+			u.conn.Close(api.NoFlush, api.LocalClose)
+			return api.Stop
+		}
+		buf.Drain(len(packet.Raw))
+	}
+	if len(forward) > 0 {
+		u.proxy.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(forward))
+	}
+	return api.Stop
+}
+
+func (u *upstreamFilter) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (u *upstreamFilter) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {}
+
+// api.ConnectionEventListener
+func (u *upstreamFilter) OnEvent(event api.ConnectionEvent) {
+	if event.IsClose() {
+		u.release()
+		// the client reconnects to resume the session
+		u.proxy.readCallbacks.Connection().Close(api.FlushWrite, api.LocalClose)
+	}
+}
+
+// release decreases the connections of the cluster once
+func (u *upstreamFilter) release() {
+	if atomic.CompareAndSwapUint32(&u.released, 0, 1) {
+		u.host.ClusterInfo().ResourceManager().Connections().Decrease()
+	}
+}
+
+func (u *upstreamFilter) onUpstreamPacket(packet *mqtt.Packet, forward []byte) ([]byte, error) {
+	p := u.proxy
+	switch packet.Type {
+	case mqtt.PUBLISH:
+		pub, err := mqtt.ParsePublish(packet, p.connect.ProtocolLevel)
+		if err != nil {
+			return forward, err
+		}
+		topic, err := resolveTopic(pub, u.outAliases)
+		if err != nil {
+			return forward, err
+		}
+		stats := p.factory.stats.topic(topic)
+		stats.publishOut.Inc(1)
+		stats.publishOutBytes.Inc(int64(pub.PayloadSize))
+
+	case mqtt.SUBACK:
+		data, err := p.mergeSubAck(packet)
+		if err != nil {
+			return forward, err
+		}
+		return append(forward, data...), nil
+	}
+	return append(forward, packet.Raw...), nil
+}
@@ -0,0 +1,372 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqttproxy
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/protocol/mqtt"
+	"mosn.io/pkg/buffer"
+)
+
+func newTestProxy(t *testing.T, cfg map[string]interface{}) (*mqttProxy, *mockConnection) {
+	factory, err := CreateMQTTProxyFactory(cfg)
+	if err != nil {
+		t.Fatalf("create factory failed: %v", err)
+	}
+	p := newMQTTProxy(factory.(*mqttProxyFilterConfigFactory))
+	conn := &mockConnection{}
+	p.InitializeReadFilterCallbacks(&mockReadFilterCallbacks{conn: conn})
+	return p, conn
+}
+
+func send(p *mqttProxy, packets ...[]byte) {
+	var data []byte
+	for _, packet := range packets {
+		data = append(data, packet...)
+	}
+	p.OnData(buffer.NewIoBufferBytes(data))
+}
+
+// findPacket returns the packet with the type and the packet id
+func findPacket(t *testing.T, packets []*mqtt.Packet, typ mqtt.PacketType, id uint16) *mqtt.Packet {
+	for _, p := range packets {
+		if p.Type != typ {
+			continue
+		}
+		if pid, _ := mqtt.PacketID(p.Body); typ == mqtt.PUBLISH || typ == mqtt.PINGRESP || pid == id {
+			return p
+		}
+	}
+	t.Fatalf("%s of packet %d not found", typ, id)
+	return nil
+}
+
+func counter(t *testing.T, labels map[string]string, key string) int64 {
+	m, err := metrics.NewMetrics(metricsType, labels)
+	if err != nil {
+		t.Fatalf("get metrics failed: %v", err)
+	}
+	return m.Counter(key).Count()
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"cluster": "mqtt",
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if cfg.MaxTopicStats != defaultMaxTopicStats || cfg.ACLDefaultDeny {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	for i, c := range []map[string]interface{}{
+		{},
+		{"routes": []interface{}{map[string]interface{}{"client_id": "^a"}}},
+		{"routes": []interface{}{map[string]interface{}{"client_id": "(", "cluster": "a"}}},
+		{"cluster": "mqtt", "acls": []interface{}{map[string]interface{}{"action": "publish"}}},
+		{"cluster": "mqtt", "acls": []interface{}{map[string]interface{}{"topic": "a", "action": "read"}}},
+	} {
+		if _, err := parseConfig(c); err == nil {
+			t.Errorf("#%d parse config should fail", i)
+		}
+	}
+}
+
+func TestConfigMatch(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"cluster": "default",
+		"routes": []interface{}{
+			map[string]interface{}{"client_id": "^sensor-", "cluster": "sensors"},
+			map[string]interface{}{"username": "^admin$", "cluster": "admin"},
+		},
+		"acls": []interface{}{
+			map[string]interface{}{"username": "^admin$", "topic": "#", "allow": true},
+			map[string]interface{}{"topic": "sensors/+/secret", "allow": false},
+			map[string]interface{}{"topic": "sensors/#", "action": "publish", "allow": true},
+			map[string]interface{}{"topic": "sensors/+/data", "action": "subscribe", "allow": true},
+		},
+		"acl_default_deny": true,
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	sensor := &mqtt.Connect{ClientID: "sensor-1"}
+	admin := &mqtt.Connect{ClientID: "admin-1", Username: "admin"}
+	if c := cfg.route(sensor); c != "sensors" {
+		t.Errorf("unexpected cluster %s", c)
+	}
+	if c := cfg.route(admin); c != "admin" {
+		t.Errorf("unexpected cluster %s", c)
+	}
+	if c := cfg.route(&mqtt.Connect{ClientID: "app"}); c != "default" {
+		t.Errorf("unexpected cluster %s", c)
+	}
+
+	for _, tc := range []struct {
+		connect   *mqtt.Connect
+		topic     string
+		publish   bool
+		subscribe bool
+	}{
+		{sensor, "sensors/1/data", true, true},
+		{sensor, "sensors/1/secret", false, false},
+		{sensor, "sensors/1/other", true, false},
+		{sensor, "sensors/+/data", true, true},
+		{sensor, "sensors/#", true, false},
+		{sensor, "other", false, false},
+		{admin, "sensors/1/secret", true, true},
+		{admin, "#", true, true},
+	} {
+		if cfg.allowPublish(tc.connect, tc.topic) != tc.publish {
+			t.Errorf("%s publish %s, expected %v", tc.connect.ClientID, tc.topic, tc.publish)
+		}
+		if cfg.allowSubscribe(tc.connect, tc.topic) != tc.subscribe {
+			t.Errorf("%s subscribe %s, expected %v", tc.connect.ClientID, tc.topic, tc.subscribe)
+		}
+	}
+}
+
+func TestMQTTProxyRouting(t *testing.T) {
+	sensors, other := newFakeBroker(t), newFakeBroker(t)
+	defer sensors.Close()
+	defer other.Close()
+	setupCluster(t, "mqtt_sensors", sensors)
+	setupCluster(t, "mqtt_other", other)
+	cfg := map[string]interface{}{
+		"cluster": "mqtt_other",
+		"routes": []interface{}{
+			map[string]interface{}{"client_id": "^sensor-", "cluster": "mqtt_sensors"},
+		},
+	}
+
+	for _, tc := range []struct {
+		clientID string
+		broker   *fakeBroker
+	}{
+		{"sensor-1", sensors},
+		{"app-1", other},
+	} {
+		p, conn := newTestProxy(t, cfg)
+		send(p, encodeConnect(mqtt.Version311, tc.clientID), mqtt.Encode(mqtt.PINGREQ, 0, nil))
+		packets := conn.readPackets(t, 2)
+		if packets[0].Type != mqtt.CONNACK || packets[1].Type != mqtt.PINGRESP {
+			t.Errorf("unexpected packets %s %s", packets[0].Type, packets[1].Type)
+		}
+		if clients := tc.broker.snapshot(&tc.broker.clients); len(clients) != 1 || clients[0] != tc.clientID {
+			t.Errorf("client %s is routed to unexpected broker, clients: %v", tc.clientID, clients)
+		}
+		conn.Close(api.NoFlush, api.RemoteClose)
+	}
+}
+
+func TestMQTTProxySessionAffinity(t *testing.T) {
+	var brokers []*fakeBroker
+	for i := 0; i < 3; i++ {
+		b := newFakeBroker(t)
+		defer b.Close()
+		brokers = append(brokers, b)
+	}
+	setupCluster(t, "mqtt_affinity", brokers...)
+	cfg := map[string]interface{}{"cluster": "mqtt_affinity"}
+
+	// the clients reconnect to the same broker
+	for i := 0; i < 3; i++ {
+		for _, clientID := range []string{"a", "b", "c", "d"} {
+			p, conn := newTestProxy(t, cfg)
+			send(p, encodeConnect(mqtt.Version311, clientID))
+			conn.readPackets(t, 1)
+			conn.Close(api.NoFlush, api.RemoteClose)
+		}
+	}
+	total := 0
+	for _, b := range brokers {
+		clients := b.snapshot(&b.clients)
+		total += len(clients)
+		counts := make(map[string]int)
+		for _, clientID := range clients {
+			counts[clientID]++
+		}
+		for clientID, count := range counts {
+			if count != 3 {
+				t.Errorf("client %s connected to broker %s %d times", clientID, b.Addr(), count)
+			}
+		}
+	}
+	if total != 12 {
+		t.Errorf("expected 12 connections, but got %d", total)
+	}
+}
+
+func TestMQTTProxyACL(t *testing.T) {
+	broker := newFakeBroker(t)
+	defer broker.Close()
+	setupCluster(t, "mqtt_acl", broker)
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"stat_prefix": "acl",
+		"cluster":     "mqtt_acl",
+		"acls": []interface{}{
+			map[string]interface{}{"topic": "sensors/#", "action": "publish", "allow": true},
+			map[string]interface{}{"topic": "commands/+", "action": "subscribe", "allow": true},
+		},
+		"acl_default_deny": true,
+	})
+	labels := func(topic string) map[string]string {
+		return map[string]string{"stat_prefix": "acl", "topic": topic}
+	}
+	inBefore := counter(t, labels("sensors/1"), statsPublishIn)
+	deniedBefore := counter(t, labels("secret"), statsPublishDenied)
+	outBefore := counter(t, labels("commands/a"), statsPublishOut)
+
+	send(p,
+		encodeConnect(mqtt.Version311, "client"),
+		encodePublish(mqtt.Version311, "sensors/1", 1, 1, "22.5"),
+		encodePublish(mqtt.Version311, "secret", 1, 2, "x"),
+		encodePublish(mqtt.Version311, "secret", 2, 3, "x"),
+		mqtt.EncodeAck(mqtt.PUBREL, 3, mqtt.Version311, 0),
+		encodeSubscribe(mqtt.Version311, 4, "commands/a", "secret/#"),
+		encodeSubscribe(mqtt.Version311, 5, "secret"),
+	)
+	// CONNACK, PUBACK * 2, PUBREC, PUBCOMP, SUBACK * 2, PUBLISH
+	packets := conn.readPackets(t, 8)
+	for _, id := range []uint16{1, 2} {
+		findPacket(t, packets, mqtt.PUBACK, id)
+	}
+	findPacket(t, packets, mqtt.PUBREC, 3)
+	findPacket(t, packets, mqtt.PUBCOMP, 3)
+	ack, _ := mqtt.ParseSubAck(findPacket(t, packets, mqtt.SUBACK, 4).Body, mqtt.Version311)
+	if !bytes.Equal(ack.ReasonCodes, []byte{1, mqtt.SubAckFailure}) {
+		t.Errorf("unexpected merged suback: %v", ack.ReasonCodes)
+	}
+	ack, _ = mqtt.ParseSubAck(findPacket(t, packets, mqtt.SUBACK, 5).Body, mqtt.Version311)
+	if !bytes.Equal(ack.ReasonCodes, []byte{mqtt.SubAckFailure}) {
+		t.Errorf("unexpected local suback: %v", ack.ReasonCodes)
+	}
+	pub, _ := mqtt.ParsePublish(findPacket(t, packets, mqtt.PUBLISH, 0), mqtt.Version311)
+	if pub.Topic != "commands/a" {
+		t.Errorf("unexpected publish from broker: %+v", pub)
+	}
+
+	if published := broker.snapshot(&broker.published); len(published) != 1 || published[0] != "sensors/1" {
+		t.Errorf("unexpected published topics: %v", published)
+	}
+	if subscribed := broker.snapshot(&broker.subscribed); len(subscribed) != 1 || subscribed[0] != "commands/a" {
+		t.Errorf("unexpected subscribed filters: %v", subscribed)
+	}
+	if n := counter(t, labels("sensors/1"), statsPublishIn) - inBefore; n != 1 {
+		t.Errorf("expected 1 publish in, but got %d", n)
+	}
+	if n := counter(t, labels("secret"), statsPublishDenied) - deniedBefore; n != 2 {
+		t.Errorf("expected 2 publish denied, but got %d", n)
+	}
+	if n := counter(t, labels("commands/a"), statsPublishOut) - outBefore; n != 1 {
+		t.Errorf("expected 1 publish out, but got %d", n)
+	}
+}
+
+func TestMQTTProxyV5(t *testing.T) {
+	broker := newFakeBroker(t)
+	defer broker.Close()
+	setupCluster(t, "mqtt_v5", broker)
+	cfg := map[string]interface{}{
+		"cluster": "mqtt_v5",
+		"acls": []interface{}{
+			map[string]interface{}{"topic": "secret/#", "allow": false},
+		},
+	}
+	p, conn := newTestProxy(t, cfg)
+	send(p,
+		encodeConnect(mqtt.Version5, "client"),
+		encodePublish(mqtt.Version5, "sensors/1", 1, 1, "a", 1),
+		encodePublish(mqtt.Version5, "secret/1", 1, 2, "b", 2),
+		// the topics are resolved by the aliases
+		encodePublish(mqtt.Version5, "", 1, 3, "c", 1),
+		encodePublish(mqtt.Version5, "", 1, 4, "d", 2),
+		encodePublish(mqtt.Version5, "secret/1", 2, 5, "e"),
+	)
+	packets := conn.readPackets(t, 6)
+	for _, id := range []uint16{1, 3} {
+		if p := findPacket(t, packets, mqtt.PUBACK, id); len(p.Body) != 2 {
+			t.Errorf("packet %d should be acknowledged by broker: %v", id, p.Raw)
+		}
+	}
+	for _, id := range []uint16{2, 4} {
+		if p := findPacket(t, packets, mqtt.PUBACK, id); len(p.Body) != 3 || p.Body[2] != mqtt.ReasonNotAuthorized {
+			t.Errorf("packet %d should be denied: %v", id, p.Raw)
+		}
+	}
+	if p := findPacket(t, packets, mqtt.PUBREC, 5); len(p.Body) != 3 || p.Body[2] != mqtt.ReasonNotAuthorized {
+		t.Errorf("packet 5 should be denied: %v", p.Raw)
+	}
+	if published := broker.snapshot(&broker.published); len(published) != 2 {
+		t.Errorf("unexpected published topics: %v", published)
+	}
+
+	// unknown topic alias is a protocol error
+	send(p, encodePublish(mqtt.Version5, "", 0, 0, "f", 9))
+	if !conn.isClosed() {
+		t.Error("connection should be closed by protocol error")
+	}
+}
+
+func TestMQTTProxyReject(t *testing.T) {
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"routes": []interface{}{
+			map[string]interface{}{"client_id": "^sensor-", "cluster": "mqtt_not_found"},
+		},
+	})
+	send(p, encodeConnect(mqtt.Version5, "app"))
+	packets := conn.readPackets(t, 1)
+	if !bytes.Equal(packets[0].Raw, mqtt.EncodeConnAck(mqtt.Version5, false, mqtt.ConnAckServerUnavailableV5)) || !conn.isClosed() {
+		t.Errorf("connection without route should be rejected: %v", packets[0].Raw)
+	}
+
+	p, conn = newTestProxy(t, map[string]interface{}{"cluster": "mqtt_not_found"})
+	send(p, encodeConnect(mqtt.Version311, "sensor-1"))
+	packets = conn.readPackets(t, 1)
+	if !bytes.Equal(packets[0].Raw, mqtt.EncodeConnAck(mqtt.Version311, false, mqtt.ConnAckServerUnavailable)) || !conn.isClosed() {
+		t.Errorf("connection to unknown cluster should be rejected: %v", packets[0].Raw)
+	}
+
+	// the first packet must be CONNECT
+	p, conn = newTestProxy(t, map[string]interface{}{"cluster": "mqtt_not_found"})
+	send(p, mqtt.Encode(mqtt.PINGREQ, 0, nil))
+	if !conn.isClosed() {
+		t.Error("connection should be closed by protocol error")
+	}
+}
+
+func TestMQTTProxyUpstreamClose(t *testing.T) {
+	broker := newFakeBroker(t)
+	defer broker.Close()
+	setupCluster(t, "mqtt_close", broker)
+	p, conn := newTestProxy(t, map[string]interface{}{"cluster": "mqtt_close"})
+	send(p, encodeConnect(mqtt.Version311, "client"))
+	conn.readPackets(t, 1)
+
+	broker.closeClients()
+	for i := 0; i < 100 && !conn.isClosed(); i++ {
+		time.Sleep(30 * time.Millisecond)
+	}
+	if !conn.isClosed() {
+		t.Error("downstream connection should be closed with the broker connection")
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqttproxy
+
+import (
+	"sync"
+	"sync/atomic"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+const metricsType = "mqtt_proxy"
+
+// otherTopics is the topic label of the topics beyond the max topic stats
+const otherTopics = "_other"
+
+// metrics key
+const (
+	statsConnect         = "connect"
+	statsConnectRejected = "connect_rejected"
+	statsProtocolError   = "protocol_error"
+	statsSubscribe       = "subscribe"
+	statsSubscribeDenied = "subscribe_denied"
+	statsPing            = "ping"
+
+	statsPublishIn       = "publish_in"
+	statsPublishInBytes  = "publish_in_bytes"
+	statsPublishOut      = "publish_out"
+	statsPublishOutBytes = "publish_out_bytes"
+	statsPublishDenied   = "publish_denied"
+)
+
+type proxyStats struct {
+	prefix        string
+	maxTopicStats int32
+	topicCount    int32
+	topics        sync.Map // topic -> *topicStats
+
+	connect         gometrics.Counter
+	connectRejected gometrics.Counter
+	protocolError   gometrics.Counter
+	subscribe       gometrics.Counter
+	subscribeDenied gometrics.Counter
+	ping            gometrics.Counter
+}
+
+// topicStats counts the application messages of the topic, in is from the clients to the brokers,
+// and out is from the brokers to the clients
+type topicStats struct {
+	publishIn       gometrics.Counter
+	publishInBytes  gometrics.Counter
+	publishOut      gometrics.Counter
+	publishOutBytes gometrics.Counter
+	publishDenied   gometrics.Counter
+}
+
+func newProxyStats(prefix string, maxTopicStats int) *proxyStats {
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"stat_prefix": prefix})
+	return &proxyStats{
+		prefix:          prefix,
+		maxTopicStats:   int32(maxTopicStats),
+		connect:         m.Counter(statsConnect),
+		connectRejected: m.Counter(statsConnectRejected),
+		protocolError:   m.Counter(statsProtocolError),
+		subscribe:       m.Counter(statsSubscribe),
+		subscribeDenied: m.Counter(statsSubscribeDenied),
+		ping:            m.Counter(statsPing),
+	}
+}
+
+// topic returns the stats of the topic, the topics beyond the limit share the same stats
+func (s *proxyStats) topic(name string) *topicStats {
+	if v, ok := s.topics.Load(name); ok {
+		return v.(*topicStats)
+	}
+	if name != otherTopics && atomic.AddInt32(&s.topicCount, 1) > s.maxTopicStats {
+		atomic.AddInt32(&s.topicCount, -1)
+		return s.topic(otherTopics)
+	}
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"stat_prefix": s.prefix, "topic": name})
+	v, loaded := s.topics.LoadOrStore(name, &topicStats{
+		publishIn:       m.Counter(statsPublishIn),
+		publishInBytes:  m.Counter(statsPublishInBytes),
+		publishOut:      m.Counter(statsPublishOut),
+		publishOutBytes: m.Counter(statsPublishOutBytes),
+		publishDenied:   m.Counter(statsPublishDenied),
+	})
+	if loaded && name != otherTopics {
+		atomic.AddInt32(&s.topicCount, -1)
+	}
+	return v.(*topicStats)
+}
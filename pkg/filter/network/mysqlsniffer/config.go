@@ -0,0 +1,74 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysqlsniffer
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
+)
+
+var defaultSlowQueryLogPath = types.MosnLogBasePath + string(os.PathSeparator) + "mysql_slow.log"
+
+type config struct {
+	// StatPrefix is the label of the stats
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// SlowQueryThreshold enables the slow query log, the statements that take longer than it are logged
+	SlowQueryThreshold api.DurationConfig `json:"slow_query_threshold,omitempty"`
+	// SlowQueryLogPath is the path of the slow query log, mysql_slow.log in the log directory of mosn by default
+	SlowQueryLogPath string `json:"slow_query_log_path,omitempty"`
+	// DenyPatterns are the regular expressions of the denied statements, the COM_QUERY and COM_STMT_PREPARE
+	// commands matching any of them are replied with an ERR packet instead of being forwarded
+	DenyPatterns []string `json:"deny_patterns,omitempty"`
+
+	denyPatterns []*regexp.Regexp
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	for _, pattern := range filterConfig.DenyPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filterConfig.denyPatterns = append(filterConfig.denyPatterns, re)
+	}
+	if filterConfig.SlowQueryThreshold.Duration > 0 && filterConfig.SlowQueryLogPath == "" {
+		filterConfig.SlowQueryLogPath = defaultSlowQueryLogPath
+	}
+	return filterConfig, nil
+}
+
+// denied reports whether the statement matches any deny pattern
+func (c *config) denied(sql string) bool {
+	for _, re := range c.denyPatterns {
+		if re.MatchString(sql) {
+			return true
+		}
+	}
+	return false
+}
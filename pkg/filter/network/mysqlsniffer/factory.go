@@ -0,0 +1,62 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysqlsniffer
+
+import (
+	"context"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/pkg/log"
+)
+
+func init() {
+	api.RegisterNetwork(v2.MYSQL_SNIFFER, CreateMySQLSnifferFactory)
+}
+
+type mysqlSnifferFilterConfigFactory struct {
+	config *config
+	stats  *snifferStats
+	// slowLogger is nil if the slow query log is disabled
+	slowLogger *log.Logger
+}
+
+// CreateFilterChain adds the sniffer as both the read filter and the write filter, it should be placed
+// before the tcp proxy so that the statements are inspected before they are forwarded
+func (f *mysqlSnifferFilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	sniffer := newMySQLSniffer(f)
+	callbacks.AddReadFilter(sniffer)
+	callbacks.AddWriteFilter(sniffer)
+}
+
+// CreateMySQLSnifferFactory creates the factory of the mysql sniffer filter
+func CreateMySQLSnifferFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	factory := &mysqlSnifferFilterConfigFactory{
+		config: cfg,
+		stats:  newSnifferStats(cfg.StatPrefix),
+	}
+	if cfg.SlowQueryThreshold.Duration > 0 {
+		if factory.slowLogger, err = log.GetOrCreateLogger(cfg.SlowQueryLogPath, nil); err != nil {
+			return nil, err
+		}
+	}
+	return factory, nil
+}
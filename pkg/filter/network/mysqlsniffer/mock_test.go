@@ -0,0 +1,70 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysqlsniffer
+
+import (
+	"net"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockReadFilterCallbacks struct {
+	api.ReadFilterCallbacks
+	conn *mockConnection
+}
+
+func (cb *mockReadFilterCallbacks) Connection() api.Connection {
+	return cb.conn
+}
+
+type mockConnection struct {
+	api.Connection
+	onRead    func(bytesRead uint64)
+	listeners []api.ConnectionEventListener
+	// filter is called with the written buffers as the write filter of the connection
+	filter  api.WriteFilter
+	written []byte
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 12345}
+}
+
+func (c *mockConnection) AddBytesReadListener(listener func(bytesRead uint64)) {
+	c.onRead = listener
+}
+
+func (c *mockConnection) AddConnectionEventListener(listener api.ConnectionEventListener) {
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *mockConnection) Write(buffers ...buffer.IoBuffer) error {
+	c.filter.OnWrite(buffers)
+	for _, buf := range buffers {
+		c.written = append(c.written, buf.Bytes()...)
+	}
+	return nil
+}
+
+func (c *mockConnection) close() {
+	for _, l := range c.listeners {
+		l.OnEvent(api.RemoteClose)
+	}
+}
@@ -0,0 +1,549 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysqlsniffer
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/mysql"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// the states of the connection
+type state int
+
+const (
+	// waiting for the initial handshake of the server
+	stateHandshake state = iota
+	// waiting for the handshake response of the client
+	stateHandshakeResponse
+	// authenticating, the client packets are the auth data
+	stateAuth
+	// the command phase
+	stateCommand
+	// the connection is not inspected any more, e.g. it is upgraded to TLS or cannot be decoded
+	statePassthrough
+)
+
+// the phases of a command response
+type phase int
+
+const (
+	// waiting for the first packet of the response
+	phaseFirst phase = iota
+	// the column definitions and the EOF packet after them
+	phaseColumns
+	// the rows of the result set, which end with an EOF or ERR packet
+	phaseRows
+	// the parameter and column definitions of the COM_STMT_PREPARE response
+	phasePrepareDefinitions
+	// the client is sending the file of LOAD DATA LOCAL INFILE
+	phaseLocalInFile
+)
+
+// the server packets larger than it are only inspected by the beginning, which are rows or column definitions
+const maxInspectedPayload = 1 << 16
+
+// the error replied to the denied statements, which is ER_SPECIFIC_ACCESS_DENIED_ERROR
+var deniedError = &mysql.Err{
+	Code:     1227,
+	SQLState: "42000",
+	Message:  "Access denied by the mysql sniffer of mosn",
+}
+
+// command is a command waiting for the response
+type command struct {
+	cmd byte
+	// statement is nil if the command is not counted by the statements
+	statement *mysql.Statement
+	sql       string
+	start     time.Time
+	phase     phase
+	// remaining is the remaining packets of the column definitions or the prepare definitions
+	remaining uint64
+	failed    bool
+}
+
+// preparedStatement is a statement prepared by COM_STMT_PREPARE
+type preparedStatement struct {
+	statement *mysql.Statement
+	sql       string
+}
+
+// packetReader splits the bytes of a direction into packets
+type packetReader struct {
+	buf []byte
+	// skip is the bytes of the large packet to be skipped
+	skip int
+	// continued indicates the last packet is followed by more packets of the same payload
+	continued bool
+}
+
+// mysqlSniffer decodes the mysql protocol on the connection passively, it is both the read filter and the write filter.
+// The bytes are not changed unless there are deny patterns, in which case only the complete client packets are forwarded.
+type mysqlSniffer struct {
+	factory *mysqlSnifferFilterConfigFactory
+
+	mux          sync.Mutex
+	conn         api.Connection
+	state        state
+	capabilities uint32
+	client       packetReader
+	server       packetReader
+	pending      []*command
+	statements   map[uint32]*preparedStatement
+	// dropping indicates the continuation packets of the denied command are dropped
+	dropping bool
+	// local are the buffers written by the sniffer, which are not the server packets
+	local map[buffer.IoBuffer]bool
+	// lastRead is the bytes read by the last read, which are the tail of the read buffer
+	lastRead uint64
+}
+
+func newMySQLSniffer(factory *mysqlSnifferFilterConfigFactory) *mysqlSniffer {
+	return &mysqlSniffer{
+		factory:    factory,
+		statements: make(map[uint32]*preparedStatement),
+		local:      make(map[buffer.IoBuffer]bool),
+	}
+}
+
+func (s *mysqlSniffer) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (s *mysqlSniffer) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	s.conn = cb.Connection()
+	s.conn.AddBytesReadListener(s.onBytesRead)
+	s.conn.AddConnectionEventListener(s)
+}
+
+func (s *mysqlSniffer) onBytesRead(bytesRead uint64) {
+	s.mux.Lock()
+	s.lastRead = bytesRead
+	s.mux.Unlock()
+}
+
+// OnEvent releases the decoded data when the connection is closed
+func (s *mysqlSniffer) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	s.mux.Lock()
+	s.state = statePassthrough
+	s.client, s.server = packetReader{}, packetReader{}
+	s.pending = nil
+	s.mux.Unlock()
+}
+
+// OnData decodes the client packets, the bytes not drained by the next filters are left in the buffer,
+// so only the new bytes at the tail are decoded
+func (s *mysqlSniffer) OnData(buf types.IoBuffer) api.FilterStatus {
+	s.mux.Lock()
+	n := int(s.lastRead)
+	s.lastRead = 0
+	if n > buf.Len() {
+		n = buf.Len()
+	}
+	if s.state == statePassthrough && len(s.client.buf) == 0 {
+		s.mux.Unlock()
+		return api.Continue
+	}
+	data := buf.Bytes()
+	s.client.buf = append(s.client.buf, data[len(data)-n:]...)
+	forward, replies := s.onClientData()
+	if s.factory.config.denyPatterns == nil {
+		s.mux.Unlock()
+		return api.Continue
+	}
+	// only the complete packets that are not denied are forwarded, the others are kept by the sniffer
+	if !bytes.Equal(forward, data[len(data)-n:]) {
+		old := append([]byte{}, data[:len(data)-n]...)
+		buf.Reset()
+		buf.Write(old)
+		buf.Write(forward)
+	}
+	for _, reply := range replies {
+		s.local[reply] = true
+	}
+	s.mux.Unlock()
+	for _, reply := range replies {
+		if err := s.conn.Write(reply); err != nil {
+			log.DefaultLogger.Errorf("[mysql_sniffer] write the denied error failed: %v", err)
+		}
+	}
+	return api.Continue
+}
+
+// OnWrite decodes the server packets
+func (s *mysqlSniffer) OnWrite(buffers []buffer.IoBuffer) api.FilterStatus {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, buf := range buffers {
+		if buf == nil {
+			continue
+		}
+		if s.local[buf] {
+			delete(s.local, buf)
+			continue
+		}
+		if s.state == statePassthrough {
+			continue
+		}
+		s.server.buf = append(s.server.buf, buf.Bytes()...)
+		s.onServerData()
+	}
+	return api.Continue
+}
+
+// onClientData handles the complete client packets, it returns the bytes that should be forwarded
+// and the local replies of the denied commands
+func (s *mysqlSniffer) onClientData() (forward []byte, replies []buffer.IoBuffer) {
+	data := s.client.buf
+	for s.state != statePassthrough {
+		pkt := mysql.Decode(data)
+		if pkt == nil {
+			break
+		}
+		data = data[len(pkt.Raw):]
+		continued := s.client.continued
+		s.client.continued = len(pkt.Payload) == mysql.MaxPayloadSize
+		if continued {
+			if !s.dropping {
+				forward = append(forward, pkt.Raw...)
+			}
+			continue
+		}
+		s.dropping = false
+		if deny := s.onClientPacket(pkt); deny {
+			s.dropping = true
+			replies = append(replies, buffer.NewIoBufferBytes(deniedError.Encode(pkt.Seq+1, s.capabilities)))
+			continue
+		}
+		forward = append(forward, pkt.Raw...)
+	}
+	if s.state == statePassthrough {
+		// the left bytes may be the TLS handshake
+		forward = append(forward, data...)
+		data = nil
+	}
+	s.client.buf = compact(s.client.buf, data)
+	return forward, replies
+}
+
+// onClientPacket handles the first packet of a client payload, it returns true if the command is denied
+func (s *mysqlSniffer) onClientPacket(pkt *mysql.Packet) bool {
+	switch s.state {
+	case stateHandshakeResponse:
+		resp, err := mysql.ParseHandshakeResponse(pkt.Payload)
+		if err != nil {
+			s.decodeError(err)
+			return false
+		}
+		s.capabilities &= resp.Capabilities
+		if resp.SSLRequest {
+			s.factory.stats.upgradedToSSL.Inc(1)
+			s.state = statePassthrough
+			return false
+		}
+		s.factory.stats.loginAttempts.Inc(1)
+		s.state = stateAuth
+	case stateCommand:
+		return s.onCommand(pkt)
+	}
+	// the auth data, the file of LOAD DATA LOCAL INFILE or the unexpected packets before the handshake
+	return false
+}
+
+// onCommand handles the command packet, it returns true if the command is denied
+func (s *mysqlSniffer) onCommand(pkt *mysql.Packet) bool {
+	if len(s.pending) > 0 && s.pending[len(s.pending)-1].phase == phaseLocalInFile {
+		return false
+	}
+	if len(pkt.Payload) == 0 {
+		s.decodeError(mysql.ErrMalformed)
+		return false
+	}
+	cmd := &command{
+		cmd:   pkt.Payload[0],
+		start: time.Now(),
+	}
+	switch cmd.cmd {
+	case mysql.ComQuery, mysql.ComStmtPrepare:
+		sql, ok := string(pkt.Payload[1:]), true
+		if cmd.cmd == mysql.ComQuery {
+			sql, ok = mysql.ParseQuery(pkt.Payload, s.capabilities)
+		}
+		if !ok {
+			cmd.statement = &mysql.Statement{Type: mysql.UnknownStatement}
+			break
+		}
+		if s.factory.config.denied(sql) {
+			s.factory.stats.denied.Inc(1)
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[mysql_sniffer] statement denied: %s", sql)
+			}
+			return true
+		}
+		cmd.sql = sql
+		cmd.statement = mysql.ParseStatement(sql)
+	case mysql.ComStmtExecute:
+		if len(pkt.Payload) >= 5 {
+			id := uint32(pkt.Payload[1]) | uint32(pkt.Payload[2])<<8 | uint32(pkt.Payload[3])<<16 | uint32(pkt.Payload[4])<<24
+			if stmt, ok := s.statements[id]; ok {
+				cmd.statement, cmd.sql = stmt.statement, stmt.sql
+			}
+		}
+		if cmd.statement == nil {
+			cmd.statement = &mysql.Statement{Type: mysql.UnknownStatement}
+		}
+	case mysql.ComStmtClose:
+		if len(pkt.Payload) >= 5 {
+			delete(s.statements, uint32(pkt.Payload[1])|uint32(pkt.Payload[2])<<8|uint32(pkt.Payload[3])<<16|uint32(pkt.Payload[4])<<24)
+		}
+		return false
+	case mysql.ComStmtSendLongData, mysql.ComQuit:
+		// no response
+		return false
+	case mysql.ComChangeUser:
+		s.factory.stats.loginAttempts.Inc(1)
+		s.state = stateAuth
+		return false
+	case mysql.ComInitDB, mysql.ComPing, mysql.ComStmtReset, mysql.ComResetConnection:
+		// the response is an OK or ERR packet
+	default:
+		// the responses of the other commands are not known
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[mysql_sniffer] command %d is not inspected, stop sniffing", cmd.cmd)
+		}
+		s.state = statePassthrough
+		return false
+	}
+	s.pending = append(s.pending, cmd)
+	return false
+}
+
+// onServerData handles the complete server packets, the large packets are inspected by the beginning
+func (s *mysqlSniffer) onServerData() {
+	r := &s.server
+	data := r.buf
+	for s.state != statePassthrough {
+		if r.skip > 0 {
+			n := r.skip
+			if n > len(data) {
+				n = len(data)
+			}
+			data, r.skip = data[n:], r.skip-n
+			if r.skip > 0 {
+				break
+			}
+			continue
+		}
+		if len(data) < mysql.PacketHeaderSize {
+			break
+		}
+		length := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+		payload := data[mysql.PacketHeaderSize:]
+		if length <= maxInspectedPayload {
+			if len(payload) < length {
+				break
+			}
+			payload = payload[:length]
+			data = data[mysql.PacketHeaderSize+length:]
+		} else {
+			if len(payload) == 0 {
+				break
+			}
+			payload = payload[:1]
+			data = data[mysql.PacketHeaderSize+1:]
+			r.skip = length - 1
+		}
+		continued := r.continued
+		r.continued = length == mysql.MaxPayloadSize
+		if !continued {
+			s.onServerPacket(payload, length)
+		}
+	}
+	if s.state == statePassthrough {
+		r.buf, r.skip = nil, 0
+		return
+	}
+	r.buf = compact(r.buf, data)
+}
+
+// onServerPacket handles the first packet of a server payload, the payload may be truncated if it is larger than length
+func (s *mysqlSniffer) onServerPacket(payload []byte, length int) {
+	switch s.state {
+	case stateHandshake:
+		handshake, err := mysql.ParseHandshake(payload)
+		if err != nil {
+			// e.g. the ERR packet of too many connections
+			s.decodeError(err)
+			return
+		}
+		s.factory.stats.sessions.Inc(1)
+		s.capabilities = handshake.Capabilities
+		s.state = stateHandshakeResponse
+	case stateAuth:
+		switch payload[0] {
+		case mysql.HeaderOK:
+			s.state = stateCommand
+		case mysql.HeaderERR:
+			s.factory.stats.loginFailures.Inc(1)
+			s.state = stateCommand
+		}
+		// the auth switch request and the auth more data continue the authentication
+	case stateCommand:
+		if len(s.pending) == 0 {
+			s.decodeError(mysql.ErrMalformed)
+			return
+		}
+		s.onResponse(s.pending[0], payload, length)
+	}
+}
+
+// onResponse handles the response packet of the command
+func (s *mysqlSniffer) onResponse(cmd *command, payload []byte, length int) {
+	if len(payload) == 0 {
+		s.decodeError(mysql.ErrMalformed)
+		return
+	}
+	header := payload[0]
+	switch cmd.phase {
+	case phaseFirst, phaseLocalInFile:
+		switch {
+		case header == mysql.HeaderERR:
+			cmd.failed = true
+			s.finish()
+		case header == mysql.HeaderOK && cmd.cmd == mysql.ComStmtPrepare:
+			ok, err := mysql.ParseStmtPrepareOK(payload)
+			if err != nil {
+				s.decodeError(err)
+				return
+			}
+			s.statements[ok.StatementID] = &preparedStatement{statement: cmd.statement, sql: cmd.sql}
+			cmd.remaining = uint64(ok.Params) + uint64(ok.Columns)
+			if s.capabilities&mysql.ClientDeprecateEOF == 0 {
+				if ok.Params > 0 {
+					cmd.remaining++
+				}
+				if ok.Columns > 0 {
+					cmd.remaining++
+				}
+			}
+			cmd.phase = phasePrepareDefinitions
+			if cmd.remaining == 0 {
+				s.finish()
+			}
+		case header == mysql.HeaderOK || cmd.statement == nil:
+			// the commands other than the statements are replied with a single packet
+			if header == mysql.HeaderOK && cmd.statement != nil {
+				if ok, err := mysql.ParseOK(payload, s.capabilities); err == nil && ok.StatusFlags&mysql.ServerMoreResultsExists != 0 {
+					cmd.phase = phaseFirst
+					return
+				}
+			}
+			s.finish()
+		case header == mysql.HeaderLocalInFile && cmd.cmd == mysql.ComQuery:
+			cmd.phase = phaseLocalInFile
+		default:
+			columns, n := mysql.LenencInt(payload)
+			if n == 0 || columns == 0 {
+				s.decodeError(mysql.ErrMalformed)
+				return
+			}
+			cmd.remaining = columns
+			if s.capabilities&mysql.ClientDeprecateEOF == 0 {
+				cmd.remaining++
+			}
+			cmd.phase = phaseColumns
+		}
+	case phaseColumns:
+		cmd.remaining--
+		if cmd.remaining == 0 {
+			cmd.phase = phaseRows
+		}
+	case phaseRows:
+		switch {
+		case header == mysql.HeaderERR && length <= maxInspectedPayload:
+			cmd.failed = true
+			s.finish()
+		case length <= maxInspectedPayload && mysql.IsEOF(payload, s.capabilities):
+			if mysql.EOFStatus(payload, s.capabilities)&mysql.ServerMoreResultsExists != 0 {
+				cmd.phase = phaseFirst
+				return
+			}
+			s.finish()
+		}
+	case phasePrepareDefinitions:
+		cmd.remaining--
+		if cmd.remaining == 0 {
+			s.finish()
+		}
+	}
+}
+
+// finish records the stats of the first pending command, which is completed
+func (s *mysqlSniffer) finish() {
+	cmd := s.pending[0]
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+	if cmd.statement == nil {
+		return
+	}
+	duration := time.Since(cmd.start)
+	stats := s.factory.stats.statement(cmd.statement.Type)
+	stats.total.Inc(1)
+	if cmd.failed {
+		stats.err.Inc(1)
+	}
+	stats.latency.Update(duration.Nanoseconds() / int64(time.Microsecond))
+	for _, table := range cmd.statement.Tables {
+		s.factory.stats.table(table, cmd.statement.Type).Inc(1)
+	}
+	if threshold := s.factory.config.SlowQueryThreshold.Duration; threshold > 0 && duration >= threshold {
+		s.factory.stats.slowQueries.Inc(1)
+		remote := ""
+		if addr := s.conn.RemoteAddr(); addr != nil {
+			remote = addr.String()
+		}
+		s.factory.slowLogger.Printf("%s %s %s %s %s", time.Now().Format("2006-01-02 15:04:05.000"), remote,
+			duration, cmd.statement.Type, cmd.sql)
+	}
+}
+
+// decodeError stops sniffing the connection, the bytes are still proxied
+func (s *mysqlSniffer) decodeError(err error) {
+	s.factory.stats.decoderErrors.Inc(1)
+	log.DefaultLogger.Warnf("[mysql_sniffer] decode failed, stop sniffing the connection: %v", err)
+	s.state = statePassthrough
+	s.pending = nil
+}
+
+// compact keeps the left bytes at the beginning of buf
+func compact(buf, left []byte) []byte {
+	if len(left) == 0 {
+		return buf[:0]
+	}
+	n := copy(buf, left)
+	return buf[:n]
+}
@@ -0,0 +1,470 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysqlsniffer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/protocol/mysql"
+	"mosn.io/pkg/buffer"
+)
+
+const (
+	baseCapabilities = mysql.ClientProtocol41 | mysql.ClientSecureConnection | mysql.ClientPluginAuth
+	deprecateEOF     = baseCapabilities | mysql.ClientDeprecateEOF
+)
+
+// testConn drives the sniffer like a downstream connection proxied by the tcp proxy
+type testConn struct {
+	t       *testing.T
+	sniffer *mysqlSniffer
+	conn    *mockConnection
+	buf     buffer.IoBuffer
+	// forwarded are the client bytes forwarded to the next filter
+	forwarded []byte
+}
+
+func newTestConn(t *testing.T, conf map[string]interface{}) *testConn {
+	factory, err := CreateMySQLSnifferFactory(conf)
+	if err != nil {
+		t.Fatalf("create factory failed: %v", err)
+	}
+	s := newMySQLSniffer(factory.(*mysqlSnifferFilterConfigFactory))
+	conn := &mockConnection{filter: s}
+	s.InitializeReadFilterCallbacks(&mockReadFilterCallbacks{conn: conn})
+	return &testConn{
+		t:       t,
+		sniffer: s,
+		conn:    conn,
+		buf:     buffer.NewIoBuffer(0),
+	}
+}
+
+func (c *testConn) fromClient(data []byte) {
+	c.buf.Write(data)
+	c.conn.onRead(uint64(len(data)))
+	c.sniffer.OnData(c.buf)
+	// the tcp proxy forwards all the bytes
+	c.forwarded = append(c.forwarded, c.buf.Bytes()...)
+	c.buf.Drain(c.buf.Len())
+}
+
+func (c *testConn) fromServer(packets ...[]byte) {
+	c.conn.Write(buffer.NewIoBufferBytes(bytes.Join(packets, nil)))
+}
+
+// login makes the handshake with the capabilities
+func (c *testConn) login(capabilities uint32) {
+	c.fromServer(mysql.Encode(0, handshake(capabilities)))
+	c.fromClient(mysql.Encode(1, handshakeResponse(capabilities, "root")))
+	c.fromServer(mysql.Encode(2, ok(0)))
+	if c.sniffer.state != stateCommand {
+		c.t.Fatalf("expected command phase, but got %d", c.sniffer.state)
+	}
+}
+
+func handshake(capabilities uint32) []byte {
+	payload := []byte{mysql.ProtocolVersion}
+	payload = append(payload, "8.0.20\x00"...)
+	payload = append(payload, 1, 0, 0, 0)
+	payload = append(payload, make([]byte, 9)...)
+	payload = append(payload, byte(capabilities), byte(capabilities>>8), 0x21, 0x02, 0x00)
+	return append(payload, byte(capabilities>>16), byte(capabilities>>24))
+}
+
+func handshakeResponse(capabilities uint32, user string) []byte {
+	payload := []byte{byte(capabilities), byte(capabilities >> 8), byte(capabilities >> 16), byte(capabilities >> 24)}
+	payload = append(payload, make([]byte, 28)...)
+	if user == "" {
+		return payload
+	}
+	payload = append(payload, user...)
+	return append(payload, 0, 2, 'p', 'w')
+}
+
+func query(sql string) []byte {
+	return mysql.Encode(0, append([]byte{mysql.ComQuery}, sql...))
+}
+
+func ok(status uint16) []byte {
+	return []byte{mysql.HeaderOK, 0, 0, byte(status), byte(status >> 8), 0, 0}
+}
+
+func eof(status uint16, capabilities uint32) []byte {
+	if capabilities&mysql.ClientDeprecateEOF != 0 {
+		return []byte{mysql.HeaderEOF, 0, 0, byte(status), byte(status >> 8), 0, 0}
+	}
+	return []byte{mysql.HeaderEOF, 0, 0, byte(status), byte(status >> 8)}
+}
+
+// resultSet encodes a result set with the columns and the rows, the rows are the payloads
+func resultSet(capabilities uint32, status uint16, columns int, rows ...[]byte) []byte {
+	seq := byte(1)
+	next := func(payload []byte) []byte {
+		seq++
+		return mysql.Encode(seq-1, payload)
+	}
+	data := next([]byte{byte(columns)})
+	for i := 0; i < columns; i++ {
+		data = append(data, next([]byte{3, 'd', 'e', 'f'})...)
+	}
+	if capabilities&mysql.ClientDeprecateEOF == 0 {
+		data = append(data, next(eof(0, capabilities))...)
+	}
+	for _, row := range rows {
+		data = append(data, next(row)...)
+	}
+	return append(data, next(eof(status, capabilities))...)
+}
+
+func labels(prefix string, kv ...string) map[string]string {
+	l := map[string]string{"stat_prefix": prefix}
+	for i := 0; i+1 < len(kv); i += 2 {
+		l[kv[i]] = kv[i+1]
+	}
+	return l
+}
+
+func counter(t *testing.T, labels map[string]string, key string) int64 {
+	m, err := metrics.NewMetrics(metricsType, labels)
+	if err != nil {
+		t.Fatalf("get metrics failed: %v", err)
+	}
+	return m.Counter(key).Count()
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"slow_query_threshold": "1s",
+		"deny_patterns":        []interface{}{"(?i)^drop "},
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if cfg.SlowQueryThreshold.Duration != time.Second || cfg.SlowQueryLogPath != defaultSlowQueryLogPath {
+		t.Errorf("unexpected slow query config: %+v", cfg)
+	}
+	if !cfg.denied("DROP TABLE users") || cfg.denied("select 1") {
+		t.Error("unexpected deny result")
+	}
+	if _, err := parseConfig(map[string]interface{}{
+		"deny_patterns": []interface{}{"("},
+	}); err == nil {
+		t.Error("expected error of the invalid pattern")
+	}
+}
+
+func TestSniffQueries(t *testing.T) {
+	prefix := "queries"
+	sessions := counter(t, labels(prefix), statsSessions)
+	attempts := counter(t, labels(prefix), statsLoginAttempts)
+	inserts := counter(t, labels(prefix, "statement", "insert"), statsTotal)
+	selects := counter(t, labels(prefix, "statement", "select"), statsTotal)
+	selectErrors := counter(t, labels(prefix, "statement", "select"), statsError)
+	calls := counter(t, labels(prefix, "statement", "call"), statsTotal)
+	userInserts := counter(t, labels(prefix, "table", "users"), "insert")
+	userSelects := counter(t, labels(prefix, "table", "users"), "select")
+
+	c := newTestConn(t, map[string]interface{}{"stat_prefix": prefix})
+	c.login(baseCapabilities)
+	c.forwarded = nil
+	var sent []byte
+	send := func(data []byte) {
+		sent = append(sent, data...)
+		c.fromClient(data)
+	}
+
+	send(query("INSERT INTO users (name) VALUES ('a')"))
+	c.fromServer(mysql.Encode(1, ok(0)))
+	// the command is split into several reads
+	data := query("SELECT u.name FROM users u JOIN orders o ON u.id = o.uid")
+	send(data[:2])
+	send(data[2:10])
+	send(data[10:])
+	rs := resultSet(baseCapabilities, 0, 1, []byte{1, 'a'}, []byte{1, 'b'})
+	// the response is written in several writes
+	c.fromServer(rs[:7])
+	c.fromServer(rs[7:])
+	send(query("SELECT * FROM missing"))
+	c.fromServer((&mysql.Err{Code: 1146, SQLState: "42S02", Message: "no such table"}).Encode(1, baseCapabilities))
+	// a stored procedure returns a result set and an OK packet
+	send(query("CALL p()"))
+	c.fromServer(resultSet(baseCapabilities, mysql.ServerMoreResultsExists, 2, []byte{1, 'a', 1, 'b'}))
+	if len(c.sniffer.pending) != 1 {
+		t.Fatal("the statement should wait for more results")
+	}
+	c.fromServer(mysql.Encode(5, ok(0)))
+	send(mysql.Encode(0, []byte{mysql.ComPing}))
+	c.fromServer(mysql.Encode(1, ok(0)))
+
+	if !bytes.Equal(c.forwarded, sent) {
+		t.Error("the client bytes should be forwarded as they are")
+	}
+	if len(c.sniffer.pending) != 0 || c.sniffer.state != stateCommand {
+		t.Fatalf("unexpected sniffer state: %d, %d pending", c.sniffer.state, len(c.sniffer.pending))
+	}
+	for _, tc := range []struct {
+		labels   map[string]string
+		key      string
+		before   int64
+		expected int64
+	}{
+		{labels(prefix), statsSessions, sessions, 1},
+		{labels(prefix), statsLoginAttempts, attempts, 1},
+		{labels(prefix, "statement", "insert"), statsTotal, inserts, 1},
+		{labels(prefix, "statement", "select"), statsTotal, selects, 2},
+		{labels(prefix, "statement", "select"), statsError, selectErrors, 1},
+		{labels(prefix, "statement", "call"), statsTotal, calls, 1},
+		{labels(prefix, "table", "users"), "insert", userInserts, 1},
+		{labels(prefix, "table", "users"), "select", userSelects, 1},
+	} {
+		if n := counter(t, tc.labels, tc.key) - tc.before; n != tc.expected {
+			t.Errorf("%v %s: expected %d, but got %d", tc.labels, tc.key, tc.expected, n)
+		}
+	}
+	m, _ := metrics.NewMetrics(metricsType, labels(prefix, "statement", "select"))
+	if m.Histogram(statsLatency).Count() == 0 {
+		t.Error("expected the latency of the statements")
+	}
+	c.conn.close()
+	c.fromClient(query("SELECT 1"))
+}
+
+func TestSniffPreparedStatements(t *testing.T) {
+	prefix := "prepared"
+	prepares := counter(t, labels(prefix, "statement", "select"), statsTotal)
+	orders := counter(t, labels(prefix, "table", "orders"), "select")
+	unknown := counter(t, labels(prefix, "statement", mysql.UnknownStatement), statsTotal)
+
+	c := newTestConn(t, map[string]interface{}{"stat_prefix": prefix})
+	c.login(deprecateEOF)
+	c.fromClient(mysql.Encode(0, append([]byte{mysql.ComStmtPrepare}, "SELECT * FROM orders WHERE id = ?"...)))
+	// statement 7 with 1 parameter and 2 columns, there is no EOF packet after the definitions
+	c.fromServer(
+		mysql.Encode(1, []byte{mysql.HeaderOK, 7, 0, 0, 0, 2, 0, 1, 0, 0, 0, 0}),
+		mysql.Encode(2, []byte{3, 'd', 'e', 'f'}),
+		mysql.Encode(3, []byte{3, 'd', 'e', 'f'}),
+		mysql.Encode(4, []byte{3, 'd', 'e', 'f'}),
+	)
+	execute := mysql.Encode(0, []byte{mysql.ComStmtExecute, 7, 0, 0, 0, 0, 1, 0, 0, 0})
+	c.fromClient(execute)
+	c.fromServer(resultSet(deprecateEOF, 0, 2, []byte{0, 0, 1, 'a', 1, 'b'}))
+	// the long data has no response
+	c.fromClient(mysql.Encode(0, []byte{mysql.ComStmtSendLongData, 7, 0, 0, 0, 0, 0, 'x'}))
+	c.fromClient(execute)
+	c.fromServer(mysql.Encode(1, ok(0)))
+	c.fromClient(mysql.Encode(0, []byte{mysql.ComStmtClose, 7, 0, 0, 0}))
+	if _, ok := c.sniffer.statements[7]; ok {
+		t.Error("the statement should be closed")
+	}
+	c.fromClient(execute)
+	c.fromServer((&mysql.Err{Code: 1243, SQLState: "HY000", Message: "unknown statement"}).Encode(1, deprecateEOF))
+	if len(c.sniffer.pending) != 0 || c.sniffer.state != stateCommand {
+		t.Fatalf("unexpected sniffer state: %d, %d pending", c.sniffer.state, len(c.sniffer.pending))
+	}
+	// the prepare and 2 executions
+	if n := counter(t, labels(prefix, "statement", "select"), statsTotal) - prepares; n != 3 {
+		t.Errorf("expected 3 select statements, but got %d", n)
+	}
+	if n := counter(t, labels(prefix, "table", "orders"), "select") - orders; n != 3 {
+		t.Errorf("expected 3 select statements of orders, but got %d", n)
+	}
+	if n := counter(t, labels(prefix, "statement", mysql.UnknownStatement), statsTotal) - unknown; n != 1 {
+		t.Errorf("expected 1 unknown statement, but got %d", n)
+	}
+}
+
+func TestSniffLargeRows(t *testing.T) {
+	c := newTestConn(t, map[string]interface{}{"stat_prefix": "large"})
+	c.login(baseCapabilities)
+	c.fromClient(query("SELECT data FROM blobs"))
+	// the large row begins with 0xfe, which is not an EOF packet
+	row := bytes.Repeat([]byte{0xfe}, maxInspectedPayload+100)
+	rs := resultSet(baseCapabilities, 0, 1, row)
+	for len(rs) > 0 {
+		n := 4096
+		if n > len(rs) {
+			n = len(rs)
+		}
+		c.fromServer(rs[:n])
+		rs = rs[n:]
+		if len(c.sniffer.server.buf) > maxInspectedPayload {
+			t.Fatalf("the large row should not be buffered: %d", len(c.sniffer.server.buf))
+		}
+	}
+	if len(c.sniffer.pending) != 0 || c.sniffer.state != stateCommand {
+		t.Fatalf("unexpected sniffer state: %d, %d pending", c.sniffer.state, len(c.sniffer.pending))
+	}
+}
+
+func TestDenyStatements(t *testing.T) {
+	prefix := "deny"
+	denied := counter(t, labels(prefix), statsDenied)
+	updates := counter(t, labels(prefix, "statement", "update"), statsTotal)
+
+	c := newTestConn(t, map[string]interface{}{
+		"stat_prefix":   prefix,
+		"deny_patterns": []interface{}{`(?i)^\s*drop\s`, `(?i)^\s*truncate\s`},
+	})
+	c.login(baseCapabilities)
+	c.forwarded = nil
+
+	// the incomplete packet is held until the statement is known
+	data := query("DROP TABLE users")
+	c.fromClient(data[:6])
+	if len(c.forwarded) != 0 {
+		t.Fatal("the incomplete packet should not be forwarded")
+	}
+	c.fromClient(data[6:])
+	if len(c.forwarded) != 0 {
+		t.Fatal("the denied statement should not be forwarded")
+	}
+	p := mysql.Decode(c.conn.written[len(c.conn.written)-len(deniedError.Encode(1, baseCapabilities)):])
+	if p == nil || p.Seq != 1 {
+		t.Fatal("expected the ERR packet")
+	}
+	if e, err := mysql.ParseErr(p.Payload, baseCapabilities); err != nil || e.Code != deniedError.Code || e.SQLState != "42000" {
+		t.Fatalf("unexpected ERR packet: %+v, %v", e, err)
+	}
+	if len(c.sniffer.local) != 0 {
+		t.Error("the local reply should not be inspected as the server packets")
+	}
+
+	// the allowed statements in the same read are forwarded
+	allowed := query("UPDATE users SET name = 'a' WHERE id = 1")
+	c.fromClient(append(query("truncate users"), allowed...))
+	if !bytes.Equal(c.forwarded, allowed) {
+		t.Fatalf("unexpected forwarded bytes: %q", c.forwarded)
+	}
+	c.fromServer(mysql.Encode(1, ok(0)))
+	if len(c.sniffer.pending) != 0 {
+		t.Fatal("the allowed statement should be completed")
+	}
+	if n := counter(t, labels(prefix), statsDenied) - denied; n != 2 {
+		t.Errorf("expected 2 denied statements, but got %d", n)
+	}
+	if n := counter(t, labels(prefix, "statement", "update"), statsTotal) - updates; n != 1 {
+		t.Errorf("expected 1 update statement, but got %d", n)
+	}
+}
+
+func TestUpgradeToSSL(t *testing.T) {
+	prefix := "ssl"
+	upgraded := counter(t, labels(prefix), statsUpgradedToSSL)
+	decoderErrors := counter(t, labels(prefix), statsDecoderErrors)
+
+	c := newTestConn(t, map[string]interface{}{
+		"stat_prefix":   prefix,
+		"deny_patterns": []interface{}{"(?i)^drop "},
+	})
+	c.fromServer(mysql.Encode(0, handshake(baseCapabilities|mysql.ClientSSL)))
+	sslRequest := mysql.Encode(1, handshakeResponse(baseCapabilities|mysql.ClientSSL, ""))
+	// the TLS client hello follows the SSL request
+	clientHello := []byte{0x16, 0x03, 0x01, 0x00, 0x05, 1, 2, 3, 4, 5}
+	c.fromClient(append(append([]byte{}, sslRequest...), clientHello[:3]...))
+	c.fromClient(clientHello[3:])
+	c.fromServer([]byte{0x16, 0x03, 0x03, 0x00, 0x01, 0xff})
+	if !bytes.Equal(c.forwarded, append(sslRequest, clientHello...)) {
+		t.Fatalf("unexpected forwarded bytes: %v", c.forwarded)
+	}
+	if c.sniffer.state != statePassthrough {
+		t.Fatal("the connection upgraded to TLS should not be sniffed")
+	}
+	if n := counter(t, labels(prefix), statsUpgradedToSSL) - upgraded; n != 1 {
+		t.Errorf("expected 1 upgraded connection, but got %d", n)
+	}
+	if n := counter(t, labels(prefix), statsDecoderErrors) - decoderErrors; n != 0 {
+		t.Errorf("expected no decoder errors, but got %d", n)
+	}
+}
+
+func TestLoginFailures(t *testing.T) {
+	prefix := "login"
+	failures := counter(t, labels(prefix), statsLoginFailures)
+	decoderErrors := counter(t, labels(prefix), statsDecoderErrors)
+
+	c := newTestConn(t, map[string]interface{}{"stat_prefix": prefix})
+	c.fromServer(mysql.Encode(0, handshake(baseCapabilities)))
+	c.fromClient(mysql.Encode(1, handshakeResponse(baseCapabilities, "root")))
+	// auth switch request and the auth data of the client
+	c.fromServer(mysql.Encode(2, []byte{mysql.HeaderEOF, 'p', 0}))
+	c.fromClient(mysql.Encode(3, []byte{'p', 'w'}))
+	c.fromServer((&mysql.Err{Code: 1045, SQLState: "28000", Message: "access denied"}).Encode(4, baseCapabilities))
+	if n := counter(t, labels(prefix), statsLoginFailures) - failures; n != 1 {
+		t.Errorf("expected 1 login failure, but got %d", n)
+	}
+
+	// the server replies an ERR packet instead of the handshake
+	c = newTestConn(t, map[string]interface{}{"stat_prefix": prefix})
+	c.fromServer((&mysql.Err{Code: 1040, Message: "too many connections"}).Encode(0, 0))
+	if c.sniffer.state != statePassthrough {
+		t.Fatal("the connection should not be sniffed")
+	}
+	if n := counter(t, labels(prefix), statsDecoderErrors) - decoderErrors; n != 1 {
+		t.Errorf("expected 1 decoder error, but got %d", n)
+	}
+	data := query("SELECT 1")
+	c.fromClient(data)
+	if !bytes.Equal(c.forwarded, data) {
+		t.Error("the bytes should be forwarded")
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysql_sniffer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+	prefix := "slow"
+	slow := counter(t, labels(prefix), statsSlowQueries)
+
+	c := newTestConn(t, map[string]interface{}{
+		"stat_prefix":          prefix,
+		"slow_query_threshold": "20ms",
+		"slow_query_log_path":  path,
+	})
+	c.login(baseCapabilities)
+	c.fromClient(query("SELECT 1"))
+	c.fromServer(resultSet(baseCapabilities, 0, 1, []byte{1, '1'}))
+	c.fromClient(query("SELECT SLEEP(1)"))
+	time.Sleep(30 * time.Millisecond)
+	c.fromServer(resultSet(baseCapabilities, 0, 1, []byte{1, '0'}))
+	if n := counter(t, labels(prefix), statsSlowQueries) - slow; n != 1 {
+		t.Errorf("expected 1 slow query, but got %d", n)
+	}
+	var content string
+	for i := 0; i < 100; i++ {
+		data, _ := ioutil.ReadFile(path)
+		if content = string(data); content != "" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(content, "10.0.0.1:12345") || !strings.Contains(content, "select SELECT SLEEP(1)") ||
+		strings.Contains(content, "SELECT 1") {
+		t.Errorf("unexpected slow query log: %q", content)
+	}
+}
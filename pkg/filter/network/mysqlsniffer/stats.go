@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysqlsniffer
+
+import (
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/types"
+)
+
+const metricsType = "mysql"
+
+// metrics key
+const (
+	statsSessions      = "sessions"
+	statsLoginAttempts = "login_attempts"
+	statsLoginFailures = "login_failures"
+	statsUpgradedToSSL = "upgraded_to_ssl"
+	statsDecoderErrors = "decoder_errors"
+	statsDenied        = "denied"
+	statsSlowQueries   = "slow_queries"
+
+	statsTotal   = "total"
+	statsError   = "error"
+	statsLatency = "latency"
+)
+
+type snifferStats struct {
+	prefix     string
+	statements sync.Map // statement type -> *statementStats
+	tables     sync.Map // table -> types.Metrics
+
+	sessions      gometrics.Counter
+	loginAttempts gometrics.Counter
+	loginFailures gometrics.Counter
+	upgradedToSSL gometrics.Counter
+	decoderErrors gometrics.Counter
+	denied        gometrics.Counter
+	slowQueries   gometrics.Counter
+}
+
+type statementStats struct {
+	total gometrics.Counter
+	err   gometrics.Counter
+	// latency is the duration of the statement in microseconds
+	latency gometrics.Histogram
+}
+
+func newSnifferStats(prefix string) *snifferStats {
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"stat_prefix": prefix})
+	return &snifferStats{
+		prefix:        prefix,
+		sessions:      m.Counter(statsSessions),
+		loginAttempts: m.Counter(statsLoginAttempts),
+		loginFailures: m.Counter(statsLoginFailures),
+		upgradedToSSL: m.Counter(statsUpgradedToSSL),
+		decoderErrors: m.Counter(statsDecoderErrors),
+		denied:        m.Counter(statsDenied),
+		slowQueries:   m.Counter(statsSlowQueries),
+	}
+}
+
+// statement returns the stats of the statement type
+func (s *snifferStats) statement(typ string) *statementStats {
+	if v, ok := s.statements.Load(typ); ok {
+		return v.(*statementStats)
+	}
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"stat_prefix": s.prefix, "statement": typ})
+	v, _ := s.statements.LoadOrStore(typ, &statementStats{
+		total:   m.Counter(statsTotal),
+		err:     m.Counter(statsError),
+		latency: m.Histogram(statsLatency),
+	})
+	return v.(*statementStats)
+}
+
+// table counts the statements of the table by the statement types
+func (s *snifferStats) table(table, typ string) gometrics.Counter {
+	if v, ok := s.tables.Load(table); ok {
+		return v.(types.Metrics).Counter(typ)
+	}
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"stat_prefix": s.prefix, "table": table})
+	v, _ := s.tables.LoadOrStore(table, m)
+	return v.(types.Metrics).Counter(typ)
+}
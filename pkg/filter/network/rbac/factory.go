@@ -0,0 +1,53 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"context"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/rbac"
+)
+
+func init() {
+	api.RegisterNetwork(v2.RBAC_NETWORK_FILTER, CreateRBACFactory)
+}
+
+type rbacConfigFactory struct {
+	authorizer *rbac.Authorizer
+}
+
+func (f *rbacConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	rf := NewRBACFilter(context, f.authorizer)
+	callbacks.AddReadFilter(rf)
+}
+
+// CreateRBACFactory creates the rbac network filter factory
+func CreateRBACFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	cfg, err := rbac.ParseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	authorizer, err := rbac.NewAuthorizer(cfg, "network")
+	if err != nil {
+		return nil, err
+	}
+	return &rbacConfigFactory{
+		authorizer: authorizer,
+	}, nil
+}
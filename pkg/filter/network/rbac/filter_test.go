@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+)
+
+func TestRBACFilter(t *testing.T) {
+	factory, err := CreateRBACFactory(map[string]interface{}{
+		"rules": map[string]interface{}{
+			"action": "DENY",
+			"policies": map[string]interface{}{
+				"blocked": map[string]interface{}{
+					"permissions": []interface{}{map[string]interface{}{"destination_port": 9090}},
+					"principals": []interface{}{map[string]interface{}{
+						"source_ip": map[string]interface{}{"address_prefix": "192.168.0.0", "prefix_len": 16},
+					}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create factory failed: %v", err)
+	}
+	testCases := []struct {
+		remote  string
+		local   string
+		allowed bool
+	}{
+		{"192.168.1.1:1234", "127.0.0.1:9090", false},
+		{"192.168.1.1:1234", "127.0.0.1:8080", true},
+		{"10.1.1.1:1234", "127.0.0.1:9090", true},
+	}
+	for _, tc := range testCases {
+		remote, _ := net.ResolveTCPAddr("tcp", tc.remote)
+		local, _ := net.ResolveTCPAddr("tcp", tc.local)
+		conn := &mockConnection{remote: remote, local: local}
+		filter := NewRBACFilter(context.Background(), factory.(*rbacConfigFactory).authorizer)
+		filter.InitializeReadFilterCallbacks(&mockReadFilterCallbacks{conn: conn})
+		// the connection is authorized only once
+		for i := 0; i < 2; i++ {
+			buf := buffer.NewIoBufferString("data")
+			status := filter.OnData(buf)
+			if tc.allowed && (status != api.Continue || buf.Len() != 4) {
+				t.Errorf("%s -> %s should be allowed", tc.remote, tc.local)
+			}
+			if !tc.allowed && (status != api.Stop || buf.Len() != 0) {
+				t.Errorf("%s -> %s should be denied", tc.remote, tc.local)
+			}
+		}
+		if tc.allowed && conn.closed != 0 || !tc.allowed && conn.closed != 1 {
+			t.Errorf("%s -> %s: unexpected close count %d", tc.remote, tc.local, conn.closed)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redisproxy
+
+import (
+	"fmt"
+	"strconv"
+
+	"mosn.io/mosn/pkg/protocol/redis"
+)
+
+// commandKind describes how the command is sent to the shards
+type commandKind int
+
+const (
+	// simpleCommand has a single key as the first argument
+	simpleCommand commandKind = iota
+	// evalCommand is EVAL and EVALSHA, which is sent to the shard of the first key
+	evalCommand
+	// mgetCommand fetches the keys from the shards, and the values are merged into an array
+	mgetCommand
+	// msetCommand sets the key-value pairs on the shards
+	msetCommand
+	// sumCommand sends the keys to the shards, and the integer results are summed up
+	sumCommand
+)
+
+var commands = map[string]commandKind{
+	"append": simpleCommand, "bitcount": simpleCommand, "bitfield": simpleCommand, "bitpos": simpleCommand,
+	"decr": simpleCommand, "decrby": simpleCommand, "dump": simpleCommand, "expire": simpleCommand,
+	"expireat": simpleCommand, "geoadd": simpleCommand, "geodist": simpleCommand, "geohash": simpleCommand,
+	"geopos": simpleCommand, "georadius_ro": simpleCommand, "georadiusbymember_ro": simpleCommand,
+	"get": simpleCommand, "getbit": simpleCommand, "getrange": simpleCommand, "getset": simpleCommand,
+	"hdel": simpleCommand, "hexists": simpleCommand, "hget": simpleCommand, "hgetall": simpleCommand,
+	"hincrby": simpleCommand, "hincrbyfloat": simpleCommand, "hkeys": simpleCommand, "hlen": simpleCommand,
+	"hmget": simpleCommand, "hmset": simpleCommand, "hscan": simpleCommand, "hset": simpleCommand,
+	"hsetnx": simpleCommand, "hstrlen": simpleCommand, "hvals": simpleCommand, "incr": simpleCommand,
+	"incrby": simpleCommand, "incrbyfloat": simpleCommand, "lindex": simpleCommand, "linsert": simpleCommand,
+	"llen": simpleCommand, "lpop": simpleCommand, "lpush": simpleCommand, "lpushx": simpleCommand,
+	"lrange": simpleCommand, "lrem": simpleCommand, "lset": simpleCommand, "ltrim": simpleCommand,
+	"persist": simpleCommand, "pexpire": simpleCommand, "pexpireat": simpleCommand, "pfadd": simpleCommand,
+	"pfcount": simpleCommand, "psetex": simpleCommand, "pttl": simpleCommand, "restore": simpleCommand,
+	"rpop": simpleCommand, "rpush": simpleCommand, "rpushx": simpleCommand, "sadd": simpleCommand,
+	"scard": simpleCommand, "set": simpleCommand, "setbit": simpleCommand, "setex": simpleCommand,
+	"setnx": simpleCommand, "setrange": simpleCommand, "sismember": simpleCommand, "smembers": simpleCommand,
+	"spop": simpleCommand, "srandmember": simpleCommand, "srem": simpleCommand, "sscan": simpleCommand,
+	"strlen": simpleCommand, "ttl": simpleCommand, "type": simpleCommand, "zadd": simpleCommand,
+	"zcard": simpleCommand, "zcount": simpleCommand, "zincrby": simpleCommand, "zlexcount": simpleCommand,
+	"zpopmax": simpleCommand, "zpopmin": simpleCommand, "zrange": simpleCommand, "zrangebylex": simpleCommand,
+	"zrangebyscore": simpleCommand, "zrank": simpleCommand, "zrem": simpleCommand,
+	"zremrangebylex": simpleCommand, "zremrangebyrank": simpleCommand, "zremrangebyscore": simpleCommand,
+	"zrevrange": simpleCommand, "zrevrangebylex": simpleCommand, "zrevrangebyscore": simpleCommand,
+	"zrevrank": simpleCommand, "zscan": simpleCommand, "zscore": simpleCommand,
+
+	"eval":    evalCommand,
+	"evalsha": evalCommand,
+
+	"mget": mgetCommand,
+	"mset": msetCommand,
+
+	"del":    sumCommand,
+	"exists": sumCommand,
+	"touch":  sumCommand,
+	"unlink": sumCommand,
+}
+
+// shardRequest is the command sent to the shard of the key
+type shardRequest struct {
+	key     string
+	command *redis.Value
+}
+
+// mergeFunc merges the responses of the shard requests into the response of the command
+type mergeFunc func(responses []*redis.Value) *redis.Value
+
+// splitCommand splits the command into the requests sent to the shards, and returns the function
+// that merges the responses. An error response is returned if the command can not be proxied.
+func splitCommand(name string, command *redis.Value, args []string) ([]shardRequest, mergeFunc, *redis.Value) {
+	kind, ok := commands[name]
+	if !ok {
+		return nil, nil, redis.NewError(fmt.Sprintf("ERR unsupported command '%s'", args[0]))
+	}
+	switch kind {
+	case simpleCommand:
+		if len(args) < 2 {
+			return nil, nil, wrongArguments(args[0])
+		}
+		return []shardRequest{{key: args[1], command: command}}, mergeSingle, nil
+
+	case evalCommand:
+		// EVAL script numkeys key [key ...] arg [arg ...]
+		if len(args) < 4 {
+			return nil, nil, wrongArguments(args[0])
+		}
+		if n, err := strconv.Atoi(args[2]); err != nil || n < 1 || n > len(args)-3 {
+			return nil, nil, redis.NewError(fmt.Sprintf("ERR '%s' requires at least one key", args[0]))
+		}
+		return []shardRequest{{key: args[3], command: command}}, mergeSingle, nil
+
+	case mgetCommand:
+		if len(args) < 2 {
+			return nil, nil, wrongArguments(args[0])
+		}
+		requests := make([]shardRequest, 0, len(args)-1)
+		for _, key := range args[1:] {
+			requests = append(requests, shardRequest{key: key, command: redis.NewCommand("GET", key)})
+		}
+		return requests, mergeArray, nil
+
+	case msetCommand:
+		if len(args) < 3 || len(args)%2 == 0 {
+			return nil, nil, wrongArguments(args[0])
+		}
+		requests := make([]shardRequest, 0, len(args)/2)
+		for i := 1; i < len(args); i += 2 {
+			requests = append(requests, shardRequest{key: args[i], command: redis.NewCommand("SET", args[i], args[i+1])})
+		}
+		return requests, mergeOK, nil
+
+	case sumCommand:
+		if len(args) < 2 {
+			return nil, nil, wrongArguments(args[0])
+		}
+		requests := make([]shardRequest, 0, len(args)-1)
+		for _, key := range args[1:] {
+			requests = append(requests, shardRequest{key: key, command: redis.NewCommand(args[0], key)})
+		}
+		return requests, mergeSum, nil
+	}
+	return nil, nil, redis.NewError(fmt.Sprintf("ERR unsupported command '%s'", args[0]))
+}
+
+func wrongArguments(command string) *redis.Value {
+	return redis.NewError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", command))
+}
+
+func mergeSingle(responses []*redis.Value) *redis.Value {
+	return responses[0]
+}
+
+// mergeArray merges the values of the keys into an array, the error of a key is kept in the array
+func mergeArray(responses []*redis.Value) *redis.Value {
+	return redis.NewArray(responses...)
+}
+
+// mergeOK returns OK if all of the responses are OK, otherwise the first error is returned
+func mergeOK(responses []*redis.Value) *redis.Value {
+	for _, resp := range responses {
+		if resp.IsError() {
+			return resp
+		}
+	}
+	return redis.NewSimpleString("OK")
+}
+
+// mergeSum returns the sum of the integer responses, the first error is returned if any
+func mergeSum(responses []*redis.Value) *redis.Value {
+	var sum int64
+	for _, resp := range responses {
+		if resp.IsError() {
+			return resp
+		}
+		if resp.Type != redis.Integer {
+			return redis.NewError("ERR unexpected upstream response")
+		}
+		sum += resp.Int
+	}
+	return redis.NewInteger(sum)
+}
@@ -0,0 +1,57 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redisproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"mosn.io/api"
+)
+
+const defaultOpTimeout = time.Second
+
+type config struct {
+	// StatPrefix is the label of the stats
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// Cluster is the cluster of the redis shards, the keys are sharded by the hash slots,
+	// and the slots are divided evenly across the hosts in the order of the cluster
+	Cluster string `json:"cluster"`
+	// OpTimeout is the timeout of each command sent to the upstream, 1s by default
+	OpTimeout api.DurationConfig `json:"op_timeout,omitempty"`
+	// EnableRedirection follows the MOVED and ASK redirections of the Redis Cluster
+	EnableRedirection bool `json:"enable_redirection,omitempty"`
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.Cluster == "" {
+		return nil, errors.New("cluster of redis proxy is required")
+	}
+	if filterConfig.OpTimeout.Duration <= 0 {
+		filterConfig.OpTimeout.Duration = defaultOpTimeout
+	}
+	return filterConfig, nil
+}
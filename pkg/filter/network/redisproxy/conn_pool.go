@@ -0,0 +1,223 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redisproxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/redis"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+var (
+	errConnectFailed   = redis.NewError("ERR upstream connect failed")
+	errConnectionClose = redis.NewError("ERR upstream connection closed")
+	errTimeout         = redis.NewError("ERR upstream operation timed out")
+)
+
+// connPool holds a pipelined connection for each upstream host, it is shared by the downstream connections.
+// The upstream responds the commands on a connection in order, so the responses are paired with the
+// commands in the order they are sent.
+type connPool struct {
+	mux     sync.Mutex
+	clients map[string]*upstreamClient // address -> client
+}
+
+func newConnPool() *connPool {
+	return &connPool{
+		clients: make(map[string]*upstreamClient),
+	}
+}
+
+// send sends the command to the host, and the callback is called once with the response,
+// or an error response if the command fails or times out.
+// The ASKING command is sent before the command if asking is true.
+func (cp *connPool) send(host types.Host, command *redis.Value, asking bool, timeout time.Duration, callback func(*redis.Value)) {
+	cp.mux.Lock()
+	c, ok := cp.clients[host.AddressString()]
+	if !ok {
+		c = &upstreamClient{
+			pool: cp,
+			host: host,
+		}
+		cp.clients[host.AddressString()] = c
+	}
+	cp.mux.Unlock()
+
+	c.send(command, asking, timeout, callback)
+}
+
+func (cp *connPool) remove(c *upstreamClient) {
+	cp.mux.Lock()
+	defer cp.mux.Unlock()
+
+	if cp.clients[c.host.AddressString()] == c {
+		delete(cp.clients, c.host.AddressString())
+	}
+}
+
+// upstreamRequest is a command waiting for the response
+type upstreamRequest struct {
+	callback func(*redis.Value)
+	// timer is set with the lock of the client held, and should be read with the lock held
+	timer    *time.Timer
+	finished uint32
+}
+
+func (r *upstreamRequest) finish(resp *redis.Value) {
+	if !atomic.CompareAndSwapUint32(&r.finished, 0, 1) {
+		return
+	}
+	r.callback(resp)
+}
+
+// stopTimer stops the timeout of the request, it is called with the lock of the client held
+func (r *upstreamRequest) stopTimer() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// upstreamClient is the connection to an upstream host
+// api.ReadFilter
+// api.ConnectionEventListener
+type upstreamClient struct {
+	pool *connPool
+	host types.Host
+
+	mux     sync.Mutex
+	conn    types.ClientConnection
+	pending []*upstreamRequest
+	closed  bool
+}
+
+func (c *upstreamClient) send(command *redis.Value, asking bool, timeout time.Duration, callback func(*redis.Value)) {
+	req := &upstreamRequest{
+		callback: callback,
+	}
+
+	c.mux.Lock()
+	if c.conn == nil && !c.closed {
+		if err := c.connect(); err != nil {
+			log.DefaultLogger.Errorf("[redis proxy] connect to upstream %s failed: %v", c.host.AddressString(), err)
+			c.closed = true
+		}
+	}
+	if c.closed {
+		c.mux.Unlock()
+		c.pool.remove(c)
+		req.finish(errConnectFailed)
+		return
+	}
+
+	var data []byte
+	if asking {
+		data = redis.NewCommand("ASKING").Encode(data)
+		// the response of ASKING is ignored
+		c.pending = append(c.pending, &upstreamRequest{callback: func(*redis.Value) {}})
+	}
+	data = command.Encode(data)
+	c.pending = append(c.pending, req)
+	req.timer = time.AfterFunc(timeout, func() {
+		req.finish(errTimeout)
+	})
+	err := c.conn.Write(buffer.NewIoBufferBytes(data))
+	c.mux.Unlock()
+
+	if err != nil {
+		log.DefaultLogger.Errorf("[redis proxy] write to upstream %s failed: %v", c.host.AddressString(), err)
+		c.conn.Close(api.NoFlush, api.LocalClose)
+	}
+}
+
+// connect creates the connection to the host, it is called with the lock held
+func (c *upstreamClient) connect() error {
+	data := c.host.CreateConnection(context.Background())
+	conn := data.Connection
+	conn.AddConnectionEventListener(c)
+	conn.FilterManager().AddReadFilter(c)
+	if err := conn.Connect(); err != nil {
+		return err
+	}
+	conn.SetNoDelay(true)
+	c.conn = conn
+	return nil
+}
+
+// api.ReadFilter
+func (c *upstreamClient) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	for buf.Len() > 0 {
+		resp, n, err := redis.Decode(buf.Bytes())
+		if err != nil {
+			log.DefaultLogger.Errorf("[redis proxy] decode upstream %s response failed: %v", c.host.AddressString(), err)
+			buf.Drain(buf.Len())
+			c.conn.Close(api.NoFlush, api.LocalClose)
+			return api.Stop
+		}
+		if resp == nil {
+			break
+		}
+		buf.Drain(n)
+
+		c.mux.Lock()
+		if len(c.pending) == 0 {
+			c.mux.Unlock()
+			log.DefaultLogger.Errorf("[redis proxy] unexpected response from upstream %s: %s", c.host.AddressString(), resp)
+			continue
+		}
+		req := c.pending[0]
+		c.pending[0] = nil
+		c.pending = c.pending[1:]
+		req.stopTimer()
+		c.mux.Unlock()
+
+		req.finish(resp)
+	}
+	return api.Stop
+}
+
+func (c *upstreamClient) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (c *upstreamClient) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {}
+
+// api.ConnectionEventListener
+func (c *upstreamClient) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	c.mux.Lock()
+	c.closed = true
+	pending := c.pending
+	c.pending = nil
+	for _, req := range pending {
+		req.stopTimer()
+	}
+	c.mux.Unlock()
+
+	c.pool.remove(c)
+	for _, req := range pending {
+		req.finish(errConnectionClose)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redisproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol/redis"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+func init() {
+	api.RegisterNetwork(v2.REDIS_PROXY, CreateRedisProxyFactory)
+}
+
+type redisProxyFilterConfigFactory struct {
+	config *config
+	pool   *connPool
+	stats  *proxyStats
+	// redirectHosts caches the hosts of the redirections that are not in the cluster
+	redirectHosts sync.Map
+}
+
+func (f *redisProxyFilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	callbacks.AddReadFilter(newRedisProxy(f))
+}
+
+// CreateRedisProxyFactory creates the factory of the redis proxy filter
+func CreateRedisProxyFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &redisProxyFilterConfigFactory{
+		config: cfg,
+		pool:   newConnPool(),
+		stats:  newProxyStats(cfg.StatPrefix),
+	}, nil
+}
+
+// shard returns the host of the key, the hash slots are divided evenly across the hosts in order
+func (f *redisProxyFilterConfigFactory) shard(key string) (types.Host, error) {
+	snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), f.config.Cluster)
+	if snapshot == nil {
+		return nil, fmt.Errorf("cluster %s not found", f.config.Cluster)
+	}
+	hosts := snapshot.HostSet().Hosts()
+	if len(hosts) == 0 {
+		return nil, errors.New("no host in cluster " + f.config.Cluster)
+	}
+	return hosts[redis.Slot(key)*len(hosts)/redis.SlotCount], nil
+}
+
+// hostByAddress returns the host of the redirection, the host is created if it is not in the cluster
+func (f *redisProxyFilterConfigFactory) hostByAddress(addr string) (types.Host, error) {
+	snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), f.config.Cluster)
+	if snapshot == nil {
+		return nil, fmt.Errorf("cluster %s not found", f.config.Cluster)
+	}
+	for _, host := range snapshot.HostSet().Hosts() {
+		if host.AddressString() == addr {
+			return host, nil
+		}
+	}
+	if v, ok := f.redirectHosts.Load(addr); ok {
+		return v.(types.Host), nil
+	}
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: addr,
+		},
+	}, snapshot.ClusterInfo())
+	v, _ := f.redirectHosts.LoadOrStore(addr, host)
+	return v.(types.Host), nil
+}
@@ -0,0 +1,282 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redisproxy
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol/redis"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockReadFilterCallbacks struct {
+	api.ReadFilterCallbacks
+	conn *mockConnection
+}
+
+func (cb *mockReadFilterCallbacks) Connection() api.Connection {
+	return cb.conn
+}
+
+type mockConnection struct {
+	api.Connection
+	mux       sync.Mutex
+	written   []byte
+	closed    bool
+	listeners []api.ConnectionEventListener
+}
+
+func (c *mockConnection) AddConnectionEventListener(listener api.ConnectionEventListener) {
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *mockConnection) Write(buffers ...buffer.IoBuffer) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for _, buf := range buffers {
+		c.written = append(c.written, buf.Bytes()...)
+	}
+	return nil
+}
+
+func (c *mockConnection) Close(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+	c.mux.Lock()
+	c.closed = true
+	c.mux.Unlock()
+	for _, listener := range c.listeners {
+		listener.OnEvent(eventType)
+	}
+	return nil
+}
+
+func (c *mockConnection) isClosed() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.closed
+}
+
+// readResponses waits for n responses written to the connection
+func (c *mockConnection) readResponses(t *testing.T, n int) []*redis.Value {
+	var responses []*redis.Value
+	for i := 0; i < 100; i++ {
+		c.mux.Lock()
+		data := c.written
+		responses = responses[:0]
+		for len(data) > 0 {
+			v, size, err := redis.Decode(data)
+			if err != nil {
+				c.mux.Unlock()
+				t.Fatalf("decode response failed: %v", err)
+			}
+			if v == nil {
+				break
+			}
+			responses = append(responses, v)
+			data = data[size:]
+		}
+		c.mux.Unlock()
+		if len(responses) >= n {
+			return responses
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+	t.Fatalf("expected %d responses, but got %d", n, len(responses))
+	return nil
+}
+
+// fakeRedisServer is a in-process redis server that supports a few commands.
+// The keys in moved are responded with MOVED to the target, and the keys in asking
+// are responded with ASK unless the ASKING is received on the connection.
+type fakeRedisServer struct {
+	ln    net.Listener
+	delay time.Duration
+
+	mux    sync.Mutex
+	data   map[string]string
+	moved  map[string]string
+	asking map[string]string
+}
+
+func newFakeRedisServer(t *testing.T) *fakeRedisServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedisServer{
+		ln:     ln,
+		data:   make(map[string]string),
+		moved:  make(map[string]string),
+		asking: make(map[string]string),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedisServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeRedisServer) Close() {
+	s.ln.Close()
+}
+
+func (s *fakeRedisServer) setDelay(delay time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.delay = delay
+}
+
+func (s *fakeRedisServer) set(key, value string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.data[key] = value
+}
+
+func (s *fakeRedisServer) size() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.data)
+}
+
+// redirect responds the key with MOVED or ASK to the target
+func (s *fakeRedisServer) redirect(key, target string, asking bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if asking {
+		s.asking[key] = target
+	} else {
+		s.moved[key] = target
+	}
+}
+
+func (s *fakeRedisServer) get(key string) (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	v, ok := s.data[key]
+	return v, ok
+}
+
+func (s *fakeRedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	var data []byte
+	buf := make([]byte, 4096)
+	asking := false
+	for {
+		n, err := reader.Read(buf)
+		if err != nil {
+			return
+		}
+		data = append(data, buf[:n]...)
+		var out []byte
+		for {
+			v, size, err := redis.Decode(data)
+			if err != nil {
+				return
+			}
+			if v == nil {
+				break
+			}
+			data = data[size:]
+			args, _ := v.Args()
+			if strings.ToUpper(args[0]) == "ASKING" {
+				asking = true
+				out = redis.NewSimpleString("OK").Encode(out)
+				continue
+			}
+			out = s.handle(args, asking).Encode(out)
+			asking = false
+		}
+		s.mux.Lock()
+		delay := s.delay
+		s.mux.Unlock()
+		time.Sleep(delay)
+		conn.Write(out)
+	}
+}
+
+func (s *fakeRedisServer) handle(args []string, asking bool) *redis.Value {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(args) > 1 {
+		if target, ok := s.moved[args[1]]; ok {
+			return redis.NewError("MOVED " + strconv.Itoa(redis.Slot(args[1])) + " " + target)
+		}
+		if target, ok := s.asking[args[1]]; ok && !asking {
+			return redis.NewError("ASK 0 " + target)
+		}
+	}
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if v, ok := s.data[args[1]]; ok {
+			return redis.NewBulkString(v)
+		}
+		return redis.NewNullBulkString()
+	case "SET":
+		s.data[args[1]] = args[2]
+		return redis.NewSimpleString("OK")
+	case "DEL", "EXISTS":
+		var n int64
+		for _, key := range args[1:] {
+			if _, ok := s.data[key]; ok {
+				n++
+				if strings.ToUpper(args[0]) == "DEL" {
+					delete(s.data, key)
+				}
+			}
+		}
+		return redis.NewInteger(n)
+	}
+	return redis.NewError("ERR unknown command '" + args[0] + "'")
+}
+
+// setupCluster adds the cluster of the redis servers to the cluster manager
+func setupCluster(t *testing.T, name string, servers ...*fakeRedisServer) {
+	cluster.NewClusterManagerSingleton(nil, nil)
+	var hosts []v2.Host
+	for _, s := range servers {
+		hosts = append(hosts, v2.Host{HostConfig: v2.HostConfig{Address: s.Addr()}})
+	}
+	err := cluster.GetClusterMngAdapterInstance().TriggerClusterAndHostsAddOrUpdate(v2.Cluster{
+		Name:        name,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	}, hosts)
+	if err != nil {
+		t.Fatalf("add cluster failed: %v", err)
+	}
+}
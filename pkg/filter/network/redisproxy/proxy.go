@@ -0,0 +1,279 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redisproxy
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/redis"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+const unsupportedCommand = "unsupported"
+
+// redisProxy is the read filter of the downstream connection, the pipelined commands are sent to
+// the shards concurrently, and the responses are written back in the order of the commands
+// api.ReadFilter
+// api.ConnectionEventListener
+type redisProxy struct {
+	factory       *redisProxyFilterConfigFactory
+	readCallbacks api.ReadFilterCallbacks
+
+	mux     sync.Mutex
+	pending []*pendingRequest
+	closed  bool
+	// quit closes the connection after the pending responses are written
+	quit bool
+}
+
+// pendingRequest is a downstream command waiting for the responses of the shards
+type pendingRequest struct {
+	proxy     *redisProxy
+	stats     *commandStats
+	start     time.Time
+	merge     mergeFunc
+	responses []*redis.Value
+	remaining int
+	resp      *redis.Value
+	done      bool
+}
+
+func newRedisProxy(factory *redisProxyFilterConfigFactory) *redisProxy {
+	return &redisProxy{
+		factory: factory,
+	}
+}
+
+func (p *redisProxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	for buf.Len() > 0 {
+		// the commands after QUIT are ignored
+		p.mux.Lock()
+		quit := p.quit
+		p.mux.Unlock()
+		if quit {
+			buf.Drain(buf.Len())
+			break
+		}
+
+		command, n, err := redis.Decode(buf.Bytes())
+		if err != nil {
+			log.DefaultLogger.Errorf("[redis proxy] decode downstream command failed: %v", err)
+			p.factory.stats.protocolError.Inc(1)
+			buf.Drain(buf.Len())
+			p.onProtocolError()
+			return api.Stop
+		}
+		if command == nil {
+			break
+		}
+		buf.Drain(n)
+		p.handleCommand(command)
+	}
+	return api.Stop
+}
+
+func (p *redisProxy) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (p *redisProxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	p.readCallbacks = cb
+	p.readCallbacks.Connection().AddConnectionEventListener(p)
+}
+
+// api.ConnectionEventListener
+func (p *redisProxy) OnEvent(event api.ConnectionEvent) {
+	if event.IsClose() {
+		p.mux.Lock()
+		p.closed = true
+		p.pending = nil
+		p.mux.Unlock()
+	}
+}
+
+func (p *redisProxy) handleCommand(command *redis.Value) {
+	req := &pendingRequest{
+		proxy: p,
+		start: time.Now(),
+	}
+	p.mux.Lock()
+	p.pending = append(p.pending, req)
+	p.mux.Unlock()
+
+	args, ok := command.Args()
+	if !ok {
+		req.stats = p.factory.stats.command(unsupportedCommand)
+		p.complete(req, redis.NewError("ERR invalid command, an array of bulk strings is expected"))
+		return
+	}
+	name := strings.ToLower(args[0])
+
+	// the commands handled by the proxy
+	switch name {
+	case "ping":
+		req.stats = p.factory.stats.command(name)
+		if len(args) > 1 {
+			p.complete(req, redis.NewBulkString(args[1]))
+		} else {
+			p.complete(req, redis.NewSimpleString("PONG"))
+		}
+		return
+	case "quit":
+		req.stats = p.factory.stats.command(name)
+		p.mux.Lock()
+		p.quit = true
+		p.mux.Unlock()
+		p.complete(req, redis.NewSimpleString("OK"))
+		return
+	}
+
+	requests, merge, errResp := splitCommand(name, command, args)
+	if errResp != nil {
+		if _, ok := commands[name]; !ok {
+			name = unsupportedCommand
+		}
+		req.stats = p.factory.stats.command(name)
+		p.complete(req, errResp)
+		return
+	}
+	req.stats = p.factory.stats.command(name)
+	req.merge = merge
+	req.responses = make([]*redis.Value, len(requests))
+	req.remaining = len(requests)
+	for i := range requests {
+		idx := i
+		p.route(requests[i], func(resp *redis.Value) {
+			req.onResponse(idx, resp)
+		})
+	}
+}
+
+// route sends the request to the shard of the key
+func (p *redisProxy) route(request shardRequest, callback func(*redis.Value)) {
+	host, err := p.factory.shard(request.key)
+	if err != nil {
+		log.DefaultLogger.Errorf("[redis proxy] route command failed: %v", err)
+		callback(redis.NewError("ERR no upstream host"))
+		return
+	}
+	p.send(host, request.command, false, false, callback)
+}
+
+// send sends the command to the upstream, and follows the redirection once if it is enabled
+func (p *redisProxy) send(host types.Host, command *redis.Value, asking bool, redirected bool, callback func(*redis.Value)) {
+	p.factory.pool.send(host, command, asking, p.factory.config.OpTimeout.Duration, func(resp *redis.Value) {
+		if p.factory.config.EnableRedirection && !redirected && resp.IsError() {
+			if addr, ask, ok := parseRedirection(resp.Str); ok {
+				if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+					log.DefaultLogger.Debugf("[redis proxy] follow the redirection: %s", resp.Str)
+				}
+				target, err := p.factory.hostByAddress(addr)
+				if err != nil {
+					log.DefaultLogger.Errorf("[redis proxy] follow the redirection failed: %v", err)
+					callback(resp)
+					return
+				}
+				p.factory.stats.redirection.Inc(1)
+				p.send(target, command, ask, true, callback)
+				return
+			}
+		}
+		callback(resp)
+	})
+}
+
+// parseRedirection parses the MOVED and ASK error of the Redis Cluster, e.g. "MOVED 3999 127.0.0.1:6381"
+func parseRedirection(msg string) (addr string, asking bool, ok bool) {
+	parts := strings.Fields(msg)
+	if len(parts) != 3 {
+		return "", false, false
+	}
+	switch parts[0] {
+	case "MOVED":
+		return parts[2], false, true
+	case "ASK":
+		return parts[2], true, true
+	}
+	return "", false, false
+}
+
+func (req *pendingRequest) onResponse(idx int, resp *redis.Value) {
+	p := req.proxy
+	p.mux.Lock()
+	req.responses[idx] = resp
+	req.remaining--
+	finished := req.remaining == 0
+	p.mux.Unlock()
+
+	if finished {
+		p.complete(req, req.merge(req.responses))
+	}
+}
+
+// complete sets the response of the request, and writes the responses that are ready in order
+func (p *redisProxy) complete(req *pendingRequest, resp *redis.Value) {
+	req.stats.total.Inc(1)
+	if resp.IsError() {
+		req.stats.err.Inc(1)
+	} else {
+		req.stats.success.Inc(1)
+	}
+	req.stats.latency.Update(time.Since(req.start).Nanoseconds() / int64(time.Microsecond))
+
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		return
+	}
+	req.resp = resp
+	req.done = true
+	var data []byte
+	for len(p.pending) > 0 && p.pending[0].done {
+		data = p.pending[0].resp.Encode(data)
+		p.pending[0] = nil
+		p.pending = p.pending[1:]
+	}
+	quit := p.quit && len(p.pending) == 0
+	p.mux.Unlock()
+
+	conn := p.readCallbacks.Connection()
+	if len(data) > 0 {
+		conn.Write(buffer.NewIoBufferBytes(data))
+	}
+	if quit {
+		conn.Close(api.FlushWrite, api.LocalClose)
+	}
+}
+
+// onProtocolError responds the error after the pending responses, and closes the connection
+func (p *redisProxy) onProtocolError() {
+	req := &pendingRequest{
+		proxy: p,
+		start: time.Now(),
+		stats: p.factory.stats.command(unsupportedCommand),
+	}
+	p.mux.Lock()
+	p.pending = append(p.pending, req)
+	p.quit = true
+	p.mux.Unlock()
+	p.complete(req, redis.NewError("ERR Protocol error"))
+}
@@ -0,0 +1,339 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redisproxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/protocol/redis"
+	"mosn.io/pkg/buffer"
+)
+
+func newTestProxy(t *testing.T, cfg map[string]interface{}) (*redisProxy, *mockConnection) {
+	factory, err := CreateRedisProxyFactory(cfg)
+	if err != nil {
+		t.Fatalf("create factory failed: %v", err)
+	}
+	p := newRedisProxy(factory.(*redisProxyFilterConfigFactory))
+	conn := &mockConnection{}
+	p.InitializeReadFilterCallbacks(&mockReadFilterCallbacks{conn: conn})
+	return p, conn
+}
+
+// sendCommands sends the pipelined commands to the proxy
+func sendCommands(p *redisProxy, commands ...*redis.Value) {
+	var data []byte
+	for _, command := range commands {
+		data = command.Encode(data)
+	}
+	p.OnData(buffer.NewIoBufferBytes(data))
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"stat_prefix": "redis",
+		"cluster":     "redis_cluster",
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if cfg.OpTimeout.Duration != defaultOpTimeout || cfg.EnableRedirection {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if _, err := parseConfig(map[string]interface{}{}); err == nil {
+		t.Error("cluster should be required")
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		keys     []string
+		errorMsg string
+	}{
+		{args: []string{"GET", "k"}, keys: []string{"k"}},
+		{args: []string{"EVAL", "return 1", "1", "k", "arg"}, keys: []string{"k"}},
+		{args: []string{"MGET", "k1", "k2"}, keys: []string{"k1", "k2"}},
+		{args: []string{"MSET", "k1", "v1", "k2", "v2"}, keys: []string{"k1", "k2"}},
+		{args: []string{"DEL", "k1", "k2", "k3"}, keys: []string{"k1", "k2", "k3"}},
+		{args: []string{"GET"}, errorMsg: "ERR wrong number of arguments"},
+		{args: []string{"MSET", "k1", "v1", "k2"}, errorMsg: "ERR wrong number of arguments"},
+		{args: []string{"EVAL", "return 1", "0"}, errorMsg: "ERR wrong number of arguments"},
+		{args: []string{"EVAL", "return 1", "0", "arg"}, errorMsg: "requires at least one key"},
+		{args: []string{"KEYS", "*"}, errorMsg: "ERR unsupported command"},
+	} {
+		requests, _, errResp := splitCommand(strings.ToLower(tc.args[0]), redis.NewCommand(tc.args...), tc.args)
+		if tc.errorMsg != "" {
+			if errResp == nil || !strings.Contains(errResp.Str, tc.errorMsg) {
+				t.Errorf("%v expected error %s, but got %v", tc.args, tc.errorMsg, errResp)
+			}
+			continue
+		}
+		if errResp != nil || len(requests) != len(tc.keys) {
+			t.Fatalf("%v split failed: %v, %v", tc.args, requests, errResp)
+		}
+		for i, req := range requests {
+			if req.key != tc.keys[i] {
+				t.Errorf("%v expected key %s, but got %s", tc.args, tc.keys[i], req.key)
+			}
+		}
+	}
+}
+
+func TestRedisProxySharding(t *testing.T) {
+	s1, s2 := newFakeRedisServer(t), newFakeRedisServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	setupCluster(t, "redis_sharding", s1, s2)
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"cluster": "redis_sharding",
+	})
+
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	var commands []*redis.Value
+	for _, key := range keys {
+		commands = append(commands, redis.NewCommand("SET", key, "value-"+key))
+	}
+	// the responses of the pipelined commands are in order
+	commands = append(commands, redis.NewCommand("PING"))
+	for _, key := range keys {
+		commands = append(commands, redis.NewCommand("GET", key))
+	}
+	sendCommands(p, commands...)
+	responses := conn.readResponses(t, 2*len(keys)+1)
+	if responses[len(keys)].Str != "PONG" {
+		t.Errorf("unexpected ping response: %s", responses[len(keys)])
+	}
+	for i, key := range keys {
+		if responses[i].Str != "OK" {
+			t.Errorf("set %s failed: %s", key, responses[i])
+		}
+		if resp := responses[len(keys)+1+i]; resp.Str != "value-"+key {
+			t.Errorf("get %s unexpected response: %s", key, resp)
+		}
+		// the slots are divided evenly by the hosts in order
+		shard, other := s1, s2
+		if redis.Slot(key) >= redis.SlotCount/2 {
+			shard, other = s2, s1
+		}
+		if _, ok := shard.get(key); !ok {
+			t.Errorf("key %s should be stored on shard %s", key, shard.Addr())
+		}
+		if _, ok := other.get(key); ok {
+			t.Errorf("key %s should not be stored on shard %s", key, other.Addr())
+		}
+	}
+}
+
+func TestRedisProxyMultiKeys(t *testing.T) {
+	s1, s2 := newFakeRedisServer(t), newFakeRedisServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	setupCluster(t, "redis_multikeys", s1, s2)
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"cluster": "redis_multikeys",
+	})
+
+	sendCommands(p,
+		redis.NewCommand("MSET", "a", "1", "b", "2", "c", "3", "d", "4"),
+		redis.NewCommand("MGET", "a", "b", "x", "c", "d"),
+		redis.NewCommand("DEL", "a", "b", "x"),
+		redis.NewCommand("EXISTS", "a", "c", "d"),
+		redis.NewCommand("KEYS", "*"),
+		redis.NewCommand("GET"),
+	)
+	responses := conn.readResponses(t, 6)
+	if responses[0].Str != "OK" {
+		t.Errorf("unexpected mset response: %s", responses[0])
+	}
+	mget := responses[1]
+	if len(mget.Array) != 5 || mget.Array[0].Str != "1" || mget.Array[1].Str != "2" || !mget.Array[2].Null ||
+		mget.Array[3].Str != "3" || mget.Array[4].Str != "4" {
+		t.Errorf("unexpected mget response: %s", mget)
+	}
+	if responses[2].Int != 2 {
+		t.Errorf("unexpected del response: %s", responses[2])
+	}
+	if responses[3].Int != 2 {
+		t.Errorf("unexpected exists response: %s", responses[3])
+	}
+	if !responses[4].IsError() || !strings.Contains(responses[4].Str, "unsupported command") {
+		t.Errorf("unexpected keys response: %s", responses[4])
+	}
+	if !responses[5].IsError() || !strings.Contains(responses[5].Str, "wrong number of arguments") {
+		t.Errorf("unexpected get response: %s", responses[5])
+	}
+	// the keys are fanned out to both shards
+	if s1.size() == 0 || s2.size() == 0 {
+		t.Errorf("the keys should be stored on both shards, %d, %d", s1.size(), s2.size())
+	}
+}
+
+func TestRedisProxyRedirection(t *testing.T) {
+	s1, s2 := newFakeRedisServer(t), newFakeRedisServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	// the cluster only knows the first server
+	setupCluster(t, "redis_redirection", s1)
+	s1.redirect("moved", s2.Addr(), false)
+	s1.redirect("migrating", s2.Addr(), true)
+	s2.set("moved", "moved-value")
+	s2.set("migrating", "migrating-value")
+	s2.redirect("migrating", s2.Addr(), true)
+
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"cluster":            "redis_redirection",
+		"enable_redirection": true,
+	})
+	redirections := p.factory.stats.redirection.Count()
+	sendCommands(p, redis.NewCommand("GET", "moved"), redis.NewCommand("GET", "migrating"))
+	responses := conn.readResponses(t, 2)
+	if responses[0].Str != "moved-value" {
+		t.Errorf("unexpected response of moved key: %s", responses[0])
+	}
+	// the ASKING is sent before the command
+	if responses[1].Str != "migrating-value" {
+		t.Errorf("unexpected response of migrating key: %s", responses[1])
+	}
+	if n := p.factory.stats.redirection.Count() - redirections; n != 2 {
+		t.Errorf("expected 2 redirections, but got %d", n)
+	}
+
+	// the redirection is responded directly if it is disabled
+	p, conn = newTestProxy(t, map[string]interface{}{
+		"cluster": "redis_redirection",
+	})
+	sendCommands(p, redis.NewCommand("GET", "moved"))
+	responses = conn.readResponses(t, 1)
+	if !responses[0].IsError() || !strings.HasPrefix(responses[0].Str, "MOVED") {
+		t.Errorf("unexpected response of moved key: %s", responses[0])
+	}
+}
+
+func TestRedisProxyTimeout(t *testing.T) {
+	s := newFakeRedisServer(t)
+	defer s.Close()
+	s.setDelay(300 * time.Millisecond)
+	setupCluster(t, "redis_timeout", s)
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"cluster":    "redis_timeout",
+		"op_timeout": "100ms",
+	})
+
+	sendCommands(p, redis.NewCommand("SET", "k", "v"))
+	responses := conn.readResponses(t, 1)
+	if responses[0].Str != errTimeout.Str {
+		t.Errorf("expected timeout, but got %s", responses[0])
+	}
+	// the late response is dropped, and the next response is paired correctly
+	time.Sleep(300 * time.Millisecond)
+	s.setDelay(0)
+	sendCommands(p, redis.NewCommand("GET", "k"))
+	responses = conn.readResponses(t, 2)
+	if responses[1].Str != "v" {
+		t.Errorf("unexpected response: %s", responses[1])
+	}
+}
+
+func TestRedisProxyUpstreamFailure(t *testing.T) {
+	s := newFakeRedisServer(t)
+	setupCluster(t, "redis_failure", s)
+	s.Close()
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"cluster": "redis_failure",
+	})
+	sendCommands(p, redis.NewCommand("GET", "k"))
+	responses := conn.readResponses(t, 1)
+	if responses[0].Str != errConnectFailed.Str {
+		t.Errorf("expected connect failure, but got %s", responses[0])
+	}
+}
+
+func TestRedisProxyQuitAndProtocolError(t *testing.T) {
+	s := newFakeRedisServer(t)
+	defer s.Close()
+	setupCluster(t, "redis_quit", s)
+
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"cluster": "redis_quit",
+	})
+	sendCommands(p, redis.NewCommand("SET", "k", "v"), redis.NewCommand("QUIT"), redis.NewCommand("SET", "k", "v2"))
+	responses := conn.readResponses(t, 2)
+	if responses[0].Str != "OK" || responses[1].Str != "OK" || len(responses) != 2 {
+		t.Errorf("unexpected responses: %v", responses)
+	}
+	if !conn.isClosed() {
+		t.Error("the connection should be closed after quit")
+	}
+	if v, _ := s.get("k"); v != "v" {
+		t.Errorf("the command after quit should be ignored, but got %s", v)
+	}
+
+	p, conn = newTestProxy(t, map[string]interface{}{
+		"cluster": "redis_quit",
+	})
+	protocolErrors := p.factory.stats.protocolError.Count()
+	if status := p.OnData(buffer.NewIoBufferString("?invalid\r\n")); status != api.Stop {
+		t.Errorf("unexpected filter status: %v", status)
+	}
+	responses = conn.readResponses(t, 1)
+	if !responses[0].IsError() || !conn.isClosed() {
+		t.Errorf("the connection should be closed with protocol error, but got %s", responses[0])
+	}
+	if n := p.factory.stats.protocolError.Count() - protocolErrors; n != 1 {
+		t.Errorf("unexpected protocol error count: %d", n)
+	}
+}
+
+func TestRedisProxyStats(t *testing.T) {
+	s := newFakeRedisServer(t)
+	defer s.Close()
+	setupCluster(t, "redis_stats", s)
+	metrics.ResetAll()
+	p, conn := newTestProxy(t, map[string]interface{}{
+		"cluster":     "redis_stats",
+		"stat_prefix": "redis_stats",
+	})
+	sendCommands(p, redis.NewCommand("set", "k", "v"), redis.NewCommand("GET", "k"), redis.NewCommand("GET", "k"),
+		redis.NewCommand("FLUSHALL"))
+	conn.readResponses(t, 4)
+
+	stats := p.factory.stats
+	for _, tc := range []struct {
+		command string
+		total   int64
+		success int64
+		err     int64
+	}{
+		{command: "set", total: 1, success: 1},
+		{command: "get", total: 2, success: 2},
+		{command: unsupportedCommand, total: 1, err: 1},
+	} {
+		s := stats.command(tc.command)
+		if s.total.Count() != tc.total || s.success.Count() != tc.success || s.err.Count() != tc.err {
+			t.Errorf("%s unexpected stats, total: %d, success: %d, error: %d", tc.command,
+				s.total.Count(), s.success.Count(), s.err.Count())
+		}
+		if s.latency.Count() != tc.total {
+			t.Errorf("%s unexpected latency count: %d", tc.command, s.latency.Count())
+		}
+	}
+}
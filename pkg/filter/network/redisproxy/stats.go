@@ -0,0 +1,76 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redisproxy
+
+import (
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+const metricsType = "redis_proxy"
+
+// metrics key
+const (
+	statsTotal         = "total"
+	statsSuccess       = "success"
+	statsError         = "error"
+	statsLatency       = "latency"
+	statsProtocolError = "downstream_protocol_error"
+	statsRedirection   = "redirection"
+)
+
+type proxyStats struct {
+	prefix   string
+	commands sync.Map // command -> *commandStats
+
+	protocolError gometrics.Counter
+	redirection   gometrics.Counter
+}
+
+type commandStats struct {
+	total   gometrics.Counter
+	success gometrics.Counter
+	err     gometrics.Counter
+	// latency is the duration of the command in microseconds
+	latency gometrics.Histogram
+}
+
+func newProxyStats(prefix string) *proxyStats {
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"stat_prefix": prefix})
+	return &proxyStats{
+		prefix:        prefix,
+		protocolError: m.Counter(statsProtocolError),
+		redirection:   m.Counter(statsRedirection),
+	}
+}
+
+// command returns the stats of the command, the unsupported commands share the same stats
+func (s *proxyStats) command(name string) *commandStats {
+	if v, ok := s.commands.Load(name); ok {
+		return v.(*commandStats)
+	}
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"stat_prefix": s.prefix, "command": name})
+	v, _ := s.commands.LoadOrStore(name, &commandStats{
+		total:   m.Counter(statsTotal),
+		success: m.Counter(statsSuccess),
+		err:     m.Counter(statsError),
+		latency: m.Histogram(statsLatency),
+	})
+	return v.(*commandStats)
+}
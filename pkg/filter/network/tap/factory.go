@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"context"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/tap"
+)
+
+func init() {
+	api.RegisterNetwork(v2.TAP_NETWORK_FILTER, CreateTapFactory)
+}
+
+type tapConfigFactory struct {
+	tap *tap.Tap
+}
+
+func (f *tapConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	tf := NewTapFilter(context, f.tap)
+	callbacks.AddReadFilter(tf)
+	callbacks.AddWriteFilter(tf)
+}
+
+// CreateTapFactory creates the tap network filter factory
+func CreateTapFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	cfg, err := tap.ParseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	t, err := tap.NewTap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &tapConfigFactory{
+		tap: t,
+	}, nil
+}
@@ -0,0 +1,148 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/tap"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// tapFilter captures the bytes read from and written to the connection, the trace is submitted when the connection is closed.
+// It should be the first network filter, so the read bytes are captured before they are drained by the proxy.
+type tapFilter struct {
+	ctx context.Context
+	tap *tap.Tap
+
+	mux       sync.Mutex
+	conn      api.Connection
+	tapped    bool
+	startTime time.Time
+	read      *tap.Capture
+	written   *tap.Capture
+	// lastRead is the bytes read by the last read, which are the tail of the read buffer
+	lastRead uint64
+}
+
+// TapFilter is both the read filter and the write filter
+type TapFilter interface {
+	api.ReadFilter
+	api.WriteFilter
+}
+
+// NewTapFilter makes a tap filter as types.ReadFilter and types.WriteFilter
+func NewTapFilter(ctx context.Context, t *tap.Tap) TapFilter {
+	return &tapFilter{
+		ctx: ctx,
+		tap: t,
+	}
+}
+
+func (f *tapFilter) OnData(buf types.IoBuffer) api.FilterStatus {
+	if !f.tapped {
+		return api.Continue
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.read == nil {
+		return api.Continue
+	}
+	// the bytes not drained by the next filters are left in the buffer, only the new bytes are captured
+	if n := int(f.lastRead); n > 0 {
+		if n > buf.Len() {
+			n = buf.Len()
+		}
+		f.read.Write(buf.Bytes()[buf.Len()-n:])
+		f.lastRead = 0
+	}
+	return api.Continue
+}
+
+func (f *tapFilter) OnWrite(buffers []buffer.IoBuffer) api.FilterStatus {
+	if !f.tapped {
+		return api.Continue
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.written == nil {
+		return api.Continue
+	}
+	for _, buf := range buffers {
+		if buf != nil {
+			f.written.Write(buf.Bytes())
+		}
+	}
+	return api.Continue
+}
+
+func (f *tapFilter) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (f *tapFilter) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	conn := cb.Connection()
+	if !f.tap.Active() || !f.tap.MatchConnection(conn.RemoteAddr()) {
+		return
+	}
+	f.tapped = true
+	f.conn = conn
+	f.startTime = time.Now()
+	f.read = tap.NewCapture(f.tap.MaxBodyBytes())
+	f.written = tap.NewCapture(f.tap.MaxBodyBytes())
+	conn.AddBytesReadListener(f.onBytesRead)
+	conn.AddConnectionEventListener(f)
+}
+
+func (f *tapFilter) onBytesRead(bytesRead uint64) {
+	f.mux.Lock()
+	f.lastRead = bytesRead
+	f.mux.Unlock()
+}
+
+// OnEvent submits the trace when the connection is closed
+func (f *tapFilter) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.read == nil {
+		return
+	}
+	trace := &tap.Trace{
+		Protocol:  "tcp",
+		StartTime: f.startTime,
+		Duration:  time.Since(f.startTime).String(),
+		Read:      f.read.Body(true),
+		Written:   f.written.Body(true),
+	}
+	if addr := f.conn.LocalAddr(); addr != nil {
+		trace.LocalAddress = addr.String()
+	}
+	if addr := f.conn.RemoteAddr(); addr != nil {
+		trace.RemoteAddress = addr.String()
+	}
+	// submitted once
+	f.read, f.written = nil, nil
+	f.tap.Submit(trace)
+}
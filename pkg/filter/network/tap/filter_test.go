@@ -0,0 +1,131 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/tap"
+	"mosn.io/pkg/buffer"
+)
+
+func newFilter(t *testing.T, conf map[string]interface{}, conn *mockConnection) TapFilter {
+	factory, err := CreateTapFactory(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewTapFilter(context.Background(), factory.(*tapConfigFactory).tap)
+	f.InitializeReadFilterCallbacks(&mockReadFilterCallbacks{conn: conn})
+	return f
+}
+
+func newMockConnection(ip string) *mockConnection {
+	return &mockConnection{
+		remote: &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345},
+		local:  &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 80},
+	}
+}
+
+func decode(t *testing.T, body *tap.Body) string {
+	if !body.Base64 {
+		t.Fatalf("expected base64 body: %+v", body)
+	}
+	data, err := base64.StdEncoding.DecodeString(body.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestTapConnection(t *testing.T) {
+	s := tap.Subscribe("tcp")
+	defer tap.Unsubscribe(s)
+
+	conn := newMockConnection("10.0.0.1")
+	f := newFilter(t, map[string]interface{}{
+		"id":             "tcp",
+		"max_body_bytes": 8,
+		"match": map[string]interface{}{
+			"source_ips": []interface{}{"10.0.0.0/24"},
+		},
+	}, conn)
+
+	// the next filter drains part of the buffer, the left bytes should not be captured again
+	buf := buffer.NewIoBufferString("hello")
+	conn.onRead(5)
+	f.OnData(buf)
+	buf.Drain(3)
+	buf.WriteString(" world")
+	conn.onRead(6)
+	f.OnData(buf)
+	f.OnWrite([]buffer.IoBuffer{buffer.NewIoBufferString("ok"), nil})
+	conn.close()
+	// closed twice
+	conn.close()
+
+	var trace *tap.Trace
+	select {
+	case data := <-s.Traces():
+		trace = &tap.Trace{}
+		if err := json.Unmarshal(data, trace); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected trace")
+	}
+	if trace.Protocol != "tcp" || trace.RemoteAddress != "10.0.0.1:12345" || trace.LocalAddress != "10.0.0.2:80" {
+		t.Fatalf("unexpected trace: %+v", trace)
+	}
+	if read := decode(t, trace.Read); read != "hello wo" || trace.Read.Size != 11 || !trace.Read.Truncated {
+		t.Fatalf("unexpected read: %s, %+v", read, trace.Read)
+	}
+	if written := decode(t, trace.Written); written != "ok" || trace.Written.Size != 2 {
+		t.Fatalf("unexpected written: %s, %+v", written, trace.Written)
+	}
+	if len(s.Traces()) != 0 {
+		t.Fatal("the trace should be submitted once")
+	}
+	// the writes after closed are ignored
+	f.OnWrite([]buffer.IoBuffer{buffer.NewIoBufferString("ok")})
+}
+
+func TestTapConnectionNotMatched(t *testing.T) {
+	s := tap.Subscribe("tcp")
+	defer tap.Unsubscribe(s)
+
+	conn := newMockConnection("192.168.0.1")
+	f := newFilter(t, map[string]interface{}{
+		"id": "tcp",
+		"match": map[string]interface{}{
+			"source_ips": []interface{}{"10.0.0.0/24"},
+		},
+	}, conn)
+	if conn.onRead != nil || len(conn.listeners) != 0 {
+		t.Fatal("the connection should not be tapped")
+	}
+	f.OnData(buffer.NewIoBufferString("hello"))
+	f.OnWrite([]buffer.IoBuffer{buffer.NewIoBufferString("ok")})
+	if len(s.Traces()) != 0 {
+		t.Fatal("expected no trace")
+	}
+}
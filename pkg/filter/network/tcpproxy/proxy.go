@@ -224,12 +224,16 @@ func (p *proxy) onDownstreamEvent(event api.ConnectionEvent) {
 	}
 }
 
+// ReadDisableUpstream stops or resumes reading the upstream connection
 func (p *proxy) ReadDisableUpstream(disable bool) {
-	// TODO
+	if p.upstreamConnection != nil {
+		p.upstreamConnection.SetReadDisable(disable)
+	}
 }
 
+// ReadDisableDownstream stops or resumes reading the downstream connection
 func (p *proxy) ReadDisableDownstream(disable bool) {
-	// TODO
+	p.readCallbacks.Connection().SetReadDisable(disable)
 }
 
 type proxyConfig struct {
@@ -376,6 +380,7 @@ func (pc *proxyConfig) GetRouteFromEntries(connection api.Connection) string {
 
 // ConnectionEventListener
 // ReadFilter
+// types.WatermarkListener
 type upstreamCallbacks struct {
 	proxy *proxy
 }
@@ -401,7 +406,17 @@ func (uc *upstreamCallbacks) OnNewConnection() api.FilterStatus {
 
 func (uc *upstreamCallbacks) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {}
 
+// the downstream data is not read until the upstream connection writes the buffered data
+func (uc *upstreamCallbacks) OnAboveWriteBufferHighWatermark() {
+	uc.proxy.ReadDisableDownstream(true)
+}
+
+func (uc *upstreamCallbacks) OnBelowWriteBufferLowWatermark() {
+	uc.proxy.ReadDisableDownstream(false)
+}
+
 // ConnectionEventListener
+// types.WatermarkListener
 type downstreamCallbacks struct {
 	proxy *proxy
 }
@@ -410,6 +425,15 @@ func (dc *downstreamCallbacks) OnEvent(event api.ConnectionEvent) {
 	dc.proxy.onDownstreamEvent(event)
 }
 
+// the upstream data is not read until the downstream connection writes the buffered data
+func (dc *downstreamCallbacks) OnAboveWriteBufferHighWatermark() {
+	dc.proxy.ReadDisableUpstream(true)
+}
+
+func (dc *downstreamCallbacks) OnBelowWriteBufferLowWatermark() {
+	dc.proxy.ReadDisableUpstream(false)
+}
+
 // LbContext is a types.LoadBalancerContext implementation
 type LbContext struct {
 	conn    api.ReadFilterCallbacks
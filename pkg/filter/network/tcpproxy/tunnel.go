@@ -0,0 +1,319 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tcpproxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+var (
+	ErrTunnelHandshakeTimeout = errors.New("tunnel handshake timeout")
+	ErrTunnelClosed           = errors.New("tunnel closed")
+
+	headerEnd = []byte("\r\n\r\n")
+)
+
+// Tunnel is a raw tcp tunnel between a stream and an upstream connection,
+// such as the tunnel established by a HTTP CONNECT request.
+// The data received from the upstream before the tunnel started is kept, and sent to the downstream when started.
+type Tunnel struct {
+	upstreamConnection types.ClientConnection
+	// cluster counts the tunnel in its connections resource, it is nil if the tunnel is not created by a cluster
+	cluster     types.ClusterInfo
+	requestInfo types.RequestInfo
+
+	mux         sync.Mutex
+	downstream  types.TunnelSender
+	onClose     func()
+	pending     buffer.IoBuffer
+	onHandshake func(err error)
+	timer       *time.Timer
+	// readDisabled is set when the upstream connection is above the write buffer high watermark,
+	// the downstream stops receiving the tunnel data until it is below the low watermark
+	readDisabled bool
+	// sendMux keeps the order of the data sent to the downstream, the sending may wait for
+	// the flow control of the downstream, so the data is sent without holding mux
+	sendMux sync.Mutex
+
+	closed   uint32
+	acquired uint32
+}
+
+// NewTunnel creates a tunnel with the upstream connection, the connection is counted by the cluster when connected
+func NewTunnel(connection types.ClientConnection, cluster types.ClusterInfo, requestInfo types.RequestInfo) *Tunnel {
+	t := &Tunnel{
+		upstreamConnection: connection,
+		cluster:            cluster,
+		requestInfo:        requestInfo,
+		pending:            buffer.GetIoBuffer(0),
+	}
+	t.upstreamConnection.AddConnectionEventListener(t)
+	t.upstreamConnection.FilterManager().AddReadFilter(t)
+	return t
+}
+
+// RemoteAddr returns the upstream address of the tunnel
+func (t *Tunnel) RemoteAddr() net.Addr {
+	return t.upstreamConnection.RemoteAddr()
+}
+
+// Connect connects to the upstream
+func (t *Tunnel) Connect() error {
+	if err := t.upstreamConnection.Connect(); err != nil {
+		return err
+	}
+	if t.cluster != nil && atomic.CompareAndSwapUint32(&t.acquired, 0, 1) {
+		t.cluster.ResourceManager().Connections().Increase()
+	}
+	return nil
+}
+
+// Handshake sends a CONNECT request to the upstream, which is a http proxy too.
+// It does not wait for the response, cb is called once with the result when the response
+// is received, the handshake is timeout, or the tunnel is closed.
+func (t *Tunnel) Handshake(authority string, timeout time.Duration, cb func(err error)) {
+	t.mux.Lock()
+	t.onHandshake = cb
+	t.timer = time.AfterFunc(timeout, func() {
+		t.finishHandshake(ErrTunnelHandshakeTimeout)
+	})
+	t.mux.Unlock()
+
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", authority, authority)
+	if err := t.upstreamConnection.Write(buffer.NewIoBufferString(req)); err != nil {
+		t.finishHandshake(err)
+	}
+}
+
+// finishHandshake calls the handshake callback if the handshake is not finished yet
+func (t *Tunnel) finishHandshake(err error) {
+	t.mux.Lock()
+	cb := t.onHandshake
+	t.onHandshake = nil
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.mux.Unlock()
+
+	if cb != nil {
+		cb(err)
+	}
+}
+
+// Start starts the tunnel, the data received from upstream will be sent to the downstream,
+// onClose is called when the tunnel is closed, even if it is closed before started.
+func (t *Tunnel) Start(downstream types.TunnelSender, onClose func()) {
+	t.mux.Lock()
+	if atomic.LoadUint32(&t.closed) == 1 {
+		t.mux.Unlock()
+		downstream.CloseTunnel()
+		if onClose != nil {
+			onClose()
+		}
+		return
+	}
+	t.downstream = downstream
+	t.onClose = onClose
+	if t.readDisabled {
+		downstream.ReadDisableTunnel(true)
+	}
+	if t.pending.Len() == 0 {
+		t.mux.Unlock()
+		return
+	}
+	data := t.pending.Clone()
+	t.pending.Drain(t.pending.Len())
+	t.sendDownstream(downstream, data)
+}
+
+// Close closes the tunnel
+func (t *Tunnel) Close() {
+	t.close(api.NoFlush)
+}
+
+func (t *Tunnel) close(ccType api.ConnectionCloseType) {
+	if !atomic.CompareAndSwapUint32(&t.closed, 0, 1) {
+		return
+	}
+
+	t.upstreamConnection.Close(ccType, api.LocalClose)
+
+	t.mux.Lock()
+	// the downstream is cleared, so that the callbacks are called only once if the tunnel is starting concurrently
+	downstream, onClose := t.downstream, t.onClose
+	t.downstream, t.onClose = nil, nil
+	buffer.PutIoBuffer(t.pending)
+	t.pending = nil
+	t.mux.Unlock()
+
+	t.finishHandshake(ErrTunnelClosed)
+	if downstream != nil {
+		downstream.CloseTunnel()
+	}
+	if onClose != nil {
+		onClose()
+	}
+}
+
+// sendDownstream sends the data to the downstream, it is called with mux held, and releases it
+// before sending, the sendMux is held instead to keep the order of the data.
+func (t *Tunnel) sendDownstream(downstream types.TunnelSender, data buffer.IoBuffer) {
+	bytesSent := t.requestInfo.BytesSent() + uint64(data.Len())
+	t.requestInfo.SetBytesSent(bytesSent)
+
+	t.sendMux.Lock()
+	t.mux.Unlock()
+	defer t.sendMux.Unlock()
+	if err := downstream.SendTunnelData(data); err != nil {
+		log.DefaultLogger.Errorf("[tcpproxy] [tunnel] send downstream data failed: %v", err)
+	}
+}
+
+// checkHandshake parses the response of CONNECT request, it is called with the lock held.
+// done is false if the response is not received completely.
+func (t *Tunnel) checkHandshake() (done bool, err error) {
+	data := t.pending.Bytes()
+	idx := bytes.Index(data, headerEnd)
+	if idx < 0 {
+		return false, nil
+	}
+
+	// status line: HTTP/1.1 200 Connection Established
+	statusLine := data[:idx]
+	if i := bytes.Index(statusLine, []byte("\r\n")); i >= 0 {
+		statusLine = statusLine[:i]
+	}
+	fields := bytes.Fields(statusLine)
+	if len(fields) < 2 || !bytes.HasPrefix(fields[0], []byte("HTTP/")) || len(fields[1]) != 3 || fields[1][0] != '2' {
+		err = fmt.Errorf("upstream proxy refused the tunnel: %s", statusLine)
+	}
+
+	t.pending.Drain(idx + len(headerEnd))
+	return true, err
+}
+
+// types.TunnelReceiver
+func (t *Tunnel) OnTunnelData(data buffer.IoBuffer) {
+	bytesRecved := t.requestInfo.BytesReceived() + uint64(data.Len())
+	t.requestInfo.SetBytesReceived(bytesRecved)
+
+	if err := t.upstreamConnection.Write(data); err != nil {
+		log.DefaultLogger.Errorf("[tcpproxy] [tunnel] send upstream data failed: %v", err)
+	}
+}
+
+func (t *Tunnel) OnTunnelClose() {
+	t.close(api.FlushWrite)
+}
+
+// ReadDisableUpstream stops or resumes reading the upstream connection,
+// it is called when the downstream crosses the write buffer watermarks
+func (t *Tunnel) ReadDisableUpstream(disable bool) {
+	t.upstreamConnection.SetReadDisable(disable)
+}
+
+// readDisableDownstream stops or resumes receiving the data from the downstream.
+// The watermark callbacks are called by the upstream connection writing, so the lock is not held when calling the downstream.
+func (t *Tunnel) readDisableDownstream(disable bool) {
+	t.mux.Lock()
+	if t.readDisabled == disable {
+		t.mux.Unlock()
+		return
+	}
+	t.readDisabled = disable
+	downstream := t.downstream
+	t.mux.Unlock()
+
+	if downstream != nil {
+		downstream.ReadDisableTunnel(disable)
+	}
+}
+
+// api.ReadFilter
+func (t *Tunnel) OnData(data buffer.IoBuffer) api.FilterStatus {
+	t.mux.Lock()
+	// the pending buffer is released when closed
+	if t.pending == nil {
+		t.mux.Unlock()
+		data.Drain(data.Len())
+		return api.Stop
+	}
+
+	if t.downstream == nil {
+		t.pending.Write(data.Bytes())
+		data.Drain(data.Len())
+		var done bool
+		var err error
+		if t.onHandshake != nil {
+			done, err = t.checkHandshake()
+		}
+		t.mux.Unlock()
+		// the handshake callback may start the tunnel, so it is called without the lock
+		if done {
+			t.finishHandshake(err)
+		}
+		return api.Stop
+	}
+
+	buf := data.Clone()
+	data.Drain(data.Len())
+	t.sendDownstream(t.downstream, buf)
+	return api.Stop
+}
+
+func (t *Tunnel) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (t *Tunnel) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {}
+
+// types.WatermarkListener
+func (t *Tunnel) OnAboveWriteBufferHighWatermark() {
+	t.readDisableDownstream(true)
+}
+
+func (t *Tunnel) OnBelowWriteBufferLowWatermark() {
+	t.readDisableDownstream(false)
+}
+
+// api.ConnectionEventListener
+func (t *Tunnel) OnEvent(event api.ConnectionEvent) {
+	switch {
+	case event == api.Connected:
+		t.upstreamConnection.SetNoDelay(true)
+	case event.IsClose():
+		if t.cluster != nil && atomic.CompareAndSwapUint32(&t.acquired, 1, 0) {
+			t.cluster.ResourceManager().Connections().Decrease()
+		}
+		if event != api.LocalClose {
+			t.close(api.NoFlush)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package tcpproxy
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+type mockTunnelSender struct {
+	data         chan string
+	closed       chan struct{}
+	readDisabled []bool
+}
+
+func (s *mockTunnelSender) StartTunnel(ctx context.Context, receiver types.TunnelReceiver) error {
+	return nil
+}
+
+func (s *mockTunnelSender) SendTunnelData(data buffer.IoBuffer) error {
+	s.data <- data.String()
+	return nil
+}
+
+func (s *mockTunnelSender) ReadDisableTunnel(disable bool) {
+	s.readDisabled = append(s.readDisabled, disable)
+}
+
+func (s *mockTunnelSender) CloseTunnel() {
+	close(s.closed)
+}
+
+// startUpstreamProxy starts a http proxy that responses the CONNECT request with the status code,
+// and writes the greeting after the response
+func startUpstreamProxy(t *testing.T, status int, greeting string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect || req.Host != "example.com:443" {
+			return
+		}
+		resp := &http.Response{
+			StatusCode: status,
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+		}
+		resp.Write(conn)
+		conn.Write([]byte(greeting))
+		time.Sleep(time.Second)
+	}()
+	return ln
+}
+
+func newTestTunnel(addr net.Addr) *Tunnel {
+	conn := network.NewClientConnection(nil, time.Second, nil, addr, nil)
+	return NewTunnel(conn, nil, network.NewRequestInfo())
+}
+
+// handshake waits for the handshake callback of the tunnel
+func handshake(t *testing.T, tunnel *Tunnel, timeout time.Duration) error {
+	result := make(chan error, 1)
+	tunnel.Handshake("example.com:443", timeout, func(err error) {
+		result <- err
+	})
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout + time.Second):
+		t.Fatal("the handshake callback is not called")
+		return nil
+	}
+}
+
+func TestTunnelHandshake(t *testing.T) {
+	ln := startUpstreamProxy(t, http.StatusOK, "hello")
+	defer ln.Close()
+
+	tunnel := newTestTunnel(ln.Addr())
+	if err := tunnel.Connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer tunnel.Close()
+	if err := handshake(t, tunnel, 2*time.Second); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+
+	sender := &mockTunnelSender{
+		data:   make(chan string, 10),
+		closed: make(chan struct{}),
+	}
+	tunnel.Start(sender, nil)
+
+	var received string
+	timeout := time.After(2 * time.Second)
+	for received != "hello" {
+		select {
+		case data := <-sender.data:
+			received += data
+		case <-timeout:
+			t.Fatalf("expected greeting after handshake, got %q", received)
+		}
+	}
+}
+
+func TestTunnelHandshakeRefused(t *testing.T) {
+	ln := startUpstreamProxy(t, http.StatusForbidden, "")
+	defer ln.Close()
+
+	tunnel := newTestTunnel(ln.Addr())
+	if err := tunnel.Connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer tunnel.Close()
+	if err := handshake(t, tunnel, 2*time.Second); err == nil {
+		t.Fatal("expected handshake failed")
+	}
+}
+
+func TestTunnelHandshakeTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// the upstream never responses
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(2 * time.Second)
+		}
+	}()
+
+	tunnel := newTestTunnel(ln.Addr())
+	if err := tunnel.Connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer tunnel.Close()
+	if err := handshake(t, tunnel, 200*time.Millisecond); err != ErrTunnelHandshakeTimeout {
+		t.Fatalf("expected handshake timeout, but got %v", err)
+	}
+}
+
+func TestTunnelWatermark(t *testing.T) {
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	tunnel := newTestTunnel(addr)
+	// the upstream is above the high watermark before the tunnel started
+	tunnel.OnAboveWriteBufferHighWatermark()
+
+	sender := &mockTunnelSender{
+		data:   make(chan string, 10),
+		closed: make(chan struct{}),
+	}
+	tunnel.Start(sender, nil)
+	tunnel.OnBelowWriteBufferLowWatermark()
+	tunnel.OnAboveWriteBufferHighWatermark()
+
+	expected := []bool{true, false, true}
+	if !reflect.DeepEqual(sender.readDisabled, expected) {
+		t.Fatalf("expected read disable calls %v, but got %v", expected, sender.readDisabled)
+	}
+}
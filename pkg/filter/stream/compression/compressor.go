@@ -0,0 +1,170 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compression
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+const compressReadSize = 16 * 1024
+
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newCompressWriter(w io.Writer, encoding string, level int) (compressWriter, error) {
+	if encoding == encodingDeflate {
+		return flate.NewWriter(w, level)
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// compressBuffer compresses the whole data
+func compressBuffer(data []byte, encoding string, level int) (types.IoBuffer, error) {
+	out := buffer.NewIoBuffer(len(data)/2 + 64)
+	w, err := newCompressWriter(out, encoding, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// compressBody compresses a streaming body while it is read, the data read from the source
+// is flushed at once, so the downstream receives the compressed data without waiting for the whole body.
+type compressBody struct {
+	buffer.IoBuffer
+
+	mux    sync.Mutex
+	source types.StreamingBuffer
+	writer compressWriter
+	buf    []byte
+	err    error
+}
+
+func newCompressBody(source types.StreamingBuffer, encoding string, level int) (*compressBody, error) {
+	b := &compressBody{
+		IoBuffer: buffer.NewIoBuffer(compressReadSize),
+		source:   source,
+		buf:      make([]byte, compressReadSize),
+	}
+	w, err := newCompressWriter(b.IoBuffer, encoding, level)
+	if err != nil {
+		return nil, err
+	}
+	b.writer = w
+	return b, nil
+}
+
+// fill compresses the next part of the source, it is called with the lock held
+func (b *compressBody) fill() {
+	n, err := b.source.Read(b.buf)
+	if n > 0 {
+		if _, werr := b.writer.Write(b.buf[:n]); werr != nil {
+			b.err = werr
+			return
+		}
+	}
+	switch err {
+	case nil:
+		if n > 0 {
+			b.err = b.writer.Flush()
+		}
+	case io.EOF:
+		if b.err = b.writer.Close(); b.err == nil {
+			b.err = io.EOF
+		}
+	default:
+		b.err = err
+	}
+}
+
+// Read blocks until some compressed data is available, io.EOF is returned at the end of the body
+func (b *compressBody) Read(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for b.IoBuffer.Len() == 0 && b.err == nil {
+		b.fill()
+	}
+	if b.IoBuffer.Len() == 0 {
+		return 0, b.err
+	}
+	return b.IoBuffer.Read(p)
+}
+
+// readAll compresses the rest of the source, it is called with the lock held
+func (b *compressBody) readAll() {
+	for b.err == nil {
+		b.fill()
+	}
+}
+
+func (b *compressBody) Bytes() []byte {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.readAll()
+	return b.IoBuffer.Bytes()
+}
+
+func (b *compressBody) String() string {
+	return string(b.Bytes())
+}
+
+func (b *compressBody) Clone() buffer.IoBuffer {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.readAll()
+	return b.IoBuffer.Clone()
+}
+
+func (b *compressBody) Len() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.IoBuffer.Len()
+}
+
+// Count always keeps a reference, the body is not a pooled buffer
+func (b *compressBody) Count(count int32) int32 {
+	return 1
+}
+
+// CloseRead implements types.StreamingBuffer
+func (b *compressBody) CloseRead() {
+	b.source.CloseRead()
+}
+
+// SetActiveListener implements types.StreamingBuffer
+func (b *compressBody) SetActiveListener(listener func()) {
+	b.source.SetActiveListener(listener)
+}
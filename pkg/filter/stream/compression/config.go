@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compression
+
+import (
+	"compress/flate"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+const defaultMinContentLength = 30
+
+var defaultContentTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/xml",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+}
+
+type config struct {
+	// Disabled turns off the compression, it is useful to disable the compression for some routes
+	Disabled bool `json:"disabled,omitempty"`
+	// Encodings is the supported encodings in the order of preference, gzip and deflate are supported
+	Encodings []string `json:"encodings,omitempty"`
+	// Level is the compression level of gzip and deflate, 0 means the default level
+	Level int `json:"level,omitempty"`
+	// MinContentLength is the minimum response size to be compressed, the response with an unknown size is always compressed
+	MinContentLength int `json:"min_content_length,omitempty"`
+	// ContentTypes is the content types to be compressed, the parameters such as charset are ignored
+	ContentTypes []string `json:"content_types,omitempty"`
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if len(filterConfig.Encodings) == 0 {
+		filterConfig.Encodings = []string{encodingGzip, encodingDeflate}
+	}
+	for i, encoding := range filterConfig.Encodings {
+		encoding = strings.ToLower(encoding)
+		if encoding != encodingGzip && encoding != encodingDeflate {
+			return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+		}
+		filterConfig.Encodings[i] = encoding
+	}
+	if filterConfig.Level == 0 {
+		filterConfig.Level = flate.DefaultCompression
+	}
+	if filterConfig.Level < flate.HuffmanOnly || filterConfig.Level > flate.BestCompression {
+		return nil, fmt.Errorf("invalid compression level: %d", filterConfig.Level)
+	}
+	if filterConfig.MinContentLength == 0 {
+		filterConfig.MinContentLength = defaultMinContentLength
+	}
+	if len(filterConfig.ContentTypes) == 0 {
+		filterConfig.ContentTypes = append([]string(nil), defaultContentTypes...)
+	}
+	for i, contentType := range filterConfig.ContentTypes {
+		filterConfig.ContentTypes[i] = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return filterConfig, nil
+}
+
+// matchContentType returns true if the content type should be compressed
+func (c *config) matchContentType(contentType string) bool {
+	if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, t := range c.ContentTypes {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
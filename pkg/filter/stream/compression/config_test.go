@@ -0,0 +1,77 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compression
+
+import (
+	"compress/flate"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if len(cfg.Encodings) != 2 || cfg.Encodings[0] != encodingGzip || cfg.Encodings[1] != encodingDeflate ||
+		cfg.Level != flate.DefaultCompression ||
+		cfg.MinContentLength != defaultMinContentLength ||
+		len(cfg.ContentTypes) != len(defaultContentTypes) {
+		t.Errorf("unexpected default config: %+v", cfg)
+	}
+
+	cfg, err = parseConfig(map[string]interface{}{
+		"encodings":          []string{"Deflate"},
+		"level":              9,
+		"min_content_length": 1024,
+		"content_types":      []string{"Application/JSON "},
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if len(cfg.Encodings) != 1 || cfg.Encodings[0] != encodingDeflate ||
+		cfg.Level != 9 ||
+		cfg.MinContentLength != 1024 ||
+		len(cfg.ContentTypes) != 1 || cfg.ContentTypes[0] != "application/json" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	for i, invalid := range []map[string]interface{}{
+		{"encodings": []string{"br"}},
+		{"level": 10},
+		{"min_content_length": "1024"},
+	} {
+		if _, err := parseConfig(invalid); err == nil {
+			t.Errorf("#%d invalid config should be failed", i)
+		}
+	}
+}
+
+func TestMatchContentType(t *testing.T) {
+	cfg, _ := parseConfig(map[string]interface{}{})
+	for contentType, expected := range map[string]bool{
+		"application/json":               true,
+		"application/JSON; charset=utf8": true,
+		"text/html;charset=utf-8":        true,
+		"image/png":                      false,
+		"":                               false,
+	} {
+		if cfg.matchContentType(contentType) != expected {
+			t.Errorf("content type %s should be matched: %v", contentType, expected)
+		}
+	}
+}
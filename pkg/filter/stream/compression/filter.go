@@ -0,0 +1,262 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compression
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	headerAcceptEncoding  = "Accept-Encoding"
+	headerContentEncoding = "Content-Encoding"
+	headerContentLength   = "Content-Length"
+	headerContentType     = "Content-Type"
+	headerCacheControl    = "Cache-Control"
+	headerVary            = "Vary"
+	headerETag            = "ETag"
+)
+
+// compressionFilter is an implement of types.StreamReceiverFilter/types.StreamSendFilter,
+// it compresses the response according to the Accept-Encoding of the request.
+type compressionFilter struct {
+	ctx context.Context
+	cfg *config
+
+	// encoding is the encoding accepted by the downstream, empty means no compression
+	encoding string
+	isHead   bool
+	// chunked is true if the downstream is http1.1, which supports the chunked encoding
+	chunked bool
+
+	receiveHandler api.StreamReceiverFilterHandler
+	sendHandler    api.StreamSenderFilterHandler
+}
+
+func newCompressionFilter(ctx context.Context, cfg *config) *compressionFilter {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter][compression] create compression filter with config: %v", cfg)
+	}
+	return &compressionFilter{
+		ctx: ctx,
+		cfg: cfg,
+	}
+}
+
+// readPerRouteConfig makes route-level configuration override filter-level configuration
+func (f *compressionFilter) readPerRouteConfig(ctx context.Context, cfg map[string]interface{}) {
+	if cfg == nil {
+		return
+	}
+	if compressionCfg, ok := cfg[v2.Compression]; ok {
+		config, err := parseConfig(compressionCfg)
+		if err != nil {
+			log.Proxy.Errorf(ctx, "[stream filter][compression] parse route config failed: %v", err)
+			return
+		}
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[stream filter][compression] use router config to replace stream filter config, config: %v", config)
+		}
+		f.cfg = config
+	}
+}
+
+func (f *compressionFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *compressionFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	acceptEncoding, ok := headers.Get(headerAcceptEncoding)
+	if !ok {
+		return api.StreamFilterContinue
+	}
+	if route := f.receiveHandler.Route(); route != nil && route.RouteRule() != nil {
+		f.readPerRouteConfig(ctx, route.RouteRule().PerFilterConfig())
+	}
+	if f.cfg.Disabled {
+		return api.StreamFilterContinue
+	}
+	if method, ok := headers.Get(protocol.MosnHeaderMethod); ok && strings.EqualFold(method, http.MethodHead) {
+		f.isHead = true
+	}
+	if h, ok := headers.(mosnhttp.RequestHeader); ok {
+		f.chunked = h.IsHTTP11()
+	}
+	f.encoding = negotiateEncoding(acceptEncoding, f.cfg.Encodings)
+	return api.StreamFilterContinue
+}
+
+func (f *compressionFilter) OnDestroy() {}
+
+// SetSenderFilterHandler sets the StreamSenderFilterHandler
+func (f *compressionFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+// Append compresses the response body, the streaming body is compressed while it is sent
+func (f *compressionFilter) Append(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	if f.encoding == "" || f.isHead || buf == nil || !f.shouldCompress(headers, buf) {
+		return api.StreamFilterContinue
+	}
+
+	if body, ok := buf.(types.StreamingBuffer); ok {
+		out, err := newCompressBody(body, f.encoding, f.cfg.Level)
+		if err != nil {
+			log.Proxy.Errorf(ctx, "[stream filter][compression] create compressor failed: %v", err)
+			return api.StreamFilterContinue
+		}
+		// the length of the compressed body is unknown, the http1 body is sent with the chunked
+		// encoding, or ends with the connection if the downstream does not support it.
+		headers.Del(headerContentLength)
+		if h, ok := headers.(mosnhttp.ResponseHeader); ok {
+			if f.chunked {
+				h.SetContentLength(-1)
+			} else {
+				h.SetContentLength(-2)
+			}
+		}
+		f.setEncodingHeaders(headers)
+		f.sendHandler.SetResponseData(out)
+	} else {
+		out, err := compressBuffer(buf.Bytes(), f.encoding, f.cfg.Level)
+		if err != nil {
+			log.Proxy.Errorf(ctx, "[stream filter][compression] compress response failed: %v", err)
+			return api.StreamFilterContinue
+		}
+		headers.Set(headerContentLength, strconv.Itoa(out.Len()))
+		f.setEncodingHeaders(headers)
+		f.sendHandler.SetResponseData(out)
+	}
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter][compression] compress response with %s", f.encoding)
+	}
+	return api.StreamFilterContinue
+}
+
+// shouldCompress checks the response, the response compressed by the upstream already,
+// or too small, or not in the configured content types is not compressed
+func (f *compressionFilter) shouldCompress(headers types.HeaderMap, buf types.IoBuffer) bool {
+	if status, ok := headers.Get(types.HeaderStatus); ok {
+		switch status {
+		case "204", "206", "304":
+			return false
+		}
+	}
+	if encoding, ok := headers.Get(headerContentEncoding); ok && encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+	if cacheControl, ok := headers.Get(headerCacheControl); ok && strings.Contains(strings.ToLower(cacheControl), "no-transform") {
+		return false
+	}
+	contentType, _ := headers.Get(headerContentType)
+	if !f.cfg.matchContentType(contentType) {
+		return false
+	}
+	if _, ok := buf.(types.StreamingBuffer); ok {
+		if length, ok := contentLength(headers); ok && length < f.cfg.MinContentLength {
+			return false
+		}
+		return true
+	}
+	return buf.Len() >= f.cfg.MinContentLength
+}
+
+func (f *compressionFilter) setEncodingHeaders(headers types.HeaderMap) {
+	headers.Set(headerContentEncoding, f.encoding)
+	addVary(headers, headerAcceptEncoding)
+	// the compressed body is not byte-for-byte equivalent to the original one
+	if etag, ok := headers.Get(headerETag); ok && strings.HasPrefix(etag, "\"") {
+		headers.Set(headerETag, "W/"+etag)
+	}
+}
+
+// contentLength returns the Content-Length of the response, false is returned if it is unknown
+func contentLength(headers types.HeaderMap) (int, bool) {
+	if h, ok := headers.(mosnhttp.ResponseHeader); ok {
+		length := h.ContentLength()
+		return length, length >= 0
+	}
+	value, ok := headers.Get(headerContentLength)
+	if !ok {
+		return 0, false
+	}
+	length, err := strconv.Atoi(value)
+	return length, err == nil
+}
+
+// addVary adds the value to the Vary header if it is not contained
+func addVary(headers types.HeaderMap, value string) {
+	vary, ok := headers.Get(headerVary)
+	if !ok || strings.TrimSpace(vary) == "" {
+		headers.Set(headerVary, value)
+		return
+	}
+	for _, v := range strings.Split(vary, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.EqualFold(v, value) {
+			return
+		}
+	}
+	headers.Set(headerVary, vary+", "+value)
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality value in the Accept-Encoding,
+// the encodings with the same quality value are chosen in the order of the supported ones.
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	qvalues := make(map[string]float64)
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qvalues[name] = q
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, encoding := range supported {
+		q, ok := qvalues[encoding]
+		if !ok {
+			q = qvalues["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,332 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compression
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{encodingGzip, encodingDeflate}
+	for acceptEncoding, expected := range map[string]string{
+		"gzip, deflate, br":       encodingGzip,
+		"deflate":                 encodingDeflate,
+		"GZIP":                    encodingGzip,
+		"gzip;q=0.5, deflate":     encodingDeflate,
+		"gzip;q=0, deflate;q=0.1": encodingDeflate,
+		"*":                       encodingGzip,
+		"*;q=0.5, gzip;q=0":       encodingDeflate,
+		"br, identity":            "",
+		"gzip;q=0":                "",
+		"":                        "",
+	} {
+		if encoding := negotiateEncoding(acceptEncoding, supported); encoding != expected {
+			t.Errorf("accept encoding %q expected %q, but got %q", acceptEncoding, expected, encoding)
+		}
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	for vary, expected := range map[string]string{
+		"":                "Accept-Encoding",
+		"Origin":          "Origin, Accept-Encoding",
+		"accept-encoding": "accept-encoding",
+		"Origin, *":       "Origin, *",
+	} {
+		headers := protocol.CommonHeader{}
+		if vary != "" {
+			headers.Set(headerVary, vary)
+		}
+		addVary(headers, headerAcceptEncoding)
+		if v, _ := headers.Get(headerVary); v != expected {
+			t.Errorf("vary %q expected %q, but got %q", vary, expected, v)
+		}
+	}
+}
+
+func decompress(t *testing.T, encoding string, data []byte) string {
+	var r io.Reader
+	if encoding == encodingGzip {
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("create gzip reader failed: %v", err)
+		}
+		r = gr
+	} else {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	return string(b)
+}
+
+func newTestFilter(t *testing.T, cfg map[string]interface{}, route map[string]interface{}, reqHeaders types.HeaderMap) (*compressionFilter, *mockStreamSenderFilterHandler) {
+	filterConfig, err := parseConfig(cfg)
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	f := newCompressionFilter(context.Background(), filterConfig)
+	receiveHandler := &mockStreamReceiverFilterHandler{}
+	if route != nil {
+		receiveHandler.route = &mockRoute{
+			rule: &mockRouteRule{
+				config: map[string]interface{}{
+					v2.Compression: route,
+				},
+			},
+		}
+	}
+	sendHandler := &mockStreamSenderFilterHandler{}
+	f.SetReceiveFilterHandler(receiveHandler)
+	f.SetSenderFilterHandler(sendHandler)
+	f.OnReceive(context.Background(), reqHeaders, nil, nil)
+	return f, sendHandler
+}
+
+func TestCompressBufferedResponse(t *testing.T) {
+	body := strings.Repeat(`{"key":"value"}`, 100)
+	for _, encoding := range []string{encodingGzip, encodingDeflate} {
+		f, sendHandler := newTestFilter(t, nil, nil, protocol.CommonHeader{
+			headerAcceptEncoding: encoding,
+		})
+		headers := protocol.CommonHeader{
+			types.HeaderStatus:  "200",
+			headerContentType:   "application/json",
+			headerContentLength: "1500",
+			headerVary:          "Origin",
+			headerETag:          `"abc"`,
+		}
+		f.Append(context.Background(), headers, buffer.NewIoBufferString(body), nil)
+
+		if sendHandler.data == nil {
+			t.Fatalf("%s: response is not compressed", encoding)
+		}
+		if decompress(t, encoding, sendHandler.data.Bytes()) != body {
+			t.Errorf("%s: unexpected decompressed body", encoding)
+		}
+		expected := map[string]string{
+			headerContentEncoding: encoding,
+			headerContentLength:   strconv.Itoa(sendHandler.data.Len()),
+			headerVary:            "Origin, Accept-Encoding",
+			headerETag:            `W/"abc"`,
+		}
+		for k, v := range expected {
+			if value, _ := headers.Get(k); value != v {
+				t.Errorf("%s: header %s expected %s, but got %s", encoding, k, v, value)
+			}
+		}
+	}
+}
+
+func TestSkipCompression(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	testCases := []struct {
+		name       string
+		cfg        map[string]interface{}
+		route      map[string]interface{}
+		reqHeaders protocol.CommonHeader
+		headers    protocol.CommonHeader
+		body       string
+	}{
+		{
+			name:       "no accept encoding",
+			reqHeaders: protocol.CommonHeader{},
+			headers:    protocol.CommonHeader{headerContentType: "text/plain"},
+			body:       body,
+		},
+		{
+			name:       "unsupported encoding",
+			reqHeaders: protocol.CommonHeader{headerAcceptEncoding: "br"},
+			headers:    protocol.CommonHeader{headerContentType: "text/plain"},
+			body:       body,
+		},
+		{
+			name:       "compressed by upstream",
+			reqHeaders: protocol.CommonHeader{headerAcceptEncoding: "gzip"},
+			headers:    protocol.CommonHeader{headerContentType: "text/plain", headerContentEncoding: "br"},
+			body:       body,
+		},
+		{
+			name:       "content type not matched",
+			reqHeaders: protocol.CommonHeader{headerAcceptEncoding: "gzip"},
+			headers:    protocol.CommonHeader{headerContentType: "image/png"},
+			body:       body,
+		},
+		{
+			name:       "too small",
+			cfg:        map[string]interface{}{"min_content_length": 1024},
+			reqHeaders: protocol.CommonHeader{headerAcceptEncoding: "gzip"},
+			headers:    protocol.CommonHeader{headerContentType: "text/plain"},
+			body:       body,
+		},
+		{
+			name:       "no transform",
+			reqHeaders: protocol.CommonHeader{headerAcceptEncoding: "gzip"},
+			headers:    protocol.CommonHeader{headerContentType: "text/plain", headerCacheControl: "no-cache, no-transform"},
+			body:       body,
+		},
+		{
+			name:       "not modified",
+			reqHeaders: protocol.CommonHeader{headerAcceptEncoding: "gzip"},
+			headers:    protocol.CommonHeader{headerContentType: "text/plain", types.HeaderStatus: "304"},
+			body:       body,
+		},
+		{
+			name:       "head request",
+			reqHeaders: protocol.CommonHeader{headerAcceptEncoding: "gzip", protocol.MosnHeaderMethod: "HEAD"},
+			headers:    protocol.CommonHeader{headerContentType: "text/plain"},
+			body:       body,
+		},
+		{
+			name:       "disabled by route",
+			route:      map[string]interface{}{"disabled": true},
+			reqHeaders: protocol.CommonHeader{headerAcceptEncoding: "gzip"},
+			headers:    protocol.CommonHeader{headerContentType: "text/plain"},
+			body:       body,
+		},
+	}
+	for _, tc := range testCases {
+		f, sendHandler := newTestFilter(t, tc.cfg, tc.route, tc.reqHeaders)
+		f.Append(context.Background(), tc.headers, buffer.NewIoBufferString(tc.body), nil)
+		if sendHandler.data != nil {
+			t.Errorf("%s: response should not be compressed", tc.name)
+		}
+		if _, ok := tc.headers.Get(headerVary); ok {
+			t.Errorf("%s: vary should not be added", tc.name)
+		}
+	}
+}
+
+func TestRouteConfig(t *testing.T) {
+	// the route prefers deflate, and compresses the small response
+	f, sendHandler := newTestFilter(t, nil, map[string]interface{}{
+		"encodings":          []string{"deflate", "gzip"},
+		"min_content_length": 1,
+	}, protocol.CommonHeader{
+		headerAcceptEncoding: "gzip, deflate",
+	})
+	headers := protocol.CommonHeader{headerContentType: "text/plain"}
+	f.Append(context.Background(), headers, buffer.NewIoBufferString("hello"), nil)
+	if sendHandler.data == nil {
+		t.Fatal("response is not compressed")
+	}
+	if encoding, _ := headers.Get(headerContentEncoding); encoding != encodingDeflate {
+		t.Errorf("expected deflate, but got %s", encoding)
+	}
+	if decompress(t, encodingDeflate, sendHandler.data.Bytes()) != "hello" {
+		t.Error("unexpected decompressed body")
+	}
+}
+
+func TestCompressStreamingResponse(t *testing.T) {
+	reqHeaders := mosnhttp.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	reqHeaders.Set(headerAcceptEncoding, "gzip")
+	f, sendHandler := newTestFilter(t, nil, nil, reqHeaders)
+	headers := mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	headers.SetContentType("application/json")
+	headers.SetContentLength(1024)
+	source := newMockStreamingBuffer()
+	f.Append(context.Background(), headers, source, nil)
+
+	body, ok := sendHandler.data.(types.StreamingBuffer)
+	if !ok {
+		t.Fatalf("the streaming response should be compressed in streaming mode, but got %T", sendHandler.data)
+	}
+	// the length is unknown, the http1.1 stream sends it with chunked encoding
+	if headers.ContentLength() != -1 {
+		t.Errorf("content length should be unknown, but got %d", headers.ContentLength())
+	}
+	if encoding, _ := headers.Get(headerContentEncoding); encoding != encodingGzip {
+		t.Errorf("expected gzip, but got %s", encoding)
+	}
+
+	// the compressed data can be read before the source is finished
+	first := make([]byte, 1024)
+	readResult := make(chan int, 1)
+	go func() {
+		n, _ := body.Read(first)
+		readResult <- n
+	}()
+	source.writer.Write([]byte(`{"first":"part"}`))
+	select {
+	case n := <-readResult:
+		if n == 0 {
+			t.Fatal("read compressed data failed")
+		}
+		first = first[:n]
+	case <-time.After(2 * time.Second):
+		t.Fatal("the compressed data should be flushed before the end of the body")
+	}
+
+	go func() {
+		source.writer.Write([]byte(`{"second":"part"}`))
+		source.writer.Close()
+	}()
+	// reading the whole body waits for the end of the source
+	compressed := append(first, body.Bytes()...)
+	if decompress(t, encodingGzip, compressed) != `{"first":"part"}{"second":"part"}` {
+		t.Error("unexpected decompressed body")
+	}
+
+	body.CloseRead()
+	select {
+	case <-source.readClosed:
+	default:
+		t.Error("close read should be passed to the source")
+	}
+}
+
+func TestCompressStreamingRead(t *testing.T) {
+	data := strings.Repeat("streaming body ", 10000)
+	source := newMockStreamingBuffer()
+	body, err := newCompressBody(source, encodingDeflate, flate.DefaultCompression)
+	if err != nil {
+		t.Fatalf("create compress body failed: %v", err)
+	}
+	go func() {
+		for i := 0; i < len(data); i += 1000 {
+			source.writer.Write([]byte(data[i : i+1000]))
+		}
+		source.writer.Close()
+	}()
+	compressed, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read compressed body failed: %v", err)
+	}
+	if decompress(t, encodingDeflate, compressed) != data {
+		t.Error("unexpected decompressed body")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compression
+
+import (
+	"io"
+	"sync"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockStreamReceiverFilterHandler struct {
+	api.StreamReceiverFilterHandler
+	route *mockRoute
+}
+
+func (h *mockStreamReceiverFilterHandler) Route() api.Route {
+	if h.route == nil {
+		return nil
+	}
+	return h.route
+}
+
+type mockStreamSenderFilterHandler struct {
+	api.StreamSenderFilterHandler
+	data types.IoBuffer
+}
+
+func (h *mockStreamSenderFilterHandler) SetResponseData(data types.IoBuffer) {
+	h.data = data
+}
+
+type mockRoute struct {
+	api.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() api.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	api.RouteRule
+	config map[string]interface{}
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+// mockStreamingBuffer is a streaming body written by the test
+type mockStreamingBuffer struct {
+	buffer.IoBuffer
+	reader     *io.PipeReader
+	writer     *io.PipeWriter
+	closeOnce  sync.Once
+	readClosed chan struct{}
+}
+
+func newMockStreamingBuffer() *mockStreamingBuffer {
+	r, w := io.Pipe()
+	return &mockStreamingBuffer{
+		IoBuffer:   buffer.NewIoBuffer(0),
+		reader:     r,
+		writer:     w,
+		readClosed: make(chan struct{}),
+	}
+}
+
+func (b *mockStreamingBuffer) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *mockStreamingBuffer) CloseRead() {
+	b.closeOnce.Do(func() {
+		b.reader.Close()
+		close(b.readClosed)
+	})
+}
+
+func (b *mockStreamingBuffer) SetActiveListener(listener func()) {
+}
@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubboinvocation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type attachmentConfig struct {
+	// Name is the attachment key, such as dubbo.tag
+	Name string `json:"name"`
+	// Header is the header that the attachment is copied to, Name by default
+	Header string `json:"header,omitempty"`
+}
+
+type argumentConfig struct {
+	// Index is the position of the argument, starts from 0
+	Index int `json:"index"`
+	// Field is the dot separated path of the value in an object argument, the argument itself is used if it is empty.
+	// Only the objects whose class is not registered in hessian are decoded as maps and can be looked up.
+	Field string `json:"field,omitempty"`
+	// Header is the header that the value is copied to
+	Header string `json:"header"`
+}
+
+type config struct {
+	// Attachments are the request attachments copied to the headers
+	Attachments []attachmentConfig `json:"attachments,omitempty"`
+	// Arguments are the argument values copied to the headers, only the scalar values are copied.
+	// The arguments after the max index are not decoded if no attachment is configured.
+	Arguments []argumentConfig `json:"arguments,omitempty"`
+	// maxArguments is the number of arguments to decode
+	maxArguments int
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if len(filterConfig.Attachments) == 0 && len(filterConfig.Arguments) == 0 {
+		return nil, errors.New("no attachment or argument is configured")
+	}
+	for i := range filterConfig.Attachments {
+		attachment := &filterConfig.Attachments[i]
+		if attachment.Name == "" {
+			return nil, fmt.Errorf("attachment #%d has no name", i)
+		}
+		if attachment.Header == "" {
+			attachment.Header = attachment.Name
+		}
+	}
+	for i, argument := range filterConfig.Arguments {
+		if argument.Index < 0 || argument.Header == "" {
+			return nil, fmt.Errorf("argument #%d needs a valid index and header", i)
+		}
+		if argument.Index >= filterConfig.maxArguments {
+			filterConfig.maxArguments = argument.Index + 1
+		}
+	}
+	return filterConfig, nil
+}
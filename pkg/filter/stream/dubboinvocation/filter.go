@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubboinvocation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/pkg/buffer"
+)
+
+// invocationFilter decodes the dubbo request body partially, and copies the
+// attachments and the argument values to the headers, so the routes can match them.
+type invocationFilter struct {
+	cfg     *config
+	handler api.StreamReceiverFilterHandler
+}
+
+func newInvocationFilter(cfg *config) *invocationFilter {
+	return &invocationFilter{
+		cfg: cfg,
+	}
+}
+
+func (f *invocationFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *invocationFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	frame, ok := headers.(*dubbo.Frame)
+	if !ok || frame.IsHeartbeatFrame() {
+		return api.StreamFilterContinue
+	}
+	inv, err := frame.DecodeInvocation(f.cfg.maxArguments, len(f.cfg.Attachments) > 0)
+	if err != nil {
+		log.DefaultLogger.Debugf("[stream filter][dubbo_invocation] decode invocation failed: %v", err)
+		return api.StreamFilterContinue
+	}
+	for _, attachment := range f.cfg.Attachments {
+		if value, ok := inv.Attachments[attachment.Name]; ok {
+			headers.Set(attachment.Header, value)
+		}
+	}
+	for _, argument := range f.cfg.Arguments {
+		if argument.Index >= len(inv.Arguments) {
+			continue
+		}
+		if value, ok := formatValue(lookup(inv.Arguments[argument.Index], argument.Field)); ok {
+			headers.Set(argument.Header, value)
+		}
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *invocationFilter) OnDestroy() {}
+
+// lookup returns the value of the dot separated field path in the decoded object
+func lookup(value interface{}, field string) interface{} {
+	if field == "" {
+		return value
+	}
+	for _, key := range strings.Split(field, ".") {
+		object, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// formatValue formats the scalar values, the objects and lists are not supported
+func formatValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool, int, int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,137 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubboinvocation
+
+import (
+	"context"
+	"testing"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"attachments": []interface{}{
+			map[string]interface{}{"name": "dubbo.tag"},
+			map[string]interface{}{"name": "zone", "header": "x-zone"},
+		},
+		"arguments": []interface{}{
+			map[string]interface{}{"index": 2, "header": "x-arg-id"},
+			map[string]interface{}{"index": 0, "field": "user.level", "header": "x-arg-level"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Attachments[0].Header != "dubbo.tag" || cfg.Attachments[1].Header != "x-zone" || cfg.maxArguments != 3 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	for _, invalid := range []map[string]interface{}{
+		{},
+		{"attachments": []interface{}{map[string]interface{}{"header": "x-zone"}}},
+		{"arguments": []interface{}{map[string]interface{}{"index": 0}}},
+		{"arguments": []interface{}{map[string]interface{}{"index": -1, "header": "x-arg"}}},
+	} {
+		if _, err := parseConfig(invalid); err == nil {
+			t.Errorf("expected error for %v", invalid)
+		}
+	}
+}
+
+func buildRequest(t *testing.T) *dubbo.Frame {
+	frame, err := dubbo.NewGenericRequest(&dubbo.GenericRequest{
+		Interface:      "com.foo.UserService",
+		Method:         "query",
+		ParameterTypes: []string{"com.foo.Query", "java.lang.String", "long", "java.util.List"},
+		Arguments: []interface{}{
+			map[string]interface{}{"user": map[string]interface{}{"level": "gold"}},
+			"bob",
+			float64(42),
+			[]interface{}{"a"},
+		},
+		Attachments: map[string]string{"dubbo.tag": "gray"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func TestInvocationFilter(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"attachments": []interface{}{
+			map[string]interface{}{"name": "dubbo.tag"},
+			map[string]interface{}{"name": "zone", "header": "x-zone"},
+		},
+		"arguments": []interface{}{
+			map[string]interface{}{"index": 0, "field": "user.level", "header": "x-arg-level"},
+			map[string]interface{}{"index": 0, "field": "user.missing", "header": "x-arg-missing"},
+			map[string]interface{}{"index": 1, "header": "x-arg-name"},
+			map[string]interface{}{"index": 2, "header": "x-arg-id"},
+			map[string]interface{}{"index": 3, "header": "x-arg-list"},
+			map[string]interface{}{"index": 9, "header": "x-arg-none"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := buildRequest(t)
+	f := newInvocationFilter(cfg)
+	if status := f.OnReceive(context.Background(), frame, frame.GetData(), nil); status != api.StreamFilterContinue {
+		t.Fatalf("unexpected status: %v", status)
+	}
+	for k, v := range map[string]string{
+		"dubbo.tag":   "gray",
+		"x-arg-level": "gold",
+		"x-arg-name":  "bob",
+		"x-arg-id":    "42",
+	} {
+		if value, ok := frame.Get(k); !ok || value != v {
+			t.Errorf("header %s: expected %s, got %s", k, v, value)
+		}
+	}
+	for _, k := range []string{"x-zone", "x-arg-missing", "x-arg-list", "x-arg-none"} {
+		if value, ok := frame.Get(k); ok {
+			t.Errorf("unexpected header %s: %s", k, value)
+		}
+	}
+}
+
+func TestInvocationFilterArgumentsOnly(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"arguments": []interface{}{
+			map[string]interface{}{"index": 1, "header": "x-arg-name"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := buildRequest(t)
+	f := newInvocationFilter(cfg)
+	f.OnReceive(context.Background(), frame, frame.GetData(), nil)
+	if value, _ := frame.Get("x-arg-name"); value != "bob" {
+		t.Fatalf("unexpected header: %s", value)
+	}
+	// other protocols are ignored
+	headers := protocol.CommonHeader{}
+	if status := f.OnReceive(context.Background(), headers, nil, nil); status != api.StreamFilterContinue || len(headers) != 0 {
+		t.Fatalf("unexpected status: %v, %v", status, headers)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// The messages are the subset of envoy.service.auth.v2 used by the filter, the field numbers are kept
+// so that they are compatible with the grpc services implement envoy.service.auth.v2.Authorization.
+// The oneof http_response in CheckResponse is declared as optional fields, which has the same encoding.
+
+const checkMethod = "/envoy.service.auth.v2.Authorization/Check"
+
+type authCheckRequest struct {
+	Attributes *attributeContext `protobuf:"bytes,1,opt,name=attributes,proto3" json:"attributes,omitempty"`
+}
+
+func (m *authCheckRequest) Reset()         { *m = authCheckRequest{} }
+func (m *authCheckRequest) String() string { return proto.CompactTextString(m) }
+func (*authCheckRequest) ProtoMessage()    {}
+
+type attributeContext struct {
+	Source            *attributeContextPeer    `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Destination       *attributeContextPeer    `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	Request           *attributeContextRequest `protobuf:"bytes,4,opt,name=request,proto3" json:"request,omitempty"`
+	ContextExtensions map[string]string        `protobuf:"bytes,10,rep,name=context_extensions,json=contextExtensions,proto3" json:"context_extensions,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *attributeContext) Reset()         { *m = attributeContext{} }
+func (m *attributeContext) String() string { return proto.CompactTextString(m) }
+func (*attributeContext) ProtoMessage()    {}
+
+type attributeContextPeer struct {
+	Address   *address `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Principal string   `protobuf:"bytes,4,opt,name=principal,proto3" json:"principal,omitempty"`
+}
+
+func (m *attributeContextPeer) Reset()         { *m = attributeContextPeer{} }
+func (m *attributeContextPeer) String() string { return proto.CompactTextString(m) }
+func (*attributeContextPeer) ProtoMessage()    {}
+
+// address is envoy.api.v2.core.Address
+type address struct {
+	SocketAddress *socketAddress `protobuf:"bytes,1,opt,name=socket_address,json=socketAddress,proto3" json:"socket_address,omitempty"`
+}
+
+func (m *address) Reset()         { *m = address{} }
+func (m *address) String() string { return proto.CompactTextString(m) }
+func (*address) ProtoMessage()    {}
+
+type socketAddress struct {
+	Address   string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	PortValue uint32 `protobuf:"varint,3,opt,name=port_value,json=portValue,proto3" json:"port_value,omitempty"`
+}
+
+func (m *socketAddress) Reset()         { *m = socketAddress{} }
+func (m *socketAddress) String() string { return proto.CompactTextString(m) }
+func (*socketAddress) ProtoMessage()    {}
+
+type attributeContextRequest struct {
+	HTTP *attributeContextHTTPRequest `protobuf:"bytes,2,opt,name=http,proto3" json:"http,omitempty"`
+}
+
+func (m *attributeContextRequest) Reset()         { *m = attributeContextRequest{} }
+func (m *attributeContextRequest) String() string { return proto.CompactTextString(m) }
+func (*attributeContextRequest) ProtoMessage()    {}
+
+type attributeContextHTTPRequest struct {
+	Method   string            `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`
+	Headers  map[string]string `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Path     string            `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`
+	Host     string            `protobuf:"bytes,5,opt,name=host,proto3" json:"host,omitempty"`
+	Query    string            `protobuf:"bytes,7,opt,name=query,proto3" json:"query,omitempty"`
+	Size     int64             `protobuf:"varint,9,opt,name=size,proto3" json:"size,omitempty"`
+	Protocol string            `protobuf:"bytes,10,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Body     string            `protobuf:"bytes,11,opt,name=body,proto3" json:"body,omitempty"`
+}
+
+func (m *attributeContextHTTPRequest) Reset()         { *m = attributeContextHTTPRequest{} }
+func (m *attributeContextHTTPRequest) String() string { return proto.CompactTextString(m) }
+func (*attributeContextHTTPRequest) ProtoMessage()    {}
+
+type authCheckResponse struct {
+	Status         *rpcStatus          `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	DeniedResponse *deniedHTTPResponse `protobuf:"bytes,2,opt,name=denied_response,json=deniedResponse,proto3" json:"denied_response,omitempty"`
+	OkResponse     *okHTTPResponse     `protobuf:"bytes,3,opt,name=ok_response,json=okResponse,proto3" json:"ok_response,omitempty"`
+}
+
+func (m *authCheckResponse) Reset()         { *m = authCheckResponse{} }
+func (m *authCheckResponse) String() string { return proto.CompactTextString(m) }
+func (*authCheckResponse) ProtoMessage()    {}
+
+// rpcStatus is google.rpc.Status
+type rpcStatus struct {
+	Code    int32  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (m *rpcStatus) Reset()         { *m = rpcStatus{} }
+func (m *rpcStatus) String() string { return proto.CompactTextString(m) }
+func (*rpcStatus) ProtoMessage()    {}
+
+type deniedHTTPResponse struct {
+	Status  *httpStatus          `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Headers []*headerValueOption `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
+	Body    string               `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+}
+
+func (m *deniedHTTPResponse) Reset()         { *m = deniedHTTPResponse{} }
+func (m *deniedHTTPResponse) String() string { return proto.CompactTextString(m) }
+func (*deniedHTTPResponse) ProtoMessage()    {}
+
+// httpStatus is envoy.type.HttpStatus
+type httpStatus struct {
+	Code int32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+}
+
+func (m *httpStatus) Reset()         { *m = httpStatus{} }
+func (m *httpStatus) String() string { return proto.CompactTextString(m) }
+func (*httpStatus) ProtoMessage()    {}
+
+type okHTTPResponse struct {
+	Headers []*headerValueOption `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
+}
+
+func (m *okHTTPResponse) Reset()         { *m = okHTTPResponse{} }
+func (m *okHTTPResponse) String() string { return proto.CompactTextString(m) }
+func (*okHTTPResponse) ProtoMessage()    {}
+
+// headerValueOption is envoy.api.v2.core.HeaderValueOption, the append is ignored
+type headerValueOption struct {
+	Header *headerValue `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+}
+
+func (m *headerValueOption) Reset()         { *m = headerValueOption{} }
+func (m *headerValueOption) String() string { return proto.CompactTextString(m) }
+func (*headerValueOption) ProtoMessage()    {}
+
+type headerValue struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *headerValue) Reset()         { *m = headerValue{} }
+func (m *headerValue) String() string { return proto.CompactTextString(m) }
+func (*headerValue) ProtoMessage()    {}
@@ -0,0 +1,131 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"strings"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// checkRequest is the attributes of the request sent to the authorization service
+type checkRequest struct {
+	method   string
+	host     string
+	path     string
+	query    string
+	protocol string
+	// headers are the allowed headers of the request, the keys are in lower case
+	headers map[string]string
+	body    []byte
+	size    int64
+
+	sourceAddress      net.Addr
+	sourcePrincipal    string
+	destinationAddress net.Addr
+
+	contextExtensions map[string]string
+}
+
+// checkResponse is the result of the authorization
+type checkResponse struct {
+	allowed bool
+	// status is the status code of the denied response
+	status int
+	// headers are added to the request if it is allowed, or sent to the client if it is denied
+	headers []header
+	body    string
+}
+
+type header struct {
+	key   string
+	value string
+}
+
+// authzClient sends the check request to the authorization service
+type authzClient interface {
+	Check(ctx context.Context, req *checkRequest) (*checkResponse, error)
+}
+
+func newCheckRequest(cfg *config, conn api.Connection, headers types.HeaderMap, body []byte, size int64) *checkRequest {
+	req := &checkRequest{
+		headers: make(map[string]string),
+		body:    body,
+		size:    size,
+	}
+	req.method, _ = headers.Get(protocol.MosnHeaderMethod)
+	req.host, _ = headers.Get(protocol.MosnHeaderHostKey)
+	req.path, _ = headers.Get(protocol.MosnHeaderPathKey)
+	req.query, _ = headers.Get(protocol.MosnHeaderQueryStringKey)
+	headers.Range(func(key, value string) bool {
+		key = strings.ToLower(key)
+		// the internal headers are not sent
+		if strings.HasPrefix(key, "x-mosn-") {
+			return true
+		}
+		if len(cfg.AllowedHeaders) == 0 || containsHeader(cfg.AllowedHeaders, key) {
+			req.headers[key] = value
+		}
+		return true
+	})
+	if conn != nil {
+		req.sourceAddress = conn.RemoteAddr()
+		req.destinationAddress = conn.LocalAddr()
+		req.sourcePrincipal = peerPrincipal(conn)
+	}
+	return req
+}
+
+// peerPrincipal returns the identity of the client certificate, the URI SAN is used if it exists,
+// such as the SPIFFE ID, otherwise the subject is used.
+func peerPrincipal(conn api.Connection) string {
+	tlsConn, ok := conn.RawConn().(interface {
+		ConnectionState() tls.ConnectionState
+	})
+	if !ok {
+		return ""
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	return certPrincipal(state.PeerCertificates[0])
+}
+
+func certPrincipal(cert *x509.Certificate) string {
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return cert.Subject.String()
+}
+
+// requestURI returns the escaped path with the query string, the path in the headers is decoded by the stream layer
+func (r *checkRequest) requestURI() string {
+	u := url.URL{
+		Path:     r.path,
+		RawQuery: r.query,
+	}
+	return u.RequestURI()
+}
@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"mosn.io/api"
+)
+
+const (
+	protocolHTTP = "http"
+	protocolGRPC = "grpc"
+
+	defaultTimeout = 200 * time.Millisecond
+)
+
+type config struct {
+	// Cluster is the cluster of the authorization service
+	Cluster string `json:"cluster"`
+	// Protocol is the protocol of the authorization service, http or grpc, http by default.
+	// The grpc service is compatible with the envoy.service.auth.v2.Authorization
+	Protocol string `json:"protocol,omitempty"`
+	// PathPrefix is prepended to the path of the original request in the http authorization request
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// Timeout is the timeout of the authorization request, 200ms by default
+	Timeout api.DurationConfig `json:"timeout,omitempty"`
+	// FailureModeAllow passes the request if the authorization service fails or times out
+	FailureModeAllow bool `json:"failure_mode_allow,omitempty"`
+	// StatusOnError is the status code of the response if the authorization service fails, 403 by default
+	StatusOnError int `json:"status_on_error,omitempty"`
+	// AllowedHeaders is the request headers sent to the authorization service, all headers are sent if it is empty
+	AllowedHeaders []string `json:"allowed_headers,omitempty"`
+	// AllowedUpstreamHeaders is the headers of the ok response added to the request, no header is added if it is empty
+	AllowedUpstreamHeaders []string `json:"allowed_upstream_headers,omitempty"`
+	// AllowedClientHeaders is the headers of the denied response sent to the client, all headers are sent if it is empty
+	AllowedClientHeaders []string `json:"allowed_client_headers,omitempty"`
+	// WithRequestBody sends the prefix of the request body to the authorization service
+	WithRequestBody *bodySettings `json:"with_request_body,omitempty"`
+}
+
+type bodySettings struct {
+	MaxRequestBytes uint32 `json:"max_request_bytes"`
+	// AllowPartialMessage sends the prefix of the body if it is larger than MaxRequestBytes,
+	// otherwise the request is rejected with 413
+	AllowPartialMessage bool `json:"allow_partial_message,omitempty"`
+}
+
+// routeConfig is the route's per filter config
+type routeConfig struct {
+	// Disabled skips the authorization of the route
+	Disabled bool `json:"disabled,omitempty"`
+	// ContextExtensions is sent to the grpc authorization service with the request
+	ContextExtensions map[string]string `json:"context_extensions,omitempty"`
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.Cluster == "" {
+		return nil, errors.New("cluster of authorization service is required")
+	}
+	switch filterConfig.Protocol {
+	case "":
+		filterConfig.Protocol = protocolHTTP
+	case protocolHTTP, protocolGRPC:
+	default:
+		return nil, fmt.Errorf("unsupported protocol: %s", filterConfig.Protocol)
+	}
+	if filterConfig.Timeout.Duration <= 0 {
+		filterConfig.Timeout.Duration = defaultTimeout
+	}
+	if filterConfig.StatusOnError == 0 {
+		filterConfig.StatusOnError = http.StatusForbidden
+	}
+	if filterConfig.WithRequestBody != nil && filterConfig.WithRequestBody.MaxRequestBytes == 0 {
+		return nil, errors.New("max_request_bytes of with_request_body should be greater than 0")
+	}
+	// the headers are matched case-insensitively
+	for _, headers := range [][]string{
+		filterConfig.AllowedHeaders,
+		filterConfig.AllowedUpstreamHeaders,
+		filterConfig.AllowedClientHeaders,
+	} {
+		for i := range headers {
+			headers[i] = strings.ToLower(headers[i])
+		}
+	}
+	return filterConfig, nil
+}
+
+func parseRouteConfig(cfg interface{}) (*routeConfig, error) {
+	routeCfg := &routeConfig{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, routeCfg); err != nil {
+		return nil, err
+	}
+	return routeCfg, nil
+}
+
+func containsHeader(allowed []string, key string) bool {
+	key = strings.ToLower(key)
+	for _, h := range allowed {
+		if h == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"context"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+func init() {
+	api.RegisterStream(v2.ExtAuthz, createFilterChainFactory)
+}
+
+type filterChainFactory struct {
+	cfg *config
+	// client is shared by the filters, so the connections are reused
+	client authzClient
+	stats  *extAuthzStats
+}
+
+func (f *filterChainFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := newExtAuthzFilter(context, f.cfg, f.client, f.stats)
+	callbacks.AddStreamReceiverFilter(filter, api.AfterRoute)
+}
+
+func createFilterChainFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	var client authzClient
+	if cfg.Protocol == protocolGRPC {
+		client = newGRPCClient(cfg)
+	} else {
+		client = newHTTPClient(cfg)
+	}
+	return &filterChainFactory{
+		cfg:    cfg,
+		client: client,
+		stats:  newExtAuthzStats(cfg.Cluster),
+	}, nil
+}
@@ -0,0 +1,230 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+)
+
+// extAuthzFilter is an implement of types.StreamReceiverFilter, it calls the authorization service
+// for each request, and the request is allowed, denied or modified by the result.
+// The filter chain is paused while waiting for the result, so the proxy goroutine is not blocked.
+type extAuthzFilter struct {
+	ctx    context.Context
+	cfg    *config
+	client authzClient
+	stats  *extAuthzStats
+
+	handler api.StreamReceiverFilterHandler
+
+	// mux protects the stream from the result received after the filter is destroyed
+	mux       sync.Mutex
+	cancel    context.CancelFunc
+	destroyed bool
+}
+
+func newExtAuthzFilter(ctx context.Context, cfg *config, client authzClient, stats *extAuthzStats) *extAuthzFilter {
+	return &extAuthzFilter{
+		ctx:    ctx,
+		cfg:    cfg,
+		client: client,
+		stats:  stats,
+	}
+}
+
+// readPerRouteConfig returns the route's config, nil is returned if the route has none
+func (f *extAuthzFilter) readPerRouteConfig(ctx context.Context, cfg map[string]interface{}) *routeConfig {
+	routeCfg, ok := cfg[v2.ExtAuthz]
+	if !ok {
+		return nil
+	}
+	c, err := parseRouteConfig(routeCfg)
+	if err != nil {
+		// the request is still checked
+		log.Proxy.Errorf(ctx, "[stream filter][ext_authz] invalid route config: %v", err)
+		return nil
+	}
+	return c
+}
+
+func (f *extAuthzFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *extAuthzFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	var routeCfg *routeConfig
+	if route := f.handler.Route(); route != nil && route.RouteRule() != nil {
+		routeCfg = f.readPerRouteConfig(ctx, route.RouteRule().PerFilterConfig())
+	}
+	if routeCfg != nil && routeCfg.Disabled {
+		f.stats.disabled.Inc(1)
+		return api.StreamFilterContinue
+	}
+
+	body, size, ok := f.requestBody(buf)
+	if !ok {
+		log.Proxy.Warnf(ctx, "[stream filter][ext_authz] request body is larger than %d bytes", f.cfg.WithRequestBody.MaxRequestBytes)
+		f.handler.SendHijackReply(http.StatusRequestEntityTooLarge, headers)
+		return api.StreamFilterStop
+	}
+	req := newCheckRequest(f.cfg, f.handler.Connection(), headers, body, size)
+	req.protocol = string(f.handler.RequestInfo().Protocol())
+	if routeCfg != nil {
+		req.contextExtensions = routeCfg.ContextExtensions
+	}
+
+	checkCtx, cancel := context.WithTimeout(context.Background(), f.cfg.Timeout.Duration)
+	resumable, ok := f.handler.(types.ResumableStreamReceiverFilterHandler)
+	if !ok {
+		// the filter chain cannot be paused, waits for the result in the proxy goroutine
+		resp, err := f.check(checkCtx, req)
+		cancel()
+		if f.onResult(ctx, headers, resp, err) {
+			return api.StreamFilterContinue
+		}
+		return api.StreamFilterStop
+	}
+
+	f.mux.Lock()
+	f.cancel = cancel
+	f.mux.Unlock()
+	utils.GoWithRecover(func() {
+		resp, err := f.check(checkCtx, req)
+		cancel()
+
+		f.mux.Lock()
+		defer f.mux.Unlock()
+		if f.destroyed {
+			return
+		}
+		f.onResult(ctx, headers, resp, err)
+		resumable.ContinueReceiving()
+	}, func(r interface{}) {
+		// the stream should not be hung up
+		f.mux.Lock()
+		defer f.mux.Unlock()
+		if !f.destroyed {
+			f.handler.SendHijackReply(f.cfg.StatusOnError, headers)
+			resumable.ContinueReceiving()
+		}
+	})
+	return types.StreamFilterPause
+}
+
+// requestBody returns the prefix of the body sent to the authorization service, and the size of the body.
+// false is returned if the body is too large and the partial message is not allowed.
+func (f *extAuthzFilter) requestBody(buf types.IoBuffer) ([]byte, int64, bool) {
+	if buf == nil {
+		return nil, 0, true
+	}
+	// the streaming body is not received yet
+	if _, ok := buf.(types.StreamingBuffer); ok {
+		return nil, -1, true
+	}
+	size := int64(buf.Len())
+	settings := f.cfg.WithRequestBody
+	if settings == nil {
+		return nil, size, true
+	}
+	body := buf.Bytes()
+	if len(body) > int(settings.MaxRequestBytes) {
+		if !settings.AllowPartialMessage {
+			return nil, size, false
+		}
+		body = body[:settings.MaxRequestBytes]
+	}
+	return body, size, true
+}
+
+func (f *extAuthzFilter) check(ctx context.Context, req *checkRequest) (*checkResponse, error) {
+	start := time.Now()
+	resp, err := f.client.Check(ctx, req)
+	f.stats.latency.Update(time.Since(start).Nanoseconds() / int64(time.Millisecond))
+	return resp, err
+}
+
+// onResult applies the result to the request, true is returned if the request is allowed
+func (f *extAuthzFilter) onResult(ctx context.Context, headers types.HeaderMap, resp *checkResponse, err error) bool {
+	if err != nil {
+		f.stats.err.Inc(1)
+		if f.cfg.FailureModeAllow {
+			log.Proxy.Warnf(ctx, "[stream filter][ext_authz] authorization failed, the request is allowed: %v", err)
+			f.stats.failureModeAllowed.Inc(1)
+			return true
+		}
+		log.Proxy.Errorf(ctx, "[stream filter][ext_authz] authorization failed: %v", err)
+		f.handler.RequestInfo().SetResponseFlag(types.UnauthorizedFlag)
+		f.handler.SendHijackReply(f.cfg.StatusOnError, headers)
+		return false
+	}
+
+	if resp.allowed {
+		f.stats.ok.Inc(1)
+		for _, h := range resp.headers {
+			if containsHeader(f.cfg.AllowedUpstreamHeaders, h.key) {
+				headers.Set(h.key, h.value)
+			}
+		}
+		return true
+	}
+
+	f.stats.denied.Inc(1)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter][ext_authz] request is denied with status %d", resp.status)
+	}
+	f.handler.RequestInfo().SetResponseFlag(types.UnauthorizedFlag)
+	for _, h := range resp.headers {
+		if h.key == "host" {
+			continue
+		}
+		if len(f.cfg.AllowedClientHeaders) == 0 || containsHeader(f.cfg.AllowedClientHeaders, h.key) {
+			headers.Set(h.key, h.value)
+		}
+	}
+	// the body is only sent to the http clients, see sendHijackReplyWithBody in proxy
+	prot := f.handler.RequestInfo().Protocol()
+	if resp.body != "" && (prot == protocol.HTTP1 || prot == protocol.HTTP2) {
+		f.handler.RequestInfo().SetResponseCode(resp.status)
+		headers.Set(types.HeaderStatus, strconv.Itoa(resp.status))
+		f.handler.SendDirectResponse(headers, buffer.NewIoBufferString(resp.body), nil)
+		return false
+	}
+	f.handler.SendHijackReply(resp.status, headers)
+	return false
+}
+
+func (f *extAuthzFilter) OnDestroy() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.destroyed = true
+	if f.cancel != nil {
+		f.cancel()
+	}
+}
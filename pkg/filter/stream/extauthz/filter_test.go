@@ -0,0 +1,212 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"cluster":           "authz",
+		"allowed_headers":   []string{"Authorization"},
+		"with_request_body": map[string]interface{}{"max_request_bytes": 10},
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if cfg.Protocol != protocolHTTP || cfg.Timeout.Duration != defaultTimeout ||
+		cfg.StatusOnError != http.StatusForbidden || cfg.AllowedHeaders[0] != "authorization" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	for i, invalid := range []map[string]interface{}{
+		{},
+		{"cluster": "authz", "protocol": "dubbo"},
+		{"cluster": "authz", "with_request_body": map[string]interface{}{}},
+	} {
+		if _, err := parseConfig(invalid); err == nil {
+			t.Errorf("#%d invalid config should be failed", i)
+		}
+	}
+}
+
+func newTestFilter(t *testing.T, conf map[string]interface{}) *extAuthzFilter {
+	factory, err := createFilterChainFactory(conf)
+	if err != nil {
+		t.Fatalf("create filter factory failed: %v", err)
+	}
+	f := factory.(*filterChainFactory)
+	return newExtAuthzFilter(context.Background(), f.cfg, f.client, f.stats)
+}
+
+func TestHTTPAuthz(t *testing.T) {
+	server := newHTTPAuthzServer(t, "http_authz")
+	defer server.Close()
+
+	f := newTestFilter(t, map[string]interface{}{
+		"cluster":                  "http_authz",
+		"path_prefix":              "/check",
+		"timeout":                  "200ms",
+		"allowed_upstream_headers": []string{"x-user"},
+		"allowed_client_headers":   []string{"x-reason"},
+	})
+
+	// the allowed request is continued with the headers added
+	handler := newMockHandler()
+	f.SetReceiveFilterHandler(handler)
+	headers := protocol.CommonHeader{
+		"x-allowed":                       "true",
+		protocol.MosnHeaderPathKey:        "/api",
+		protocol.MosnHeaderMethod:         http.MethodGet,
+		protocol.MosnHeaderHostKey:        "example.com",
+		protocol.MosnHeaderQueryStringKey: "a=b",
+	}
+	if status := f.OnReceive(context.Background(), headers, nil, nil); status != types.StreamFilterPause {
+		t.Fatalf("the filter chain should be paused, but got %s", status)
+	}
+	handler.wait(t)
+	if handler.hijackCode != 0 {
+		t.Errorf("the request should be allowed, but hijacked with %d", handler.hijackCode)
+	}
+	if v, _ := headers.Get("x-user"); v != "mosn" {
+		t.Errorf("the allowed upstream header is not added: %s", v)
+	}
+	if _, ok := headers.Get("x-not-allowed"); ok {
+		t.Error("the header not allowed should not be added")
+	}
+
+	// the denied response is sent to the client
+	handler = newMockHandler()
+	f.SetReceiveFilterHandler(handler)
+	headers = protocol.CommonHeader{
+		protocol.MosnHeaderPathKey: "/api",
+	}
+	f.OnReceive(context.Background(), headers, nil, nil)
+	handler.wait(t)
+	if handler.hijackCode != http.StatusUnauthorized || handler.directBody != "denied by /check/api" ||
+		handler.info.flag != types.UnauthorizedFlag {
+		t.Errorf("the request should be denied, code: %d, body: %s", handler.hijackCode, handler.directBody)
+	}
+	if v, _ := headers.Get("x-reason"); v != "denied" {
+		t.Errorf("the allowed client header is not sent: %s", v)
+	}
+}
+
+func TestAuthzFailureMode(t *testing.T) {
+	server := newHTTPAuthzServer(t, "failure_authz")
+	defer server.Close()
+
+	for _, allow := range []bool{true, false} {
+		f := newTestFilter(t, map[string]interface{}{
+			"cluster":            "failure_authz",
+			"timeout":            "100ms",
+			"failure_mode_allow": allow,
+			"status_on_error":    503,
+		})
+		handler := newMockHandler()
+		f.SetReceiveFilterHandler(handler)
+		// the authorization service times out
+		f.OnReceive(context.Background(), protocol.CommonHeader{"x-delay": "true", "x-allowed": "true"}, nil, nil)
+		handler.wait(t)
+		if allow && handler.hijackCode != 0 {
+			t.Errorf("the request should be allowed in failure mode allow, but hijacked with %d", handler.hijackCode)
+		}
+		if !allow && handler.hijackCode != 503 {
+			t.Errorf("the request should be rejected with the status on error, but got %d", handler.hijackCode)
+		}
+	}
+
+	// the cluster not found
+	f := newTestFilter(t, map[string]interface{}{
+		"cluster": "unknown_cluster",
+	})
+	handler := newMockHandler()
+	f.SetReceiveFilterHandler(handler)
+	f.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil)
+	handler.wait(t)
+	if handler.hijackCode != http.StatusForbidden {
+		t.Errorf("the request should be rejected, but got %d", handler.hijackCode)
+	}
+}
+
+func TestAuthzRouteAndBody(t *testing.T) {
+	f := newTestFilter(t, map[string]interface{}{
+		"cluster": "unknown_cluster",
+		"with_request_body": map[string]interface{}{
+			"max_request_bytes": 4,
+		},
+	})
+
+	// disabled by the route
+	handler := newMockHandler()
+	handler.route = &mockRoute{
+		rule: &mockRouteRule{
+			config: map[string]interface{}{
+				v2.ExtAuthz: map[string]interface{}{"disabled": true},
+			},
+		},
+	}
+	f.SetReceiveFilterHandler(handler)
+	if status := f.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil); status != api.StreamFilterContinue {
+		t.Errorf("the disabled route should be continued, but got %s", status)
+	}
+
+	// the body is too large
+	handler = newMockHandler()
+	f.SetReceiveFilterHandler(handler)
+	status := f.OnReceive(context.Background(), protocol.CommonHeader{}, buffer.NewIoBufferString("large body"), nil)
+	if status != api.StreamFilterStop || handler.hijackCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("the large body should be rejected, status: %s, code: %d", status, handler.hijackCode)
+	}
+
+	// the partial body is sent
+	f.cfg.WithRequestBody.AllowPartialMessage = true
+	body, size, ok := f.requestBody(buffer.NewIoBufferString("large body"))
+	if !ok || string(body) != "larg" || size != 10 {
+		t.Errorf("unexpected partial body: %s, %d, %v", body, size, ok)
+	}
+}
+
+func TestAuthzDestroyed(t *testing.T) {
+	server := newHTTPAuthzServer(t, "destroy_authz")
+	defer server.Close()
+
+	f := newTestFilter(t, map[string]interface{}{
+		"cluster": "destroy_authz",
+		"timeout": "1s",
+	})
+	handler := newMockHandler()
+	f.SetReceiveFilterHandler(handler)
+	f.OnReceive(context.Background(), protocol.CommonHeader{"x-delay": "true"}, nil, nil)
+	f.OnDestroy()
+	select {
+	case <-handler.continued:
+		t.Error("the destroyed filter should not continue the stream")
+	case <-time.After(700 * time.Millisecond):
+	}
+}
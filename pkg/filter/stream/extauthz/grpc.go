@@ -0,0 +1,169 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+// grpcClient checks the request by a grpc service implements envoy.service.auth.v2.Authorization
+type grpcClient struct {
+	cfg *config
+
+	mux sync.Mutex
+	// conns are the grpc connections of the hosts, the key is the address of the host
+	conns map[string]*grpc.ClientConn
+}
+
+func newGRPCClient(cfg *config) *grpcClient {
+	return &grpcClient{
+		cfg:   cfg,
+		conns: make(map[string]*grpc.ClientConn),
+	}
+}
+
+func (c *grpcClient) Check(ctx context.Context, req *checkRequest) (*checkResponse, error) {
+	conn, err := c.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := &authCheckResponse{}
+	if err := conn.Invoke(ctx, checkMethod, newAuthCheckRequest(req), out); err != nil {
+		return nil, err
+	}
+
+	result := &checkResponse{}
+	if out.Status != nil && codes.Code(out.Status.Code) == codes.OK {
+		result.allowed = true
+		if out.OkResponse != nil {
+			result.headers = convertHeaders(out.OkResponse.Headers)
+		}
+		return result, nil
+	}
+	result.status = http.StatusForbidden
+	if denied := out.DeniedResponse; denied != nil {
+		if denied.Status != nil && denied.Status.Code > 0 {
+			result.status = int(denied.Status.Code)
+		}
+		result.headers = convertHeaders(denied.Headers)
+		result.body = denied.Body
+	}
+	return result, nil
+}
+
+// getConn returns the connection of a host chosen by the load balancer,
+// the connections of the hosts removed from the cluster are closed when a new connection is created.
+func (c *grpcClient) getConn(ctx context.Context) (*grpc.ClientConn, error) {
+	snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(ctx, c.cfg.Cluster)
+	if snapshot == nil {
+		return nil, fmt.Errorf("cluster %s not found", c.cfg.Cluster)
+	}
+	host := snapshot.LoadBalancer().ChooseHost(nil)
+	if host == nil {
+		return nil, fmt.Errorf("no available host in cluster %s", c.cfg.Cluster)
+	}
+	addr := host.AddressString()
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if conn, ok := c.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	c.conns[addr] = conn
+
+	hosts := make(map[string]bool)
+	for _, h := range snapshot.HostSet().Hosts() {
+		hosts[h.AddressString()] = true
+	}
+	for a, conn := range c.conns {
+		if !hosts[a] {
+			log.DefaultLogger.Infof("[stream filter][ext_authz] close the grpc connection of removed host %s", a)
+			conn.Close()
+			delete(c.conns, a)
+		}
+	}
+	return conn, nil
+}
+
+func newAuthCheckRequest(req *checkRequest) *authCheckRequest {
+	return &authCheckRequest{
+		Attributes: &attributeContext{
+			Source: &attributeContextPeer{
+				Address:   newAddress(req.sourceAddress),
+				Principal: req.sourcePrincipal,
+			},
+			Destination: &attributeContextPeer{
+				Address: newAddress(req.destinationAddress),
+			},
+			Request: &attributeContextRequest{
+				HTTP: &attributeContextHTTPRequest{
+					Method:   req.method,
+					Headers:  req.headers,
+					Path:     req.requestURI(),
+					Host:     req.host,
+					Query:    req.query,
+					Size:     req.size,
+					Protocol: req.protocol,
+					Body:     string(req.body),
+				},
+			},
+			ContextExtensions: req.contextExtensions,
+		},
+	}
+}
+
+func newAddress(addr net.Addr) *address {
+	if addr == nil {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	portValue, _ := strconv.ParseUint(port, 10, 32)
+	return &address{
+		SocketAddress: &socketAddress{
+			Address:   host,
+			PortValue: uint32(portValue),
+		},
+	}
+}
+
+func convertHeaders(options []*headerValueOption) []header {
+	headers := make([]header, 0, len(options))
+	for _, o := range options {
+		if o.Header != nil && o.Header.Key != "" {
+			headers = append(headers, header{key: o.Header.Key, value: o.Header.Value})
+		}
+	}
+	return headers
+}
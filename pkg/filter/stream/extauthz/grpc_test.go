@@ -0,0 +1,145 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// mockAuthorizationServer is a grpc authorization service, the request with the
+// header "x-allowed: true" is allowed, and the others are denied
+type mockAuthorizationServer struct {
+	last *authCheckRequest
+}
+
+func (s *mockAuthorizationServer) check(ctx context.Context, req *authCheckRequest) (*authCheckResponse, error) {
+	s.last = req
+	if req.Attributes.Request.HTTP.Headers["x-allowed"] == "true" {
+		return &authCheckResponse{
+			Status: &rpcStatus{Code: int32(codes.OK)},
+			OkResponse: &okHTTPResponse{
+				Headers: []*headerValueOption{
+					{Header: &headerValue{Key: "x-user", Value: "mosn"}},
+				},
+			},
+		}, nil
+	}
+	return &authCheckResponse{
+		Status: &rpcStatus{Code: int32(codes.PermissionDenied)},
+		DeniedResponse: &deniedHTTPResponse{
+			Status: &httpStatus{Code: 401},
+			Headers: []*headerValueOption{
+				{Header: &headerValue{Key: "x-reason", Value: "denied"}},
+			},
+		},
+	}, nil
+}
+
+func newGRPCAuthzServer(t *testing.T, name string) (*grpc.Server, *mockAuthorizationServer) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	impl := &mockAuthorizationServer{}
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "envoy.service.auth.v2.Authorization",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Check",
+				Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+					req := &authCheckRequest{}
+					if err := dec(req); err != nil {
+						return nil, err
+					}
+					return impl.check(ctx, req)
+				},
+			},
+		},
+	}, impl)
+	go server.Serve(ln)
+	setupCluster(t, name, ln.Addr().String())
+	return server, impl
+}
+
+func TestGRPCAuthz(t *testing.T) {
+	server, impl := newGRPCAuthzServer(t, "grpc_authz")
+	defer server.Stop()
+
+	f := newTestFilter(t, map[string]interface{}{
+		"cluster":                  "grpc_authz",
+		"protocol":                 "grpc",
+		"timeout":                  "1s",
+		"allowed_upstream_headers": []string{"x-user"},
+	})
+
+	handler := newMockHandler()
+	handler.route = &mockRoute{
+		rule: &mockRouteRule{
+			config: map[string]interface{}{
+				v2.ExtAuthz: map[string]interface{}{
+					"context_extensions": map[string]string{"route": "api"},
+				},
+			},
+		},
+	}
+	f.SetReceiveFilterHandler(handler)
+	headers := protocol.CommonHeader{
+		"x-allowed":                "true",
+		protocol.MosnHeaderPathKey: "/api",
+		protocol.MosnHeaderMethod:  "POST",
+	}
+	f.OnReceive(context.Background(), headers, nil, nil)
+	handler.wait(t)
+	if handler.hijackCode != 0 {
+		t.Errorf("the request should be allowed, but hijacked with %d", handler.hijackCode)
+	}
+	if v, _ := headers.Get("x-user"); v != "mosn" {
+		t.Errorf("the allowed upstream header is not added: %s", v)
+	}
+	attrs := impl.last.Attributes
+	if attrs.Request.HTTP.Path != "/api" || attrs.Request.HTTP.Method != "POST" ||
+		attrs.Source.Address.SocketAddress.Address != "10.0.0.1" || attrs.Source.Address.SocketAddress.PortValue != 12345 ||
+		attrs.ContextExtensions["route"] != "api" {
+		t.Errorf("unexpected check request: %v", impl.last)
+	}
+	if _, ok := attrs.Request.HTTP.Headers[protocol.MosnHeaderPathKey]; ok {
+		t.Error("the internal headers should not be sent")
+	}
+
+	handler = newMockHandler()
+	f.SetReceiveFilterHandler(handler)
+	headers = protocol.CommonHeader{}
+	f.OnReceive(context.Background(), headers, nil, nil)
+	handler.wait(t)
+	if handler.hijackCode != 401 || handler.info.flag != types.UnauthorizedFlag {
+		t.Errorf("the request should be denied, but got %d", handler.hijackCode)
+	}
+	if v, _ := headers.Get("x-reason"); v != "denied" {
+		t.Errorf("the client header is not sent: %s", v)
+	}
+}
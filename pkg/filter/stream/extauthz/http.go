@@ -0,0 +1,139 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+const (
+	headerPeerAddress   = "x-ext-authz-peer-address"
+	headerPeerPrincipal = "x-ext-authz-peer-principal"
+
+	// the body of the denied response larger than it is truncated
+	maxDeniedBodySize = 64 * 1024
+)
+
+// httpClient checks the request by a http authorization service. The request is allowed
+// if the service responds 200, otherwise the response is sent to the client.
+type httpClient struct {
+	cfg    *config
+	client *http.Client
+}
+
+func newHTTPClient(cfg *config) *httpClient {
+	return &httpClient{
+		cfg: cfg,
+		// the timeout is controlled by the context
+		client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (c *httpClient) Check(ctx context.Context, req *checkRequest) (*checkResponse, error) {
+	host, err := chooseHost(ctx, c.cfg.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	method := req.method
+	if method == "" {
+		method = http.MethodPost
+	}
+	var body io.Reader
+	if len(req.body) > 0 {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequest(method, "http://"+host.AddressString()+c.cfg.PathPrefix+req.requestURI(), body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	for k, v := range req.headers {
+		switch k {
+		// the length is the length of the body sent
+		case "content-length", "transfer-encoding", "connection":
+			continue
+		case "host":
+			httpReq.Host = v
+			continue
+		}
+		httpReq.Header.Set(k, v)
+	}
+	if req.host != "" {
+		httpReq.Host = req.host
+	}
+	if req.sourceAddress != nil {
+		httpReq.Header.Set(headerPeerAddress, req.sourceAddress.String())
+	}
+	if req.sourcePrincipal != "" {
+		httpReq.Header.Set(headerPeerPrincipal, req.sourcePrincipal)
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &checkResponse{
+		allowed: resp.StatusCode == http.StatusOK,
+		status:  resp.StatusCode,
+	}
+	for k, values := range resp.Header {
+		k = strings.ToLower(k)
+		if k == "content-length" || k == "transfer-encoding" || k == "connection" {
+			continue
+		}
+		for _, v := range values {
+			result.headers = append(result.headers, header{key: k, value: v})
+		}
+	}
+	if !result.allowed {
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDeniedBodySize))
+		if err != nil {
+			return nil, err
+		}
+		result.body = string(data)
+	}
+	return result, nil
+}
+
+// chooseHost returns a host of the cluster by the load balancer
+func chooseHost(ctx context.Context, clusterName string) (types.Host, error) {
+	snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(ctx, clusterName)
+	if snapshot == nil {
+		return nil, fmt.Errorf("cluster %s not found", clusterName)
+	}
+	host := snapshot.LoadBalancer().ChooseHost(nil)
+	if host == nil {
+		return nil, fmt.Errorf("no available host in cluster %s", clusterName)
+	}
+	return host, nil
+}
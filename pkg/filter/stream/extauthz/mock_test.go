@@ -0,0 +1,184 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockStreamReceiverFilterHandler struct {
+	api.StreamReceiverFilterHandler
+	route      *mockRoute
+	info       *mockRequestInfo
+	conn       *mockConnection
+	hijackCode int
+	directBody string
+	// continued is closed when the paused filter chain continues
+	continued chan struct{}
+}
+
+func newMockHandler() *mockStreamReceiverFilterHandler {
+	return &mockStreamReceiverFilterHandler{
+		info: &mockRequestInfo{protocol: api.Protocol("Http1")},
+		conn: &mockConnection{
+			remote: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 12345},
+			local:  &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 80},
+		},
+		continued: make(chan struct{}),
+	}
+}
+
+func (h *mockStreamReceiverFilterHandler) Route() api.Route {
+	if h.route == nil {
+		return nil
+	}
+	return h.route
+}
+
+func (h *mockStreamReceiverFilterHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func (h *mockStreamReceiverFilterHandler) Connection() api.Connection {
+	return h.conn
+}
+
+func (h *mockStreamReceiverFilterHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+func (h *mockStreamReceiverFilterHandler) SendDirectResponse(headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) {
+	status, _ := headers.Get(types.HeaderStatus)
+	h.hijackCode, _ = strconv.Atoi(status)
+	h.directBody = buf.String()
+}
+
+func (h *mockStreamReceiverFilterHandler) ContinueReceiving() {
+	close(h.continued)
+}
+
+// wait waits for the paused filter chain continues
+func (h *mockStreamReceiverFilterHandler) wait(t *testing.T) {
+	select {
+	case <-h.continued:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the filter chain is not continued")
+	}
+}
+
+type mockRoute struct {
+	api.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() api.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	api.RouteRule
+	config map[string]interface{}
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+type mockRequestInfo struct {
+	api.RequestInfo
+	protocol api.Protocol
+	flag     api.ResponseFlag
+}
+
+func (info *mockRequestInfo) Protocol() api.Protocol {
+	return info.protocol
+}
+
+func (info *mockRequestInfo) SetResponseFlag(flag api.ResponseFlag) {
+	info.flag = flag
+}
+
+func (info *mockRequestInfo) SetResponseCode(code int) {}
+
+type mockConnection struct {
+	api.Connection
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *mockConnection) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *mockConnection) RawConn() net.Conn {
+	return nil
+}
+
+// setupCluster adds the cluster of the authorization service to the cluster manager
+func setupCluster(t *testing.T, name string, addr string) {
+	cluster.NewClusterManagerSingleton(nil, nil)
+	err := cluster.GetClusterMngAdapterInstance().TriggerClusterAndHostsAddOrUpdate(v2.Cluster{
+		Name:        name,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	}, []v2.Host{
+		{HostConfig: v2.HostConfig{Address: addr}},
+	})
+	if err != nil {
+		t.Fatalf("add cluster failed: %v", err)
+	}
+}
+
+// newHTTPAuthzServer starts a http authorization service, the requests with the header
+// "x-allowed: true" is allowed, and the others are denied
+func newHTTPAuthzServer(t *testing.T, name string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-delay") != "" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		if r.Header.Get("x-allowed") == "true" {
+			w.Header().Set("x-user", "mosn")
+			w.Header().Set("x-not-allowed", "value")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("x-reason", "denied")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("denied by " + r.URL.Path))
+	}))
+	setupCluster(t, name, strings.TrimPrefix(server.URL, "http://"))
+	return server
+}
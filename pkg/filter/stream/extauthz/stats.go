@@ -0,0 +1,57 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extauthz
+
+import (
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+const metricsType = "ext_authz"
+
+// metrics key
+const (
+	statsOk                 = "ok"
+	statsDenied             = "denied"
+	statsError              = "error"
+	statsFailureModeAllowed = "failure_mode_allowed"
+	statsDisabled           = "disabled"
+	statsLatency            = "latency"
+)
+
+type extAuthzStats struct {
+	ok                 gometrics.Counter
+	denied             gometrics.Counter
+	err                gometrics.Counter
+	failureModeAllowed gometrics.Counter
+	disabled           gometrics.Counter
+	// latency is the duration of the authorization request in milliseconds
+	latency gometrics.Histogram
+}
+
+func newExtAuthzStats(clusterName string) *extAuthzStats {
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"cluster": clusterName})
+	return &extAuthzStats{
+		ok:                 m.Counter(statsOk),
+		denied:             m.Counter(statsDenied),
+		err:                m.Counter(statsError),
+		failureModeAllowed: m.Counter(statsFailureModeAllowed),
+		disabled:           m.Counter(statsDisabled),
+		latency:            m.Histogram(statsLatency),
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"encoding/json"
 	"math/rand"
+	"strconv"
 	"time"
 
 	"mosn.io/api"
@@ -31,20 +32,35 @@ import (
 	"mosn.io/pkg/buffer"
 )
 
+// the request headers override the delay and the abort if the header overrides is enabled,
+// the headers are removed before the request is sent to the upstream
+const (
+	// HeaderDelayRequest is the delay in milliseconds
+	HeaderDelayRequest = "x-mosn-fault-delay-request"
+	// HeaderDelayRequestPercentage is the percentage(0~100) of the delay, 100 by default
+	HeaderDelayRequestPercentage = "x-mosn-fault-delay-request-percentage"
+	// HeaderAbortRequest is the status code of the abort
+	HeaderAbortRequest = "x-mosn-fault-abort-request"
+	// HeaderAbortRequestPercentage is the percentage(0~100) of the abort, 100 by default
+	HeaderAbortRequestPercentage = "x-mosn-fault-abort-request-percentage"
+)
+
 // faultInjectConfig is parsed from v2.StreamFaultInject
 type faultInjectConfig struct {
-	fixedDelay   time.Duration
-	delayPercent uint32
-	abortStatus  int
-	abortPercent uint32
-	upstream     string
-	headers      []*types.HeaderData
+	fixedDelay      time.Duration
+	delayPercent    uint32
+	abortStatus     int
+	abortPercent    uint32
+	upstream        string
+	headers         []*types.HeaderData
+	headerOverrides bool
 }
 
 func makefaultInjectConfig(cfg *v2.StreamFaultInject) *faultInjectConfig {
 	faultConfig := &faultInjectConfig{
-		upstream: cfg.UpstreamCluster,
-		headers:  router.GetRouterHeaders(cfg.Headers),
+		upstream:        cfg.UpstreamCluster,
+		headers:         router.GetRouterHeaders(cfg.Headers),
+		headerOverrides: cfg.HeaderOverrides,
 	}
 	if cfg.Delay != nil {
 		faultConfig.fixedDelay = cfg.Delay.Delay
@@ -57,6 +73,41 @@ func makefaultInjectConfig(cfg *v2.StreamFaultInject) *faultInjectConfig {
 	return faultConfig
 }
 
+// override returns a copy of the config that is overridden by the request headers
+func (c *faultInjectConfig) override(headers api.HeaderMap) *faultInjectConfig {
+	overridden := *c
+	if v, ok := headers.Get(HeaderDelayRequest); ok {
+		if ms, err := strconv.ParseUint(v, 10, 32); err == nil {
+			overridden.fixedDelay = time.Duration(ms) * time.Millisecond
+			if overridden.delayPercent == 0 {
+				overridden.delayPercent = 100
+			}
+		}
+	}
+	if v, ok := headers.Get(HeaderDelayRequestPercentage); ok {
+		if percent, err := strconv.ParseUint(v, 10, 32); err == nil && percent <= 100 {
+			overridden.delayPercent = uint32(percent)
+		}
+	}
+	if v, ok := headers.Get(HeaderAbortRequest); ok {
+		if status, err := strconv.Atoi(v); err == nil && status > 0 {
+			overridden.abortStatus = status
+			if overridden.abortPercent == 0 {
+				overridden.abortPercent = 100
+			}
+		}
+	}
+	if v, ok := headers.Get(HeaderAbortRequestPercentage); ok {
+		if percent, err := strconv.ParseUint(v, 10, 32); err == nil && percent <= 100 {
+			overridden.abortPercent = uint32(percent)
+		}
+	}
+	for _, h := range []string{HeaderDelayRequest, HeaderDelayRequestPercentage, HeaderAbortRequest, HeaderAbortRequestPercentage} {
+		headers.Del(h)
+	}
+	return &overridden
+}
+
 // TODO: this is a hack for per route config parse
 // delete it later, when per route config changes to map[string]interface{}
 func parseStreamFaultInjectConfig(c interface{}) (*faultInjectConfig, bool) {
@@ -140,7 +191,9 @@ func (f *streamFaultInjectFilter) OnReceive(ctx context.Context, headers api.Hea
 		}
 		return api.StreamFilterContinue
 	}
-	// TODO: some parameters can get from request header
+	if f.config.headerOverrides {
+		f.config = f.config.override(headers)
+	}
 	if delay := f.getDelayDuration(); delay > 0 {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] start a delay timer")
@@ -387,3 +387,57 @@ func TestFaultInject_RouteConfigOverride(t *testing.T) {
 		t.Error("timeout")
 	}
 }
+
+func TestFaultInject_HeaderOverrides(t *testing.T) {
+	cfg := &v2.StreamFaultInject{
+		HeaderOverrides: true,
+	}
+	newCallbacks := func() *mockStreamReceiverFilterCallbacks {
+		return &mockStreamReceiverFilterCallbacks{
+			info: &mockRequestInfo{},
+			route: &mockRoute{
+				rule: &mockRouteRule{},
+			},
+			called: make(chan int, 1),
+		}
+	}
+	// delay and abort by the headers
+	cb := newCallbacks()
+	f := NewFilter(context.Background(), cfg)
+	f.SetReceiveFilterHandler(cb)
+	headers := protocol.CommonHeader{
+		HeaderDelayRequest: "200",
+		HeaderAbortRequest: "503",
+	}
+	start := time.Now()
+	if status := f.OnReceive(context.TODO(), headers, nil, nil); status != api.StreamFilterStop {
+		t.Fatal("fault inject should matched")
+	}
+	if cost := time.Since(start); cost < 200*time.Millisecond || cb.hijackCode != 503 || cb.info.flag != api.FaultInjected {
+		t.Fatalf("unexpected fault inject, cost: %v, code: %d", cost, cb.hijackCode)
+	}
+	if len(headers) != 0 {
+		t.Fatalf("the override headers should be removed: %v", headers)
+	}
+	// the percentage headers disable the configured faults
+	cb = newCallbacks()
+	f = NewFilter(context.Background(), &v2.StreamFaultInject{
+		Abort: &v2.AbortInject{
+			Percent: 100,
+			Status:  500,
+		},
+		HeaderOverrides: true,
+	})
+	f.SetReceiveFilterHandler(cb)
+	if status := f.OnReceive(context.TODO(), protocol.CommonHeader{HeaderAbortRequestPercentage: "0"}, nil, nil); status != api.StreamFilterContinue {
+		t.Fatal("fault inject should not abort")
+	}
+	// the headers are ignored without header overrides
+	cb = newCallbacks()
+	f = NewFilter(context.Background(), &v2.StreamFaultInject{})
+	f.SetReceiveFilterHandler(cb)
+	headers = protocol.CommonHeader{HeaderAbortRequest: "503"}
+	if status := f.OnReceive(context.TODO(), headers, nil, nil); status != api.StreamFilterContinue || len(headers) != 1 {
+		t.Fatal("the override headers should be ignored")
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+func init() {
+	api.RegisterStream(v2.HealthCheckStream, createFilterChainFactory)
+}
+
+type filterChainFactory struct {
+	checker *checker
+}
+
+func (f *filterChainFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := newHealthCheckFilter(f.checker)
+	// the health check requests are answered before the route, so they need no route
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+	callbacks.AddStreamSenderFilter(filter)
+}
+
+func createFilterChainFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &filterChainFactory{
+		checker: newChecker(cfg),
+	}, nil
+}
+
+func parseConfig(conf map[string]interface{}) (*v2.HealthCheckFilter, error) {
+	cfg := &v2.HealthCheckFilter{}
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	// endpoint is the single path of the old config
+	if cfg.Endpoint != "" {
+		cfg.Paths = append(cfg.Paths, cfg.Endpoint)
+	}
+	if len(cfg.Paths) == 0 {
+		return nil, errors.New("health check paths are required")
+	}
+	for name, percentage := range cfg.ClusterMinHealthyPercentage {
+		if percentage < 0 || percentage > 100 {
+			return nil, errors.New("invalid min healthy percentage of cluster " + name)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/server"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// isDraining reports whether the servers are draining the connections
+var isDraining = server.IsDraining
+
+// checker is shared by the filters of a listener
+type checker struct {
+	cfg   *v2.HealthCheckFilter
+	paths map[string]bool
+	stats *healthCheckStats
+
+	// the status code of the last upstream response in the pass through mode
+	mux       sync.RWMutex
+	code      int
+	expiredAt time.Time
+}
+
+func newChecker(cfg *v2.HealthCheckFilter) *checker {
+	paths := make(map[string]bool, len(cfg.Paths))
+	for _, path := range cfg.Paths {
+		paths[path] = true
+	}
+	return &checker{
+		cfg:   cfg,
+		paths: paths,
+		stats: newHealthCheckStats(cfg.Paths),
+	}
+}
+
+// unhealthyCluster returns the first cluster whose healthy hosts are less than the min percentage,
+// the cluster not found or without hosts is treated as zero percent healthy
+func (c *checker) unhealthyCluster(ctx context.Context) (string, bool) {
+	for name, minPercentage := range c.cfg.ClusterMinHealthyPercentage {
+		var percentage float32
+		snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(ctx, name)
+		if snapshot != nil {
+			hosts := snapshot.HostSet().Hosts()
+			healthy := 0
+			for _, host := range hosts {
+				if host.Health() {
+					healthy++
+				}
+			}
+			if len(hosts) > 0 {
+				percentage = float32(healthy) * 100 / float32(len(hosts))
+			}
+		}
+		if percentage < minPercentage {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (c *checker) cachedCode() (int, bool) {
+	if c.cfg.CacheTime <= 0 {
+		return 0, false
+	}
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	if c.code == 0 || time.Now().After(c.expiredAt) {
+		return 0, false
+	}
+	return c.code, true
+}
+
+func (c *checker) cache(code int) {
+	if c.cfg.CacheTime <= 0 {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.code = code
+	c.expiredAt = time.Now().Add(c.cfg.CacheTime)
+}
+
+// healthCheckFilter answers the health check requests of the load balancers locally,
+// the requests fail once the servers start draining, so the instance is taken out before shutdown.
+type healthCheckFilter struct {
+	checker        *checker
+	receiveHandler api.StreamReceiverFilterHandler
+	sendHandler    api.StreamSenderFilterHandler
+	// passThrough is set when the health check request is sent to the upstream
+	passThrough bool
+}
+
+func newHealthCheckFilter(c *checker) *healthCheckFilter {
+	return &healthCheckFilter{
+		checker: c,
+	}
+}
+
+func (f *healthCheckFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *healthCheckFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *healthCheckFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	prot := f.receiveHandler.RequestInfo().Protocol()
+	if prot != protocol.HTTP1 && prot != protocol.HTTP2 {
+		return api.StreamFilterContinue
+	}
+	if path, _ := headers.Get(protocol.MosnHeaderPathKey); !f.checker.paths[path] {
+		return api.StreamFilterContinue
+	}
+	if isDraining() {
+		f.checker.stats.draining.Inc(1)
+		f.respond(prot, http.StatusServiceUnavailable)
+		return api.StreamFilterStop
+	}
+	if f.checker.cfg.PassThrough {
+		if code, ok := f.checker.cachedCode(); ok {
+			f.checker.stats.cached.Inc(1)
+			f.respond(prot, code)
+			return api.StreamFilterStop
+		}
+		f.checker.stats.passThrough.Inc(1)
+		f.passThrough = true
+		return api.StreamFilterContinue
+	}
+	if name, ok := f.checker.unhealthyCluster(ctx); ok {
+		log.Proxy.Warnf(ctx, "[stream filter][health_check] the healthy hosts of cluster %s are less than %.2f%%", name, f.checker.cfg.ClusterMinHealthyPercentage[name])
+		f.checker.stats.failed.Inc(1)
+		f.respond(prot, http.StatusServiceUnavailable)
+		return api.StreamFilterStop
+	}
+	f.checker.stats.ok.Inc(1)
+	f.respond(prot, http.StatusOK)
+	return api.StreamFilterStop
+}
+
+// respond sends the response without echoing the request headers
+func (f *healthCheckFilter) respond(prot api.Protocol, code int) {
+	var headers api.HeaderMap
+	if prot == protocol.HTTP1 {
+		headers = mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	} else {
+		headers = protocol.CommonHeader{}
+	}
+	headers.Set(types.HeaderStatus, strconv.Itoa(code))
+	f.receiveHandler.RequestInfo().SetResponseCode(code)
+	f.receiveHandler.SendDirectResponse(headers, nil, nil)
+}
+
+func (f *healthCheckFilter) Append(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if f.passThrough {
+		f.checker.cache(f.sendHandler.RequestInfo().ResponseCode())
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *healthCheckFilter) OnDestroy() {}
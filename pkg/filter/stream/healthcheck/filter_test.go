@@ -0,0 +1,172 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/server"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+// port is the port of the next host, the health flags are shared by the hosts with the same address
+var port = 10000
+
+// setupCluster adds a cluster with the hosts, the first unhealthy hosts are marked as failed
+func setupCluster(t *testing.T, name string, hosts, unhealthy int) {
+	cluster.NewClusterManagerSingleton(nil, nil)
+	cfgs := make([]v2.Host, 0, hosts)
+	for i := 0; i < hosts; i++ {
+		cfgs = append(cfgs, v2.Host{HostConfig: v2.HostConfig{Address: fmt.Sprintf("127.0.0.1:%d", port)}})
+		port++
+	}
+	err := cluster.GetClusterMngAdapterInstance().TriggerClusterAndHostsAddOrUpdate(v2.Cluster{
+		Name:        name,
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	}, cfgs)
+	if err != nil {
+		t.Fatalf("add cluster failed: %v", err)
+	}
+	snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), name)
+	for _, host := range snapshot.HostSet().Hosts()[:unhealthy] {
+		host.SetHealthFlag(api.FAILED_ACTIVE_HC)
+	}
+}
+
+func newTestFilter(t *testing.T, conf map[string]interface{}, prot api.Protocol) (*healthCheckFilter, *mockStreamReceiverFilterHandler) {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	return newTestFilterWithChecker(newChecker(cfg), prot)
+}
+
+func newTestFilterWithChecker(c *checker, prot api.Protocol) (*healthCheckFilter, *mockStreamReceiverFilterHandler) {
+	info := &mockRequestInfo{protocol: prot}
+	f := newHealthCheckFilter(c)
+	handler := &mockStreamReceiverFilterHandler{info: info}
+	f.SetReceiveFilterHandler(handler)
+	f.SetSenderFilterHandler(&mockStreamSenderFilterHandler{info: info})
+	return f, handler
+}
+
+func request(path string) api.HeaderMap {
+	return protocol.CommonHeader{protocol.MosnHeaderPathKey: path}
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, conf := range []map[string]interface{}{
+		{},
+		{"paths": []string{"/healthz"}, "cluster_min_healthy_percentages": map[string]float32{"test": 101}},
+	} {
+		if _, err := parseConfig(conf); err == nil {
+			t.Errorf("expected error: %v", conf)
+		}
+	}
+	cfg, err := parseConfig(map[string]interface{}{
+		"endpoint":   "/status",
+		"paths":      []string{"/healthz"},
+		"cache_time": "1s",
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if len(cfg.Paths) != 2 || cfg.CacheTime.Seconds() != 1 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	setupCluster(t, "healthy", 4, 1)
+	setupCluster(t, "unhealthy", 4, 3)
+	cases := []struct {
+		name     string
+		clusters map[string]float32
+		prot     api.Protocol
+		path     string
+		status   api.StreamFilterStatus
+		code     int
+	}{
+		{"not health check", nil, protocol.HTTP1, "/foo", api.StreamFilterContinue, 0},
+		{"not http", nil, protocol.Xprotocol, "/healthz", api.StreamFilterContinue, 0},
+		{"http1", nil, protocol.HTTP1, "/healthz", api.StreamFilterStop, http.StatusOK},
+		{"http2", nil, protocol.HTTP2, "/healthz", api.StreamFilterStop, http.StatusOK},
+		{"healthy cluster", map[string]float32{"healthy": 75}, protocol.HTTP1, "/healthz", api.StreamFilterStop, http.StatusOK},
+		{"unhealthy cluster", map[string]float32{"healthy": 75, "unhealthy": 50}, protocol.HTTP1, "/healthz", api.StreamFilterStop, http.StatusServiceUnavailable},
+		{"cluster not found", map[string]float32{"unknown": 0.1}, protocol.HTTP1, "/healthz", api.StreamFilterStop, http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		f, handler := newTestFilter(t, map[string]interface{}{
+			"paths":                           []string{"/healthz"},
+			"cluster_min_healthy_percentages": c.clusters,
+		}, c.prot)
+		if status := f.OnReceive(context.Background(), request(c.path), nil, nil); status != c.status || handler.directCode != c.code {
+			t.Errorf("%s: expected %v %d, but got %v %d", c.name, c.status, c.code, status, handler.directCode)
+		}
+	}
+}
+
+func TestHealthCheckDraining(t *testing.T) {
+	isDraining = func() bool { return true }
+	defer func() { isDraining = server.IsDraining }()
+	for _, passThrough := range []bool{false, true} {
+		f, handler := newTestFilter(t, map[string]interface{}{
+			"paths":       []string{"/healthz"},
+			"passthrough": passThrough,
+		}, protocol.HTTP2)
+		if status := f.OnReceive(context.Background(), request("/healthz"), nil, nil); status != api.StreamFilterStop || handler.directCode != http.StatusServiceUnavailable {
+			t.Errorf("expected 503, but got %v %d", status, handler.directCode)
+		}
+	}
+}
+
+func TestHealthCheckPassThrough(t *testing.T) {
+	cfg, _ := parseConfig(map[string]interface{}{
+		"paths":       []string{"/healthz"},
+		"passthrough": true,
+		"cache_time":  "1h",
+	})
+	c := newChecker(cfg)
+
+	// the first request is sent to the upstream, and the response is cached
+	f, handler := newTestFilterWithChecker(c, protocol.HTTP1)
+	if status := f.OnReceive(context.Background(), request("/healthz"), nil, nil); status != api.StreamFilterContinue {
+		t.Fatalf("expected continue, but got %v", status)
+	}
+	handler.info.code = http.StatusServiceUnavailable
+	f.Append(context.Background(), protocol.CommonHeader{}, nil, nil)
+
+	f, handler = newTestFilterWithChecker(c, protocol.HTTP1)
+	if status := f.OnReceive(context.Background(), request("/healthz"), nil, nil); status != api.StreamFilterStop || handler.directCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the cached response, but got %v %d", status, handler.directCode)
+	}
+	// the responses of the other requests are not cached
+	f, _ = newTestFilterWithChecker(c, protocol.HTTP1)
+	f.OnReceive(context.Background(), request("/foo"), nil, nil)
+	handler.info.code = http.StatusOK
+	f.Append(context.Background(), protocol.CommonHeader{}, nil, nil)
+	if code, _ := c.cachedCode(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected the cached code not changed, but got %d", code)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package healthcheck
+
+import (
+	"strconv"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockStreamReceiverFilterHandler struct {
+	api.StreamReceiverFilterHandler
+	info       *mockRequestInfo
+	directCode int
+}
+
+func (h *mockStreamReceiverFilterHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func (h *mockStreamReceiverFilterHandler) SendDirectResponse(headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) {
+	status, _ := headers.Get(types.HeaderStatus)
+	h.directCode, _ = strconv.Atoi(status)
+}
+
+type mockStreamSenderFilterHandler struct {
+	api.StreamSenderFilterHandler
+	info *mockRequestInfo
+}
+
+func (h *mockStreamSenderFilterHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+type mockRequestInfo struct {
+	api.RequestInfo
+	protocol api.Protocol
+	code     int
+}
+
+func (info *mockRequestInfo) Protocol() api.Protocol {
+	return info.protocol
+}
+
+func (info *mockRequestInfo) SetResponseCode(code int) {
+	info.code = code
+}
+
+func (info *mockRequestInfo) ResponseCode() int {
+	return info.code
+}
@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package healthcheck
+
+import (
+	"strings"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+const metricsType = "health_check"
+
+// metrics key
+const (
+	statsOk          = "ok"
+	statsFailed      = "failed"
+	statsDraining    = "draining"
+	statsPassThrough = "passthrough"
+	statsCached      = "cached"
+)
+
+type healthCheckStats struct {
+	ok gometrics.Counter
+	// failed counts the requests failed by the unhealthy clusters
+	failed      gometrics.Counter
+	draining    gometrics.Counter
+	passThrough gometrics.Counter
+	// cached counts the requests answered by the cached upstream response
+	cached gometrics.Counter
+}
+
+func newHealthCheckStats(paths []string) *healthCheckStats {
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"paths": strings.Join(paths, ",")})
+	return &healthCheckStats{
+		ok:          m.Counter(statsOk),
+		failed:      m.Counter(statsFailed),
+		draining:    m.Counter(statsDraining),
+		passThrough: m.Counter(statsPassThrough),
+		cached:      m.Counter(statsCached),
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"mosn.io/api"
+)
+
+const (
+	defaultHeader          = "Authorization"
+	defaultValuePrefix     = "Bearer "
+	defaultFetchTimeout    = time.Second
+	defaultCacheDuration   = 5 * time.Minute
+	defaultClockSkewSecond = 60
+)
+
+type config struct {
+	// Providers is the jwt providers, the key is the provider name used in the requirements
+	Providers map[string]*providerConfig `json:"providers"`
+	// Requires is the default requirement of the requests, the route's requirement overrides it
+	Requires *requirement `json:"requires,omitempty"`
+}
+
+// requirement is the providers required by the request, the request is passed if the token
+// is verified by any one of them. It is configured in the route's per filter config too.
+type requirement struct {
+	Providers []string `json:"providers,omitempty"`
+	// AllowMissing passes the request without a token, but the invalid token is still rejected
+	AllowMissing bool `json:"allow_missing,omitempty"`
+	// Disabled skips the verification, it is used to disable the default requirement for some routes
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+type providerConfig struct {
+	Issuer    string   `json:"issuer,omitempty"`
+	Audiences []string `json:"audiences,omitempty"`
+	// LocalJwks and RemoteJwks are the JWKS to verify the token, only one of them should be set
+	LocalJwks  *localJwks  `json:"local_jwks,omitempty"`
+	RemoteJwks *remoteJwks `json:"remote_jwks,omitempty"`
+	// FromHeaders and FromParams are where the token is extracted, "Authorization: Bearer <token>" is the default
+	FromHeaders []*jwtHeader `json:"from_headers,omitempty"`
+	FromParams  []string     `json:"from_params,omitempty"`
+	// Forward keeps the token header, the header is removed after the verification by default
+	Forward bool `json:"forward,omitempty"`
+	// ClaimToHeaders and ClaimToVariables forward the claims of the verified token
+	ClaimToHeaders   []*claimToHeader   `json:"claim_to_headers,omitempty"`
+	ClaimToVariables []*claimToVariable `json:"claim_to_variables,omitempty"`
+	// ClockSkewSeconds is the tolerance of checking the exp and nbf, 60 seconds by default
+	ClockSkewSeconds int `json:"clock_skew_seconds,omitempty"`
+}
+
+type localJwks struct {
+	FileName     string `json:"file_name,omitempty"`
+	InlineString string `json:"inline_string,omitempty"`
+}
+
+type remoteJwks struct {
+	// Cluster is the cluster of the JWKS server, Path is the http path of the JWKS
+	Cluster string `json:"cluster"`
+	Path    string `json:"path"`
+	// Host is the Host header of the request, the address of the host is used if it is empty
+	Host          string             `json:"host,omitempty"`
+	Timeout       api.DurationConfig `json:"timeout,omitempty"`
+	CacheDuration api.DurationConfig `json:"cache_duration,omitempty"`
+}
+
+type jwtHeader struct {
+	Name        string `json:"name"`
+	ValuePrefix string `json:"value_prefix,omitempty"`
+}
+
+type claimToHeader struct {
+	Claim  string `json:"claim"`
+	Header string `json:"header"`
+}
+
+type claimToVariable struct {
+	Claim    string `json:"claim"`
+	Variable string `json:"variable"`
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if len(filterConfig.Providers) == 0 {
+		return nil, errors.New("no jwt providers")
+	}
+	for name, provider := range filterConfig.Providers {
+		if err := provider.init(); err != nil {
+			return nil, fmt.Errorf("invalid jwt provider %s: %v", name, err)
+		}
+	}
+	if filterConfig.Requires != nil {
+		if err := filterConfig.checkRequirement(filterConfig.Requires); err != nil {
+			return nil, err
+		}
+	}
+	return filterConfig, nil
+}
+
+func parseRequirement(cfg interface{}) (*requirement, error) {
+	req := &requirement{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// checkRequirement checks the providers of the requirement are configured
+func (c *config) checkRequirement(req *requirement) error {
+	if req.Disabled {
+		return nil
+	}
+	if len(req.Providers) == 0 {
+		return errors.New("no providers in the jwt requirement")
+	}
+	for _, name := range req.Providers {
+		if _, ok := c.Providers[name]; !ok {
+			return fmt.Errorf("unknown jwt provider: %s", name)
+		}
+	}
+	return nil
+}
+
+// init checks the provider config and sets the default values
+func (p *providerConfig) init() error {
+	if (p.LocalJwks == nil) == (p.RemoteJwks == nil) {
+		return errors.New("one of local_jwks and remote_jwks should be set")
+	}
+	if p.LocalJwks != nil && p.LocalJwks.FileName == "" && p.LocalJwks.InlineString == "" {
+		return errors.New("no file_name or inline_string in local_jwks")
+	}
+	if p.RemoteJwks != nil {
+		if p.RemoteJwks.Cluster == "" || p.RemoteJwks.Path == "" {
+			return errors.New("no cluster or path in remote_jwks")
+		}
+		if p.RemoteJwks.Timeout.Duration <= 0 {
+			p.RemoteJwks.Timeout.Duration = defaultFetchTimeout
+		}
+		if p.RemoteJwks.CacheDuration.Duration <= 0 {
+			p.RemoteJwks.CacheDuration.Duration = defaultCacheDuration
+		}
+	}
+	if len(p.FromHeaders) == 0 && len(p.FromParams) == 0 {
+		p.FromHeaders = []*jwtHeader{
+			{Name: defaultHeader, ValuePrefix: defaultValuePrefix},
+		}
+	}
+	for _, c := range p.ClaimToHeaders {
+		if c.Claim == "" || c.Header == "" {
+			return errors.New("no claim or header in claim_to_headers")
+		}
+	}
+	for _, c := range p.ClaimToVariables {
+		if c.Claim == "" || c.Variable == "" {
+			return errors.New("no claim or variable in claim_to_variables")
+		}
+	}
+	if p.ClockSkewSeconds <= 0 {
+		p.ClockSkewSeconds = defaultClockSkewSecond
+	}
+	return nil
+}
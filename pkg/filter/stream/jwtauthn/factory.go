@@ -0,0 +1,60 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"context"
+	"fmt"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+func init() {
+	api.RegisterStream(v2.JwtAuthn, createFilterChainFactory)
+}
+
+type filterChainFactory struct {
+	cfg *config
+	// providers are shared by the filters, so the remote JWKS is cached in them
+	providers map[string]*provider
+}
+
+func (f *filterChainFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := newJwtAuthnFilter(context, f.cfg, f.providers)
+	callbacks.AddStreamReceiverFilter(filter, api.AfterRoute)
+}
+
+func createFilterChainFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	providers := make(map[string]*provider, len(cfg.Providers))
+	for name, providerCfg := range cfg.Providers {
+		p, err := newProvider(providerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("create jwt provider %s failed: %v", name, err)
+		}
+		providers[name] = p
+	}
+	return &filterChainFactory{
+		cfg:       cfg,
+		providers: providers,
+	}, nil
+}
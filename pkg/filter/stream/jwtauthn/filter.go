@@ -0,0 +1,130 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"context"
+	"fmt"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+const headerWWWAuthenticate = "WWW-Authenticate"
+
+// jwtAuthnFilter is an implement of types.StreamReceiverFilter, it verifies the jwt of the request
+// according to the requirement, and the request is rejected with 401 if the verification fails.
+type jwtAuthnFilter struct {
+	ctx       context.Context
+	cfg       *config
+	providers map[string]*provider
+
+	handler api.StreamReceiverFilterHandler
+}
+
+func newJwtAuthnFilter(ctx context.Context, cfg *config, providers map[string]*provider) *jwtAuthnFilter {
+	return &jwtAuthnFilter{
+		ctx:       ctx,
+		cfg:       cfg,
+		providers: providers,
+	}
+}
+
+// readPerRouteConfig returns the route's requirement, the default requirement is used if the route has none
+func (f *jwtAuthnFilter) readPerRouteConfig(ctx context.Context, cfg map[string]interface{}) *requirement {
+	if cfg == nil {
+		return f.cfg.Requires
+	}
+	routeCfg, ok := cfg[v2.JwtAuthn]
+	if !ok {
+		return f.cfg.Requires
+	}
+	req, err := parseRequirement(routeCfg)
+	if err == nil {
+		err = f.cfg.checkRequirement(req)
+	}
+	if err != nil {
+		// reject the requests rather than skip the verification
+		log.Proxy.Errorf(ctx, "[stream filter][jwt_authn] invalid route config: %v", err)
+		return &requirement{}
+	}
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter][jwt_authn] use router config to replace the requirement: %+v", req)
+	}
+	return req
+}
+
+func (f *jwtAuthnFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *jwtAuthnFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	req := f.cfg.Requires
+	if route := f.handler.Route(); route != nil && route.RouteRule() != nil {
+		req = f.readPerRouteConfig(ctx, route.RouteRule().PerFilterConfig())
+	}
+	if req == nil || req.Disabled {
+		return api.StreamFilterContinue
+	}
+
+	for _, name := range req.Providers {
+		f.providers[name].removeClaimHeaders(headers)
+	}
+
+	var err error
+	for _, name := range req.Providers {
+		p := f.providers[name]
+		raw, header := p.extract(headers)
+		if raw == "" {
+			continue
+		}
+		token, verifyErr := p.verify(raw)
+		if verifyErr != nil {
+			err = verifyErr
+			continue
+		}
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[stream filter][jwt_authn] jwt is verified by provider %s", name)
+		}
+		if !p.cfg.Forward && header != "" {
+			headers.Del(header)
+		}
+		p.forwardClaims(ctx, headers, token)
+		return api.StreamFilterContinue
+	}
+
+	if err == nil {
+		if req.AllowMissing {
+			return api.StreamFilterContinue
+		}
+		err = errJwtMissing
+	}
+	log.Proxy.Warnf(ctx, "[stream filter][jwt_authn] request is rejected: %v", err)
+	f.handler.RequestInfo().SetResponseFlag(types.UnauthorizedFlag)
+	if err == errJwtMissing {
+		headers.Set(headerWWWAuthenticate, "Bearer")
+	} else {
+		headers.Set(headerWWWAuthenticate, fmt.Sprintf(`Bearer error="invalid_token", error_description="%s"`, err))
+	}
+	f.handler.SendHijackReply(types.UnauthorizedCode, headers)
+	return api.StreamFilterStop
+}
+
+func (f *jwtAuthnFilter) OnDestroy() {}
@@ -0,0 +1,284 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"providers": map[string]interface{}{
+			"remote": map[string]interface{}{
+				"remote_jwks": map[string]interface{}{
+					"cluster": "jwks_cluster",
+					"path":    "/jwks",
+				},
+				"from_params": []string{"access_token"},
+			},
+		},
+		"requires": map[string]interface{}{
+			"providers": []string{"remote"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	p := cfg.Providers["remote"]
+	if p.RemoteJwks.Timeout.Duration != defaultFetchTimeout ||
+		p.RemoteJwks.CacheDuration.Duration != defaultCacheDuration ||
+		p.ClockSkewSeconds != defaultClockSkewSecond ||
+		len(p.FromHeaders) != 0 {
+		t.Errorf("unexpected provider config: %+v", p)
+	}
+
+	for i, invalid := range []map[string]interface{}{
+		{},
+		{"providers": map[string]interface{}{"p": map[string]interface{}{}}},
+		{"providers": map[string]interface{}{"p": map[string]interface{}{
+			"local_jwks":  map[string]interface{}{"inline_string": "{}"},
+			"remote_jwks": map[string]interface{}{"cluster": "c", "path": "/"},
+		}}},
+		{"providers": map[string]interface{}{"p": map[string]interface{}{
+			"remote_jwks": map[string]interface{}{"cluster": "c"},
+		}}},
+		{
+			"providers": map[string]interface{}{"p": map[string]interface{}{
+				"remote_jwks": map[string]interface{}{"cluster": "c", "path": "/"},
+			}},
+			"requires": map[string]interface{}{"providers": []string{"unknown"}},
+		},
+	} {
+		if _, err := parseConfig(invalid); err == nil {
+			t.Errorf("#%d invalid config should be failed", i)
+		}
+	}
+}
+
+func newTestFactory(t *testing.T, keys *testKeys) *filterChainFactory {
+	factory, err := createFilterChainFactory(map[string]interface{}{
+		"providers": map[string]interface{}{
+			"rsa_provider": map[string]interface{}{
+				"issuer":     "rsa_issuer",
+				"local_jwks": map[string]interface{}{"inline_string": keys.jwks()},
+				"claim_to_headers": []map[string]string{
+					{"claim": "sub", "header": "x-jwt-sub"},
+				},
+				"claim_to_variables": []map[string]string{
+					{"claim": "user.name", "variable": "test_jwt_user_name"},
+				},
+			},
+			"hmac_provider": map[string]interface{}{
+				"issuer":     "hmac_issuer",
+				"local_jwks": map[string]interface{}{"inline_string": keys.jwks()},
+				"from_headers": []map[string]string{
+					{"name": "Authorization", "value_prefix": "Bearer "},
+				},
+				"from_params": []string{"token"},
+				"forward":     true,
+			},
+		},
+		"requires": map[string]interface{}{
+			"providers": []string{"rsa_provider", "hmac_provider"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create filter factory failed: %v", err)
+	}
+	return factory.(*filterChainFactory)
+}
+
+func TestJwtAuthnFilter(t *testing.T) {
+	keys := newTestKeys(t)
+	factory := newTestFactory(t, keys)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	rsaToken := keys.sign(t, algRS256, "rsa", map[string]interface{}{
+		"iss":  "rsa_issuer",
+		"sub":  "rsa_user",
+		"exp":  exp,
+		"user": map[string]interface{}{"name": "mosn"},
+	})
+	hmacToken := keys.sign(t, algHS256, "hmac", map[string]interface{}{
+		"iss": "hmac_issuer",
+		"sub": "hmac_user",
+		"exp": exp,
+	})
+	expiredToken := keys.sign(t, algRS256, "rsa", map[string]interface{}{
+		"iss": "rsa_issuer",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	})
+
+	testCases := []struct {
+		name        string
+		route       map[string]interface{}
+		headers     protocol.CommonHeader
+		passed      bool
+		wantHeaders map[string]string
+		variable    string
+	}{
+		{
+			name: "rsa token in header",
+			headers: protocol.CommonHeader{
+				"Authorization": "Bearer " + rsaToken,
+				"x-jwt-sub":     "forged",
+			},
+			passed: true,
+			wantHeaders: map[string]string{
+				"x-jwt-sub":     "rsa_user",
+				"Authorization": "",
+			},
+			variable: "mosn",
+		},
+		{
+			name: "hmac token in query",
+			headers: protocol.CommonHeader{
+				protocol.MosnHeaderQueryStringKey: "a=b&token=" + hmacToken,
+			},
+			passed: true,
+		},
+		{
+			name: "hmac token in header is forwarded",
+			headers: protocol.CommonHeader{
+				"Authorization": "bearer " + hmacToken,
+			},
+			passed: true,
+			wantHeaders: map[string]string{
+				"Authorization": "bearer " + hmacToken,
+			},
+		},
+		{
+			name:    "missing token",
+			headers: protocol.CommonHeader{},
+			passed:  false,
+			wantHeaders: map[string]string{
+				headerWWWAuthenticate: "Bearer",
+			},
+		},
+		{
+			name: "expired token",
+			headers: protocol.CommonHeader{
+				"Authorization": "Bearer " + expiredToken,
+			},
+			passed: false,
+		},
+		{
+			name: "forged claim header without token",
+			route: map[string]interface{}{
+				"providers":     []string{"rsa_provider"},
+				"allow_missing": true,
+			},
+			headers: protocol.CommonHeader{
+				"x-jwt-sub": "forged",
+			},
+			passed: true,
+			wantHeaders: map[string]string{
+				"x-jwt-sub": "",
+			},
+		},
+		{
+			name: "invalid token is rejected even if missing is allowed",
+			route: map[string]interface{}{
+				"providers":     []string{"rsa_provider"},
+				"allow_missing": true,
+			},
+			headers: protocol.CommonHeader{
+				"Authorization": "Bearer invalid",
+			},
+			passed: false,
+		},
+		{
+			name: "the provider is not required by the route",
+			route: map[string]interface{}{
+				"providers": []string{"rsa_provider"},
+			},
+			headers: protocol.CommonHeader{
+				protocol.MosnHeaderQueryStringKey: "token=" + hmacToken,
+			},
+			passed: false,
+		},
+		{
+			name: "disabled by route",
+			route: map[string]interface{}{
+				"disabled": true,
+			},
+			headers: protocol.CommonHeader{},
+			passed:  true,
+		},
+		{
+			name: "invalid route config",
+			route: map[string]interface{}{
+				"providers": []string{"unknown"},
+			},
+			headers: protocol.CommonHeader{
+				"Authorization": "Bearer " + rsaToken,
+			},
+			passed: false,
+		},
+	}
+	for _, tc := range testCases {
+		ctx := variable.NewVariableContext(context.Background())
+		handler := &mockStreamReceiverFilterHandler{
+			info: &mockRequestInfo{},
+		}
+		if tc.route != nil {
+			handler.route = &mockRoute{
+				rule: &mockRouteRule{
+					config: map[string]interface{}{
+						v2.JwtAuthn: tc.route,
+					},
+				},
+			}
+		}
+		f := newJwtAuthnFilter(ctx, factory.cfg, factory.providers)
+		f.SetReceiveFilterHandler(handler)
+		status := f.OnReceive(ctx, tc.headers, nil, nil)
+
+		if tc.passed {
+			if status != api.StreamFilterContinue || handler.hijackCode != 0 {
+				t.Errorf("%s: the request should be passed", tc.name)
+			}
+		} else {
+			if status != api.StreamFilterStop || handler.hijackCode != types.UnauthorizedCode || handler.info.flag != types.UnauthorizedFlag {
+				t.Errorf("%s: the request should be rejected", tc.name)
+			}
+			if value, _ := tc.headers.Get(headerWWWAuthenticate); !strings.HasPrefix(value, "Bearer") {
+				t.Errorf("%s: unexpected WWW-Authenticate: %s", tc.name, value)
+			}
+		}
+		for k, v := range tc.wantHeaders {
+			if value, _ := tc.headers.Get(k); value != v {
+				t.Errorf("%s: header %s expected %q, but got %q", tc.name, k, v, value)
+			}
+		}
+		if tc.variable != "" {
+			if value, err := variable.GetVariableValue(ctx, "test_jwt_user_name"); err != nil || value != tc.variable {
+				t.Errorf("%s: variable expected %s, but got %s, %v", tc.name, tc.variable, value, err)
+			}
+		}
+	}
+}
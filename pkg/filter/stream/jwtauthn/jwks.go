@@ -0,0 +1,294 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/utils"
+)
+
+// the failed fetching is not retried in the interval
+const fetchRetryInterval = time.Second
+
+// jwk is a public key of RSA or EC, or a HMAC secret
+type jwk struct {
+	kid string
+	alg string
+	// key is *rsa.PublicKey, *ecdsa.PublicKey or []byte
+	key interface{}
+}
+
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	// HMAC
+	K string `json:"k,omitempty"`
+}
+
+// parseJwks parses the JWKS defined in RFC 7517, the keys not supported are skipped
+func parseJwks(data []byte) ([]*jwk, error) {
+	jwks := struct {
+		Keys []*jwkJSON `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %v", err)
+	}
+	keys := make([]*jwk, 0, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		key, err := k.parse()
+		if err != nil {
+			log.DefaultLogger.Warnf("[stream filter][jwt_authn] skip the key %s in jwks: %v", k.Kid, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no valid keys in jwks")
+	}
+	return keys, nil
+}
+
+func (k *jwkJSON) parse() (*jwk, error) {
+	key := &jwk{
+		kid: k.Kid,
+		alg: k.Alg,
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeSegment(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeSegment(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid rsa key")
+		}
+		key.key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeSegment(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("invalid ec key")
+		}
+		key.key = pub
+	case "oct":
+		secret, err := decodeSegment(k.K)
+		if err != nil {
+			return nil, err
+		}
+		if len(secret) == 0 {
+			return nil, errors.New("empty hmac secret")
+		}
+		key.key = secret
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+	return key, nil
+}
+
+// jwksSource provides the keys of a provider
+type jwksSource interface {
+	Keys() ([]*jwk, error)
+}
+
+type localJwksSource struct {
+	keys []*jwk
+}
+
+func newLocalJwksSource(cfg *localJwks) (*localJwksSource, error) {
+	data := []byte(cfg.InlineString)
+	if cfg.FileName != "" {
+		var err error
+		if data, err = ioutil.ReadFile(cfg.FileName); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := parseJwks(data)
+	if err != nil {
+		return nil, err
+	}
+	return &localJwksSource{keys: keys}, nil
+}
+
+func (s *localJwksSource) Keys() ([]*jwk, error) {
+	return s.keys, nil
+}
+
+// remoteJwksSource fetches the JWKS from the cluster and caches it.
+// The expired keys are still used while they are refreshed in the background.
+type remoteJwksSource struct {
+	cfg   *remoteJwks
+	fetch func(cfg *remoteJwks) ([]byte, error)
+
+	// fetchMux makes the keys fetched once at a time
+	fetchMux   sync.Mutex
+	mux        sync.Mutex
+	keys       []*jwk
+	expireTime time.Time
+	failTime   time.Time
+	err        error
+	refreshing uint32
+}
+
+func newRemoteJwksSource(cfg *remoteJwks) *remoteJwksSource {
+	return &remoteJwksSource{
+		cfg:   cfg,
+		fetch: fetchRemoteJwks,
+	}
+}
+
+func (s *remoteJwksSource) Keys() ([]*jwk, error) {
+	keys, expired := s.cached()
+	if keys != nil {
+		if expired && atomic.CompareAndSwapUint32(&s.refreshing, 0, 1) {
+			utils.GoWithRecover(func() {
+				defer atomic.StoreUint32(&s.refreshing, 0)
+				s.fetchMux.Lock()
+				defer s.fetchMux.Unlock()
+				s.load()
+			}, nil)
+		}
+		return keys, nil
+	}
+
+	// the first fetching blocks the requests, the requests waiting for it use the result directly
+	s.fetchMux.Lock()
+	defer s.fetchMux.Unlock()
+	if keys, _ := s.cached(); keys != nil {
+		return keys, nil
+	}
+	s.mux.Lock()
+	failTime, err := s.failTime, s.err
+	s.mux.Unlock()
+	if time.Since(failTime) < fetchRetryInterval {
+		return nil, err
+	}
+	return s.load()
+}
+
+func (s *remoteJwksSource) cached() ([]*jwk, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.keys, time.Now().After(s.expireTime)
+}
+
+// load fetches the JWKS, it is called with the fetchMux held
+func (s *remoteJwksSource) load() ([]*jwk, error) {
+	data, err := s.fetch(s.cfg)
+	var keys []*jwk
+	if err == nil {
+		keys, err = parseJwks(data)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now()
+	if err != nil {
+		log.DefaultLogger.Errorf("[stream filter][jwt_authn] fetch jwks from cluster %s failed: %v", s.cfg.Cluster, err)
+		s.failTime = now
+		s.err = err
+		// keep the stale keys, and try again later
+		if s.keys != nil {
+			s.expireTime = now.Add(fetchRetryInterval)
+		}
+		return nil, err
+	}
+	s.keys = keys
+	s.expireTime = now.Add(s.cfg.CacheDuration.Duration)
+	s.err = nil
+	return keys, nil
+}
+
+// fetchRemoteJwks gets the JWKS from a host of the cluster by http
+func fetchRemoteJwks(cfg *remoteJwks) ([]byte, error) {
+	snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), cfg.Cluster)
+	if snapshot == nil {
+		return nil, fmt.Errorf("cluster %s not found", cfg.Cluster)
+	}
+	host := snapshot.LoadBalancer().ChooseHost(nil)
+	if host == nil {
+		return nil, fmt.Errorf("no available host in cluster %s", cfg.Cluster)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+host.AddressString()+cfg.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Host != "" {
+		req.Host = cfg.Host
+	}
+	client := &http.Client{Timeout: cfg.Timeout.Duration}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func decodeSegment(seg string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(seg)
+}
@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+func TestLocalJwks(t *testing.T) {
+	keys := newTestKeys(t)
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "jwks.json")
+	ioutil.WriteFile(file, []byte(keys.jwks()), 0644)
+
+	for _, cfg := range []*localJwks{
+		{FileName: file},
+		{InlineString: keys.jwks()},
+	} {
+		source, err := newLocalJwksSource(cfg)
+		if err != nil {
+			t.Fatalf("load local jwks failed: %v", err)
+		}
+		if jwks, _ := source.Keys(); len(jwks) != 3 {
+			t.Errorf("expected 3 keys, but got %d", len(jwks))
+		}
+	}
+	for _, cfg := range []*localJwks{
+		{FileName: filepath.Join(dir, "not_exists")},
+		{InlineString: "invalid"},
+		{InlineString: `{"keys":[{"kty":"EC","crv":"P-384"}]}`},
+	} {
+		if _, err := newLocalJwksSource(cfg); err == nil {
+			t.Errorf("load invalid jwks %+v should be failed", cfg)
+		}
+	}
+}
+
+func TestRemoteJwksCache(t *testing.T) {
+	keys := newTestKeys(t)
+	var fetched int32
+	var fail atomic.Value
+	fail.Store(false)
+	source := newRemoteJwksSource(&remoteJwks{
+		Cluster:       "jwks_cluster",
+		Path:          "/jwks",
+		CacheDuration: api.DurationConfig{Duration: 100 * time.Millisecond},
+	})
+	source.fetch = func(cfg *remoteJwks) ([]byte, error) {
+		atomic.AddInt32(&fetched, 1)
+		if fail.Load().(bool) {
+			return nil, errors.New("fetch failed")
+		}
+		return []byte(keys.jwks()), nil
+	}
+
+	// the keys are cached
+	for i := 0; i < 3; i++ {
+		if jwks, err := source.Keys(); err != nil || len(jwks) != 3 {
+			t.Fatalf("get keys failed: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetched); n != 1 {
+		t.Errorf("the keys should be fetched once, but fetched %d times", n)
+	}
+
+	// the stale keys are used when the refreshing fails
+	fail.Store(true)
+	time.Sleep(150 * time.Millisecond)
+	if jwks, err := source.Keys(); err != nil || len(jwks) != 3 {
+		t.Fatalf("the stale keys should be used, but got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&fetched); n != 2 {
+		t.Errorf("the expired keys should be refreshed, but fetched %d times", n)
+	}
+	if jwks, err := source.Keys(); err != nil || len(jwks) != 3 {
+		t.Fatalf("the stale keys should be used, but got %v", err)
+	}
+}
+
+func TestRemoteJwksFetchFailed(t *testing.T) {
+	var fetched int32
+	source := newRemoteJwksSource(&remoteJwks{
+		Cluster: "jwks_cluster",
+		Path:    "/jwks",
+	})
+	source.fetch = func(cfg *remoteJwks) ([]byte, error) {
+		atomic.AddInt32(&fetched, 1)
+		return nil, errors.New("fetch failed")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := source.Keys(); err == nil {
+			t.Fatal("get keys should be failed")
+		}
+	}
+	// the failed fetching is not retried at once
+	if n := atomic.LoadInt32(&fetched); n != 1 {
+		t.Errorf("the keys should be fetched once, but fetched %d times", n)
+	}
+
+	// the cluster is not exists
+	cluster.NewClusterManagerSingleton(nil, nil)
+	if _, err := fetchRemoteJwks(&remoteJwks{Cluster: "not_exists", Path: "/jwks"}); err == nil {
+		t.Error("fetch jwks from an unknown cluster should be failed")
+	}
+}
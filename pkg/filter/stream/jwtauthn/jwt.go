@@ -0,0 +1,219 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algRS256 = "RS256"
+	algES256 = "ES256"
+	algHS256 = "HS256"
+)
+
+var (
+	errJwtMissing          = errors.New("jwt is missing")
+	errJwtMalformed        = errors.New("jwt is malformed")
+	errJwtUnsupportedAlg   = errors.New("jwt algorithm is not supported")
+	errJwtVerificationFail = errors.New("jwt verification fails")
+	errJwtExpired          = errors.New("jwt is expired")
+	errJwtNotYetValid      = errors.New("jwt is not yet valid")
+	errJwtUnknownIssuer    = errors.New("jwt issuer is not configured")
+	errJwtAudienceNotAllow = errors.New("audiences in jwt are not allowed")
+)
+
+// jwtToken is a parsed JWS compact serialization of JWT
+type jwtToken struct {
+	header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid,omitempty"`
+	}
+	claims       map[string]interface{}
+	signingInput []byte
+	signature    []byte
+}
+
+func parseToken(raw string) (*jwtToken, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errJwtMalformed
+	}
+	token := &jwtToken{
+		signingInput: []byte(raw[:len(parts[0])+1+len(parts[1])]),
+	}
+	header, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, errJwtMalformed
+	}
+	if err := json.Unmarshal(header, &token.header); err != nil {
+		return nil, errJwtMalformed
+	}
+	payload, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, errJwtMalformed
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&token.claims); err != nil || token.claims == nil {
+		return nil, errJwtMalformed
+	}
+	if token.signature, err = decodeSegment(parts[2]); err != nil {
+		return nil, errJwtMalformed
+	}
+	return token, nil
+}
+
+// verify checks the signature by the keys, the key is chosen by the kid and the algorithm
+func (t *jwtToken) verify(keys []*jwk) error {
+	switch t.header.Alg {
+	case algRS256, algES256, algHS256:
+	default:
+		return errJwtUnsupportedAlg
+	}
+	hashed := sha256.Sum256(t.signingInput)
+	for _, key := range keys {
+		if t.header.Kid != "" && key.kid != "" && t.header.Kid != key.kid {
+			continue
+		}
+		if key.alg != "" && key.alg != t.header.Alg {
+			continue
+		}
+		// the type of the key must match the algorithm, so a public key is never used as a HMAC secret
+		switch k := key.key.(type) {
+		case *rsa.PublicKey:
+			if t.header.Alg == algRS256 && rsa.VerifyPKCS1v15(k, crypto.SHA256, hashed[:], t.signature) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if t.header.Alg == algES256 && len(t.signature) == 64 {
+				r := new(big.Int).SetBytes(t.signature[:32])
+				s := new(big.Int).SetBytes(t.signature[32:])
+				if ecdsa.Verify(k, hashed[:], r, s) {
+					return nil
+				}
+			}
+		case []byte:
+			if t.header.Alg == algHS256 {
+				mac := hmac.New(sha256.New, k)
+				mac.Write(t.signingInput)
+				if hmac.Equal(mac.Sum(nil), t.signature) {
+					return nil
+				}
+			}
+		}
+	}
+	return errJwtVerificationFail
+}
+
+// checkClaims checks the exp, nbf, iss and aud of the token
+func (t *jwtToken) checkClaims(cfg *providerConfig, now time.Time) error {
+	skew := int64(cfg.ClockSkewSeconds)
+	if exp, ok := t.numericClaim("exp"); ok && now.Unix() > exp+skew {
+		return errJwtExpired
+	}
+	if nbf, ok := t.numericClaim("nbf"); ok && now.Unix() < nbf-skew {
+		return errJwtNotYetValid
+	}
+	if cfg.Issuer != "" {
+		if iss, _ := t.claims["iss"].(string); iss != cfg.Issuer {
+			return errJwtUnknownIssuer
+		}
+	}
+	if len(cfg.Audiences) > 0 && !t.matchAudiences(cfg.Audiences) {
+		return errJwtAudienceNotAllow
+	}
+	return nil
+}
+
+func (t *jwtToken) numericClaim(name string) (int64, bool) {
+	n, ok := t.claims[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	if v, err := n.Int64(); err == nil {
+		return v, true
+	}
+	if v, err := n.Float64(); err == nil {
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// matchAudiences returns true if any one of the aud is allowed, the aud is a string or an array of strings
+func (t *jwtToken) matchAudiences(allowed []string) bool {
+	var auds []string
+	switch aud := t.claims["aud"].(type) {
+	case string:
+		auds = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				auds = append(auds, s)
+			}
+		}
+	}
+	for _, aud := range auds {
+		for _, a := range allowed {
+			if aud == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimValue returns the claim as a string, the nested claim is separated by dots, such as "user.name".
+// false is returned if the claim is not found.
+func (t *jwtToken) claimValue(name string) (string, bool) {
+	var value interface{} = t.claims
+	for _, key := range strings.Split(name, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if value, ok = m[key]; !ok || value == nil {
+			return "", false
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyToken(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks, err := parseJwks([]byte(keys.jwks()))
+	if err != nil {
+		t.Fatalf("parse jwks failed: %v", err)
+	}
+	if len(jwks) != 3 {
+		t.Fatalf("the unsupported key should be skipped, but got %d keys", len(jwks))
+	}
+	claims := map[string]interface{}{"sub": "test"}
+	for _, tc := range []struct {
+		alg, kid string
+	}{
+		{algRS256, "rsa"},
+		{algES256, "ec"},
+		{algHS256, "hmac"},
+		// the key is found by the algorithm without kid
+		{algRS256, ""},
+		{algES256, ""},
+		{algHS256, ""},
+	} {
+		token, err := parseToken(keys.sign(t, tc.alg, tc.kid, claims))
+		if err != nil {
+			t.Fatalf("%s parse token failed: %v", tc.alg, err)
+		}
+		if err := token.verify(jwks); err != nil {
+			t.Errorf("%s with kid %q verify failed: %v", tc.alg, tc.kid, err)
+		}
+	}
+
+	// the kid is not matched
+	token, _ := parseToken(keys.sign(t, algRS256, "ec", claims))
+	if err := token.verify(jwks); err != errJwtVerificationFail {
+		t.Errorf("the token with an unmatched kid should be failed, but got %v", err)
+	}
+	// the payload is changed
+	raw := keys.sign(t, algES256, "ec", claims)
+	parts := strings.Split(raw, ".")
+	parts[1] = encodeSegment([]byte(`{"sub":"admin"}`))
+	token, _ = parseToken(strings.Join(parts, "."))
+	if err := token.verify(jwks); err != errJwtVerificationFail {
+		t.Errorf("the forged token should be failed, but got %v", err)
+	}
+	// the public key cannot be used as the HMAC secret
+	other := newTestKeys(t)
+	other.secret = keys.rsa.N.Bytes()
+	token, _ = parseToken(other.sign(t, algHS256, "rsa", claims))
+	if err := token.verify(jwks); err != errJwtVerificationFail {
+		t.Errorf("the token signed by the public key should be failed, but got %v", err)
+	}
+	// the algorithm is not supported
+	token, _ = parseToken(encodeSegment([]byte(`{"alg":"none"}`)) + "." + encodeSegment([]byte(`{}`)) + ".")
+	if err := token.verify(jwks); err != errJwtUnsupportedAlg {
+		t.Errorf("the none algorithm should not be supported, but got %v", err)
+	}
+
+	for _, raw := range []string{"", "a.b", "a.b.c", "e30.e30.!", encodeSegment([]byte(`{}`)) + ".bnVsbA."} {
+		if _, err := parseToken(raw); err != errJwtMalformed {
+			t.Errorf("token %q should be malformed, but got %v", raw, err)
+		}
+	}
+}
+
+func TestCheckClaims(t *testing.T) {
+	keys := newTestKeys(t)
+	now := time.Now()
+	cfg := &providerConfig{
+		Issuer:           "https://issuer.example.com",
+		Audiences:        []string{"api", "web"},
+		ClockSkewSeconds: 60,
+	}
+	testCases := []struct {
+		claims   map[string]interface{}
+		expected error
+	}{
+		{
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": "api",
+				"exp": now.Add(time.Minute).Unix(),
+				"nbf": now.Unix(),
+			},
+			expected: nil,
+		},
+		{
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": []string{"other", "web"},
+				// in the clock skew
+				"exp": now.Add(-30 * time.Second).Unix(),
+			},
+			expected: nil,
+		},
+		{
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": "api",
+				"exp": now.Add(-2 * time.Minute).Unix(),
+			},
+			expected: errJwtExpired,
+		},
+		{
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": "api",
+				"nbf": now.Add(2 * time.Minute).Unix(),
+			},
+			expected: errJwtNotYetValid,
+		},
+		{
+			claims: map[string]interface{}{
+				"iss": "https://other.example.com",
+				"aud": "api",
+			},
+			expected: errJwtUnknownIssuer,
+		},
+		{
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": []string{"other"},
+			},
+			expected: errJwtAudienceNotAllow,
+		},
+		{
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+			},
+			expected: errJwtAudienceNotAllow,
+		},
+	}
+	for i, tc := range testCases {
+		token, err := parseToken(keys.sign(t, algHS256, "", tc.claims))
+		if err != nil {
+			t.Fatalf("#%d parse token failed: %v", i, err)
+		}
+		if err := token.checkClaims(cfg, now); err != tc.expected {
+			t.Errorf("#%d expected %v, but got %v", i, tc.expected, err)
+		}
+	}
+}
+
+func TestClaimValue(t *testing.T) {
+	keys := newTestKeys(t)
+	token, err := parseToken(keys.sign(t, algHS256, "", map[string]interface{}{
+		"sub":   "user",
+		"admin": true,
+		"level": 10,
+		"user": map[string]interface{}{
+			"name":  "mosn",
+			"roles": []string{"a", "b"},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("parse token failed: %v", err)
+	}
+	for claim, expected := range map[string]string{
+		"sub":        "user",
+		"admin":      "true",
+		"level":      "10",
+		"user.name":  "mosn",
+		"user.roles": `["a","b"]`,
+	} {
+		if value, ok := token.claimValue(claim); !ok || value != expected {
+			t.Errorf("claim %s expected %s, but got %s", claim, expected, value)
+		}
+	}
+	for _, claim := range []string{"unknown", "sub.name", "user.unknown"} {
+		if _, ok := token.claimValue(claim); ok {
+			t.Errorf("claim %s should not be found", claim)
+		}
+	}
+}
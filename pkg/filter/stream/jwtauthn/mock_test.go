@@ -0,0 +1,179 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"mosn.io/api"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockStreamReceiverFilterHandler struct {
+	api.StreamReceiverFilterHandler
+	route      *mockRoute
+	info       *mockRequestInfo
+	hijackCode int
+}
+
+func (h *mockStreamReceiverFilterHandler) Route() api.Route {
+	if h.route == nil {
+		return nil
+	}
+	return h.route
+}
+
+func (h *mockStreamReceiverFilterHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func (h *mockStreamReceiverFilterHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+type mockRoute struct {
+	api.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() api.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	api.RouteRule
+	config map[string]interface{}
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+type mockRequestInfo struct {
+	api.RequestInfo
+	flag api.ResponseFlag
+}
+
+func (info *mockRequestInfo) SetResponseFlag(flag api.ResponseFlag) {
+	info.flag = flag
+}
+
+// testKeys is the keys to sign the tokens in the tests
+type testKeys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key failed: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key failed: %v", err)
+	}
+	return &testKeys{
+		rsa:    rsaKey,
+		ec:     ecKey,
+		secret: []byte("hmac-secret-for-test"),
+	}
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func fixedBytes(i *big.Int, size int) []byte {
+	b := make([]byte, size)
+	data := i.Bytes()
+	copy(b[size-len(data):], data)
+	return b
+}
+
+// jwks returns the JWKS of the keys
+func (k *testKeys) jwks() string {
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"n":   encodeSegment(k.rsa.N.Bytes()),
+				"e":   encodeSegment(big.NewInt(int64(k.rsa.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   encodeSegment(fixedBytes(k.ec.X, 32)),
+				"y":   encodeSegment(fixedBytes(k.ec.Y, 32)),
+			},
+			{
+				"kty": "oct",
+				"kid": "hmac",
+				"k":   encodeSegment(k.secret),
+			},
+			{
+				"kty": "unknown",
+			},
+		},
+	}
+	data, _ := json.Marshal(jwks)
+	return string(data)
+}
+
+// sign returns a token signed by the algorithm
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := encodeSegment(header) + "." + encodeSegment(payload)
+	hashed := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case algRS256:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, hashed[:])
+	case algES256:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, hashed[:])
+		if err == nil {
+			sig = append(fixedBytes(r, 32), fixedBytes(s, 32)...)
+		}
+	case algHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	}
+	if err != nil {
+		t.Fatalf("sign token failed: %v", err)
+	}
+	return input + "." + encodeSegment(sig)
+}
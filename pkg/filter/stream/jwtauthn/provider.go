@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtauthn
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+// provider verifies the tokens issued by an issuer
+type provider struct {
+	cfg  *providerConfig
+	jwks jwksSource
+}
+
+func newProvider(cfg *providerConfig) (*provider, error) {
+	p := &provider{
+		cfg: cfg,
+	}
+	if cfg.LocalJwks != nil {
+		source, err := newLocalJwksSource(cfg.LocalJwks)
+		if err != nil {
+			return nil, err
+		}
+		p.jwks = source
+	} else {
+		p.jwks = newRemoteJwksSource(cfg.RemoteJwks)
+	}
+	// the claims are stored in the indexed variables, which should be registered before used
+	for _, c := range cfg.ClaimToVariables {
+		if _, err := variable.AddVariable(c.Variable); err != nil {
+			if err := variable.RegisterVariable(variable.NewIndexedVariable(c.Variable, nil, nil, variable.BasicSetter, 0)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
+
+// extract returns the token in the request, and the header contains it.
+// The header is empty if the token is in the query parameters.
+func (p *provider) extract(headers types.HeaderMap) (token string, header string) {
+	for _, h := range p.cfg.FromHeaders {
+		value, ok := headers.Get(h.Name)
+		if !ok {
+			continue
+		}
+		if h.ValuePrefix != "" {
+			if len(value) < len(h.ValuePrefix) || !strings.EqualFold(value[:len(h.ValuePrefix)], h.ValuePrefix) {
+				continue
+			}
+			value = value[len(h.ValuePrefix):]
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			return value, h.Name
+		}
+	}
+	if len(p.cfg.FromParams) > 0 {
+		if qs, ok := headers.Get(protocol.MosnHeaderQueryStringKey); ok {
+			if query, err := url.ParseQuery(qs); err == nil {
+				for _, param := range p.cfg.FromParams {
+					if value := query.Get(param); value != "" {
+						return value, ""
+					}
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// verify parses the token and checks the signature and the claims
+func (p *provider) verify(raw string) (*jwtToken, error) {
+	token, err := parseToken(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.checkClaims(p.cfg, time.Now()); err != nil {
+		return nil, err
+	}
+	keys, err := p.jwks.Keys()
+	if err != nil {
+		return nil, err
+	}
+	if err := token.verify(keys); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// removeClaimHeaders removes the claim headers sent by the downstream, so they cannot be forged
+func (p *provider) removeClaimHeaders(headers types.HeaderMap) {
+	for _, c := range p.cfg.ClaimToHeaders {
+		headers.Del(c.Header)
+	}
+}
+
+// forwardClaims sets the claims of the verified token to the headers and the variables
+func (p *provider) forwardClaims(ctx context.Context, headers types.HeaderMap, token *jwtToken) {
+	for _, c := range p.cfg.ClaimToHeaders {
+		if value, ok := token.claimValue(c.Claim); ok {
+			headers.Set(c.Header, value)
+		}
+	}
+	for _, c := range p.cfg.ClaimToVariables {
+		if value, ok := token.claimValue(c.Claim); ok {
+			if err := variable.SetVariableValue(ctx, c.Variable, value); err != nil {
+				log.Proxy.Warnf(ctx, "[stream filter][jwt_authn] set variable %s failed: %v", c.Variable, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"encoding/binary"
+)
+
+// the constants of the proxy-wasm abi, see https://github.com/proxy-wasm/spec
+
+// status is returned by the host functions
+type status uint32
+
+const (
+	statusOK                  status = 0
+	statusNotFound            status = 1
+	statusBadArgument         status = 2
+	statusSerializationFailed status = 3
+	statusInvalidMemoryAccess status = 6
+	statusEmpty               status = 7
+	statusCasMismatch         status = 8
+	statusInternalFailure     status = 10
+	statusUnimplemented       status = 12
+)
+
+// action is returned by the http callbacks of the module
+const (
+	actionContinue = 0
+	actionPause    = 1
+)
+
+const (
+	logLevelTrace    = 0
+	logLevelDebug    = 1
+	logLevelInfo     = 2
+	logLevelWarn     = 3
+	logLevelError    = 4
+	logLevelCritical = 5
+)
+
+const (
+	bufferHTTPRequestBody     = 0
+	bufferHTTPResponseBody    = 1
+	bufferVMConfiguration     = 6
+	bufferPluginConfiguration = 7
+)
+
+const (
+	mapHTTPRequestHeaders   = 0
+	mapHTTPRequestTrailers  = 1
+	mapHTTPResponseHeaders  = 2
+	mapHTTPResponseTrailers = 3
+)
+
+const (
+	streamRequest  = 0
+	streamResponse = 1
+)
+
+const (
+	metricCounter   = 0
+	metricGauge     = 1
+	metricHistogram = 2
+)
+
+// the functions exported by the module
+const (
+	exportMemoryAllocate   = "proxy_on_memory_allocate"
+	exportMalloc           = "malloc"
+	exportStart            = "_start"
+	exportInitialize       = "_initialize"
+	exportContextCreate    = "proxy_on_context_create"
+	exportVMStart          = "proxy_on_vm_start"
+	exportConfigure        = "proxy_on_configure"
+	exportTick             = "proxy_on_tick"
+	exportRequestHeaders   = "proxy_on_request_headers"
+	exportRequestBody      = "proxy_on_request_body"
+	exportRequestTrailers  = "proxy_on_request_trailers"
+	exportResponseHeaders  = "proxy_on_response_headers"
+	exportResponseBody     = "proxy_on_response_body"
+	exportResponseTrailers = "proxy_on_response_trailers"
+	exportDone             = "proxy_on_done"
+	exportLog              = "proxy_on_log"
+	exportDelete           = "proxy_on_delete"
+)
+
+// abiVersions is the supported versions, the module exports a function named by the version
+var abiVersions = []string{
+	"proxy_abi_version_0_2_1",
+	"proxy_abi_version_0_2_0",
+	"proxy_abi_version_0_1_0",
+}
+
+type headerPair struct {
+	key   string
+	value string
+}
+
+// encodePairs serializes the pairs in the format of the abi:
+// the count of the pairs, the sizes of the keys and the values, then the null-terminated keys and values
+func encodePairs(pairs []headerPair) []byte {
+	size := 4
+	for _, p := range pairs {
+		size += 8 + len(p.key) + len(p.value) + 2
+	}
+	b := make([]byte, size)
+	binary.LittleEndian.PutUint32(b, uint32(len(pairs)))
+	pos := 4
+	for _, p := range pairs {
+		binary.LittleEndian.PutUint32(b[pos:], uint32(len(p.key)))
+		binary.LittleEndian.PutUint32(b[pos+4:], uint32(len(p.value)))
+		pos += 8
+	}
+	for _, p := range pairs {
+		pos += copy(b[pos:], p.key) + 1
+		pos += copy(b[pos:], p.value) + 1
+	}
+	return b
+}
+
+// decodePairs parses the serialized pairs, false is returned if the data is malformed
+func decodePairs(b []byte) ([]headerPair, bool) {
+	if len(b) == 0 {
+		return nil, true
+	}
+	if len(b) < 4 {
+		return nil, false
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	if n < 0 || n > (len(b)-4)/8 {
+		return nil, false
+	}
+	pairs := make([]headerPair, n)
+	pos := 4 + n*8
+	for i := 0; i < n; i++ {
+		keySize := int(binary.LittleEndian.Uint32(b[4+i*8:]))
+		valueSize := int(binary.LittleEndian.Uint32(b[8+i*8:]))
+		if keySize < 0 || valueSize < 0 || pos+keySize+valueSize+2 > len(b) || pos+keySize+valueSize+2 < pos {
+			return nil, false
+		}
+		pairs[i].key = string(b[pos : pos+keySize])
+		pos += keySize + 1
+		pairs[i].value = string(b[pos : pos+valueSize])
+		pos += valueSize + 1
+	}
+	return pairs, true
+}
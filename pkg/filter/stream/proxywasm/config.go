@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+
+	"mosn.io/api"
+)
+
+// defaultExecutionLimit is large enough for the usual filters, and a tight loop hits it in less than a second
+const defaultExecutionLimit = 100000000
+
+type config struct {
+	// Path is the file path of the wasm module
+	Path string `json:"path"`
+	// RootID is the root id of the plugin, a module can contain multiple plugins identified by the root id
+	RootID string `json:"root_id,omitempty"`
+	// VMID is the id of the vm, the plugins with the same vm id share the shared data
+	VMID string `json:"vm_id,omitempty"`
+	// VMConfig is passed to proxy_on_vm_start, a string is passed as it is and the others are passed as json
+	VMConfig json.RawMessage `json:"vm_configuration,omitempty"`
+	// Configuration is passed to proxy_on_configure in the same way as VMConfig
+	Configuration json.RawMessage `json:"configuration,omitempty"`
+	// InstanceNum is the count of the instances of the module, the streams are dispatched to the instances
+	// in turn, and an instance handles one call at the same time. The count of the cpus by default.
+	InstanceNum int `json:"instance_num,omitempty"`
+	// ReloadInterval is the interval to check the modification of the module file,
+	// the module is reloaded if it is modified. The module is not reloaded if it is zero.
+	ReloadInterval api.DurationConfig `json:"reload_interval,omitempty"`
+	// FailOpen passes the streams if the module fails, otherwise the requests are rejected with 503
+	FailOpen bool `json:"fail_open,omitempty"`
+	// ExecutionLimit is the max count of the branches and calls executed by a call into the module,
+	// the call traps if it is exceeded, so a module that loops forever fails instead of hanging the worker.
+	ExecutionLimit uint64 `json:"execution_limit,omitempty"`
+
+	// vmConfig and pluginConfig are the bytes of VMConfig and Configuration
+	vmConfig     []byte
+	pluginConfig []byte
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.Path == "" {
+		return nil, errors.New("path of wasm module is required")
+	}
+	if filterConfig.InstanceNum <= 0 {
+		filterConfig.InstanceNum = runtime.NumCPU()
+	}
+	if filterConfig.ExecutionLimit == 0 {
+		filterConfig.ExecutionLimit = defaultExecutionLimit
+	}
+	if filterConfig.ReloadInterval.Duration < 0 {
+		return nil, errors.New("reload_interval should not be negative")
+	}
+	if filterConfig.vmConfig, err = rawConfig(filterConfig.VMConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.pluginConfig, err = rawConfig(filterConfig.Configuration); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// rawConfig returns the content of the json string, or the json itself if it is not a string
+func rawConfig(raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+	return []byte(raw), nil
+}
@@ -0,0 +1,309 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/wasm"
+	"mosn.io/pkg/buffer"
+)
+
+const headerContentLength = "Content-Length"
+
+// proxyWasmFilter is an implement of types.StreamReceiverFilter and types.StreamSenderFilter,
+// the stream is processed by an http context of the module.
+// The fields are protected by the lock of the instance, because the host functions access them.
+type proxyWasmFilter struct {
+	ctx    context.Context
+	plugin *plugin
+	inst   *instance
+	// vm and id identify the http context, the context is lost if the vm is recreated
+	vm *wasm.Instance
+	id uint32
+
+	receiveHandler api.StreamReceiverFilterHandler
+	sendHandler    api.StreamSenderFilterHandler
+
+	reqHeaders   api.HeaderMap
+	reqBody      buffer.IoBuffer
+	reqTrailers  api.HeaderMap
+	respHeaders  api.HeaderMap
+	respBody     buffer.IoBuffer
+	respTrailers api.HeaderMap
+	// the streaming body can not be accessed by the module
+	reqStreaming  bool
+	respStreaming bool
+
+	// response is true if the response is being processed
+	response bool
+	// running is true while the request callbacks are called, continued is true if the module
+	// continues the request in the callbacks
+	running   bool
+	continued bool
+	paused    bool
+	// localResponse is true if the module sends the local response
+	localResponse bool
+	destroyed     bool
+}
+
+func newProxyWasmFilter(ctx context.Context, p *plugin) *proxyWasmFilter {
+	return &proxyWasmFilter{
+		ctx:    ctx,
+		plugin: p,
+		inst:   p.instance(),
+	}
+}
+
+func (f *proxyWasmFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *proxyWasmFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *proxyWasmFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	f.inst.mux.Lock()
+	defer f.inst.mux.Unlock()
+	f.ctx = ctx
+	f.reqHeaders, f.reqBody, f.reqTrailers = headers, buf, trailers
+	f.running = true
+	action, ok := f.callHTTP(exportRequestHeaders, exportRequestBody, exportRequestTrailers, headers, buf, trailers)
+	f.running = false
+	if !ok {
+		if f.plugin.cfg.FailOpen {
+			return api.StreamFilterContinue
+		}
+		f.receiveHandler.SendHijackReply(http.StatusServiceUnavailable, headers)
+		return api.StreamFilterStop
+	}
+	if f.localResponse {
+		return api.StreamFilterStop
+	}
+	if action == actionPause && !f.continued {
+		if _, ok := f.receiveHandler.(types.ResumableStreamReceiverFilterHandler); ok {
+			f.paused = true
+			return types.StreamFilterPause
+		}
+		log.Proxy.Warnf(ctx, "%s the stream can not be paused, the request is continued", f.plugin.logPrefix)
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *proxyWasmFilter) Append(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	f.inst.mux.Lock()
+	defer f.inst.mux.Unlock()
+	f.ctx = ctx
+	f.respHeaders, f.respBody, f.respTrailers = headers, buf, trailers
+	f.response = true
+	action, ok := f.callHTTP(exportResponseHeaders, exportResponseBody, exportResponseTrailers, headers, buf, trailers)
+	// the response is always sent, because the sender filters can not be paused
+	if ok && action == actionPause && log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "%s the response can not be paused, the response is continued", f.plugin.logPrefix)
+	}
+	return api.StreamFilterContinue
+}
+
+// callHTTP calls the callbacks of the headers, the body and the trailers in order, until the module
+// pauses the stream or sends a local response. false is returned if the module fails.
+func (f *proxyWasmFilter) callHTTP(onHeaders, onBody, onTrailers string, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) (uint64, bool) {
+	i := f.inst
+	if !f.ensureContext() {
+		return 0, false
+	}
+	i.current = f
+	defer func() {
+		i.current = nil
+	}()
+
+	_, streaming := buf.(types.StreamingBuffer)
+	if f.response {
+		f.respStreaming = streaming
+	} else {
+		f.reqStreaming = streaming
+	}
+	hasBody := buf != nil && !streaming && buf.Len() > 0
+	hasTrailers := trailers != nil
+	endOfStream := func(end bool) uint64 {
+		if end {
+			return 1
+		}
+		return 0
+	}
+	var action uint64
+	var err error
+	name := onHeaders
+	if headers != nil {
+		n := len(headerPairs(headers))
+		action, err = i.call(name, uint64(f.id), uint64(n), endOfStream(!hasBody && !hasTrailers && !streaming))
+	}
+	if err == nil && action == actionContinue && !f.localResponse && hasBody {
+		name = onBody
+		action, err = i.call(name, uint64(f.id), uint64(buf.Len()), endOfStream(!hasTrailers))
+	}
+	if err == nil && action == actionContinue && !f.localResponse && hasTrailers {
+		name = onTrailers
+		action, err = i.call(name, uint64(f.id), uint64(len(headerPairs(trailers))))
+	}
+	if err != nil {
+		i.fail(f.ctx, name, err)
+		return 0, false
+	}
+	return action, true
+}
+
+// ensureContext creates the http context if it is not created, false is returned if the context is lost
+func (f *proxyWasmFilter) ensureContext() bool {
+	i := f.inst
+	if f.vm != nil {
+		return f.vm == i.vm
+	}
+	if !i.ready() {
+		return false
+	}
+	if err := i.createContext(f); err != nil {
+		i.fail(f.ctx, exportContextCreate, err)
+		return false
+	}
+	return true
+}
+
+// continueRequest resumes the paused request
+func (f *proxyWasmFilter) continueRequest() {
+	if f.destroyed {
+		return
+	}
+	if f.running {
+		f.continued = true
+		return
+	}
+	if f.paused {
+		f.paused = false
+		f.receiveHandler.(types.ResumableStreamReceiverFilterHandler).ContinueReceiving()
+	}
+}
+
+// sendLocalResponse sends the response to the client, the response is replaced if it is called in the response callbacks
+func (f *proxyWasmFilter) sendLocalResponse(code int, body []byte, pairs []headerPair) {
+	headers := protocol.CommonHeader{}
+	for _, p := range pairs {
+		addHeader(headers, p.key, p.value)
+	}
+	f.localResponse = true
+	if f.response {
+		headers.Set(types.HeaderStatus, strconv.Itoa(code))
+		f.sendHandler.SetResponseHeaders(headers)
+		f.sendHandler.SetResponseData(buffer.NewIoBufferBytes(body))
+		f.sendHandler.SetResponseTrailers(nil)
+		f.respHeaders, f.respBody, f.respTrailers = headers, nil, nil
+		return
+	}
+	// the body is only sent to the http clients, see sendHijackReplyWithBody in proxy
+	prot := f.receiveHandler.RequestInfo().Protocol()
+	if len(body) > 0 && (prot == protocol.HTTP1 || prot == protocol.HTTP2) {
+		f.receiveHandler.RequestInfo().SetResponseCode(code)
+		headers.Set(types.HeaderStatus, strconv.Itoa(code))
+		f.receiveHandler.SendDirectResponse(headers, buffer.NewIoBufferBytes(body), nil)
+	} else {
+		f.receiveHandler.SendHijackReply(code, headers)
+	}
+	// the hijack reply is sent after the paused stream is resumed
+	f.continueRequest()
+}
+
+// setRequestBody replaces the request body, false is returned if the body is streaming
+func (f *proxyWasmFilter) setRequestBody(body []byte) bool {
+	if f.reqStreaming {
+		return false
+	}
+	if f.reqBody != nil {
+		f.reqBody.Reset()
+		f.reqBody.Write(body)
+	} else {
+		f.reqBody = buffer.NewIoBufferBytes(body)
+		f.receiveHandler.SetRequestData(f.reqBody)
+	}
+	updateContentLength(f.reqHeaders, len(body))
+	return true
+}
+
+// setResponseBody replaces the response body, false is returned if the body is streaming
+func (f *proxyWasmFilter) setResponseBody(body []byte) bool {
+	if f.respStreaming {
+		return false
+	}
+	if f.respBody != nil {
+		f.respBody.Reset()
+		f.respBody.Write(body)
+	} else {
+		f.respBody = buffer.NewIoBufferBytes(body)
+		f.sendHandler.SetResponseData(f.respBody)
+	}
+	updateContentLength(f.respHeaders, len(body))
+	return true
+}
+
+func updateContentLength(headers api.HeaderMap, length int) {
+	if headers == nil {
+		return
+	}
+	if _, ok := headers.Get(headerContentLength); ok {
+		headers.Set(headerContentLength, strconv.Itoa(length))
+	}
+}
+
+func bufferBytes(buf buffer.IoBuffer) []byte {
+	if buf == nil {
+		return nil
+	}
+	if _, ok := buf.(types.StreamingBuffer); ok {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// OnDestroy finishes the http context, it is called by both the receiver and the sender filter chains
+func (f *proxyWasmFilter) OnDestroy() {
+	i := f.inst
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if f.destroyed {
+		return
+	}
+	f.destroyed = true
+	if f.vm == nil || f.vm != i.vm {
+		return
+	}
+	delete(i.contexts, f.id)
+	i.current = f
+	defer func() {
+		i.current = nil
+	}()
+	for _, name := range []string{exportDone, exportLog, exportDelete} {
+		if _, err := i.call(name, uint64(f.id)); err != nil {
+			i.fail(f.ctx, name, err)
+			return
+		}
+	}
+}
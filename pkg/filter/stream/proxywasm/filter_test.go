@@ -0,0 +1,531 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// the helpers assemble the wasm module for the tests
+
+const (
+	opLoop        = 0x03
+	opIf          = 0x04
+	opBr          = 0x0c
+	opEnd         = 0x0b
+	opReturn      = 0x0f
+	opCall        = 0x10
+	opDrop        = 0x1a
+	opLocalGet    = 0x20
+	opGlobalGet   = 0x23
+	opGlobalSet   = 0x24
+	opI32Load     = 0x28
+	opI32Load8U   = 0x2d
+	opI32Const    = 0x41
+	opI32Eqz      = 0x45
+	opI32Eq       = 0x46
+	opI32Add      = 0x6a
+	opUnreachable = 0x00
+	typeI32       = 0x7f
+	blockVoid     = 0x40
+)
+
+func uleb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func sleb(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func vec(items ...[]byte) []byte {
+	return cat(uleb(uint64(len(items))), cat(items...))
+}
+
+func str(s string) []byte {
+	return cat(uleb(uint64(len(s))), []byte(s))
+}
+
+func section(id byte, items ...[]byte) []byte {
+	payload := vec(items...)
+	return cat([]byte{id}, uleb(uint64(len(payload))), payload)
+}
+
+func funcType(params, results int) []byte {
+	b := append([]byte{0x60}, uleb(uint64(params))...)
+	b = append(b, bytes.Repeat([]byte{typeI32}, params)...)
+	b = append(b, uleb(uint64(results))...)
+	return append(b, bytes.Repeat([]byte{typeI32}, results)...)
+}
+
+// i32 pushes the constants
+func i32(values ...int32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = append(b, opI32Const)
+		b = append(b, sleb(int64(v))...)
+	}
+	return b
+}
+
+func call(idx byte) []byte {
+	return []byte{opCall, idx}
+}
+
+func body(instrs ...[]byte) []byte {
+	b := cat([]byte{0}, cat(instrs...), []byte{opEnd})
+	return cat(uleb(uint64(len(b))), b)
+}
+
+func importFunc(name string, typ byte) []byte {
+	return cat(str("env"), str(name), []byte{0, typ})
+}
+
+func exportFunc(name string, idx byte) []byte {
+	return cat(str(name), []byte{0, idx})
+}
+
+// the addresses of the strings in the memory
+const (
+	addrUser    = 256
+	addrWasm    = 272
+	addrValue   = 288
+	addrDenied  = 304
+	addrStarted = 320
+	addrTrap    = 352
+	addrPause   = 368
+	addrLoop    = 384
+	// the address and the size returned by the host
+	addrRetPtr  = 512
+	addrRetSize = 516
+)
+
+// the indexes of the imported functions
+const (
+	fnLog = iota
+	fnGetHeader
+	fnAddHeader
+	fnSendLocalResponse
+	fnGetBuffer
+	fnSetBuffer
+	fnSetTickPeriod
+	fnSetEffectiveContext
+	fnContinueStream
+	fnHTTPCall
+)
+
+// testModule builds a module of the abi, the value of the header x-wasm added by the module is the value
+//   - the request without x-user is denied with 403 and the body "denied"
+//   - the request with x-trap traps, the request with x-loop loops forever,
+//     and the request with x-pause is paused until the next tick
+//   - the request body is replaced by the value, and x-wasm is added to the request and the response
+//   - proxy_on_configure fails if the plugin configuration starts with "x"
+func testModule(value string) []byte {
+	getHeader := func(addr, size int32) []byte {
+		return cat(i32(mapHTTPRequestHeaders, addr, size, addrRetPtr, addrRetSize), call(fnGetHeader), []byte{opI32Eqz})
+	}
+	data := func(addr int32, s string) []byte {
+		return cat([]byte{0}, i32(addr), []byte{opEnd}, str(s))
+	}
+	global := func(init int32) []byte {
+		return cat([]byte{typeI32, 1}, i32(init), []byte{opEnd})
+	}
+	return cat([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		section(1,
+			funcType(3, 1), // 0: proxy_log, http callbacks
+			funcType(5, 1), // 1: header and buffer functions
+			funcType(8, 1), // 2: proxy_send_local_response
+			funcType(1, 1), // 3: memory allocate, tick period, effective context, continue stream
+			funcType(2, 0), // 4: context create
+			funcType(2, 1), // 5: vm start and configure
+			funcType(0, 0), // 6: abi version
+			funcType(1, 0), // 7: tick
+		),
+		section(2,
+			importFunc("proxy_log", 0),
+			importFunc("proxy_get_header_map_value", 1),
+			importFunc("proxy_add_header_map_value", 1),
+			importFunc("proxy_send_local_response", 2),
+			importFunc("proxy_get_buffer_bytes", 1),
+			importFunc("proxy_set_buffer_bytes", 1),
+			importFunc("proxy_set_tick_period_milliseconds", 3),
+			importFunc("proxy_set_effective_context", 3),
+			importFunc("proxy_continue_stream", 3),
+			// the function is not implemented by the host
+			importFunc("proxy_http_call", 1),
+		),
+		section(3, []byte{6}, []byte{3}, []byte{4}, []byte{5}, []byte{5}, []byte{0}, []byte{0}, []byte{0}, []byte{7}),
+		section(5, []byte{0x00, 1}),
+		// 0: the heap pointer, 1: the paused context
+		section(6, global(4096), global(0)),
+		section(7,
+			cat(str("memory"), []byte{2, 0}),
+			exportFunc("proxy_abi_version_0_2_1", 10),
+			exportFunc(exportMemoryAllocate, 11),
+			exportFunc(exportContextCreate, 12),
+			exportFunc(exportVMStart, 13),
+			exportFunc(exportConfigure, 14),
+			exportFunc(exportRequestHeaders, 15),
+			exportFunc(exportRequestBody, 16),
+			exportFunc(exportResponseHeaders, 17),
+			exportFunc(exportTick, 18),
+		),
+		section(10,
+			body(),
+			// the bump allocator
+			body([]byte{opGlobalGet, 0, opGlobalGet, 0, opLocalGet, 0, opI32Add, opGlobalSet, 0}),
+			body(),
+			body(
+				i32(logLevelInfo, addrStarted, 10), call(fnLog), []byte{opDrop},
+				i32(10), call(fnSetTickPeriod), []byte{opDrop},
+				i32(1),
+			),
+			body(
+				[]byte{opLocalGet, 1, opIf, blockVoid},
+				i32(bufferPluginConfiguration, 0, 1, addrRetPtr, addrRetSize), call(fnGetBuffer), []byte{opDrop},
+				i32(addrRetPtr), []byte{opI32Load, 2, 0, opI32Load8U, 0, 0}, i32('x'), []byte{opI32Eq},
+				[]byte{opIf, blockVoid}, i32(0), []byte{opReturn, opEnd},
+				[]byte{opEnd},
+				i32(1),
+			),
+			body(
+				getHeader(addrTrap, 6), []byte{opIf, blockVoid, opUnreachable, opEnd},
+				getHeader(addrLoop, 6), []byte{opIf, blockVoid, opLoop, blockVoid, opBr, 0, opEnd, opEnd},
+				getHeader(addrPause, 7), []byte{opIf, blockVoid, opLocalGet, 0, opGlobalSet, 1}, i32(actionPause), []byte{opReturn, opEnd},
+				getHeader(addrUser, 6), []byte{opIf, blockVoid},
+				i32(mapHTTPRequestHeaders, addrWasm, 6, addrValue, int32(len(value))), call(fnAddHeader), []byte{opDrop},
+				i32(actionContinue), []byte{opReturn, opEnd},
+				i32(http.StatusForbidden, 0, 0, addrDenied, 6, 0, 0, -1), call(fnSendLocalResponse), []byte{opDrop},
+				i32(actionPause),
+			),
+			body(
+				i32(bufferHTTPRequestBody, 0), []byte{opLocalGet, 1}, i32(addrValue, int32(len(value))), call(fnSetBuffer), []byte{opDrop},
+				i32(actionContinue),
+			),
+			body(
+				i32(mapHTTPResponseHeaders, addrWasm, 6, addrValue, int32(len(value))), call(fnAddHeader), []byte{opDrop},
+				i32(actionContinue),
+			),
+			body(
+				[]byte{opGlobalGet, 1, opIf, blockVoid},
+				[]byte{opGlobalGet, 1}, call(fnSetEffectiveContext), []byte{opDrop},
+				i32(streamRequest), call(fnContinueStream), []byte{opDrop},
+				i32(0), []byte{opGlobalSet, 1},
+				[]byte{opEnd},
+			),
+		),
+		section(11,
+			data(addrUser, "x-user"),
+			data(addrWasm, "x-wasm"),
+			data(addrValue, value),
+			data(addrDenied, "denied"),
+			data(addrStarted, "vm started"),
+			data(addrTrap, "x-trap"),
+			data(addrPause, "x-pause"),
+			data(addrLoop, "x-loop"),
+		),
+	)
+}
+
+func writeModule(t *testing.T, dir string, value string) string {
+	path := filepath.Join(dir, "filter.wasm")
+	if err := ioutil.WriteFile(path, testModule(value), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestManager(t *testing.T, conf map[string]interface{}) *pluginManager {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	m, err := newPluginManager(cfg)
+	if err != nil {
+		t.Fatalf("create plugin failed: %v", err)
+	}
+	return m
+}
+
+func newTestFilter(m *pluginManager) (*proxyWasmFilter, *mockStreamReceiverFilterHandler, *mockStreamSenderFilterHandler) {
+	f := newProxyWasmFilter(context.Background(), m.plugin())
+	receiveHandler := newMockHandler()
+	sendHandler := &mockStreamSenderFilterHandler{}
+	f.SetReceiveFilterHandler(receiveHandler)
+	f.SetSenderFilterHandler(sendHandler)
+	return f, receiveHandler, sendHandler
+}
+
+func TestParseConfig(t *testing.T) {
+	if _, err := parseConfig(map[string]interface{}{}); err == nil {
+		t.Error("expected error without path")
+	}
+	cfg, err := parseConfig(map[string]interface{}{
+		"path":             "filter.wasm",
+		"vm_configuration": "vm",
+		"configuration":    map[string]interface{}{"key": "value"},
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if string(cfg.vmConfig) != "vm" || string(cfg.pluginConfig) != `{"key":"value"}` {
+		t.Errorf("unexpected configurations: %s, %s", cfg.vmConfig, cfg.pluginConfig)
+	}
+	if cfg.InstanceNum <= 0 {
+		t.Errorf("expected default instance num, but got %d", cfg.InstanceNum)
+	}
+	if cfg.ExecutionLimit != defaultExecutionLimit {
+		t.Errorf("expected default execution limit, but got %d", cfg.ExecutionLimit)
+	}
+}
+
+func TestHeaderPairs(t *testing.T) {
+	headers := protocol.CommonHeader{
+		protocol.MosnHeaderMethod:         "GET",
+		protocol.MosnHeaderPathKey:        "/foo",
+		protocol.MosnHeaderQueryStringKey: "a=1",
+		"x-key":                           "value",
+	}
+	pairs, ok := decodePairs(encodePairs(headerPairs(headers)))
+	if !ok {
+		t.Fatal("decode pairs failed")
+	}
+	expected := []headerPair{{":method", "GET"}, {":path", "/foo?a=1"}, {"x-key", "value"}}
+	if len(pairs) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, pairs)
+	}
+	for i := range expected {
+		if pairs[i] != expected[i] {
+			t.Errorf("expected %v, but got %v", expected[i], pairs[i])
+		}
+	}
+	setHeader(headers, ":path", "/bar")
+	if path, _ := headers.Get(protocol.MosnHeaderPathKey); path != "/bar" {
+		t.Errorf("expected path /bar, but got %s", path)
+	}
+	if _, ok := headers.Get(protocol.MosnHeaderQueryStringKey); ok {
+		t.Error("expected the query string removed")
+	}
+	addHeader(headers, "X-Key", "value2")
+	if v, _ := headers.Get("x-key"); v != "value,value2" {
+		t.Errorf("expected the values joined, but got %s", v)
+	}
+	if _, ok := decodePairs([]byte{2, 0, 0, 0, 1, 0, 0, 0}); ok {
+		t.Error("expected malformed pairs")
+	}
+}
+
+func TestProxyWasmFilter(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "proxywasm")
+	defer os.RemoveAll(dir)
+	m := newTestManager(t, map[string]interface{}{
+		"path":         writeModule(t, dir, "hello"),
+		"root_id":      "test",
+		"instance_num": 2,
+	})
+	defer m.close()
+
+	// the request is allowed and modified
+	f, receiveHandler, sendHandler := newTestFilter(m)
+	headers := protocol.CommonHeader{"x-user": "foo", "Content-Length": "3"}
+	if status := f.OnReceive(context.Background(), headers, buffer.NewIoBufferString("abc"), nil); status != api.StreamFilterContinue {
+		t.Fatalf("expected continue, but got %v", status)
+	}
+	if v, _ := headers.Get("x-wasm"); v != "hello" {
+		t.Errorf("expected request header added, but got %s", v)
+	}
+	if receiveHandler.data != nil {
+		t.Error("expected the body replaced in place")
+	}
+	if f.reqBody.String() != "hello" {
+		t.Errorf("expected request body replaced, but got %s", f.reqBody.String())
+	}
+	if v, _ := headers.Get("Content-Length"); v != "5" {
+		t.Errorf("expected content length updated, but got %s", v)
+	}
+	respHeaders := protocol.CommonHeader{}
+	if status := f.Append(context.Background(), respHeaders, nil, nil); status != api.StreamFilterContinue {
+		t.Fatalf("expected continue, but got %v", status)
+	}
+	if v, _ := respHeaders.Get("x-wasm"); v != "hello" {
+		t.Errorf("expected response header added, but got %s", v)
+	}
+	if sendHandler.data != nil {
+		t.Error("expected the response body not changed")
+	}
+	f.OnDestroy()
+	f.OnDestroy()
+	if len(f.inst.contexts) != 0 {
+		t.Errorf("expected the context deleted, but got %d", len(f.inst.contexts))
+	}
+
+	// the request is denied with the local response
+	f, receiveHandler, _ = newTestFilter(m)
+	if status := f.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil); status != api.StreamFilterStop {
+		t.Fatalf("expected stop, but got %v", status)
+	}
+	if receiveHandler.hijackCode != http.StatusForbidden || receiveHandler.directBody != "denied" {
+		t.Errorf("expected local response, but got %d %s", receiveHandler.hijackCode, receiveHandler.directBody)
+	}
+	f.OnDestroy()
+}
+
+func TestProxyWasmPause(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "proxywasm")
+	defer os.RemoveAll(dir)
+	m := newTestManager(t, map[string]interface{}{
+		"path":         writeModule(t, dir, "hello"),
+		"instance_num": 1,
+	})
+	defer m.close()
+
+	f, receiveHandler, _ := newTestFilter(m)
+	if status := f.OnReceive(context.Background(), protocol.CommonHeader{"x-pause": "1"}, nil, nil); status != types.StreamFilterPause {
+		t.Fatalf("expected pause, but got %v", status)
+	}
+	// the request is continued on the tick
+	receiveHandler.wait(t)
+	f.OnDestroy()
+}
+
+func TestProxyWasmTrap(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "proxywasm")
+	defer os.RemoveAll(dir)
+	for _, failOpen := range []bool{false, true} {
+		testProxyWasmTrap(t, dir, "x-trap", failOpen)
+	}
+}
+
+func TestProxyWasmExecutionLimit(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "proxywasm")
+	defer os.RemoveAll(dir)
+	for _, failOpen := range []bool{false, true} {
+		testProxyWasmTrap(t, dir, "x-loop", failOpen)
+	}
+}
+
+// testProxyWasmTrap checks the request with the header fails the instance, and the instance is recreated
+func testProxyWasmTrap(t *testing.T, dir string, header string, failOpen bool) {
+	m := newTestManager(t, map[string]interface{}{
+		"path":            writeModule(t, dir, "hello"),
+		"instance_num":    1,
+		"fail_open":       failOpen,
+		"execution_limit": 10000,
+	})
+	defer m.close()
+	trapped := m.plugin().stats.trap.Count()
+	f, receiveHandler, _ := newTestFilter(m)
+	status := f.OnReceive(context.Background(), protocol.CommonHeader{header: "1"}, nil, nil)
+	if failOpen && status != api.StreamFilterContinue {
+		t.Errorf("expected continue, but got %v", status)
+	}
+	if !failOpen && (status != api.StreamFilterStop || receiveHandler.hijackCode != http.StatusServiceUnavailable) {
+		t.Errorf("expected 503, but got %v %d", status, receiveHandler.hijackCode)
+	}
+	if n := m.plugin().stats.trap.Count() - trapped; n != 1 {
+		t.Errorf("expected the trap counted, but got %d", n)
+	}
+	// the context is lost
+	if status := f.Append(context.Background(), protocol.CommonHeader{}, nil, nil); status != api.StreamFilterContinue {
+		t.Errorf("expected continue, but got %v", status)
+	}
+	f.OnDestroy()
+
+	// the instance is recreated for the new streams
+	f, _, _ = newTestFilter(m)
+	headers := protocol.CommonHeader{"x-user": "foo"}
+	if status := f.OnReceive(context.Background(), headers, nil, nil); status != api.StreamFilterContinue {
+		t.Errorf("expected continue, but got %v", status)
+	}
+	if v, _ := headers.Get("x-wasm"); v != "hello" {
+		t.Errorf("expected request header added, but got %s", v)
+	}
+	f.OnDestroy()
+}
+
+func TestProxyWasmReload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "proxywasm")
+	defer os.RemoveAll(dir)
+	path := writeModule(t, dir, "hello")
+	cfg, _ := parseConfig(map[string]interface{}{
+		"path":          path,
+		"configuration": "x",
+	})
+	if _, err := newPluginManager(cfg); err == nil {
+		t.Error("expected configure error")
+	}
+	m := newTestManager(t, map[string]interface{}{
+		"path":            path,
+		"instance_num":    1,
+		"reload_interval": "1h",
+	})
+	defer m.close()
+	old, _, _ := newTestFilter(m)
+
+	// the invalid module is not loaded
+	ioutil.WriteFile(path, []byte("invalid"), 0644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	m.checkReload()
+	writeModule(t, dir, "world")
+	os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second))
+	m.checkReload()
+
+	f, _, _ := newTestFilter(m)
+	for filter, expected := range map[*proxyWasmFilter]string{old: "hello", f: "world"} {
+		headers := protocol.CommonHeader{"x-user": "foo"}
+		filter.OnReceive(context.Background(), headers, nil, nil)
+		if v, _ := headers.Get("x-wasm"); v != expected {
+			t.Errorf("expected %s, but got %s", expected, v)
+		}
+		filter.OnDestroy()
+	}
+}
@@ -0,0 +1,160 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"strings"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+)
+
+// the pseudo headers are stored in the internal headers of mosn,
+// :path is the combination of the path and the query string
+var pseudoHeaders = map[string]string{
+	":method":    protocol.MosnHeaderMethod,
+	":authority": protocol.MosnHeaderHostKey,
+	":status":    types.HeaderStatus,
+}
+
+// internalHeaders are hidden from the module, they are visible as the pseudo headers
+var internalHeaders = map[string]bool{
+	protocol.MosnHeaderMethod:         true,
+	protocol.MosnHeaderHostKey:        true,
+	protocol.MosnHeaderPathKey:        true,
+	protocol.MosnHeaderQueryStringKey: true,
+	types.HeaderStatus:                true,
+}
+
+func getHeader(headers api.HeaderMap, key string) (string, bool) {
+	key = strings.ToLower(key)
+	if key == ":path" {
+		path, ok := headers.Get(protocol.MosnHeaderPathKey)
+		if !ok {
+			return "", false
+		}
+		if query, _ := headers.Get(protocol.MosnHeaderQueryStringKey); query != "" {
+			path = path + "?" + query
+		}
+		return path, true
+	}
+	if internal, ok := pseudoHeaders[key]; ok {
+		return headers.Get(internal)
+	}
+	if internalHeaders[key] {
+		return "", false
+	}
+	return headers.Get(key)
+}
+
+func setHeader(headers api.HeaderMap, key, value string) {
+	key = strings.ToLower(key)
+	if key == ":path" {
+		path, query := value, ""
+		if i := strings.IndexByte(value, '?'); i >= 0 {
+			path, query = value[:i], value[i+1:]
+		}
+		headers.Set(protocol.MosnHeaderPathKey, path)
+		if query != "" {
+			headers.Set(protocol.MosnHeaderQueryStringKey, query)
+		} else {
+			headers.Del(protocol.MosnHeaderQueryStringKey)
+		}
+		return
+	}
+	if internal, ok := pseudoHeaders[key]; ok {
+		headers.Set(internal, value)
+		return
+	}
+	if !internalHeaders[key] {
+		headers.Set(key, value)
+	}
+}
+
+func addHeader(headers api.HeaderMap, key, value string) {
+	key = strings.ToLower(key)
+	if strings.HasPrefix(key, ":") || internalHeaders[key] {
+		// the pseudo headers have only one value
+		setHeader(headers, key, value)
+		return
+	}
+	old, ok := headers.Get(key)
+	if !ok {
+		headers.Set(key, value)
+		return
+	}
+	switch headers.(type) {
+	case http.RequestHeader, http.ResponseHeader, *http2.HeaderMap:
+		headers.Add(key, value)
+	default:
+		// the other header maps can not hold multiple values
+		headers.Set(key, old+","+value)
+	}
+}
+
+func removeHeader(headers api.HeaderMap, key string) {
+	key = strings.ToLower(key)
+	if key == ":path" {
+		headers.Del(protocol.MosnHeaderPathKey)
+		headers.Del(protocol.MosnHeaderQueryStringKey)
+		return
+	}
+	if internal, ok := pseudoHeaders[key]; ok {
+		headers.Del(internal)
+		return
+	}
+	if !internalHeaders[key] {
+		headers.Del(key)
+	}
+}
+
+// headerPairs returns the pseudo headers followed by the other headers
+func headerPairs(headers api.HeaderMap) []headerPair {
+	var pairs []headerPair
+	for _, key := range []string{":method", ":path", ":authority", ":status"} {
+		if value, ok := getHeader(headers, key); ok {
+			pairs = append(pairs, headerPair{key, value})
+		}
+	}
+	headers.Range(func(key, value string) bool {
+		if !internalHeaders[strings.ToLower(key)] {
+			pairs = append(pairs, headerPair{key, value})
+		}
+		return true
+	})
+	return pairs
+}
+
+// setHeaderPairs replaces all the headers with the pairs
+func setHeaderPairs(headers api.HeaderMap, pairs []headerPair) {
+	var keys []string
+	headers.Range(func(key, value string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	for _, key := range keys {
+		if !internalHeaders[strings.ToLower(key)] {
+			headers.Del(key)
+		}
+	}
+	for _, p := range pairs {
+		addHeader(headers, p.key, p.value)
+	}
+}
@@ -0,0 +1,748 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/mosn/pkg/wasm"
+)
+
+const (
+	moduleEnv = "env"
+	// the modules of wasi, they are imported by the modules compiled for wasi
+	moduleWasi         = "wasi_snapshot_preview1"
+	moduleWasiUnstable = "wasi_unstable"
+
+	// the errno of wasi
+	wasiErrnoSuccess = 0
+	wasiErrnoBadf    = 8
+	wasiErrnoNosys   = 52
+)
+
+// hostFunc is a function imported by the module, it returns the first result
+type hostFunc func(i *instance, vm *wasm.Instance, args []uint64) uint64
+
+type hostFuncDef struct {
+	params  []wasm.ValueType
+	results []wasm.ValueType
+	fn      hostFunc
+}
+
+func i32s(n int) []wasm.ValueType {
+	t := make([]wasm.ValueType, n)
+	for i := range t {
+		t[i] = wasm.ValueTypeI32
+	}
+	return t
+}
+
+var i32Result = []wasm.ValueType{wasm.ValueTypeI32}
+
+// proxyFunc defines the function of the abi, all the parameters are i32 and it returns the status
+func proxyFunc(params int, fn func(i *instance, vm *wasm.Instance, args []uint64) status) hostFuncDef {
+	return hostFuncDef{
+		params:  i32s(params),
+		results: i32Result,
+		fn: func(i *instance, vm *wasm.Instance, args []uint64) uint64 {
+			return uint64(fn(i, vm, args))
+		},
+	}
+}
+
+// proxyFuncs are the functions of the proxy-wasm abi imported from the env module
+var proxyFuncs = map[string]hostFuncDef{
+	"proxy_log":                          proxyFunc(3, (*instance).proxyLog),
+	"proxy_get_log_level":                proxyFunc(1, (*instance).proxyGetLogLevel),
+	"proxy_get_current_time_nanoseconds": proxyFunc(1, (*instance).proxyGetCurrentTime),
+	"proxy_set_tick_period_milliseconds": proxyFunc(1, (*instance).proxySetTickPeriod),
+	"proxy_get_property":                 proxyFunc(4, (*instance).proxyGetProperty),
+	"proxy_set_property":                 proxyFunc(4, (*instance).proxySetProperty),
+	"proxy_get_buffer_bytes":             proxyFunc(5, (*instance).proxyGetBufferBytes),
+	"proxy_get_buffer_status":            proxyFunc(3, (*instance).proxyGetBufferStatus),
+	"proxy_set_buffer_bytes":             proxyFunc(5, (*instance).proxySetBufferBytes),
+	"proxy_get_header_map_pairs":         proxyFunc(3, (*instance).proxyGetHeaderMapPairs),
+	"proxy_set_header_map_pairs":         proxyFunc(3, (*instance).proxySetHeaderMapPairs),
+	"proxy_get_header_map_value":         proxyFunc(5, (*instance).proxyGetHeaderMapValue),
+	"proxy_add_header_map_value":         proxyFunc(5, (*instance).proxyAddHeaderMapValue),
+	"proxy_replace_header_map_value":     proxyFunc(5, (*instance).proxyReplaceHeaderMapValue),
+	"proxy_remove_header_map_value":      proxyFunc(3, (*instance).proxyRemoveHeaderMapValue),
+	"proxy_get_header_map_size":          proxyFunc(2, (*instance).proxyGetHeaderMapSize),
+	"proxy_continue_stream":              proxyFunc(1, (*instance).proxyContinueStream),
+	"proxy_continue_request":             proxyFunc(0, (*instance).proxyContinueRequest),
+	"proxy_continue_response":            proxyFunc(0, (*instance).proxyContinueResponse),
+	"proxy_send_local_response":          proxyFunc(8, (*instance).proxySendLocalResponse),
+	"proxy_set_effective_context":        proxyFunc(1, (*instance).proxySetEffectiveContext),
+	"proxy_done":                         proxyFunc(0, (*instance).proxyDone),
+	"proxy_define_metric":                proxyFunc(4, (*instance).proxyDefineMetric),
+	"proxy_increment_metric": {
+		params:  []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI64},
+		results: i32Result,
+		fn:      (*instance).proxyIncrementMetric,
+	},
+	"proxy_record_metric": {
+		params:  []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI64},
+		results: i32Result,
+		fn:      (*instance).proxyRecordMetric,
+	},
+	"proxy_get_metric":      proxyFunc(2, (*instance).proxyGetMetric),
+	"proxy_get_shared_data": proxyFunc(5, (*instance).proxyGetSharedData),
+	"proxy_set_shared_data": proxyFunc(5, (*instance).proxySetSharedData),
+}
+
+// wasiFuncs are the functions of wasi that are used by the runtimes of the languages,
+// the file system and the sockets are not supported
+var wasiFuncs = map[string]hostFuncDef{
+	"fd_write": {params: i32s(4), results: i32Result, fn: (*instance).wasiFdWrite},
+	"proc_exit": {params: i32s(1), fn: func(i *instance, vm *wasm.Instance, args []uint64) uint64 {
+		panic(&wasm.Trap{Reason: fmt.Sprintf("proc_exit(%d)", uint32(args[0]))})
+	}},
+	"clock_time_get": {
+		params:  []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI64, wasm.ValueTypeI32},
+		results: i32Result,
+		fn:      (*instance).wasiClockTimeGet,
+	},
+	"random_get":        {params: i32s(2), results: i32Result, fn: (*instance).wasiRandomGet},
+	"args_sizes_get":    {params: i32s(2), results: i32Result, fn: wasiEmptySizes},
+	"args_get":          {params: i32s(2), results: i32Result, fn: wasiSuccess},
+	"environ_sizes_get": {params: i32s(2), results: i32Result, fn: wasiEmptySizes},
+	"environ_get":       {params: i32s(2), results: i32Result, fn: wasiSuccess},
+	"sched_yield":       {results: i32Result, fn: wasiSuccess},
+}
+
+// imports creates the functions imported by the module, the functions not implemented by the host
+// return the status of unimplemented
+func (i *instance) imports() wasm.Imports {
+	imports := wasm.Imports{}
+	for _, imp := range i.plugin.module.Imports() {
+		var def hostFuncDef
+		var ok bool
+		switch imp.Module {
+		case moduleEnv:
+			def, ok = proxyFuncs[imp.Name]
+		case moduleWasi, moduleWasiUnstable:
+			def, ok = wasiFuncs[imp.Name]
+		}
+		typ := &wasm.FuncType{Params: def.params, Results: def.results}
+		if !ok {
+			typ = i.plugin.module.ImportType(imp)
+			def.fn = unimplemented(imp.Module)
+		}
+		fn := def.fn
+		if imports[imp.Module] == nil {
+			imports[imp.Module] = map[string]*wasm.HostFunction{}
+		}
+		imports[imp.Module][imp.Name] = &wasm.HostFunction{
+			Type: typ,
+			Func: func(vm *wasm.Instance, stack []uint64) {
+				result := fn(i, vm, stack)
+				if len(typ.Results) > 0 {
+					stack[0] = result
+				}
+			},
+		}
+	}
+	return imports
+}
+
+func unimplemented(module string) hostFunc {
+	code := uint64(statusUnimplemented)
+	if module != moduleEnv {
+		code = wasiErrnoNosys
+	}
+	return func(i *instance, vm *wasm.Instance, args []uint64) uint64 {
+		for j := range args {
+			args[j] = 0
+		}
+		return code
+	}
+}
+
+// allocate allocates the memory in the module
+func (i *instance) allocate(vm *wasm.Instance, size int) (uint32, bool) {
+	name := exportMemoryAllocate
+	if !vm.HasFunction(name) {
+		name = exportMalloc
+	}
+	results, err := vm.Call(name, uint64(size))
+	if err != nil || len(results) != 1 || uint32(results[0]) == 0 {
+		log.Proxy.Errorf(i.context(), "[stream filter][proxywasm] allocate memory failed: %v", err)
+		return 0, false
+	}
+	return uint32(results[0]), true
+}
+
+// returnBytes copies the data to the memory allocated in the module, and writes the address and the size
+func (i *instance) returnBytes(vm *wasm.Instance, data []byte, ptrPtr, sizePtr uint32) status {
+	var ptr uint32
+	if len(data) > 0 {
+		var ok bool
+		if ptr, ok = i.allocate(vm, len(data)); !ok {
+			return statusInternalFailure
+		}
+		if !vm.WriteBytes(ptr, data) {
+			return statusInvalidMemoryAccess
+		}
+	}
+	if !vm.WriteUint32(ptrPtr, ptr) || !vm.WriteUint32(sizePtr, uint32(len(data))) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+func readString(vm *wasm.Instance, ptr, size uint64) (string, bool) {
+	b, ok := vm.ReadBytes(uint32(ptr), uint32(size))
+	return string(b), ok
+}
+
+func (i *instance) proxyLog(vm *wasm.Instance, args []uint64) status {
+	msg, ok := readString(vm, args[1], args[2])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	ctx := i.context()
+	prefix := i.plugin.logPrefix
+	switch args[0] {
+	case logLevelTrace, logLevelDebug:
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "%s %s", prefix, msg)
+		}
+	case logLevelInfo:
+		log.Proxy.Infof(ctx, "%s %s", prefix, msg)
+	case logLevelWarn:
+		log.Proxy.Warnf(ctx, "%s %s", prefix, msg)
+	default:
+		log.Proxy.Errorf(ctx, "%s %s", prefix, msg)
+	}
+	return statusOK
+}
+
+func (i *instance) proxyGetLogLevel(vm *wasm.Instance, args []uint64) status {
+	var level uint32
+	switch log.Proxy.GetLogLevel() {
+	case log.TRACE, log.RAW:
+		level = logLevelTrace
+	case log.DEBUG:
+		level = logLevelDebug
+	case log.INFO:
+		level = logLevelInfo
+	case log.WARN:
+		level = logLevelWarn
+	case log.ERROR:
+		level = logLevelError
+	default:
+		level = logLevelCritical
+	}
+	if !vm.WriteUint32(uint32(args[0]), level) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+func (i *instance) proxyGetCurrentTime(vm *wasm.Instance, args []uint64) status {
+	if !vm.WriteUint64(uint32(args[0]), uint64(time.Now().UnixNano())) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+func (i *instance) proxySetTickPeriod(vm *wasm.Instance, args []uint64) status {
+	i.setTickPeriod(time.Duration(uint32(args[0])) * time.Millisecond)
+	return statusOK
+}
+
+// property returns the value of the property, the well-known attributes of envoy are supported,
+// and the other paths are joined by "_" as the name of the variable, such as request_header_x.
+func (i *instance) property(path []string) (string, bool) {
+	name := strings.Join(path, ".")
+	cfg := i.plugin.cfg
+	switch name {
+	case "plugin_name", "plugin_root_id":
+		return cfg.RootID, true
+	case "plugin_vm_id":
+		return cfg.VMID, true
+	}
+	f := i.current
+	if f == nil {
+		return "", false
+	}
+	if f.reqHeaders != nil {
+		switch name {
+		case "request.path":
+			return getHeader(f.reqHeaders, ":path")
+		case "request.url_path":
+			return f.reqHeaders.Get(protocol.MosnHeaderPathKey)
+		case "request.query":
+			return f.reqHeaders.Get(protocol.MosnHeaderQueryStringKey)
+		case "request.host":
+			return getHeader(f.reqHeaders, ":authority")
+		case "request.method":
+			return getHeader(f.reqHeaders, ":method")
+		}
+	}
+	if v, ok := propertyVariables[name]; ok {
+		name = v
+	} else {
+		name = strings.Join(path, "_")
+	}
+	value, err := variable.GetVariableValue(f.ctx, name)
+	return value, err == nil
+}
+
+var propertyVariables = map[string]string{
+	"request.protocol":    types.VarProtocol,
+	"response.code":       types.VarResponseCode,
+	"source.address":      types.VarDownstreamRemoteAddress,
+	"destination.address": types.VarDownstreamLocalAddress,
+	"upstream.address":    types.VarUpstreamHost,
+}
+
+// propertyPath splits the path of the property, the segments are separated by the null character
+func propertyPath(vm *wasm.Instance, ptr, size uint64) ([]string, bool) {
+	path, ok := readString(vm, ptr, size)
+	if !ok {
+		return nil, false
+	}
+	return strings.Split(strings.TrimRight(path, "\x00"), "\x00"), true
+}
+
+func (i *instance) proxyGetProperty(vm *wasm.Instance, args []uint64) status {
+	path, ok := propertyPath(vm, args[0], args[1])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	value, ok := i.property(path)
+	if !ok {
+		return statusNotFound
+	}
+	return i.returnBytes(vm, []byte(value), uint32(args[2]), uint32(args[3]))
+}
+
+func (i *instance) proxySetProperty(vm *wasm.Instance, args []uint64) status {
+	path, ok := propertyPath(vm, args[0], args[1])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	value, ok := readString(vm, args[2], args[3])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	if i.current == nil {
+		return statusNotFound
+	}
+	if err := variable.SetVariableValue(i.current.ctx, strings.Join(path, "_"), value); err != nil {
+		return statusNotFound
+	}
+	return statusOK
+}
+
+// buffer returns the content of the buffer
+func (i *instance) buffer(typ uint64) ([]byte, bool) {
+	switch typ {
+	case bufferVMConfiguration:
+		return i.plugin.cfg.vmConfig, true
+	case bufferPluginConfiguration:
+		return i.plugin.cfg.pluginConfig, true
+	}
+	f := i.current
+	if f == nil {
+		return nil, false
+	}
+	switch typ {
+	case bufferHTTPRequestBody:
+		return bufferBytes(f.reqBody), true
+	case bufferHTTPResponseBody:
+		return bufferBytes(f.respBody), true
+	}
+	return nil, false
+}
+
+func (i *instance) proxyGetBufferBytes(vm *wasm.Instance, args []uint64) status {
+	data, ok := i.buffer(args[0])
+	if !ok {
+		return statusNotFound
+	}
+	start, max := uint32(args[1]), uint32(args[2])
+	if int(start) > len(data) {
+		return statusBadArgument
+	}
+	data = data[start:]
+	if int(max) < len(data) {
+		data = data[:max]
+	}
+	return i.returnBytes(vm, data, uint32(args[3]), uint32(args[4]))
+}
+
+func (i *instance) proxyGetBufferStatus(vm *wasm.Instance, args []uint64) status {
+	data, ok := i.buffer(args[0])
+	if !ok {
+		return statusNotFound
+	}
+	if !vm.WriteUint32(uint32(args[1]), uint32(len(data))) || !vm.WriteUint32(uint32(args[2]), 0) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+// proxySetBufferBytes replaces the bytes from the start with the data, the size is the count of the replaced bytes
+func (i *instance) proxySetBufferBytes(vm *wasm.Instance, args []uint64) status {
+	f := i.current
+	if f == nil || (args[0] != bufferHTTPRequestBody && args[0] != bufferHTTPResponseBody) {
+		return statusNotFound
+	}
+	value, ok := vm.ReadBytes(uint32(args[3]), uint32(args[4]))
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	data, _ := i.buffer(args[0])
+	start, size := uint64(uint32(args[1])), uint64(uint32(args[2]))
+	if start > uint64(len(data)) {
+		return statusBadArgument
+	}
+	end := start + size
+	if end > uint64(len(data)) {
+		end = uint64(len(data))
+	}
+	body := make([]byte, 0, len(data)-int(end-start)+len(value))
+	body = append(body, data[:start]...)
+	body = append(body, value...)
+	body = append(body, data[end:]...)
+	if args[0] == bufferHTTPRequestBody {
+		ok = f.setRequestBody(body)
+	} else {
+		ok = f.setResponseBody(body)
+	}
+	if !ok {
+		return statusBadArgument
+	}
+	return statusOK
+}
+
+// headerMap returns the header map of the current stream, nil is returned if it does not exist
+func (i *instance) headerMap(typ uint64) api.HeaderMap {
+	f := i.current
+	if f == nil {
+		return nil
+	}
+	switch typ {
+	case mapHTTPRequestHeaders:
+		return f.reqHeaders
+	case mapHTTPRequestTrailers:
+		return f.reqTrailers
+	case mapHTTPResponseHeaders:
+		return f.respHeaders
+	case mapHTTPResponseTrailers:
+		return f.respTrailers
+	}
+	return nil
+}
+
+func (i *instance) proxyGetHeaderMapPairs(vm *wasm.Instance, args []uint64) status {
+	var pairs []headerPair
+	if h := i.headerMap(args[0]); h != nil {
+		pairs = headerPairs(h)
+	}
+	return i.returnBytes(vm, encodePairs(pairs), uint32(args[1]), uint32(args[2]))
+}
+
+func (i *instance) proxySetHeaderMapPairs(vm *wasm.Instance, args []uint64) status {
+	h := i.headerMap(args[0])
+	if h == nil {
+		return statusNotFound
+	}
+	data, ok := vm.ReadBytes(uint32(args[1]), uint32(args[2]))
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	pairs, ok := decodePairs(data)
+	if !ok {
+		return statusSerializationFailed
+	}
+	setHeaderPairs(h, pairs)
+	return statusOK
+}
+
+func (i *instance) proxyGetHeaderMapValue(vm *wasm.Instance, args []uint64) status {
+	key, ok := readString(vm, args[1], args[2])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	h := i.headerMap(args[0])
+	if h == nil {
+		return statusNotFound
+	}
+	value, ok := getHeader(h, key)
+	if !ok {
+		return statusNotFound
+	}
+	return i.returnBytes(vm, []byte(value), uint32(args[3]), uint32(args[4]))
+}
+
+// headerKeyValue reads the key and the value of the header functions
+func (i *instance) headerKeyValue(vm *wasm.Instance, args []uint64) (api.HeaderMap, string, string, status) {
+	key, ok := readString(vm, args[1], args[2])
+	if !ok {
+		return nil, "", "", statusInvalidMemoryAccess
+	}
+	value, ok := readString(vm, args[3], args[4])
+	if !ok {
+		return nil, "", "", statusInvalidMemoryAccess
+	}
+	h := i.headerMap(args[0])
+	if h == nil {
+		return nil, "", "", statusNotFound
+	}
+	return h, key, value, statusOK
+}
+
+func (i *instance) proxyAddHeaderMapValue(vm *wasm.Instance, args []uint64) status {
+	h, key, value, s := i.headerKeyValue(vm, args)
+	if s == statusOK {
+		addHeader(h, key, value)
+	}
+	return s
+}
+
+func (i *instance) proxyReplaceHeaderMapValue(vm *wasm.Instance, args []uint64) status {
+	h, key, value, s := i.headerKeyValue(vm, args)
+	if s == statusOK {
+		setHeader(h, key, value)
+	}
+	return s
+}
+
+func (i *instance) proxyRemoveHeaderMapValue(vm *wasm.Instance, args []uint64) status {
+	key, ok := readString(vm, args[1], args[2])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	h := i.headerMap(args[0])
+	if h == nil {
+		return statusNotFound
+	}
+	removeHeader(h, key)
+	return statusOK
+}
+
+// proxyGetHeaderMapSize returns the size of the serialized header map
+func (i *instance) proxyGetHeaderMapSize(vm *wasm.Instance, args []uint64) status {
+	h := i.headerMap(args[0])
+	if h == nil {
+		return statusNotFound
+	}
+	if !vm.WriteUint32(uint32(args[1]), uint32(len(encodePairs(headerPairs(h))))) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+func (i *instance) proxyContinueStream(vm *wasm.Instance, args []uint64) status {
+	f := i.current
+	if f == nil {
+		return statusNotFound
+	}
+	switch args[0] {
+	case streamRequest:
+		f.continueRequest()
+	case streamResponse:
+		// the response is never paused
+	default:
+		return statusBadArgument
+	}
+	return statusOK
+}
+
+func (i *instance) proxyContinueRequest(vm *wasm.Instance, args []uint64) status {
+	return i.proxyContinueStream(vm, []uint64{streamRequest})
+}
+
+func (i *instance) proxyContinueResponse(vm *wasm.Instance, args []uint64) status {
+	return i.proxyContinueStream(vm, []uint64{streamResponse})
+}
+
+func (i *instance) proxySendLocalResponse(vm *wasm.Instance, args []uint64) status {
+	f := i.current
+	if f == nil {
+		return statusNotFound
+	}
+	body, ok := vm.ReadBytes(uint32(args[3]), uint32(args[4]))
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	data, ok := vm.ReadBytes(uint32(args[5]), uint32(args[6]))
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	pairs, ok := decodePairs(data)
+	if !ok {
+		return statusSerializationFailed
+	}
+	f.sendLocalResponse(int(uint32(args[0])), append([]byte(nil), body...), pairs)
+	return statusOK
+}
+
+func (i *instance) proxySetEffectiveContext(vm *wasm.Instance, args []uint64) status {
+	id := uint32(args[0])
+	if id == rootContextID {
+		i.current = nil
+		return statusOK
+	}
+	f, ok := i.contexts[id]
+	if !ok {
+		return statusBadArgument
+	}
+	i.current = f
+	return statusOK
+}
+
+func (i *instance) proxyDone(vm *wasm.Instance, args []uint64) status {
+	return statusOK
+}
+
+func (i *instance) proxyDefineMetric(vm *wasm.Instance, args []uint64) status {
+	name, ok := readString(vm, args[1], args[2])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	id, ok := i.plugin.metrics.define(uint32(args[0]), name)
+	if !ok {
+		return statusBadArgument
+	}
+	if !vm.WriteUint32(uint32(args[3]), id) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+func (i *instance) proxyIncrementMetric(vm *wasm.Instance, args []uint64) uint64 {
+	if !i.plugin.metrics.increment(uint32(args[0]), int64(args[1])) {
+		return uint64(statusNotFound)
+	}
+	return uint64(statusOK)
+}
+
+func (i *instance) proxyRecordMetric(vm *wasm.Instance, args []uint64) uint64 {
+	if !i.plugin.metrics.record(uint32(args[0]), int64(args[1])) {
+		return uint64(statusNotFound)
+	}
+	return uint64(statusOK)
+}
+
+func (i *instance) proxyGetMetric(vm *wasm.Instance, args []uint64) status {
+	value, ok := i.plugin.metrics.get(uint32(args[0]))
+	if !ok {
+		return statusNotFound
+	}
+	if !vm.WriteUint64(uint32(args[1]), uint64(value)) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+func (i *instance) proxyGetSharedData(vm *wasm.Instance, args []uint64) status {
+	key, ok := readString(vm, args[0], args[1])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	value, cas, ok := getSharedData(i.plugin.cfg.VMID, key)
+	if !ok {
+		return statusNotFound
+	}
+	if s := i.returnBytes(vm, value, uint32(args[2]), uint32(args[3])); s != statusOK {
+		return s
+	}
+	if !vm.WriteUint32(uint32(args[4]), cas) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+func (i *instance) proxySetSharedData(vm *wasm.Instance, args []uint64) status {
+	key, ok := readString(vm, args[0], args[1])
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	value, ok := vm.ReadBytes(uint32(args[2]), uint32(args[3]))
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	if !setSharedData(i.plugin.cfg.VMID, key, value, uint32(args[4])) {
+		return statusCasMismatch
+	}
+	return statusOK
+}
+
+// wasiFdWrite writes the stdout and the stderr to the log
+func (i *instance) wasiFdWrite(vm *wasm.Instance, args []uint64) uint64 {
+	fd, iovs, n := uint32(args[0]), uint32(args[1]), uint32(args[2])
+	if fd != 1 && fd != 2 {
+		return wasiErrnoBadf
+	}
+	var msg []byte
+	for j := uint32(0); j < n; j++ {
+		ptr, ok1 := vm.ReadUint32(iovs + j*8)
+		size, ok2 := vm.ReadUint32(iovs + j*8 + 4)
+		b, ok3 := vm.ReadBytes(ptr, size)
+		if !ok1 || !ok2 || !ok3 {
+			return wasiErrnoBadf
+		}
+		msg = append(msg, b...)
+	}
+	if s := strings.TrimRight(string(msg), "\n"); s != "" {
+		log.Proxy.Infof(i.context(), "%s %s", i.plugin.logPrefix, s)
+	}
+	if !vm.WriteUint32(uint32(args[3]), uint32(len(msg))) {
+		return wasiErrnoBadf
+	}
+	return wasiErrnoSuccess
+}
+
+func (i *instance) wasiClockTimeGet(vm *wasm.Instance, args []uint64) uint64 {
+	if !vm.WriteUint64(uint32(args[2]), uint64(time.Now().UnixNano())) {
+		return wasiErrnoBadf
+	}
+	return wasiErrnoSuccess
+}
+
+func (i *instance) wasiRandomGet(vm *wasm.Instance, args []uint64) uint64 {
+	b, ok := vm.ReadBytes(uint32(args[0]), uint32(args[1]))
+	if !ok {
+		return wasiErrnoBadf
+	}
+	rand.Read(b)
+	return wasiErrnoSuccess
+}
+
+// wasiEmptySizes returns no arguments and no environment variables
+func wasiEmptySizes(i *instance, vm *wasm.Instance, args []uint64) uint64 {
+	if !vm.WriteUint32(uint32(args[0]), 0) || !vm.WriteUint32(uint32(args[1]), 0) {
+		return wasiErrnoBadf
+	}
+	return wasiErrnoSuccess
+}
+
+func wasiSuccess(i *instance, vm *wasm.Instance, args []uint64) uint64 {
+	return wasiErrnoSuccess
+}
@@ -0,0 +1,223 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/wasm"
+	"mosn.io/pkg/utils"
+)
+
+// rootContextID is the id of the root context of the plugin in each instance
+const rootContextID = 1
+
+// instance is an instance of the module, the calls to an instance are serialized by the lock,
+// so the streams dispatched to the same instance are processed one by one.
+type instance struct {
+	plugin *plugin
+
+	mux sync.Mutex
+	// vm is recreated if the module traps, the contexts in the previous vm are lost
+	vm            *wasm.Instance
+	nextContextID uint32
+	contexts      map[uint32]*proxyWasmFilter
+	// current is the context of the running callback, nil means the root context
+	current *proxyWasmFilter
+	// stopTick stops the timer of proxy_on_tick
+	stopTick chan struct{}
+}
+
+func newInstance(p *plugin) (*instance, error) {
+	i := &instance{
+		plugin: p,
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if err := i.start(); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// start creates the vm and starts the root context, it is called with the lock held
+func (i *instance) start() error {
+	vm, err := i.plugin.module.InstantiateWithLimit(i.imports(), i.plugin.cfg.ExecutionLimit)
+	if err != nil {
+		return err
+	}
+	i.vm = vm
+	i.nextContextID = rootContextID + 1
+	i.contexts = make(map[uint32]*proxyWasmFilter)
+	i.current = nil
+	if err := i.init(); err != nil {
+		i.vm = nil
+		i.setTickPeriod(0)
+		return err
+	}
+	return nil
+}
+
+func (i *instance) init() error {
+	// the wasi reactors are initialized by _initialize, the commands are initialized by _start
+	for _, name := range []string{exportInitialize, exportStart} {
+		if i.vm.HasFunction(name) {
+			if _, err := i.vm.Call(name); err != nil {
+				return fmt.Errorf("%s failed: %v", name, err)
+			}
+			break
+		}
+	}
+	if _, err := i.call(exportContextCreate, rootContextID, 0); err != nil {
+		return err
+	}
+	cfg := i.plugin.cfg
+	if i.vm.HasFunction(exportVMStart) {
+		ok, err := i.call(exportVMStart, rootContextID, uint64(len(cfg.vmConfig)))
+		if err != nil {
+			return err
+		}
+		if ok == 0 {
+			return errors.New("proxy_on_vm_start returns false")
+		}
+	}
+	if i.vm.HasFunction(exportConfigure) {
+		ok, err := i.call(exportConfigure, rootContextID, uint64(len(cfg.pluginConfig)))
+		if err != nil {
+			return err
+		}
+		if ok == 0 {
+			return errors.New("proxy_on_configure returns false")
+		}
+	}
+	return nil
+}
+
+// call calls the function exported by the module, 0 is returned if it is not exported.
+// The arguments that are not declared by the function are dropped, because the older abi has fewer parameters.
+func (i *instance) call(name string, args ...uint64) (uint64, error) {
+	typ, ok := i.plugin.module.FunctionType(name)
+	if !ok {
+		return 0, nil
+	}
+	if len(args) > len(typ.Params) {
+		args = args[:len(typ.Params)]
+	}
+	results, err := i.vm.Call(name, args...)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0], nil
+}
+
+// ready returns false if the vm is not available, the failed vm is recreated
+func (i *instance) ready() bool {
+	if i.vm != nil {
+		return true
+	}
+	if err := i.start(); err != nil {
+		log.DefaultLogger.Errorf("[stream filter][proxywasm] restart wasm instance of %s failed: %v", i.plugin.cfg.Path, err)
+		return false
+	}
+	return true
+}
+
+// fail handles the error of the call, the vm is dropped because its state may be inconsistent
+func (i *instance) fail(ctx context.Context, name string, err error) {
+	log.Proxy.Errorf(ctx, "[stream filter][proxywasm] call %s of %s failed: %v", name, i.plugin.cfg.Path, err)
+	i.plugin.stats.trap.Inc(1)
+	i.vm = nil
+	i.contexts = nil
+	i.current = nil
+	i.setTickPeriod(0)
+}
+
+// context returns the context of the current stream
+func (i *instance) context() context.Context {
+	if i.current != nil {
+		return i.current.ctx
+	}
+	return context.Background()
+}
+
+// createContext creates the http context of the filter
+func (i *instance) createContext(f *proxyWasmFilter) error {
+	id := i.nextContextID
+	i.nextContextID++
+	if i.nextContextID == 0 {
+		i.nextContextID = rootContextID + 1
+	}
+	f.id = id
+	f.vm = i.vm
+	i.contexts[id] = f
+	_, err := i.call(exportContextCreate, uint64(id), rootContextID)
+	return err
+}
+
+// setTickPeriod starts or stops the timer of proxy_on_tick, it is called with the lock held
+func (i *instance) setTickPeriod(period time.Duration) {
+	if i.stopTick != nil {
+		close(i.stopTick)
+		i.stopTick = nil
+	}
+	if period <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	i.stopTick = stop
+	vm := i.vm
+	utils.GoWithRecover(func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				i.onTick(vm)
+			}
+		}
+	}, nil)
+}
+
+func (i *instance) onTick(vm *wasm.Instance) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	// the timer is stopped or the vm is recreated
+	if i.vm != vm || i.stopTick == nil {
+		return
+	}
+	if _, err := i.call(exportTick, rootContextID); err != nil {
+		i.fail(context.Background(), exportTick, err)
+	}
+	i.current = nil
+}
+
+// close stops the timer, the instance can still be used by the streams that are not finished
+func (i *instance) close() {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.setTickPeriod(0)
+}
@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockStreamReceiverFilterHandler struct {
+	types.ResumableStreamReceiverFilterHandler
+	info       *mockRequestInfo
+	hijackCode int
+	directBody string
+	data       buffer.IoBuffer
+	// continued is closed when the paused filter chain continues
+	continued chan struct{}
+}
+
+func newMockHandler() *mockStreamReceiverFilterHandler {
+	return &mockStreamReceiverFilterHandler{
+		info:      &mockRequestInfo{protocol: api.Protocol("Http1")},
+		continued: make(chan struct{}),
+	}
+}
+
+func (h *mockStreamReceiverFilterHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func (h *mockStreamReceiverFilterHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+func (h *mockStreamReceiverFilterHandler) SendDirectResponse(headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) {
+	status, _ := headers.Get(types.HeaderStatus)
+	h.hijackCode, _ = strconv.Atoi(status)
+	h.directBody = buf.String()
+}
+
+func (h *mockStreamReceiverFilterHandler) SetRequestData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func (h *mockStreamReceiverFilterHandler) ContinueReceiving() {
+	close(h.continued)
+}
+
+// wait waits for the paused filter chain continues
+func (h *mockStreamReceiverFilterHandler) wait(t *testing.T) {
+	select {
+	case <-h.continued:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the filter chain is not continued")
+	}
+}
+
+type mockStreamSenderFilterHandler struct {
+	api.StreamSenderFilterHandler
+	headers api.HeaderMap
+	data    buffer.IoBuffer
+}
+
+func (h *mockStreamSenderFilterHandler) SetResponseHeaders(headers api.HeaderMap) {
+	h.headers = headers
+}
+
+func (h *mockStreamSenderFilterHandler) SetResponseData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func (h *mockStreamSenderFilterHandler) SetResponseTrailers(trailers api.HeaderMap) {}
+
+type mockRequestInfo struct {
+	api.RequestInfo
+	protocol api.Protocol
+	code     int
+}
+
+func (r *mockRequestInfo) Protocol() api.Protocol {
+	return r.protocol
+}
+
+func (r *mockRequestInfo) SetResponseCode(code int) {
+	r.code = code
+}
@@ -0,0 +1,178 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/wasm"
+	"mosn.io/pkg/utils"
+)
+
+// plugin is a loaded module with a pool of instances
+type plugin struct {
+	cfg       *config
+	module    *wasm.Module
+	instances []*instance
+	next      uint32
+	logPrefix string
+	metrics   *pluginMetrics
+	stats     *wasmStats
+}
+
+func loadPlugin(cfg *config, metrics *pluginMetrics, stats *wasmStats) (*plugin, error) {
+	data, err := ioutil.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	module, err := wasm.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	supported := false
+	for _, version := range abiVersions {
+		if _, ok := module.FunctionType(version); ok {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("wasm module %s does not export a supported abi version", cfg.Path)
+	}
+	p := &plugin{
+		cfg:       cfg,
+		module:    module,
+		logPrefix: fmt.Sprintf("[stream filter][proxywasm][%s]", cfg.RootID),
+		metrics:   metrics,
+		stats:     stats,
+	}
+	for n := 0; n < cfg.InstanceNum; n++ {
+		inst, err := newInstance(p)
+		if err != nil {
+			p.close()
+			return nil, fmt.Errorf("create wasm instance failed: %v", err)
+		}
+		stats.instanceCreated.Inc(1)
+		p.instances = append(p.instances, inst)
+	}
+	return p, nil
+}
+
+// instance returns the instances in turn
+func (p *plugin) instance() *instance {
+	n := atomic.AddUint32(&p.next, 1)
+	return p.instances[n%uint32(len(p.instances))]
+}
+
+func (p *plugin) close() {
+	for _, inst := range p.instances {
+		inst.close()
+	}
+}
+
+// pluginManager holds the plugin of a listener, and reloads the plugin if the module file is modified
+type pluginManager struct {
+	cfg     *config
+	metrics *pluginMetrics
+	stats   *wasmStats
+	// current is the *plugin used by the new streams
+	current atomic.Value
+	modTime time.Time
+	size    int64
+	stop    chan struct{}
+}
+
+func newPluginManager(cfg *config) (*pluginManager, error) {
+	name := cfg.RootID
+	if name == "" {
+		name = cfg.Path
+	}
+	m := &pluginManager{
+		cfg:     cfg,
+		metrics: newPluginMetrics(name),
+		stats:   newWasmStats(name),
+		stop:    make(chan struct{}),
+	}
+	if info, err := os.Stat(cfg.Path); err == nil {
+		m.modTime, m.size = info.ModTime(), info.Size()
+	}
+	p, err := loadPlugin(cfg, m.metrics, m.stats)
+	if err != nil {
+		return nil, err
+	}
+	m.current.Store(p)
+	if cfg.ReloadInterval.Duration > 0 {
+		utils.GoWithRecover(m.watch, nil)
+	}
+	return m, nil
+}
+
+func (m *pluginManager) plugin() *plugin {
+	return m.current.Load().(*plugin)
+}
+
+// watch checks the module file periodically until the manager is closed
+func (m *pluginManager) watch() {
+	ticker := time.NewTicker(m.cfg.ReloadInterval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			// the plugin is closed here, so it is not replaced after closed
+			m.plugin().close()
+			return
+		case <-ticker.C:
+			m.checkReload()
+		}
+	}
+}
+
+// checkReload reloads the plugin if the module file is modified, the current plugin is kept if it fails.
+// The streams created before the reloading are still processed by the previous plugin.
+func (m *pluginManager) checkReload() {
+	info, err := os.Stat(m.cfg.Path)
+	if err != nil {
+		return
+	}
+	if info.ModTime().Equal(m.modTime) && info.Size() == m.size {
+		return
+	}
+	m.modTime, m.size = info.ModTime(), info.Size()
+	p, err := loadPlugin(m.cfg, m.metrics, m.stats)
+	if err != nil {
+		m.stats.reloadFailed.Inc(1)
+		log.DefaultLogger.Errorf("[stream filter][proxywasm] reload wasm module %s failed: %v", m.cfg.Path, err)
+		return
+	}
+	old := m.plugin()
+	m.current.Store(p)
+	old.close()
+	m.stats.reload.Inc(1)
+	log.DefaultLogger.Infof("[stream filter][proxywasm] wasm module %s is reloaded", m.cfg.Path)
+}
+
+func (m *pluginManager) close() {
+	close(m.stop)
+	if m.cfg.ReloadInterval.Duration <= 0 {
+		m.plugin().close()
+	}
+}
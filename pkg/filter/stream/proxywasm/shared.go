@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"sync"
+)
+
+// sharedData is the data shared by the instances of the same vm id, the cas is increased when the data is set
+type sharedData struct {
+	value []byte
+	cas   uint32
+}
+
+var (
+	sharedDataMux sync.Mutex
+	sharedDatas   = map[string]map[string]*sharedData{}
+)
+
+func getSharedData(vmID, key string) ([]byte, uint32, bool) {
+	sharedDataMux.Lock()
+	defer sharedDataMux.Unlock()
+	data, ok := sharedDatas[vmID][key]
+	if !ok {
+		return nil, 0, false
+	}
+	return data.value, data.cas, true
+}
+
+// setSharedData sets the data if the cas is 0 or matches the current cas
+func setSharedData(vmID, key string, value []byte, cas uint32) bool {
+	sharedDataMux.Lock()
+	defer sharedDataMux.Unlock()
+	datas, ok := sharedDatas[vmID]
+	if !ok {
+		datas = map[string]*sharedData{}
+		sharedDatas[vmID] = datas
+	}
+	data, ok := datas[key]
+	if !ok {
+		data = &sharedData{}
+		datas[key] = data
+	}
+	if cas != 0 && cas != data.cas {
+		return false
+	}
+	data.value = append([]byte(nil), value...)
+	data.cas++
+	if data.cas == 0 {
+		data.cas = 1
+	}
+	return true
+}
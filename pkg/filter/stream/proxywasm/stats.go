@@ -0,0 +1,153 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxywasm
+
+import (
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+const (
+	metricsType = "proxywasm"
+	// userMetricsType is the type of the metrics defined by the module
+	userMetricsType = "proxywasm_user"
+)
+
+// metrics key
+const (
+	statsInstanceCreated = "instance_created"
+	statsTrap            = "trap"
+	statsReload          = "reload"
+	statsReloadFailed    = "reload_failed"
+)
+
+type wasmStats struct {
+	instanceCreated gometrics.Counter
+	trap            gometrics.Counter
+	reload          gometrics.Counter
+	reloadFailed    gometrics.Counter
+}
+
+func newWasmStats(name string) *wasmStats {
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"plugin": name})
+	return &wasmStats{
+		instanceCreated: m.Counter(statsInstanceCreated),
+		trap:            m.Counter(statsTrap),
+		reload:          m.Counter(statsReload),
+		reloadFailed:    m.Counter(statsReloadFailed),
+	}
+}
+
+// pluginMetrics is the metrics defined by the module, the metrics are shared by the instances
+type pluginMetrics struct {
+	labels map[string]string
+
+	mux     sync.RWMutex
+	ids     map[metricKey]uint32
+	metrics []interface{}
+}
+
+type metricKey struct {
+	typ  uint32
+	name string
+}
+
+func newPluginMetrics(name string) *pluginMetrics {
+	return &pluginMetrics{
+		labels: map[string]string{"plugin": name},
+		ids:    make(map[metricKey]uint32),
+	}
+}
+
+// define returns the id of the metric, the same id is returned if the metric is defined
+func (m *pluginMetrics) define(typ uint32, name string) (uint32, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	key := metricKey{typ, name}
+	if id, ok := m.ids[key]; ok {
+		return id, true
+	}
+	ms, err := metrics.NewMetrics(userMetricsType, m.labels)
+	if err != nil {
+		return 0, false
+	}
+	var metric interface{}
+	switch typ {
+	case metricCounter:
+		metric = ms.Counter(name)
+	case metricGauge:
+		metric = ms.Gauge(name)
+	case metricHistogram:
+		metric = ms.Histogram(name)
+	default:
+		return 0, false
+	}
+	id := uint32(len(m.metrics))
+	m.metrics = append(m.metrics, metric)
+	m.ids[key] = id
+	return id, true
+}
+
+func (m *pluginMetrics) metric(id uint32) interface{} {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	if int(id) >= len(m.metrics) {
+		return nil
+	}
+	return m.metrics[id]
+}
+
+func (m *pluginMetrics) increment(id uint32, offset int64) bool {
+	switch metric := m.metric(id).(type) {
+	case gometrics.Counter:
+		metric.Inc(offset)
+	case gometrics.Gauge:
+		metric.Update(metric.Value() + offset)
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *pluginMetrics) record(id uint32, value int64) bool {
+	switch metric := m.metric(id).(type) {
+	case gometrics.Counter:
+		metric.Clear()
+		metric.Inc(value)
+	case gometrics.Gauge:
+		metric.Update(value)
+	case gometrics.Histogram:
+		metric.Update(value)
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *pluginMetrics) get(id uint32) (int64, bool) {
+	switch metric := m.metric(id).(type) {
+	case gometrics.Counter:
+		return metric.Count(), true
+	case gometrics.Gauge:
+		return metric.Value(), true
+	case gometrics.Histogram:
+		return metric.Count(), true
+	}
+	return 0, false
+}
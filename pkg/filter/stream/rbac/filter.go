@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"context"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/rbac"
+	"mosn.io/mosn/pkg/types"
+)
+
+// rbacFilter is an implement of types.StreamReceiverFilter,
+// the request is rejected with 403 if it is denied by the rbac rules.
+type rbacFilter struct {
+	authorizer *rbac.Authorizer
+	handler    api.StreamReceiverFilterHandler
+}
+
+func newRBACFilter(authorizer *rbac.Authorizer) *rbacFilter {
+	return &rbacFilter{
+		authorizer: authorizer,
+	}
+}
+
+func (f *rbacFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *rbacFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	attrs := rbac.NewAttributes(ctx, f.handler.Connection(), headers)
+	if f.authorizer.Authorize(ctx, attrs) {
+		return api.StreamFilterContinue
+	}
+	f.handler.RequestInfo().SetResponseFlag(types.UnauthorizedFlag)
+	f.handler.SendHijackReply(types.PermissionDeniedCode, headers)
+	return api.StreamFilterStop
+}
+
+func (f *rbacFilter) OnDestroy() {}
@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+func TestRBACFilter(t *testing.T) {
+	var conf map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"rules": {
+			"action": "ALLOW",
+			"policies": {
+				"admin": {
+					"permissions": [{"path": {"prefix": "/admin/"}}],
+					"principals": [{"source_ip": {"address_prefix": "10.0.0.0", "prefix_len": 8}}]
+				},
+				"public": {
+					"permissions": [{"not_rule": {"path": {"prefix": "/admin/"}}}],
+					"principals": [{"any": true}]
+				}
+			}
+		}
+	}`), &conf); err != nil {
+		t.Fatal(err)
+	}
+	factory, err := createFilterChainFactory(conf)
+	if err != nil {
+		t.Fatalf("create factory failed: %v", err)
+	}
+	testCases := []struct {
+		remote string
+		path   string
+		status api.StreamFilterStatus
+	}{
+		{"10.1.1.1:1234", "/admin/users", api.StreamFilterContinue},
+		{"192.168.1.1:1234", "/admin/users", api.StreamFilterStop},
+		{"192.168.1.1:1234", "/index.html", api.StreamFilterContinue},
+	}
+	for _, tc := range testCases {
+		filter := newRBACFilter(factory.(*filterChainFactory).authorizer)
+		handler := &mockStreamReceiverFilterHandler{
+			conn: newMockConnection(tc.remote, "127.0.0.1:8080"),
+			info: &mockRequestInfo{},
+		}
+		filter.SetReceiveFilterHandler(handler)
+		headers := protocol.CommonHeader{
+			protocol.MosnHeaderMethod:  "GET",
+			protocol.MosnHeaderPathKey: tc.path,
+		}
+		status := filter.OnReceive(context.Background(), headers, nil, nil)
+		if status != tc.status {
+			t.Errorf("%s %s: expected %s, but got %s", tc.remote, tc.path, tc.status, status)
+		}
+		if status == api.StreamFilterStop && (handler.hijackCode != types.PermissionDeniedCode || handler.info.flag != types.UnauthorizedFlag) {
+			t.Errorf("%s %s: unexpected hijack code %d and flag %v", tc.remote, tc.path, handler.hijackCode, handler.info.flag)
+		}
+	}
+}
+
+func TestCreateFilterChainFactoryFailed(t *testing.T) {
+	for _, conf := range []map[string]interface{}{
+		{},
+		{"rules": map[string]interface{}{"action": "LOG"}},
+		{"rules": "invalid"},
+	} {
+		if _, err := createFilterChainFactory(conf); err == nil {
+			t.Errorf("config %v should be invalid", conf)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"net"
+
+	"mosn.io/api"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockStreamReceiverFilterHandler struct {
+	api.StreamReceiverFilterHandler
+	conn       *mockConnection
+	info       *mockRequestInfo
+	hijackCode int
+}
+
+func (h *mockStreamReceiverFilterHandler) Connection() api.Connection {
+	return h.conn
+}
+
+func (h *mockStreamReceiverFilterHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func (h *mockStreamReceiverFilterHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+type mockRequestInfo struct {
+	api.RequestInfo
+	flag api.ResponseFlag
+}
+
+func (info *mockRequestInfo) SetResponseFlag(flag api.ResponseFlag) {
+	info.flag = flag
+}
+
+type mockConnection struct {
+	api.Connection
+	remote net.Addr
+	local  net.Addr
+}
+
+func newMockConnection(remote, local string) *mockConnection {
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", remote)
+	localAddr, _ := net.ResolveTCPAddr("tcp", local)
+	return &mockConnection{
+		remote: remoteAddr,
+		local:  localAddr,
+	}
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *mockConnection) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *mockConnection) RawConn() net.Conn {
+	return nil
+}
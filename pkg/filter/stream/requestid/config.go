@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package requestid
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const (
+	defaultHeader = "x-request-id"
+	// the incoming request id longer than it is not trusted
+	maxRequestIDLength = 128
+)
+
+type config struct {
+	// Header is the http header of the request id, x-request-id by default
+	Header string `json:"header,omitempty"`
+	// XProtocolKey is the header of bolt requests, or the attachment of dubbo requests, Header by default
+	XProtocolKey string `json:"xprotocol_key,omitempty"`
+	// TrustIncoming reuses the request id of the incoming request, a new one is generated if it is false
+	TrustIncoming bool `json:"trust_incoming,omitempty"`
+	// FromTraceID uses the trace id of the active span as the generated request id, so the
+	// access logs and the tracing share the same id
+	FromTraceID bool `json:"from_trace_id,omitempty"`
+	// IncludeInResponse sets the request id to the response
+	IncludeInResponse bool `json:"include_in_response,omitempty"`
+	// TracingSampling is the percentage of the traced requests, all requests are traced if it is not set.
+	// The decision is made by the hash of the request id, so all the hops of a request make the same decision
+	TracingSampling *float64 `json:"tracing_sampling,omitempty"`
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.Header == "" {
+		filterConfig.Header = defaultHeader
+	}
+	if filterConfig.XProtocolKey == "" {
+		filterConfig.XProtocolKey = filterConfig.Header
+	}
+	if s := filterConfig.TracingSampling; s != nil && (*s < 0 || *s > 100) {
+		return nil, errors.New("tracing sampling should be a percentage between 0 and 100")
+	}
+	return filterConfig, nil
+}
@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package requestid
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/trace"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+)
+
+// generateID generates the request id if there is no trusted one
+var generateID = utils.GenerateUUID
+
+type requestIDFilter struct {
+	cfg           *config
+	id            string
+	handler       api.StreamReceiverFilterHandler
+	senderHandler api.StreamSenderFilterHandler
+}
+
+func newRequestIDFilter(cfg *config) *requestIDFilter {
+	return &requestIDFilter{
+		cfg: cfg,
+	}
+}
+
+func (f *requestIDFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *requestIDFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.senderHandler = handler
+}
+
+func (f *requestIDFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if frame, ok := headers.(xprotocol.XFrame); ok && frame.IsHeartbeatFrame() {
+		return api.StreamFilterContinue
+	}
+	var id string
+	if f.cfg.TrustIncoming {
+		if incoming, ok := f.get(headers); ok && incoming != "" && len(incoming) <= maxRequestIDLength {
+			id = incoming
+		}
+	}
+	if id == "" {
+		id = f.generate(ctx)
+		if err := f.set(headers, id); err != nil {
+			log.DefaultLogger.Warnf("[stream filter][request_id] set request id failed: %v", err)
+		}
+	}
+	f.id = id
+
+	if err := variable.SetVariableValue(ctx, types.VarRequestID, id); err != nil {
+		log.DefaultLogger.Debugf("[stream filter][request_id] set variable failed: %v", err)
+	}
+	if s := f.cfg.TracingSampling; s != nil {
+		variable.SetVariableValue(ctx, types.VarRequestIDSampled, strconv.FormatBool(sampled(id, *s)))
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *requestIDFilter) Append(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if f.id == "" {
+		return api.StreamFilterContinue
+	}
+	// the dubbo response has no attachments
+	if _, ok := headers.(*dubbo.Frame); ok {
+		return api.StreamFilterContinue
+	}
+	if err := f.set(headers, f.id); err != nil {
+		log.DefaultLogger.Debugf("[stream filter][request_id] set response request id failed: %v", err)
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *requestIDFilter) OnDestroy() {}
+
+func (f *requestIDFilter) generate(ctx context.Context) string {
+	if f.cfg.FromTraceID {
+		if span := trace.SpanFromContext(ctx); span != nil && span.TraceId() != "" {
+			return span.TraceId()
+		}
+	}
+	return generateID()
+}
+
+// get returns the request id of the request.
+// The request id of the xprotocol is in the headers of bolt, or in the attachments of dubbo.
+func (f *requestIDFilter) get(headers api.HeaderMap) (string, bool) {
+	switch h := headers.(type) {
+	case *dubbo.Frame:
+		return h.GetAttachment(f.cfg.XProtocolKey)
+	case xprotocol.XFrame:
+		return h.GetHeader().Get(f.cfg.XProtocolKey)
+	default:
+		return headers.Get(f.cfg.Header)
+	}
+}
+
+func (f *requestIDFilter) set(headers api.HeaderMap, id string) error {
+	switch h := headers.(type) {
+	case *dubbo.Frame:
+		return h.SetAttachment(f.cfg.XProtocolKey, id)
+	case xprotocol.XFrame:
+		h.GetHeader().Set(f.cfg.XProtocolKey, id)
+	default:
+		headers.Set(f.cfg.Header, id)
+	}
+	return nil
+}
+
+// sampled decides whether the request is traced by the hash of the request id
+func sampled(id string, percentage float64) bool {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return float64(h.Sum32()%10000) < percentage*100
+}
@@ -0,0 +1,236 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package requestid
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/pkg/buffer"
+)
+
+func newFilter(t *testing.T, conf map[string]interface{}) *requestIDFilter {
+	cfg, err := parseConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRequestIDFilter(cfg)
+}
+
+func newContext() context.Context {
+	return variable.NewVariableContext(context.Background())
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Header != defaultHeader || cfg.XProtocolKey != defaultHeader || cfg.TracingSampling != nil {
+		t.Fatalf("unexpected default config: %+v", cfg)
+	}
+	cfg, err = parseConfig(map[string]interface{}{
+		"header":           "x-trace",
+		"xprotocol_key":    "sofa_trace_id",
+		"trust_incoming":   true,
+		"tracing_sampling": 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Header != "x-trace" || cfg.XProtocolKey != "sofa_trace_id" || !cfg.TrustIncoming || *cfg.TracingSampling != 10 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if _, err := parseConfig(map[string]interface{}{"tracing_sampling": 101}); err == nil {
+		t.Fatal("expected invalid sampling")
+	}
+}
+
+func TestRequestIDGenerate(t *testing.T) {
+	f := newFilter(t, map[string]interface{}{
+		"include_in_response": true,
+	})
+	ctx := newContext()
+	headers := protocol.CommonHeader{defaultHeader: "untrusted"}
+	if f.OnReceive(ctx, headers, nil, nil) != api.StreamFilterContinue {
+		t.Fatal("request id filter should not stop the request")
+	}
+	id, _ := headers.Get(defaultHeader)
+	if id == "untrusted" || len(id) != 36 || strings.Count(id, "-") != 4 {
+		t.Fatalf("unexpected request id: %s", id)
+	}
+	if v, err := variable.GetVariableValue(ctx, types.VarRequestID); err != nil || v != id {
+		t.Fatalf("unexpected request id variable: %s, %v", v, err)
+	}
+	// no sampling decision
+	if _, err := variable.GetVariableValue(ctx, types.VarRequestIDSampled); err == nil {
+		t.Fatal("expected no sampling decision")
+	}
+	resp := protocol.CommonHeader{}
+	f.Append(ctx, resp, nil, nil)
+	if v, _ := resp.Get(defaultHeader); v != id {
+		t.Fatalf("unexpected response request id: %s", v)
+	}
+}
+
+func TestRequestIDTrustIncoming(t *testing.T) {
+	f := newFilter(t, map[string]interface{}{
+		"trust_incoming": true,
+	})
+	headers := protocol.CommonHeader{defaultHeader: "incoming"}
+	f.OnReceive(newContext(), headers, nil, nil)
+	if id, _ := headers.Get(defaultHeader); id != "incoming" {
+		t.Fatalf("expected incoming request id, but got %s", id)
+	}
+	// too long to be trusted
+	long := strings.Repeat("a", maxRequestIDLength+1)
+	headers = protocol.CommonHeader{defaultHeader: long}
+	f.OnReceive(newContext(), headers, nil, nil)
+	if id, _ := headers.Get(defaultHeader); id == long {
+		t.Fatal("expected the long request id is replaced")
+	}
+}
+
+func TestRequestIDFromTraceID(t *testing.T) {
+	f := newFilter(t, map[string]interface{}{
+		"from_trace_id": true,
+	})
+	ctx := mosnctx.WithValue(newContext(), types.ContextKeyActiveSpan, &mockSpan{traceID: "0a1b2c3d"})
+	headers := protocol.CommonHeader{}
+	f.OnReceive(ctx, headers, nil, nil)
+	if id, _ := headers.Get(defaultHeader); id != "0a1b2c3d" {
+		t.Fatalf("expected trace id, but got %s", id)
+	}
+}
+
+func TestRequestIDSampling(t *testing.T) {
+	f := newFilter(t, map[string]interface{}{
+		"trust_incoming":   true,
+		"tracing_sampling": 50,
+	})
+	total, traced := 1000, 0
+	for i := 0; i < total; i++ {
+		id := generateID()
+		ctx := newContext()
+		f.OnReceive(ctx, protocol.CommonHeader{defaultHeader: id}, nil, nil)
+		v, err := variable.GetVariableValue(ctx, types.VarRequestIDSampled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the decision is the same for the same request id
+		if expected := sampled(id, 50); v != map[bool]string{true: "true", false: "false"}[expected] {
+			t.Fatalf("unexpected sampling decision: %s", v)
+		}
+		if v == "true" {
+			traced++
+		}
+	}
+	if traced < 400 || traced > 600 {
+		t.Fatalf("unexpected traced requests: %d", traced)
+	}
+	if sampled("any", 0) || !sampled("any", 100) {
+		t.Fatal("unexpected sampling of the boundary")
+	}
+}
+
+func TestRequestIDBolt(t *testing.T) {
+	f := newFilter(t, map[string]interface{}{
+		"trust_incoming":      true,
+		"xprotocol_key":       "rpc_trace_id",
+		"include_in_response": true,
+	})
+	ctx := newContext()
+	req := bolt.NewRpcRequest(1, protocol.CommonHeader{}, nil)
+	f.OnReceive(ctx, req, nil, nil)
+	id, ok := req.Get("rpc_trace_id")
+	if !ok || len(id) != 36 {
+		t.Fatalf("unexpected request id: %s", id)
+	}
+	if _, ok := req.Get(defaultHeader); ok {
+		t.Fatal("the http header should not be set")
+	}
+	resp := bolt.NewRpcResponse(1, bolt.ResponseStatusSuccess, protocol.CommonHeader{}, nil)
+	f.Append(ctx, resp, nil, nil)
+	if v, _ := resp.Get("rpc_trace_id"); v != id {
+		t.Fatalf("unexpected response request id: %s", v)
+	}
+	// heartbeat is ignored
+	hb := bolt.NewRpcRequest(2, protocol.CommonHeader{}, nil)
+	hb.CmdCode = bolt.CmdCodeHeartbeat
+	f.OnReceive(newContext(), hb, nil, nil)
+	if _, ok := hb.Get("rpc_trace_id"); ok {
+		t.Fatal("heartbeat should be ignored")
+	}
+}
+
+func buildDubboRequest(t *testing.T, attachments map[string]string) *dubbo.Frame {
+	encoder := hessian.NewEncoder()
+	for _, v := range []interface{}{
+		"2.0.2", "com.alipay.test.TestService", "1.0.0", "sayHello",
+		"Ljava/lang/String;", "hello",
+		attachments,
+	} {
+		if err := encoder.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payload := encoder.Buffer()
+	data := make([]byte, dubbo.HeaderLen)
+	data[0], data[1] = 0xda, 0xbb
+	data[dubbo.FlagIdx] = 0xc2 // request, two way, hessian2
+	binary.BigEndian.PutUint32(data[dubbo.DataLenIdx:], uint32(len(payload)))
+	data = append(data, payload...)
+	cmd, err := xprotocol.GetProtocol(dubbo.ProtocolName).Decode(context.Background(), buffer.NewIoBufferBytes(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd.(*dubbo.Frame)
+}
+
+func TestRequestIDDubbo(t *testing.T) {
+	f := newFilter(t, map[string]interface{}{
+		"trust_incoming": true,
+	})
+	req := buildDubboRequest(t, map[string]string{defaultHeader: "incoming"})
+	f.OnReceive(newContext(), req, nil, nil)
+	if id, _ := req.GetAttachment(defaultHeader); id != "incoming" {
+		t.Fatalf("expected incoming request id, but got %s", id)
+	}
+
+	req = buildDubboRequest(t, map[string]string{})
+	ctx := newContext()
+	f.OnReceive(ctx, req, nil, nil)
+	id, ok := req.GetAttachment(defaultHeader)
+	if !ok || len(id) != 36 {
+		t.Fatalf("unexpected request id: %s", id)
+	}
+	if v, _ := variable.GetVariableValue(ctx, types.VarRequestID); v != id {
+		t.Fatalf("unexpected request id variable: %s", v)
+	}
+}
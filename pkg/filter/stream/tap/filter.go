@@ -0,0 +1,129 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"context"
+	"time"
+
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/tap"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// tapFilter captures the matched request and its response, the trace is submitted when the stream is destroyed
+type tapFilter struct {
+	tap           *tap.Tap
+	trace         *tap.Trace
+	binary        bool
+	handler       api.StreamReceiverFilterHandler
+	senderHandler api.StreamSenderFilterHandler
+}
+
+func newTapFilter(t *tap.Tap) *tapFilter {
+	return &tapFilter{
+		tap: t,
+	}
+}
+
+func (f *tapFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *tapFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.senderHandler = handler
+}
+
+func (f *tapFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if !f.tap.Active() {
+		return api.StreamFilterContinue
+	}
+	var cluster string
+	if route := f.handler.Route(); route != nil && route.RouteRule() != nil {
+		cluster = route.RouteRule().ClusterName()
+	}
+	conn := f.handler.Connection()
+	if !f.tap.MatchRequest(conn.RemoteAddr(), headers, cluster) {
+		return api.StreamFilterContinue
+	}
+
+	info := f.handler.RequestInfo()
+	proto := info.Protocol()
+	// the xprotocol payloads are binary, the http body is captured as text if it is valid utf8
+	f.binary = proto != protocol.HTTP1 && proto != protocol.HTTP2
+	f.trace = &tap.Trace{
+		Protocol:  string(proto),
+		StartTime: info.StartTime(),
+		Request:   f.message(headers, buf),
+	}
+	if subProtocol, ok := mosnctx.Get(ctx, types.ContextSubProtocol).(string); ok && subProtocol != "" {
+		f.trace.Protocol = subProtocol
+	}
+	if addr := conn.LocalAddr(); addr != nil {
+		f.trace.LocalAddress = addr.String()
+	}
+	if addr := conn.RemoteAddr(); addr != nil {
+		f.trace.RemoteAddress = addr.String()
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *tapFilter) Append(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if f.trace == nil {
+		return api.StreamFilterContinue
+	}
+	f.trace.Response = f.message(headers, buf)
+	info := f.senderHandler.RequestInfo()
+	f.trace.RequestReceivedDuration = info.RequestReceivedDuration().String()
+	f.trace.ResponseReceivedDuration = info.ResponseReceivedDuration().String()
+	f.trace.Duration = time.Since(f.trace.StartTime).String()
+	return api.StreamFilterContinue
+}
+
+// OnDestroy submits the trace, the response is empty if the stream is reset
+func (f *tapFilter) OnDestroy() {
+	// OnDestroy is called by both the receiver and the sender filter, the trace is submitted once
+	if f.trace == nil {
+		return
+	}
+	if f.trace.Duration == "" {
+		f.trace.Duration = time.Since(f.trace.StartTime).String()
+	}
+	f.tap.Submit(f.trace)
+	f.trace = nil
+}
+
+func (f *tapFilter) message(headers api.HeaderMap, buf buffer.IoBuffer) *tap.Message {
+	msg := &tap.Message{}
+	if headers != nil {
+		msg.Headers = map[string]string{}
+		headers.Range(func(key, value string) bool {
+			msg.Headers[key] = value
+			return true
+		})
+	}
+	if buf != nil && buf.Len() > 0 {
+		capture := tap.NewCapture(f.tap.MaxBodyBytes())
+		capture.Write(buf.Bytes())
+		msg.Body = capture.Body(f.binary)
+	}
+	return msg
+}
@@ -0,0 +1,146 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/tap"
+	"mosn.io/pkg/buffer"
+)
+
+func newFilter(t *testing.T, conf map[string]interface{}, handler *mockStreamReceiverFilterHandler) *tapFilter {
+	factory, err := createFilterChainFactory(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newTapFilter(factory.(*filterChainFactory).tap)
+	f.SetReceiveFilterHandler(handler)
+	f.SetSenderFilterHandler(&mockStreamSenderFilterHandler{info: handler.info})
+	return f
+}
+
+func receiveTrace(t *testing.T, s *tap.Subscriber) *tap.Trace {
+	select {
+	case data := <-s.Traces():
+		trace := &tap.Trace{}
+		if err := json.Unmarshal(data, trace); err != nil {
+			t.Fatal(err)
+		}
+		return trace
+	case <-time.After(time.Second):
+		t.Fatal("expected trace")
+	}
+	return nil
+}
+
+func TestTapHTTP(t *testing.T) {
+	s := tap.Subscribe("http")
+	defer tap.Unsubscribe(s)
+
+	handler := newMockHandler(protocol.HTTP1)
+	handler.route = &mockRoute{rule: &mockRouteRule{cluster: "up"}}
+	f := newFilter(t, map[string]interface{}{
+		"id":             "http",
+		"max_body_bytes": 5,
+		"match": map[string]interface{}{
+			"path_prefix": "/api",
+			"clusters":    []interface{}{"up"},
+		},
+	}, handler)
+
+	ctx := context.Background()
+	headers := protocol.CommonHeader{protocol.MosnHeaderPathKey: "/api/v1", "user-agent": "test"}
+	if f.OnReceive(ctx, headers, buffer.NewIoBufferString("hello world"), nil) != api.StreamFilterContinue {
+		t.Fatal("tap filter should not stop the request")
+	}
+	f.Append(ctx, protocol.CommonHeader{"content-type": "text/plain"}, buffer.NewIoBufferString("ok"), nil)
+	// OnDestroy is called by the receiver and the sender filter
+	f.OnDestroy()
+	f.OnDestroy()
+
+	trace := receiveTrace(t, s)
+	if trace.TapID != "http" || trace.Protocol != "Http1" || trace.RemoteAddress != "10.0.0.1:12345" ||
+		trace.RequestReceivedDuration != "1ms" || trace.ResponseReceivedDuration != "2ms" || trace.Duration == "" {
+		t.Fatalf("unexpected trace: %+v", trace)
+	}
+	if req := trace.Request; req.Headers["user-agent"] != "test" || req.Body.Data != "hello" || !req.Body.Truncated || req.Body.Size != 11 {
+		t.Fatalf("unexpected request: %+v, %+v", req, req.Body)
+	}
+	if resp := trace.Response; resp.Headers["content-type"] != "text/plain" || resp.Body.Data != "ok" || resp.Body.Base64 {
+		t.Fatalf("unexpected response: %+v, %+v", resp, resp.Body)
+	}
+	if len(s.Traces()) != 0 {
+		t.Fatal("the trace should be submitted once")
+	}
+
+	// not matched
+	handler.route.rule.cluster = "down"
+	f.OnReceive(ctx, headers, nil, nil)
+	f.OnDestroy()
+	if len(s.Traces()) != 0 {
+		t.Fatal("expected no trace")
+	}
+}
+
+func TestTapXProtocol(t *testing.T) {
+	s := tap.Subscribe("bolt")
+	defer tap.Unsubscribe(s)
+
+	handler := newMockHandler(protocol.Xprotocol)
+	f := newFilter(t, map[string]interface{}{
+		"id": "bolt",
+		"match": map[string]interface{}{
+			"headers": []interface{}{map[string]interface{}{"name": "service", "value": "test"}},
+		},
+	}, handler)
+
+	ctx := context.Background()
+	req := bolt.NewRpcRequest(1, protocol.CommonHeader{"service": "test"}, nil)
+	f.OnReceive(ctx, req, buffer.NewIoBufferBytes([]byte{0x01, 0x02, 0x03}), nil)
+	// the stream is reset without response
+	f.OnDestroy()
+
+	trace := receiveTrace(t, s)
+	if trace.Response != nil || trace.Duration == "" {
+		t.Fatalf("unexpected trace: %+v", trace)
+	}
+	body := trace.Request.Body
+	if data, _ := base64.StdEncoding.DecodeString(body.Data); !body.Base64 || string(data) != "\x01\x02\x03" {
+		t.Fatalf("unexpected request body: %+v", body)
+	}
+	if trace.Request.Headers["service"] != "test" {
+		t.Fatalf("unexpected request headers: %v", trace.Request.Headers)
+	}
+}
+
+func TestTapInactive(t *testing.T) {
+	handler := newMockHandler(protocol.HTTP1)
+	f := newFilter(t, map[string]interface{}{"id": "inactive"}, handler)
+	f.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil)
+	if f.trace != nil {
+		t.Fatal("the traffic should not be captured without outputs")
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"net"
+	"time"
+
+	"mosn.io/api"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockStreamReceiverFilterHandler struct {
+	api.StreamReceiverFilterHandler
+	route *mockRoute
+	info  *mockRequestInfo
+	conn  *mockConnection
+}
+
+func newMockHandler(protocol api.Protocol) *mockStreamReceiverFilterHandler {
+	return &mockStreamReceiverFilterHandler{
+		info: &mockRequestInfo{
+			protocol:  protocol,
+			startTime: time.Now(),
+		},
+		conn: &mockConnection{
+			remote: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 12345},
+			local:  &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 80},
+		},
+	}
+}
+
+func (h *mockStreamReceiverFilterHandler) Route() api.Route {
+	if h.route == nil {
+		return nil
+	}
+	return h.route
+}
+
+func (h *mockStreamReceiverFilterHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func (h *mockStreamReceiverFilterHandler) Connection() api.Connection {
+	return h.conn
+}
+
+type mockStreamSenderFilterHandler struct {
+	api.StreamSenderFilterHandler
+	info *mockRequestInfo
+}
+
+func (h *mockStreamSenderFilterHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+type mockRoute struct {
+	api.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() api.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	api.RouteRule
+	cluster string
+}
+
+func (r *mockRouteRule) ClusterName() string {
+	return r.cluster
+}
+
+type mockRequestInfo struct {
+	api.RequestInfo
+	protocol  api.Protocol
+	startTime time.Time
+}
+
+func (info *mockRequestInfo) Protocol() api.Protocol {
+	return info.protocol
+}
+
+func (info *mockRequestInfo) StartTime() time.Time {
+	return info.startTime
+}
+
+func (info *mockRequestInfo) RequestReceivedDuration() time.Duration {
+	return time.Millisecond
+}
+
+func (info *mockRequestInfo) ResponseReceivedDuration() time.Duration {
+	return 2 * time.Millisecond
+}
+
+type mockConnection struct {
+	api.Connection
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *mockConnection) LocalAddr() net.Addr {
+	return c.local
+}
@@ -23,6 +23,8 @@ import (
 
 type config struct {
 	Type string `json:"type, omitempty"`
+	// Config is passed to the factory of the transcoder
+	Config map[string]interface{} `json:"config,omitempty"`
 }
 
 func parseConfig(cfg interface{}) (*config, error) {
@@ -19,6 +19,7 @@ package transcoder
 
 import (
 	"context"
+	"fmt"
 
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/config/v2"
@@ -50,16 +51,35 @@ func createFilterChainFactory(conf map[string]interface{}) (api.StreamFilterChai
 }
 
 // transcoder factory
-var transcoderFactory = make(map[string]Transcoder)
+var transcoderFactory = make(map[string]TranscoderFactory)
 
+// MustRegister registers a stateless transcoder that ignores the config
 func MustRegister(typ string, transcoder Transcoder) {
+	MustRegisterFactory(typ, func(cfg map[string]interface{}) (Transcoder, error) {
+		return transcoder, nil
+	})
+}
+
+// MustRegisterFactory registers the factory of the transcoder, a transcoder is created for each stream
+// with the config of the filter or the route, so it can keep the states of the request
+func MustRegisterFactory(typ string, factory TranscoderFactory) {
 	if transcoderFactory[typ] != nil {
 		panic("target stream transcoder already exists: " + typ)
 	}
 
-	transcoderFactory[typ] = transcoder
+	transcoderFactory[typ] = factory
 }
 
 func GetTranscoder(typ string) Transcoder {
-	return transcoderFactory[typ]
+	transcoder, _ := CreateTranscoder(typ, nil)
+	return transcoder
+}
+
+// CreateTranscoder creates the transcoder of the type with the config
+func CreateTranscoder(typ string, cfg map[string]interface{}) (Transcoder, error) {
+	factory := transcoderFactory[typ]
+	if factory == nil {
+		return nil, fmt.Errorf("no such transcoder type: %s", typ)
+	}
+	return factory(cfg)
 }
@@ -45,9 +45,9 @@ func newTranscodeFilter(ctx context.Context, cfg *config) *transcodeFilter {
 		log.Proxy.Debugf(ctx, "[stream filter][transcoder] create transcoder filter with config: %v", cfg)
 	}
 
-	transcoder := GetTranscoder(cfg.Type)
-	if transcoder == nil {
-		log.Proxy.Errorf(ctx, "[stream filter][transcoder] create failed, no such transcoder type: %s", cfg.Type)
+	transcoder, err := CreateTranscoder(cfg.Type, cfg.Config)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter][transcoder] create failed: %v", err)
 		return nil
 	}
 
@@ -65,10 +65,16 @@ func (f *transcodeFilter) readPerRouteConfig(ctx context.Context, cfg map[string
 	}
 	if transcodeCfg, ok := cfg[v2.Transcoder]; ok {
 		if config, err := parseConfig(transcodeCfg); err == nil {
+			transcoder, err := CreateTranscoder(config.Type, config.Config)
+			if err != nil {
+				log.Proxy.Errorf(ctx, "[stream filter][transcoder] create router transcoder failed: %v", err)
+				return
+			}
 			if log.Proxy.GetLogLevel() >= log.DEBUG {
 				log.Proxy.Debugf(ctx, "[stream filter][transcoder] use router config to replace stream filter config, config: %v", config)
 			}
 			f.cfg = config
+			f.transcoder = transcoder
 		}
 	}
 }
@@ -78,6 +84,12 @@ func (f *transcodeFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilt
 }
 
 func (f *transcodeFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	// the router config may use another transcoder, so it is read before the accept
+	if route := f.receiveHandler.Route(); route != nil {
+		// TODO: makes ReadPerRouteConfig as the StreamReceiverFilter's function
+		f.readPerRouteConfig(ctx, route.RouteRule().PerFilterConfig())
+	}
+
 	// check accept
 	if !f.transcoder.Accept(ctx, headers, buf, trailers) {
 		return api.StreamFilterContinue
@@ -90,17 +102,16 @@ func (f *transcodeFilter) OnReceive(ctx context.Context, headers types.HeaderMap
 		log.Proxy.Debugf(ctx, "[stream filter][transcoder] receive request: %+v", headers)
 	}
 
-	if route := f.receiveHandler.Route(); route != nil {
-		// TODO: makes ReadPerRouteConfig as the StreamReceiverFilter's function
-		f.readPerRouteConfig(ctx, route.RouteRule().PerFilterConfig())
-	}
-
 	// do transcoding
 	outHeaders, outBuf, outTrailers, err := f.transcoder.TranscodingRequest(ctx, headers, buf, trailers)
 	if err != nil {
 		log.Proxy.Errorf(ctx, "[stream filter][transcoder] transcode request failed: %v", err)
 		f.receiveHandler.RequestInfo().SetResponseFlag(RequestTranscodeFail)
-		f.receiveHandler.SendHijackReply(http.StatusBadRequest, headers)
+		statusCode := http.StatusBadRequest
+		if e, ok := err.(*StatusError); ok {
+			statusCode = e.StatusCode
+		}
+		f.receiveHandler.SendHijackReply(statusCode, headers)
 		return api.StreamFilterStop
 	}
 	f.receiveHandler.SetRequestHeaders(outHeaders)
@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transcoder
+
+import (
+	"context"
+	"testing"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+func init() {
+	MustRegisterFactory("mock", newMockTranscoder)
+}
+
+func TestTranscodeFilterConfig(t *testing.T) {
+	if f := newTranscodeFilter(context.Background(), &config{Type: "unknown"}); f != nil {
+		t.Fatal("unknown transcoder should not create the filter")
+	}
+	if GetTranscoder("mock") == nil {
+		t.Fatal("transcoder should be created without config")
+	}
+
+	for _, tc := range []struct {
+		route    *mockRoute
+		expected string
+	}{
+		{nil, "filter"},
+		{&mockRoute{rule: &mockRouteRule{}}, "filter"},
+		{&mockRoute{rule: &mockRouteRule{config: map[string]interface{}{
+			v2.Transcoder: map[string]interface{}{
+				"type":   "mock",
+				"config": map[string]interface{}{"name": "route"},
+			},
+		}}}, "route"},
+		// the invalid router config is ignored
+		{&mockRoute{rule: &mockRouteRule{config: map[string]interface{}{
+			v2.Transcoder: map[string]interface{}{"type": "unknown"},
+		}}}, "filter"},
+	} {
+		f := newTranscodeFilter(context.Background(), &config{
+			Type:   "mock",
+			Config: map[string]interface{}{"name": "filter"},
+		})
+		handler := &mockStreamReceiverFilterHandler{route: tc.route}
+		f.SetReceiveFilterHandler(handler)
+		if status := f.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil); status != api.StreamFilterContinue {
+			t.Fatalf("unexpected status: %v", status)
+		}
+		if name, _ := handler.headers.Get("transcoder"); name != tc.expected {
+			t.Errorf("expected transcoder %s, got %s", tc.expected, name)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	decoder := json.NewDecoder(bytes.NewReader([]byte(s)))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestEncodeMessage(t *testing.T) {
+	r := testRegistry(t)
+	b := proto.NewBuffer(nil)
+	err := r.encodeMessage(b, ".test.v1.GetRequest", decodeJSON(t, `{
+		"name": "go",
+		"id": "9007199254740993",
+		"tags": [1, -2, 3],
+		"kind": "KIND_BOOK",
+		"inner": {"value": "shelf"},
+		"labels": {"a": 1, "b": "2"},
+		"flag": true,
+		"data": "aGVsbG8=",
+		"time": "2020-01-02T03:04:05.5Z",
+		"count": 7,
+		"score": 1.5,
+		"delta": -3,
+		"page_no": "2"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &testGetRequest{}
+	if err := proto.Unmarshal(b.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	expected := &testGetRequest{
+		Name:   "go",
+		Id:     9007199254740993,
+		Tags:   []int32{1, -2, 3},
+		Kind:   1,
+		Inner:  &testInner{Value: "shelf"},
+		Labels: map[string]int32{"a": 1, "b": 2},
+		Flag:   true,
+		Data:   []byte("hello"),
+		Time:   &timestamp.Timestamp{Seconds: 1577934245, Nanos: 500000000},
+		Count:  &testInt32Value{Value: 7},
+		Score:  1.5,
+		Delta:  -3,
+		PageNo: 2,
+	}
+	if !proto.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestEncodeMessageError(t *testing.T) {
+	r := testRegistry(t)
+	for _, body := range []string{
+		`{"unknown": 1}`,
+		`{"id": "abc"}`,
+		`{"tags": [2147483648]}`,
+		`{"kind": "KIND_MOVIE"}`,
+		`{"inner": "shelf"}`,
+		`{"time": "yesterday"}`,
+		`[]`,
+	} {
+		if err := r.encodeMessage(proto.NewBuffer(nil), ".test.v1.GetRequest", decodeJSON(t, body)); err == nil {
+			t.Fatalf("expected an error for %s", body)
+		}
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	r := testRegistry(t)
+	data, err := proto.Marshal(&testGetRequest{
+		Name:   "go",
+		Id:     -1,
+		Tags:   []int32{1, 2},
+		Kind:   1,
+		Inner:  &testInner{Value: "shelf"},
+		Labels: map[string]int32{"a": 1},
+		Data:   []byte("hello"),
+		Time:   &timestamp.Timestamp{Seconds: 1577934245},
+		Count:  &testInt32Value{},
+		Score:  0.1,
+		Delta:  -3,
+		PageNo: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := r.decodeMessage(data, ".test.v1.GetRequest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(msg)
+	// the fields are in the order of the message, and the default values are omitted
+	expected := `{"name":"go","id":"-1","tags":[1,2],"kind":"KIND_BOOK","inner":{"value":"shelf"},"labels":{"a":1},` +
+		`"data":"aGVsbG8=","time":"2020-01-02T03:04:05Z","count":0,"score":0.1,"delta":-3,"pageNo":2}`
+	if string(got) != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	// the unpacked repeated scalars and the unknown fields
+	b := proto.NewBuffer(nil)
+	b.EncodeVarint(3<<3 | proto.WireVarint)
+	b.EncodeVarint(5)
+	b.EncodeVarint(3<<3 | proto.WireVarint)
+	b.EncodeVarint(6)
+	b.EncodeVarint(100<<3 | proto.WireBytes)
+	b.EncodeStringBytes("unknown")
+	msg, err = r.decodeMessage(b.Bytes(), ".test.v1.GetRequest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(msg); string(got) != `{"tags":[5,6]}` {
+		t.Fatalf("unexpected message: %s", got)
+	}
+
+	if _, err := r.decodeMessage(data[:len(data)-1], ".test.v1.GetRequest"); err == nil {
+		t.Fatal("expected an error for the truncated message")
+	}
+}
+
+func TestWellKnownTypes(t *testing.T) {
+	r := testRegistry(t)
+	cases := []struct {
+		typeName string
+		value    string
+	}{
+		{timestampType, `"1970-01-01T00:00:01.000000001Z"`},
+		{durationType, `"1.5s"`},
+		{durationType, `"-0.001s"`},
+		{durationType, `"3s"`},
+		{emptyType, `{}`},
+		{fieldMaskType, `"user.displayName,photo"`},
+		{structType, `{"a":[1,"b",true,null,{"c":{}}]}`},
+		{valueType, `"text"`},
+		{listValueType, `[1.5,{"d":false}]`},
+		{".google.protobuf.StringValue", `"wrapped"`},
+		{".google.protobuf.Int64Value", `"-9007199254740993"`},
+		{".google.protobuf.BoolValue", `false`},
+	}
+	for _, c := range cases {
+		b := proto.NewBuffer(nil)
+		if err := r.encodeMessage(b, c.typeName, decodeJSON(t, c.value)); err != nil {
+			t.Fatalf("encode %s %s failed: %v", c.typeName, c.value, err)
+		}
+		msg, err := r.decodeMessage(b.Bytes(), c.typeName)
+		if err != nil {
+			t.Fatalf("decode %s %s failed: %v", c.typeName, c.value, err)
+		}
+		got, _ := json.Marshal(msg)
+		if string(got) != c.value {
+			t.Fatalf("%s expected %s, got %s", c.typeName, c.value, got)
+		}
+	}
+
+	// the field mask paths are snake case on the wire
+	b := proto.NewBuffer(nil)
+	r.encodeMessage(b, fieldMaskType, "user.displayName")
+	fields, _ := decodeRawFields(b.Bytes())
+	if len(fields) != 1 || string(fields[0].data) != "user.display_name" {
+		t.Fatalf("unexpected field mask: %v", fields)
+	}
+}
+
+func TestConvertNumbers(t *testing.T) {
+	if i, err := toInt(json.Number("1e3"), 32); err != nil || i != 1000 {
+		t.Fatalf("unexpected int: %d, %v", i, err)
+	}
+	if _, err := toInt(json.Number("1.5"), 64); err == nil {
+		t.Fatal("expected an error for the fraction")
+	}
+	if _, err := toUint("-1", 32); err == nil {
+		t.Fatal("expected an error for the negative unsigned integer")
+	}
+	if f, err := toFloat("-Infinity", 64); err != nil || !reflect.DeepEqual(floatValue(f, 64), "-Infinity") {
+		t.Fatalf("unexpected float: %v, %v", f, err)
+	}
+	if b, err := toBool("true"); err != nil || !b {
+		t.Fatalf("unexpected bool: %v, %v", b, err)
+	}
+	if data, err := toBytes("-_8"); err != nil || !bytes.Equal(data, []byte{0xfb, 0xff}) {
+		t.Fatalf("unexpected bytes: %v, %v", data, err)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"encoding/json"
+)
+
+const (
+	// StreamFormatArray renders the server streaming responses as a json array
+	StreamFormatArray = "array"
+	// StreamFormatNDJSON renders the server streaming responses as newline-delimited json
+	StreamFormatNDJSON = "ndjson"
+)
+
+type config struct {
+	// ProtoDescriptor is the path of the FileDescriptorSet compiled by protoc with --include_imports
+	ProtoDescriptor string `json:"proto_descriptor"`
+	// Services are the full names of the grpc services to transcode, all the services are transcoded if it is empty
+	Services []string `json:"services,omitempty"`
+	// StreamFormat is the format of the server streaming responses, array by default
+	StreamFormat string `json:"stream_format,omitempty"`
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
@@ -0,0 +1,341 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// jsonField is the field of the jsonObject
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+// jsonObject keeps the fields in the order of the message
+type jsonObject []jsonField
+
+func (o jsonObject) get(name string) (interface{}, bool) {
+	for _, f := range o {
+		if f.name == name {
+			return f.value, true
+		}
+	}
+	return nil, false
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// wireReader reads the protobuf wire format
+type wireReader struct {
+	data []byte
+}
+
+func (w *wireReader) done() bool {
+	return len(w.data) == 0
+}
+
+func (w *wireReader) varint() (uint64, error) {
+	x, n := proto.DecodeVarint(w.data)
+	if n == 0 {
+		return 0, errTruncated
+	}
+	w.data = w.data[n:]
+	return x, nil
+}
+
+func (w *wireReader) fixed64() (uint64, error) {
+	if len(w.data) < 8 {
+		return 0, errTruncated
+	}
+	x := binary.LittleEndian.Uint64(w.data)
+	w.data = w.data[8:]
+	return x, nil
+}
+
+func (w *wireReader) fixed32() (uint64, error) {
+	if len(w.data) < 4 {
+		return 0, errTruncated
+	}
+	x := binary.LittleEndian.Uint32(w.data)
+	w.data = w.data[4:]
+	return uint64(x), nil
+}
+
+func (w *wireReader) bytes() ([]byte, error) {
+	n, err := w.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(w.data)) < n {
+		return nil, errTruncated
+	}
+	data := w.data[:n]
+	w.data = w.data[n:]
+	return data, nil
+}
+
+// skip skips the value of the unknown field
+func (w *wireReader) skip(wire uint64) error {
+	var err error
+	switch wire {
+	case proto.WireVarint:
+		_, err = w.varint()
+	case proto.WireFixed64:
+		_, err = w.fixed64()
+	case proto.WireFixed32:
+		_, err = w.fixed32()
+	case proto.WireBytes:
+		_, err = w.bytes()
+	default:
+		err = fmt.Errorf("unsupported wire type %d", wire)
+	}
+	return err
+}
+
+// decodeMessage decodes the protobuf message of the type to the json value
+func (r *registry) decodeMessage(data []byte, typeName string) (interface{}, error) {
+	if isWellKnownType(typeName) {
+		return r.decodeWellKnown(data, typeName)
+	}
+	msg := r.messages[typeName]
+	if msg == nil {
+		return nil, fmt.Errorf("unknown message %s", typeName)
+	}
+	return r.decodeFields(data, typeName, msg)
+}
+
+// decodeFields decodes the fields on the wire, the fields with default values are omitted like the proto3 json
+func (r *registry) decodeFields(data []byte, typeName string, msg *descriptor.DescriptorProto) (jsonObject, error) {
+	values := make(map[int32]interface{}, len(msg.Field))
+	w := &wireReader{data: data}
+	for !w.done() {
+		key, err := w.varint()
+		if err != nil {
+			return nil, err
+		}
+		number, wire := int32(key>>3), key&7
+		field := findFieldByNumber(msg, number)
+		if field == nil {
+			if err := w.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := r.decodeField(w, wire, field, values); err != nil {
+			return nil, fmt.Errorf("field %s of %s: %v", field.GetName(), typeName, err)
+		}
+	}
+
+	obj := make(jsonObject, 0, len(values))
+	for _, field := range msg.Field {
+		if v, ok := values[field.GetNumber()]; ok {
+			obj = append(obj, jsonField{name: jsonName(field), value: v})
+		}
+	}
+	return obj, nil
+}
+
+func (r *registry) decodeField(w *wireReader, wire uint64, field *descriptor.FieldDescriptorProto, values map[int32]interface{}) error {
+	number := field.GetNumber()
+	if entry, ok := r.isMapEntry(field); ok {
+		data, err := w.bytes()
+		if err != nil {
+			return err
+		}
+		kv, err := r.decodeFields(data, field.GetTypeName(), entry)
+		if err != nil {
+			return err
+		}
+		m, _ := values[number].(map[string]interface{})
+		if m == nil {
+			m = make(map[string]interface{})
+			values[number] = m
+		}
+		key, ok := kv.get(jsonName(findFieldByNumber(entry, 1)))
+		if !ok {
+			key = defaultValue(findFieldByNumber(entry, 1))
+		}
+		value, ok := kv.get(jsonName(findFieldByNumber(entry, 2)))
+		if !ok {
+			value = defaultValue(findFieldByNumber(entry, 2))
+		}
+		m[mapKey(key)] = value
+		return nil
+	}
+
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		value, err := r.decodeValue(w, field)
+		if err != nil {
+			return err
+		}
+		values[number] = value
+		return nil
+	}
+	list, _ := values[number].([]interface{})
+	// the packed and unpacked encodings are both accepted for the repeated scalars
+	if wire == proto.WireBytes && isPackable(field.GetType()) {
+		data, err := w.bytes()
+		if err != nil {
+			return err
+		}
+		packed := &wireReader{data: data}
+		for !packed.done() {
+			value, err := r.decodeValue(packed, field)
+			if err != nil {
+				return err
+			}
+			list = append(list, value)
+		}
+	} else {
+		value, err := r.decodeValue(w, field)
+		if err != nil {
+			return err
+		}
+		list = append(list, value)
+	}
+	values[number] = list
+	return nil
+}
+
+func (r *registry) decodeValue(w *wireReader, field *descriptor.FieldDescriptorProto) (interface{}, error) {
+	var x uint64
+	var err error
+	switch wireType(field.GetType()) {
+	case proto.WireFixed64:
+		x, err = w.fixed64()
+	case proto.WireFixed32:
+		x, err = w.fixed32()
+	case proto.WireVarint:
+		x, err = w.varint()
+	default:
+		var data []byte
+		if data, err = w.bytes(); err != nil {
+			return nil, err
+		}
+		switch field.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			return string(data), nil
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			return base64.StdEncoding.EncodeToString(data), nil
+		}
+		return r.decodeMessage(data, field.GetTypeName())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return floatValue(math.Float64frombits(x), 64), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return floatValue(float64(math.Float32frombits(uint32(x))), 32), nil
+	// the 64-bit integers are strings in the proto3 json
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return strconv.FormatInt(int64(x), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return strconv.FormatInt(int64(x>>1)^-int64(x&1), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return strconv.FormatUint(x, 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return int32(x), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return int32(uint32(x)>>1) ^ -int32(x&1), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return uint32(x), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return x != 0, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if enum := r.enums[field.GetTypeName()]; enum != nil {
+			for _, v := range enum.Value {
+				if v.GetNumber() == int32(x) {
+					return v.GetName(), nil
+				}
+			}
+		}
+		return int32(x), nil
+	}
+	return nil, fmt.Errorf("unsupported field type %s", field.GetType())
+}
+
+// floatValue keeps the shortest representation of the float, and the special values are strings
+func floatValue(f float64, bits int) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, bits))
+}
+
+// defaultValue returns the default json value of the map key or value that is not on the wire
+func defaultValue(field *descriptor.FieldDescriptorProto) interface{} {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return ""
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return false
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return jsonObject{}
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "0"
+	}
+	return 0
+}
+
+func mapKey(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	}
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,240 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// registries caches the registries of the descriptor files, the transcoder is created for each stream
+var registries sync.Map
+
+// method is the grpc method
+type method struct {
+	service string
+	// path is /{package}.{service}/{method}
+	path            string
+	input           string
+	output          string
+	serverStreaming bool
+}
+
+// route maps the http method and path template to the grpc method
+type route struct {
+	method       *method
+	httpMethod   string
+	template     *pathTemplate
+	body         string
+	responseBody string
+}
+
+// registry keeps the messages, enums and routes of a FileDescriptorSet.
+// The messages and enums are keyed by the full names with a leading dot, as they are referenced in the fields.
+type registry struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	// proto3 is the messages defined in the proto3 files, whose repeated scalars are packed by default
+	proto3 map[string]bool
+	routes []*route
+}
+
+// loadRegistry loads the FileDescriptorSet file, the registry is cached by the path
+func loadRegistry(path string) (*registry, error) {
+	if r, ok := registries.Load(path); ok {
+		return r.(*registry), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("invalid proto descriptor %s: %v", path, err)
+	}
+	r, err := newRegistry(set)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := registries.LoadOrStore(path, r)
+	return actual.(*registry), nil
+}
+
+func newRegistry(set *descriptor.FileDescriptorSet) (*registry, error) {
+	r := &registry{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		proto3:   make(map[string]bool),
+	}
+	for _, file := range set.File {
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = "." + file.GetPackage()
+		}
+		for _, enum := range file.EnumType {
+			r.enums[prefix+"."+enum.GetName()] = enum
+		}
+		for _, msg := range file.MessageType {
+			r.addMessage(prefix, msg, file.GetSyntax() == "proto3")
+		}
+	}
+	for _, file := range set.File {
+		for _, service := range file.Service {
+			name := service.GetName()
+			if file.GetPackage() != "" {
+				name = file.GetPackage() + "." + name
+			}
+			for _, md := range service.Method {
+				if err := r.addMethod(name, md); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+func (r *registry) addMessage(prefix string, msg *descriptor.DescriptorProto, proto3 bool) {
+	name := prefix + "." + msg.GetName()
+	r.messages[name] = msg
+	r.proto3[name] = proto3
+	for _, enum := range msg.EnumType {
+		r.enums[name+"."+enum.GetName()] = enum
+	}
+	for _, nested := range msg.NestedType {
+		r.addMessage(name, nested, proto3)
+	}
+}
+
+// addMethod adds the routes of the http rules, the methods without the rule are bound to POST /{service}/{method}.
+// The client streaming methods are not supported.
+func (r *registry) addMethod(service string, md *descriptor.MethodDescriptorProto) error {
+	if md.GetClientStreaming() {
+		return nil
+	}
+	m := &method{
+		service:         service,
+		path:            "/" + service + "/" + md.GetName(),
+		input:           md.GetInputType(),
+		output:          md.GetOutputType(),
+		serverStreaming: md.GetServerStreaming(),
+	}
+	if r.messages[m.input] == nil && !isWellKnownType(m.input) {
+		return fmt.Errorf("method %s uses the unknown message %s", m.path, m.input)
+	}
+	if r.messages[m.output] == nil && !isWellKnownType(m.output) {
+		return fmt.Errorf("method %s uses the unknown message %s", m.path, m.output)
+	}
+	rule := getHttpRule(md)
+	if rule == nil {
+		rule = &HttpRule{Post: m.path, Body: "*"}
+	}
+	rules := append([]*HttpRule{rule}, rule.AdditionalBindings...)
+	for _, rule := range rules {
+		httpMethod, path := rule.pattern()
+		if httpMethod == "" {
+			return fmt.Errorf("method %s has an http rule without pattern", m.path)
+		}
+		template, err := parseTemplate(path)
+		if err != nil {
+			return err
+		}
+		r.routes = append(r.routes, &route{
+			method:       m,
+			httpMethod:   httpMethod,
+			template:     template,
+			body:         rule.Body,
+			responseBody: rule.ResponseBody,
+		})
+	}
+	return nil
+}
+
+// match returns the first route that matches the request, and the values of the path variables
+func (r *registry) match(services map[string]bool, httpMethod, path string) (*route, map[string]string) {
+	for _, rt := range r.routes {
+		if len(services) > 0 && !services[rt.method.service] {
+			continue
+		}
+		if rt.httpMethod != httpMethod {
+			continue
+		}
+		if values, ok := rt.template.match(path); ok {
+			return rt, values
+		}
+	}
+	return nil, nil
+}
+
+// findField finds the field by the json name or the proto name
+func findField(msg *descriptor.DescriptorProto, name string) *descriptor.FieldDescriptorProto {
+	for _, field := range msg.Field {
+		if field.GetName() == name || jsonName(field) == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// jsonName returns the json name of the field, protoc fills it in the descriptors
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.JsonName != nil {
+		return field.GetJsonName()
+	}
+	var b strings.Builder
+	upper := false
+	for _, c := range field.GetName() {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (r *registry) isMapEntry(field *descriptor.FieldDescriptorProto) (*descriptor.DescriptorProto, bool) {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return nil, false
+	}
+	msg := r.messages[field.GetTypeName()]
+	if msg == nil || !msg.GetOptions().GetMapEntry() {
+		return nil, false
+	}
+	return msg, true
+}
+
+// isPacked reports whether the repeated scalar field is encoded as packed
+func (r *registry) isPacked(msg string, field *descriptor.FieldDescriptorProto) bool {
+	if !isPackable(field.GetType()) {
+		return false
+	}
+	if field.GetOptions() != nil && field.GetOptions().Packed != nil {
+		return field.GetOptions().GetPacked()
+	}
+	return r.proto3[msg]
+}
@@ -0,0 +1,296 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// testItem is the message test.v1.Item
+type testItem struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Id   int64  `protobuf:"varint,2,opt,name=id,proto3"`
+	Kind int32  `protobuf:"varint,3,opt,name=kind,proto3"`
+}
+
+func (m *testItem) Reset()         { *m = testItem{} }
+func (m *testItem) String() string { return proto.CompactTextString(m) }
+func (*testItem) ProtoMessage()    {}
+
+// testInner is the message test.v1.GetRequest.Inner
+type testInner struct {
+	Value string `protobuf:"bytes,1,opt,name=value,proto3"`
+}
+
+func (m *testInner) Reset()         { *m = testInner{} }
+func (m *testInner) String() string { return proto.CompactTextString(m) }
+func (*testInner) ProtoMessage()    {}
+
+// testInt32Value is the message google.protobuf.Int32Value
+type testInt32Value struct {
+	Value int32 `protobuf:"varint,1,opt,name=value,proto3"`
+}
+
+func (m *testInt32Value) Reset()         { *m = testInt32Value{} }
+func (m *testInt32Value) String() string { return proto.CompactTextString(m) }
+func (*testInt32Value) ProtoMessage()    {}
+
+// testGetRequest is the message test.v1.GetRequest
+type testGetRequest struct {
+	Name   string               `protobuf:"bytes,1,opt,name=name,proto3"`
+	Id     int64                `protobuf:"varint,2,opt,name=id,proto3"`
+	Tags   []int32              `protobuf:"varint,3,rep,packed,name=tags,proto3"`
+	Kind   int32                `protobuf:"varint,4,opt,name=kind,proto3"`
+	Inner  *testInner           `protobuf:"bytes,5,opt,name=inner,proto3"`
+	Labels map[string]int32     `protobuf:"bytes,6,rep,name=labels,proto3" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	Flag   bool                 `protobuf:"varint,7,opt,name=flag,proto3"`
+	Data   []byte               `protobuf:"bytes,8,opt,name=data,proto3"`
+	Time   *timestamp.Timestamp `protobuf:"bytes,9,opt,name=time,proto3"`
+	Count  *testInt32Value      `protobuf:"bytes,10,opt,name=count,proto3"`
+	Score  float64              `protobuf:"fixed64,11,opt,name=score,proto3"`
+	Delta  int32                `protobuf:"zigzag32,12,opt,name=delta,proto3"`
+	PageNo int32                `protobuf:"varint,13,opt,name=page_no,json=pageNo,proto3"`
+}
+
+func (m *testGetRequest) Reset()         { *m = testGetRequest{} }
+func (m *testGetRequest) String() string { return proto.CompactTextString(m) }
+func (*testGetRequest) ProtoMessage()    {}
+
+func field(name string, number int32, typ descriptor.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptor.FieldDescriptorProto {
+	f := &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Type:     typ.Enum(),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String(lowerCamelCase(name)),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	if repeated {
+		f.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+	return f
+}
+
+func rpc(name, input, output string, serverStreaming, clientStreaming bool, rule *HttpRule) *descriptor.MethodDescriptorProto {
+	m := &descriptor.MethodDescriptorProto{
+		Name:            proto.String(name),
+		InputType:       proto.String(input),
+		OutputType:      proto.String(output),
+		ServerStreaming: proto.Bool(serverStreaming),
+		ClientStreaming: proto.Bool(clientStreaming),
+	}
+	if rule != nil {
+		m.Options = &descriptor.MethodOptions{}
+		if err := proto.SetExtension(m.Options, E_Http, rule); err != nil {
+			panic(err)
+		}
+	}
+	return m
+}
+
+// testDescriptorSet builds the descriptors like protoc with --include_imports
+func testDescriptorSet() *descriptor.FileDescriptorSet {
+	timestampFile := &descriptor.FileDescriptorProto{
+		Name:    proto.String("google/protobuf/timestamp.proto"),
+		Package: proto.String("google.protobuf"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("Timestamp"),
+			Field: []*descriptor.FieldDescriptorProto{
+				field("seconds", 1, descriptor.FieldDescriptorProto_TYPE_INT64, "", false),
+				field("nanos", 2, descriptor.FieldDescriptorProto_TYPE_INT32, "", false),
+			},
+		}},
+	}
+	const (
+		typeMessage = descriptor.FieldDescriptorProto_TYPE_MESSAGE
+		typeString  = descriptor.FieldDescriptorProto_TYPE_STRING
+	)
+	file := &descriptor.FileDescriptorProto{
+		Name:       proto.String("test/v1/item.proto"),
+		Package:    proto.String("test.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto", "google/protobuf/wrappers.proto", "google/api/annotations.proto"},
+		EnumType: []*descriptor.EnumDescriptorProto{{
+			Name: proto.String("Kind"),
+			Value: []*descriptor.EnumValueDescriptorProto{
+				{Name: proto.String("KIND_UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("KIND_BOOK"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("GetRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("name", 1, typeString, "", false),
+					field("id", 2, descriptor.FieldDescriptorProto_TYPE_INT64, "", false),
+					field("tags", 3, descriptor.FieldDescriptorProto_TYPE_INT32, "", true),
+					field("kind", 4, descriptor.FieldDescriptorProto_TYPE_ENUM, ".test.v1.Kind", false),
+					field("inner", 5, typeMessage, ".test.v1.GetRequest.Inner", false),
+					field("labels", 6, typeMessage, ".test.v1.GetRequest.LabelsEntry", true),
+					field("flag", 7, descriptor.FieldDescriptorProto_TYPE_BOOL, "", false),
+					field("data", 8, descriptor.FieldDescriptorProto_TYPE_BYTES, "", false),
+					field("time", 9, typeMessage, ".google.protobuf.Timestamp", false),
+					field("count", 10, typeMessage, ".google.protobuf.Int32Value", false),
+					field("score", 11, descriptor.FieldDescriptorProto_TYPE_DOUBLE, "", false),
+					field("delta", 12, descriptor.FieldDescriptorProto_TYPE_SINT32, "", false),
+					field("page_no", 13, descriptor.FieldDescriptorProto_TYPE_INT32, "", false),
+				},
+				NestedType: []*descriptor.DescriptorProto{
+					{
+						Name:  proto.String("Inner"),
+						Field: []*descriptor.FieldDescriptorProto{field("value", 1, typeString, "", false)},
+					},
+					{
+						Name: proto.String("LabelsEntry"),
+						Field: []*descriptor.FieldDescriptorProto{
+							field("key", 1, typeString, "", false),
+							field("value", 2, descriptor.FieldDescriptorProto_TYPE_INT32, "", false),
+						},
+						Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+					},
+				},
+			},
+			{
+				Name: proto.String("Item"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("name", 1, typeString, "", false),
+					field("id", 2, descriptor.FieldDescriptorProto_TYPE_INT64, "", false),
+					field("kind", 3, descriptor.FieldDescriptorProto_TYPE_ENUM, ".test.v1.Kind", false),
+				},
+			},
+			{
+				Name:  proto.String("ListResponse"),
+				Field: []*descriptor.FieldDescriptorProto{field("items", 1, typeMessage, ".test.v1.Item", true)},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("ItemService"),
+			Method: []*descriptor.MethodDescriptorProto{
+				rpc("Get", ".test.v1.GetRequest", ".test.v1.Item", false, false, &HttpRule{
+					Get: "/v1/items/{name}",
+					AdditionalBindings: []*HttpRule{
+						{Post: "/v1/{inner.value=groups/*}/items:fetch", Body: "*"},
+					},
+				}),
+				rpc("List", ".test.v1.GetRequest", ".test.v1.ListResponse", false, false, &HttpRule{
+					Get:          "/v1/items",
+					ResponseBody: "items",
+				}),
+				rpc("Watch", ".test.v1.GetRequest", ".test.v1.Item", true, false, &HttpRule{
+					Post: "/v1/items:watch",
+					Body: "inner",
+				}),
+				rpc("Create", ".test.v1.Item", ".test.v1.Item", false, false, nil),
+				rpc("Upload", ".test.v1.Item", ".test.v1.Item", false, true, nil),
+			},
+		}},
+	}
+	return &descriptor.FileDescriptorSet{File: []*descriptor.FileDescriptorProto{timestampFile, file}}
+}
+
+func testRegistry(t *testing.T) *registry {
+	r, err := newRegistry(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// writeDescriptorSet writes the test descriptors to a file, and returns the path
+func writeDescriptorSet(t *testing.T) string {
+	data, err := proto.Marshal(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "item.pb")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewRegistry(t *testing.T) {
+	r := testRegistry(t)
+	for _, name := range []string{".test.v1.GetRequest", ".test.v1.GetRequest.Inner", ".test.v1.Item", ".google.protobuf.Timestamp"} {
+		if r.messages[name] == nil {
+			t.Fatalf("message %s is not registered", name)
+		}
+	}
+	if r.enums[".test.v1.Kind"] == nil {
+		t.Fatal("enum test.v1.Kind is not registered")
+	}
+	// the client streaming method is skipped
+	expected := []struct {
+		method, path, template string
+	}{
+		{"GET", "/test.v1.ItemService/Get", "/v1/items/{name}"},
+		{"POST", "/test.v1.ItemService/Get", "/v1/{inner.value=groups/*}/items:fetch"},
+		{"GET", "/test.v1.ItemService/List", "/v1/items"},
+		{"POST", "/test.v1.ItemService/Watch", "/v1/items:watch"},
+		{"POST", "/test.v1.ItemService/Create", "/test.v1.ItemService/Create"},
+	}
+	if len(r.routes) != len(expected) {
+		t.Fatalf("expected %d routes, got %d", len(expected), len(r.routes))
+	}
+	for i, e := range expected {
+		rt := r.routes[i]
+		if rt.httpMethod != e.method || rt.method.path != e.path {
+			t.Fatalf("route %d expected %s %s, got %s %s", i, e.method, e.path, rt.httpMethod, rt.method.path)
+		}
+	}
+
+	rt, values := r.match(nil, "POST", "/v1/groups/books/items:fetch")
+	if rt == nil || rt.method.path != "/test.v1.ItemService/Get" || values["inner.value"] != "groups/books" {
+		t.Fatalf("unexpected match: %+v, %v", rt, values)
+	}
+	if rt, _ := r.match(map[string]bool{"test.v2.ItemService": true}, "GET", "/v1/items"); rt != nil {
+		t.Fatal("the route of the service that is not configured should not match")
+	}
+	if rt, _ := r.match(nil, "DELETE", "/v1/items/foo"); rt != nil {
+		t.Fatal("the route of another http method should not match")
+	}
+}
+
+func TestLoadRegistry(t *testing.T) {
+	path := writeDescriptorSet(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	r, err := loadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := loadRegistry(path); cached != r {
+		t.Fatal("the registry should be cached")
+	}
+	if _, err := loadRegistry(filepath.Join(filepath.Dir(path), "not_exists.pb")); err == nil {
+		t.Fatal("expected an error for the missing file")
+	}
+}
@@ -0,0 +1,392 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// encodeMessage encodes the json value to the protobuf message of the type.
+// The json numbers should be decoded as json.Number, and the values of the path and query are strings.
+func (r *registry) encodeMessage(b *proto.Buffer, typeName string, value interface{}) error {
+	if isWellKnownType(typeName) {
+		return r.encodeWellKnown(b, typeName, value)
+	}
+	msg := r.messages[typeName]
+	if msg == nil {
+		return fmt.Errorf("unknown message %s", typeName)
+	}
+	return r.encodeFields(b, typeName, msg, value)
+}
+
+func (r *registry) encodeFields(b *proto.Buffer, typeName string, msg *descriptor.DescriptorProto, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s should be a json object", typeName)
+	}
+	used := 0
+	// encodes in the order of the fields, so the output is stable
+	for _, field := range msg.Field {
+		v, ok := obj[jsonName(field)]
+		if !ok {
+			v, ok = obj[field.GetName()]
+		}
+		if !ok {
+			continue
+		}
+		used++
+		if v == nil && field.GetTypeName() != valueType {
+			continue
+		}
+		if err := r.encodeField(b, typeName, field, v); err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+	}
+	if used < len(obj) {
+		for key := range obj {
+			if findField(msg, key) == nil {
+				return fmt.Errorf("unknown field %s in %s", key, typeName)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *registry) encodeField(b *proto.Buffer, typeName string, field *descriptor.FieldDescriptorProto, value interface{}) error {
+	if entry, ok := r.isMapEntry(field); ok {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("map should be a json object")
+		}
+		keyField, valueField := findFieldByNumber(entry, 1), findFieldByNumber(entry, 2)
+		if keyField == nil || valueField == nil {
+			return fmt.Errorf("invalid map entry %s", field.GetTypeName())
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			tmp := proto.NewBuffer(nil)
+			if err := r.encodeSingle(tmp, keyField, key); err != nil {
+				return err
+			}
+			if obj[key] != nil {
+				if err := r.encodeSingle(tmp, valueField, obj[key]); err != nil {
+					return err
+				}
+			}
+			b.EncodeVarint(uint64(field.GetNumber())<<3 | proto.WireBytes)
+			b.EncodeRawBytes(tmp.Bytes())
+		}
+		return nil
+	}
+
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return r.encodeSingle(b, field, value)
+	}
+	// a single value of the repeated field is allowed, such as the query parameter
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+	if r.isPacked(typeName, field) {
+		tmp := proto.NewBuffer(nil)
+		for _, v := range list {
+			if err := r.encodeValue(tmp, field, v); err != nil {
+				return err
+			}
+		}
+		b.EncodeVarint(uint64(field.GetNumber())<<3 | proto.WireBytes)
+		return b.EncodeRawBytes(tmp.Bytes())
+	}
+	for _, v := range list {
+		if err := r.encodeSingle(b, field, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeSingle encodes the tag and the value of the field
+func (r *registry) encodeSingle(b *proto.Buffer, field *descriptor.FieldDescriptorProto, value interface{}) error {
+	b.EncodeVarint(uint64(field.GetNumber())<<3 | wireType(field.GetType()))
+	return r.encodeValue(b, field, value)
+}
+
+func (r *registry) encodeValue(b *proto.Buffer, field *descriptor.FieldDescriptorProto, value interface{}) error {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		f, err := toFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		return b.EncodeFixed64(math.Float64bits(f))
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		f, err := toFloat(value, 32)
+		if err != nil {
+			return err
+		}
+		return b.EncodeFixed32(uint64(math.Float32bits(float32(f))))
+	case descriptor.FieldDescriptorProto_TYPE_INT64:
+		i, err := toInt(value, 64)
+		if err != nil {
+			return err
+		}
+		return b.EncodeVarint(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		i, err := toInt(value, 64)
+		if err != nil {
+			return err
+		}
+		return b.EncodeFixed64(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		i, err := toInt(value, 64)
+		if err != nil {
+			return err
+		}
+		return b.EncodeZigzag64(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_INT32:
+		i, err := toInt(value, 32)
+		if err != nil {
+			return err
+		}
+		return b.EncodeVarint(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		i, err := toInt(value, 32)
+		if err != nil {
+			return err
+		}
+		return b.EncodeFixed32(uint64(uint32(i)))
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		i, err := toInt(value, 32)
+		if err != nil {
+			return err
+		}
+		return b.EncodeZigzag32(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_UINT32:
+		u, err := toUint(value, bitSize(field.GetType()))
+		if err != nil {
+			return err
+		}
+		return b.EncodeVarint(u)
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		u, err := toUint(value, 64)
+		if err != nil {
+			return err
+		}
+		return b.EncodeFixed64(u)
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		u, err := toUint(value, 32)
+		if err != nil {
+			return err
+		}
+		return b.EncodeFixed32(u)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		v, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		if v {
+			return b.EncodeVarint(1)
+		}
+		return b.EncodeVarint(0)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		n, err := r.enumNumber(field.GetTypeName(), value)
+		if err != nil {
+			return err
+		}
+		return b.EncodeVarint(uint64(n))
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a string", value)
+		}
+		return b.EncodeStringBytes(s)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		data, err := toBytes(value)
+		if err != nil {
+			return err
+		}
+		return b.EncodeRawBytes(data)
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		tmp := proto.NewBuffer(nil)
+		if err := r.encodeMessage(tmp, field.GetTypeName(), value); err != nil {
+			return err
+		}
+		return b.EncodeRawBytes(tmp.Bytes())
+	}
+	return fmt.Errorf("unsupported field type %s", field.GetType())
+}
+
+// enumNumber accepts the name or the number of the enum value
+func (r *registry) enumNumber(typeName string, value interface{}) (int64, error) {
+	if s, ok := value.(string); ok {
+		if enum := r.enums[typeName]; enum != nil {
+			for _, v := range enum.Value {
+				if v.GetName() == s {
+					return int64(v.GetNumber()), nil
+				}
+			}
+		}
+	}
+	n, err := toInt(value, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown value %v of enum %s", value, typeName)
+	}
+	return n, nil
+}
+
+func findFieldByNumber(msg *descriptor.DescriptorProto, number int32) *descriptor.FieldDescriptorProto {
+	for _, field := range msg.Field {
+		if field.GetNumber() == number {
+			return field
+		}
+	}
+	return nil
+}
+
+func wireType(typ descriptor.FieldDescriptorProto_Type) uint64 {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return proto.WireFixed64
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return proto.WireFixed32
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES, descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return proto.WireBytes
+	}
+	return proto.WireVarint
+}
+
+func isPackable(typ descriptor.FieldDescriptorProto_Type) bool {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return false
+	}
+	return true
+}
+
+func bitSize(typ descriptor.FieldDescriptorProto_Type) int {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return 32
+	}
+	return 64
+}
+
+// numberString returns the text of the json number, the numbers can be quoted as the 64-bit integers in the proto3 json
+func numberString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return string(v), nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("%v is not a number", value)
+}
+
+func toInt(value interface{}, bits int) (int64, error) {
+	s, err := numberString(value)
+	if err != nil {
+		return 0, err
+	}
+	if i, err := strconv.ParseInt(s, 10, bits); err == nil {
+		return i, nil
+	}
+	// the exponent notation of the integers, such as 1e3
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || f < -math.Pow(2, float64(bits-1)) || f >= math.Pow(2, float64(bits-1)) {
+		return 0, fmt.Errorf("%s is not a %d-bit integer", s, bits)
+	}
+	return int64(f), nil
+}
+
+func toUint(value interface{}, bits int) (uint64, error) {
+	s, err := numberString(value)
+	if err != nil {
+		return 0, err
+	}
+	if u, err := strconv.ParseUint(s, 10, bits); err == nil {
+		return u, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || f < 0 || f >= math.Pow(2, float64(bits)) {
+		return 0, fmt.Errorf("%s is not a %d-bit unsigned integer", s, bits)
+	}
+	return uint64(f), nil
+}
+
+func toFloat(value interface{}, bits int) (float64, error) {
+	s, err := numberString(value)
+	if err != nil {
+		return 0, err
+	}
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(s, bits)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number", s)
+	}
+	return f, nil
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, fmt.Errorf("%v is not a bool", value)
+}
+
+// toBytes decodes the base64 string, both the standard and the url encoding are accepted
+func toBytes(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a base64 string", value)
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a base64 string", s)
+}
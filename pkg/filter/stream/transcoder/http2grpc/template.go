@@ -0,0 +1,172 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	segmentLiteral = iota
+	// segmentSingle is "*" that matches a single path segment
+	segmentSingle
+	// segmentMulti is "**" that matches zero or more path segments
+	segmentMulti
+)
+
+type segment struct {
+	kind    int
+	literal string
+}
+
+// variable binds the path segments in [start, end) to the field path
+type variable struct {
+	fieldPath  string
+	start, end int
+}
+
+// pathTemplate is the compiled path template of the google.api.http rule:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	Verb     = ":" LITERAL ;
+type pathTemplate struct {
+	segments  []segment
+	variables []variable
+	verb      string
+}
+
+func parseTemplate(template string) (*pathTemplate, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("path template %s should start with /", template)
+	}
+	path := template[1:]
+	t := &pathTemplate{}
+	// the verb is after the last segment, the colons in the variables are not verbs
+	if idx := strings.LastIndex(path, ":"); idx >= 0 && idx > strings.LastIndex(path, "/") && idx > strings.LastIndex(path, "}") {
+		t.verb = path[idx+1:]
+		path = path[:idx]
+	}
+	for len(path) > 0 {
+		if path[0] == '{' {
+			end := strings.Index(path, "}")
+			if end < 0 {
+				return nil, fmt.Errorf("path template %s has an unclosed variable", template)
+			}
+			v := variable{fieldPath: path[1:end], start: len(t.segments)}
+			pattern := "*"
+			if idx := strings.Index(v.fieldPath, "="); idx >= 0 {
+				v.fieldPath, pattern = v.fieldPath[:idx], v.fieldPath[idx+1:]
+			}
+			if v.fieldPath == "" || pattern == "" {
+				return nil, fmt.Errorf("path template %s has an invalid variable", template)
+			}
+			for _, s := range strings.Split(pattern, "/") {
+				t.segments = append(t.segments, newSegment(s))
+			}
+			v.end = len(t.segments)
+			t.variables = append(t.variables, v)
+			path = path[end+1:]
+		} else {
+			end := strings.Index(path, "/")
+			if end < 0 {
+				end = len(path)
+			}
+			t.segments = append(t.segments, newSegment(path[:end]))
+			path = path[end:]
+		}
+		if len(path) > 0 {
+			if path[0] != '/' {
+				return nil, fmt.Errorf("path template %s has an invalid segment", template)
+			}
+			path = path[1:]
+		}
+	}
+	return t, nil
+}
+
+func newSegment(s string) segment {
+	switch s {
+	case "*":
+		return segment{kind: segmentSingle}
+	case "**":
+		return segment{kind: segmentMulti}
+	}
+	return segment{kind: segmentLiteral, literal: s}
+}
+
+// match matches the escaped request path, and returns the unescaped values of the variables
+func (t *pathTemplate) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if t.verb != "" {
+		if !strings.HasSuffix(path, ":"+t.verb) {
+			return nil, false
+		}
+		path = path[:len(path)-len(t.verb)-1]
+	}
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	positions := make([]int, len(t.segments)+1)
+	if !t.matchFrom(parts, 0, 0, positions) {
+		return nil, false
+	}
+	values := make(map[string]string, len(t.variables))
+	for _, v := range t.variables {
+		bound := parts[positions[v.start]:positions[v.end]]
+		unescaped := make([]string, len(bound))
+		for i, part := range bound {
+			p, err := url.PathUnescape(part)
+			if err != nil {
+				return nil, false
+			}
+			unescaped[i] = p
+		}
+		values[v.fieldPath] = strings.Join(unescaped, "/")
+	}
+	return values, true
+}
+
+// matchFrom matches the segments from i with the parts from j, the start positions of the segments are recorded
+func (t *pathTemplate) matchFrom(parts []string, i, j int, positions []int) bool {
+	positions[i] = j
+	if i == len(t.segments) {
+		return j == len(parts)
+	}
+	switch s := t.segments[i]; s.kind {
+	case segmentMulti:
+		for k := len(parts); k >= j; k-- {
+			if t.matchFrom(parts, i+1, k, positions) {
+				return true
+			}
+		}
+		return false
+	case segmentSingle:
+		return j < len(parts) && parts[j] != "" && t.matchFrom(parts, i+1, j+1, positions)
+	default:
+		return j < len(parts) && parts[j] == s.literal && t.matchFrom(parts, i+1, j+1, positions)
+	}
+}
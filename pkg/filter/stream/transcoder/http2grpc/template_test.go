@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathTemplate(t *testing.T) {
+	cases := []struct {
+		template string
+		path     string
+		match    bool
+		values   map[string]string
+	}{
+		{"/v1/items", "/v1/items", true, map[string]string{}},
+		{"/v1/items", "/v1/items/1", false, nil},
+		{"/v1/items/{id}", "/v1/items/1", true, map[string]string{"id": "1"}},
+		{"/v1/items/{id}", "/v1/items/", false, nil},
+		{"/v1/items/{id}", "/v1/items/a%2Fb", true, map[string]string{"id": "a/b"}},
+		{"/v1/*/items/{id}", "/v1/shelf/items/1", true, map[string]string{"id": "1"}},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/books/2", true, map[string]string{"name": "shelves/1/books/2"}},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/books", false, nil},
+		{"/v1/{name=files/**}", "/v1/files/a/b/c", true, map[string]string{"name": "files/a/b/c"}},
+		{"/v1/{name=**}/meta", "/v1/a/b/meta", true, map[string]string{"name": "a/b"}},
+		{"/v1/{book.name}:publish", "/v1/go:publish", true, map[string]string{"book.name": "go"}},
+		{"/v1/{book.name}:publish", "/v1/go", false, nil},
+		{"/v1/{a}/{b}", "/v1/x/y", true, map[string]string{"a": "x", "b": "y"}},
+	}
+	for _, c := range cases {
+		tmpl, err := parseTemplate(c.template)
+		if err != nil {
+			t.Fatalf("parse %s failed: %v", c.template, err)
+		}
+		values, ok := tmpl.match(c.path)
+		if ok != c.match {
+			t.Fatalf("%s matches %s expected %v, got %v", c.template, c.path, c.match, ok)
+		}
+		if ok && !reflect.DeepEqual(values, c.values) {
+			t.Fatalf("%s matches %s expected values %v, got %v", c.template, c.path, c.values, values)
+		}
+	}
+}
+
+func TestParseTemplateError(t *testing.T) {
+	for _, template := range []string{"v1/items", "/v1/{id", "/v1/{=*}", "/v1/{id}x"} {
+		if _, err := parseTemplate(template); err == nil {
+			t.Fatalf("expected an error for %s", template)
+		}
+	}
+}
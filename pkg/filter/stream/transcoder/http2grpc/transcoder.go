@@ -0,0 +1,381 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc/codes"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func init() {
+	transcoder.MustRegisterFactory("http2grpc", newHTTP2GRPC)
+}
+
+var (
+	ErrRouteNotFound  = errors.New("no grpc method matches the request")
+	ErrCompressedData = errors.New("the compressed grpc message is not supported")
+)
+
+// skipHeaders are the http/1.1 headers that are not sent to the grpc server
+var skipHeaders = map[string]bool{
+	"host":              true,
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"te":                true,
+	"content-length":    true,
+	"content-type":      true,
+}
+
+// http2grpc transcodes the http json requests to the grpc requests by the proto descriptors
+type http2grpc struct {
+	cfg      *config
+	registry *registry
+	services map[string]bool
+	// route is the matched route of the request, the response is decoded by its method
+	route *route
+}
+
+func newHTTP2GRPC(cfg map[string]interface{}) (transcoder.Transcoder, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if c.ProtoDescriptor == "" {
+		return nil, errors.New("proto_descriptor is required")
+	}
+	switch c.StreamFormat {
+	case "":
+		c.StreamFormat = StreamFormatArray
+	case StreamFormatArray, StreamFormatNDJSON:
+	default:
+		return nil, fmt.Errorf("unknown stream format %s", c.StreamFormat)
+	}
+	r, err := loadRegistry(c.ProtoDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	t := &http2grpc{
+		cfg:      c,
+		registry: r,
+	}
+	if len(c.Services) > 0 {
+		t.services = make(map[string]bool, len(c.Services))
+		for _, s := range c.Services {
+			t.services[s] = true
+		}
+	}
+	return t, nil
+}
+
+func (t *http2grpc) Accept(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) bool {
+	_, ok := headers.(http.RequestHeader)
+	return ok
+}
+
+func (t *http2grpc) TranscodingRequest(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	header := headers.(http.RequestHeader)
+	uri := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(uri)
+	uri.Parse(nil, header.RequestURI())
+
+	rt, values := t.registry.match(t.services, string(header.Method()), string(uri.PathOriginal()))
+	if rt == nil {
+		return nil, nil, nil, ErrRouteNotFound
+	}
+	t.route = rt
+
+	msg, err := t.buildMessage(rt, values, uri.QueryArgs(), buf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data := proto.NewBuffer(nil)
+	if err := t.registry.encodeMessage(data, rt.method.input, msg); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid request of %s: %v", rt.method.path, err)
+	}
+
+	host := string(header.Host())
+	req := &stdhttp.Request{
+		Method: stdhttp.MethodPost,
+		URL: &url.URL{
+			Scheme: "http",
+			Host:   host,
+			Path:   rt.method.path,
+		},
+		Host:   host,
+		Header: make(stdhttp.Header),
+	}
+	header.Range(func(key, value string) bool {
+		key = strings.ToLower(key)
+		if !skipHeaders[key] && !strings.HasPrefix(key, ":") && !strings.HasPrefix(key, "x-mosn-") {
+			req.Header.Add(key, value)
+		}
+		return true
+	})
+	req.Header.Set("content-type", "application/grpc")
+	req.Header.Set("te", "trailers")
+	return http2.NewReqHeader(req), buffer.NewIoBufferBytes(frame(data.Bytes())), nil, nil
+}
+
+// buildMessage builds the json of the request message from the body, the path variables and the query parameters.
+// The query parameters are only used if the body is not mapped to the whole message.
+func (t *http2grpc) buildMessage(rt *route, values map[string]string, query *fasthttp.Args, buf types.IoBuffer) (map[string]interface{}, error) {
+	msg := make(map[string]interface{})
+	if rt.body != "" && buf != nil && buf.Len() > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+		decoder.UseNumber()
+		var body interface{}
+		if err := decoder.Decode(&body); err != nil {
+			return nil, fmt.Errorf("invalid json body: %v", err)
+		}
+		if rt.body == "*" {
+			obj, ok := body.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("the json body should be an object")
+			}
+			msg = obj
+		} else if err := setField(msg, rt.body, body); err != nil {
+			return nil, err
+		}
+	}
+	for fieldPath, value := range values {
+		if err := setField(msg, fieldPath, value); err != nil {
+			return nil, err
+		}
+	}
+	if rt.body != "*" {
+		params := make(map[string][]interface{})
+		query.VisitAll(func(key, value []byte) {
+			params[string(key)] = append(params[string(key)], string(value))
+		})
+		for key, list := range params {
+			if _, ok := values[key]; ok {
+				continue
+			}
+			var value interface{} = list
+			if len(list) == 1 {
+				value = list[0]
+			}
+			if err := setField(msg, key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return msg, nil
+}
+
+// setField sets the value at the dot separated field path
+func setField(msg map[string]interface{}, fieldPath string, value interface{}) error {
+	names := strings.Split(fieldPath, ".")
+	for _, name := range names[:len(names)-1] {
+		next, ok := msg[name]
+		if !ok || next == nil {
+			next = make(map[string]interface{})
+			msg[name] = next
+		}
+		obj, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("field %s is not a message", fieldPath)
+		}
+		msg = obj
+	}
+	msg[names[len(names)-1]] = value
+	return nil
+}
+
+func (t *http2grpc) TranscodingResponse(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	response, ok := headers.(*http2.RspHeader)
+	if !ok || t.route == nil {
+		// the responses hijacked by the proxy
+		return headers, buf, trailers, nil
+	}
+
+	code, message, ok := grpcStatus(headers, trailers)
+	if !ok {
+		if response.Rsp.StatusCode != http.OK {
+			return jsonResponse(response.Rsp.StatusCode, "application/json", &errorBody{Code: int(codes.Unknown), Message: stdhttp.StatusText(response.Rsp.StatusCode)})
+		}
+		// the grpc server must send the grpc-status
+		code, message = codes.Internal, "missing grpc-status"
+	}
+	if code != codes.OK {
+		return jsonResponse(httpStatusCode(code), "application/json", &errorBody{Code: int(code), Message: message})
+	}
+
+	var data []byte
+	if buf != nil {
+		data = buf.Bytes()
+	}
+	var messages []interface{}
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, nil, nil, errTruncated
+		}
+		if data[0] != 0 {
+			return nil, nil, nil, ErrCompressedData
+		}
+		length := binary.BigEndian.Uint32(data[1:5])
+		if uint64(len(data)-5) < uint64(length) {
+			return nil, nil, nil, errTruncated
+		}
+		msg, err := t.registry.decodeMessage(data[5:5+length], t.route.method.output)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if t.route.responseBody != "" {
+			msg = responseField(msg, t.route.responseBody)
+		}
+		messages = append(messages, msg)
+		data = data[5+length:]
+	}
+
+	if !t.route.method.serverStreaming {
+		if len(messages) == 0 {
+			return jsonResponse(http.OK, "application/json", jsonObject{})
+		}
+		return jsonResponse(http.OK, "application/json", messages[0])
+	}
+	if t.cfg.StreamFormat == StreamFormatNDJSON {
+		body := &bytes.Buffer{}
+		encoder := json.NewEncoder(body)
+		for _, msg := range messages {
+			if err := encoder.Encode(msg); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		return httpResponse(http.OK, "application/x-ndjson", body.Bytes())
+	}
+	if messages == nil {
+		messages = []interface{}{}
+	}
+	return jsonResponse(http.OK, "application/json", messages)
+}
+
+// responseField returns the field of the response_body, the json name or the proto name of the field is accepted
+func responseField(msg interface{}, name string) interface{} {
+	obj, ok := msg.(jsonObject)
+	if !ok {
+		return msg
+	}
+	if v, ok := obj.get(name); ok {
+		return v
+	}
+	v, _ := obj.get(lowerCamelCase(name))
+	return v
+}
+
+// grpcStatus reads the grpc-status from the trailers, or the headers of the trailers-only response
+func grpcStatus(headers, trailers types.HeaderMap) (codes.Code, string, bool) {
+	for _, h := range []types.HeaderMap{trailers, headers} {
+		if h == nil {
+			continue
+		}
+		status, ok := h.Get("grpc-status")
+		if !ok {
+			continue
+		}
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return codes.Unknown, "invalid grpc-status " + status, true
+		}
+		message, _ := h.Get("grpc-message")
+		// the grpc-message is percent encoded
+		if m, err := url.PathUnescape(message); err == nil {
+			message = m
+		}
+		return codes.Code(code), message, true
+	}
+	return codes.OK, "", false
+}
+
+// httpStatusCode maps the grpc status code to the http status code like the grpc-gateway
+func httpStatusCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.OK
+	case codes.Canceled:
+		return http.RequestTimeout
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.BadRequest
+	case codes.DeadlineExceeded:
+		return http.GatewayTimeout
+	case codes.NotFound:
+		return http.NotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.Conflict
+	case codes.PermissionDenied:
+		return http.Forbidden
+	case codes.Unauthenticated:
+		return http.Unauthorized
+	case codes.ResourceExhausted:
+		return http.TooManyRequests
+	case codes.Unimplemented:
+		return http.NotImplemented
+	case codes.Unavailable:
+		return http.ServiceUnavailable
+	}
+	return http.InternalServerError
+}
+
+// errorBody is the json body of the failed grpc calls
+type errorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func jsonResponse(statusCode int, contentType string, body interface{}) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("marshal response failed: %v", err)
+	}
+	return httpResponse(statusCode, contentType, data)
+}
+
+func httpResponse(statusCode int, contentType string, body []byte) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	header := &fasthttp.ResponseHeader{}
+	header.SetStatusCode(statusCode)
+	header.SetContentType(contentType)
+	return http.ResponseHeader{ResponseHeader: header}, buffer.NewIoBufferBytes(body), nil, nil
+}
+
+// frame adds the length-prefixed message header of grpc, the message is not compressed
+func frame(data []byte) []byte {
+	b := make([]byte, 5+len(data))
+	binary.BigEndian.PutUint32(b[1:5], uint32(len(data)))
+	copy(b[5:], data)
+	return b
+}
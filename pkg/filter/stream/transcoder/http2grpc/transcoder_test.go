@@ -0,0 +1,310 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	stdhttp "net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc/codes"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func buildHTTPRequest(method, uri string, headers map[string]string) http.RequestHeader {
+	header := &fasthttp.RequestHeader{}
+	header.SetMethod(method)
+	header.SetRequestURI(uri)
+	header.SetHost("api.example.com")
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+	return http.RequestHeader{RequestHeader: header}
+}
+
+func buildGRPCResponse(status string, messages ...proto.Message) (types.HeaderMap, types.IoBuffer, types.HeaderMap) {
+	headers := http2.NewRspHeader(&stdhttp.Response{
+		StatusCode: http.OK,
+		Header:     stdhttp.Header{"Content-Type": []string{"application/grpc"}},
+	})
+	buf := buffer.NewIoBuffer(0)
+	for _, msg := range messages {
+		data, _ := proto.Marshal(msg)
+		buf.Write(frame(data))
+	}
+	trailers := http2.NewHeaderMap(stdhttp.Header{})
+	trailers.Set("grpc-status", status)
+	return headers, buf, trailers
+}
+
+func newTestTranscoder(t *testing.T, cfg map[string]interface{}) *http2grpc {
+	tc, err := transcoder.CreateTranscoder("http2grpc", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tc.(*http2grpc)
+}
+
+// unframe returns the message of the grpc request
+func unframe(t *testing.T, buf types.IoBuffer, msg proto.Message) {
+	t.Helper()
+	data := buf.Bytes()
+	if len(data) < 5 || data[0] != 0 || int(binary.BigEndian.Uint32(data[1:5])) != len(data)-5 {
+		t.Fatalf("invalid grpc frame: %v", data)
+	}
+	if err := proto.Unmarshal(data[5:], msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkResponse(t *testing.T, headers types.HeaderMap, buf types.IoBuffer, statusCode int, contentType, expected string) {
+	t.Helper()
+	header, ok := headers.(http.ResponseHeader)
+	if !ok {
+		t.Fatalf("unexpected response headers: %T", headers)
+	}
+	if header.StatusCode() != statusCode || string(header.ContentType()) != contentType {
+		t.Fatalf("unexpected status: %d, %s", header.StatusCode(), header.ContentType())
+	}
+	if buf.String() != expected {
+		t.Fatalf("expected body %s, got %s", expected, buf.String())
+	}
+}
+
+func TestNewHTTP2GRPC(t *testing.T) {
+	path := writeDescriptorSet(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	for _, cfg := range []map[string]interface{}{
+		nil,
+		{"proto_descriptor": filepath.Join(filepath.Dir(path), "not_exists.pb")},
+		{"proto_descriptor": path, "stream_format": "csv"},
+	} {
+		if _, err := newHTTP2GRPC(cfg); err == nil {
+			t.Fatalf("expected an error for config %v", cfg)
+		}
+	}
+	tc := newTestTranscoder(t, map[string]interface{}{"proto_descriptor": path})
+	if tc.cfg.StreamFormat != StreamFormatArray {
+		t.Fatalf("unexpected stream format: %s", tc.cfg.StreamFormat)
+	}
+	if !tc.Accept(context.Background(), buildHTTPRequest("GET", "/v1/items", nil), nil, nil) {
+		t.Fatal("the http request should be accepted")
+	}
+	if tc.Accept(context.Background(), http2.NewReqHeader(&stdhttp.Request{Header: stdhttp.Header{}}), nil, nil) {
+		t.Fatal("the http2 request should not be accepted")
+	}
+}
+
+func TestTranscodingRequest(t *testing.T) {
+	path := writeDescriptorSet(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	tc := newTestTranscoder(t, map[string]interface{}{"proto_descriptor": path})
+	ctx := context.Background()
+
+	// path variables and query parameters
+	headers, buf, _, err := tc.TranscodingRequest(ctx, buildHTTPRequest("GET", "/v1/items/go%20lang?id=3&tags=1&tags=2&inner.value=shelf&kind=KIND_BOOK", map[string]string{
+		"Authorization": "Bearer token",
+		"Connection":    "keep-alive",
+	}), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := headers.(*http2.ReqHeader).Req
+	if req.Method != "POST" || req.URL.Path != "/test.v1.ItemService/Get" || req.Host != "api.example.com" {
+		t.Fatalf("unexpected request: %s %s %s", req.Method, req.URL, req.Host)
+	}
+	if req.Header.Get("content-type") != "application/grpc" || req.Header.Get("te") != "trailers" ||
+		req.Header.Get("authorization") != "Bearer token" || req.Header.Get("connection") != "" {
+		t.Fatalf("unexpected request headers: %v", req.Header)
+	}
+	msg := &testGetRequest{}
+	unframe(t, buf, msg)
+	expected := &testGetRequest{Name: "go lang", Id: 3, Tags: []int32{1, 2}, Inner: &testInner{Value: "shelf"}, Kind: 1}
+	if !proto.Equal(msg, expected) {
+		t.Fatalf("expected %v, got %v", expected, msg)
+	}
+
+	// the whole body with the path variable, the query parameters are ignored
+	_, buf, _, err = tc.TranscodingRequest(ctx, buildHTTPRequest("POST", "/v1/groups/books/items:fetch?name=ignored", nil),
+		buffer.NewIoBufferString(`{"name": "go", "inner": {"value": "overwritten"}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = &testGetRequest{}
+	unframe(t, buf, msg)
+	if msg.Name != "go" || msg.Inner.Value != "groups/books" {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+
+	// the body of a field
+	_, buf, _, err = tc.TranscodingRequest(ctx, buildHTTPRequest("POST", "/v1/items:watch?name=go", nil),
+		buffer.NewIoBufferString(`{"value": "shelf"}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = &testGetRequest{}
+	unframe(t, buf, msg)
+	if msg.Name != "go" || msg.Inner.Value != "shelf" {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+
+	// the default route of the method without http rule
+	headers, buf, _, err = tc.TranscodingRequest(ctx, buildHTTPRequest("POST", "/test.v1.ItemService/Create", nil),
+		buffer.NewIoBufferString(`{"name": "go", "id": 1}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &testItem{}
+	unframe(t, buf, item)
+	if headers.(*http2.ReqHeader).Req.URL.Path != "/test.v1.ItemService/Create" || !proto.Equal(item, &testItem{Name: "go", Id: 1}) {
+		t.Fatalf("unexpected message: %v", item)
+	}
+
+	for _, c := range []struct {
+		method, uri, body string
+	}{
+		{"GET", "/v2/items", ""},
+		{"POST", "/test.v1.ItemService/Upload", "{}"},
+		{"POST", "/test.v1.ItemService/Create", "{"},
+		{"POST", "/test.v1.ItemService/Create", `{"unknown": 1}`},
+		{"GET", "/v1/items?id=abc", ""},
+	} {
+		if _, _, _, err := tc.TranscodingRequest(ctx, buildHTTPRequest(c.method, c.uri, nil), buffer.NewIoBufferString(c.body), nil); err == nil {
+			t.Fatalf("expected an error for %s %s %s", c.method, c.uri, c.body)
+		}
+	}
+}
+
+func TestTranscodingResponse(t *testing.T) {
+	path := writeDescriptorSet(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	ctx := context.Background()
+	request := func(tc *http2grpc, method, uri string) {
+		if _, _, _, err := tc.TranscodingRequest(ctx, buildHTTPRequest(method, uri, nil), buffer.NewIoBufferString("{}"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// unary
+	tc := newTestTranscoder(t, map[string]interface{}{"proto_descriptor": path})
+	request(tc, "GET", "/v1/items/go")
+	rspHeaders, rspBuf, rspTrailers := buildGRPCResponse("0", &testItem{Name: "go", Id: 1, Kind: 1})
+	headers, buf, trailers, err := tc.TranscodingResponse(ctx, rspHeaders, rspBuf, rspTrailers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, headers, buf, http.OK, "application/json", `{"name":"go","id":"1","kind":"KIND_BOOK"}`)
+	if trailers != nil {
+		t.Fatalf("unexpected trailers: %v", trailers)
+	}
+
+	// response body
+	tc = newTestTranscoder(t, map[string]interface{}{"proto_descriptor": path})
+	request(tc, "GET", "/v1/items")
+	listData, _ := proto.Marshal(&testItem{Name: "a"})
+	list := buffer.NewIoBuffer(0)
+	b := proto.NewBuffer(nil)
+	b.EncodeVarint(1<<3 | proto.WireBytes)
+	b.EncodeRawBytes(listData)
+	list.Write(frame(b.Bytes()))
+	rspHeaders, _, rspTrailers = buildGRPCResponse("0")
+	headers, buf, _, err = tc.TranscodingResponse(ctx, rspHeaders, list, rspTrailers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, headers, buf, http.OK, "application/json", `[{"name":"a"}]`)
+
+	// server streaming
+	items := []proto.Message{&testItem{Name: "a"}, &testItem{Name: "b"}}
+	tc = newTestTranscoder(t, map[string]interface{}{"proto_descriptor": path})
+	request(tc, "POST", "/v1/items:watch")
+	rspHeaders, rspBuf, rspTrailers = buildGRPCResponse("0", items...)
+	headers, buf, _, err = tc.TranscodingResponse(ctx, rspHeaders, rspBuf, rspTrailers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, headers, buf, http.OK, "application/json", `[{"name":"a"},{"name":"b"}]`)
+
+	tc = newTestTranscoder(t, map[string]interface{}{"proto_descriptor": path, "stream_format": "ndjson"})
+	request(tc, "POST", "/v1/items:watch")
+	rspHeaders, rspBuf, rspTrailers = buildGRPCResponse("0", items...)
+	headers, buf, _, err = tc.TranscodingResponse(ctx, rspHeaders, rspBuf, rspTrailers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, headers, buf, http.OK, "application/x-ndjson", "{\"name\":\"a\"}\n{\"name\":\"b\"}\n")
+
+	// grpc errors
+	tc = newTestTranscoder(t, map[string]interface{}{"proto_descriptor": path})
+	request(tc, "GET", "/v1/items/go")
+	rspHeaders, rspBuf, rspTrailers = buildGRPCResponse("5")
+	rspTrailers.Set("grpc-message", "item%20not%20found")
+	headers, buf, _, err = tc.TranscodingResponse(ctx, rspHeaders, rspBuf, rspTrailers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, headers, buf, http.NotFound, "application/json", `{"code":5,"message":"item not found"}`)
+
+	// trailers-only response
+	rspHeaders, _, _ = buildGRPCResponse("0")
+	rspHeaders.Set("grpc-status", "14")
+	headers, buf, _, err = tc.TranscodingResponse(ctx, rspHeaders, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, headers, buf, http.ServiceUnavailable, "application/json", `{"code":14,"message":""}`)
+
+	// compressed message
+	rspHeaders, rspBuf, rspTrailers = buildGRPCResponse("0", &testItem{Name: "go"})
+	rspBuf.Bytes()[0] = 1
+	if _, _, _, err := tc.TranscodingResponse(ctx, rspHeaders, rspBuf, rspTrailers); err != ErrCompressedData {
+		t.Fatalf("expected compressed data error, got %v", err)
+	}
+
+	// the hijacked response
+	hijack := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	headers, _, _, err = tc.TranscodingResponse(ctx, hijack, nil, nil)
+	if err != nil || !reflect.DeepEqual(headers, hijack) {
+		t.Fatalf("the hijacked response should not be transcoded: %v, %v", headers, err)
+	}
+}
+
+func TestHTTPStatusCode(t *testing.T) {
+	body, _ := json.Marshal(&errorBody{Code: 3, Message: "bad"})
+	if string(body) != `{"code":3,"message":"bad"}` {
+		t.Fatalf("unexpected error body: %s", body)
+	}
+	expected := map[int]int{0: 200, 1: 408, 2: 500, 3: 400, 4: 504, 5: 404, 6: 409, 7: 403, 8: 429, 9: 400,
+		10: 409, 11: 400, 12: 501, 13: 500, 14: 503, 15: 500, 16: 401}
+	for code, status := range expected {
+		if got := httpStatusCode(codes.Code(code)); got != status {
+			t.Fatalf("grpc code %d expected %d, got %d", code, status, got)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+const (
+	timestampType = ".google.protobuf.Timestamp"
+	durationType  = ".google.protobuf.Duration"
+	emptyType     = ".google.protobuf.Empty"
+	fieldMaskType = ".google.protobuf.FieldMask"
+	structType    = ".google.protobuf.Struct"
+	valueType     = ".google.protobuf.Value"
+	listValueType = ".google.protobuf.ListValue"
+)
+
+// wrapperTypes are the messages of the wrappers.proto, they are the wrapped values in json
+var wrapperTypes = map[string]*descriptor.DescriptorProto{
+	".google.protobuf.DoubleValue": wrapper(descriptor.FieldDescriptorProto_TYPE_DOUBLE),
+	".google.protobuf.FloatValue":  wrapper(descriptor.FieldDescriptorProto_TYPE_FLOAT),
+	".google.protobuf.Int64Value":  wrapper(descriptor.FieldDescriptorProto_TYPE_INT64),
+	".google.protobuf.UInt64Value": wrapper(descriptor.FieldDescriptorProto_TYPE_UINT64),
+	".google.protobuf.Int32Value":  wrapper(descriptor.FieldDescriptorProto_TYPE_INT32),
+	".google.protobuf.UInt32Value": wrapper(descriptor.FieldDescriptorProto_TYPE_UINT32),
+	".google.protobuf.BoolValue":   wrapper(descriptor.FieldDescriptorProto_TYPE_BOOL),
+	".google.protobuf.StringValue": wrapper(descriptor.FieldDescriptorProto_TYPE_STRING),
+	".google.protobuf.BytesValue":  wrapper(descriptor.FieldDescriptorProto_TYPE_BYTES),
+}
+
+func wrapper(typ descriptor.FieldDescriptorProto_Type) *descriptor.DescriptorProto {
+	return &descriptor.DescriptorProto{
+		Field: []*descriptor.FieldDescriptorProto{{
+			Name:   proto.String("value"),
+			Number: proto.Int32(1),
+			Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}},
+	}
+}
+
+// isWellKnownType reports whether the message has the special json mapping.
+// The google.protobuf.Any is not supported, since the type url can not be resolved.
+func isWellKnownType(typeName string) bool {
+	switch typeName {
+	case timestampType, durationType, emptyType, fieldMaskType, structType, valueType, listValueType:
+		return true
+	}
+	_, ok := wrapperTypes[typeName]
+	return ok
+}
+
+func (r *registry) encodeWellKnown(b *proto.Buffer, typeName string, value interface{}) error {
+	if msg, ok := wrapperTypes[typeName]; ok {
+		if value == nil {
+			return nil
+		}
+		return r.encodeFields(b, typeName, msg, map[string]interface{}{"value": value})
+	}
+
+	switch typeName {
+	case timestampType:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("timestamp should be a RFC 3339 string")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %s", s)
+		}
+		encodeSecondsNanos(b, t.Unix(), int64(t.Nanosecond()))
+	case durationType:
+		s, ok := value.(string)
+		if !ok || !strings.HasSuffix(s, "s") {
+			return fmt.Errorf("duration should be a string with the suffix s")
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %s", s)
+		}
+		encodeSecondsNanos(b, int64(d/time.Second), int64(d%time.Second))
+	case emptyType:
+		if _, ok := value.(map[string]interface{}); !ok && value != nil {
+			return fmt.Errorf("empty should be a json object")
+		}
+	case fieldMaskType:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("field mask should be a string")
+		}
+		for _, path := range strings.Split(s, ",") {
+			if path == "" {
+				continue
+			}
+			b.EncodeVarint(1<<3 | proto.WireBytes)
+			b.EncodeStringBytes(snakeCase(path))
+		}
+	case structType:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("struct should be a json object")
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entry := proto.NewBuffer(nil)
+			entry.EncodeVarint(1<<3 | proto.WireBytes)
+			entry.EncodeStringBytes(key)
+			if err := r.encodeWellKnownField(entry, 2, valueType, obj[key]); err != nil {
+				return err
+			}
+			b.EncodeVarint(1<<3 | proto.WireBytes)
+			b.EncodeRawBytes(entry.Bytes())
+		}
+	case valueType:
+		switch v := value.(type) {
+		case nil:
+			// null_value is the only value of the enum NullValue
+			b.EncodeVarint(1<<3 | proto.WireVarint)
+			b.EncodeVarint(0)
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return err
+			}
+			b.EncodeVarint(2<<3 | proto.WireFixed64)
+			b.EncodeFixed64(math.Float64bits(f))
+		case string:
+			b.EncodeVarint(3<<3 | proto.WireBytes)
+			b.EncodeStringBytes(v)
+		case bool:
+			b.EncodeVarint(4<<3 | proto.WireVarint)
+			if v {
+				b.EncodeVarint(1)
+			} else {
+				b.EncodeVarint(0)
+			}
+		case map[string]interface{}:
+			return r.encodeWellKnownField(b, 5, structType, v)
+		case []interface{}:
+			return r.encodeWellKnownField(b, 6, listValueType, v)
+		default:
+			return fmt.Errorf("unsupported value %v", value)
+		}
+	case listValueType:
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("list value should be a json array")
+		}
+		for _, v := range list {
+			if err := r.encodeWellKnownField(b, 1, valueType, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeWellKnownField encodes the field whose type is the well known message
+func (r *registry) encodeWellKnownField(b *proto.Buffer, number uint64, typeName string, value interface{}) error {
+	tmp := proto.NewBuffer(nil)
+	if err := r.encodeWellKnown(tmp, typeName, value); err != nil {
+		return err
+	}
+	b.EncodeVarint(number<<3 | proto.WireBytes)
+	return b.EncodeRawBytes(tmp.Bytes())
+}
+
+func encodeSecondsNanos(b *proto.Buffer, seconds, nanos int64) {
+	if seconds != 0 {
+		b.EncodeVarint(1<<3 | proto.WireVarint)
+		b.EncodeVarint(uint64(seconds))
+	}
+	if nanos != 0 {
+		b.EncodeVarint(2<<3 | proto.WireVarint)
+		b.EncodeVarint(uint64(nanos))
+	}
+}
+
+func (r *registry) decodeWellKnown(data []byte, typeName string) (interface{}, error) {
+	if msg, ok := wrapperTypes[typeName]; ok {
+		obj, err := r.decodeFields(data, typeName, msg)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := obj.get("value"); ok {
+			return v, nil
+		}
+		return defaultValue(msg.Field[0]), nil
+	}
+
+	fields, err := decodeRawFields(data)
+	if err != nil {
+		return nil, err
+	}
+	switch typeName {
+	case timestampType:
+		seconds, nanos := secondsNanos(fields)
+		return time.Unix(seconds, nanos).UTC().Format(time.RFC3339Nano), nil
+	case durationType:
+		seconds, nanos := secondsNanos(fields)
+		return formatDuration(seconds, nanos), nil
+	case emptyType:
+		return jsonObject{}, nil
+	case fieldMaskType:
+		paths := make([]string, 0, len(fields))
+		for _, f := range fields {
+			if f.number == 1 {
+				paths = append(paths, lowerCamelCase(string(f.data)))
+			}
+		}
+		return strings.Join(paths, ","), nil
+	case structType:
+		obj := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			if f.number != 1 {
+				continue
+			}
+			entry, err := decodeRawFields(f.data)
+			if err != nil {
+				return nil, err
+			}
+			var key string
+			var value interface{}
+			for _, e := range entry {
+				switch e.number {
+				case 1:
+					key = string(e.data)
+				case 2:
+					if value, err = r.decodeWellKnown(e.data, valueType); err != nil {
+						return nil, err
+					}
+				}
+			}
+			obj[key] = value
+		}
+		return obj, nil
+	case valueType:
+		var value interface{}
+		for _, f := range fields {
+			switch f.number {
+			case 1:
+				value = nil
+			case 2:
+				value = floatValue(math.Float64frombits(f.x), 64)
+			case 3:
+				value = string(f.data)
+			case 4:
+				value = f.x != 0
+			case 5:
+				if value, err = r.decodeWellKnown(f.data, structType); err != nil {
+					return nil, err
+				}
+			case 6:
+				if value, err = r.decodeWellKnown(f.data, listValueType); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return value, nil
+	case listValueType:
+		list := make([]interface{}, 0, len(fields))
+		for _, f := range fields {
+			if f.number != 1 {
+				continue
+			}
+			value, err := r.decodeWellKnown(f.data, valueType)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unknown message %s", typeName)
+}
+
+// rawField is the field on the wire, x is the value of the numeric fields, and data is the value of the bytes fields
+type rawField struct {
+	number int32
+	x      uint64
+	data   []byte
+}
+
+func decodeRawFields(data []byte) ([]rawField, error) {
+	var fields []rawField
+	w := &wireReader{data: data}
+	for !w.done() {
+		key, err := w.varint()
+		if err != nil {
+			return nil, err
+		}
+		f := rawField{number: int32(key >> 3)}
+		switch key & 7 {
+		case proto.WireVarint:
+			f.x, err = w.varint()
+		case proto.WireFixed64:
+			f.x, err = w.fixed64()
+		case proto.WireFixed32:
+			f.x, err = w.fixed32()
+		case proto.WireBytes:
+			f.data, err = w.bytes()
+		default:
+			err = fmt.Errorf("unsupported wire type %d", key&7)
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func secondsNanos(fields []rawField) (int64, int64) {
+	var seconds, nanos int64
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			seconds = int64(f.x)
+		case 2:
+			nanos = int64(int32(f.x))
+		}
+	}
+	return seconds, nanos
+}
+
+// formatDuration formats the duration as the seconds with the suffix s, such as 1.5s
+func formatDuration(seconds, nanos int64) string {
+	sign := ""
+	if seconds < 0 || nanos < 0 {
+		sign, seconds, nanos = "-", -seconds, -nanos
+	}
+	s := sign + strconv.FormatInt(seconds, 10)
+	if nanos != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%09d", nanos), "0")
+	}
+	return s + "s"
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if c >= 'A' && c <= 'Z' {
+			b.WriteByte('_')
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func lowerCamelCase(s string) string {
+	return jsonName(&descriptor.FieldDescriptorProto{Name: proto.String(s)})
+}
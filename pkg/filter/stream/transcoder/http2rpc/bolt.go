@@ -0,0 +1,139 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2rpc
+
+import (
+	"context"
+	"fmt"
+
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func init() {
+	transcoder.MustRegisterFactory("http2bolt", newHTTP2Bolt)
+}
+
+// http2bolt transcodes the http json requests to the sofarpc hessian2 invocations over bolt
+type http2bolt struct {
+	cfg *config
+}
+
+func newHTTP2Bolt(cfg map[string]interface{}) (transcoder.Transcoder, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if c.Version == "" {
+		c.Version = defaultSofaVersion
+	}
+	return &http2bolt{cfg: c}, nil
+}
+
+func (t *http2bolt) Accept(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) bool {
+	return accept(headers)
+}
+
+func (t *http2bolt) TranscodingRequest(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	inv, err := parseInvocation(t.cfg, headers, buf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	args, err := dubbo.ConvertArguments(inv.types, inv.arguments)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// the unique name of the sofarpc service is interface:version[:uniqueId]
+	service := inv.service + ":" + t.cfg.Version
+	if t.cfg.UniqueID != "" {
+		service += ":" + t.cfg.UniqueID
+	}
+	sigs := inv.types
+	if sigs == nil {
+		sigs = []string{}
+	}
+	content, err := encodeSofaRequest(&sofaRequest{
+		TargetAppName:           t.cfg.TargetApp,
+		MethodName:              inv.method,
+		TargetServiceUniqueName: service,
+		RequestProps:            inv.attachments,
+		MethodArgSigs:           sigs,
+	}, args)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("encode sofarpc request failed: %v", err)
+	}
+
+	rpcHeaders := protocol.CommonHeader{
+		types.SofaRouteMatchKey: service,
+		sofaHeaderService:       service,
+		sofaHeaderMethod:        inv.method,
+	}
+	if t.cfg.TargetApp != "" {
+		rpcHeaders[sofaHeaderApp] = t.cfg.TargetApp
+	}
+	data := buffer.NewIoBufferBytes(content)
+	request := bolt.NewRpcRequest(0, rpcHeaders, data)
+	request.Class = sofaRequestClass
+	mosnctx.WithValue(ctx, types.ContextSubProtocol, string(bolt.ProtocolName))
+	return request, data, trailers, nil
+}
+
+func (t *http2bolt) TranscodingResponse(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	response, ok := headers.(*bolt.Response)
+	if !ok {
+		// the responses hijacked by the proxy
+		return headers, buf, trailers, nil
+	}
+	var content []byte
+	if buf != nil {
+		content = buf.Bytes()
+	}
+	if response.ResponseStatus != bolt.ResponseStatusSuccess {
+		statusCode, err := xprotocol.GetMapping(bolt.ProtocolName).MappingHeaderStatusCode(ctx, response)
+		if err != nil {
+			statusCode = http.InternalServerError
+		}
+		body := &errorBody{
+			Error: fmt.Sprintf("bolt response status %d", response.ResponseStatus),
+		}
+		// the server exceptions may be described by the response object
+		if result, err := decodeSofaResponse(content); err == nil && result.ErrorMsg != "" {
+			body.Error = result.ErrorMsg
+		}
+		return jsonResponse(statusCode, body)
+	}
+	result, err := decodeSofaResponse(content)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if result.IsError {
+		return jsonResponse(http.InternalServerError, &errorBody{Error: result.ErrorMsg})
+	}
+	// the exceptions thrown by the provider are the app responses
+	if _, ok := result.AppResponse.(error); ok {
+		return exceptionResponse(result.AppResponse)
+	}
+	return jsonResponse(http.OK, dubbo.ToJSONValue(result.AppResponse))
+}
@@ -0,0 +1,168 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2rpc
+
+import (
+	"reflect"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// testSofaResponse encodes the response object as the sofarpc providers
+type testSofaResponse struct {
+	IsError       bool              `hessian:"isError"`
+	ErrorMsg      string            `hessian:"errorMsg"`
+	AppResponse   interface{}       `hessian:"appResponse"`
+	ResponseProps map[string]string `hessian:"responseProps"`
+}
+
+func (r *testSofaResponse) JavaClassName() string {
+	return sofaResponseClass
+}
+
+func init() {
+	hessian.RegisterPOJO(&testSofaResponse{})
+}
+
+func buildBoltResponse(t *testing.T, status uint16, response *testSofaResponse) *bolt.Response {
+	var data types.IoBuffer
+	if response != nil {
+		encoder := hessian.NewEncoder()
+		if err := encoder.Encode(response); err != nil {
+			t.Fatal(err)
+		}
+		data = buffer.NewIoBufferBytes(encoder.Buffer())
+	}
+	resp := bolt.NewRpcResponse(1, status, nil, data)
+	resp.Class = sofaResponseClass
+	return resp
+}
+
+func TestHTTP2Bolt(t *testing.T) {
+	tc, err := transcoder.CreateTranscoder("http2bolt", map[string]interface{}{
+		"service":    "com.foo.UserService",
+		"target_app": "user",
+		"methods": map[string]interface{}{
+			"query": []string{"java.lang.String", "int"},
+		},
+		"attachments": map[string]string{"x-tag": "tag"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext()
+	reqHeaders, reqBuf, _, err := tc.TranscodingRequest(ctx, buildHTTPRequest("/users/query", map[string]string{"x-tag": "gray"}),
+		buffer.NewIoBufferString(`["bob", 18]`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub := mosnctx.Get(ctx, types.ContextSubProtocol); sub != string(bolt.ProtocolName) {
+		t.Fatalf("unexpected sub protocol: %v", sub)
+	}
+	request := reqHeaders.(*bolt.Request)
+	if request.Class != sofaRequestClass || request.Codec != bolt.Hessian2Serialize || request.Content != reqBuf {
+		t.Fatalf("unexpected request: %+v", request.RequestHeader)
+	}
+	for k, v := range map[string]string{
+		types.SofaRouteMatchKey: "com.foo.UserService:1.0",
+		sofaHeaderService:       "com.foo.UserService:1.0",
+		sofaHeaderMethod:        "query",
+		sofaHeaderApp:           "user",
+	} {
+		if value, _ := request.Get(k); value != v {
+			t.Errorf("header %s: expected %s, got %s", k, v, value)
+		}
+	}
+	decoder := hessian.NewDecoder(reqBuf.Bytes())
+	var fields []interface{}
+	for i := 0; i < 3; i++ {
+		field, err := decoder.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, field)
+	}
+	expected := &sofaRequest{
+		TargetAppName:           "user",
+		MethodName:              "query",
+		TargetServiceUniqueName: "com.foo.UserService:1.0",
+		RequestProps:            map[string]string{"tag": "gray"},
+		MethodArgSigs:           []string{"java.lang.String", "int"},
+	}
+	if !reflect.DeepEqual(fields, []interface{}{expected, "bob", int32(18)}) {
+		t.Fatalf("unexpected content: %#v", fields)
+	}
+
+	shared := map[interface{}]interface{}{"name": "bob"}
+	for i, c := range []struct {
+		response   *bolt.Response
+		statusCode int
+		body       string
+	}{
+		{
+			buildBoltResponse(t, bolt.ResponseStatusSuccess, &testSofaResponse{AppResponse: []interface{}{shared, shared}}),
+			200, `[{"name": "bob"}, {"name": "bob"}]`,
+		},
+		{
+			buildBoltResponse(t, bolt.ResponseStatusSuccess, &testSofaResponse{AppResponse: "hello"}),
+			200, `"hello"`,
+		},
+		{
+			buildBoltResponse(t, bolt.ResponseStatusSuccess, &testSofaResponse{IsError: true, ErrorMsg: "no such method"}),
+			500, `{"error": "no such method"}`,
+		},
+		{
+			buildBoltResponse(t, bolt.ResponseStatusSuccess, &testSofaResponse{AppResponse: java_exception.NewRuntimeException("invalid name")}),
+			500, `{"error": "invalid name", "exception": "java.lang.RuntimeException"}`,
+		},
+		{
+			buildBoltResponse(t, bolt.ResponseStatusServerException, &testSofaResponse{IsError: true, ErrorMsg: "server busy"}),
+			500, `{"error": "server busy"}`,
+		},
+		{
+			buildBoltResponse(t, bolt.ResponseStatusTimeout, nil),
+			504, `{"error": "bolt response status 7"}`,
+		},
+	} {
+		respHeaders, respBuf, _, err := tc.TranscodingResponse(ctx, c.response, c.response.Content, nil)
+		if err != nil {
+			t.Fatalf("#%d transcode response failed: %v", i, err)
+		}
+		checkJSONResponse(t, respHeaders, respBuf, c.statusCode, c.body)
+	}
+}
+
+func TestDecodeSofaResponseMalformed(t *testing.T) {
+	encoder := hessian.NewEncoder()
+	encoder.Encode(java_exception.NewThrowable("not a response"))
+	for _, content := range [][]byte{nil, {'C'}, {'C', 0x03, 'a', 'b'}, encoder.Buffer()} {
+		if _, err := decodeSofaResponse(content); err == nil {
+			t.Errorf("expected error for %v", content)
+		}
+	}
+	if _, err := transcoder.CreateTranscoder("http2bolt", map[string]interface{}{"methods": 1}); err == nil {
+		t.Error("expected invalid config")
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2rpc
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const defaultSofaVersion = "1.0"
+
+var ErrNoService = errors.New("either service or services should be configured")
+
+type config struct {
+	// Service is the interface of the rpc service.
+	// The request path is /{service}/{method} if it is empty, otherwise the last segment of the path is the method.
+	Service string `json:"service,omitempty"`
+	// Services are the interfaces that can be called by the path /{service}/{method}, it is required if Service is empty
+	Services []string `json:"services,omitempty"`
+	// Version is the version of the service, the sofarpc service uses 1.0 by default
+	Version string `json:"version,omitempty"`
+	// Group is the group of the dubbo service
+	Group string `json:"group,omitempty"`
+	// UniqueID is the unique id of the sofarpc service
+	UniqueID string `json:"unique_id,omitempty"`
+	// TargetApp is the application of the sofarpc service
+	TargetApp string `json:"target_app,omitempty"`
+	// Generic invokes the methods by the GenericService of the dubbo providers
+	Generic bool `json:"generic,omitempty"`
+	// Methods are the java parameter types of the methods, the methods that are not configured are not allowed
+	Methods map[string][]string `json:"methods,omitempty"`
+	// Attachments maps the http headers to the attachments of dubbo, or the request props of sofarpc
+	Attachments map[string]string `json:"attachments,omitempty"`
+
+	services map[string]struct{}
+}
+
+func parseConfig(cfg interface{}) (*config, error) {
+	filterConfig := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	if filterConfig.Service == "" && len(filterConfig.Services) == 0 {
+		return nil, ErrNoService
+	}
+	filterConfig.services = make(map[string]struct{}, len(filterConfig.Services))
+	for _, service := range filterConfig.Services {
+		filterConfig.services[service] = struct{}{}
+	}
+	return filterConfig, nil
+}
@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2rpc
+
+import (
+	"context"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	transcoder.MustRegisterFactory("http2dubbo", newHTTP2Dubbo)
+}
+
+// http2dubbo transcodes the http json requests to the dubbo hessian2 invocations
+type http2dubbo struct {
+	cfg *config
+}
+
+func newHTTP2Dubbo(cfg map[string]interface{}) (transcoder.Transcoder, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http2dubbo{cfg: c}, nil
+}
+
+func (t *http2dubbo) Accept(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) bool {
+	return accept(headers)
+}
+
+func (t *http2dubbo) TranscodingRequest(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	inv, err := parseInvocation(t.cfg, headers, buf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	request, err := dubbo.NewGenericRequest(&dubbo.GenericRequest{
+		Interface:      inv.service,
+		Version:        t.cfg.Version,
+		Group:          t.cfg.Group,
+		Method:         inv.method,
+		ParameterTypes: inv.types,
+		Arguments:      inv.arguments,
+		Attachments:    inv.attachments,
+		Generic:        t.cfg.Generic,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	mosnctx.WithValue(ctx, types.ContextSubProtocol, string(dubbo.ProtocolName))
+	return request, request.GetData(), trailers, nil
+}
+
+func (t *http2dubbo) TranscodingResponse(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	response, ok := headers.(*dubbo.Frame)
+	if !ok {
+		// the responses hijacked by the proxy
+		return headers, buf, trailers, nil
+	}
+	result, err := response.DecodeResult()
+	if err != nil {
+		if e, ok := err.(*dubbo.ResponseError); ok {
+			return jsonResponse(dubboStatusCode(e.Status), &errorBody{Error: e.Message})
+		}
+		return nil, nil, nil, err
+	}
+	if result.HasException {
+		return exceptionResponse(result.Exception)
+	}
+	return jsonResponse(http.OK, result.Value)
+}
+
+func dubboStatusCode(status byte) int {
+	switch status {
+	case hessian.Response_CLIENT_TIMEOUT, hessian.Response_SERVER_TIMEOUT:
+		return http.GatewayTimeout
+	case hessian.Response_BAD_REQUEST:
+		return http.BadRequest
+	case hessian.Response_SERVICE_NOT_FOUND:
+		return http.NotFound
+	case hessian.Response_BAD_RESPONSE:
+		return http.BadGateway
+	default:
+		return http.InternalServerError
+	}
+}
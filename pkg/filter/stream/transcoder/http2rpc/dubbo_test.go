@@ -0,0 +1,249 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/valyala/fasthttp"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func buildHTTPRequest(uri string, headers map[string]string) http.RequestHeader {
+	header := &fasthttp.RequestHeader{}
+	header.SetMethod("POST")
+	header.SetRequestURI(uri)
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+	return http.RequestHeader{RequestHeader: header}
+}
+
+func newContext() context.Context {
+	return mosnctx.WithValue(context.Background(), types.ContextKeyStreamID, uint64(1))
+}
+
+// checkJSONResponse checks the status code and the json body of the transcoded response
+func checkJSONResponse(t *testing.T, headers types.HeaderMap, buf types.IoBuffer, statusCode int, expected string) {
+	t.Helper()
+	header, ok := headers.(http.ResponseHeader)
+	if !ok {
+		t.Fatalf("unexpected response headers: %T", headers)
+	}
+	if header.StatusCode() != statusCode || string(header.ContentType()) != "application/json" {
+		t.Fatalf("unexpected status: %d, %s", header.StatusCode(), header.ContentType())
+	}
+	var got, want interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal([]byte(expected), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected body %s, got %s", expected, buf.String())
+	}
+}
+
+func TestParseInvocation(t *testing.T) {
+	cfg, err := parseConfig(map[string]interface{}{
+		"services": []string{"com.foo.UserService", "com.foo.Calculator"},
+		"methods": map[string][]string{
+			"query": {"com.foo.Query"},
+			"add":   {"int", "int"},
+			"ping":  {},
+		},
+		"attachments": map[string]string{"x-tag": "dubbo.tag"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := parseInvocation(cfg, buildHTTPRequest("/api/com.foo.UserService/query?debug=1", map[string]string{"x-tag": "gray"}),
+		buffer.NewIoBufferString(`{"name": "bob"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.service != "com.foo.UserService" || inv.method != "query" || len(inv.arguments) != 1 ||
+		!reflect.DeepEqual(inv.arguments[0], map[string]interface{}{"name": "bob"}) || inv.attachments["dubbo.tag"] != "gray" {
+		t.Fatalf("unexpected invocation: %+v", inv)
+	}
+	inv, err = parseInvocation(cfg, buildHTTPRequest("/com.foo.Calculator/add", nil), buffer.NewIoBufferString(`[1, 2]`))
+	if err != nil || len(inv.arguments) != 2 || inv.attachments != nil {
+		t.Fatalf("unexpected invocation: %+v, %v", inv, err)
+	}
+	// the configured service is not read from the path
+	inv, err = parseInvocation(&config{Service: "com.foo.UserService", Methods: cfg.Methods}, buildHTTPRequest("/users/ping", nil), nil)
+	if err != nil || inv.service != "com.foo.UserService" || inv.method != "ping" || len(inv.arguments) != 0 {
+		t.Fatalf("unexpected invocation: %+v, %v", inv, err)
+	}
+
+	// the services and methods that are not configured are not found
+	for _, uri := range []string{"/com.foo.AdminService/ping", "/com.foo.UserService/delete"} {
+		_, err := parseInvocation(cfg, buildHTTPRequest(uri, nil), nil)
+		if e, ok := err.(*transcoder.StatusError); !ok || e.StatusCode != http.NotFound {
+			t.Errorf("expected not found for %s, but got %v", uri, err)
+		}
+	}
+	if _, err := parseConfig(map[string]interface{}{"methods": cfg.Methods}); err != ErrNoService {
+		t.Errorf("expected the service required, but got %v", err)
+	}
+
+	for _, tc := range []struct {
+		uri  string
+		body string
+	}{
+		{"/query", ""},
+		{"/com.foo.UserService/", ""},
+		{"/com.foo.Calculator/add", `{"a": 1}`},
+		{"/com.foo.Calculator/add", `[1]`},
+		{"/com.foo.Calculator/add", `[1,`},
+		{"/com.foo.UserService/ping", `[1]`},
+	} {
+		if _, err := parseInvocation(cfg, buildHTTPRequest(tc.uri, nil), buffer.NewIoBufferString(tc.body)); err == nil {
+			t.Errorf("expected error for %s %s", tc.uri, tc.body)
+		}
+	}
+}
+
+func buildDubboResponse(t *testing.T, status byte, fields ...interface{}) *dubbo.Frame {
+	encoder := hessian.NewEncoder()
+	for _, v := range fields {
+		if err := encoder.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payload := encoder.Buffer()
+	header := make([]byte, dubbo.HeaderLen)
+	copy(header, dubbo.MagicTag)
+	header[dubbo.FlagIdx] = 0x02 // response, hessian2
+	header[dubbo.StatusIdx] = status
+	binary.BigEndian.PutUint32(header[dubbo.DataLenIdx:], uint32(len(payload)))
+	return dubbo.NewRpcResponse(nil, buffer.NewIoBufferBytes(append(header, payload...)))
+}
+
+func TestHTTP2Dubbo(t *testing.T) {
+	tc, err := transcoder.CreateTranscoder("http2dubbo", map[string]interface{}{
+		"service": "com.foo.UserService",
+		"version": "1.0.0",
+		"methods": map[string]interface{}{
+			"get": []string{"long"},
+		},
+		"attachments": map[string]string{"x-tag": "dubbo.tag"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := buildHTTPRequest("/users/get", map[string]string{"x-tag": "gray"})
+	if !tc.Accept(context.Background(), headers, nil, nil) {
+		t.Fatal("http request should be accepted")
+	}
+	ctx := newContext()
+	reqHeaders, reqBuf, _, err := tc.TranscodingRequest(ctx, headers, buffer.NewIoBufferString(`"42"`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub := mosnctx.Get(ctx, types.ContextSubProtocol); sub != string(dubbo.ProtocolName) {
+		t.Fatalf("unexpected sub protocol: %v", sub)
+	}
+	request := reqHeaders.(*dubbo.Frame)
+	if reqBuf != request.GetData() {
+		t.Fatal("the data should be the payload of the request")
+	}
+	inv, err := request.DecodeInvocation(-1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Service != "com.foo.UserService" || inv.Version != "1.0.0" || inv.Method != "get" || inv.ParameterTypes != "J" ||
+		!reflect.DeepEqual(inv.Arguments, []interface{}{int64(42)}) || inv.Attachments["dubbo.tag"] != "gray" {
+		t.Fatalf("unexpected invocation: %+v", inv)
+	}
+
+	for i, c := range []struct {
+		response   *dubbo.Frame
+		statusCode int
+		body       string
+	}{
+		{
+			buildDubboResponse(t, hessian.Response_OK, hessian.RESPONSE_VALUE, map[interface{}]interface{}{"id": int64(42), "name": "bob"}),
+			200, `{"id": 42, "name": "bob"}`,
+		},
+		{
+			buildDubboResponse(t, hessian.Response_OK, hessian.RESPONSE_NULL_VALUE),
+			200, `null`,
+		},
+		{
+			buildDubboResponse(t, hessian.Response_OK, hessian.RESPONSE_WITH_EXCEPTION, java_exception.NewThrowable("user not found")),
+			500, `{"error": "user not found", "exception": "java.lang.Throwable"}`,
+		},
+		{
+			buildDubboResponse(t, hessian.Response_SERVICE_NOT_FOUND, "no provider"),
+			404, `{"error": "no provider"}`,
+		},
+		{
+			buildDubboResponse(t, hessian.Response_SERVER_TIMEOUT, "timeout"),
+			504, `{"error": "timeout"}`,
+		},
+	} {
+		respHeaders, respBuf, _, err := tc.TranscodingResponse(ctx, c.response, c.response.GetData(), nil)
+		if err != nil {
+			t.Fatalf("#%d transcode response failed: %v", i, err)
+		}
+		checkJSONResponse(t, respHeaders, respBuf, c.statusCode, c.body)
+	}
+
+	// the hijacked responses are not transcoded
+	hijack := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	if respHeaders, _, _, err := tc.TranscodingResponse(ctx, hijack, nil, nil); err != nil ||
+		respHeaders.(http.ResponseHeader).ResponseHeader != hijack.ResponseHeader {
+		t.Fatalf("unexpected hijack response: %v, %v", respHeaders, err)
+	}
+}
+
+func TestHTTP2DubboGeneric(t *testing.T) {
+	tc, err := transcoder.CreateTranscoder("http2dubbo", map[string]interface{}{
+		"generic":  true,
+		"services": []string{"com.foo.Calculator"},
+		"methods": map[string]interface{}{
+			"add": []string{"int", "int"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqHeaders, _, _, err := tc.TranscodingRequest(newContext(), buildHTTPRequest("/com.foo.Calculator/add", nil), buffer.NewIoBufferString(`[1, 2]`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := reqHeaders.(*dubbo.Frame).DecodeInvocation(-1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Service != "com.foo.Calculator" || inv.Method != dubbo.GenericMethod || inv.Attachments["generic"] != "true" ||
+		!reflect.DeepEqual(inv.Arguments[2], []interface{}{int32(1), int32(2)}) {
+		t.Fatalf("unexpected invocation: %+v", inv)
+	}
+}
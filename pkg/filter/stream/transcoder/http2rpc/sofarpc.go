@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2rpc
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+)
+
+const (
+	sofaRequestClass  = "com.alipay.sofa.rpc.core.request.SofaRequest"
+	sofaResponseClass = "com.alipay.sofa.rpc.core.response.SofaResponse"
+)
+
+// the bolt headers used by the sofarpc providers
+const (
+	sofaHeaderService = "sofa_head_target_service"
+	sofaHeaderMethod  = "sofa_head_method_name"
+	sofaHeaderApp     = "sofa_head_target_app"
+)
+
+var errMalformedSofaResponse = errors.New("malformed sofarpc response")
+
+// responsePlaceholder is a class definition without fields, followed by an empty map
+var responsePlaceholder = []byte{
+	hessian.BC_OBJECT_DEF,
+	0x10, 'j', 'a', 'v', 'a', '.', 'l', 'a', 'n', 'g', '.', 'O', 'b', 'j', 'e', 'c', 't',
+	0x90,
+	hessian.BC_MAP_UNTYPED, hessian.BC_END,
+}
+
+func init() {
+	hessian.RegisterPOJO(&sofaRequest{})
+}
+
+// sofaRequest is the hessian2 object of the sofarpc request, the arguments follow it in the content
+type sofaRequest struct {
+	TargetAppName           string            `hessian:"targetAppName"`
+	MethodName              string            `hessian:"methodName"`
+	TargetServiceUniqueName string            `hessian:"targetServiceUniqueName"`
+	RequestProps            map[string]string `hessian:"requestProps"`
+	MethodArgSigs           []string          `hessian:"methodArgSigs"`
+}
+
+func (r *sofaRequest) JavaClassName() string {
+	return sofaRequestClass
+}
+
+func encodeSofaRequest(request *sofaRequest, args []interface{}) ([]byte, error) {
+	encoder := hessian.NewEncoder()
+	if err := encoder.Encode(request); err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		if err := encoder.Encode(arg); err != nil {
+			return nil, err
+		}
+	}
+	return encoder.Buffer(), nil
+}
+
+// sofaResponse is the hessian2 object of the sofarpc response
+type sofaResponse struct {
+	IsError     bool
+	ErrorMsg    string
+	AppResponse interface{}
+}
+
+// decodeSofaResponse decodes the response object.
+// The fields of a registered object should have the declared types, but the app response can be
+// any value, so the class definition is read here and the fields are decoded one by one.
+func decodeSofaResponse(content []byte) (*sofaResponse, error) {
+	r := &hessianReader{data: content}
+	if tag := r.byte(); tag != hessian.BC_OBJECT_DEF {
+		return nil, errMalformedSofaResponse
+	}
+	if class := r.string(); class != sofaResponseClass {
+		return nil, fmt.Errorf("unexpected response class %s", class)
+	}
+	fields := make([]string, r.int())
+	for i := range fields {
+		fields[i] = r.string()
+	}
+	switch tag := r.byte(); {
+	case tag == hessian.BC_OBJECT:
+		r.int()
+	case tag >= hessian.BC_OBJECT_DIRECT && tag <= hessian.BC_OBJECT_DIRECT+hessian.OBJECT_DIRECT_MAX:
+	default:
+		return nil, errMalformedSofaResponse
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// the response object is the first class definition and the first reference of the encoder,
+	// a placeholder takes its place, so the class indexes and the references of the fields are kept.
+	data := make([]byte, 0, len(responsePlaceholder)+len(r.data)-r.pos)
+	data = append(append(data, responsePlaceholder...), r.data[r.pos:]...)
+	decoder := hessian.NewDecoderWithSkip(data)
+	if _, err := decoder.Decode(); err != nil {
+		return nil, err
+	}
+	response := &sofaResponse{}
+	for _, field := range fields {
+		value, err := decoder.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("decode %s failed: %v", field, err)
+		}
+		switch field {
+		case "isError":
+			response.IsError, _ = value.(bool)
+		case "errorMsg":
+			response.ErrorMsg, _ = value.(string)
+		case "appResponse":
+			response.AppResponse = value
+		}
+	}
+	return response, nil
+}
+
+// hessianReader reads the strings and ints of a class definition
+type hessianReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *hessianReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.err = errMalformedSofaResponse
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *hessianReader) int() int {
+	b := r.byte()
+	switch {
+	case b >= 0x80 && b <= 0xbf:
+		return int(b) - 0x90
+	case b >= 0xc0 && b <= 0xcf:
+		return (int(b)-0xc8)<<8 | int(r.byte())
+	case b >= 0xd0 && b <= 0xd7:
+		return (int(b)-0xd4)<<16 | int(r.byte())<<8 | int(r.byte())
+	case b == 'I':
+		return int(int32(uint32(r.byte())<<24 | uint32(r.byte())<<16 | uint32(r.byte())<<8 | uint32(r.byte())))
+	}
+	r.err = errMalformedSofaResponse
+	return 0
+}
+
+// string reads the string that is not chunked, the length is the count of the characters
+func (r *hessianReader) string() string {
+	b := r.byte()
+	var n int
+	switch {
+	case b <= 0x1f:
+		n = int(b)
+	case b >= 0x30 && b <= 0x33:
+		n = (int(b)-0x30)<<8 | int(r.byte())
+	case b == 'S':
+		n = int(r.byte())<<8 | int(r.byte())
+	default:
+		r.err = errMalformedSofaResponse
+		return ""
+	}
+	start := r.pos
+	for i := 0; i < n && r.err == nil; i++ {
+		if r.pos >= len(r.data) {
+			r.err = errMalformedSofaResponse
+			break
+		}
+		_, size := utf8.DecodeRune(r.data[r.pos:])
+		r.pos += size
+	}
+	if r.err != nil {
+		return ""
+	}
+	return string(r.data[start:r.pos])
+}
@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+var (
+	ErrInvalidPath     = errors.New("the path should be /{service}/{method}")
+	ErrServiceNotFound = errors.New("the service is not allowed")
+	ErrMethodNotFound  = errors.New("the method is not allowed")
+)
+
+// invocation is the rpc invocation described by the http request
+type invocation struct {
+	service     string
+	method      string
+	types       []string
+	arguments   []interface{}
+	attachments map[string]string
+}
+
+// errorBody is the json body of the failed responses
+type errorBody struct {
+	Error string `json:"error"`
+	// Exception is the java class of the exception thrown by the provider
+	Exception string `json:"exception,omitempty"`
+}
+
+func accept(headers types.HeaderMap) bool {
+	_, ok := headers.(http.RequestHeader)
+	return ok
+}
+
+// parseInvocation maps the path to the service and method, and the json body to the arguments.
+// The body is the argument if the method has exactly one parameter, otherwise it is the array of the arguments.
+// The services and methods that are not configured are replied with 404.
+func parseInvocation(cfg *config, headers types.HeaderMap, buf types.IoBuffer) (*invocation, error) {
+	header := headers.(http.RequestHeader)
+	uri := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(uri)
+	uri.Parse(nil, header.RequestURI())
+
+	segments := strings.Split(strings.Trim(string(uri.Path()), "/"), "/")
+	inv := &invocation{
+		service: cfg.Service,
+		method:  segments[len(segments)-1],
+	}
+	if inv.service == "" && len(segments) > 1 {
+		inv.service = segments[len(segments)-2]
+		if _, ok := cfg.services[inv.service]; !ok {
+			return nil, &transcoder.StatusError{StatusCode: http.NotFound, Err: ErrServiceNotFound}
+		}
+	}
+	if inv.service == "" || inv.method == "" {
+		return nil, ErrInvalidPath
+	}
+	paramTypes, ok := cfg.Methods[inv.method]
+	if !ok {
+		return nil, &transcoder.StatusError{StatusCode: http.NotFound, Err: ErrMethodNotFound}
+	}
+	inv.types = paramTypes
+
+	if buf != nil && buf.Len() > 0 {
+		var body interface{}
+		if err := json.Unmarshal(buf.Bytes(), &body); err != nil {
+			return nil, fmt.Errorf("invalid json body: %v", err)
+		}
+		if len(inv.types) == 1 {
+			inv.arguments = []interface{}{body}
+		} else if args, ok := body.([]interface{}); ok {
+			inv.arguments = args
+		} else {
+			return nil, fmt.Errorf("method %s needs an array of %d arguments", inv.method, len(inv.types))
+		}
+	}
+	if len(inv.arguments) != len(inv.types) {
+		return nil, fmt.Errorf("method %s needs %d arguments, but got %d", inv.method, len(inv.types), len(inv.arguments))
+	}
+
+	for name, key := range cfg.Attachments {
+		if value, ok := headers.Get(name); ok {
+			if inv.attachments == nil {
+				inv.attachments = make(map[string]string, len(cfg.Attachments))
+			}
+			inv.attachments[key] = value
+		}
+	}
+	return inv, nil
+}
+
+// jsonResponse builds the http response with the json body
+func jsonResponse(statusCode int, body interface{}) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("marshal response failed: %v", err)
+	}
+	header := &fasthttp.ResponseHeader{}
+	header.SetStatusCode(statusCode)
+	header.SetContentType("application/json")
+	return http.ResponseHeader{ResponseHeader: header}, buffer.NewIoBufferBytes(data), nil, nil
+}
+
+// exceptionResponse builds the response of the exception thrown by the provider.
+// The exceptions whose class is not registered in hessian are decoded as nil.
+func exceptionResponse(exception interface{}) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	body := &errorBody{
+		Error: "the provider throws an exception",
+	}
+	if e, ok := exception.(error); ok {
+		body.Error = e.Error()
+	}
+	if c, ok := exception.(interface{ JavaClassName() string }); ok {
+		body.Exception = c.JavaClassName()
+	}
+	return jsonResponse(http.InternalServerError, body)
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transcoder
+
+import (
+	"context"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+type mockStreamReceiverFilterHandler struct {
+	api.StreamReceiverFilterHandler
+	route   *mockRoute
+	headers types.HeaderMap
+}
+
+func (h *mockStreamReceiverFilterHandler) Route() api.Route {
+	if h.route == nil {
+		return nil
+	}
+	return h.route
+}
+
+func (h *mockStreamReceiverFilterHandler) SetRequestHeaders(headers types.HeaderMap) {
+	h.headers = headers
+}
+
+func (h *mockStreamReceiverFilterHandler) SetRequestData(data types.IoBuffer) {}
+
+func (h *mockStreamReceiverFilterHandler) SetRequestTrailers(trailers types.HeaderMap) {}
+
+type mockRoute struct {
+	api.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() api.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	api.RouteRule
+	config map[string]interface{}
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+// mockTranscoder sets the name from the config to the transcoded request
+type mockTranscoder struct {
+	name string
+}
+
+func newMockTranscoder(cfg map[string]interface{}) (Transcoder, error) {
+	t := &mockTranscoder{}
+	if cfg != nil {
+		t.name, _ = cfg["name"].(string)
+	}
+	return t, nil
+}
+
+func (t *mockTranscoder) Accept(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) bool {
+	return true
+}
+
+func (t *mockTranscoder) TranscodingRequest(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	return protocol.CommonHeader{"transcoder": t.name}, buf, trailers, nil
+}
+
+func (t *mockTranscoder) TranscodingResponse(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	return headers, buf, trailers, nil
+}
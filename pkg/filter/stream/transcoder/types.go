@@ -35,3 +35,17 @@ type Transcoder interface {
 	// TranscodingResponse
 	TranscodingResponse(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error)
 }
+
+// StatusError is returned by TranscodingRequest to reply the request with the status code,
+// the other errors are replied with 400
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+// TranscoderFactory creates a Transcoder with the config
+type TranscoderFactory func(cfg map[string]interface{}) (Transcoder, error)
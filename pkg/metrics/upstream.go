@@ -18,6 +18,8 @@
 package metrics
 
 import (
+	"strconv"
+
 	"mosn.io/mosn/pkg/types"
 )
 
@@ -61,12 +63,30 @@ const (
 	UpstreamBytesWriteBuffered   = "connection_bytes_write_buffered"
 )
 
+//  key in host
+const (
+	UpstreamConnectionPoolConnected    = "connection_pool_connected"
+	UpstreamConnectionPoolStreamsMax   = "connection_pool_streams_max"
+	UpstreamConnectionPoolStreamsTotal = "connection_pool_streams_total"
+)
+
+//  key in connection of the pool
+const (
+	UpstreamConnectionPoolStreams = "connection_pool_streams"
+)
+
 // NewHostStats returns a stats that namespace contains cluster and host address
 func NewHostStats(clusterName string, addr string) types.Metrics {
 	metrics, _ := NewMetrics(UpstreamType, map[string]string{"cluster": clusterName, "host": addr})
 	return metrics
 }
 
+// NewConnectionPoolStats returns a stats that namespace contains cluster, host address and the slot of the connection in the pool
+func NewConnectionPoolStats(clusterName string, addr string, subProtocol string, index int) types.Metrics {
+	metrics, _ := NewMetrics(UpstreamType, map[string]string{"cluster": clusterName, "host": addr, "sub_protocol": subProtocol, "connection": strconv.Itoa(index)})
+	return metrics
+}
+
 // NewClusterStats returns a stats with namespace prefix cluster
 func NewClusterStats(clusterName string) types.Metrics {
 	metrics, _ := NewMetrics(UpstreamType, map[string]string{"cluster": clusterName})
@@ -139,6 +139,51 @@ func (ms *MStream) SendResponse() error {
 	return err
 }
 
+// SendHeaders sends the response headers without ending the stream,
+// the stream can send data frames by SendDataFrame after that, such as a CONNECT tunnel
+func (ms *MStream) SendHeaders() error {
+	rsp := ms.Response
+	ws := &writeResHeaders{
+		streamID:    ms.id,
+		httpResCode: rsp.StatusCode,
+		h:           rsp.Header,
+		endStream:   false,
+	}
+	return ms.conn.writeHeaders(ws)
+}
+
+// SendDataFrame sends data frames on the stream, endStream ends the stream.
+// The data is split by the max frame size of the peer, and each frame waits for the send flow control window.
+func (ms *MStream) SendDataFrame(data []byte, endStream bool) error {
+	for {
+		n, err := ms.conn.takeSendWindow(ms.stream, len(data))
+		if err != nil {
+			return err
+		}
+		frame := data[:n]
+		data = data[n:]
+		end := endStream && len(data) == 0
+		if end {
+			ms.conn.closeStream(ms.stream, nil)
+		}
+		if err := ms.conn.Framer.writeData(ms.stream.id, end, frame); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+// ConsumeData returns the flow control window of the data that has been consumed
+func (ms *MStream) ConsumeData(n int) {
+	if n <= 0 {
+		return
+	}
+	ms.conn.sendWindowUpdate(nil, n)
+	ms.conn.sendWindowUpdate(ms.stream, n)
+}
+
 func (ms *MStream) Reset() {
 	ev := streamError(ms.id, ErrCodeInternal)
 	ms.conn.resetStream(ev)
@@ -148,6 +193,13 @@ func (ms *MStream) Reset() {
 type MServerConn struct {
 	serverConn
 	mu sync.Mutex
+	// goAwayMu protects the go away state and maxClientStreamID, the GOAWAY frame may be sent
+	// by the graceful shutdown out of the frame handling
+	goAwayMu sync.Mutex
+	// flowMu protects the flow control windows of the connection and streams, the window of the data
+	// may be returned out of the frame handling, and the data frames wait for the send window by flowCond
+	flowMu   sync.Mutex
+	flowCond *sync.Cond
 
 	Framer *MFramer
 	api.Connection
@@ -157,6 +209,7 @@ type MServerConn struct {
 func NewServerConn(conn api.Connection) *MServerConn {
 	sc := new(MServerConn)
 	sc.Connection = conn
+	sc.flowCond = sync.NewCond(&sc.flowMu)
 
 	// init serverConn
 	sc.serverConn.hpackEncoder = hpack.NewEncoder(&sc.headerWriteBuf)
@@ -215,12 +268,17 @@ func (sc *MServerConn) getStream(id uint32) *stream {
 
 func (sc *MServerConn) delStream(id uint32) bool {
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
-	if _, ok := sc.streams[id]; ok {
-		delete(sc.streams, id)
-		return true
+	_, ok := sc.streams[id]
+	delete(sc.streams, id)
+	sc.mu.Unlock()
+
+	if ok {
+		// wake up the data frames waiting for the send window of the stream
+		sc.flowMu.Lock()
+		sc.flowCond.Broadcast()
+		sc.flowMu.Unlock()
 	}
-	return false
+	return ok
 }
 
 func (sc *MServerConn) setStream(id uint32, ms *stream) {
@@ -229,6 +287,32 @@ func (sc *MServerConn) setStream(id uint32, ms *stream) {
 	sc.streams[id] = ms
 }
 
+// takeSendWindow waits for the send flow control window of the stream, and takes at most n bytes of it,
+// which is not larger than the max frame size of the peer. It returns errStreamClosed if the stream is closed.
+func (sc *MServerConn) takeSendWindow(st *stream, n int) (int, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	sc.flowMu.Lock()
+	defer sc.flowMu.Unlock()
+	for {
+		if sc.getStream(st.id) == nil {
+			return 0, errStreamClosed
+		}
+		if allowed := st.flow.available(); allowed > 0 {
+			if n > int(sc.maxFrameSize) {
+				n = int(sc.maxFrameSize)
+			}
+			if n > int(allowed) {
+				n = int(allowed)
+			}
+			st.flow.take(int32(n))
+			return n, nil
+		}
+		sc.flowCond.Wait()
+	}
+}
+
 // sendWindowUpdate Http2 server send window update frame
 func (sc *MServerConn) sendWindowUpdate(st *stream, n int) {
 	// "The legal range for the increment to the flow control
@@ -257,11 +341,13 @@ func (sc *MServerConn) sendWindowUpdate32(st *stream, n int32) {
 	}
 	sc.Framer.writeWindowUpdate(streamID, uint32(n))
 	var ok bool
+	sc.flowMu.Lock()
 	if st == nil {
 		ok = sc.inflow.add(n)
 	} else {
 		ok = st.inflow.add(n)
 	}
+	sc.flowMu.Unlock()
 	if !ok {
 		panic("internal error; sent too many window updates without decrements?")
 	}
@@ -383,7 +469,7 @@ func (sc *MServerConn) HandleError(ctx context.Context, f Frame, err error) {
 // processHeaders processes Headers Frame
 func (sc *MServerConn) processHeaders(ctx context.Context, f *MetaHeadersFrame) (*MStream, bool, bool, error) {
 	id := f.StreamID
-	if sc.inGoAway {
+	if sc.inErrorGoAway() {
 		// Ignore.
 		return nil, false, false, nil
 	}
@@ -410,10 +496,18 @@ func (sc *MServerConn) processHeaders(ctx context.Context, f *MetaHeadersFrame)
 	// endpoint has opened or reserved. [...]  An endpoint that
 	// receives an unexpected stream identifier MUST respond with
 	// a connection error (Section 5.4.1) of type PROTOCOL_ERROR.
+	sc.goAwayMu.Lock()
+	if sc.inGoAway {
+		sc.goAwayMu.Unlock()
+		// The new streams are ignored after GOAWAY, the streams opened still work.
+		return nil, false, false, nil
+	}
 	if id <= sc.maxClientStreamID {
+		sc.goAwayMu.Unlock()
 		return nil, false, false, ConnectionError(ErrCodeProtocol)
 	}
 	sc.maxClientStreamID = id
+	sc.goAwayMu.Unlock()
 
 	// http://tools.ietf.org/html/rfc7540#section-5.1.2
 	// [...] Endpoints MUST NOT exceed the limit set by their peer. An
@@ -664,7 +758,7 @@ func (sc *MServerConn) state(streamID uint32) (streamState, *stream) {
 
 // processData processes Data Frame for Http2 Server
 func (sc *MServerConn) processData(ctx context.Context, f *DataFrame) (bool, error) {
-	if sc.inGoAway && sc.goAwayCode != ErrCodeNo {
+	if sc.inErrorGoAway() {
 		return false, nil
 	}
 	data := f.Data()
@@ -691,7 +785,9 @@ func (sc *MServerConn) processData(ctx context.Context, f *DataFrame) (bool, err
 		// But still enforce their connection-level flow control,
 		// and return any flow control bytes since we're not going
 		// to consume them.
+		sc.flowMu.Lock()
 		if sc.inflow.available() < int32(f.Length) {
+			sc.flowMu.Unlock()
 			return false, streamError(id, ErrCodeFlowControl)
 		}
 		// Deduct the flow control from inflow, since we're
@@ -699,6 +795,7 @@ func (sc *MServerConn) processData(ctx context.Context, f *DataFrame) (bool, err
 		// sendWindowUpdate, which also schedules sending the
 		// frames.
 		sc.inflow.take(int32(f.Length))
+		sc.flowMu.Unlock()
 		sc.sendWindowUpdate(nil, int(f.Length)) // conn-level
 
 		if st != nil && st.resetQueued {
@@ -715,10 +812,13 @@ func (sc *MServerConn) processData(ctx context.Context, f *DataFrame) (bool, err
 
 	if f.Length > 0 {
 		// Check whether the client has flow control quota.
+		sc.flowMu.Lock()
 		if st.inflow.available() < int32(f.Length) {
+			sc.flowMu.Unlock()
 			return false, streamError(id, ErrCodeFlowControl)
 		}
 		st.inflow.take(int32(f.Length))
+		sc.flowMu.Unlock()
 
 		// Return any padded flow control now, since we won't
 		// refund it later on body reads.
@@ -747,7 +847,12 @@ func (sc *MServerConn) processSettings(f *SettingsFrame) error {
 		}
 		return nil
 	}
-	if err := f.ForeachSetting(sc.processSetting); err != nil {
+	// the settings may change the max frame size and the send windows
+	sc.flowMu.Lock()
+	err := f.ForeachSetting(sc.processSetting)
+	sc.flowCond.Broadcast()
+	sc.flowMu.Unlock()
+	if err != nil {
 		return err
 	}
 	buf := buffer.NewIoBuffer(frameHeaderLen)
@@ -775,11 +880,19 @@ func (sc *MServerConn) processWindowUpdate(f *WindowUpdateFrame) error {
 			// NOT treat this as an error, see Section 5.1."
 			return nil
 		}
-		if !st.flow.add(int32(f.Increment)) {
+		sc.flowMu.Lock()
+		ok := st.flow.add(int32(f.Increment))
+		sc.flowCond.Broadcast()
+		sc.flowMu.Unlock()
+		if !ok {
 			return streamError(f.StreamID, ErrCodeFlowControl)
 		}
 	default: // connection-level flow control
-		if !sc.flow.add(int32(f.Increment)) {
+		sc.flowMu.Lock()
+		ok := sc.flow.add(int32(f.Increment))
+		sc.flowCond.Broadcast()
+		sc.flowMu.Unlock()
+		if !ok {
 			return goAwayFlowError{}
 		}
 	}
@@ -801,7 +914,7 @@ func (sc *MServerConn) processPing(f *PingFrame) error {
 		// PROTOCOL_ERROR."
 		return ConnectionError(ErrCodeProtocol)
 	}
-	if sc.inGoAway && sc.goAwayCode != ErrCodeNo {
+	if sc.inErrorGoAway() {
 		return nil
 	}
 	buf := buffer.NewIoBuffer(frameHeaderLen + 8)
@@ -861,7 +974,24 @@ func (sc *MServerConn) resetStream(se StreamError) error {
 	return nil
 }
 
+// GracefulShutdown sends the GOAWAY frame with NO_ERROR, the new streams are refused
+// while the streams opened are still processed
+func (sc *MServerConn) GracefulShutdown() {
+	sc.startGracefulShutdownInternal()
+}
+
+// inErrorGoAway returns true if the GOAWAY frame is sent for an error, the frames are ignored then
+func (sc *MServerConn) inErrorGoAway() bool {
+	sc.goAwayMu.Lock()
+	defer sc.goAwayMu.Unlock()
+
+	return sc.inGoAway && sc.goAwayCode != ErrCodeNo
+}
+
 func (sc *MServerConn) goAway(code ErrCode, debugData []byte) {
+	sc.goAwayMu.Lock()
+	defer sc.goAwayMu.Unlock()
+
 	if sc.inGoAway {
 		return
 	}
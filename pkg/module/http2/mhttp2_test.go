@@ -0,0 +1,102 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http2
+
+import (
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+)
+
+// mockFrameConn records the frames written
+type mockFrameConn struct {
+	api.Connection
+	frames chan FrameHeader
+}
+
+func (c *mockFrameConn) Write(bufs ...buffer.IoBuffer) error {
+	for _, buf := range bufs {
+		b := buf.Bytes()
+		c.frames <- FrameHeader{
+			Length:   uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]),
+			Type:     FrameType(b[3]),
+			Flags:    Flags(b[4]),
+			StreamID: uint32(b[5])<<24 | uint32(b[6])<<16 | uint32(b[7])<<8 | uint32(b[8]),
+		}
+	}
+	return nil
+}
+
+func expectDataFrames(t *testing.T, conn *mockFrameConn, lengths []uint32, endStream bool) {
+	for i, length := range lengths {
+		select {
+		case f := <-conn.frames:
+			if f.Type != FrameData || f.Length != length {
+				t.Fatalf("expected data frame with length %d, but got %v", length, f)
+			}
+			if end := f.Flags.Has(FlagDataEndStream); end != (endStream && i == len(lengths)-1) {
+				t.Fatalf("unexpected end stream flag of frame %v", f)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected data frame with length %d, but got nothing", length)
+		}
+	}
+	select {
+	case f := <-conn.frames:
+		t.Fatalf("unexpected frame %v", f)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSendDataFrameFlowControl(t *testing.T) {
+	conn := &mockFrameConn{frames: make(chan FrameHeader, 10)}
+	sc := NewServerConn(conn)
+	sc.maxFrameSize = 10
+	ms := &MStream{stream: sc.newStream(1, 0, stateOpen), conn: sc}
+	ms.flow.n = 15
+
+	result := make(chan error, 1)
+	go func() {
+		result <- ms.SendDataFrame(make([]byte, 30), true)
+	}()
+	// the data is split by the max frame size, and waits for the window
+	expectDataFrames(t, conn, []uint32{10, 5}, false)
+
+	if err := sc.processWindowUpdate(&WindowUpdateFrame{
+		FrameHeader: FrameHeader{StreamID: 1},
+		Increment:   100,
+	}); err != nil {
+		t.Fatalf("process window update failed: %v", err)
+	}
+	expectDataFrames(t, conn, []uint32{10, 5}, true)
+	if err := <-result; err != nil {
+		t.Fatalf("send data frame failed: %v", err)
+	}
+}
+
+func TestSendDataFrameStreamReset(t *testing.T) {
+	conn := &mockFrameConn{frames: make(chan FrameHeader, 10)}
+	sc := NewServerConn(conn)
+	ms := &MStream{stream: sc.newStream(1, 0, stateOpen), conn: sc}
+	ms.flow.n = 0
+
+	result := make(chan error, 1)
+	go func() {
+		result <- ms.SendDataFrame(make([]byte, 30), false)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	// the waiting data is not sent after the stream reset
+	ms.Reset()
+	select {
+	case err := <-result:
+		if err != errStreamClosed {
+			t.Fatalf("expected stream closed, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the data frame is still waiting for the window after the stream reset")
+	}
+}
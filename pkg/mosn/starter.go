@@ -31,7 +31,9 @@ import (
 	"mosn.io/mosn/pkg/metrics/shm"
 	"mosn.io/mosn/pkg/metrics/sink"
 	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/plugin"
+	"mosn.io/mosn/pkg/protocol/xprotocol/declarative"
 	"mosn.io/mosn/pkg/router"
 	"mosn.io/mosn/pkg/server"
 	"mosn.io/mosn/pkg/server/keeper"
@@ -86,6 +88,8 @@ func NewMosn(c *v2.MOSNConfig) *Mosn {
 	}
 
 	initializeMetrics(c.Metrics)
+	initializeOverload(c.Overload)
+	initializeXProtocolCodecs(c.XProtocolCodecs)
 
 	m := &Mosn{
 		config:           c,
@@ -285,6 +289,7 @@ func (m *Mosn) Close() {
 		srv.Close()
 	}
 	m.xdsClient.Stop()
+	overload.Stop()
 	m.clustermanager.Destroy()
 	m.wg.Done()
 }
@@ -336,6 +341,20 @@ func initializeMetrics(config v2.MetricsConfig) {
 	}
 }
 
+func initializeOverload(config v2.OverloadConfig) {
+	if err := overload.Start(config); err != nil {
+		log.StartLogger.Fatalf("[mosn] [NewMosn] start overload manager failed: %v", err)
+	}
+}
+
+func initializeXProtocolCodecs(codecs []v2.XProtocolCodecConfig) {
+	for _, cfg := range codecs {
+		if err := declarative.Register(cfg); err != nil {
+			log.StartLogger.Fatalf("[mosn] [NewMosn] register xprotocol codec %s failed: %v", cfg.Name, err)
+		}
+	}
+}
+
 func initializePidFile(pid string) {
 	keeper.SetPid(pid)
 }
@@ -64,8 +64,9 @@ type connection struct {
 	readEnabled          bool
 	readEnabledChan      chan bool
 	readDisableCount     int
+	readDisableMutex     sync.Mutex
 	localAddressRestored bool
-	bufferLimit          uint32 // todo: support soft buffer limit
+	bufferLimit          uint32 // the high watermark of the buffered data to be written
 	rawConnection        net.Conn
 	tlsMng               types.TLSContextManager
 	closeWithFlush       bool
@@ -104,6 +105,11 @@ type connection struct {
 	tryMutex     *utils.Mutex
 	needTransfer bool
 	useWriteLoop bool
+
+	// the buffered data to be written, including the data that is waiting for the write loop
+	watermarkMutex     sync.Mutex
+	writeBufferedBytes int64
+	aboveHighWatermark bool
 }
 
 // NewServerConnection new server-side connection, rawc is the raw connection from go/net
@@ -505,6 +511,14 @@ func (c *connection) Write(buffers ...buffer.IoBuffer) (err error) {
 		return nil
 	}
 
+	var size int
+	for _, buf := range buffers {
+		if buf != nil {
+			size += buf.Len()
+		}
+	}
+	c.updateWriteBuffered(int64(size))
+
 	if !UseNetpollMode {
 		if c.useWriteLoop {
 			c.writeBufferChan <- &buffers
@@ -679,6 +693,7 @@ func (c *connection) doWrite() (int64, error) {
 
 func (c *connection) doWriteIo() (bytesSent int64, err error) {
 	buffers := c.writeBuffers
+	size := c.writeBufLen()
 	if tlsConn, ok := c.rawConnection.(*mtls.TLSConn); ok {
 		bytesSent, err = tlsConn.WriteTo(&buffers)
 	} else {
@@ -700,9 +715,49 @@ func (c *connection) doWriteIo() (bytesSent int64, err error) {
 	}
 	c.ioBuffers = c.ioBuffers[:0]
 	c.writeBuffers = c.writeBuffers[:0]
+	c.updateWriteBuffered(-int64(size))
 	return
 }
 
+// updateWriteBuffered updates the size of the buffered data to be written, the listeners
+// are notified when the size goes above the buffer limit, or drops below half of it.
+// The listeners are called without the lock held, so they can write to the connection.
+func (c *connection) updateWriteBuffered(delta int64) {
+	highWatermark := int64(atomic.LoadUint32(&c.bufferLimit))
+	if highWatermark == 0 {
+		return
+	}
+
+	c.watermarkMutex.Lock()
+	c.writeBufferedBytes += delta
+	// the data buffered before the limit is set is not counted
+	if c.writeBufferedBytes < 0 {
+		c.writeBufferedBytes = 0
+	}
+	above, below := false, false
+	if !c.aboveHighWatermark && c.writeBufferedBytes > highWatermark {
+		c.aboveHighWatermark = true
+		above = true
+	} else if c.aboveHighWatermark && c.writeBufferedBytes <= highWatermark/2 {
+		c.aboveHighWatermark = false
+		below = true
+	}
+	c.watermarkMutex.Unlock()
+
+	if !above && !below {
+		return
+	}
+	for _, cb := range c.connCallbacks {
+		if listener, ok := cb.(types.WatermarkListener); ok {
+			if above {
+				listener.OnAboveWriteBufferHighWatermark()
+			} else {
+				listener.OnBelowWriteBufferLowWatermark()
+			}
+		}
+	}
+}
+
 func (c *connection) updateWriteBuffStats(bytesWrite int64, bytesBufSize int64) {
 	if c.stats == nil {
 		return
@@ -820,6 +875,9 @@ func (c *connection) SetNoDelay(enable bool) {
 }
 
 func (c *connection) SetReadDisable(disable bool) {
+	c.readDisableMutex.Lock()
+	defer c.readDisableMutex.Unlock()
+
 	if disable {
 		if !c.readEnabled {
 			c.readDisableCount++
@@ -835,7 +893,10 @@ func (c *connection) SetReadDisable(disable bool) {
 
 		c.readEnabled = true
 		// only on read disable status, we need to trigger chan to wake read loop up
-		c.readEnabledChan <- true
+		select {
+		case c.readEnabledChan <- true:
+		default:
+		}
 	}
 }
 
@@ -849,12 +910,13 @@ func (c *connection) TLS() net.Conn {
 
 func (c *connection) SetBufferLimit(limit uint32) {
 	if limit > 0 {
-		c.bufferLimit = limit
+		// the limit can be changed by the overload manager while the connection is running
+		atomic.StoreUint32(&c.bufferLimit, limit)
 	}
 }
 
 func (c *connection) BufferLimit() uint32 {
-	return c.bufferLimit
+	return atomic.LoadUint32(&c.bufferLimit)
 }
 
 func (c *connection) SetLocalAddress(localAddress net.Addr, restored bool) {
@@ -194,3 +194,103 @@ func TestConnState(t *testing.T) {
 		t.Errorf("ConnState should be ConnClosed")
 	}
 }
+
+type watermarkListener struct {
+	MyEventListener
+	above int
+	below int
+}
+
+func (l *watermarkListener) OnAboveWriteBufferHighWatermark() {
+	l.above++
+}
+
+func (l *watermarkListener) OnBelowWriteBufferLowWatermark() {
+	l.below++
+}
+
+func TestWriteBufferWatermark(t *testing.T) {
+	listener := &watermarkListener{}
+	c := &connection{
+		bufferLimit: 100,
+	}
+	c.AddConnectionEventListener(listener)
+	c.AddConnectionEventListener(&MyEventListener{})
+
+	c.updateWriteBuffered(100)
+	if listener.above != 0 {
+		t.Fatal("should not be notified before exceeding the high watermark")
+	}
+	c.updateWriteBuffered(1)
+	c.updateWriteBuffered(50)
+	if listener.above != 1 {
+		t.Fatalf("should be notified once above the high watermark, but got %d", listener.above)
+	}
+	c.updateWriteBuffered(-100)
+	if listener.below != 0 {
+		t.Fatal("should not be notified before dropping below the low watermark")
+	}
+	c.updateWriteBuffered(-1)
+	if listener.above != 1 || listener.below != 1 {
+		t.Fatalf("should be notified below the low watermark, but got above %d, below %d", listener.above, listener.below)
+	}
+
+	// no watermarks without buffer limit
+	c.bufferLimit = 0
+	c.updateWriteBuffered(1000)
+	if listener.above != 1 {
+		t.Fatal("should not be notified without buffer limit")
+	}
+}
+
+// reentrantWatermarkListener writes to the connection in the callbacks
+type reentrantWatermarkListener struct {
+	watermarkListener
+	c *connection
+}
+
+func (l *reentrantWatermarkListener) OnAboveWriteBufferHighWatermark() {
+	l.watermarkListener.OnAboveWriteBufferHighWatermark()
+	l.c.updateWriteBuffered(-200)
+}
+
+func TestWriteBufferWatermarkReentrant(t *testing.T) {
+	c := &connection{
+		bufferLimit: 100,
+	}
+	listener := &reentrantWatermarkListener{c: c}
+	c.AddConnectionEventListener(listener)
+
+	done := make(chan struct{})
+	go func() {
+		c.updateWriteBuffered(200)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the listener should be called without the lock held")
+	}
+	if listener.above != 1 || listener.below != 1 {
+		t.Fatalf("should be notified above and below, but got above %d, below %d", listener.above, listener.below)
+	}
+}
+
+func TestSetReadDisable(t *testing.T) {
+	c := &connection{
+		readEnabled:     true,
+		readEnabledChan: make(chan bool, 1),
+	}
+	c.SetReadDisable(true)
+	c.SetReadDisable(true)
+	c.SetReadDisable(false)
+	if c.ReadEnabled() {
+		t.Fatal("read should be enabled after all the disables resumed")
+	}
+	c.SetReadDisable(false)
+	if !c.ReadEnabled() {
+		t.Fatal("read should be enabled")
+	}
+	// enable again should not be blocked
+	c.SetReadDisable(false)
+}
@@ -28,6 +28,7 @@ import (
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/utils"
 )
@@ -141,6 +142,9 @@ func (l *listener) Start(lctx context.Context, restart bool) {
 		}
 
 		for {
+			if !l.waitOverload() {
+				return
+			}
 			if err := l.accept(lctx); err != nil {
 				if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
 					log.DefaultLogger.Infof("[network] [listener start] [accept] listener %s stop accepting connections by deadline", l.name)
@@ -165,6 +169,29 @@ func (l *listener) Start(lctx context.Context, restart bool) {
 	}
 }
 
+// overloadCheckInterval is the interval of checking the overload action when the listener stops accepting
+var overloadCheckInterval = 100 * time.Millisecond
+
+// waitOverload waits until the connections can be accepted, the new connections are queued in the backlog
+// while the process is overloaded. It returns false if the listener is closed during waiting.
+func (l *listener) waitOverload() bool {
+	if !overload.StopAcceptingConnections.Active() {
+		return true
+	}
+	log.DefaultLogger.Warnf("[network] [listener start] [accept] listener %s stop accepting connections by overload", l.name)
+	for overload.StopAcceptingConnections.Active() {
+		time.Sleep(overloadCheckInterval)
+		l.mutex.Lock()
+		stopped := l.state == ListenerStopped
+		l.mutex.Unlock()
+		if stopped {
+			return false
+		}
+	}
+	log.DefaultLogger.Infof("[network] [listener start] [accept] listener %s resume accepting connections", l.name)
+	return true
+}
+
 func (l *listener) Stop() error {
 	return l.rawl.SetDeadline(time.Now())
 }
@@ -0,0 +1,312 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package overload
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/pkg/utils"
+)
+
+const defaultRefreshInterval = time.Second
+
+const metricsType = "overload"
+
+// metrics key
+const (
+	statsUsage     = "usage"
+	statsPressure  = "pressure"
+	statsActive    = "active"
+	statsActivated = "activated"
+)
+
+type resource struct {
+	name    string
+	max     uint64
+	monitor ResourceMonitor
+	usage   uint64
+	// pressure stores the bits of the float64 pressure
+	pressure uint64
+	// the gauge of the pressure is in percentage
+	usageGauge    gometrics.Gauge
+	pressureGauge gometrics.Gauge
+}
+
+func (r *resource) refresh() {
+	usage := r.monitor()
+	pressure := float64(usage) / float64(r.max)
+	atomic.StoreUint64(&r.usage, usage)
+	atomic.StoreUint64(&r.pressure, math.Float64bits(pressure))
+	r.usageGauge.Update(int64(usage))
+	r.pressureGauge.Update(int64(pressure * 100))
+}
+
+func (r *resource) getPressure() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&r.pressure))
+}
+
+type trigger struct {
+	resource  *resource
+	threshold float64
+}
+
+type actionState struct {
+	action      *Action
+	triggers    []trigger
+	bufferLimit uint32
+	activeGauge gometrics.Gauge
+	activated   gometrics.Counter
+}
+
+// fired returns the resource that fires the action, nil means the action is inactive
+func (a *actionState) fired() *resource {
+	for _, t := range a.triggers {
+		if t.resource.getPressure() >= t.threshold {
+			return t.resource
+		}
+	}
+	return nil
+}
+
+type manager struct {
+	interval  time.Duration
+	resources []*resource
+	actions   []*actionState
+	stop      chan struct{}
+	// mux protects the actions from being activated after the manager is closed
+	mux    sync.Mutex
+	closed bool
+}
+
+var (
+	mux     sync.Mutex
+	current *manager
+)
+
+// Start starts the overload manager by the config, the manager started already is stopped.
+// Nothing is started if no resource monitor is configured.
+func Start(cfg v2.OverloadConfig) error {
+	mux.Lock()
+	defer mux.Unlock()
+	if len(cfg.ResourceMonitors) == 0 {
+		return nil
+	}
+	m, err := newManager(cfg)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		current.close()
+	}
+	current = m
+	m.refresh()
+	utils.GoWithRecover(m.run, nil)
+	log.DefaultLogger.Infof("[overload] overload manager started, %d resources, %d actions", len(m.resources), len(m.actions))
+	return nil
+}
+
+// Stop stops the overload manager, the actions are deactivated
+func Stop() {
+	mux.Lock()
+	defer mux.Unlock()
+	if current != nil {
+		current.close()
+		current = nil
+	}
+}
+
+func newManager(cfg v2.OverloadConfig) (*manager, error) {
+	m := &manager{
+		interval: cfg.RefreshInterval.Duration,
+		stop:     make(chan struct{}),
+	}
+	if m.interval <= 0 {
+		m.interval = defaultRefreshInterval
+	}
+	resources := make(map[string]*resource, len(cfg.ResourceMonitors))
+	for _, rc := range cfg.ResourceMonitors {
+		monitor := getResourceMonitor(rc.Name)
+		if monitor == nil {
+			return nil, fmt.Errorf("unknown resource monitor: %s", rc.Name)
+		}
+		if rc.Max == 0 {
+			return nil, fmt.Errorf("the max of resource %s is required", rc.Name)
+		}
+		if _, ok := resources[rc.Name]; ok {
+			return nil, fmt.Errorf("duplicated resource monitor: %s", rc.Name)
+		}
+		s, _ := metrics.NewMetrics(metricsType, map[string]string{"resource": rc.Name})
+		r := &resource{
+			name:          rc.Name,
+			max:           rc.Max,
+			monitor:       monitor,
+			usageGauge:    s.Gauge(statsUsage),
+			pressureGauge: s.Gauge(statsPressure),
+		}
+		resources[rc.Name] = r
+		m.resources = append(m.resources, r)
+	}
+	configured := make(map[*Action]bool, len(cfg.Actions))
+	for _, ac := range cfg.Actions {
+		action := getAction(ac.Name)
+		if action == nil {
+			return nil, fmt.Errorf("unknown overload action: %s", ac.Name)
+		}
+		if configured[action] {
+			return nil, fmt.Errorf("duplicated overload action: %s", ac.Name)
+		}
+		configured[action] = true
+		if len(ac.Triggers) == 0 {
+			return nil, fmt.Errorf("the triggers of action %s are required", ac.Name)
+		}
+		s, _ := metrics.NewMetrics(metricsType, map[string]string{"action": ac.Name})
+		state := &actionState{
+			action:      action,
+			bufferLimit: ac.BufferLimitBytes,
+			activeGauge: s.Gauge(statsActive),
+			activated:   s.Counter(statsActivated),
+		}
+		for _, tc := range ac.Triggers {
+			r, ok := resources[tc.Name]
+			if !ok {
+				return nil, fmt.Errorf("the resource %s of action %s is not monitored", tc.Name, ac.Name)
+			}
+			if tc.Threshold <= 0 || tc.Threshold > 1 {
+				return nil, errors.New("the threshold should be in (0, 1]")
+			}
+			state.triggers = append(state.triggers, trigger{resource: r, threshold: tc.Threshold})
+		}
+		m.actions = append(m.actions, state)
+	}
+	return m, nil
+}
+
+func (m *manager) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.refresh()
+		}
+	}
+}
+
+// refresh updates the pressure of the resources, and then the state of the actions
+func (m *manager) refresh() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed {
+		return
+	}
+	for _, r := range m.resources {
+		r.refresh()
+	}
+	for _, a := range m.actions {
+		r := a.fired()
+		if r != nil && a.action == ShrinkBufferLimits {
+			limit := a.bufferLimit
+			if limit == 0 {
+				limit = DefaultShrunkBufferLimit
+			}
+			atomic.StoreUint32(&shrunkBufferLimit, limit)
+		}
+		if !a.action.setActive(r != nil) {
+			continue
+		}
+		if r != nil {
+			log.DefaultLogger.Warnf("[overload] action %s is active, the pressure of %s is %.2f", a.action.name, r.name, r.getPressure())
+			a.activeGauge.Update(1)
+			a.activated.Inc(1)
+		} else {
+			log.DefaultLogger.Infof("[overload] action %s is inactive", a.action.name)
+			a.activeGauge.Update(0)
+		}
+	}
+}
+
+func (m *manager) close() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.closed = true
+	close(m.stop)
+	for _, a := range m.actions {
+		if a.action.setActive(false) {
+			a.activeGauge.Update(0)
+		}
+	}
+}
+
+// ResourceStatus is the current state of a resource
+type ResourceStatus struct {
+	Name     string  `json:"name"`
+	Usage    uint64  `json:"usage"`
+	Max      uint64  `json:"max"`
+	Pressure float64 `json:"pressure"`
+}
+
+// ActionStatus is the current state of an action
+type ActionStatus struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// Status is the current state of the overload manager
+type Status struct {
+	Resources []ResourceStatus `json:"resources"`
+	Actions   []ActionStatus   `json:"actions"`
+}
+
+// GetStatus returns the current pressure of the resources and the state of all the actions
+func GetStatus() Status {
+	mux.Lock()
+	m := current
+	mux.Unlock()
+
+	status := Status{
+		Resources: []ResourceStatus{},
+		Actions:   make([]ActionStatus, 0, len(actions)),
+	}
+	if m != nil {
+		for _, r := range m.resources {
+			status.Resources = append(status.Resources, ResourceStatus{
+				Name:     r.name,
+				Usage:    atomic.LoadUint64(&r.usage),
+				Max:      r.max,
+				Pressure: r.getPressure(),
+			})
+		}
+	}
+	for _, action := range actions {
+		status.Actions = append(status.Actions, ActionStatus{
+			Name:   action.name,
+			Active: action.Active(),
+		})
+	}
+	return status
+}
@@ -0,0 +1,168 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package overload protects the process from the resource exhaustion.
+// The resource monitors report the usage of the resources, such as the heap size and the active connections,
+// and the actions are triggered when the pressure of the resources reaches the thresholds.
+// The actions are checked by the modules on their hot paths, so checking an action is just an atomic load.
+package overload
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// the names of the resource monitors
+const (
+	// HeapSize is the bytes of the allocated heap objects
+	HeapSize = "heap_size"
+	// Goroutines is the number of the goroutines
+	Goroutines = "goroutines"
+	// ActiveConnections is the number of the downstream connections, it is registered by the server
+	ActiveConnections = "active_connections"
+)
+
+// ResourceMonitor returns the current usage of the resource
+type ResourceMonitor func() uint64
+
+var (
+	monitorsMux sync.RWMutex
+	monitors    = map[string]ResourceMonitor{
+		HeapSize:   heapSize,
+		Goroutines: goroutines,
+	}
+)
+
+// RegisterResourceMonitor registers the monitor of the resource, the monitor registered already is replaced
+func RegisterResourceMonitor(name string, monitor ResourceMonitor) {
+	monitorsMux.Lock()
+	defer monitorsMux.Unlock()
+	monitors[name] = monitor
+}
+
+func getResourceMonitor(name string) ResourceMonitor {
+	monitorsMux.RLock()
+	defer monitorsMux.RUnlock()
+	return monitors[name]
+}
+
+func heapSize() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func goroutines() uint64 {
+	return uint64(runtime.NumGoroutine())
+}
+
+// Action is triggered by the overload manager
+type Action struct {
+	name   string
+	active uint32
+
+	mux       sync.Mutex
+	callbacks []func(active bool)
+}
+
+// the actions supported
+var (
+	// StopAcceptingConnections stops the listeners accepting the connections,
+	// the new connections are queued in the backlog until the action is inactive.
+	StopAcceptingConnections = newAction("stop_accepting_connections")
+	// ShrinkBufferLimits shrinks the per connection buffer limits to the configured buffer limit bytes
+	ShrinkBufferLimits = newAction("shrink_buffer_limits")
+	// DisableHTTPKeepAlive closes the HTTP/1 connections after the current response
+	DisableHTTPKeepAlive = newAction("disable_http_keepalive")
+	// StopAcceptingRequests rejects the new streams with a local reply
+	StopAcceptingRequests = newAction("stop_accepting_requests")
+)
+
+var actions = []*Action{
+	StopAcceptingConnections,
+	ShrinkBufferLimits,
+	DisableHTTPKeepAlive,
+	StopAcceptingRequests,
+}
+
+func newAction(name string) *Action {
+	return &Action{
+		name: name,
+	}
+}
+
+func getAction(name string) *Action {
+	for _, action := range actions {
+		if action.name == name {
+			return action
+		}
+	}
+	return nil
+}
+
+// Name returns the name of the action
+func (a *Action) Name() string {
+	return a.name
+}
+
+// Active returns true if the action is triggered
+func (a *Action) Active() bool {
+	return atomic.LoadUint32(&a.active) == 1
+}
+
+// AddCallback adds the callback that is called when the action becomes active or inactive
+func (a *Action) AddCallback(cb func(active bool)) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.callbacks = append(a.callbacks, cb)
+}
+
+// setActive returns true if the state is changed, the callbacks are called after the state changed
+func (a *Action) setActive(active bool) bool {
+	var value uint32
+	if active {
+		value = 1
+	}
+	if atomic.SwapUint32(&a.active, value) == value {
+		return false
+	}
+	a.mux.Lock()
+	callbacks := a.callbacks
+	a.mux.Unlock()
+	for _, cb := range callbacks {
+		cb(active)
+	}
+	return true
+}
+
+// DefaultShrunkBufferLimit is the per connection buffer limit when the buffer limits are shrunk
+// and the buffer limit bytes is not configured
+const DefaultShrunkBufferLimit = 16 * 1024
+
+var shrunkBufferLimit uint32 = DefaultShrunkBufferLimit
+
+// BufferLimit returns the per connection buffer limit, the limit is shrunk if ShrinkBufferLimits is active
+func BufferLimit(limit uint32) uint32 {
+	if !ShrinkBufferLimits.Active() {
+		return limit
+	}
+	shrunk := atomic.LoadUint32(&shrunkBufferLimit)
+	if limit == 0 || limit > shrunk {
+		return shrunk
+	}
+	return limit
+}
@@ -0,0 +1,166 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package overload
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// testUsage is the usage of the test resource
+var testUsage uint64
+
+func init() {
+	RegisterResourceMonitor("test", func() uint64 {
+		return atomic.LoadUint64(&testUsage)
+	})
+}
+
+func testConfig() v2.OverloadConfig {
+	return v2.OverloadConfig{
+		RefreshInterval: api.DurationConfig{Duration: 10 * time.Millisecond},
+		ResourceMonitors: []v2.ResourceMonitorConfig{
+			{Name: "test", Max: 100},
+			{Name: Goroutines, Max: 1 << 20},
+		},
+		Actions: []v2.OverloadActionConfig{
+			{
+				Name:     StopAcceptingRequests.Name(),
+				Triggers: []v2.OverloadTriggerConfig{{Name: "test", Threshold: 0.95}},
+			},
+			{
+				Name: ShrinkBufferLimits.Name(),
+				Triggers: []v2.OverloadTriggerConfig{
+					{Name: Goroutines, Threshold: 0.9},
+					{Name: "test", Threshold: 0.8},
+				},
+				BufferLimitBytes: 1024,
+			},
+		},
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, update := range []func(cfg *v2.OverloadConfig){
+		func(cfg *v2.OverloadConfig) { cfg.ResourceMonitors[0].Name = "unknown" },
+		func(cfg *v2.OverloadConfig) { cfg.ResourceMonitors[0].Max = 0 },
+		func(cfg *v2.OverloadConfig) { cfg.ResourceMonitors[1].Name = "test" },
+		func(cfg *v2.OverloadConfig) { cfg.Actions[0].Name = "unknown" },
+		func(cfg *v2.OverloadConfig) { cfg.Actions[1].Name = cfg.Actions[0].Name },
+		func(cfg *v2.OverloadConfig) { cfg.Actions[0].Triggers = nil },
+		func(cfg *v2.OverloadConfig) { cfg.Actions[0].Triggers[0].Name = HeapSize },
+		func(cfg *v2.OverloadConfig) { cfg.Actions[0].Triggers[0].Threshold = 1.5 },
+	} {
+		cfg := testConfig()
+		update(&cfg)
+		if err := Start(cfg); err == nil {
+			t.Errorf("expected error: %+v", cfg)
+		}
+	}
+	// nothing is started without the resource monitors
+	if err := Start(v2.OverloadConfig{}); err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+}
+
+func waitAction(t *testing.T, action *Action, active bool) {
+	for i := 0; i < 100; i++ {
+		if action.Active() == active {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected the action %s active: %t", action.Name(), active)
+}
+
+func TestOverloadManager(t *testing.T) {
+	var changes []bool
+	StopAcceptingRequests.AddCallback(func(active bool) {
+		changes = append(changes, active)
+	})
+	atomic.StoreUint64(&testUsage, 50)
+	if err := Start(testConfig()); err != nil {
+		t.Fatalf("start overload manager failed: %v", err)
+	}
+	defer Stop()
+	if StopAcceptingRequests.Active() || ShrinkBufferLimits.Active() {
+		t.Fatal("expected the actions inactive")
+	}
+	if BufferLimit(32*1024) != 32*1024 {
+		t.Error("expected the buffer limit not shrunk")
+	}
+
+	atomic.StoreUint64(&testUsage, 85)
+	waitAction(t, ShrinkBufferLimits, true)
+	if StopAcceptingRequests.Active() {
+		t.Error("expected the action inactive")
+	}
+	if limit := BufferLimit(32 * 1024); limit != 1024 {
+		t.Errorf("expected the buffer limit shrunk, but got %d", limit)
+	}
+	if limit := BufferLimit(512); limit != 512 {
+		t.Errorf("expected the smaller buffer limit not changed, but got %d", limit)
+	}
+
+	atomic.StoreUint64(&testUsage, 100)
+	waitAction(t, StopAcceptingRequests, true)
+	status := GetStatus()
+	if len(status.Resources) != 2 || status.Resources[0].Name != "test" || status.Resources[0].Pressure != 1 {
+		t.Errorf("unexpected resources status: %+v", status.Resources)
+	}
+	if len(status.Actions) != len(actions) {
+		t.Errorf("unexpected actions status: %+v", status.Actions)
+	}
+	for _, action := range status.Actions {
+		expected := action.Name == StopAcceptingRequests.Name() || action.Name == ShrinkBufferLimits.Name()
+		if action.Active != expected {
+			t.Errorf("expected the action %s active: %t", action.Name, expected)
+		}
+	}
+	if _, err := json.Marshal(status); err != nil {
+		t.Errorf("marshal status failed: %v", err)
+	}
+
+	atomic.StoreUint64(&testUsage, 10)
+	waitAction(t, StopAcceptingRequests, false)
+	waitAction(t, ShrinkBufferLimits, false)
+
+	// the actions are deactivated when the manager stops
+	atomic.StoreUint64(&testUsage, 100)
+	waitAction(t, StopAcceptingRequests, true)
+	Stop()
+	if StopAcceptingRequests.Active() || ShrinkBufferLimits.Active() {
+		t.Error("expected the actions inactive after stopped")
+	}
+	if len(GetStatus().Resources) != 0 {
+		t.Error("expected no resources after stopped")
+	}
+	expected := []bool{true, false, true, false}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected changes %v, but got %v", expected, changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("expected changes %v, but got %v", expected, changes)
+		}
+	}
+}
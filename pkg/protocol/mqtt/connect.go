@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqtt
+
+// return codes of CONNACK, the codes of MQTT 5.0 are different from the former versions
+const (
+	ConnAckServerUnavailable   byte = 0x03
+	ConnAckNotAuthorized       byte = 0x05
+	ConnAckServerUnavailableV5 byte = 0x88
+	ConnAckNotAuthorizedV5     byte = 0x87
+)
+
+// Connect is the CONNECT packet
+type Connect struct {
+	ProtocolName  string
+	ProtocolLevel byte
+	CleanSession  bool
+	KeepAlive     uint16
+	ClientID      string
+	HasUsername   bool
+	Username      string
+}
+
+// ParseConnect parses the body of the CONNECT packet
+func ParseConnect(body []byte) (*Connect, error) {
+	r := &reader{data: body}
+	c := &Connect{
+		ProtocolName:  r.string(),
+		ProtocolLevel: r.byte(),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	switch {
+	case c.ProtocolLevel == Version31 && c.ProtocolName == "MQIsdp":
+	case (c.ProtocolLevel == Version311 || c.ProtocolLevel == Version5) && c.ProtocolName == "MQTT":
+	default:
+		return nil, ErrUnsupportedVersion
+	}
+	flags := r.byte()
+	c.CleanSession = flags&0x02 != 0
+	c.KeepAlive = r.uint16()
+	if c.ProtocolLevel == Version5 {
+		r.properties()
+	}
+	c.ClientID = r.string()
+	if flags&0x04 != 0 {
+		// will
+		if c.ProtocolLevel == Version5 {
+			r.properties()
+		}
+		r.binary()
+		r.binary()
+	}
+	if flags&0x80 != 0 {
+		c.HasUsername = true
+		c.Username = r.string()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return c, nil
+}
+
+// EncodeConnAck encodes the CONNACK packet without properties
+func EncodeConnAck(version byte, sessionPresent bool, code byte) []byte {
+	body := []byte{0, code}
+	if sessionPresent {
+		body[0] = 1
+	}
+	if version == Version5 {
+		body = appendProperties(body, nil)
+	}
+	return Encode(CONNACK, 0, body)
+}
@@ -0,0 +1,277 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package mqtt implements the codec of the MQTT 3.1.1 and 5.0 control packets that are
+// inspected by the proxy, the other packets are only framed.
+package mqtt
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// PacketType is the type of the control packet
+type PacketType byte
+
+// control packet types
+const (
+	CONNECT PacketType = iota + 1
+	CONNACK
+	PUBLISH
+	PUBACK
+	PUBREC
+	PUBREL
+	PUBCOMP
+	SUBSCRIBE
+	SUBACK
+	UNSUBSCRIBE
+	UNSUBACK
+	PINGREQ
+	PINGRESP
+	DISCONNECT
+	AUTH
+)
+
+var packetNames = [...]string{
+	"reserved", "connect", "connack", "publish", "puback", "pubrec", "pubrel", "pubcomp",
+	"subscribe", "suback", "unsubscribe", "unsuback", "pingreq", "pingresp", "disconnect", "auth",
+}
+
+func (t PacketType) String() string {
+	if int(t) < len(packetNames) {
+		return packetNames[t]
+	}
+	return "unknown"
+}
+
+// protocol levels
+const (
+	Version31  byte = 3
+	Version311 byte = 4
+	Version5   byte = 5
+)
+
+// MaxRemainingLength is the max remaining length that can be encoded by the variable byte integer
+const MaxRemainingLength = 268435455
+
+var (
+	ErrMalformed          = errors.New("mqtt: malformed packet")
+	ErrUnsupportedVersion = errors.New("mqtt: unsupported protocol version")
+)
+
+// Packet is a framed control packet, the slices refer to the decoded data
+type Packet struct {
+	Type  PacketType
+	Flags byte
+	// Raw is the whole packet including the fixed header
+	Raw []byte
+	// Body is the variable header and the payload
+	Body []byte
+}
+
+// Decode decodes the first control packet of data, a nil packet is returned if the data is not enough
+func Decode(data []byte) (*Packet, error) {
+	if len(data) < 2 {
+		return nil, nil
+	}
+	length, n, err := decodeVarint(data[1:])
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	headerLen := 1 + n
+	if len(data) < headerLen+length {
+		return nil, nil
+	}
+	return &Packet{
+		Type:  PacketType(data[0] >> 4),
+		Flags: data[0] & 0x0f,
+		Raw:   data[:headerLen+length],
+		Body:  data[headerLen : headerLen+length],
+	}, nil
+}
+
+// Encode encodes a control packet with the body
+func Encode(t PacketType, flags byte, body []byte) []byte {
+	dst := make([]byte, 0, len(body)+5)
+	dst = append(dst, byte(t)<<4|flags&0x0f)
+	dst = appendVarint(dst, len(body))
+	return append(dst, body...)
+}
+
+// decodeVarint decodes the variable byte integer, n is 0 if the data is not enough
+func decodeVarint(data []byte) (value int, n int, err error) {
+	multiplier := 1
+	for i := 0; i < 4; i++ {
+		if i >= len(data) {
+			return 0, 0, nil
+		}
+		value += int(data[i]&0x7f) * multiplier
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+		multiplier *= 128
+	}
+	return 0, 0, ErrMalformed
+}
+
+func appendVarint(dst []byte, value int) []byte {
+	for {
+		b := byte(value % 128)
+		value /= 128
+		if value > 0 {
+			b |= 0x80
+		}
+		dst = append(dst, b)
+		if value == 0 {
+			return dst
+		}
+	}
+}
+
+func appendUint16(dst []byte, v uint16) []byte {
+	return append(dst, byte(v>>8), byte(v))
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = appendUint16(dst, uint16(len(s)))
+	return append(dst, s...)
+}
+
+// appendProperties appends the properties of MQTT 5.0 with the length
+func appendProperties(dst []byte, props []byte) []byte {
+	dst = appendVarint(dst, len(props))
+	return append(dst, props...)
+}
+
+// reader reads the fields of the packet body, the first error is kept and
+// the following reads return the zero values
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = ErrMalformed
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) varint() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n, err := decodeVarint(r.data)
+	if err == nil && n == 0 {
+		err = ErrMalformed
+	}
+	if err != nil {
+		r.err = err
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) binary() []byte {
+	return r.next(int(r.uint16()))
+}
+
+func (r *reader) string() string {
+	return string(r.binary())
+}
+
+// properties reads the properties of MQTT 5.0 without the length
+func (r *reader) properties() []byte {
+	return r.next(r.varint())
+}
+
+// property identifiers that are used by the proxy
+const (
+	PropertyTopicAlias byte = 0x23
+)
+
+// LookupProperty returns the value of the first property with the id
+func LookupProperty(props []byte, id byte) ([]byte, bool, error) {
+	r := &reader{data: props}
+	for len(r.data) > 0 {
+		pid := r.varint()
+		start := r.data
+		switch pid {
+		case 0x01, 0x17, 0x19, 0x24, 0x25, 0x28, 0x29, 0x2a:
+			r.next(1)
+		case 0x13, 0x21, 0x22, 0x23:
+			r.next(2)
+		case 0x02, 0x11, 0x18, 0x27:
+			r.next(4)
+		case 0x0b:
+			r.varint()
+		case 0x03, 0x08, 0x09, 0x12, 0x15, 0x16, 0x1a, 0x1c, 0x1f:
+			r.binary()
+		case 0x26:
+			r.binary()
+			r.binary()
+		default:
+			return nil, false, ErrMalformed
+		}
+		if r.err != nil {
+			return nil, false, r.err
+		}
+		if byte(pid) == id {
+			return start[:len(start)-len(r.data)], true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// PacketID reads the packet identifier at the beginning of the body, which is used by
+// PUBACK, PUBREC, PUBREL, PUBCOMP, SUBACK, UNSUBSCRIBE and UNSUBACK
+func PacketID(body []byte) (uint16, error) {
+	if len(body) < 2 {
+		return 0, ErrMalformed
+	}
+	return binary.BigEndian.Uint16(body), nil
+}
@@ -0,0 +1,209 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqtt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	// PINGREQ
+	p, err := Decode([]byte{0xc0, 0x00, 0xd0})
+	if err != nil || p.Type != PINGREQ || len(p.Raw) != 2 || len(p.Body) != 0 {
+		t.Fatalf("decode pingreq failed: %+v %v", p, err)
+	}
+	// the remaining length is encoded in 2 bytes
+	body := bytes.Repeat([]byte{'a'}, 200)
+	data := Encode(PUBLISH, 0, body)
+	if !bytes.Equal(data[:3], []byte{0x30, 0xc8, 0x01}) {
+		t.Fatalf("unexpected fixed header: %v", data[:3])
+	}
+	for i := 0; i < len(data); i++ {
+		if p, err := Decode(data[:i]); p != nil || err != nil {
+			t.Fatalf("decode partial data should wait for more, got %+v %v", p, err)
+		}
+	}
+	p, err = Decode(data)
+	if err != nil || p.Type != PUBLISH || !bytes.Equal(p.Body, body) {
+		t.Fatalf("decode publish failed: %+v %v", p, err)
+	}
+	// the remaining length is more than 4 bytes
+	if _, err := Decode([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}); err != ErrMalformed {
+		t.Errorf("expected malformed, but got %v", err)
+	}
+}
+
+func encodeConnect(version byte, clientID, username string, will bool) []byte {
+	name := "MQTT"
+	if version == Version31 {
+		name = "MQIsdp"
+	}
+	body := appendString(nil, name)
+	var flags byte = 0x02
+	if will {
+		flags |= 0x04
+	}
+	if username != "" {
+		flags |= 0x80 | 0x40
+	}
+	body = append(body, version, flags, 0, 60)
+	if version == Version5 {
+		// session expiry interval
+		body = appendProperties(body, []byte{0x11, 0, 0, 0, 10})
+	}
+	body = appendString(body, clientID)
+	if will {
+		if version == Version5 {
+			body = appendProperties(body, nil)
+		}
+		body = appendString(body, "will/topic")
+		body = appendString(body, "bye")
+	}
+	if username != "" {
+		body = appendString(body, username)
+		body = appendString(body, "password")
+	}
+	return Encode(CONNECT, 0, body)
+}
+
+func TestParseConnect(t *testing.T) {
+	for _, version := range []byte{Version31, Version311, Version5} {
+		p, _ := Decode(encodeConnect(version, "client-1", "user", true))
+		c, err := ParseConnect(p.Body)
+		if err != nil {
+			t.Fatalf("version %d parse connect failed: %v", version, err)
+		}
+		if c.ProtocolLevel != version || !c.CleanSession || c.KeepAlive != 60 ||
+			c.ClientID != "client-1" || !c.HasUsername || c.Username != "user" {
+			t.Errorf("version %d unexpected connect: %+v", version, c)
+		}
+	}
+	p, _ := Decode(encodeConnect(Version311, "", "", false))
+	if c, err := ParseConnect(p.Body); err != nil || c.ClientID != "" || c.HasUsername {
+		t.Errorf("unexpected connect: %+v %v", c, err)
+	}
+	if _, err := ParseConnect(p.Body[:len(p.Body)-1]); err != ErrMalformed {
+		t.Errorf("expected malformed, but got %v", err)
+	}
+	body := append([]byte{}, p.Body...)
+	body[6] = 6
+	if _, err := ParseConnect(body); err != ErrUnsupportedVersion {
+		t.Errorf("expected unsupported version, but got %v", err)
+	}
+
+	if ack := EncodeConnAck(Version311, false, ConnAckServerUnavailable); !bytes.Equal(ack, []byte{0x20, 0x02, 0x00, 0x03}) {
+		t.Errorf("unexpected connack: %v", ack)
+	}
+	if ack := EncodeConnAck(Version5, true, ConnAckNotAuthorizedV5); !bytes.Equal(ack, []byte{0x20, 0x03, 0x01, 0x87, 0x00}) {
+		t.Errorf("unexpected connack: %v", ack)
+	}
+}
+
+func TestParsePublish(t *testing.T) {
+	// QoS 1 with topic alias
+	body := appendString(nil, "a/b")
+	body = appendUint16(body, 10)
+	body = appendProperties(body, []byte{0x01, 0x01, 0x26, 0, 1, 'k', 0, 1, 'v', 0x23, 0, 5})
+	body = append(body, "hello"...)
+	p, _ := Decode(Encode(PUBLISH, 0x0b, body))
+	pub, err := ParsePublish(p, Version5)
+	if err != nil {
+		t.Fatalf("parse publish failed: %v", err)
+	}
+	if !pub.Dup || pub.QoS != 1 || !pub.Retain || pub.Topic != "a/b" || pub.PacketID != 10 ||
+		pub.TopicAlias != 5 || pub.PayloadSize != 5 {
+		t.Errorf("unexpected publish: %+v", pub)
+	}
+
+	// QoS 0 has no packet id
+	body = append(appendString(nil, "a/b"), "hello"...)
+	p, _ = Decode(Encode(PUBLISH, 0, body))
+	if pub, err := ParsePublish(p, Version311); err != nil || pub.PacketID != 0 || pub.PayloadSize != 5 {
+		t.Errorf("unexpected publish: %+v %v", pub, err)
+	}
+	p, _ = Decode(Encode(PUBLISH, 0x06, body))
+	if _, err := ParsePublish(p, Version311); err != ErrMalformed {
+		t.Errorf("QoS 3 is malformed, but got %v", err)
+	}
+
+	if ack := EncodeAck(PUBACK, 10, Version311, ReasonNotAuthorized); !bytes.Equal(ack, []byte{0x40, 0x02, 0x00, 0x0a}) {
+		t.Errorf("unexpected puback: %v", ack)
+	}
+	if ack := EncodeAck(PUBREC, 10, Version5, ReasonNotAuthorized); !bytes.Equal(ack, []byte{0x50, 0x03, 0x00, 0x0a, 0x87}) {
+		t.Errorf("unexpected pubrec: %v", ack)
+	}
+	ack := EncodeAck(PUBREL, 10, Version5, 0)
+	if !bytes.Equal(ack, []byte{0x62, 0x02, 0x00, 0x0a}) {
+		t.Errorf("unexpected pubrel: %v", ack)
+	}
+	p, _ = Decode(ack)
+	if id, err := PacketID(p.Body); err != nil || id != 10 {
+		t.Errorf("unexpected packet id %d %v", id, err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		s := &Subscribe{
+			PacketID: 1,
+			Subscriptions: []Subscription{
+				{Filter: "a/+", Options: 1},
+				{Filter: "b/#", Options: 2},
+			},
+		}
+		if version == Version5 {
+			// subscription identifier
+			s.Properties = []byte{0x0b, 0x01}
+		}
+		p, _ := Decode(s.Encode(version))
+		if p.Type != SUBSCRIBE || p.Flags != 0x02 {
+			t.Fatalf("unexpected fixed header: %+v", p)
+		}
+		parsed, err := ParseSubscribe(p.Body, version)
+		if err != nil {
+			t.Fatalf("parse subscribe failed: %v", err)
+		}
+		if parsed.PacketID != 1 || !bytes.Equal(parsed.Properties, s.Properties) ||
+			len(parsed.Subscriptions) != 2 || parsed.Subscriptions[1] != s.Subscriptions[1] {
+			t.Errorf("unexpected subscribe: %+v", parsed)
+		}
+
+		ack := &SubAck{PacketID: 1, Properties: s.Properties, ReasonCodes: []byte{1, SubAckFailure}}
+		p, _ = Decode(ack.Encode(version))
+		parsedAck, err := ParseSubAck(p.Body, version)
+		if err != nil || parsedAck.PacketID != 1 || !bytes.Equal(parsedAck.ReasonCodes, ack.ReasonCodes) {
+			t.Errorf("unexpected suback: %+v %v", parsedAck, err)
+		}
+	}
+	if _, err := ParseSubscribe([]byte{0, 1}, Version311); err != ErrMalformed {
+		t.Errorf("subscribe without filters is malformed, but got %v", err)
+	}
+}
+
+func TestLookupProperty(t *testing.T) {
+	props := []byte{0x02, 0, 0, 0, 1, 0x0b, 0x81, 0x01, 0x1f, 0, 2, 'o', 'k', 0x23, 0, 7}
+	if v, ok, err := LookupProperty(props, PropertyTopicAlias); !ok || err != nil || !bytes.Equal(v, []byte{0, 7}) {
+		t.Errorf("unexpected topic alias: %v %v %v", v, ok, err)
+	}
+	if _, ok, err := LookupProperty(props[:13], PropertyTopicAlias); ok || err != nil {
+		t.Errorf("topic alias should not be found: %v %v", ok, err)
+	}
+	if _, _, err := LookupProperty([]byte{0x7f, 0}, PropertyTopicAlias); err != ErrMalformed {
+		t.Errorf("expected malformed, but got %v", err)
+	}
+}
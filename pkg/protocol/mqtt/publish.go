@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqtt
+
+import "encoding/binary"
+
+// ReasonNotAuthorized is the reason code of MQTT 5.0 for the unauthorized publications and subscriptions
+const ReasonNotAuthorized byte = 0x87
+
+// Publish is the PUBLISH packet
+type Publish struct {
+	Dup      bool
+	QoS      byte
+	Retain   bool
+	Topic    string
+	PacketID uint16
+	// TopicAlias is the topic alias of MQTT 5.0, the topic may be empty if the alias is set
+	TopicAlias uint16
+	// PayloadSize is the size of the application message
+	PayloadSize int
+}
+
+// ParsePublish parses the PUBLISH packet
+func ParsePublish(p *Packet, version byte) (*Publish, error) {
+	pub := &Publish{
+		Dup:    p.Flags&0x08 != 0,
+		QoS:    (p.Flags >> 1) & 0x03,
+		Retain: p.Flags&0x01 != 0,
+	}
+	if pub.QoS > 2 {
+		return nil, ErrMalformed
+	}
+	r := &reader{data: p.Body}
+	pub.Topic = r.string()
+	if pub.QoS > 0 {
+		pub.PacketID = r.uint16()
+	}
+	if version == Version5 {
+		props := r.properties()
+		if r.err != nil {
+			return nil, r.err
+		}
+		alias, ok, err := LookupProperty(props, PropertyTopicAlias)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			pub.TopicAlias = binary.BigEndian.Uint16(alias)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	pub.PayloadSize = len(r.data)
+	return pub, nil
+}
+
+// EncodeAck encodes the PUBACK, PUBREC, PUBREL and PUBCOMP packets,
+// the reason code is only encoded for MQTT 5.0
+func EncodeAck(t PacketType, packetID uint16, version byte, reasonCode byte) []byte {
+	body := appendUint16(make([]byte, 0, 3), packetID)
+	if version == Version5 && reasonCode != 0 {
+		body = append(body, reasonCode)
+	}
+	var flags byte
+	if t == PUBREL {
+		flags = 0x02
+	}
+	return Encode(t, flags, body)
+}
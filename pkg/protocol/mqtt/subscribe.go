@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqtt
+
+// SubAckFailure is the return code of the failed subscription before MQTT 5.0,
+// ReasonNotAuthorized is used by MQTT 5.0 instead
+const SubAckFailure byte = 0x80
+
+// Subscription is a topic filter with the subscription options
+type Subscription struct {
+	Filter  string
+	Options byte
+}
+
+// Subscribe is the SUBSCRIBE packet
+type Subscribe struct {
+	PacketID uint16
+	// Properties are the properties of MQTT 5.0 without the length
+	Properties    []byte
+	Subscriptions []Subscription
+}
+
+// ParseSubscribe parses the body of the SUBSCRIBE packet
+func ParseSubscribe(body []byte, version byte) (*Subscribe, error) {
+	r := &reader{data: body}
+	s := &Subscribe{
+		PacketID: r.uint16(),
+	}
+	if version == Version5 {
+		s.Properties = r.properties()
+	}
+	for r.err == nil && len(r.data) > 0 {
+		s.Subscriptions = append(s.Subscriptions, Subscription{
+			Filter:  r.string(),
+			Options: r.byte(),
+		})
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(s.Subscriptions) == 0 {
+		return nil, ErrMalformed
+	}
+	return s, nil
+}
+
+// Encode encodes the SUBSCRIBE packet
+func (s *Subscribe) Encode(version byte) []byte {
+	body := appendUint16(nil, s.PacketID)
+	if version == Version5 {
+		body = appendProperties(body, s.Properties)
+	}
+	for _, sub := range s.Subscriptions {
+		body = appendString(body, sub.Filter)
+		body = append(body, sub.Options)
+	}
+	return Encode(SUBSCRIBE, 0x02, body)
+}
+
+// SubAck is the SUBACK packet
+type SubAck struct {
+	PacketID uint16
+	// Properties are the properties of MQTT 5.0 without the length
+	Properties []byte
+	// ReasonCodes are the return codes of the subscriptions in order
+	ReasonCodes []byte
+}
+
+// ParseSubAck parses the body of the SUBACK packet
+func ParseSubAck(body []byte, version byte) (*SubAck, error) {
+	r := &reader{data: body}
+	s := &SubAck{
+		PacketID: r.uint16(),
+	}
+	if version == Version5 {
+		s.Properties = r.properties()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	s.ReasonCodes = r.data
+	return s, nil
+}
+
+// Encode encodes the SUBACK packet
+func (s *SubAck) Encode(version byte) []byte {
+	body := appendUint16(nil, s.PacketID)
+	if version == Version5 {
+		body = appendProperties(body, s.Properties)
+	}
+	body = append(body, s.ReasonCodes...)
+	return Encode(SUBACK, 0, body)
+}
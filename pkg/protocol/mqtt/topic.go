@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqtt
+
+import "strings"
+
+// MatchTopic reports whether the topic name matches the topic filter, the wildcards
+// do not match the topics beginning with '$' at the first level
+func MatchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	return covers(strings.Split(filter, "/"), strings.Split(topic, "/"))
+}
+
+// CoverFilter reports whether all the topics matched by the subscription filter are
+// matched by the filter as well, e.g. "sensors/#" covers "sensors/+/temperature"
+func CoverFilter(filter, subscription string) bool {
+	if strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#") {
+		// the filter does not cover the topics beginning with '$'
+		if strings.HasPrefix(subscription, "$") {
+			return false
+		}
+	}
+	return covers(strings.Split(filter, "/"), strings.Split(subscription, "/"))
+}
+
+// OverlapFilter reports whether there is any topic matched by both filters
+func OverlapFilter(a, b string) bool {
+	if strings.HasPrefix(a, "$") != strings.HasPrefix(b, "$") {
+		// the wildcards at the first level do not match the topics beginning with '$'
+		return false
+	}
+	la, lb := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(la) && i < len(lb); i++ {
+		if la[i] == "#" || lb[i] == "#" {
+			return true
+		}
+		if la[i] != "+" && lb[i] != "+" && la[i] != lb[i] {
+			return false
+		}
+	}
+	if len(la) < len(lb) {
+		la, lb = lb, la
+	}
+	// "a/#" matches "a" as well
+	return len(la) == len(lb) || (len(la) == len(lb)+1 && la[len(lb)] == "#")
+}
+
+func covers(filter, levels []string) bool {
+	for i, f := range filter {
+		if f == "#" {
+			return true
+		}
+		if i >= len(levels) {
+			return false
+		}
+		switch levels[i] {
+		case "#":
+			return false
+		case "+":
+			if f != "+" {
+				return false
+			}
+		default:
+			if f != "+" && f != levels[i] {
+				return false
+			}
+		}
+	}
+	return len(filter) == len(levels)
+}
@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mqtt
+
+import "testing"
+
+func TestMatchTopic(t *testing.T) {
+	for _, tc := range []struct {
+		filter, topic string
+		expected      bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/+", "a", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"+/+", "/b", true},
+		{"#", "$SYS/broker", false},
+		{"+/broker", "$SYS/broker", false},
+		{"$SYS/#", "$SYS/broker", true},
+	} {
+		if MatchTopic(tc.filter, tc.topic) != tc.expected {
+			t.Errorf("match %s with %s, expected %v", tc.filter, tc.topic, tc.expected)
+		}
+	}
+}
+
+func TestCoverFilter(t *testing.T) {
+	for _, tc := range []struct {
+		filter, subscription string
+		expected             bool
+	}{
+		{"a/#", "a/+/c", true},
+		{"a/#", "a/#", true},
+		{"a/+", "a/+", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/#", false},
+		{"a/b", "a/+", false},
+		{"a/+/c", "a/b/#", false},
+		{"#", "$SYS/#", false},
+		{"$SYS/#", "$SYS/+", true},
+	} {
+		if CoverFilter(tc.filter, tc.subscription) != tc.expected {
+			t.Errorf("cover %s with %s, expected %v", tc.subscription, tc.filter, tc.expected)
+		}
+	}
+}
+
+func TestOverlapFilter(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected bool
+	}{
+		{"a/#", "a/secret", true},
+		{"a/secret", "a/+", true},
+		{"a/+/c", "a/b/+", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a", "a/+", false},
+		{"#", "$SYS/broker", false},
+		{"$SYS/#", "$SYS/+", true},
+	} {
+		if OverlapFilter(tc.a, tc.b) != tc.expected || OverlapFilter(tc.b, tc.a) != tc.expected {
+			t.Errorf("overlap %s with %s, expected %v", tc.a, tc.b, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package mysql implements the codec of the MySQL client/server protocol packets that
+// are inspected by the proxy.
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// PacketHeaderSize is the size of the payload length and the sequence id
+	PacketHeaderSize = 4
+	// MaxPayloadSize is the max payload size of a packet, the larger payloads are split
+	// into several packets and the last one is less than it
+	MaxPayloadSize = 1<<24 - 1
+)
+
+// capability flags
+const (
+	ClientLongPassword               uint32 = 0x00000001
+	ClientConnectWithDB              uint32 = 0x00000008
+	ClientProtocol41                 uint32 = 0x00000200
+	ClientSSL                        uint32 = 0x00000800
+	ClientSecureConnection           uint32 = 0x00008000
+	ClientPluginAuth                 uint32 = 0x00080000
+	ClientPluginAuthLenencClientData uint32 = 0x00200000
+	ClientDeprecateEOF               uint32 = 0x01000000
+	ClientQueryAttributes            uint32 = 0x08000000
+)
+
+// ServerMoreResultsExists is the status flag indicating that there are more result sets
+const ServerMoreResultsExists uint16 = 0x0008
+
+// commands
+const (
+	ComQuit             byte = 0x01
+	ComInitDB           byte = 0x02
+	ComQuery            byte = 0x03
+	ComPing             byte = 0x0e
+	ComChangeUser       byte = 0x11
+	ComStmtPrepare      byte = 0x16
+	ComStmtExecute      byte = 0x17
+	ComStmtSendLongData byte = 0x18
+	ComStmtClose        byte = 0x19
+	ComStmtReset        byte = 0x1a
+	ComResetConnection  byte = 0x1f
+)
+
+// the headers of the response packets
+const (
+	HeaderOK          byte = 0x00
+	HeaderAuthMore    byte = 0x01
+	HeaderLocalInFile byte = 0xfb
+	HeaderEOF         byte = 0xfe
+	HeaderERR         byte = 0xff
+)
+
+// ProtocolVersion is the version of the initial handshake
+const ProtocolVersion byte = 10
+
+// ErrMalformed is returned if the packet cannot be decoded
+var ErrMalformed = errors.New("mysql: malformed packet")
+
+// Packet is a decoded packet, the slices refer to the decoded data
+type Packet struct {
+	Seq     byte
+	Payload []byte
+	// Raw is the whole packet including the header
+	Raw []byte
+}
+
+// Decode decodes the first packet of data, nil is returned if the data is not enough
+func Decode(data []byte) *Packet {
+	if len(data) < PacketHeaderSize {
+		return nil
+	}
+	length := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+	if len(data) < PacketHeaderSize+length {
+		return nil
+	}
+	return &Packet{
+		Seq:     data[3],
+		Payload: data[PacketHeaderSize : PacketHeaderSize+length],
+		Raw:     data[:PacketHeaderSize+length],
+	}
+}
+
+// Encode encodes the payload into a packet, the payload should be less than MaxPayloadSize
+func Encode(seq byte, payload []byte) []byte {
+	n := len(payload)
+	dst := make([]byte, 0, PacketHeaderSize+n)
+	dst = append(dst, byte(n), byte(n>>8), byte(n>>16), seq)
+	return append(dst, payload...)
+}
+
+// Handshake is the initial handshake packet of the server
+type Handshake struct {
+	ProtocolVersion byte
+	ServerVersion   string
+	ConnectionID    uint32
+	Capabilities    uint32
+}
+
+// ParseHandshake parses the initial handshake packet
+func ParseHandshake(payload []byte) (*Handshake, error) {
+	r := &reader{data: payload}
+	h := &Handshake{
+		ProtocolVersion: r.byte(),
+	}
+	if h.ProtocolVersion != ProtocolVersion {
+		return nil, ErrMalformed
+	}
+	h.ServerVersion = r.nullString()
+	h.ConnectionID = r.uint32()
+	// auth plugin data part 1 and the filler
+	r.next(9)
+	h.Capabilities = uint32(r.uint16())
+	if r.err == nil && len(r.data) > 0 {
+		// character set and status flags
+		r.next(3)
+		h.Capabilities |= uint32(r.uint16()) << 16
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return h, nil
+}
+
+// HandshakeResponse is the handshake response packet of the client
+type HandshakeResponse struct {
+	Capabilities uint32
+	// SSLRequest is set if the packet is a SSL request, the connection is upgraded to TLS after it
+	SSLRequest bool
+	Username   string
+	Database   string
+}
+
+// ParseHandshakeResponse parses the handshake response packet
+func ParseHandshakeResponse(payload []byte) (*HandshakeResponse, error) {
+	r := &reader{data: payload}
+	resp := &HandshakeResponse{
+		Capabilities: uint32(r.uint16()),
+	}
+	if resp.Capabilities&ClientProtocol41 == 0 {
+		// HandshakeResponse320
+		r.next(3)
+		resp.SSLRequest = resp.Capabilities&ClientSSL != 0 && r.err == nil && len(r.data) == 0
+		if !resp.SSLRequest {
+			resp.Username = r.nullString()
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		return resp, nil
+	}
+	resp.Capabilities |= uint32(r.uint16()) << 16
+	// max packet size, character set and the filler
+	r.next(28)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.data) == 0 {
+		resp.SSLRequest = resp.Capabilities&ClientSSL != 0
+		if !resp.SSLRequest {
+			return nil, ErrMalformed
+		}
+		return resp, nil
+	}
+	resp.Username = r.nullString()
+	switch {
+	case resp.Capabilities&ClientPluginAuthLenencClientData != 0:
+		r.next(int(r.lenencInt()))
+	case resp.Capabilities&ClientSecureConnection != 0:
+		r.next(int(r.byte()))
+	default:
+		r.nullString()
+	}
+	if resp.Capabilities&ClientConnectWithDB != 0 && r.err == nil && len(r.data) > 0 {
+		resp.Database = r.nullString()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return resp, nil
+}
+
+// OK is the OK packet, which is also used as the EOF packet if ClientDeprecateEOF is set
+type OK struct {
+	AffectedRows uint64
+	LastInsertID uint64
+	StatusFlags  uint16
+}
+
+// ParseOK parses the OK packet
+func ParseOK(payload []byte, capabilities uint32) (*OK, error) {
+	r := &reader{data: payload}
+	r.byte()
+	ok := &OK{
+		AffectedRows: r.lenencInt(),
+		LastInsertID: r.lenencInt(),
+	}
+	if capabilities&ClientProtocol41 != 0 {
+		ok.StatusFlags = r.uint16()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return ok, nil
+}
+
+// IsEOF reports whether the payload is an EOF packet or an OK packet ending the result set,
+// a row of the result set may begin with 0xfe as well but it is much longer
+func IsEOF(payload []byte, capabilities uint32) bool {
+	if len(payload) == 0 || payload[0] != HeaderEOF {
+		return false
+	}
+	if capabilities&ClientDeprecateEOF != 0 {
+		return len(payload) < MaxPayloadSize
+	}
+	return len(payload) < 9
+}
+
+// EOFStatus returns the status flags of the EOF packet or the OK packet ending the result set
+func EOFStatus(payload []byte, capabilities uint32) uint16 {
+	if capabilities&ClientDeprecateEOF != 0 {
+		if ok, err := ParseOK(payload, capabilities); err == nil {
+			return ok.StatusFlags
+		}
+		return 0
+	}
+	if len(payload) >= 5 {
+		return binary.LittleEndian.Uint16(payload[3:])
+	}
+	return 0
+}
+
+// Err is the ERR packet
+type Err struct {
+	Code     uint16
+	SQLState string
+	Message  string
+}
+
+// ParseErr parses the ERR packet
+func ParseErr(payload []byte, capabilities uint32) (*Err, error) {
+	r := &reader{data: payload}
+	if r.byte() != HeaderERR {
+		return nil, ErrMalformed
+	}
+	e := &Err{
+		Code: r.uint16(),
+	}
+	if capabilities&ClientProtocol41 != 0 && r.err == nil && len(r.data) > 0 && r.data[0] == '#' {
+		r.next(1)
+		e.SQLState = string(r.next(5))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	e.Message = string(r.data)
+	return e, nil
+}
+
+// Encode encodes the ERR packet
+func (e *Err) Encode(seq byte, capabilities uint32) []byte {
+	payload := []byte{HeaderERR, byte(e.Code), byte(e.Code >> 8)}
+	if capabilities&ClientProtocol41 != 0 {
+		payload = append(payload, '#')
+		payload = append(payload, e.SQLState...)
+	}
+	payload = append(payload, e.Message...)
+	return Encode(seq, payload)
+}
+
+// StmtPrepareOK is the first packet of the COM_STMT_PREPARE response
+type StmtPrepareOK struct {
+	StatementID uint32
+	Columns     uint16
+	Params      uint16
+}
+
+// ParseStmtPrepareOK parses the first packet of the COM_STMT_PREPARE response
+func ParseStmtPrepareOK(payload []byte) (*StmtPrepareOK, error) {
+	r := &reader{data: payload}
+	if r.byte() != HeaderOK {
+		return nil, ErrMalformed
+	}
+	ok := &StmtPrepareOK{
+		StatementID: r.uint32(),
+		Columns:     r.uint16(),
+		Params:      r.uint16(),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return ok, nil
+}
+
+// ParseQuery returns the statement of the COM_QUERY packet, false is returned if it has query attributes
+func ParseQuery(payload []byte, capabilities uint32) (string, bool) {
+	r := &reader{data: payload}
+	r.byte()
+	if capabilities&ClientQueryAttributes != 0 {
+		params := r.lenencInt()
+		r.lenencInt()
+		if params > 0 {
+			return "", false
+		}
+	}
+	if r.err != nil {
+		return "", false
+	}
+	return string(r.data), true
+}
+
+// LenencInt decodes the length-encoded integer, n is 0 if it is malformed
+func LenencInt(data []byte) (v uint64, n int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	switch data[0] {
+	case 0xfc:
+		n = 3
+	case 0xfd:
+		n = 4
+	case 0xfe:
+		n = 9
+	case 0xfb, 0xff:
+		return 0, 0
+	default:
+		return uint64(data[0]), 1
+	}
+	if len(data) < n {
+		return 0, 0
+	}
+	for i := n - 1; i > 0; i-- {
+		v = v<<8 | uint64(data[i])
+	}
+	return v, n
+}
+
+// reader reads the fields of the payload, the first error is kept and
+// the following reads return the zero values
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = ErrMalformed
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) lenencInt() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := LenencInt(r.data)
+	if n == 0 {
+		r.err = ErrMalformed
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) nullString() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.data, 0)
+	if i < 0 {
+		r.err = ErrMalformed
+		return ""
+	}
+	s := string(r.data[:i])
+	r.data = r.data[i+1:]
+	return s
+}
@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysql
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	data := Encode(3, []byte{ComQuery, 's', 'e', 'l'})
+	if !bytes.Equal(data[:4], []byte{4, 0, 0, 3}) {
+		t.Fatalf("unexpected header: %v", data[:4])
+	}
+	for i := 0; i < len(data); i++ {
+		if p := Decode(data[:i]); p != nil {
+			t.Fatalf("decode partial data should wait for more, got %+v", p)
+		}
+	}
+	p := Decode(append(data, 0xff))
+	if p == nil || p.Seq != 3 || len(p.Raw) != len(data) || p.Payload[0] != ComQuery {
+		t.Errorf("unexpected packet: %+v", p)
+	}
+}
+
+func TestParseHandshake(t *testing.T) {
+	payload := []byte{ProtocolVersion}
+	payload = append(payload, "8.0.20\x00"...)
+	payload = append(payload, 1, 0, 0, 0)
+	payload = append(payload, make([]byte, 9)...)
+	payload = append(payload, 0x00, 0x82, 0x21, 0x02, 0x00, 0x00, 0x01)
+	h, err := ParseHandshake(payload)
+	if err != nil {
+		t.Fatalf("parse handshake failed: %v", err)
+	}
+	if h.ServerVersion != "8.0.20" || h.ConnectionID != 1 || h.Capabilities != 0x01008200 {
+		t.Errorf("unexpected handshake: %+v", h)
+	}
+	if _, err := ParseHandshake([]byte{9}); err != ErrMalformed {
+		t.Errorf("expected malformed, but got %v", err)
+	}
+}
+
+func encodeHandshakeResponse(capabilities uint32, user, db string) []byte {
+	payload := []byte{byte(capabilities), byte(capabilities >> 8), byte(capabilities >> 16), byte(capabilities >> 24)}
+	payload = append(payload, make([]byte, 28)...)
+	if user == "" {
+		return payload
+	}
+	payload = append(payload, user...)
+	payload = append(payload, 0, 2, 'p', 'w')
+	if db != "" {
+		payload = append(payload, db...)
+		payload = append(payload, 0)
+	}
+	return payload
+}
+
+func TestParseHandshakeResponse(t *testing.T) {
+	caps := ClientProtocol41 | ClientSecureConnection | ClientConnectWithDB
+	resp, err := ParseHandshakeResponse(encodeHandshakeResponse(caps, "root", "test"))
+	if err != nil {
+		t.Fatalf("parse handshake response failed: %v", err)
+	}
+	if resp.SSLRequest || resp.Username != "root" || resp.Database != "test" || resp.Capabilities != caps {
+		t.Errorf("unexpected handshake response: %+v", resp)
+	}
+	resp, err = ParseHandshakeResponse(encodeHandshakeResponse(caps|ClientSSL, "", ""))
+	if err != nil || !resp.SSLRequest {
+		t.Errorf("expected ssl request: %+v %v", resp, err)
+	}
+	if _, err := ParseHandshakeResponse(encodeHandshakeResponse(caps, "", "")); err != ErrMalformed {
+		t.Errorf("expected malformed, but got %v", err)
+	}
+}
+
+func TestResponsePackets(t *testing.T) {
+	caps := ClientProtocol41
+	ok, err := ParseOK([]byte{HeaderOK, 0xfc, 0x00, 0x01, 5, 0x08, 0x00, 0, 0}, caps)
+	if err != nil || ok.AffectedRows != 256 || ok.LastInsertID != 5 || ok.StatusFlags != ServerMoreResultsExists {
+		t.Errorf("unexpected ok: %+v %v", ok, err)
+	}
+
+	eof := []byte{HeaderEOF, 0, 0, 0x08, 0}
+	if !IsEOF(eof, caps) || EOFStatus(eof, caps) != ServerMoreResultsExists {
+		t.Errorf("unexpected eof")
+	}
+	if IsEOF(append([]byte{HeaderEOF}, make([]byte, 10)...), caps) {
+		t.Errorf("row beginning with 0xfe is not eof")
+	}
+	okEOF := []byte{HeaderEOF, 0, 0, 0x08, 0, 0, 0}
+	if !IsEOF(okEOF, caps|ClientDeprecateEOF) || EOFStatus(okEOF, caps|ClientDeprecateEOF) != ServerMoreResultsExists {
+		t.Errorf("unexpected ok ending the result set")
+	}
+
+	e := &Err{Code: 1227, SQLState: "42000", Message: "denied"}
+	p := Decode(e.Encode(1, caps))
+	parsed, err := ParseErr(p.Payload, caps)
+	if err != nil || p.Seq != 1 || !reflect.DeepEqual(parsed, e) {
+		t.Errorf("unexpected err: %+v %v", parsed, err)
+	}
+
+	prepare, err := ParseStmtPrepareOK([]byte{HeaderOK, 1, 0, 0, 0, 2, 0, 3, 0, 0, 0, 0})
+	if err != nil || prepare.StatementID != 1 || prepare.Columns != 2 || prepare.Params != 3 {
+		t.Errorf("unexpected prepare ok: %+v %v", prepare, err)
+	}
+
+	if sql, ok := ParseQuery([]byte("\x03select 1"), caps); !ok || sql != "select 1" {
+		t.Errorf("unexpected query: %s", sql)
+	}
+	if sql, ok := ParseQuery([]byte("\x03\x00\x01select 1"), caps|ClientQueryAttributes); !ok || sql != "select 1" {
+		t.Errorf("unexpected query: %s", sql)
+	}
+	if _, ok := ParseQuery([]byte("\x03\x01\x01"), caps|ClientQueryAttributes); ok {
+		t.Errorf("query with attributes is not parsed")
+	}
+}
+
+func TestLenencInt(t *testing.T) {
+	for _, tc := range []struct {
+		data []byte
+		v    uint64
+		n    int
+	}{
+		{[]byte{0xfa}, 250, 1},
+		{[]byte{0xfc, 0xfb, 0x00}, 251, 3},
+		{[]byte{0xfd, 0x00, 0x00, 0x01}, 65536, 4},
+		{[]byte{0xfe, 1, 0, 0, 0, 0, 0, 0, 1}, 1<<56 + 1, 9},
+		{[]byte{0xfe, 1}, 0, 0},
+		{[]byte{0xfb}, 0, 0},
+	} {
+		if v, n := LenencInt(tc.data); v != tc.v || n != tc.n {
+			t.Errorf("%v decoded as %d %d", tc.data, v, n)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysql
+
+import "strings"
+
+// Statement is the summary of a SQL statement
+type Statement struct {
+	// Type is the first keyword of the statement in lower case, e.g. select, insert, begin
+	Type string
+	// Tables are the tables referenced by the statement without the quotes
+	Tables []string
+}
+
+// UnknownStatement is the type of the statements that cannot be parsed
+const UnknownStatement = "unknown"
+
+// the keywords followed by table references
+var tableKeywords = map[string]bool{
+	"FROM":   true,
+	"JOIN":   true,
+	"INTO":   true,
+	"UPDATE": true,
+	"TABLE":  true,
+}
+
+// the keywords that may follow a table reference, which are not aliases
+var clauseKeywords = map[string]bool{
+	"WHERE": true, "SET": true, "VALUES": true, "VALUE": true, "SELECT": true, "ON": true,
+	"USING": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "CROSS": true,
+	"NATURAL": true, "STRAIGHT_JOIN": true, "OUTER": true, "GROUP": true, "ORDER": true,
+	"HAVING": true, "LIMIT": true, "UNION": true, "FOR": true, "LOCK": true, "WINDOW": true,
+	"PARTITION": true, "USE": true, "IGNORE": true, "FORCE": true, "ADD": true, "DROP": true,
+	"MODIFY": true, "CHANGE": true, "RENAME": true, "LIKE": true, "AS": true, "WITH": true,
+}
+
+// the modifiers that may be between the table keywords and the tables
+var tableModifiers = map[string]bool{
+	"IF": true, "NOT": true, "EXISTS": true, "LOW_PRIORITY": true, "IGNORE": true,
+	"QUICK": true, "ONLY": true, "TEMPORARY": true,
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenIdentifier
+	tokenSymbol
+	tokenLiteral
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// ParseStatement parses the type and the tables of the statement, it is a lightweight
+// tokenizer that recognizes the common statements rather than a full SQL parser
+func ParseStatement(sql string) *Statement {
+	tokens := tokenize(sql)
+	stmt := &Statement{Type: UnknownStatement}
+	for _, t := range tokens {
+		if t.kind == tokenWord {
+			stmt.Type = strings.ToLower(t.text)
+			break
+		}
+		if t.kind != tokenSymbol || t.text != "(" {
+			break
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].kind != tokenWord || !tableKeywords[strings.ToUpper(tokens[i].text)] {
+			continue
+		}
+		i++
+		for i < len(tokens) && tokens[i].kind == tokenWord && tableModifiers[strings.ToUpper(tokens[i].text)] {
+			i++
+		}
+		// the table references separated by commas
+		for i < len(tokens) && isName(tokens[i]) {
+			if table := tokens[i].text; !seen[table] {
+				seen[table] = true
+				stmt.Tables = append(stmt.Tables, table)
+			}
+			i++
+			// alias
+			if i < len(tokens) && tokens[i].kind == tokenWord && strings.ToUpper(tokens[i].text) == "AS" {
+				i++
+			}
+			if i < len(tokens) && isName(tokens[i]) {
+				i++
+			}
+			if i >= len(tokens) || tokens[i].kind != tokenSymbol || tokens[i].text != "," {
+				break
+			}
+			i++
+		}
+		i--
+	}
+	return stmt
+}
+
+func isName(t token) bool {
+	return t.kind == tokenIdentifier || (t.kind == tokenWord && !clauseKeywords[strings.ToUpper(t.text)])
+}
+
+func tokenize(sql string) []token {
+	var tokens []token
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || (c == '-' && strings.HasPrefix(sql[i:], "-- ")):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'' || c == '"':
+			i = skipQuoted(sql, i)
+			tokens = append(tokens, token{kind: tokenLiteral})
+		case c == '`' || isWordChar(c):
+			var name string
+			name, i = readName(sql, i)
+			kind := tokenIdentifier
+			if c != '`' && !strings.Contains(name, ".") {
+				kind = tokenWord
+			}
+			tokens = append(tokens, token{kind: kind, text: name})
+		default:
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+// readName reads the identifier which may be quoted and qualified, e.g. `db`.`table`
+func readName(sql string, i int) (string, int) {
+	var parts []string
+	for {
+		var part string
+		if i < len(sql) && sql[i] == '`' {
+			end := i + 1
+			for end < len(sql) && sql[end] != '`' {
+				end++
+			}
+			part = sql[i+1 : end]
+			i = end + 1
+		} else {
+			start := i
+			for i < len(sql) && isWordChar(sql[i]) {
+				i++
+			}
+			part = sql[start:i]
+		}
+		parts = append(parts, part)
+		if i+1 >= len(sql) || sql[i] != '.' || (sql[i+1] != '`' && !isWordChar(sql[i+1])) {
+			break
+		}
+		i++
+	}
+	if i > len(sql) {
+		i = len(sql)
+	}
+	return strings.Join(parts, "."), i
+}
+
+func skipQuoted(sql string, i int) int {
+	quote := sql[i]
+	for i++; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			i++
+		case quote:
+			// the doubled quote is escaped
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return i
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
@@ -0,0 +1,49 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStatement(t *testing.T) {
+	for _, tc := range []struct {
+		sql    string
+		typ    string
+		tables []string
+	}{
+		{"SELECT * FROM users WHERE id = 1", "select", []string{"users"}},
+		{"select a.id from `db`.`orders` a join items b on a.id = b.order_id", "select", []string{"db.orders", "items"}},
+		{"SELECT * FROM t1, t2 AS b, db.t3 WHERE 1", "select", []string{"t1", "t2", "db.t3"}},
+		{"/* comment */ insert into logs (msg) values ('from x')", "insert", []string{"logs"}},
+		{"UPDATE LOW_PRIORITY accounts SET balance = 0", "update", []string{"accounts"}},
+		{"DELETE FROM sessions WHERE expired", "delete", []string{"sessions"}},
+		{"create table if not exists `my table` (id int)", "create", []string{"my table"}},
+		{"DROP TABLE a, b", "drop", []string{"a", "b"}},
+		{"(SELECT 1 FROM t) UNION (SELECT 2 FROM t)", "select", []string{"t"}},
+		{"SELECT * FROM (SELECT id FROM inner_t) d", "select", []string{"inner_t"}},
+		{"-- comment\nBEGIN", "begin", nil},
+		{"SELECT 'it''s FROM x' FROM y", "select", []string{"y"}},
+		{"", UnknownStatement, nil},
+	} {
+		stmt := ParseStatement(tc.sql)
+		if stmt.Type != tc.typ || !reflect.DeepEqual(stmt.Tables, tc.tables) {
+			t.Errorf("%q parsed as %+v", tc.sql, stmt)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package redis implements the codec of the REdis Serialization Protocol(RESP)
+package redis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValueType is the first byte of the RESP value
+type ValueType byte
+
+const (
+	SimpleString ValueType = '+'
+	Error        ValueType = '-'
+	Integer      ValueType = ':'
+	BulkString   ValueType = '$'
+	Array        ValueType = '*'
+)
+
+const (
+	// maxBulkLength is the max length of the bulk string accepted by redis
+	maxBulkLength = 512 * 1024 * 1024
+	// maxArrayLength limits the elements of the array, so a malformed length can not exhaust the memory
+	maxArrayLength = 1024 * 1024
+	// maxLineLength limits the line without CRLF
+	maxLineLength = 64 * 1024
+)
+
+var (
+	ErrProtocol = errors.New("redis protocol error")
+
+	crlf = []byte("\r\n")
+)
+
+// Value is a RESP value, Null is set for the null bulk string and the null array
+type Value struct {
+	Type  ValueType
+	Str   string
+	Int   int64
+	Array []*Value
+	Null  bool
+}
+
+// NewSimpleString creates a simple string value
+func NewSimpleString(s string) *Value {
+	return &Value{Type: SimpleString, Str: s}
+}
+
+// NewError creates an error value
+func NewError(msg string) *Value {
+	return &Value{Type: Error, Str: msg}
+}
+
+// NewInteger creates an integer value
+func NewInteger(i int64) *Value {
+	return &Value{Type: Integer, Int: i}
+}
+
+// NewBulkString creates a bulk string value
+func NewBulkString(s string) *Value {
+	return &Value{Type: BulkString, Str: s}
+}
+
+// NewNullBulkString creates a null bulk string value
+func NewNullBulkString() *Value {
+	return &Value{Type: BulkString, Null: true}
+}
+
+// NewArray creates an array value
+func NewArray(values ...*Value) *Value {
+	if values == nil {
+		values = []*Value{}
+	}
+	return &Value{Type: Array, Array: values}
+}
+
+// NewCommand creates a command, which is an array of bulk strings
+func NewCommand(args ...string) *Value {
+	values := make([]*Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, NewBulkString(arg))
+	}
+	return NewArray(values...)
+}
+
+// IsError returns true if the value is an error
+func (v *Value) IsError() bool {
+	return v.Type == Error
+}
+
+// Args returns the arguments of the command, false is returned if the value is not a command
+func (v *Value) Args() ([]string, bool) {
+	if v.Type != Array || v.Null || len(v.Array) == 0 {
+		return nil, false
+	}
+	args := make([]string, 0, len(v.Array))
+	for _, arg := range v.Array {
+		if arg.Type != BulkString || arg.Null {
+			return nil, false
+		}
+		args = append(args, arg.Str)
+	}
+	return args, true
+}
+
+// String returns the readable format of the value
+func (v *Value) String() string {
+	switch v.Type {
+	case SimpleString:
+		return v.Str
+	case Error:
+		return "(error) " + v.Str
+	case Integer:
+		return "(integer) " + strconv.FormatInt(v.Int, 10)
+	case BulkString:
+		if v.Null {
+			return "(nil)"
+		}
+		return strconv.Quote(v.Str)
+	case Array:
+		if v.Null {
+			return "(nil)"
+		}
+		items := make([]string, 0, len(v.Array))
+		for _, item := range v.Array {
+			items = append(items, item.String())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprintf("(unknown type %q)", byte(v.Type))
+}
+
+// Encode appends the RESP format of the value to the dst
+func (v *Value) Encode(dst []byte) []byte {
+	dst = append(dst, byte(v.Type))
+	switch v.Type {
+	case SimpleString, Error:
+		dst = append(dst, v.Str...)
+	case Integer:
+		dst = strconv.AppendInt(dst, v.Int, 10)
+	case BulkString:
+		if v.Null {
+			return append(dst, "-1\r\n"...)
+		}
+		dst = strconv.AppendInt(dst, int64(len(v.Str)), 10)
+		dst = append(dst, crlf...)
+		dst = append(dst, v.Str...)
+	case Array:
+		if v.Null {
+			return append(dst, "-1\r\n"...)
+		}
+		dst = strconv.AppendInt(dst, int64(len(v.Array)), 10)
+		dst = append(dst, crlf...)
+		for _, item := range v.Array {
+			dst = item.Encode(dst)
+		}
+		return dst
+	}
+	return append(dst, crlf...)
+}
+
+// Decode decodes a value from the data, and returns the value and the number of bytes consumed.
+// A nil value and no error is returned if the data is not enough.
+func Decode(data []byte) (*Value, int, error) {
+	return decode(data, 0)
+}
+
+func decode(data []byte, pos int) (*Value, int, error) {
+	line, next, err := readLine(data, pos)
+	if line == nil || err != nil {
+		return nil, 0, err
+	}
+	v := &Value{Type: ValueType(line[0])}
+	switch v.Type {
+	case SimpleString, Error:
+		v.Str = string(line[1:])
+		return v, next - pos, nil
+	case Integer:
+		i, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return nil, 0, ErrProtocol
+		}
+		v.Int = i
+		return v, next - pos, nil
+	case BulkString:
+		n, err := parseLength(line[1:], maxBulkLength)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n < 0 {
+			v.Null = true
+			return v, next - pos, nil
+		}
+		end := next + n
+		if len(data) < end+2 {
+			return nil, 0, nil
+		}
+		if data[end] != '\r' || data[end+1] != '\n' {
+			return nil, 0, ErrProtocol
+		}
+		v.Str = string(data[next:end])
+		return v, end + 2 - pos, nil
+	case Array:
+		n, err := parseLength(line[1:], maxArrayLength)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n < 0 {
+			v.Null = true
+			return v, next - pos, nil
+		}
+		v.Array = make([]*Value, 0, n)
+		for i := 0; i < n; i++ {
+			item, size, err := decode(data, next)
+			if item == nil || err != nil {
+				return nil, 0, err
+			}
+			v.Array = append(v.Array, item)
+			next += size
+		}
+		return v, next - pos, nil
+	}
+	return nil, 0, ErrProtocol
+}
+
+// readLine returns the line without CRLF starts at pos, and the position of the next line
+func readLine(data []byte, pos int) ([]byte, int, error) {
+	idx := bytes.Index(data[pos:], crlf)
+	if idx < 0 {
+		if len(data)-pos > maxLineLength {
+			return nil, 0, ErrProtocol
+		}
+		return nil, 0, nil
+	}
+	if idx == 0 {
+		return nil, 0, ErrProtocol
+	}
+	return data[pos : pos+idx], pos + idx + 2, nil
+}
+
+func parseLength(b []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(b))
+	if err != nil || n < -1 || n > max {
+		return 0, ErrProtocol
+	}
+	return n, nil
+}
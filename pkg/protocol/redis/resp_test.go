@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, v := range []*Value{
+		NewSimpleString("OK"),
+		NewError("ERR unknown command"),
+		NewInteger(-42),
+		NewBulkString("hello\r\nworld"),
+		NewBulkString(""),
+		NewNullBulkString(),
+		NewArray(),
+		{Type: Array, Null: true},
+		NewArray(NewCommand("SET", "k", "v"), NewInteger(1), NewNullBulkString()),
+	} {
+		data := v.Encode(nil)
+		decoded, n, err := Decode(data)
+		if err != nil {
+			t.Fatalf("decode %s failed: %v", v, err)
+		}
+		if n != len(data) {
+			t.Errorf("decode %s consumed %d bytes, expected %d", v, n, len(data))
+		}
+		if !reflect.DeepEqual(decoded, v) {
+			t.Errorf("decoded %s, expected %s", decoded, v)
+		}
+	}
+}
+
+func TestDecodePartial(t *testing.T) {
+	data := NewCommand("MGET", "a", "b").Encode(nil)
+	// the data is not enough before the last byte
+	for i := 0; i < len(data); i++ {
+		v, n, err := Decode(data[:i])
+		if v != nil || n != 0 || err != nil {
+			t.Fatalf("decode %q should wait for more data, but got %v, %d, %v", data[:i], v, n, err)
+		}
+	}
+
+	// pipelined commands are decoded one by one
+	pipeline := append(NewCommand("PING").Encode(nil), NewCommand("GET", "k").Encode(nil)...)
+	v, n, err := Decode(pipeline)
+	if err != nil || v.Array[0].Str != "PING" {
+		t.Fatalf("decode the first command failed: %v, %v", v, err)
+	}
+	v, _, err = Decode(pipeline[n:])
+	if err != nil || v.Array[1].Str != "k" {
+		t.Fatalf("decode the second command failed: %v, %v", v, err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	for _, data := range []string{
+		"?unknown\r\n",
+		"\r\n",
+		":abc\r\n",
+		"$-2\r\n",
+		"$3\r\nabcd\r\n",
+		"*x\r\n",
+		"*1\r\n!\r\n",
+	} {
+		if _, _, err := Decode([]byte(data)); err != ErrProtocol {
+			t.Errorf("decode %q expected protocol error, but got %v", data, err)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args, ok := NewCommand("GET", "key").Args()
+	if !ok || !reflect.DeepEqual(args, []string{"GET", "key"}) {
+		t.Errorf("unexpected args: %v, %v", args, ok)
+	}
+	for _, v := range []*Value{
+		NewBulkString("GET"),
+		NewArray(),
+		NewArray(NewBulkString("GET"), NewInteger(1)),
+	} {
+		if _, ok := v.Args(); ok {
+			t.Errorf("%s should not be a command", v)
+		}
+	}
+}
+
+func TestSlot(t *testing.T) {
+	if crc := crc16("123456789"); crc != 0x31c3 {
+		t.Errorf("unexpected crc16: %x", crc)
+	}
+	if slot := Slot("foo"); slot != 12182 {
+		t.Errorf("unexpected slot of foo: %d", slot)
+	}
+	if Slot("{user1000}.following") != Slot("user1000") {
+		t.Error("the keys with the same hash tag should be in the same slot")
+	}
+	// the empty hash tag is ignored
+	if Slot("foo{}bar") != int(crc16("foo{}bar"))%SlotCount {
+		t.Error("the key with empty hash tag should be hashed entirely")
+	}
+}
@@ -46,6 +46,9 @@ const (
 	MosnOriginalHeaderPathKey = "x-mosn-original-path"
 )
 
+// MethodConnect is the value of MosnHeaderMethod for a HTTP CONNECT request
+const MethodConnect = "CONNECT"
+
 // Hseader with special meaning in istio
 // todo maybe use ":authority"
 const (
@@ -128,7 +128,7 @@ func (proto *boltProtocol) Reply(request xprotocol.XFrame) xprotocol.XRespFrame
 			CmdType:        CmdTypeResponse,
 			CmdCode:        CmdCodeHeartbeat,
 			Version:        ProtocolVersion,
-			RequestId:      uint32(request.GetRequestId()),
+			RequestId:      uint32(request.(xprotocol.Multiplexing).GetRequestId()),
 			Codec:          Hessian2Serialize,
 			ResponseStatus: ResponseStatusSuccess,
 		},
@@ -134,7 +134,7 @@ func (proto *boltv2Protocol) Reply(request xprotocol.XFrame) xprotocol.XRespFram
 				CmdType:        bolt.CmdTypeResponse,
 				CmdCode:        bolt.CmdCodeHeartbeat,
 				Version:        ProtocolVersion,
-				RequestId:      uint32(request.GetRequestId()),
+				RequestId:      uint32(request.(xprotocol.Multiplexing).GetRequestId()),
 				Codec:          bolt.Hessian2Serialize,
 				ResponseStatus: bolt.ResponseStatusSuccess,
 			},
@@ -0,0 +1,309 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package declarative
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+// codec is the frame layout built from the config
+type codec struct {
+	name             types.ProtocolName
+	order            binary.ByteOrder
+	headerSize       int
+	maxFrameSize     int
+	magicOffset      int
+	magic            []byte
+	length           *field
+	lengthAdjustment int
+	// requestID is nil if the protocol is ping-pong
+	requestID *field
+	response  *marker
+	heartbeat *marker
+	oneway    *marker
+	status    *status
+	headers   *headerBlock
+
+	serviceKey string
+	methodKey  string
+}
+
+// field is an unsigned integer field of the fixed header
+type field struct {
+	offset int
+	width  int
+	order  binary.ByteOrder
+}
+
+func (f *field) get(b []byte) uint64 {
+	b = b[f.offset : f.offset+f.width]
+	switch f.width {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(f.order.Uint16(b))
+	case 4:
+		return uint64(f.order.Uint32(b))
+	default:
+		return f.order.Uint64(b)
+	}
+}
+
+func (f *field) put(b []byte, v uint64) {
+	b = b[f.offset : f.offset+f.width]
+	switch f.width {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		f.order.PutUint16(b, uint16(v))
+	case 4:
+		f.order.PutUint32(b, uint32(v))
+	default:
+		f.order.PutUint64(b, v)
+	}
+}
+
+// max is the max value of the field
+func (f *field) max() uint64 {
+	if f.width == 8 {
+		return ^uint64(0)
+	}
+	return 1<<(uint(f.width)*8) - 1
+}
+
+// marker marks a kind of frames
+type marker struct {
+	*field
+	mask  uint64
+	value uint64
+	unset uint64
+}
+
+func (m *marker) marked(b []byte) bool {
+	return m.get(b)&m.mask == m.value
+}
+
+func (m *marker) mark(b []byte, marked bool) {
+	v := m.unset
+	if marked {
+		v = m.value
+	}
+	m.put(b, m.get(b)&^m.mask|v&m.mask)
+}
+
+// status is the status field of the responses
+type status struct {
+	*field
+	success uint64
+	err     uint64
+	// codes maps the http status codes to the statuses
+	codes map[uint32]uint64
+	// httpCodes maps the statuses to the http status codes, the smallest code is used if several codes are mapped to a status
+	httpCodes map[uint64]int
+}
+
+// headerBlock is the block of the key value pairs following the fixed header
+type headerBlock struct {
+	length   *field
+	text     bool
+	strWidth int
+}
+
+func newCodec(cfg *v2.XProtocolCodecConfig) (*codec, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	order, err := byteOrder(cfg.ByteOrder, binary.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	c := &codec{
+		name:             types.ProtocolName(cfg.Name),
+		order:            order,
+		headerSize:       cfg.HeaderSize,
+		maxFrameSize:     cfg.MaxFrameSize,
+		magicOffset:      cfg.Magic.Offset,
+		lengthAdjustment: cfg.LengthAdjustment,
+		serviceKey:       cfg.ServiceKey,
+		methodKey:        cfg.MethodKey,
+	}
+	if c.headerSize <= 0 {
+		return nil, fmt.Errorf("header_size should be positive")
+	}
+	if c.maxFrameSize <= 0 {
+		c.maxFrameSize = DefaultMaxFrameSize
+	}
+	if c.magic, err = hex.DecodeString(cfg.Magic.Value); err != nil || len(c.magic) == 0 {
+		return nil, fmt.Errorf("magic should be hex bytes: %q", cfg.Magic.Value)
+	}
+	if c.magicOffset < 0 || c.magicOffset+len(c.magic) > c.headerSize {
+		return nil, fmt.Errorf("magic is out of the fixed header")
+	}
+	if c.length, err = c.newField("length", &cfg.Length); err != nil {
+		return nil, err
+	}
+	if cfg.RequestID != nil {
+		if c.requestID, err = c.newField("request_id", cfg.RequestID); err != nil {
+			return nil, err
+		}
+	}
+	if c.response, err = c.newMarker("response", &cfg.Response); err != nil {
+		return nil, err
+	}
+	if cfg.Heartbeat != nil {
+		if c.heartbeat, err = c.newMarker("heartbeat", cfg.Heartbeat); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Oneway != nil {
+		if c.oneway, err = c.newMarker("oneway", cfg.Oneway); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Status != nil {
+		if c.status, err = c.newStatus(cfg.Status); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Headers != nil {
+		if c.headers, err = c.newHeaderBlock(cfg.Headers); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func byteOrder(s string, def binary.ByteOrder) (binary.ByteOrder, error) {
+	switch s {
+	case "":
+		return def, nil
+	case BigEndian:
+		return binary.BigEndian, nil
+	case LittleEndian:
+		return binary.LittleEndian, nil
+	default:
+		return nil, fmt.Errorf("unknown byte order: %s", s)
+	}
+}
+
+func (c *codec) newField(name string, cfg *v2.XProtocolFieldConfig) (*field, error) {
+	order, err := byteOrder(cfg.ByteOrder, c.order)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Width {
+	case 1, 2, 4, 8:
+	default:
+		return nil, fmt.Errorf("the width of %s should be 1, 2, 4 or 8", name)
+	}
+	if cfg.Offset < 0 || cfg.Offset+cfg.Width > c.headerSize {
+		return nil, fmt.Errorf("%s is out of the fixed header", name)
+	}
+	return &field{
+		offset: cfg.Offset,
+		width:  cfg.Width,
+		order:  order,
+	}, nil
+}
+
+func (c *codec) newMarker(name string, cfg *v2.XProtocolMarkerConfig) (*marker, error) {
+	f, err := c.newField(name, &cfg.XProtocolFieldConfig)
+	if err != nil {
+		return nil, err
+	}
+	m := &marker{
+		field: f,
+		mask:  cfg.Mask,
+		value: cfg.Value,
+		unset: cfg.UnsetValue,
+	}
+	if m.mask == 0 {
+		m.mask = f.max()
+	}
+	if m.value&^m.mask != 0 || m.unset&^m.mask != 0 || m.value == m.unset {
+		return nil, fmt.Errorf("the value and the unset value of %s should be different bits of the mask", name)
+	}
+	return m, nil
+}
+
+func (c *codec) newStatus(cfg *v2.XProtocolStatusConfig) (*status, error) {
+	f, err := c.newField("status", &cfg.XProtocolFieldConfig)
+	if err != nil {
+		return nil, err
+	}
+	s := &status{
+		field:     f,
+		success:   cfg.Success,
+		err:       cfg.Error,
+		codes:     make(map[uint32]uint64, len(cfg.Codes)),
+		httpCodes: make(map[uint64]int, len(cfg.Codes)),
+	}
+	codes := make([]int, 0, len(cfg.Codes))
+	for k, v := range cfg.Codes {
+		code, err := strconv.Atoi(k)
+		if err != nil || code <= 0 {
+			return nil, fmt.Errorf("invalid http status code of status: %s", k)
+		}
+		s.codes[uint32(code)] = v
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		if v := s.codes[uint32(code)]; s.httpCodes[v] == 0 {
+			s.httpCodes[v] = code
+		}
+	}
+	s.httpCodes[s.success] = http.StatusOK
+	if _, ok := s.codes[http.StatusOK]; !ok {
+		s.codes[http.StatusOK] = s.success
+	}
+	return s, nil
+}
+
+func (c *codec) newHeaderBlock(cfg *v2.XProtocolHeadersConfig) (*headerBlock, error) {
+	f, err := c.newField("the header block length", &cfg.Length)
+	if err != nil {
+		return nil, err
+	}
+	h := &headerBlock{
+		length:   f,
+		strWidth: cfg.StringLengthWidth,
+	}
+	switch cfg.Encoding {
+	case "", HeaderEncodingLengthPrefixed:
+	case HeaderEncodingText:
+		h.text = true
+	default:
+		return nil, fmt.Errorf("unknown header encoding: %s", cfg.Encoding)
+	}
+	switch h.strWidth {
+	case 0:
+		h.strWidth = DefaultStringLengthWidth
+	case 1, 2, 4:
+	default:
+		return nil, fmt.Errorf("string_length_width should be 1, 2 or 4")
+	}
+	return h, nil
+}
@@ -0,0 +1,104 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package declarative
+
+import (
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// Frame is a frame of the protocols without request id, the key value pairs of the header block are the headers
+type Frame struct {
+	protocol.CommonHeader
+
+	codec *codec
+	// fixed is the fixed header, the fields are read from and written to it
+	fixed []byte
+	data  types.IoBuffer
+}
+
+// MultiplexedFrame is a frame of the protocols with request id
+type MultiplexedFrame struct {
+	Frame
+}
+
+// ~ XFrame
+func (f *Frame) IsHeartbeatFrame() bool {
+	return f.codec.heartbeat != nil && f.codec.heartbeat.marked(f.fixed)
+}
+
+func (f *Frame) GetStreamType() xprotocol.StreamType {
+	switch {
+	case f.codec.response.marked(f.fixed):
+		return xprotocol.Response
+	case f.codec.oneway != nil && f.codec.oneway.marked(f.fixed):
+		return xprotocol.RequestOneWay
+	default:
+		return xprotocol.Request
+	}
+}
+
+func (f *Frame) GetHeader() types.HeaderMap {
+	return f
+}
+
+func (f *Frame) GetData() types.IoBuffer {
+	return f.data
+}
+
+func (f *Frame) SetData(data types.IoBuffer) {
+	f.data = data
+}
+
+// ~ XRespFrame
+func (f *Frame) GetStatusCode() uint32 {
+	if f.codec.status == nil {
+		return 0
+	}
+	return uint32(f.codec.status.get(f.fixed))
+}
+
+// ~ ServiceAware
+func (f *Frame) GetServiceName() string {
+	if f.codec.serviceKey == "" {
+		return ""
+	}
+	service, _ := f.Get(f.codec.serviceKey)
+	return service
+}
+
+func (f *Frame) GetMethodName() string {
+	if f.codec.methodKey == "" {
+		return ""
+	}
+	method, _ := f.Get(f.codec.methodKey)
+	return method
+}
+
+func (f *MultiplexedFrame) GetHeader() types.HeaderMap {
+	return f
+}
+
+// ~ Multiplexing
+func (f *MultiplexedFrame) GetRequestId() uint64 {
+	return f.codec.requestID.get(f.fixed)
+}
+
+func (f *MultiplexedFrame) SetRequestId(id uint64) {
+	f.codec.requestID.put(f.fixed, id)
+}
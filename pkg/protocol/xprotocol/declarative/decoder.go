@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package declarative
+
+import (
+	"bytes"
+	"context"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func (c *codec) decode(ctx context.Context, data types.IoBuffer) (interface{}, error) {
+	b := data.Bytes()
+	if len(b) < c.headerSize {
+		return nil, nil
+	}
+	if !bytes.Equal(b[c.magicOffset:c.magicOffset+len(c.magic)], c.magic) {
+		return nil, ErrMagicMismatch
+	}
+	length := c.length.get(b)
+	if length > uint64(c.maxFrameSize) {
+		return nil, ErrInvalidLength
+	}
+	size := c.length.offset + c.length.width + int(length) + c.lengthAdjustment
+	if size < c.headerSize || size > c.maxFrameSize {
+		return nil, ErrInvalidLength
+	}
+	if len(b) < size {
+		return nil, nil
+	}
+	raw := make([]byte, size)
+	copy(raw, b)
+	data.Drain(size)
+
+	frame := c.newFrame(raw[:c.headerSize])
+	payload := raw[c.headerSize:]
+	if c.headers != nil {
+		n := c.headers.length.get(raw)
+		if n > uint64(len(payload)) {
+			return nil, ErrInvalidHeaders
+		}
+		if err := c.headers.decode(payload[:n], frame.GetHeader()); err != nil {
+			return nil, err
+		}
+		payload = payload[n:]
+	}
+	if len(payload) > 0 {
+		frame.SetData(buffer.NewIoBufferBytes(payload))
+	}
+	return frame, nil
+}
+
+// newFrame makes a frame with the fixed header, the frame is a MultiplexedFrame if the protocol has request id
+func (c *codec) newFrame(fixed []byte) xprotocol.XFrame {
+	f := Frame{
+		CommonHeader: protocol.CommonHeader{},
+		codec:        c,
+		fixed:        fixed,
+	}
+	if c.requestID != nil {
+		return &MultiplexedFrame{Frame: f}
+	}
+	return &f
+}
+
+func (h *headerBlock) decode(b []byte, headers types.HeaderMap) error {
+	if h.text {
+		for len(b) > 0 {
+			line := b
+			if i := bytes.IndexByte(b, '\n'); i >= 0 {
+				line, b = b[:i], b[i+1:]
+			} else {
+				b = nil
+			}
+			line = bytes.TrimRight(line, "\r")
+			if len(line) == 0 {
+				continue
+			}
+			i := bytes.IndexByte(line, ':')
+			if i <= 0 {
+				return ErrInvalidHeaders
+			}
+			headers.Set(string(bytes.TrimSpace(line[:i])), string(bytes.TrimSpace(line[i+1:])))
+		}
+		return nil
+	}
+	for len(b) > 0 {
+		key, rest, err := h.readString(b)
+		if err != nil {
+			return err
+		}
+		value, rest, err := h.readString(rest)
+		if err != nil {
+			return err
+		}
+		headers.Set(key, value)
+		b = rest
+	}
+	return nil
+}
+
+// readString reads a length-prefixed string, the byte order of the length is the same as the header block length
+func (h *headerBlock) readString(b []byte) (string, []byte, error) {
+	if len(b) < h.strWidth {
+		return "", nil, ErrInvalidHeaders
+	}
+	var n int
+	switch h.strWidth {
+	case 1:
+		n = int(b[0])
+	case 2:
+		n = int(h.length.order.Uint16(b))
+	default:
+		n = int(h.length.order.Uint32(b))
+	}
+	b = b[h.strWidth:]
+	if n < 0 || n > len(b) {
+		return "", nil, ErrInvalidHeaders
+	}
+	return string(b[:n]), b[n:], nil
+}
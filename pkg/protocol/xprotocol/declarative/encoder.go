@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package declarative
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func (c *codec) encode(ctx context.Context, frame *Frame) (types.IoBuffer, error) {
+	var headers []byte
+	if c.headers != nil {
+		var err error
+		if headers, err = c.headers.encode(frame.CommonHeader); err != nil {
+			return nil, err
+		}
+		if uint64(len(headers)) > c.headers.length.max() {
+			return nil, ErrInvalidHeaders
+		}
+		c.headers.length.put(frame.fixed, uint64(len(headers)))
+	}
+	size := c.headerSize + len(headers)
+	if frame.data != nil {
+		size += frame.data.Len()
+	}
+	length := size - c.length.offset - c.length.width - c.lengthAdjustment
+	if size > c.maxFrameSize || length < 0 || uint64(length) > c.length.max() {
+		return nil, ErrInvalidLength
+	}
+	c.length.put(frame.fixed, uint64(length))
+
+	buf := buffer.GetIoBuffer(size)
+	buf.Write(frame.fixed)
+	buf.Write(headers)
+	if frame.data != nil {
+		buf.Write(frame.data.Bytes())
+	}
+	return buf, nil
+}
+
+// encode encodes the headers sorted by the keys
+func (h *headerBlock) encode(headers protocol.CommonHeader) ([]byte, error) {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b []byte
+	for _, k := range keys {
+		v := headers[k]
+		if h.text {
+			if k == "" || strings.ContainsAny(k, ":\r\n") || strings.ContainsAny(v, "\r\n") {
+				return nil, ErrInvalidHeaders
+			}
+			b = append(b, k...)
+			b = append(b, ": "...)
+			b = append(b, v...)
+			b = append(b, '\n')
+			continue
+		}
+		var err error
+		if b, err = h.appendString(b, k); err != nil {
+			return nil, err
+		}
+		if b, err = h.appendString(b, v); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (h *headerBlock) appendString(b []byte, s string) ([]byte, error) {
+	n := len(s)
+	switch h.strWidth {
+	case 1:
+		if n > 0xff {
+			return nil, ErrInvalidHeaders
+		}
+		b = append(b, byte(n))
+	case 2:
+		if n > 0xffff {
+			return nil, ErrInvalidHeaders
+		}
+		b = append(b, 0, 0)
+		h.length.order.PutUint16(b[len(b)-2:], uint16(n))
+	default:
+		b = append(b, 0, 0, 0, 0)
+		h.length.order.PutUint32(b[len(b)-4:], uint32(n))
+	}
+	return append(b, s...), nil
+}
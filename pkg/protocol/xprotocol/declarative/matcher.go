@@ -0,0 +1,43 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package declarative
+
+import (
+	"bytes"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// match compares the magic of the fixed header
+func (proto *declarativeProtocol) match(data []byte) types.MatchResult {
+	c := proto.current()
+	end := c.magicOffset + len(c.magic)
+	n := len(data)
+	if n > end {
+		n = end
+	}
+	if n <= c.magicOffset {
+		return types.MatchAgain
+	}
+	if !bytes.Equal(data[c.magicOffset:n], c.magic[:n-c.magicOffset]) {
+		return types.MatchFailed
+	}
+	if n < end {
+		return types.MatchAgain
+	}
+	return types.MatchSuccess
+}
@@ -0,0 +1,213 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package declarative
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+var (
+	mutex     sync.Mutex
+	protocols = make(map[types.ProtocolName]*declarativeProtocol)
+)
+
+// Register registers the protocol described by the config as a xprotocol sub-protocol with its matcher and
+// status mapping. The codec registered with the same name by Register before is replaced, so the config can be
+// reloaded, the frames being proxied keep the codec they are decoded by.
+func Register(cfg v2.XProtocolCodecConfig) error {
+	c, err := newCodec(&cfg)
+	if err != nil {
+		return err
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if proto, ok := protocols[c.name]; ok {
+		proto.codec.Store(c)
+		return nil
+	}
+	proto := &declarativeProtocol{name: c.name}
+	proto.codec.Store(c)
+	if err := xprotocol.RegisterProtocol(c.name, proto); err != nil {
+		return err
+	}
+	// the name is not registered by the others, the matcher and the mapping are not registered as well
+	xprotocol.RegisterMatcher(c.name, proto.match)
+	xprotocol.RegisterMapping(c.name, proto)
+	protocols[c.name] = proto
+	log.StartLogger.Infof("[protocol] [declarative] register protocol %s", c.name)
+	return nil
+}
+
+type declarativeProtocol struct {
+	name  types.ProtocolName
+	codec atomic.Value // *codec
+}
+
+func (proto *declarativeProtocol) current() *codec {
+	return proto.codec.Load().(*codec)
+}
+
+func (proto *declarativeProtocol) Name() types.ProtocolName {
+	return proto.name
+}
+
+func (proto *declarativeProtocol) Encode(ctx context.Context, model interface{}) (types.IoBuffer, error) {
+	switch frame := model.(type) {
+	case *Frame:
+		return frame.codec.encode(ctx, frame)
+	case *MultiplexedFrame:
+		return frame.codec.encode(ctx, &frame.Frame)
+	}
+	log.Proxy.Errorf(ctx, "[protocol][%s] encode with unknown command : %+v", proto.name, model)
+	return nil, xprotocol.ErrUnknownType
+}
+
+func (proto *declarativeProtocol) Decode(ctx context.Context, data types.IoBuffer) (interface{}, error) {
+	return proto.current().decode(ctx, data)
+}
+
+// PoolMode is PingPong if the protocol has no request id
+func (proto *declarativeProtocol) PoolMode() xprotocol.PoolMode {
+	if proto.current().requestID == nil {
+		return xprotocol.PingPong
+	}
+	return xprotocol.Multiplex
+}
+
+// heartbeater
+func (proto *declarativeProtocol) Trigger(requestId uint64) xprotocol.XFrame {
+	c := proto.current()
+	if c.heartbeat == nil {
+		// not support
+		return nil
+	}
+	frame := c.newFrame(c.newFixedHeader())
+	c.heartbeat.mark(frameOf(frame).fixed, true)
+	if mux, ok := frame.(xprotocol.Multiplexing); ok {
+		mux.SetRequestId(requestId)
+	}
+	return frame
+}
+
+// Reply replies the heartbeat with the fixed header of the request, so the fields not described are kept
+func (proto *declarativeProtocol) Reply(request xprotocol.XFrame) xprotocol.XRespFrame {
+	req := frameOf(request)
+	if req == nil || req.codec.heartbeat == nil {
+		// not support
+		return nil
+	}
+	c := req.codec
+	resp := c.newFrame(append([]byte{}, req.fixed...)).(xprotocol.XRespFrame)
+	fixed := frameOf(resp).fixed
+	c.response.mark(fixed, true)
+	c.heartbeat.mark(fixed, true)
+	if c.oneway != nil {
+		c.oneway.mark(fixed, false)
+	}
+	if c.status != nil {
+		c.status.put(fixed, c.status.success)
+	}
+	return resp
+}
+
+// hijacker
+func (proto *declarativeProtocol) Hijack(statusCode uint32) xprotocol.XRespFrame {
+	c := proto.current()
+	return c.hijack(c.newFixedHeader(), statusCode)
+}
+
+// HijackRequest builds the response with the fixed header of the request, so the fields not described are kept
+func (proto *declarativeProtocol) HijackRequest(request xprotocol.XFrame, statusCode uint32) xprotocol.XRespFrame {
+	req := frameOf(request)
+	if req == nil {
+		return proto.Hijack(statusCode)
+	}
+	return req.codec.hijack(append([]byte{}, req.fixed...), statusCode)
+}
+
+func (proto *declarativeProtocol) Mapping(httpStatusCode uint32) uint32 {
+	s := proto.current().status
+	if s == nil {
+		return 0
+	}
+	if status, ok := s.codes[httpStatusCode]; ok {
+		return uint32(status)
+	}
+	return uint32(s.err)
+}
+
+func (proto *declarativeProtocol) MappingHeaderStatusCode(ctx context.Context, headers types.HeaderMap) (int, error) {
+	frame := frameOf(headers)
+	if frame == nil {
+		return 0, xprotocol.ErrUnknownType
+	}
+	s := frame.codec.status
+	if s == nil {
+		return http.StatusOK, nil
+	}
+	if code, ok := s.httpCodes[s.get(frame.fixed)]; ok {
+		return code, nil
+	}
+	return http.StatusInternalServerError, nil
+}
+
+// newFixedHeader makes the fixed header of the frames built by mosn, the fields not described are zero
+func (c *codec) newFixedHeader() []byte {
+	fixed := make([]byte, c.headerSize)
+	copy(fixed[c.magicOffset:], c.magic)
+	c.response.mark(fixed, false)
+	if c.heartbeat != nil {
+		c.heartbeat.mark(fixed, false)
+	}
+	if c.oneway != nil {
+		c.oneway.mark(fixed, false)
+	}
+	return fixed
+}
+
+func (c *codec) hijack(fixed []byte, statusCode uint32) xprotocol.XRespFrame {
+	c.response.mark(fixed, true)
+	if c.heartbeat != nil {
+		c.heartbeat.mark(fixed, false)
+	}
+	if c.oneway != nil {
+		c.oneway.mark(fixed, false)
+	}
+	if c.status != nil {
+		c.status.put(fixed, uint64(statusCode))
+	}
+	return c.newFrame(fixed).(xprotocol.XRespFrame)
+}
+
+// frameOf returns the frame of the models, nil is returned if it is not a frame of the protocols
+func frameOf(model interface{}) *Frame {
+	switch frame := model.(type) {
+	case *Frame:
+		return frame
+	case *MultiplexedFrame:
+		return &frame.Frame
+	}
+	return nil
+}
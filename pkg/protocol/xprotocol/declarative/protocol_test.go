@@ -0,0 +1,350 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package declarative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// the fixed header of 14 bytes:
+// magic(2) | flags(1) | status(1) | request id(4) | length(4) | header block length(2)
+// the length is the size after the fixed header
+const multiplexedConfig = `{
+	"name": "x_declarative",
+	"header_size": 14,
+	"magic": {"offset": 0, "value": "cafe"},
+	"length": {"offset": 8, "width": 4},
+	"length_adjustment": 2,
+	"request_id": {"offset": 4, "width": 4},
+	"response": {"offset": 2, "width": 1, "mask": 128, "value": 128},
+	"heartbeat": {"offset": 2, "width": 1, "mask": 64, "value": 64},
+	"oneway": {"offset": 2, "width": 1, "mask": 32, "value": 32},
+	"status": {"offset": 3, "width": 1, "success": 0, "error": 1, "codes": {"404": 4, "502": 5, "503": 5}},
+	"headers": {"length": {"offset": 12, "width": 2}},
+	"service_key": "service",
+	"method_key": "method"
+}`
+
+// the fixed header of 8 bytes in little endian:
+// length(4) | magic(1) | type(1) | header block length(2)
+// the length is the size of the frame, the type is 0 for requests and 1 for responses
+const pingPongConfig = `{
+	"name": "x_declarative_pingpong",
+	"byte_order": "little",
+	"header_size": 8,
+	"magic": {"offset": 4, "value": "7f"},
+	"length": {"offset": 0, "width": 4},
+	"length_adjustment": -4,
+	"response": {"offset": 5, "width": 1, "value": 1},
+	"headers": {"length": {"offset": 6, "width": 2}, "encoding": "text"}
+}`
+
+func newTestCodec(t *testing.T, config string) *codec {
+	cfg := v2.XProtocolCodecConfig{}
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCodec(&cfg)
+	if err != nil {
+		t.Fatalf("new codec failed: %v", err)
+	}
+	return c
+}
+
+func multiplexedRequest(id uint32, flags byte, headers, body string) []byte {
+	data := []byte{0xca, 0xfe, flags, 0, byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	n := len(headers) + len(body)
+	data = append(data, byte(n>>24), byte(n>>16), byte(n>>8), byte(n), byte(len(headers)>>8), byte(len(headers)))
+	return append(append(data, headers...), body...)
+}
+
+func TestNewCodecErrors(t *testing.T) {
+	for _, config := range []string{
+		`{"header_size": 4, "magic": {"value": "ca"}, "length": {"offset": 0, "width": 4}, "response": {"offset": 0, "width": 1, "value": 1}}`,
+		`{"name": "x", "magic": {"value": "ca"}, "length": {"offset": 0, "width": 4}, "response": {"offset": 0, "width": 1, "value": 1}}`,
+		`{"name": "x", "header_size": 4, "magic": {"value": "xx"}, "length": {"offset": 0, "width": 4}, "response": {"offset": 0, "width": 1, "value": 1}}`,
+		`{"name": "x", "header_size": 4, "magic": {"offset": 3, "value": "cafe"}, "length": {"offset": 0, "width": 4}, "response": {"offset": 0, "width": 1, "value": 1}}`,
+		`{"name": "x", "header_size": 4, "magic": {"value": "ca"}, "length": {"offset": 0, "width": 3}, "response": {"offset": 0, "width": 1, "value": 1}}`,
+		`{"name": "x", "header_size": 4, "magic": {"value": "ca"}, "length": {"offset": 2, "width": 4}, "response": {"offset": 0, "width": 1, "value": 1}}`,
+		`{"name": "x", "header_size": 4, "byte_order": "middle", "magic": {"value": "ca"}, "length": {"offset": 0, "width": 4}, "response": {"offset": 0, "width": 1, "value": 1}}`,
+		`{"name": "x", "header_size": 4, "magic": {"value": "ca"}, "length": {"offset": 0, "width": 4}, "response": {"offset": 0, "width": 1, "mask": 1, "value": 2}}`,
+		`{"name": "x", "header_size": 4, "magic": {"value": "ca"}, "length": {"offset": 0, "width": 4}}`,
+		`{"name": "x", "header_size": 4, "magic": {"value": "ca"}, "length": {"offset": 0, "width": 4}, "response": {"offset": 0, "width": 1, "value": 1},
+			"status": {"offset": 1, "width": 1, "codes": {"not found": 1}}}`,
+		`{"name": "x", "header_size": 4, "magic": {"value": "ca"}, "length": {"offset": 0, "width": 4}, "response": {"offset": 0, "width": 1, "value": 1},
+			"headers": {"length": {"offset": 2, "width": 2}, "encoding": "json"}}`,
+	} {
+		cfg := v2.XProtocolCodecConfig{}
+		if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newCodec(&cfg); err == nil {
+			t.Errorf("expected error of config %s", config)
+		}
+	}
+}
+
+func TestMultiplexedCodec(t *testing.T) {
+	c := newTestCodec(t, multiplexedConfig)
+	headers := []byte{0, 7, 's', 'e', 'r', 'v', 'i', 'c', 'e', 0, 3, 'f', 'o', 'o', 0, 6, 'm', 'e', 't', 'h', 'o', 'd', 0, 3, 'b', 'a', 'r'}
+	data := multiplexedRequest(3, 0, string(headers), "hello")
+	buf := buffer.NewIoBuffer(0)
+	for i := 0; i < len(data); i++ {
+		buf.Write(data[i : i+1])
+		frame, err := c.decode(context.Background(), buf)
+		if err != nil || frame != nil {
+			t.Fatalf("decode partial data should wait for more, got %v, %v", frame, err)
+		}
+		if i == len(data)-2 {
+			break
+		}
+	}
+	buf.Write(data[len(data)-1:])
+	buf.Write([]byte{0xca})
+	model, err := c.decode(context.Background(), buf)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if buf.Len() != 1 {
+		t.Errorf("the next frame should be left, %d", buf.Len())
+	}
+	frame, ok := model.(*MultiplexedFrame)
+	if !ok {
+		t.Fatalf("expected multiplexed frame, but got %T", model)
+	}
+	if frame.GetRequestId() != 3 || frame.GetStreamType() != xprotocol.Request || frame.IsHeartbeatFrame() {
+		t.Errorf("unexpected frame: %+v", frame)
+	}
+	if frame.GetServiceName() != "foo" || frame.GetMethodName() != "bar" || frame.GetData().String() != "hello" {
+		t.Errorf("unexpected frame: %+v", frame)
+	}
+	if frame.GetHeader() != frame {
+		t.Error("the header should be the frame")
+	}
+
+	// the changed headers and request id are encoded
+	frame.SetRequestId(9)
+	frame.Del("method")
+	frame.Set("a", "b")
+	frame.SetData(buffer.NewIoBufferString("world!"))
+	proto := &declarativeProtocol{name: c.name}
+	proto.codec.Store(c)
+	encoded, err := proto.Encode(context.Background(), frame)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	headers = []byte{0, 1, 'a', 0, 1, 'b', 0, 7, 's', 'e', 'r', 'v', 'i', 'c', 'e', 0, 3, 'f', 'o', 'o'}
+	if expected := multiplexedRequest(9, 0, string(headers), "world!"); !bytes.Equal(encoded.Bytes(), expected) {
+		t.Errorf("unexpected encoded frame: %v, expected %v", encoded.Bytes(), expected)
+	}
+
+	// oneway
+	model, _ = c.decode(context.Background(), buffer.NewIoBufferBytes(multiplexedRequest(1, 0x20, "", "")))
+	if f := model.(*MultiplexedFrame); f.GetStreamType() != xprotocol.RequestOneWay || f.GetData() != nil {
+		t.Errorf("unexpected oneway frame: %+v", f)
+	}
+
+	for _, data := range [][]byte{
+		append([]byte{0xca, 0xfd}, data[2:]...),
+		// the header block is longer than the frame
+		append(append([]byte{}, data[:8]...), 0, 0, 0, 0, 0xff, 0xff),
+		// the frame is larger than the max frame size
+		append(append(append([]byte{}, data[:8]...), 0xff, 0xff, 0xff, 0xfe), data[12:]...),
+	} {
+		if _, err := c.decode(context.Background(), buffer.NewIoBufferBytes(data)); err == nil {
+			t.Errorf("expected error of %v", data)
+		}
+	}
+}
+
+func TestPingPongCodec(t *testing.T) {
+	c := newTestCodec(t, pingPongConfig)
+	headers := "service: foo\r\nversion:1.0\n"
+	n := 8 + len(headers) + 2
+	data := []byte{byte(n), 0, 0, 0, 0x7f, 0, byte(len(headers)), 0}
+	data = append(append(data, headers...), "hi"...)
+	model, err := c.decode(context.Background(), buffer.NewIoBufferBytes(data))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	frame, ok := model.(*Frame)
+	if !ok {
+		t.Fatalf("expected frame, but got %T", model)
+	}
+	if _, ok := model.(xprotocol.Multiplexing); ok {
+		t.Error("the frame should not be multiplexed")
+	}
+	if v, _ := frame.Get("service"); v != "foo" {
+		t.Errorf("unexpected headers: %v", frame.CommonHeader)
+	}
+	if v, _ := frame.Get("version"); v != "1.0" {
+		t.Errorf("unexpected headers: %v", frame.CommonHeader)
+	}
+	if frame.GetServiceName() != "" || frame.GetStreamType() != xprotocol.Request {
+		t.Errorf("unexpected frame: %+v", frame)
+	}
+	encoded, err := c.encode(context.Background(), frame)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	headers = "service: foo\nversion: 1.0\n"
+	n = 8 + len(headers) + 2
+	expected := []byte{byte(n), 0, 0, 0, 0x7f, 0, byte(len(headers)), 0}
+	expected = append(append(expected, headers...), "hi"...)
+	if !bytes.Equal(encoded.Bytes(), expected) {
+		t.Errorf("unexpected encoded frame: %q", encoded.Bytes())
+	}
+	// the frame is shorter than the fixed header
+	if _, err := c.decode(context.Background(), buffer.NewIoBufferBytes([]byte{4, 0, 0, 0, 0x7f, 0, 0, 0})); err != ErrInvalidLength {
+		t.Errorf("expected invalid length, but got %v", err)
+	}
+	frame.Set("bad", "a\nb")
+	if _, err := c.encode(context.Background(), frame); err != ErrInvalidHeaders {
+		t.Errorf("expected invalid headers, but got %v", err)
+	}
+}
+
+func TestHeartbeatAndHijack(t *testing.T) {
+	c := newTestCodec(t, multiplexedConfig)
+	proto := &declarativeProtocol{name: c.name}
+	proto.codec.Store(c)
+
+	hb := proto.Trigger(5)
+	if !hb.IsHeartbeatFrame() || hb.GetStreamType() != xprotocol.Request || hb.(xprotocol.Multiplexing).GetRequestId() != 5 {
+		t.Fatalf("unexpected heartbeat: %+v", hb)
+	}
+	ack := proto.Reply(hb)
+	if !ack.IsHeartbeatFrame() || ack.GetStreamType() != xprotocol.Response || ack.(xprotocol.Multiplexing).GetRequestId() != 5 {
+		t.Fatalf("unexpected heartbeat ack: %+v", ack)
+	}
+	encoded, err := proto.Encode(context.Background(), ack)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if expected := multiplexedRequest(5, 0xc0, "", ""); !bytes.Equal(encoded.Bytes(), expected) {
+		t.Errorf("unexpected heartbeat ack: %v", encoded.Bytes())
+	}
+
+	if proto.Mapping(http.StatusOK) != 0 || proto.Mapping(http.StatusNotFound) != 4 || proto.Mapping(http.StatusGatewayTimeout) != 1 {
+		t.Error("unexpected status mapping")
+	}
+	for code, expected := range map[uint32]int{
+		0: http.StatusOK,
+		4: http.StatusNotFound,
+		// the smallest http status code is used
+		5: http.StatusBadGateway,
+		1: http.StatusInternalServerError,
+	} {
+		resp := proto.Hijack(code)
+		if resp.GetStreamType() != xprotocol.Response || resp.IsHeartbeatFrame() || resp.GetStatusCode() != code {
+			t.Errorf("unexpected hijack response: %+v", resp)
+		}
+		if mapped, err := proto.MappingHeaderStatusCode(context.Background(), resp.GetHeader()); err != nil || mapped != expected {
+			t.Errorf("status %d: expected %d, but got %d, %v", code, expected, mapped, err)
+		}
+	}
+
+	// the fields of the request are kept
+	model, _ := c.decode(context.Background(), buffer.NewIoBufferBytes(multiplexedRequest(7, 0x01, "", "")))
+	resp := proto.HijackRequest(model.(xprotocol.XFrame), 4)
+	encoded, err = proto.Encode(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	expected := multiplexedRequest(7, 0x81, "", "")
+	expected[3] = 4
+	if !bytes.Equal(encoded.Bytes(), expected) {
+		t.Errorf("unexpected hijack response: %v", encoded.Bytes())
+	}
+
+	pingPong := newTestCodec(t, pingPongConfig)
+	proto.codec.Store(pingPong)
+	if proto.Trigger(1) != nil || proto.PoolMode() != xprotocol.PingPong {
+		t.Error("the heartbeat is not supported by the ping-pong protocol")
+	}
+	if resp := proto.Hijack(http.StatusOK); resp.GetStreamType() != xprotocol.Response || resp.GetStatusCode() != 0 {
+		t.Errorf("unexpected hijack response: %+v", resp)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	c := newTestCodec(t, pingPongConfig)
+	proto := &declarativeProtocol{name: c.name}
+	proto.codec.Store(c)
+	for _, tc := range []struct {
+		data     []byte
+		expected types.MatchResult
+	}{
+		{[]byte{1, 0, 0}, types.MatchAgain},
+		{[]byte{1, 0, 0, 0, 0x7f}, types.MatchSuccess},
+		{[]byte{1, 0, 0, 0, 0x7e, 0}, types.MatchFailed},
+	} {
+		if r := proto.match(tc.data); r != tc.expected {
+			t.Errorf("match %v: expected %v, but got %v", tc.data, tc.expected, r)
+		}
+	}
+	c = newTestCodec(t, multiplexedConfig)
+	proto.codec.Store(c)
+	if proto.match([]byte{0xca}) != types.MatchAgain || proto.match([]byte{0xcb}) != types.MatchFailed {
+		t.Error("unexpected match result of the partial magic")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	cfg := v2.XProtocolCodecConfig{}
+	if err := json.Unmarshal([]byte(multiplexedConfig), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Name = "x_declarative_register"
+	if err := Register(cfg); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	proto := xprotocol.GetProtocol("x_declarative_register")
+	if proto == nil || xprotocol.GetMatcher("x_declarative_register") == nil || xprotocol.GetMapping("x_declarative_register") == nil {
+		t.Fatal("the protocol should be registered")
+	}
+	if xprotocol.GetPoolMode("x_declarative_register") != xprotocol.Multiplex {
+		t.Error("the protocol with request id should be multiplexed")
+	}
+	// registered again with the new config
+	cfg.RequestID = nil
+	if err := Register(cfg); err != nil {
+		t.Fatalf("register again failed: %v", err)
+	}
+	if xprotocol.GetProtocol("x_declarative_register") != proto || xprotocol.GetPoolMode("x_declarative_register") != xprotocol.PingPong {
+		t.Error("the codec should be replaced")
+	}
+	// the name is registered by the others
+	xprotocol.RegisterProtocol("x_declarative_conflict", proto.(xprotocol.XProtocol))
+	cfg.Name = "x_declarative_conflict"
+	if err := Register(cfg); err == nil {
+		t.Error("expected error of the registered name")
+	}
+	cfg.HeaderSize = 0
+	if err := Register(cfg); err == nil {
+		t.Error("expected error of the invalid config")
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package declarative implements the xprotocol sub-protocols described by config, the frames are made up of
+// a fixed header, an optional header block and the body, and the size of the frame is in the fixed header.
+// The codecs are registered at config load, so the new protocols are proxied without a rebuild.
+package declarative
+
+import "errors"
+
+// the byte orders of the fields
+const (
+	BigEndian    = "big"
+	LittleEndian = "little"
+)
+
+// the encodings of the header block
+const (
+	HeaderEncodingLengthPrefixed = "length_prefixed"
+	HeaderEncodingText           = "text"
+)
+
+const (
+	DefaultMaxFrameSize      = 16 * 1024 * 1024 // 16M
+	DefaultStringLengthWidth = 2
+)
+
+var (
+	ErrMagicMismatch  = errors.New("declarative: magic mismatch")
+	ErrInvalidLength  = errors.New("declarative: invalid frame length")
+	ErrInvalidHeaders = errors.New("declarative: invalid header block")
+)
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"encoding/binary"
+	"errors"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"mosn.io/pkg/buffer"
+)
+
+// hessian2 serialization id
+const hessian2SerializationId = 2
+
+// hessian2 tags of the attachments
+const (
+	untypedMap = 'H'
+	mapEnd     = 'Z'
+	null       = 'N'
+)
+
+var (
+	ErrAttachmentNotSupported = errors.New("[xprotocol][dubbo] attachments only supported by hessian2 request")
+	ErrAttachmentMalformed    = errors.New("[xprotocol][dubbo] attachments is malformed")
+)
+
+func (r *Frame) attachmentSupported() bool {
+	return r.Event == 0 && r.Direction == EventRequest && r.SerializationId == hessian2SerializationId
+}
+
+// GetAttachments decodes the attachments of the request.
+// The attachments is the last field of the request body, so the arguments have to be decoded too.
+func (r *Frame) GetAttachments() (map[string]string, error) {
+	inv, err := r.DecodeInvocation(-1, true)
+	if err != nil {
+		return nil, err
+	}
+	return inv.Attachments, nil
+}
+
+// GetAttachment returns the attachment value of the key.
+func (r *Frame) GetAttachment(key string) (string, bool) {
+	attachments, err := r.GetAttachments()
+	if err != nil {
+		return "", false
+	}
+	value, ok := attachments[key]
+	return value, ok
+}
+
+// SetAttachment sets an attachment of the request.
+// The attachments map ends the request body, so the entry is appended just before the map end tag
+// without decoding the arguments. A key that already exists is overwritten by the appended entry
+// when the map is decoded, since the later value wins. The null attachments is replaced by a new map.
+func (r *Frame) SetAttachment(key, value string) error {
+	if !r.attachmentSupported() {
+		return ErrAttachmentNotSupported
+	}
+	n := len(r.payload)
+	if n == 0 || (r.payload[n-1] != mapEnd && r.payload[n-1] != null) {
+		return ErrAttachmentMalformed
+	}
+	encoder := hessian.NewEncoder()
+	if err := encoder.Encode(key); err != nil {
+		return err
+	}
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	entry := encoder.Buffer()
+
+	payload := make([]byte, 0, n+len(entry)+1)
+	payload = append(payload, r.payload[:n-1]...)
+	if r.payload[n-1] == null {
+		payload = append(payload, untypedMap)
+	}
+	payload = append(payload, entry...)
+	payload = append(payload, mapEnd)
+	r.setPayload(payload)
+	return nil
+}
+
+// setPayload replaces the payload, and keeps the raw data consistent with it.
+func (r *Frame) setPayload(payload []byte) {
+	r.payload = payload
+	r.DataLen = uint32(len(payload))
+	r.content = buffer.NewIoBufferBytes(r.payload)
+
+	if len(r.rawData) >= HeaderLen {
+		rawData := make([]byte, HeaderLen+len(payload))
+		copy(rawData, r.rawData[:HeaderLen])
+		binary.BigEndian.PutUint32(rawData[DataLenIdx:], r.DataLen)
+		copy(rawData[HeaderLen:], payload)
+		r.rawData = rawData
+		r.data = buffer.NewIoBufferBytes(r.rawData)
+	}
+}
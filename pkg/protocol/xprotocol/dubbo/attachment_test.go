@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"mosn.io/pkg/buffer"
+)
+
+func buildRequest(t *testing.T, attachments map[string]string) []byte {
+	encoder := hessian.NewEncoder()
+	for _, v := range []interface{}{
+		"2.0.2", "com.alipay.test.TestService", "1.0.0", "sayHello",
+		"Ljava/lang/String;I", "hello", int32(1),
+		attachments,
+	} {
+		if err := encoder.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payload := encoder.Buffer()
+	header := make([]byte, HeaderLen)
+	header[MagicIdx], header[MagicIdx+1] = 0xda, 0xbb
+	header[FlagIdx] = 0xc2 // request, two way, hessian2
+	binary.BigEndian.PutUint64(header[IdIdx:], 1)
+	binary.BigEndian.PutUint32(header[DataLenIdx:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestAttachment(t *testing.T) {
+	data := buffer.NewIoBufferBytes(buildRequest(t, map[string]string{
+		"path":         "com.alipay.test.TestService",
+		"x-request-id": "old",
+	}))
+	cmd, err := decodeFrame(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := cmd.(*Frame)
+	if v, ok := frame.GetAttachment("x-request-id"); !ok || v != "old" {
+		t.Fatalf("unexpected attachment: %s, %v", v, ok)
+	}
+	if err := frame.SetAttachment("x-request-id", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := frame.SetAttachment("trace", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	// encode and decode again
+	buf, err := encodeFrame(context.Background(), frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err = decodeFrame(context.Background(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame = cmd.(*Frame)
+	attachments, err := frame.GetAttachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 3 ||
+		attachments["path"] != "com.alipay.test.TestService" ||
+		attachments["x-request-id"] != "new" ||
+		attachments["trace"] != "abc" {
+		t.Fatalf("unexpected attachments: %v", attachments)
+	}
+	if service, _ := frame.Get(ServiceNameHeader); service != "com.alipay.test.TestService" {
+		t.Fatalf("unexpected service: %s", service)
+	}
+}
+
+func TestAttachmentNotSupported(t *testing.T) {
+	frame := &Frame{
+		Header: Header{
+			Direction:       EventResponse,
+			SerializationId: hessian2SerializationId,
+		},
+	}
+	if err := frame.SetAttachment("key", "value"); err != ErrAttachmentNotSupported {
+		t.Fatalf("expected not supported, but got %v", err)
+	}
+	frame.Direction = EventRequest
+	frame.payload = []byte{'T'}
+	if err := frame.SetAttachment("key", "value"); err != ErrAttachmentMalformed {
+		t.Fatalf("expected malformed, but got %v", err)
+	}
+}
+
+func TestAttachmentNull(t *testing.T) {
+	// the empty attachments is encoded as null
+	data := buffer.NewIoBufferBytes(buildRequest(t, map[string]string{}))
+	cmd, err := decodeFrame(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := cmd.(*Frame)
+	if attachments, err := frame.GetAttachments(); err != nil || len(attachments) != 0 {
+		t.Fatalf("unexpected attachments: %v, %v", attachments, err)
+	}
+	if err := frame.SetAttachment("key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := frame.GetAttachment("key"); !ok || v != "value" {
+		t.Fatalf("unexpected attachment: %s, %v", v, ok)
+	}
+}
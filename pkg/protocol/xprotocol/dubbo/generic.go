@@ -0,0 +1,357 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+const (
+	// GenericMethod is the method of the GenericService, which is exported by the dubbo providers
+	// and accepts the invocations that described by the method name, parameter types and arguments.
+	GenericMethod = "$invoke"
+	// GenericParameterTypes is the parameter types of the GenericMethod
+	GenericParameterTypes = "Ljava/lang/String;[Ljava/lang/String;[Ljava/lang/Object;"
+)
+
+var (
+	ErrGenericRequestInvalid = errors.New("[xprotocol][dubbo] generic request needs interface and method")
+	ErrResultNotSupported    = errors.New("[xprotocol][dubbo] result only supported by hessian2 response")
+)
+
+// GenericRequest describes a dubbo invocation in json.
+// The parameter types are the java class names, such as int, java.lang.String, long[] and com.foo.Bar.
+// The arguments are converted to the declared types, objects are described as json objects.
+type GenericRequest struct {
+	Interface      string            `json:"interface"`
+	Version        string            `json:"version,omitempty"`
+	Group          string            `json:"group,omitempty"`
+	Method         string            `json:"method"`
+	ParameterTypes []string          `json:"parameter_types,omitempty"`
+	Arguments      []interface{}     `json:"arguments,omitempty"`
+	Attachments    map[string]string `json:"attachments,omitempty"`
+	// Generic calls the GenericService of the provider instead of the method,
+	// so the provider converts the json objects to the argument classes.
+	Generic bool `json:"generic,omitempty"`
+}
+
+// NewGenericRequest builds a two way hessian2 request frame described by the GenericRequest.
+// The request id is set by the stream when the frame is sent.
+func NewGenericRequest(req *GenericRequest) (*Frame, error) {
+	if req.Interface == "" || req.Method == "" {
+		return nil, ErrGenericRequestInvalid
+	}
+	args, err := ConvertArguments(req.ParameterTypes, req.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	attachments := make(map[interface{}]interface{}, len(req.Attachments)+4)
+	for k, v := range req.Attachments {
+		attachments[k] = v
+	}
+	attachments["path"] = req.Interface
+	attachments["interface"] = req.Interface
+	if req.Version != "" {
+		attachments["version"] = req.Version
+	}
+	if req.Group != "" {
+		attachments["group"] = req.Group
+	}
+
+	method := req.Method
+	desc := ""
+	if req.Generic {
+		method = GenericMethod
+		desc = GenericParameterTypes
+		types := req.ParameterTypes
+		if types == nil {
+			types = []string{}
+		}
+		args = []interface{}{req.Method, types, args}
+		attachments["generic"] = "true"
+	} else {
+		for _, typ := range req.ParameterTypes {
+			d, err := TypeDescriptor(typ)
+			if err != nil {
+				return nil, err
+			}
+			desc += d
+		}
+	}
+
+	encoder := hessian.NewEncoder()
+	fields := append([]interface{}{hessian.DEFAULT_DUBBO_PROTOCOL_VERSION, req.Interface, req.Version, method, desc}, args...)
+	for _, field := range append(fields, attachments) {
+		if err := encoder.Encode(field); err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] encode request fail: %v", err)
+		}
+	}
+	frame := &Frame{
+		Header: Header{
+			Magic:           MagicTag,
+			Flag:            hessian.FLAG_REQUEST | hessian.FLAG_TWOWAY | hessian2SerializationId,
+			TwoWay:          1,
+			Direction:       EventRequest,
+			SerializationId: hessian2SerializationId,
+			CommonHeader:    protocol.CommonHeader{},
+		},
+	}
+	frame.setPayload(encoder.Buffer())
+	frame.Set(ServiceNameHeader, req.Interface)
+	frame.Set(MethodNameHeader, method)
+	return frame, nil
+}
+
+var primitiveDescriptors = map[string]string{
+	"boolean": "Z",
+	"byte":    "B",
+	"char":    "C",
+	"double":  "D",
+	"float":   "F",
+	"int":     "I",
+	"long":    "J",
+	"short":   "S",
+	"void":    "V",
+}
+
+// TypeDescriptor converts a java class name to the jvm type descriptor used by the parameter types,
+// such as int to I, java.lang.String to Ljava/lang/String; and int[] to [I.
+func TypeDescriptor(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", errors.New("[xprotocol][dubbo] empty parameter type")
+	case strings.HasSuffix(name, "[]"):
+		elem, err := TypeDescriptor(strings.TrimSuffix(name, "[]"))
+		if err != nil {
+			return "", err
+		}
+		return "[" + elem, nil
+	case strings.HasPrefix(name, "["):
+		// the name of array class, such as [Ljava.lang.String;
+		return strings.Replace(name, ".", "/", -1), nil
+	}
+	if d, ok := primitiveDescriptors[name]; ok {
+		return d, nil
+	}
+	return "L" + strings.Replace(name, ".", "/", -1) + ";", nil
+}
+
+// ConvertArguments converts the json arguments to the values that hessian encodes as the parameter types.
+func ConvertArguments(types []string, arguments []interface{}) ([]interface{}, error) {
+	if len(types) != len(arguments) {
+		return nil, fmt.Errorf("[xprotocol][dubbo] %d parameter types with %d arguments", len(types), len(arguments))
+	}
+	args := make([]interface{}, len(arguments))
+	for i, arg := range arguments {
+		v, err := convertArgument(types[i], arg)
+		if err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] argument %d: %v", i, err)
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// convertArgument converts the json value to the go type that hessian encodes as the java type.
+// Values of other types are encoded as they are, json objects are encoded as maps.
+func convertArgument(typ string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch typ {
+	case "int", "java.lang.Integer", "short", "java.lang.Short", "byte", "java.lang.Byte":
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt32 || n < math.MinInt32 {
+			return nil, fmt.Errorf("%d overflows %s", n, typ)
+		}
+		return int32(n), nil
+	case "long", "java.lang.Long":
+		return toInt64(v)
+	case "double", "java.lang.Double", "float", "java.lang.Float":
+		return toFloat64(v)
+	case "boolean", "java.lang.Boolean":
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			return strconv.ParseBool(b)
+		}
+		return nil, fmt.Errorf("%v is not a boolean", v)
+	case "java.lang.String", "char", "java.lang.Character":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(v), nil
+	}
+	if strings.HasSuffix(typ, "[]") {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is not an array", v)
+		}
+		elem := strings.TrimSuffix(typ, "[]")
+		values := make([]interface{}, len(list))
+		for i, item := range list {
+			value, err := convertArgument(elem, item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	return v, nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, fmt.Errorf("%v is not an integer", n)
+		}
+		return int64(n), nil
+	case json.Number:
+		return n.Int64()
+	case string:
+		// large longs are passed as strings, since json numbers lose the precision
+		return strconv.ParseInt(n, 10, 64)
+	case int:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	}
+	return 0, fmt.Errorf("%v is not an integer", v)
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// Result is the decoded body of a hessian2 response.
+type Result struct {
+	// Value is converted by ToJSONValue
+	Value interface{}
+	// Exception is the throwable of the provider, it is nil if the exception class is not registered in hessian
+	Exception    interface{}
+	HasException bool
+	Attachments  map[string]string
+}
+
+// ResponseError is returned by DecodeResult if the response status is not ok.
+type ResponseError struct {
+	Status  byte
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("[xprotocol][dubbo] response status %d: %s", e.Status, e.Message)
+}
+
+// DecodeResult decodes the body of the response.
+func (r *Frame) DecodeResult() (*Result, error) {
+	if r.Event != 0 || r.Direction != EventResponse || r.SerializationId != hessian2SerializationId {
+		return nil, ErrResultNotSupported
+	}
+	decoder := hessian.NewDecoderWithSkip(r.payload)
+	if r.Status != hessian.Response_OK {
+		// the error message
+		field, _ := decoder.Decode()
+		msg, _ := field.(string)
+		return nil, &ResponseError{Status: r.Status, Message: msg}
+	}
+	field, err := decoder.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("[xprotocol][dubbo] decode response type fail: %v", err)
+	}
+	typ, ok := field.(int32)
+	if !ok {
+		return nil, fmt.Errorf("[xprotocol][dubbo] unknown response type: %v", field)
+	}
+	result := &Result{}
+	switch typ {
+	case hessian.RESPONSE_WITH_EXCEPTION, hessian.RESPONSE_WITH_EXCEPTION_WITH_ATTACHMENTS:
+		result.HasException = true
+		if result.Exception, err = decoder.Decode(); err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] decode exception fail: %v", err)
+		}
+	case hessian.RESPONSE_VALUE, hessian.RESPONSE_VALUE_WITH_ATTACHMENTS:
+		value, err := decoder.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] decode value fail: %v", err)
+		}
+		result.Value = ToJSONValue(value)
+	case hessian.RESPONSE_NULL_VALUE, hessian.RESPONSE_NULL_VALUE_WITH_ATTACHMENTS:
+	default:
+		return nil, fmt.Errorf("[xprotocol][dubbo] unknown response type: %d", typ)
+	}
+	if typ >= hessian.RESPONSE_WITH_EXCEPTION_WITH_ATTACHMENTS {
+		field, err := decoder.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] decode attachments fail: %v", err)
+		}
+		if attachments, ok := field.(map[interface{}]interface{}); ok {
+			result.Attachments = hessian.ToMapStringString(attachments)
+		}
+	}
+	return result, nil
+}
+
+// ToJSONValue converts the value decoded by hessian to a value that can be marshaled to json,
+// the keys of maps are formatted as strings.
+func ToJSONValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = ToJSONValue(item)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = ToJSONValue(item)
+		}
+		return list
+	}
+	return v
+}
@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"mosn.io/pkg/buffer"
+)
+
+func TestTypeDescriptor(t *testing.T) {
+	for name, expected := range map[string]string{
+		"int":                 "I",
+		"long[]":              "[J",
+		"java.lang.String":    "Ljava/lang/String;",
+		"java.lang.String[]":  "[Ljava/lang/String;",
+		"[Ljava.lang.String;": "[Ljava/lang/String;",
+		"com.foo.Bar":         "Lcom/foo/Bar;",
+	} {
+		if d, err := TypeDescriptor(name); err != nil || d != expected {
+			t.Errorf("%s: expected %s, got %s, %v", name, expected, d, err)
+		}
+	}
+	if _, err := TypeDescriptor(" "); err == nil {
+		t.Error("expected error for empty type")
+	}
+}
+
+func decodeGenericRequest(t *testing.T, body string) *Invocation {
+	req := &GenericRequest{}
+	if err := json.Unmarshal([]byte(body), req); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := NewGenericRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame.SetRequestId(7)
+	buf, err := encodeFrame(context.Background(), frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := decodeFrame(context.Background(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := cmd.(*Frame)
+	if decoded.GetRequestId() != 7 || decoded.TwoWay != 1 {
+		t.Fatalf("unexpected header: %+v", decoded.Header)
+	}
+	if service, _ := decoded.Get(ServiceNameHeader); service != req.Interface {
+		t.Fatalf("unexpected service: %s", service)
+	}
+	inv, err := decoded.DecodeInvocation(-1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
+
+func TestNewGenericRequest(t *testing.T) {
+	inv := decodeGenericRequest(t, `{
+		"interface": "com.foo.UserService",
+		"version": "1.0.0",
+		"group": "g1",
+		"method": "query",
+		"parameter_types": ["java.lang.String", "int", "long", "boolean", "int[]", "com.foo.Query"],
+		"arguments": ["bob", 18, "9007199254740993", true, [1, 2], {"name": "bob"}],
+		"attachments": {"dubbo.tag": "gray"}
+	}`)
+	if inv.Method != "query" || inv.Version != "1.0.0" ||
+		inv.ParameterTypes != "Ljava/lang/String;IJZ[ILcom/foo/Query;" {
+		t.Fatalf("unexpected invocation: %+v", inv)
+	}
+	expected := []interface{}{"bob", int32(18), int64(9007199254740993), true, []interface{}{int32(1), int32(2)},
+		map[interface{}]interface{}{"name": "bob"}}
+	if !reflect.DeepEqual(inv.Arguments, expected) {
+		t.Fatalf("unexpected arguments: %#v", inv.Arguments)
+	}
+	for k, v := range map[string]string{
+		"dubbo.tag": "gray",
+		"path":      "com.foo.UserService",
+		"interface": "com.foo.UserService",
+		"version":   "1.0.0",
+		"group":     "g1",
+	} {
+		if inv.Attachments[k] != v {
+			t.Errorf("attachment %s: expected %s, got %s", k, v, inv.Attachments[k])
+		}
+	}
+}
+
+func TestNewGenericRequestGeneric(t *testing.T) {
+	inv := decodeGenericRequest(t, `{
+		"interface": "com.foo.UserService",
+		"method": "query",
+		"parameter_types": ["java.lang.String"],
+		"arguments": ["bob"],
+		"generic": true
+	}`)
+	if inv.Method != GenericMethod || inv.ParameterTypes != GenericParameterTypes || inv.Attachments["generic"] != "true" {
+		t.Fatalf("unexpected invocation: %+v", inv)
+	}
+	if len(inv.Arguments) != 3 || inv.Arguments[0] != "query" ||
+		!reflect.DeepEqual(inv.Arguments[2], []interface{}{"bob"}) {
+		t.Fatalf("unexpected arguments: %#v", inv.Arguments)
+	}
+}
+
+func TestNewGenericRequestInvalid(t *testing.T) {
+	for _, req := range []*GenericRequest{
+		{Method: "query"},
+		{Interface: "com.foo.UserService", Method: "query", ParameterTypes: []string{"int"}},
+		{Interface: "com.foo.UserService", Method: "query", ParameterTypes: []string{"int"}, Arguments: []interface{}{1.5}},
+		{Interface: "com.foo.UserService", Method: "query", ParameterTypes: []string{"int"}, Arguments: []interface{}{1e10}},
+		{Interface: "com.foo.UserService", Method: "query", ParameterTypes: []string{"boolean"}, Arguments: []interface{}{"yes"}},
+	} {
+		if _, err := NewGenericRequest(req); err == nil {
+			t.Errorf("expected error for %+v", req)
+		}
+	}
+}
+
+func buildResponse(t *testing.T, status byte, fields ...interface{}) *Frame {
+	encoder := hessian.NewEncoder()
+	for _, v := range fields {
+		if err := encoder.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payload := encoder.Buffer()
+	header := make([]byte, HeaderLen)
+	copy(header, MagicTag)
+	header[FlagIdx] = 0x02 // response, hessian2
+	header[StatusIdx] = status
+	binary.BigEndian.PutUint32(header[DataLenIdx:], uint32(len(payload)))
+	cmd, err := decodeFrame(context.Background(), buffer.NewIoBufferBytes(append(header, payload...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd.(*Frame)
+}
+
+func TestDecodeResult(t *testing.T) {
+	frame := buildResponse(t, hessian.Response_OK, hessian.RESPONSE_VALUE_WITH_ATTACHMENTS,
+		map[interface{}]interface{}{"name": "bob", "tags": []interface{}{"a"}, int32(1): "one"},
+		map[interface{}]interface{}{"dubbo": "2.0.2"})
+	result, err := frame.DecodeResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"name": "bob", "tags": []interface{}{"a"}, "1": "one"}
+	if !reflect.DeepEqual(result.Value, expected) || result.HasException || result.Attachments["dubbo"] != "2.0.2" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	result, err = buildResponse(t, hessian.Response_OK, hessian.RESPONSE_NULL_VALUE).DecodeResult()
+	if err != nil || result.Value != nil || result.HasException {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+
+	_, err = buildResponse(t, hessian.Response_SERVICE_NOT_FOUND, "no provider").DecodeResult()
+	if e, ok := err.(*ResponseError); !ok || e.Status != hessian.Response_SERVICE_NOT_FOUND || e.Message != "no provider" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
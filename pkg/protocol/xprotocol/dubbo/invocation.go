@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"fmt"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+)
+
+// Invocation is the decoded body of a hessian2 request.
+// Fields that are not decoded are left empty.
+type Invocation struct {
+	DubboVersion   string
+	Service        string
+	Version        string
+	Method         string
+	ParameterTypes string
+	Arguments      []interface{}
+	Attachments    map[string]string
+}
+
+// ArgumentCount returns the number of the arguments described by the parameter types.
+func (inv *Invocation) ArgumentCount() int {
+	return len(hessian.DescRegex.FindAllString(inv.ParameterTypes, -1))
+}
+
+// DecodeInvocation decodes the request body partially.
+// At most args arguments are decoded, a negative value means all of them.
+// The attachments ends the request body, so all the arguments are decoded before
+// the attachments if withAttachments is true.
+// Argument objects whose java class is not registered in hessian are decoded as nil.
+func (r *Frame) DecodeInvocation(args int, withAttachments bool) (*Invocation, error) {
+	if !r.attachmentSupported() {
+		return nil, ErrAttachmentNotSupported
+	}
+	decoder := hessian.NewDecoderWithSkip(r.payload)
+	inv := &Invocation{}
+	// dubbo version + path + version + method + args desc
+	for _, field := range []*string{&inv.DubboVersion, &inv.Service, &inv.Version, &inv.Method, &inv.ParameterTypes} {
+		value, err := decoder.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] decode request body fail: %v", err)
+		}
+		// the version may be null
+		*field, _ = value.(string)
+	}
+	total := inv.ArgumentCount()
+	if args < 0 || args > total || withAttachments {
+		args = total
+	}
+	for i := 0; i < args; i++ {
+		arg, err := decoder.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] decode request args fail: %v", err)
+		}
+		inv.Arguments = append(inv.Arguments, arg)
+	}
+	if !withAttachments {
+		return inv, nil
+	}
+	field, err := decoder.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("[xprotocol][dubbo] decode attachments fail: %v", err)
+	}
+	if field == nil {
+		inv.Attachments = map[string]string{}
+		return inv, nil
+	}
+	attachments, ok := field.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrAttachmentMalformed
+	}
+	inv.Attachments = hessian.ToMapStringString(attachments)
+	return inv, nil
+}
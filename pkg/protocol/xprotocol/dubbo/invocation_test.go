@@ -0,0 +1,60 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"testing"
+
+	"mosn.io/pkg/buffer"
+)
+
+func TestDecodeInvocation(t *testing.T) {
+	data := buffer.NewIoBufferBytes(buildRequest(t, map[string]string{
+		"dubbo.tag": "gray",
+	}))
+	cmd, err := decodeFrame(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := cmd.(*Frame)
+	inv, err := frame.DecodeInvocation(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Service != "com.alipay.test.TestService" || inv.Version != "1.0.0" || inv.Method != "sayHello" ||
+		inv.ParameterTypes != "Ljava/lang/String;I" || inv.ArgumentCount() != 2 {
+		t.Fatalf("unexpected invocation: %+v", inv)
+	}
+	if len(inv.Arguments) != 1 || inv.Arguments[0] != "hello" || inv.Attachments != nil {
+		t.Fatalf("unexpected partial decoding: %+v", inv)
+	}
+	// the attachments need all the arguments
+	inv, err = frame.DecodeInvocation(0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Arguments) != 2 || inv.Arguments[1] != int32(1) || inv.Attachments["dubbo.tag"] != "gray" {
+		t.Fatalf("unexpected invocation: %+v", inv)
+	}
+	// response is not supported
+	frame.Direction = EventResponse
+	if _, err := frame.DecodeInvocation(-1, true); err != ErrAttachmentNotSupported {
+		t.Fatalf("expected not supported, got %v", err)
+	}
+}
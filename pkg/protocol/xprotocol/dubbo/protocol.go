@@ -102,7 +102,7 @@ func (proto *dubboProtocol) Reply(request xprotocol.XFrame) xprotocol.XRespFrame
 			Magic:   MagicTag,
 			Flag:    0x22,
 			Status:  0x14,
-			Id:      request.GetRequestId(),
+			Id:      request.(xprotocol.Multiplexing).GetRequestId(),
 			DataLen: 0x02,
 		},
 		payload: []byte{0x4e, 0x4e},
@@ -48,6 +48,14 @@ func GetProtocol(name types.ProtocolName) XProtocol {
 	return protocolMap[name]
 }
 
+// GetPoolMode return the pool mode of the protocol for given name, the unknown protocols are treated as Multiplex
+func GetPoolMode(name types.ProtocolName) PoolMode {
+	if moder, ok := protocolMap[name].(PoolModer); ok {
+		return moder.PoolMode()
+	}
+	return Multiplex
+}
+
 // RegisterMatcher register the matcher of the protocol into factory
 func RegisterMatcher(name types.ProtocolName, matcher types.ProtocolMatch) error {
 	// check name conflict
@@ -43,6 +43,20 @@ func Test_Factory_Protocol(t *testing.T) {
 	}
 }
 
+func Test_Factory_PoolMode(t *testing.T) {
+	// unknown protocol is multiplexed
+	if mode := GetPoolMode("unknown-protocol"); mode != Multiplex {
+		t.Errorf("expected multiplex pool mode for unknown protocol, but got %v", mode)
+	}
+
+	if err := RegisterProtocol(mockPingPongProtocolName, &mockPingPongProtocol{}); err != nil {
+		t.Fatal("register protocol failed:", err)
+	}
+	if mode := GetPoolMode(mockPingPongProtocolName); mode != PingPong {
+		t.Errorf("expected ping-pong pool mode, but got %v", mode)
+	}
+}
+
 func Test_Factory_Matcher(t *testing.T) {
 	// 1. get nil
 	matcher := GetMatcher(mockProtocolName)
@@ -58,6 +58,20 @@ func (mp *mockProtocol) Mapping(httpStatusCode uint32) uint32 {
 	return 0
 }
 
+var mockPingPongProtocolName = types.ProtocolName("mock-pingpong-protocol")
+
+type mockPingPongProtocol struct {
+	mockProtocol
+}
+
+func (mp *mockPingPongProtocol) Name() types.ProtocolName {
+	return mockPingPongProtocolName
+}
+
+func (mp *mockPingPongProtocol) PoolMode() PoolMode {
+	return PingPong
+}
+
 func mockMatcher(data []byte) types.MatchResult {
 	return types.MatchSuccess
 }
@@ -0,0 +1,48 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package thrift
+
+import (
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// NewRpcRequest is a utility function which builds a call message of thrift protocol,
+// the payload is the encoded argument struct of the method.
+func NewRpcRequest(protocolType byte, name string, seqId int32, payload types.IoBuffer) *Frame {
+	return newFrame(protocolType, MessageTypeCall, name, seqId, payload)
+}
+
+// NewRpcResponse is a utility function which builds a reply message of thrift protocol,
+// the payload is the encoded result struct of the method.
+func NewRpcResponse(protocolType byte, name string, seqId int32, payload types.IoBuffer) *Frame {
+	return newFrame(protocolType, MessageTypeReply, name, seqId, payload)
+}
+
+func newFrame(protocolType byte, messageType byte, name string, seqId int32, payload types.IoBuffer) *Frame {
+	frame := &Frame{
+		CommonHeader: protocol.CommonHeader{},
+		ProtocolType: protocolType,
+		MessageType:  messageType,
+		Name:         name,
+		SeqId:        seqId,
+		data:         payload,
+	}
+	frame.Set(ServiceNameHeader, frame.GetServiceName())
+	frame.Set(MethodNameHeader, frame.GetMethodName())
+	return frame
+}
@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package thrift
+
+import (
+	"strings"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// Frame is a thrift message carried by the framed transport, requests and responses share the same layout
+type Frame struct {
+	protocol.CommonHeader
+
+	ProtocolType  byte
+	MessageType   byte
+	Name          string // message name, may be prefixed with the service name by TMultiplexedProtocol
+	SeqId         int32
+	ExceptionType uint32 // the type of TApplicationException, only valid for exception messages
+
+	rawData []byte         // raw data
+	payload []byte         // message body after the message header
+	data    types.IoBuffer // wrapper of payload
+}
+
+// ~ XFrame
+func (f *Frame) GetRequestId() uint64 {
+	return uint64(uint32(f.SeqId))
+}
+
+func (f *Frame) SetRequestId(id uint64) {
+	f.SeqId = int32(id)
+}
+
+func (f *Frame) IsHeartbeatFrame() bool {
+	// thrift has no heartbeat
+	return false
+}
+
+func (f *Frame) GetStreamType() xprotocol.StreamType {
+	switch f.MessageType {
+	case MessageTypeCall:
+		return xprotocol.Request
+	case MessageTypeOneway:
+		return xprotocol.RequestOneWay
+	default:
+		return xprotocol.Response
+	}
+}
+
+func (f *Frame) GetHeader() types.HeaderMap {
+	return f
+}
+
+func (f *Frame) GetData() types.IoBuffer {
+	return f.data
+}
+
+func (f *Frame) SetData(data types.IoBuffer) {
+	f.data = data
+}
+
+// ~ XRespFrame
+func (f *Frame) GetStatusCode() uint32 {
+	if f.MessageType == MessageTypeException {
+		return ResponseStatusException + f.ExceptionType
+	}
+	return ResponseStatusSuccess
+}
+
+// ~ ServiceAware
+func (f *Frame) GetServiceName() string {
+	if idx := strings.Index(f.Name, MultiplexedSeparator); idx >= 0 {
+		return f.Name[:idx]
+	}
+	return ""
+}
+
+func (f *Frame) GetMethodName() string {
+	if idx := strings.Index(f.Name, MultiplexedSeparator); idx >= 0 {
+		return f.Name[idx+len(MultiplexedSeparator):]
+	}
+	return f.Name
+}
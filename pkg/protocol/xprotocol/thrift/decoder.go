@@ -0,0 +1,225 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package thrift
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+var (
+	ErrFrameTooLarge      = errors.New("thrift frame is too large")
+	ErrInvalidHeader      = errors.New("thrift message header is invalid")
+	ErrUnsupportedVersion = errors.New("thrift protocol version is not supported")
+)
+
+// the field types used by TApplicationException
+const (
+	binaryTypeStop   byte = 0
+	binaryTypeI32    byte = 8
+	binaryTypeString byte = 11
+
+	compactTypeI32    byte = 5
+	compactTypeBinary byte = 8
+)
+
+func decodeFrame(ctx context.Context, data types.IoBuffer) (interface{}, error) {
+	bytes := data.Bytes()
+	if len(bytes) < FrameHeaderSize {
+		return nil, nil
+	}
+	size := binary.BigEndian.Uint32(bytes)
+	if size > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	frameLen := FrameHeaderSize + int(size)
+	if len(bytes) < frameLen {
+		return nil, nil
+	}
+
+	frame := &Frame{
+		CommonHeader: protocol.CommonHeader{},
+	}
+	frame.rawData = make([]byte, frameLen)
+	copy(frame.rawData, bytes[:frameLen])
+
+	headerLen, err := readMessageHeader(frame, frame.rawData[FrameHeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+	frame.payload = frame.rawData[FrameHeaderSize+headerLen:]
+	frame.data = buffer.NewIoBufferBytes(frame.payload)
+
+	if frame.MessageType == MessageTypeException {
+		_, frame.ExceptionType = readApplicationException(frame.ProtocolType, frame.payload)
+	}
+
+	// service aware
+	frame.Set(ServiceNameHeader, frame.GetServiceName())
+	frame.Set(MethodNameHeader, frame.GetMethodName())
+
+	data.Drain(frameLen)
+	return frame, nil
+}
+
+// readMessageHeader fills the frame with the message header and returns the length of the header
+func readMessageHeader(frame *Frame, msg []byte) (int, error) {
+	if len(msg) < 2 {
+		return 0, ErrInvalidHeader
+	}
+	switch {
+	case msg[0] == CompactProtocolId:
+		return readCompactMessageHeader(frame, msg)
+	case msg[0]&0x80 != 0:
+		return readBinaryMessageHeader(frame, msg)
+	default:
+		// the non-strict binary protocol, which starts with the name length, is not supported
+		return 0, ErrUnsupportedVersion
+	}
+}
+
+func readBinaryMessageHeader(frame *Frame, msg []byte) (int, error) {
+	if len(msg) < 8 {
+		return 0, ErrInvalidHeader
+	}
+	version := binary.BigEndian.Uint32(msg)
+	if version&BinaryVersionMask != BinaryVersion1 {
+		return 0, ErrUnsupportedVersion
+	}
+	nameLen := int(int32(binary.BigEndian.Uint32(msg[4:])))
+	if nameLen < 0 || len(msg) < 8+nameLen+4 {
+		return 0, ErrInvalidHeader
+	}
+	frame.ProtocolType = ProtocolTypeBinary
+	frame.MessageType = byte(version & BinaryTypeMask)
+	frame.Name = string(msg[8 : 8+nameLen])
+	frame.SeqId = int32(binary.BigEndian.Uint32(msg[8+nameLen:]))
+	return 8 + nameLen + 4, nil
+}
+
+func readCompactMessageHeader(frame *Frame, msg []byte) (int, error) {
+	if msg[1]&CompactVersionMask != CompactVersion {
+		return 0, ErrUnsupportedVersion
+	}
+	offset := 2
+	seqId, n := binary.Uvarint(msg[offset:])
+	if n <= 0 {
+		return 0, ErrInvalidHeader
+	}
+	offset += n
+	nameLen, n := binary.Uvarint(msg[offset:])
+	if n <= 0 || uint64(len(msg)-offset-n) < nameLen {
+		return 0, ErrInvalidHeader
+	}
+	offset += n
+	frame.ProtocolType = ProtocolTypeCompact
+	frame.MessageType = (msg[1] >> CompactTypeShift) & CompactTypeBitsMask
+	frame.SeqId = int32(seqId)
+	frame.Name = string(msg[offset : offset+int(nameLen)])
+	return offset + int(nameLen), nil
+}
+
+// readApplicationException reads the message and the type of the TApplicationException,
+// the type is ExceptionUnknown if the struct cannot be recognized
+func readApplicationException(protocolType byte, payload []byte) (message string, exceptionType uint32) {
+	if protocolType == ProtocolTypeCompact {
+		return readCompactApplicationException(payload)
+	}
+	return readBinaryApplicationException(payload)
+}
+
+func readBinaryApplicationException(payload []byte) (message string, exceptionType uint32) {
+	for offset := 0; offset < len(payload); {
+		fieldType := payload[offset]
+		if fieldType == binaryTypeStop || len(payload) < offset+3 {
+			return
+		}
+		fieldId := int16(binary.BigEndian.Uint16(payload[offset+1:]))
+		offset += 3
+		switch {
+		case fieldId == 1 && fieldType == binaryTypeString:
+			if len(payload) < offset+4 {
+				return
+			}
+			size := int(int32(binary.BigEndian.Uint32(payload[offset:])))
+			offset += 4
+			if size < 0 || len(payload) < offset+size {
+				return
+			}
+			message = string(payload[offset : offset+size])
+			offset += size
+		case fieldId == 2 && fieldType == binaryTypeI32:
+			if len(payload) < offset+4 {
+				return
+			}
+			exceptionType = binary.BigEndian.Uint32(payload[offset:])
+			offset += 4
+		default:
+			// unexpected field, stop parsing
+			return
+		}
+	}
+	return
+}
+
+func readCompactApplicationException(payload []byte) (message string, exceptionType uint32) {
+	var lastId int64
+	for offset := 0; offset < len(payload); {
+		b := payload[offset]
+		offset++
+		if b == binaryTypeStop {
+			return
+		}
+		fieldType := b & 0x0f
+		fieldId := lastId + int64(b>>4)
+		if b>>4 == 0 {
+			id, n := binary.Varint(payload[offset:])
+			if n <= 0 {
+				return
+			}
+			fieldId = id
+			offset += n
+		}
+		lastId = fieldId
+		switch {
+		case fieldId == 1 && fieldType == compactTypeBinary:
+			size, n := binary.Uvarint(payload[offset:])
+			if n <= 0 || uint64(len(payload)-offset-n) < size {
+				return
+			}
+			offset += n
+			message = string(payload[offset : offset+int(size)])
+			offset += int(size)
+		case fieldId == 2 && fieldType == compactTypeI32:
+			v, n := binary.Varint(payload[offset:])
+			if n <= 0 {
+				return
+			}
+			exceptionType = uint32(v)
+			offset += n
+		default:
+			// unexpected field, stop parsing
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package thrift
+
+import (
+	"context"
+	"encoding/binary"
+
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func encodeFrame(ctx context.Context, frame *Frame) (types.IoBuffer, error) {
+	header := appendMessageHeader(make([]byte, FrameHeaderSize, FrameHeaderSize+len(frame.Name)+16), frame)
+
+	var payload []byte
+	if frame.data != nil {
+		payload = frame.data.Bytes()
+	}
+	binary.BigEndian.PutUint32(header, uint32(len(header)-FrameHeaderSize+len(payload)))
+
+	buf := buffer.GetIoBuffer(len(header) + len(payload))
+	buf.Write(header)
+	if len(payload) > 0 {
+		buf.Write(payload)
+	}
+	return buf, nil
+}
+
+func appendMessageHeader(dst []byte, frame *Frame) []byte {
+	var tmp [binary.MaxVarintLen32]byte
+	if frame.ProtocolType == ProtocolTypeCompact {
+		dst = append(dst, CompactProtocolId, CompactVersion|(frame.MessageType<<CompactTypeShift))
+		n := binary.PutUvarint(tmp[:], uint64(uint32(frame.SeqId)))
+		dst = append(dst, tmp[:n]...)
+		n = binary.PutUvarint(tmp[:], uint64(len(frame.Name)))
+		dst = append(dst, tmp[:n]...)
+		return append(dst, frame.Name...)
+	}
+	dst = appendUint32(dst, BinaryVersion1|uint32(frame.MessageType))
+	dst = appendUint32(dst, uint32(len(frame.Name)))
+	dst = append(dst, frame.Name...)
+	return appendUint32(dst, uint32(frame.SeqId))
+}
+
+// appendApplicationException appends a TApplicationException struct with the given message and type
+func appendApplicationException(dst []byte, protocolType byte, message string, exceptionType uint32) []byte {
+	if protocolType == ProtocolTypeCompact {
+		var tmp [binary.MaxVarintLen64]byte
+		// field 1, delta 1
+		dst = append(dst, 1<<4|compactTypeBinary)
+		n := binary.PutUvarint(tmp[:], uint64(len(message)))
+		dst = append(dst, tmp[:n]...)
+		dst = append(dst, message...)
+		// field 2, delta 1
+		dst = append(dst, 1<<4|compactTypeI32)
+		n = binary.PutVarint(tmp[:], int64(int32(exceptionType)))
+		dst = append(dst, tmp[:n]...)
+		return append(dst, binaryTypeStop)
+	}
+	dst = append(dst, binaryTypeString, 0, 1)
+	dst = appendUint32(dst, uint32(len(message)))
+	dst = append(dst, message...)
+	dst = append(dst, binaryTypeI32, 0, 2)
+	dst = appendUint32(dst, exceptionType)
+	return append(dst, binaryTypeStop)
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
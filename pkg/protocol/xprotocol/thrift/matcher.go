@@ -0,0 +1,46 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package thrift
+
+import (
+	"encoding/binary"
+
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	xprotocol.RegisterMatcher(ProtocolName, thriftMatcher)
+}
+
+// predicate the framed transport and compare the protocol id of binary and compact protocol
+func thriftMatcher(data []byte) types.MatchResult {
+	if len(data) < FrameHeaderSize+2 {
+		return types.MatchAgain
+	}
+	if binary.BigEndian.Uint32(data) > MaxFrameSize {
+		return types.MatchFailed
+	}
+	msg := data[FrameHeaderSize:]
+	if binary.BigEndian.Uint16(msg) == uint16(BinaryVersion1>>16) {
+		return types.MatchSuccess
+	}
+	if msg[0] == CompactProtocolId && msg[1]&CompactVersionMask == CompactVersion {
+		return types.MatchSuccess
+	}
+	return types.MatchFailed
+}
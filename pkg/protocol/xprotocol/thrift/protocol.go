@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package thrift
+
+import (
+	"context"
+	"net/http"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func init() {
+	xprotocol.RegisterProtocol(ProtocolName, &thriftProtocol{})
+}
+
+var exceptionMessages = map[uint32]string{
+	ExceptionUnknownMethod: "unknown method",
+	ExceptionInternalError: "internal error",
+	ExceptionProtocolError: "protocol error",
+}
+
+type thriftProtocol struct{}
+
+func (proto *thriftProtocol) Name() types.ProtocolName {
+	return ProtocolName
+}
+
+func (proto *thriftProtocol) Encode(ctx context.Context, model interface{}) (types.IoBuffer, error) {
+	if frame, ok := model.(*Frame); ok {
+		return encodeFrame(ctx, frame)
+	}
+	log.Proxy.Errorf(ctx, "[protocol][thrift] encode with unknown command : %+v", model)
+	return nil, xprotocol.ErrUnknownType
+}
+
+func (proto *thriftProtocol) Decode(ctx context.Context, data types.IoBuffer) (interface{}, error) {
+	return decodeFrame(ctx, data)
+}
+
+// heartbeater
+func (proto *thriftProtocol) Trigger(requestId uint64) xprotocol.XFrame {
+	// not support
+	return nil
+}
+
+func (proto *thriftProtocol) Reply(request xprotocol.XFrame) xprotocol.XRespFrame {
+	// not support
+	return nil
+}
+
+// hijacker
+func (proto *thriftProtocol) Hijack(statusCode uint32) xprotocol.XRespFrame {
+	return newExceptionFrame(ProtocolTypeBinary, "", statusCode)
+}
+
+// HijackRequest replies a TApplicationException with the same protocol and message name as the request,
+// the client would reject the reply otherwise.
+func (proto *thriftProtocol) HijackRequest(request xprotocol.XFrame, statusCode uint32) xprotocol.XRespFrame {
+	req, ok := request.(*Frame)
+	if !ok {
+		return proto.Hijack(statusCode)
+	}
+	resp := newExceptionFrame(req.ProtocolType, req.Name, statusCode)
+	resp.SeqId = req.SeqId
+	return resp
+}
+
+func (proto *thriftProtocol) Mapping(httpStatusCode uint32) uint32 {
+	switch httpStatusCode {
+	case http.StatusOK:
+		return ResponseStatusSuccess
+	case types.RouterUnavailableCode:
+		return ResponseStatusException + ExceptionUnknownMethod
+	case types.NoHealthUpstreamCode, types.UpstreamOverFlowCode, types.TimeoutExceptionCode:
+		return ResponseStatusException + ExceptionInternalError
+	case types.CodecExceptionCode, types.DeserialExceptionCode:
+		return ResponseStatusException + ExceptionProtocolError
+	default:
+		return ResponseStatusException + ExceptionUnknown
+	}
+}
+
+func newExceptionFrame(protocolType byte, name string, statusCode uint32) *Frame {
+	exceptionType := ExceptionUnknown
+	if statusCode >= ResponseStatusException {
+		exceptionType = statusCode - ResponseStatusException
+	}
+	message, ok := exceptionMessages[exceptionType]
+	if !ok {
+		message = "unknown error"
+	}
+	frame := &Frame{
+		CommonHeader:  protocol.CommonHeader{},
+		ProtocolType:  protocolType,
+		MessageType:   MessageTypeException,
+		Name:          name,
+		ExceptionType: exceptionType,
+	}
+	frame.payload = appendApplicationException(nil, protocolType, message, exceptionType)
+	frame.data = buffer.NewIoBufferBytes(frame.payload)
+	return frame
+}
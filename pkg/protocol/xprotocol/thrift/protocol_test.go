@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package thrift
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+var (
+	// binary protocol, call "ping" with seqid 1 and empty arguments
+	binaryCall = []byte{
+		0x00, 0x00, 0x00, 0x11,
+		0x80, 0x01, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x04, 'p', 'i', 'n', 'g',
+		0x00, 0x00, 0x00, 0x01,
+		0x00,
+	}
+	// compact protocol, call "Calc:add" with seqid 300 and empty arguments
+	compactCall = []byte{
+		0x00, 0x00, 0x00, 0x0e,
+		0x82, 0x21, 0xac, 0x02,
+		0x08, 'C', 'a', 'l', 'c', ':', 'a', 'd', 'd',
+		0x00,
+	}
+)
+
+func TestDecodeEncode(t *testing.T) {
+	proto := &thriftProtocol{}
+	testcases := []struct {
+		data         []byte
+		protocolType byte
+		seqId        uint64
+		service      string
+		method       string
+	}{
+		{binaryCall, ProtocolTypeBinary, 1, "", "ping"},
+		{compactCall, ProtocolTypeCompact, 300, "Calc", "add"},
+	}
+	for i, tc := range testcases {
+		// not enough data
+		buf := buffer.NewIoBufferBytes(tc.data[:len(tc.data)-1])
+		if cmd, err := proto.Decode(context.Background(), buf); cmd != nil || err != nil {
+			t.Fatalf("#%d decode partial data should wait for more, got %v %v", i, cmd, err)
+		}
+
+		buf = buffer.NewIoBufferBytes(append(append([]byte{}, tc.data...), 0xff))
+		cmd, err := proto.Decode(context.Background(), buf)
+		if err != nil {
+			t.Fatalf("#%d decode failed: %v", i, err)
+		}
+		if buf.Len() != 1 {
+			t.Errorf("#%d decoded frame should be drained, left %d", i, buf.Len())
+		}
+		frame := cmd.(*Frame)
+		if frame.ProtocolType != tc.protocolType || frame.GetRequestId() != tc.seqId ||
+			frame.GetStreamType() != xprotocol.Request {
+			t.Errorf("#%d unexpected frame: %+v", i, frame)
+		}
+		if frame.GetServiceName() != tc.service || frame.GetMethodName() != tc.method {
+			t.Errorf("#%d unexpected service %s method %s", i, frame.GetServiceName(), frame.GetMethodName())
+		}
+		if method, _ := frame.Get(MethodNameHeader); method != tc.method {
+			t.Errorf("#%d unexpected method header %s", i, method)
+		}
+
+		encoded, err := proto.Encode(context.Background(), frame)
+		if err != nil || !bytes.Equal(encoded.Bytes(), tc.data) {
+			t.Errorf("#%d encode mismatch: %v %v", i, encoded, err)
+		}
+
+		// the request id is rewritten by the stream layer
+		frame.SetRequestId(1 << 20)
+		encoded, _ = proto.Encode(context.Background(), frame)
+		cmd, err = proto.Decode(context.Background(), encoded)
+		if err != nil || cmd.(*Frame).GetRequestId() != 1<<20 || cmd.(*Frame).Name != frame.Name {
+			t.Errorf("#%d decode rewritten frame failed: %+v %v", i, cmd, err)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	proto := &thriftProtocol{}
+	for i, data := range [][]byte{
+		{0x7f, 0x00, 0x00, 0x00},                               // too large
+		{0x00, 0x00, 0x00, 0x04, 0x80, 0x02, 0x00, 0x01},       // unsupported binary version
+		{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x04},       // non-strict binary
+		{0x00, 0x00, 0x00, 0x04, 0x82, 0x22, 0x01, 0x08},       // unsupported compact version
+		{0x00, 0x00, 0x00, 0x06, 0x80, 0x01, 0x00, 0x01, 0, 0}, // truncated header
+	} {
+		if _, err := proto.Decode(context.Background(), buffer.NewIoBufferBytes(data)); err == nil {
+			t.Errorf("#%d decode should fail", i)
+		}
+	}
+}
+
+func TestHijack(t *testing.T) {
+	proto := &thriftProtocol{}
+	for _, data := range [][]byte{binaryCall, compactCall} {
+		cmd, _ := proto.Decode(context.Background(), buffer.NewIoBufferBytes(data))
+		request := cmd.(*Frame)
+
+		resp := proto.HijackRequest(request, proto.Mapping(types.RouterUnavailableCode))
+		encoded, err := proto.Encode(context.Background(), resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd, err = proto.Decode(context.Background(), encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame := cmd.(*Frame)
+		if frame.ProtocolType != request.ProtocolType || frame.Name != request.Name ||
+			frame.GetRequestId() != request.GetRequestId() || frame.GetStreamType() != xprotocol.Response {
+			t.Errorf("unexpected hijack response: %+v", frame)
+		}
+		if frame.ExceptionType != ExceptionUnknownMethod {
+			t.Errorf("unexpected exception type: %d", frame.ExceptionType)
+		}
+		message, _ := readApplicationException(frame.ProtocolType, frame.payload)
+		if message != "unknown method" {
+			t.Errorf("unexpected exception message: %s", message)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	testcases := []struct {
+		data     []byte
+		expected types.MatchResult
+	}{
+		{binaryCall[:5], types.MatchAgain},
+		{binaryCall, types.MatchSuccess},
+		{compactCall, types.MatchSuccess},
+		{[]byte{0x00, 0x00, 0x00, 0x04, 0xda, 0xbb}, types.MatchFailed},
+		{[]byte{0x7f, 0x00, 0x00, 0x00, 0x80, 0x01}, types.MatchFailed},
+	}
+	for i, tc := range testcases {
+		if result := thriftMatcher(tc.data); result != tc.expected {
+			t.Errorf("#%d unexpected match result: %v", i, result)
+		}
+	}
+}
+
+func TestThriftMapping(t *testing.T) {
+	m := &thriftStatusMapping{}
+	proto := &thriftProtocol{}
+	testcases := []struct {
+		Header   types.HeaderMap
+		Expected int
+	}{
+		{
+			Header:   NewRpcResponse(ProtocolTypeBinary, "ping", 1, nil),
+			Expected: http.StatusOK,
+		},
+		{
+			Header:   proto.Hijack(proto.Mapping(types.RouterUnavailableCode)).GetHeader(),
+			Expected: http.StatusNotFound,
+		},
+		{
+			Header:   proto.Hijack(proto.Mapping(types.CodecExceptionCode)).GetHeader(),
+			Expected: http.StatusBadRequest,
+		},
+		{
+			Header:   proto.Hijack(proto.Mapping(types.NoHealthUpstreamCode)).GetHeader(),
+			Expected: http.StatusInternalServerError,
+		},
+		{
+			Header:   protocol.CommonHeader{},
+			Expected: 0,
+		},
+	}
+	for i, tc := range testcases {
+		code, _ := m.MappingHeaderStatusCode(context.Background(), tc.Header)
+		if code != tc.Expected {
+			t.Errorf("#%d get unexpected code %d", i, code)
+		}
+	}
+}
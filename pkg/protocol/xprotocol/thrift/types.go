@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package thrift
+
+const (
+	ProtocolName = "thrift"
+)
+
+// the protocols carried by the framed transport
+const (
+	ProtocolTypeBinary  byte = 1
+	ProtocolTypeCompact byte = 2
+)
+
+// message types
+const (
+	MessageTypeCall      byte = 1
+	MessageTypeReply     byte = 2
+	MessageTypeException byte = 3
+	MessageTypeOneway    byte = 4
+)
+
+const (
+	FrameHeaderSize = 4                // the frame length of the framed transport
+	MaxFrameSize    = 16 * 1024 * 1024 // 16M, the default max frame size of apache thrift
+
+	BinaryVersionMask uint32 = 0xffff0000
+	BinaryVersion1    uint32 = 0x80010000
+	BinaryTypeMask    uint32 = 0x000000ff
+
+	CompactProtocolId   byte = 0x82
+	CompactVersion      byte = 1
+	CompactVersionMask  byte = 0x1f
+	CompactTypeShift         = 5
+	CompactTypeBitsMask byte = 0x07
+
+	// MultiplexedSeparator separates the service name and the method name in the message name
+	// written by TMultiplexedProtocol, e.g. "Calculator:add"
+	MultiplexedSeparator = ":"
+)
+
+const (
+	ServiceNameHeader string = "service"
+	MethodNameHeader  string = "method"
+)
+
+// the types of TApplicationException
+const (
+	ExceptionUnknown               uint32 = 0
+	ExceptionUnknownMethod         uint32 = 1
+	ExceptionInvalidMessageType    uint32 = 2
+	ExceptionWrongMethodName       uint32 = 3
+	ExceptionBadSequenceId         uint32 = 4
+	ExceptionMissingResult         uint32 = 5
+	ExceptionInternalError         uint32 = 6
+	ExceptionProtocolError         uint32 = 7
+	ExceptionInvalidTransform      uint32 = 8
+	ExceptionInvalidProtocol       uint32 = 9
+	ExceptionUnsupportedClientType uint32 = 10
+)
+
+// response status, the status of an exception message is ResponseStatusException plus
+// the type of the TApplicationException
+const (
+	ResponseStatusSuccess   uint32 = 0
+	ResponseStatusException uint32 = 1
+)
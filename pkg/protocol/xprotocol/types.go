@@ -49,10 +49,8 @@ var (
 )
 
 // XFrame represents the minimal programmable object of the protocol.
+// The frames of multiplexed protocols should implement Multiplexing as well.
 type XFrame interface {
-	// TODO: make multiplexing optional, and maybe we can support PING-PONG protocol in this framework.
-	Multiplexing
-
 	HeartbeatPredicate
 
 	GetStreamType() StreamType
@@ -78,6 +76,23 @@ type Multiplexing interface {
 	SetRequestId(id uint64)
 }
 
+// PoolMode describes how the upstream connections of the protocol are used
+type PoolMode int
+
+const (
+	// Multiplex protocols send concurrent requests on a single connection, which are distinguished by 'request-id'
+	Multiplex PoolMode = iota
+	// PingPong protocols have no 'request-id', a connection handles one request at a time,
+	// and the responses are paired with the requests by order
+	PingPong
+)
+
+// PoolModer provides the ability to choose the pool mode of the protocol, the protocols that do not
+// implement it are treated as Multiplex
+type PoolModer interface {
+	PoolMode() PoolMode
+}
+
 // HeartbeatPredicate provides the ability to judge if current frame is a heartbeat, which is usually used to make connection keepalive
 type HeartbeatPredicate interface {
 	IsHeartbeatFrame() bool
@@ -96,6 +111,15 @@ type GoAwayPredicate interface {
 	IsGoAwayFrame() bool
 }
 
+// GoAwayer provides the ability to construct the goaway command for xprotocol sub-protocols, the protocols that support
+// GoAwayPredicate can implement it, so that the downstream connections can be drained gracefully.
+// None of the built-in sub-protocols defines a goaway command yet, so their downstream
+// connections are not notified, and they are closed after the drain timeout.
+type GoAwayer interface {
+	// GoAway builds a goaway command
+	GoAway(requestId uint64) XFrame
+}
+
 // XProtocol provides extra ability(Heartbeater, Hijacker) to interacts with the proxy framework based on the Protocol interface.
 // e.g. A request which cannot find route should be responded with a error response like '404 Not Found', that is what Hijacker
 // interface exactly provides.
@@ -124,3 +148,10 @@ type Hijacker interface {
 	// Mapping the http status code, which used by proxy framework into protocol-specific status
 	Mapping(httpStatusCode uint32) uint32
 }
+
+// RequestHijacker provides the ability to construct the response based on the hijacked request, it is preferred to
+// Hijacker if implemented. It is useful for protocols whose response depends on the request, e.g. the method name
+// and the encoding of thrift.
+type RequestHijacker interface {
+	HijackRequest(request XFrame, statusCode uint32) XRespFrame
+}
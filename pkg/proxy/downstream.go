@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"runtime/debug"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -32,12 +33,15 @@ import (
 	mbuffer "mosn.io/mosn/pkg/buffer"
 	"mosn.io/mosn/pkg/config/v2"
 	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/filter/network/tcpproxy"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/protocol/http"
 	"mosn.io/mosn/pkg/router"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
 	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/utils"
 )
@@ -55,14 +59,30 @@ type downStream struct {
 
 	// flow control
 	bufferLimit uint32
+	// the read disabled by the write buffer watermarks, they are resumed when the stream finished
+	flowControlMux         sync.Mutex
+	downstreamReadDisabled bool
+	upstreamReadDisabled   types.Stream
+	// the established tunnel of CONNECT request is read disabled instead of the upstream stream
+	establishedTunnel  *tcpproxy.Tunnel
+	tunnelReadDisabled bool
 
 	// ~~~ control args
 	timeout    Timeout
 	retryState *retryState
 
+	// stream level timeouts, see streamtimeout.go
+	streamTimerMux    sync.Mutex
+	streamIdleTimeout time.Duration
+	streamIdleTimer   *utils.Timer
+	maxDurationTimer  *utils.Timer
+	// the unix nano time of the last activity of the stream
+	lastActive int64
+
 	requestInfo     types.RequestInfo
 	responseSender  types.StreamSender
 	upstreamRequest *upstreamRequest
+	tunnel          *tcpproxy.Tunnel
 	perRetryTimer   *utils.Timer
 	responseTimer   *utils.Timer
 
@@ -98,6 +118,8 @@ type downStream struct {
 	downstreamCleaned uint32
 	upstreamReset     uint32
 	reuseBuffer       uint32
+	// set when the request is received, the stream is processed in the proxy goroutine since then
+	downstreamReceived uint32
 
 	resetReason types.StreamResetReason
 
@@ -107,6 +129,9 @@ type downStream struct {
 	receiverFilters           []*activeStreamReceiverFilter
 	receiverFiltersIndex      int
 	receiverFiltersAgainPhase types.Phase
+	// the phase and state of the receiver filters paused by types.StreamFilterPause
+	receiverFiltersPausePhase types.Phase
+	receiverFiltersState      uint32
 
 	context context.Context
 
@@ -161,6 +186,10 @@ func newActiveStream(ctx context.Context, proxy *proxy, responseSender types.Str
 		requestId := mosnctx.Get(stream.context, types.ContextKeyStreamID)
 		log.Proxy.Debugf(stream.context, "[proxy] [downstream] new stream, proxyId = %d , requestId =%v, oneway=%t", stream.ID, requestId, stream.oneway)
 	}
+
+	stream.markActive()
+	stream.setupStreamTimers()
+
 	return stream
 }
 
@@ -189,6 +218,9 @@ func (s *downStream) cleanStream() {
 
 	s.requestInfo.SetRequestFinishedDuration(time.Now())
 
+	// stop the stream timeouts
+	s.stopStreamTimers()
+
 	// reset corresponding upstream stream
 	if s.upstreamRequest != nil && !s.upstreamProcessDone && !s.oneway {
 		log.Proxy.Errorf(s.context, "[proxy] [downstream] upstreamRequest.resetStream, proxyId: %d", s.ID)
@@ -196,6 +228,19 @@ func (s *downStream) cleanStream() {
 		s.upstreamRequest.resetStream()
 	}
 
+	// close the tunnel of CONNECT request
+	if s.tunnel != nil {
+		s.tunnel.Close()
+	}
+
+	// stop receiving the streaming bodies that are not sent completely
+	if body, ok := s.downstreamReqDataBuf.(types.StreamingBuffer); ok {
+		body.CloseRead()
+	}
+	if body, ok := s.downstreamRespDataBuf.(types.StreamingBuffer); ok {
+		body.CloseRead()
+	}
+
 	// clean up timers
 	s.cleanUp()
 
@@ -220,6 +265,10 @@ func (s *downStream) cleanStream() {
 	// delete stream reference
 	s.delete()
 
+	// resume the reading stopped by the stream
+	s.readDisableUpstream(false)
+	s.readDisableDownstream(false)
+
 	// recycle if no reset events
 	s.giveStream()
 }
@@ -311,7 +360,23 @@ func (s *downStream) OnResetStream(reason types.StreamResetReason) {
 
 	s.resetReason = reason
 
+	// the tunnel cleans the stream when closed
+	if s.tunnel != nil {
+		s.tunnel.Close()
+	}
+
+	// the request is not received yet, no proxy goroutine will clean the stream
+	if atomic.CompareAndSwapUint32(&s.downstreamReceived, 0, 1) {
+		pool.ScheduleAuto(func() {
+			s.ResetStream(reason)
+		})
+		return
+	}
+
 	s.sendNotify()
+
+	// the proxy goroutine exits while the receiver filters are paused, resume it to clean the stream
+	s.resumeReceiving()
 }
 
 func (s *downStream) ResetStream(reason types.StreamResetReason) {
@@ -326,15 +391,26 @@ func (s *downStream) OnDestroyStream() {}
 
 // types.StreamReceiveListener
 func (s *downStream) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	// the stream is reset before the request received
+	if !atomic.CompareAndSwapUint32(&s.downstreamReceived, 0, 1) {
+		return
+	}
+	s.markActive()
+
 	s.downstreamReqHeaders = headers
 	s.downstreamReqDataBuf = data
 	s.downstreamReqTrailers = trailers
+	s.watchStreamingBuffer(data)
 
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(s.context, "[proxy] [downstream] OnReceive headers:%+v, data:%+v, trailers:%+v", headers, data, trailers)
 	}
 
-	id := s.ID
+	s.scheduleReceive(ctx, s.ID, types.InitPhase)
+}
+
+// scheduleReceive runs the proxy process from the phase in a new goroutine
+func (s *downStream) scheduleReceive(ctx context.Context, id uint32, phase types.Phase) {
 	// goroutine for proxy
 	pool.ScheduleAuto(func() {
 		defer func() {
@@ -348,7 +424,6 @@ func (s *downStream) OnReceive(ctx context.Context, headers types.HeaderMap, dat
 			}
 		}()
 
-		phase := types.InitPhase
 		for i := 0; i < 10; i++ {
 			s.cleanNotify()
 
@@ -372,6 +447,15 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 		switch phase {
 		// init phase
 		case types.InitPhase:
+			// the new streams are rejected when the process is overloaded
+			if overload.StopAcceptingRequests.Active() {
+				log.Proxy.Warnf(s.context, "[proxy] [downstream] reject the request by overload, proxyId = %d", id)
+				s.requestInfo.SetResponseFlag(types.OverloadFlag)
+				s.sendHijackReply(types.UpstreamOverFlowCode, s.downstreamReqHeaders)
+				if p, err := s.processError(id); err != nil {
+					return p
+				}
+			}
 			phase++
 
 		// downstream filter before route
@@ -380,6 +464,9 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, proxyId = %d  ", phase, id)
 			}
 			s.runReceiveFilters(phase, s.downstreamReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers)
+			if s.receiverFiltersPausePhase != types.InitPhase {
+				return s.pauseReceiving()
+			}
 
 			if p, err := s.processError(id); err != nil {
 				return p
@@ -403,6 +490,9 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, proxyId = %d  ", phase, id)
 			}
 			s.runReceiveFilters(phase, s.downstreamReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers)
+			if s.receiverFiltersPausePhase != types.InitPhase {
+				return s.pauseReceiving()
+			}
 
 			if p, err := s.processError(id); err != nil {
 				return p
@@ -429,6 +519,9 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, proxyId = %d  ", phase, id)
 			}
 			s.runReceiveFilters(phase, s.downstreamReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers)
+			if s.receiverFiltersPausePhase != types.InitPhase {
+				return s.pauseReceiving()
+			}
 
 			if p, err := s.processError(id); err != nil {
 				return p
@@ -437,6 +530,15 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 
 		// downstream receive header
 		case types.DownRecvHeader:
+			// CONNECT request is not proxied to upstream, the stream is switched to a tunnel
+			if s.tunnel != nil {
+				s.startTunnel(id)
+
+				if p, err := s.processError(id); err != nil {
+					return p
+				}
+				return types.End
+			}
 			if s.downstreamReqHeaders != nil {
 				if log.Proxy.GetLogLevel() >= log.DEBUG {
 					log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, proxyId = %d  ", phase, id)
@@ -705,6 +807,12 @@ func (s *downStream) chooseHost(endStream bool) {
 
 	s.cluster = s.snapshot.ClusterInfo()
 	s.requestInfo.SetRouteEntry(s.route.RouteRule())
+	s.setupRouteStreamTimers()
+
+	if s.isConnectRequest() {
+		s.chooseTunnelHost()
+		return
+	}
 
 	pool, err := s.initializeUpstreamConnectionPool(s)
 	if err != nil {
@@ -724,7 +832,10 @@ func (s *downStream) chooseHost(endStream bool) {
 
 	prot := s.getUpstreamProtocol()
 
-	s.retryState = newRetryState(s.route.RouteRule().Policy().RetryPolicy(), s.downstreamReqHeaders, s.cluster, prot)
+	// the streaming body cannot be sent again, so the request with it is not retried
+	if _, ok := s.downstreamReqDataBuf.(types.StreamingBuffer); !ok {
+		s.retryState = newRetryState(s.route.RouteRule().Policy().RetryPolicy(), s.downstreamReqHeaders, s.cluster, prot)
+	}
 
 	//Build Request
 	proxyBuffers := proxyBuffersByContext(s.context)
@@ -944,6 +1055,7 @@ func (s *downStream) initializeUpstreamConnectionPool(lbCtx types.LoadBalancerCo
 // ~~~ active stream sender wrapper
 
 func (s *downStream) appendHeaders(endStream bool) {
+	s.markActive()
 	s.upstreamProcessDone = endStream
 	headers := s.convertHeader(s.downstreamRespHeaders)
 	//Currently, just log the error
@@ -975,6 +1087,7 @@ func (s *downStream) convertHeader(headers types.HeaderMap) types.HeaderMap {
 }
 
 func (s *downStream) appendData(endStream bool) {
+	s.markActive()
 	s.upstreamProcessDone = endStream
 
 	data := s.convertData(s.downstreamRespDataBuf)
@@ -1005,6 +1118,7 @@ func (s *downStream) convertData(data types.IoBuffer) types.IoBuffer {
 }
 
 func (s *downStream) appendTrailers() {
+	s.markActive()
 	s.upstreamProcessDone = true
 	trailers := s.convertTrailer(s.downstreamRespTrailers)
 	s.responseSender.AppendTrailers(s.context, trailers)
@@ -1151,8 +1265,10 @@ func (s *downStream) finishTracing() {
 		span := trace.SpanFromContext(s.context)
 
 		if span != nil {
-			span.SetRequestInfo(s.requestInfo)
-			span.FinishSpan()
+			if s.tracingSampled() {
+				span.SetRequestInfo(s.requestInfo)
+				span.FinishSpan()
+			}
 
 			if mosnctx.Get(s.context, types.ContextKeyListenerType) == v2.INGRESS {
 				trace.DeleteSpanIdGenerator(mosnctx.Get(s.context, types.ContextKeyTraceSpanKey).(*trace.SpanKey))
@@ -1163,6 +1279,12 @@ func (s *downStream) finishTracing() {
 	}
 }
 
+// tracingSampled returns false if the stream filters, such as the request id filter, decide not to trace the request
+func (s *downStream) tracingSampled() bool {
+	sampled, err := variable.GetVariableValue(s.context, types.VarRequestIDSampled)
+	return err != nil || sampled != "false"
+}
+
 func (s *downStream) onUpstreamTrailers() {
 	s.onUpstreamResponseRecvFinished()
 
@@ -1182,6 +1304,8 @@ func (s *downStream) onUpstreamResponseRecvFinished() {
 
 func (s *downStream) setupRetry(endStream bool) bool {
 	s.upstreamRequest.setupRetry = true
+	// the upstream stream is replaced by the retry
+	s.readDisableUpstream(false)
 
 	if !endStream {
 		s.upstreamRequest.resetStream()
@@ -1314,6 +1438,51 @@ func (s *downStream) setBufferLimit(bufferLimit uint32) {
 	// todo
 }
 
+// readDisableUpstream stops or resumes reading the upstream response,
+// it is called when the downstream connection crosses the write buffer watermarks.
+func (s *downStream) readDisableUpstream(disable bool) {
+	s.flowControlMux.Lock()
+	defer s.flowControlMux.Unlock()
+
+	if !disable {
+		if s.upstreamReadDisabled != nil {
+			s.upstreamReadDisabled.ReadDisable(false)
+			s.upstreamReadDisabled = nil
+		}
+		if s.tunnelReadDisabled {
+			s.establishedTunnel.ReadDisableUpstream(false)
+			s.tunnelReadDisabled = false
+		}
+		return
+	}
+	if s.establishedTunnel != nil {
+		if !s.tunnelReadDisabled {
+			s.tunnelReadDisabled = true
+			s.establishedTunnel.ReadDisableUpstream(true)
+		}
+		return
+	}
+	if s.upstreamReadDisabled != nil || s.upstreamRequest == nil || s.upstreamRequest.requestSender == nil {
+		return
+	}
+	// keep the disabled stream, the same one should be resumed
+	s.upstreamReadDisabled = s.upstreamRequest.requestSender.GetStream()
+	s.upstreamReadDisabled.ReadDisable(true)
+}
+
+// readDisableDownstream stops or resumes reading the downstream request,
+// it is called when the upstream connection crosses the write buffer watermarks.
+func (s *downStream) readDisableDownstream(disable bool) {
+	s.flowControlMux.Lock()
+	defer s.flowControlMux.Unlock()
+
+	if s.downstreamReadDisabled == disable {
+		return
+	}
+	s.downstreamReadDisabled = disable
+	s.proxy.ReadDisableDownstream(disable)
+}
+
 func (s *downStream) AddStreamReceiverFilter(filter api.StreamReceiverFilter, p api.FilterPhase) {
 	var phase types.Phase
 	switch p {
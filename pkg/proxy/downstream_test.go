@@ -18,12 +18,15 @@
 package proxy
 
 import (
+	"container/list"
 	"context"
+	"net"
 	"testing"
 	"time"
 
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/tcpproxy"
 	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/trace"
@@ -315,3 +318,77 @@ func TestProcessError(t *testing.T) {
 		t.Errorf("TestprocessError Error")
 	}
 }
+
+func TestWatermarkFlowControl(t *testing.T) {
+	conn := &mockFlowControlConnection{}
+	p := &proxy{
+		activeSteams:  list.New(),
+		readCallbacks: &mockFlowControlCallbacks{conn: conn},
+	}
+	s := &downStream{proxy: p}
+	s.element = p.activeSteams.PushBack(s)
+	upstream := &mockFlowControlStream{}
+	s.upstreamRequest = &upstreamRequest{
+		downStream:    s,
+		proxy:         p,
+		requestSender: &mockFlowControlSender{stream: upstream},
+	}
+
+	// the downstream connection is above the high watermark, stops reading the upstream
+	dc := &downstreamCallbacks{proxy: p}
+	dc.OnAboveWriteBufferHighWatermark()
+	// the stream is disabled only once
+	s.readDisableUpstream(true)
+	if upstream.readDisabled != 1 || !p.isDownstreamAboveHighWatermark() {
+		t.Fatalf("upstream should be read disabled once, but got %d", upstream.readDisabled)
+	}
+	dc.OnBelowWriteBufferLowWatermark()
+	if upstream.readDisabled != 0 || p.isDownstreamAboveHighWatermark() {
+		t.Fatalf("upstream should be read enabled, but got %d", upstream.readDisabled)
+	}
+
+	// the upstream connection is above the high watermark, stops reading the downstream
+	s.upstreamRequest.OnAboveWriteBufferHighWatermark()
+	s.upstreamRequest.OnAboveWriteBufferHighWatermark()
+	if conn.readDisabled != 1 {
+		t.Fatalf("downstream should be read disabled once, but got %d", conn.readDisabled)
+	}
+
+	// the stream is retried with a new upstream stream, the disabled one is resumed
+	dc.OnAboveWriteBufferHighWatermark()
+	s.readDisableUpstream(false)
+	s.upstreamRequest.requestSender = &mockFlowControlSender{stream: &mockFlowControlStream{}}
+	if upstream.readDisabled != 0 {
+		t.Fatalf("the replaced upstream should be read enabled, but got %d", upstream.readDisabled)
+	}
+
+	// all the reading is resumed when the stream finished
+	s.readDisableUpstream(false)
+	s.readDisableDownstream(false)
+	if conn.readDisabled != 0 {
+		t.Fatalf("downstream should be read enabled, but got %d", conn.readDisabled)
+	}
+}
+
+func TestWatermarkFlowControlTunnel(t *testing.T) {
+	p := &proxy{
+		activeSteams: list.New(),
+	}
+	s := &downStream{proxy: p}
+	s.element = p.activeSteams.PushBack(s)
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	upstream := network.NewClientConnection(nil, time.Second, nil, addr, nil)
+	s.establishedTunnel = tcpproxy.NewTunnel(upstream, nil, network.NewRequestInfo())
+
+	// the upstream connection of the tunnel is read disabled only once
+	dc := &downstreamCallbacks{proxy: p}
+	dc.OnAboveWriteBufferHighWatermark()
+	s.readDisableUpstream(true)
+	if upstream.ReadEnabled() {
+		t.Fatal("the tunnel upstream should be read disabled")
+	}
+	dc.OnBelowWriteBufferLowWatermark()
+	if !upstream.ReadEnabled() {
+		t.Fatal("the tunnel upstream should be read enabled")
+	}
+}
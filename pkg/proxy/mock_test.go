@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"mosn.io/api"
+	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/types"
 )
@@ -97,6 +98,15 @@ func (c *mockRouteRule) FinalizeResponseHeaders(headers api.HeaderMap, requestIn
 	return
 }
 
+type mockStreamTimeoutRouteRule struct {
+	mockRouteRule
+	config v2.StreamTimeoutConfig
+}
+
+func (r *mockStreamTimeoutRouteRule) StreamTimeoutConfig() v2.StreamTimeoutConfig {
+	return r.config
+}
+
 type mockDirectRule struct {
 	status int
 	body   string
@@ -158,6 +168,48 @@ func (s *mockStream) ResetStream(reason types.StreamResetReason) {
 	// do nothing
 }
 
+// mockFlowControlStream records the read disable calls
+type mockFlowControlStream struct {
+	types.Stream
+	readDisabled int
+}
+
+func (s *mockFlowControlStream) ReadDisable(disable bool) {
+	if disable {
+		s.readDisabled++
+	} else {
+		s.readDisabled--
+	}
+}
+
+type mockFlowControlSender struct {
+	mockResponseSender
+	stream *mockFlowControlStream
+}
+
+func (s *mockFlowControlSender) GetStream() types.Stream {
+	return s.stream
+}
+
+// mockResetSender records the reset reason of the stream
+type mockResetSender struct {
+	mockResponseSender
+	reason chan types.StreamResetReason
+}
+
+func (s *mockResetSender) GetStream() types.Stream {
+	return &mockResetStream{reason: s.reason}
+}
+
+type mockResetStream struct {
+	types.Stream
+	reason chan types.StreamResetReason
+}
+
+func (s *mockResetStream) ResetStream(reason types.StreamResetReason) {
+	s.reason <- reason
+}
+
 type mockReadFilterCallbacks struct {
 	api.ReadFilterCallbacks
 }
@@ -184,6 +236,29 @@ func (c *mockConnection) RemoteAddr() net.Addr {
 	return addr
 }
 
+type mockFlowControlCallbacks struct {
+	api.ReadFilterCallbacks
+	conn *mockFlowControlConnection
+}
+
+func (cb *mockFlowControlCallbacks) Connection() api.Connection {
+	return cb.conn
+}
+
+// mockFlowControlConnection records the read disable calls
+type mockFlowControlConnection struct {
+	mockConnection
+	readDisabled int
+}
+
+func (c *mockFlowControlConnection) SetReadDisable(disable bool) {
+	if disable {
+		c.readDisabled++
+	} else {
+		c.readDisabled--
+	}
+}
+
 type mockTracer struct {
 }
 
@@ -237,6 +312,11 @@ func (s *mockSpan) SpawnChild(operationName string, startTime time.Time) types.S
 
 type mockServerConn struct {
 	types.ServerStreamConnection
+	goAway int
+}
+
+func (s *mockServerConn) GoAway() {
+	s.goAway++
 }
 
 func (s *mockServerConn) Protocol() api.Protocol {
@@ -91,6 +91,13 @@ type proxy struct {
 	stats              *Stats
 	listenerStats      *Stats
 	accessLogs         []api.AccessLog
+	// set when the downstream connection is above the write buffer high watermark
+	downstreamAboveHighWatermark uint32
+	// the stream timeouts configured in the listener
+	streamTimeout v2.StreamTimeoutConfig
+	// draining is set when the downstream connection is drained, drainMux protects it with serverStreamConn
+	drainMux sync.Mutex
+	draining bool
 }
 
 // NewProxy create proxy instance for given v2.Proxy config
@@ -115,10 +122,18 @@ func NewProxy(ctx context.Context, config *v2.Proxy) Proxy {
 		} else {
 			log.DefaultLogger.Tracef("[proxy] extend config subprotocol is empty")
 		}
+
+		var proxyGeneralExtendConfig v2.ProxyGeneralExtendConfig
+		json.Unmarshal([]byte(extJSON), &proxyGeneralExtendConfig)
+		proxy.context = mosnctx.WithValue(proxy.context, types.ContextKeyProxyGeneralConfig, proxyGeneralExtendConfig)
 	} else {
 		log.DefaultLogger.Errorf("[proxy] get proxy extend config fail = %v", err)
 	}
 
+	if config, ok := mosnctx.Get(ctx, types.ContextKeyStreamTimeoutConfig).(v2.StreamTimeoutConfig); ok {
+		proxy.streamTimeout = config
+	}
+
 	listenerName := mosnctx.Get(ctx, types.ContextKeyListenerName).(string)
 	proxy.listenerStats = newListenerStats(listenerName)
 
@@ -156,7 +171,7 @@ func (p *proxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
 			return api.Stop
 		}
 		log.DefaultLogger.Debugf("[proxy] Protoctol Auto: %v", protocol)
-		p.serverStreamConn = stream.CreateServerStreamConnection(p.context, protocol, p.readCallbacks.Connection(), p)
+		p.setServerStreamConn(stream.CreateServerStreamConnection(p.context, protocol, p.readCallbacks.Connection(), p))
 	}
 	p.serverStreamConn.Dispatch(buf)
 
@@ -184,12 +199,36 @@ func (p *proxy) onDownstreamEvent(event api.ConnectionEvent) {
 	}
 }
 
+// ReadDisableUpstream stops or resumes reading the upstream responses of all the active streams
 func (p *proxy) ReadDisableUpstream(disable bool) {
-	// TODO
+	p.asMux.RLock()
+	defer p.asMux.RUnlock()
+
+	for urEle := p.activeSteams.Front(); urEle != nil; urEle = urEle.Next() {
+		ds := urEle.Value.(*downStream)
+		ds.readDisableUpstream(disable)
+	}
 }
 
+// ReadDisableDownstream stops or resumes reading the downstream connection
 func (p *proxy) ReadDisableDownstream(disable bool) {
-	// TODO
+	if p.readCallbacks != nil {
+		p.readCallbacks.Connection().SetReadDisable(disable)
+	}
+}
+
+func (p *proxy) isDownstreamAboveHighWatermark() bool {
+	return atomic.LoadUint32(&p.downstreamAboveHighWatermark) == 1
+}
+
+func (p *proxy) onDownstreamAboveWriteBufferHighWatermark() {
+	atomic.StoreUint32(&p.downstreamAboveHighWatermark, 1)
+	p.ReadDisableUpstream(true)
+}
+
+func (p *proxy) onDownstreamBelowWriteBufferLowWatermark() {
+	atomic.StoreUint32(&p.downstreamAboveHighWatermark, 0)
+	p.ReadDisableUpstream(false)
 }
 
 func (p *proxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
@@ -205,7 +244,33 @@ func (p *proxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
 
 	p.readCallbacks.Connection().AddConnectionEventListener(p.downstreamListener)
 	if p.config.DownstreamProtocol != string(protocol.Auto) {
-		p.serverStreamConn = stream.CreateServerStreamConnection(p.context, types.ProtocolName(p.config.DownstreamProtocol), p.readCallbacks.Connection(), p)
+		p.setServerStreamConn(stream.CreateServerStreamConnection(p.context, types.ProtocolName(p.config.DownstreamProtocol), p.readCallbacks.Connection(), p))
+	}
+}
+
+// setServerStreamConn sets the server stream connection, it goes away at once if the connection is draining
+func (p *proxy) setServerStreamConn(sc types.ServerStreamConnection) {
+	p.drainMux.Lock()
+	defer p.drainMux.Unlock()
+
+	p.serverStreamConn = sc
+	if p.draining && sc != nil {
+		sc.GoAway()
+	}
+}
+
+// OnDrain implements types.DrainListener, the downstream is asked to go away by the protocol.
+// If the protocol is not detected yet, the stream connection goes away when it is created.
+func (p *proxy) OnDrain() {
+	p.drainMux.Lock()
+	defer p.drainMux.Unlock()
+
+	if p.draining {
+		return
+	}
+	p.draining = true
+	if p.serverStreamConn != nil {
+		p.serverStreamConn.GoAway()
 	}
 }
 
@@ -267,6 +332,7 @@ func (p *proxy) deleteActiveStream(s *downStream) {
 }
 
 // ConnectionEventListener
+// types.WatermarkListener
 type downstreamCallbacks struct {
 	proxy *proxy
 }
@@ -274,3 +340,11 @@ type downstreamCallbacks struct {
 func (dc *downstreamCallbacks) OnEvent(event api.ConnectionEvent) {
 	dc.proxy.onDownstreamEvent(event)
 }
+
+func (dc *downstreamCallbacks) OnAboveWriteBufferHighWatermark() {
+	dc.proxy.onDownstreamAboveWriteBufferHighWatermark()
+}
+
+func (dc *downstreamCallbacks) OnBelowWriteBufferLowWatermark() {
+	dc.proxy.onDownstreamBelowWriteBufferLowWatermark()
+}
@@ -0,0 +1,43 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"testing"
+)
+
+func TestProxyDrain(t *testing.T) {
+	// the stream connection goes away when the proxy is drained
+	sc := &mockServerConn{}
+	p := &proxy{}
+	p.setServerStreamConn(sc)
+	p.OnDrain()
+	p.OnDrain()
+	if sc.goAway != 1 {
+		t.Errorf("stream connection should go away once, but got %d", sc.goAway)
+	}
+
+	// the protocol is not detected when the proxy is drained
+	sc = &mockServerConn{}
+	p = &proxy{}
+	p.OnDrain()
+	p.setServerStreamConn(sc)
+	if sc.goAway != 1 {
+		t.Errorf("stream connection should go away when it is created, but got %d", sc.goAway)
+	}
+}
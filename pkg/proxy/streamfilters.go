@@ -21,6 +21,7 @@ import (
 	"sync/atomic"
 
 	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
 )
@@ -51,6 +52,11 @@ func (s *downStream) runReceiveFilters(p types.Phase, headers types.HeaderMap, d
 		switch status {
 		case api.StreamFilterStop:
 			return true
+		case types.StreamFilterPause:
+			// the paused filter is done, the filter chain is resumed from the next one
+			s.receiverFiltersIndex++
+			s.receiverFiltersPausePhase = p
+			return true
 		case api.StreamFilterReMatchRoute:
 			// Retry only at the DownFilterAfterRoute phase
 			if p == types.DownFilterAfterRoute {
@@ -77,6 +83,59 @@ func (s *downStream) runReceiveFilters(p types.Phase, headers types.HeaderMap, d
 	return false
 }
 
+// the states of the receiver filters paused by types.StreamFilterPause
+const (
+	receiverFiltersRunning uint32 = iota
+	// the proxy goroutine exits, the filters are resumed in a new goroutine
+	receiverFiltersPaused
+	// the filter continues before the proxy goroutine exits, the goroutine runs the rest filters itself
+	receiverFiltersContinued
+)
+
+// pauseReceiving is called in the proxy goroutine when a receiver filter pauses, it returns
+// types.End to exit the goroutine, or the paused phase if the filter has continued already.
+func (s *downStream) pauseReceiving() types.Phase {
+	if atomic.CompareAndSwapUint32(&s.receiverFiltersState, receiverFiltersRunning, receiverFiltersPaused) {
+		return types.End
+	}
+	atomic.StoreUint32(&s.receiverFiltersState, receiverFiltersRunning)
+	return s.resumePhase()
+}
+
+// continueReceiving is called by the paused filter
+func (s *downStream) continueReceiving(id uint32) {
+	if s.ID != id {
+		return
+	}
+	if atomic.CompareAndSwapUint32(&s.receiverFiltersState, receiverFiltersRunning, receiverFiltersContinued) {
+		return
+	}
+	s.resumeReceiving()
+}
+
+// resumeReceiving runs the rest receiver filters in a new goroutine if they are paused
+func (s *downStream) resumeReceiving() {
+	if !atomic.CompareAndSwapUint32(&s.receiverFiltersState, receiverFiltersPaused, receiverFiltersRunning) {
+		return
+	}
+	phase := s.resumePhase()
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.context, "[proxy] [downstream] resume the receiver filters at phase %d, proxyId = %d", phase, s.ID)
+	}
+	s.scheduleReceive(s.context, s.ID, phase)
+}
+
+// resumePhase returns the phase to resume the paused receiver filters
+func (s *downStream) resumePhase() types.Phase {
+	phase := s.receiverFiltersPausePhase
+	s.receiverFiltersPausePhase = types.InitPhase
+	// the paused filter has sent a hijack reply, the rest filters are skipped
+	if s.directResponse {
+		s.receiverFiltersIndex = len(s.receiverFilters)
+	}
+	return phase
+}
+
 type activeStreamFilter struct {
 	activeStream *downStream
 }
@@ -94,11 +153,13 @@ func (f *activeStreamFilter) RequestInfo() types.RequestInfo {
 }
 
 // types.StreamReceiverFilter
-// types.StreamReceiverFilterHandler
+// types.ResumableStreamReceiverFilterHandler
 type activeStreamReceiverFilter struct {
 	p types.Phase
 	activeStreamFilter
 	filter api.StreamReceiverFilter
+	// the id of the stream that the filter belongs to
+	id uint32
 }
 
 func newActiveStreamReceiverFilter(activeStream *downStream,
@@ -109,6 +170,7 @@ func newActiveStreamReceiverFilter(activeStream *downStream,
 		},
 		filter: filter,
 		p:      p,
+		id:     activeStream.ID,
 	}
 	filter.SetReceiveFilterHandler(f)
 
@@ -146,6 +208,11 @@ func (f *activeStreamReceiverFilter) SendDirectResponse(headers types.HeaderMap,
 	f.activeStream.directResponse = true
 }
 
+// ContinueReceiving resumes the receiver filters paused by types.StreamFilterPause
+func (f *activeStreamReceiverFilter) ContinueReceiving() {
+	f.activeStream.continueReceiving(f.id)
+}
+
 func (f *activeStreamReceiverFilter) SetConvert(on bool) {
 	f.activeStream.noConvert = !on
 }
@@ -204,6 +271,13 @@ func (f *activeStreamReceiverFilter) SetRequestData(data types.IoBuffer) {
 	if f.activeStream.downstreamReqDataBuf == data {
 		return
 	}
+	// the streaming body cannot be copied before it is received completely, so it is replaced
+	if isStreamingBuffer(f.activeStream.downstreamReqDataBuf) || isStreamingBuffer(data) {
+		replaceStreamingBuffer(f.activeStream.downstreamReqDataBuf, data)
+		f.activeStream.downstreamReqDataBuf = data
+		f.activeStream.watchStreamingBuffer(data)
+		return
+	}
 	if f.activeStream.downstreamReqDataBuf == nil {
 		f.activeStream.downstreamReqDataBuf = buffer.NewIoBuffer(0)
 	}
@@ -236,6 +310,13 @@ func (f *activeStreamSenderFilter) SetResponseData(data types.IoBuffer) {
 	if f.activeStream.downstreamRespDataBuf == data {
 		return
 	}
+	// the streaming body cannot be copied before it is received completely, so it is replaced
+	if isStreamingBuffer(f.activeStream.downstreamRespDataBuf) || isStreamingBuffer(data) {
+		replaceStreamingBuffer(f.activeStream.downstreamRespDataBuf, data)
+		f.activeStream.downstreamRespDataBuf = data
+		f.activeStream.watchStreamingBuffer(data)
+		return
+	}
 	if f.activeStream.downstreamRespDataBuf == nil {
 		f.activeStream.downstreamRespDataBuf = buffer.NewIoBuffer(0)
 	}
@@ -250,3 +331,16 @@ func (f *activeStreamSenderFilter) GetResponseTrailers() types.HeaderMap {
 func (f *activeStreamSenderFilter) SetResponseTrailers(trailers types.HeaderMap) {
 	f.activeStream.downstreamRespTrailers = trailers
 }
+
+func isStreamingBuffer(data types.IoBuffer) bool {
+	_, ok := data.(types.StreamingBuffer)
+	return ok
+}
+
+// replaceStreamingBuffer stops receiving the original streaming body if it is replaced by a buffered one,
+// the streaming body wrapped by the new one, such as the compressed body, is still received.
+func replaceStreamingBuffer(origin, data types.IoBuffer) {
+	if body, ok := origin.(types.StreamingBuffer); ok && !isStreamingBuffer(data) {
+		body.CloseRead()
+	}
+}
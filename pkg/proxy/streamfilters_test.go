@@ -163,6 +163,118 @@ func TestRunReiverFiltersStop(t *testing.T) {
 	}
 }
 
+// mockPauseReceiverFilter pauses the filter chain, and continues it in another goroutine
+type mockPauseReceiverFilter struct {
+	handler types.ResumableStreamReceiverFilterHandler
+	// continue before OnReceive returns
+	sync bool
+	// send hijack reply before continue
+	hijack bool
+	s      *downStream
+}
+
+func (f *mockPauseReceiverFilter) OnDestroy() {}
+
+func (f *mockPauseReceiverFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	resume := func() {
+		if f.hijack {
+			f.handler.SendHijackReply(403, headers)
+			// stop the process after the hijack
+			atomic.StoreUint32(&f.s.downstreamCleaned, 1)
+		}
+		f.handler.ContinueReceiving()
+	}
+	if f.sync {
+		resume()
+	} else {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			resume()
+		}()
+	}
+	return types.StreamFilterPause
+}
+
+func (f *mockPauseReceiverFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler.(types.ResumableStreamReceiverFilterHandler)
+}
+
+// notifyReceiverFilter notifies the channel after the filter is called
+type notifyReceiverFilter struct {
+	*mockStreamReceiverFilter
+	called chan struct{}
+}
+
+func (f *notifyReceiverFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	status := f.mockStreamReceiverFilter.OnReceive(ctx, headers, buf, trailers)
+	f.called <- struct{}{}
+	return status
+}
+
+func TestRunReiverFiltersPause(t *testing.T) {
+	testCases := []struct {
+		pause  *mockPauseReceiverFilter
+		called int
+	}{
+		{
+			pause:  &mockPauseReceiverFilter{},
+			called: 1,
+		},
+		{
+			pause:  &mockPauseReceiverFilter{sync: true},
+			called: 1,
+		},
+		{
+			pause:  &mockPauseReceiverFilter{hijack: true},
+			called: 0,
+		},
+	}
+	for i, tc := range testCases {
+		s := &downStream{
+			proxy: &proxy{
+				routersWrapper: &mockRouterWrapper{},
+				clusterManager: &mockClusterManager{},
+			},
+			requestInfo: &network.RequestInfo{},
+			notify:      make(chan struct{}, 1),
+			context:     context.Background(),
+		}
+		tc.pause.s = s
+		next := &notifyReceiverFilter{
+			mockStreamReceiverFilter: &mockStreamReceiverFilter{
+				status: api.StreamFilterStop,
+				phase:  api.AfterRoute,
+				s:      s,
+			},
+			called: make(chan struct{}, 2),
+		}
+		s.AddStreamReceiverFilter(tc.pause, api.AfterRoute)
+		s.AddStreamReceiverFilter(next, api.AfterRoute)
+		// mock run
+		s.downstreamReqHeaders = protocol.CommonHeader{}
+		s.OnReceive(s.context, s.downstreamReqHeaders, nil, nil)
+
+		// the filter is called in the resumed goroutine, the count is read after the notification
+		called := 0
+		timeout := time.After(100 * time.Millisecond)
+	wait:
+		for {
+			select {
+			case <-next.called:
+				called++
+			case <-timeout:
+				break wait
+			}
+		}
+		if called != tc.called || next.on != tc.called {
+			t.Errorf("#%d the filter after the paused one is called %d times, expected %d", i, called, tc.called)
+		}
+		if atomic.LoadUint32(&s.receiverFiltersState) != receiverFiltersRunning {
+			t.Errorf("#%d the paused state is not cleaned", i)
+		}
+	}
+}
+
 func TestRunReiverFilterHandler(t *testing.T) {
 	testCases := []struct {
 		filters []*mockStreamReceiverFilter
@@ -374,3 +486,40 @@ func (f *mockStreamSenderFilter) Append(ctx context.Context, headers types.Heade
 func (f *mockStreamSenderFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
 	f.handler = handler
 }
+
+type mockStreamingBuffer struct {
+	types.IoBuffer
+	closed   bool
+	listener func()
+}
+
+func (b *mockStreamingBuffer) CloseRead() {
+	b.closed = true
+}
+
+func (b *mockStreamingBuffer) SetActiveListener(listener func()) {
+	b.listener = listener
+}
+
+func TestSetStreamingData(t *testing.T) {
+	s := &downStream{}
+	f := &activeStreamSenderFilter{
+		activeStreamFilter: activeStreamFilter{
+			activeStream: s,
+		},
+	}
+	// the streaming body is replaced instead of copied
+	origin := &mockStreamingBuffer{IoBuffer: buffer.NewIoBuffer(0)}
+	s.downstreamRespDataBuf = origin
+	wrapped := &mockStreamingBuffer{IoBuffer: buffer.NewIoBuffer(0)}
+	f.SetResponseData(wrapped)
+	if s.downstreamRespDataBuf != wrapped || origin.closed {
+		t.Error("the streaming body should be replaced by the wrapped one")
+	}
+	// the streaming body replaced by a buffered one is not received any more
+	data := buffer.NewIoBufferString("data")
+	f.SetResponseData(data)
+	if s.downstreamRespDataBuf != data || !wrapped.closed {
+		t.Error("the streaming body should be closed after replaced by a buffered one")
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"sync/atomic"
+	"time"
+
+	"mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+// streamTimeoutConfig returns the stream timeouts of the downstream, the route's config overrides the listener's
+func (s *downStream) streamTimeoutConfig() v2.StreamTimeoutConfig {
+	config := s.proxy.streamTimeout
+	if s.route == nil || s.route.RouteRule() == nil {
+		return config
+	}
+	if rule, ok := s.route.RouteRule().(types.StreamTimeoutRouteRule); ok {
+		routeConfig := rule.StreamTimeoutConfig()
+		if routeConfig.StreamIdleTimeout != nil {
+			config.StreamIdleTimeout = routeConfig.StreamIdleTimeout
+		}
+		if routeConfig.MaxStreamDuration != nil {
+			config.MaxStreamDuration = routeConfig.MaxStreamDuration
+		}
+	}
+	return config
+}
+
+// setupStreamTimers starts the stream idle timer and the max stream duration timer.
+// it is called when the stream is created, and called again when the route is matched.
+func (s *downStream) setupStreamTimers() {
+	config := s.streamTimeoutConfig()
+
+	s.streamTimerMux.Lock()
+	defer s.streamTimerMux.Unlock()
+
+	if atomic.LoadUint32(&s.downstreamCleaned) == 1 {
+		return
+	}
+
+	s.streamIdleTimer.Stop()
+	s.streamIdleTimer = nil
+	s.streamIdleTimeout = 0
+	if config.StreamIdleTimeout != nil && config.StreamIdleTimeout.Duration > 0 {
+		s.streamIdleTimeout = config.StreamIdleTimeout.Duration
+		s.streamIdleTimer = s.newStreamTimer(s.streamIdleTimeout, s.onStreamIdleTimeout)
+	}
+
+	s.maxDurationTimer.Stop()
+	s.maxDurationTimer = nil
+	if config.MaxStreamDuration != nil && config.MaxStreamDuration.Duration > 0 {
+		// the duration counts from the stream created
+		remain := config.MaxStreamDuration.Duration - time.Since(s.requestInfo.StartTime())
+		s.maxDurationTimer = s.newStreamTimer(remain, s.onMaxStreamDuration)
+	}
+}
+
+// setupRouteStreamTimers restarts the stream timers if the matched route configures them
+func (s *downStream) setupRouteStreamTimers() {
+	if rule, ok := s.route.RouteRule().(types.StreamTimeoutRouteRule); ok {
+		config := rule.StreamTimeoutConfig()
+		if config.StreamIdleTimeout != nil || config.MaxStreamDuration != nil {
+			s.setupStreamTimers()
+		}
+	}
+}
+
+func (s *downStream) newStreamTimer(d time.Duration, onTimeout func()) *utils.Timer {
+	ID := s.ID
+	return utils.NewTimer(d, func() {
+		atomic.StoreUint32(&s.reuseBuffer, 0)
+
+		if atomic.LoadUint32(&s.downstreamCleaned) == 1 {
+			return
+		}
+		if ID != s.ID {
+			return
+		}
+		onTimeout()
+	})
+}
+
+// stopStreamIdleTimer stops the idle timer, the idle timeout is not checked any more
+func (s *downStream) stopStreamIdleTimer() {
+	s.streamTimerMux.Lock()
+	defer s.streamTimerMux.Unlock()
+
+	s.streamIdleTimer.Stop()
+	s.streamIdleTimer = nil
+}
+
+// stopStreamTimers stops the stream timers when the stream is cleaned
+func (s *downStream) stopStreamTimers() {
+	s.streamTimerMux.Lock()
+	defer s.streamTimerMux.Unlock()
+
+	s.streamIdleTimer.Stop()
+	s.streamIdleTimer = nil
+	s.maxDurationTimer.Stop()
+	s.maxDurationTimer = nil
+}
+
+// markActive records the activity of the stream, such as receiving or sending headers and data
+func (s *downStream) markActive() {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+}
+
+// watchStreamingBuffer marks the stream active when the streaming body is written or read,
+// as the streaming bodies are transferred by the stream layer out of the proxy
+func (s *downStream) watchStreamingBuffer(data types.IoBuffer) {
+	if body, ok := data.(types.StreamingBuffer); ok {
+		body.SetActiveListener(s.markActive)
+	}
+}
+
+// lastActiveTime returns the last activity time of the stream, it is called by the idle timer
+func (s *downStream) lastActiveTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastActive))
+}
+
+func (s *downStream) onStreamIdleTimeout() {
+	s.streamTimerMux.Lock()
+	if s.streamIdleTimer == nil || atomic.LoadUint32(&s.downstreamCleaned) == 1 {
+		s.streamTimerMux.Unlock()
+		return
+	}
+	// the stream is active during the timeout, check it again later
+	if idle := time.Since(s.lastActiveTime()); idle < s.streamIdleTimeout {
+		s.streamIdleTimer = s.newStreamTimer(s.streamIdleTimeout-idle, s.onStreamIdleTimeout)
+		s.streamTimerMux.Unlock()
+		return
+	}
+	s.streamIdleTimer = nil
+	s.streamTimerMux.Unlock()
+
+	log.Proxy.Warnf(s.context, "[proxy] [downstream] stream idle timeout %s, proxyId = %d", s.streamIdleTimeout, s.ID)
+	s.requestInfo.SetResponseFlag(types.StreamIdleTimeoutFlag)
+	s.resetDownstream(types.StreamIdleTimeout)
+}
+
+func (s *downStream) onMaxStreamDuration() {
+	log.Proxy.Warnf(s.context, "[proxy] [downstream] stream reaches the max duration, proxyId = %d", s.ID)
+	s.requestInfo.SetResponseFlag(types.StreamMaxDurationFlag)
+	s.resetDownstream(types.StreamMaxDurationTimeout)
+}
+
+// resetDownstream resets the downstream stream by the stream layer, the stream is cleaned in OnResetStream
+func (s *downStream) resetDownstream(reason types.StreamResetReason) {
+	if s.responseSender != nil {
+		s.responseSender.GetStream().ResetStream(reason)
+	} else {
+		s.OnResetStream(reason)
+	}
+}
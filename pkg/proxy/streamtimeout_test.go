@@ -0,0 +1,209 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+func newTimeoutStream(config v2.StreamTimeoutConfig, route api.Route) (*downStream, chan types.StreamResetReason) {
+	reason := make(chan types.StreamResetReason, 1)
+	s := &downStream{
+		ID: 1,
+		proxy: &proxy{
+			streamTimeout: config,
+		},
+		route:          route,
+		context:        context.Background(),
+		requestInfo:    network.NewRequestInfo(),
+		responseSender: &mockResetSender{reason: reason},
+	}
+	s.markActive()
+	return s, reason
+}
+
+func TestStreamTimeoutConfig(t *testing.T) {
+	listenerConfig := v2.StreamTimeoutConfig{
+		StreamIdleTimeout: &api.DurationConfig{Duration: time.Second},
+		MaxStreamDuration: &api.DurationConfig{Duration: time.Minute},
+	}
+	s, _ := newTimeoutStream(listenerConfig, nil)
+	if config := s.streamTimeoutConfig(); config.StreamIdleTimeout.Duration != time.Second || config.MaxStreamDuration.Duration != time.Minute {
+		t.Fatalf("listener config expected, but got %+v", config)
+	}
+
+	// the route overrides the listener's config
+	s.route = &mockRoute{
+		rule: &mockStreamTimeoutRouteRule{
+			config: v2.StreamTimeoutConfig{
+				StreamIdleTimeout: &api.DurationConfig{Duration: 0},
+			},
+		},
+	}
+	if config := s.streamTimeoutConfig(); config.StreamIdleTimeout.Duration != 0 || config.MaxStreamDuration.Duration != time.Minute {
+		t.Fatalf("route config expected, but got %+v", config)
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	s, reason := newTimeoutStream(v2.StreamTimeoutConfig{
+		StreamIdleTimeout: &api.DurationConfig{Duration: 100 * time.Millisecond},
+	}, nil)
+	s.setupStreamTimers()
+
+	// the active stream is not reset
+	for i := 0; i < 5; i++ {
+		time.Sleep(50 * time.Millisecond)
+		s.markActive()
+	}
+	select {
+	case r := <-reason:
+		t.Fatalf("active stream is reset: %s", r)
+	default:
+	}
+
+	select {
+	case r := <-reason:
+		if r != types.StreamIdleTimeout {
+			t.Errorf("reset reason should be idle timeout, but got %s", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("idle stream should be reset")
+	}
+	if !s.requestInfo.GetResponseFlag(types.StreamIdleTimeoutFlag) {
+		t.Error("response flag of idle timeout should be set")
+	}
+}
+
+func TestStreamIdleTimeoutWithStreamingBody(t *testing.T) {
+	s, reason := newTimeoutStream(v2.StreamTimeoutConfig{
+		StreamIdleTimeout: &api.DurationConfig{Duration: 100 * time.Millisecond},
+	}, nil)
+	body := &mockStreamingBuffer{}
+	s.watchStreamingBuffer(body)
+	s.setupStreamTimers()
+
+	// the stream transferring the streaming body is not reset
+	for i := 0; i < 5; i++ {
+		time.Sleep(50 * time.Millisecond)
+		body.listener()
+	}
+	select {
+	case r := <-reason:
+		t.Fatalf("stream transferring the body is reset: %s", r)
+	default:
+	}
+
+	select {
+	case r := <-reason:
+		if r != types.StreamIdleTimeout {
+			t.Errorf("reset reason should be idle timeout, but got %s", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("idle stream should be reset")
+	}
+}
+
+func TestMaxStreamDuration(t *testing.T) {
+	s, reason := newTimeoutStream(v2.StreamTimeoutConfig{}, nil)
+	s.setupStreamTimers()
+	if s.streamIdleTimer != nil || s.maxDurationTimer != nil {
+		t.Fatal("no timer should be started without config")
+	}
+
+	// the route's max duration counts from the stream created
+	s.route = &mockRoute{
+		rule: &mockStreamTimeoutRouteRule{
+			config: v2.StreamTimeoutConfig{
+				MaxStreamDuration: &api.DurationConfig{Duration: 200 * time.Millisecond},
+			},
+		},
+	}
+	time.Sleep(100 * time.Millisecond)
+	s.setupRouteStreamTimers()
+	start := time.Now()
+	select {
+	case r := <-reason:
+		if r != types.StreamMaxDurationTimeout {
+			t.Errorf("reset reason should be max duration, but got %s", r)
+		}
+		if time.Since(start) > 150*time.Millisecond {
+			t.Errorf("max duration should count from the stream created")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream should be reset when reaching the max duration")
+	}
+	if !s.requestInfo.GetResponseFlag(types.StreamMaxDurationFlag) {
+		t.Error("response flag of max duration should be set")
+	}
+}
+
+func TestStreamTimersStopped(t *testing.T) {
+	s, reason := newTimeoutStream(v2.StreamTimeoutConfig{
+		StreamIdleTimeout: &api.DurationConfig{Duration: 50 * time.Millisecond},
+		MaxStreamDuration: &api.DurationConfig{Duration: 50 * time.Millisecond},
+	}, nil)
+	s.setupStreamTimers()
+	s.stopStreamTimers()
+	select {
+	case r := <-reason:
+		t.Fatalf("stream should not be reset after the timers stopped: %s", r)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestResetBeforeReceive(t *testing.T) {
+	p := &proxy{
+		config:           &v2.Proxy{},
+		activeSteams:     list.New(),
+		clusterManager:   &mockClusterManager{},
+		readCallbacks:    &mockReadFilterCallbacks{},
+		stats:            globalStats,
+		listenerStats:    newListenerStats("test"),
+		serverStreamConn: &mockServerConn{},
+	}
+	s := &downStream{
+		proxy:       p,
+		context:     context.Background(),
+		requestInfo: network.NewRequestInfo(),
+	}
+	s.element = p.activeSteams.PushBack(s)
+
+	// the stream is reset when the request is still in receiving, the stream is cleaned without the proxy goroutine
+	s.OnResetStream(types.StreamIdleTimeout)
+	time.Sleep(100 * time.Millisecond)
+	p.asMux.RLock()
+	defer p.asMux.RUnlock()
+	if p.activeSteams.Len() != 0 {
+		t.Fatal("the reset stream should be cleaned")
+	}
+	// the request received after reset is ignored
+	s.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil)
+	if s.downstreamReqHeaders != nil {
+		t.Error("the request should be ignored after the stream reset")
+	}
+}
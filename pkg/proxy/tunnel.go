@@ -0,0 +1,204 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net"
+	"runtime/debug"
+	"sync/atomic"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/tcpproxy"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// isConnectRequest checks whether the downstream request is a HTTP CONNECT request
+func (s *downStream) isConnectRequest() bool {
+	if s.downstreamReqHeaders == nil {
+		return false
+	}
+	method, _ := s.downstreamReqHeaders.Get(protocol.MosnHeaderMethod)
+	return method == protocol.MethodConnect
+}
+
+// connectConfig returns the tunnel config of the matched route, nil means the tunnel is not allowed
+func (s *downStream) connectConfig() *v2.ConnectConfig {
+	if rule, ok := s.route.RouteRule().(types.TunnelRouteRule); ok {
+		return rule.ConnectConfig()
+	}
+	return nil
+}
+
+// chooseTunnelHost creates the upstream connection for the CONNECT request.
+// the connection is created by the route's cluster, or by the authority if the dynamic host is enabled.
+// The dynamic host connection is not a host of the cluster, so no host stats are registered for the authority.
+func (s *downStream) chooseTunnelHost() {
+	config := s.connectConfig()
+	if config == nil {
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] CONNECT is not allowed by the route, proxyId = %d", s.ID)
+		s.requestInfo.SetResponseFlag(api.NoRouteFound)
+		s.sendHijackReply(types.RouterUnavailableCode, s.downstreamReqHeaders)
+		return
+	}
+	if _, ok := s.responseSender.(types.TunnelSender); !ok {
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] protocol %s does not support CONNECT, proxyId = %d", s.getDownstreamProtocol(), s.ID)
+		s.requestInfo.SetResponseFlag(api.NoRouteFound)
+		s.sendHijackReply(types.RouterUnavailableCode, s.downstreamReqHeaders)
+		return
+	}
+	if !s.cluster.ResourceManager().Connections().CanCreate() {
+		s.requestInfo.SetResponseFlag(api.UpstreamOverflow)
+		s.sendHijackReply(types.UpstreamOverFlowCode, s.downstreamReqHeaders)
+		return
+	}
+
+	if config.DynamicHost {
+		s.dialTunnelAuthority()
+		return
+	}
+
+	connData := s.proxy.clusterManager.TCPConnForCluster(s, s.snapshot)
+	if connData.Connection == nil {
+		log.Proxy.Alertf(s.context, types.ErrorKeyUpstreamConn, "no healthy host for CONNECT request, proxyId = %d", s.ID)
+		s.requestInfo.SetResponseFlag(api.NoHealthyUpstream)
+		s.sendHijackReply(types.NoHealthUpstreamCode, s.downstreamReqHeaders)
+		return
+	}
+
+	s.tunnel = tcpproxy.NewTunnel(connData.Connection, s.cluster, s.requestInfo)
+	s.requestInfo.OnUpstreamHostSelected(connData.Host)
+	s.requestInfo.SetUpstreamLocalAddress(connData.Host.AddressString())
+}
+
+// dialTunnelAuthority creates the connection to the CONNECT request's authority,
+// the authority should be allowed by the route.
+func (s *downStream) dialTunnelAuthority() {
+	authority, _ := s.downstreamReqHeaders.Get(protocol.MosnHeaderHostKey)
+	rule := s.route.RouteRule().(types.TunnelRouteRule)
+	if !rule.AllowTunnelAuthority(authority) {
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] CONNECT to %s is not allowed by the route, proxyId = %d", authority, s.ID)
+		s.requestInfo.SetResponseFlag(types.UnauthorizedFlag)
+		s.sendHijackReply(types.PermissionDeniedCode, s.downstreamReqHeaders)
+		return
+	}
+	addr, err := net.ResolveTCPAddr("tcp", authority)
+	if err != nil {
+		log.Proxy.Errorf(s.context, "[proxy] [downstream] resolve CONNECT authority %s failed: %v", authority, err)
+		s.requestInfo.SetResponseFlag(api.NoHealthyUpstream)
+		s.sendHijackReply(types.NoHealthUpstreamCode, s.downstreamReqHeaders)
+		return
+	}
+
+	conn := network.NewClientConnection(nil, s.cluster.ConnectTimeout(), nil, addr, nil)
+	conn.SetBufferLimit(s.cluster.ConnBufferLimitBytes())
+	s.tunnel = tcpproxy.NewTunnel(conn, s.cluster, s.requestInfo)
+	s.requestInfo.SetUpstreamLocalAddress(addr.String())
+}
+
+// startTunnel connects to the upstream, and responses the CONNECT request if connected.
+// If the upstream is a proxy, the handshake is driven by the upstream connection,
+// and the stream is resumed by onTunnelHandshake.
+// the downstream stream is cleaned when the tunnel closed.
+func (s *downStream) startTunnel(id uint32) {
+	if err := s.tunnel.Connect(); err != nil {
+		log.Proxy.Errorf(s.context, "[proxy] [downstream] tunnel connect to %s failed: %v", s.tunnel.RemoteAddr(), err)
+		s.onTunnelFailure()
+		return
+	}
+
+	if !s.connectConfig().UpstreamProxy {
+		s.establishTunnel()
+		return
+	}
+	authority, _ := s.downstreamReqHeaders.Get(protocol.MosnHeaderHostKey)
+	timeout := s.cluster.ConnectTimeout()
+	if timeout == 0 {
+		timeout = network.DefaultConnectTimeout
+	}
+	tunnel := s.tunnel
+	tunnel.Handshake(authority, timeout, func(err error) {
+		s.onTunnelHandshake(id, tunnel, err)
+	})
+}
+
+// onTunnelHandshake is called by the tunnel when the handshake with the upstream proxy finished,
+// the stream is resumed in the worker pool.
+func (s *downStream) onTunnelHandshake(id uint32, tunnel *tcpproxy.Tunnel, err error) {
+	pool.ScheduleAuto(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Proxy.Errorf(s.context, "[proxy] [downstream] tunnel handshake panic: %v, proxyId = %d\n%s", r, id, string(debug.Stack()))
+				if id == s.ID {
+					s.delete()
+				}
+			}
+		}()
+		if s.ID != id || s.tunnel != tunnel {
+			tunnel.Close()
+			return
+		}
+		if err != nil {
+			log.Proxy.Errorf(s.context, "[proxy] [downstream] tunnel handshake with %s failed: %v", tunnel.RemoteAddr(), err)
+			tunnel.Close()
+			s.onTunnelFailure()
+		} else {
+			s.establishTunnel()
+		}
+		// the hijack reply or the reset is processed by the phases
+		if phase, err := s.processError(id); err != nil && phase != types.End {
+			s.scheduleReceive(s.context, id, phase)
+		}
+	})
+}
+
+// establishTunnel responses the CONNECT request, and starts to transfer the data.
+func (s *downStream) establishTunnel() {
+	// the request info is used by the tunnel until it closed, so the buffers should not be reused
+	atomic.StoreUint32(&s.reuseBuffer, 0)
+	s.requestInfo.SetResponseCode(types.SuccessCode)
+
+	sender := s.responseSender.(types.TunnelSender)
+	if err := sender.StartTunnel(s.context, s.tunnel); err != nil {
+		log.Proxy.Errorf(s.context, "[proxy] [downstream] start tunnel failed: %v", err)
+		s.tunnel.Close()
+		s.resetStream()
+		return
+	}
+	// the data of the tunnel is not tracked by the stream, so the stream idle timeout is not
+	// applied to the established tunnel, while the max stream duration still works.
+	s.stopStreamIdleTimer()
+	s.tunnel.Start(sender, s.endStream)
+
+	// the upstream of the tunnel is read disabled by the downstream connection watermarks too
+	s.flowControlMux.Lock()
+	s.establishedTunnel = s.tunnel
+	s.flowControlMux.Unlock()
+	if s.proxy.isDownstreamAboveHighWatermark() {
+		s.readDisableUpstream(true)
+	}
+}
+
+func (s *downStream) onTunnelFailure() {
+	s.tunnel = nil
+	s.requestInfo.SetResponseFlag(api.UpstreamConnectionFailure)
+	s.sendHijackReply(types.NoHealthUpstreamCode, s.downstreamReqHeaders)
+}
@@ -33,6 +33,7 @@ import (
 // types.StreamEventListener
 // types.StreamReceiveListener
 // types.PoolEventListener
+// types.WatermarkListener
 type upstreamRequest struct {
 	proxy         *proxy
 	downStream    *downStream
@@ -87,6 +88,16 @@ func (r *upstreamRequest) OnResetStream(reason types.StreamResetReason) {
 
 func (r *upstreamRequest) OnDestroyStream() {}
 
+// types.WatermarkListener
+// Called by stream layer when the upstream connection crosses the watermarks
+func (r *upstreamRequest) OnAboveWriteBufferHighWatermark() {
+	r.downStream.readDisableDownstream(true)
+}
+
+func (r *upstreamRequest) OnBelowWriteBufferLowWatermark() {
+	r.downStream.readDisableDownstream(false)
+}
+
 func (r *upstreamRequest) endStream() {
 	upstreamResponseDurationNs := time.Now().Sub(r.startTime).Nanoseconds()
 	r.host.HostStats().UpstreamRequestDuration.Update(upstreamResponseDurationNs)
@@ -103,6 +114,7 @@ func (r *upstreamRequest) OnReceive(ctx context.Context, headers types.HeaderMap
 	if r.downStream.processDone() || r.setupRetry {
 		return
 	}
+	r.downStream.markActive()
 
 	r.endStream()
 
@@ -114,6 +126,7 @@ func (r *upstreamRequest) OnReceive(ctx context.Context, headers types.HeaderMap
 	r.downStream.downstreamRespHeaders = headers
 	r.downStream.downstreamRespDataBuf = data
 	r.downStream.downstreamRespTrailers = trailers
+	r.downStream.watchStreamingBuffer(data)
 
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(r.downStream.context, "[proxy] [upstream] OnReceive headers: %+v, data: %+v, trailers: %+v", headers, data, trailers)
@@ -277,6 +290,9 @@ func (r *upstreamRequest) OnReady(sender types.StreamSender, host types.Host) {
 	r.requestSender = sender
 	r.host = host
 	r.requestSender.GetStream().AddEventListener(r)
+	if r.proxy.isDownstreamAboveHighWatermark() {
+		r.downStream.readDisableUpstream(true)
+	}
 	// start a upstream send
 	r.startTime = time.Now()
 
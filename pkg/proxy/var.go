@@ -20,7 +20,9 @@ package proxy
 import (
 	"context"
 	"strconv"
+	"strings"
 
+	"mosn.io/api"
 	"mosn.io/mosn/pkg/types"
 
 	"mosn.io/mosn/pkg/variable"
@@ -150,13 +152,45 @@ func durationGetter(ctx context.Context, value *variable.IndexedValue, data inte
 	return info.Duration().String(), nil
 }
 
+// responseFlagNames is the short names of the response flags in the access log
+var responseFlagNames = []struct {
+	flag api.ResponseFlag
+	name string
+}{
+	{api.NoHealthyUpstream, "UH"},
+	{api.UpstreamRequestTimeout, "UT"},
+	{api.UpstreamLocalReset, "LR"},
+	{api.UpstreamRemoteReset, "UR"},
+	{api.UpstreamConnectionFailure, "UF"},
+	{api.UpstreamConnectionTermination, "UC"},
+	{api.UpstreamOverflow, "UO"},
+	{api.NoRouteFound, "NR"},
+	{api.DelayInjected, "DI"},
+	{api.FaultInjected, "FI"},
+	{api.RateLimited, "RL"},
+	{api.ReqEntityTooLarge, "TL"},
+	{types.StreamIdleTimeoutFlag, "SI"},
+	{types.StreamMaxDurationFlag, "DT"},
+	{types.UnauthorizedFlag, "UA"},
+	{types.OverloadFlag, "OM"},
+}
+
 // GetResponseFlagGetter
-// get request's response flag
+// get request's response flags, the flags are joined by comma, and "-" means no flag is set
 func responseFlagGetter(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
 	proxyBuffers := proxyBuffersByContext(ctx)
 	info := proxyBuffers.info
 
-	return strconv.FormatBool(info.GetResponseFlag(0)), nil
+	var flags []string
+	for _, f := range responseFlagNames {
+		if info.GetResponseFlag(f.flag) {
+			flags = append(flags, f.name)
+		}
+	}
+	if len(flags) == 0 {
+		return "-", nil
+	}
+	return strings.Join(flags, ","), nil
 }
 
 // UpstreamLocalAddressGetter
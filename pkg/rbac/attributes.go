@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strings"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// Attributes are the properties of a connection or a request that the policies match.
+// The headers are nil if the engine is used by the network filter, so the permissions and
+// the principals depend on the request never match.
+type Attributes struct {
+	Context context.Context
+	Conn    api.Connection
+	Headers types.HeaderMap
+
+	principalParsed bool
+	principal       string
+	authenticated   bool
+}
+
+// NewAttributes returns the attributes of the connection and the request
+func NewAttributes(ctx context.Context, conn api.Connection, headers types.HeaderMap) *Attributes {
+	return &Attributes{
+		Context: ctx,
+		Conn:    conn,
+		Headers: headers,
+	}
+}
+
+// SourceIP returns the remote ip of the downstream connection
+func (a *Attributes) SourceIP() net.IP {
+	if a.Conn == nil {
+		return nil
+	}
+	return addrIP(a.Conn.RemoteAddr())
+}
+
+// DestinationIP returns the local ip of the downstream connection
+func (a *Attributes) DestinationIP() net.IP {
+	if a.Conn == nil {
+		return nil
+	}
+	return addrIP(a.Conn.LocalAddr())
+}
+
+// DestinationPort returns the local port of the downstream connection
+func (a *Attributes) DestinationPort() int {
+	if a.Conn == nil {
+		return 0
+	}
+	switch addr := a.Conn.LocalAddr().(type) {
+	case *net.TCPAddr:
+		return addr.Port
+	case *net.UDPAddr:
+		return addr.Port
+	}
+	return 0
+}
+
+// Principal returns the identity of the peer certificate, the URI SAN (such as the SPIFFE ID)
+// is used if it exists, otherwise the subject is used.
+// false is returned if the connection is not an mTLS connection.
+func (a *Attributes) Principal() (string, bool) {
+	if !a.principalParsed {
+		a.principalParsed = true
+		if cert := peerCertificate(a.Conn); cert != nil {
+			a.authenticated = true
+			if len(cert.URIs) > 0 {
+				a.principal = cert.URIs[0].String()
+			} else {
+				a.principal = cert.Subject.String()
+			}
+		}
+	}
+	return a.principal, a.authenticated
+}
+
+// Header returns the header value, the pseudo headers :path, :method and :authority are
+// mapped to the request line that is stored in the mosn headers.
+func (a *Attributes) Header(name string) (string, bool) {
+	if a.Headers == nil {
+		return "", false
+	}
+	switch strings.ToLower(name) {
+	case ":path":
+		path, ok := a.Headers.Get(protocol.MosnHeaderPathKey)
+		if !ok {
+			return "", false
+		}
+		if query, ok := a.Headers.Get(protocol.MosnHeaderQueryStringKey); ok && query != "" {
+			path = path + "?" + query
+		}
+		return path, true
+	case ":method":
+		return a.Headers.Get(protocol.MosnHeaderMethod)
+	case ":authority":
+		if host, ok := a.Headers.Get(protocol.MosnHeaderHostKey); ok {
+			return host, true
+		}
+		return a.Headers.Get("host")
+	}
+	return a.Headers.Get(name)
+}
+
+// Path returns the path of the request without the query string
+func (a *Attributes) Path() (string, bool) {
+	if a.Headers == nil {
+		return "", false
+	}
+	return a.Headers.Get(protocol.MosnHeaderPathKey)
+}
+
+// Method returns the method of the http request
+func (a *Attributes) Method() (string, bool) {
+	if a.Headers == nil {
+		return "", false
+	}
+	return a.Headers.Get(protocol.MosnHeaderMethod)
+}
+
+// Service returns the service name and the method name of the rpc request
+func (a *Attributes) Service() (service string, method string, ok bool) {
+	aware, ok := a.Headers.(xprotocol.ServiceAware)
+	if !ok {
+		return "", "", false
+	}
+	return aware.GetServiceName(), aware.GetMethodName(), true
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	case nil:
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+func peerCertificate(conn api.Connection) *x509.Certificate {
+	if conn == nil {
+		return nil
+	}
+	tlsConn, ok := conn.RawConn().(interface {
+		ConnectionState() tls.ConnectionState
+	})
+	if !ok {
+		return nil
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
@@ -0,0 +1,128 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+const metricsType = "rbac"
+
+// metrics key
+const (
+	statsAllowed       = "allowed"
+	statsDenied        = "denied"
+	statsShadowAllowed = "shadow_allowed"
+	statsShadowDenied  = "shadow_denied"
+)
+
+type stats struct {
+	allowed       gometrics.Counter
+	denied        gometrics.Counter
+	shadowAllowed gometrics.Counter
+	shadowDenied  gometrics.Counter
+}
+
+func newStats(filterType string) *stats {
+	m, _ := metrics.NewMetrics(metricsType, map[string]string{"filter": filterType})
+	return &stats{
+		allowed:       m.Counter(statsAllowed),
+		denied:        m.Counter(statsDenied),
+		shadowAllowed: m.Counter(statsShadowAllowed),
+		shadowDenied:  m.Counter(statsShadowDenied),
+	}
+}
+
+// Authorizer enforces the rules and evaluates the shadow rules.
+// It is shared by the rbac network filter and stream filter.
+type Authorizer struct {
+	filterType string
+	engine     *Engine
+	shadow     *Engine
+	stats      *stats
+}
+
+// NewAuthorizer creates an authorizer by the config, the filter type is used in the logs and the metrics.
+func NewAuthorizer(cfg *v2.RBAC, filterType string) (*Authorizer, error) {
+	if cfg.Rules == nil && cfg.ShadowRules == nil {
+		return nil, errors.New("neither rules nor shadow rules is configured")
+	}
+	a := &Authorizer{
+		filterType: filterType,
+		stats:      newStats(filterType),
+	}
+	var err error
+	if cfg.Rules != nil {
+		if a.engine, err = NewEngine(cfg.Rules); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ShadowRules != nil {
+		if a.shadow, err = NewEngine(cfg.ShadowRules); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Authorize returns false if the attributes are denied by the rules.
+// The result of the shadow rules is only logged and counted.
+func (a *Authorizer) Authorize(ctx context.Context, attrs *Attributes) bool {
+	if a.shadow != nil {
+		allowed, policy := a.shadow.Allowed(attrs)
+		if allowed {
+			a.stats.shadowAllowed.Inc(1)
+		} else {
+			a.stats.shadowDenied.Inc(1)
+		}
+		log.Proxy.Infof(ctx, "[%s filter][rbac] shadow rules result: allowed %t, policy %q", a.filterType, allowed, policy)
+	}
+	if a.engine == nil {
+		return true
+	}
+	allowed, policy := a.engine.Allowed(attrs)
+	if allowed {
+		a.stats.allowed.Inc(1)
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[%s filter][rbac] allowed, policy %q", a.filterType, policy)
+		}
+	} else {
+		a.stats.denied.Inc(1)
+		log.Proxy.Warnf(ctx, "[%s filter][rbac] denied, policy %q", a.filterType, policy)
+	}
+	return allowed
+}
+
+// ParseConfig parses the config of the rbac filters
+func ParseConfig(conf map[string]interface{}) (*v2.RBAC, error) {
+	cfg := &v2.RBAC{}
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,201 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// Engine evaluates the policies of the rbac rules
+type Engine struct {
+	action   string
+	policies []*policy
+}
+
+type policy struct {
+	name        string
+	permissions matcher
+	principals  matcher
+}
+
+// NewEngine creates an engine by the rules
+func NewEngine(rules *v2.RBACRules) (*Engine, error) {
+	e := &Engine{
+		action: rules.Action,
+	}
+	switch e.action {
+	case "":
+		e.action = v2.RBACActionAllow
+	case v2.RBACActionAllow, v2.RBACActionDeny:
+	default:
+		return nil, fmt.Errorf("invalid rbac action: %s", rules.Action)
+	}
+	for name, cfg := range rules.Policies {
+		p, err := newPolicy(name, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rbac policy %s: %v", name, err)
+		}
+		e.policies = append(e.policies, p)
+	}
+	// the policies are evaluated in order of the names, so the matched policy is stable
+	sort.Slice(e.policies, func(i, j int) bool {
+		return e.policies[i].name < e.policies[j].name
+	})
+	return e, nil
+}
+
+// Allowed returns whether the attributes are allowed by the rules, and the name of the matched policy.
+// The policy name is empty if no policy matches.
+func (e *Engine) Allowed(a *Attributes) (bool, string) {
+	for _, p := range e.policies {
+		if p.permissions.match(a) && p.principals.match(a) {
+			return e.action == v2.RBACActionAllow, p.name
+		}
+	}
+	return e.action == v2.RBACActionDeny, ""
+}
+
+func newPolicy(name string, cfg *v2.RBACPolicy) (*policy, error) {
+	if cfg == nil || len(cfg.Permissions) == 0 || len(cfg.Principals) == 0 {
+		return nil, errors.New("a policy needs at least one permission and one principal")
+	}
+	permissions, err := newPermissions(cfg.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	principals, err := newPrincipals(cfg.Principals)
+	if err != nil {
+		return nil, err
+	}
+	return &policy{
+		name:        name,
+		permissions: permissions,
+		principals:  principals,
+	}, nil
+}
+
+func newPermissions(cfgs []*v2.RBACPermission) (orMatcher, error) {
+	matchers := make(orMatcher, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		m, err := newPermission(cfg)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func newPermission(cfg *v2.RBACPermission) (matcher, error) {
+	if cfg == nil {
+		return nil, errors.New("permission is empty")
+	}
+	switch {
+	case cfg.Any:
+		return anyMatcher{}, nil
+	case len(cfg.AndRules) > 0:
+		rules, err := newPermissions(cfg.AndRules)
+		return andMatcher(rules), err
+	case len(cfg.OrRules) > 0:
+		return newPermissions(cfg.OrRules)
+	case cfg.NotRule != nil:
+		rule, err := newPermission(cfg.NotRule)
+		return notMatcher{rule}, err
+	case cfg.Header != nil:
+		return newHeaderMatcher(cfg.Header)
+	case cfg.Path != nil:
+		m, err := newStringMatcher(cfg.Path)
+		return pathMatcher{m}, err
+	case cfg.Method != "":
+		return methodMatcher(cfg.Method), nil
+	case cfg.DestinationIP != nil:
+		return newIPMatcher(cfg.DestinationIP, false)
+	case cfg.DestinationPort != 0:
+		return portMatcher(cfg.DestinationPort), nil
+	case cfg.Service != nil:
+		m, err := newStringMatcher(cfg.Service)
+		return &serviceMatcher{value: m}, err
+	case cfg.RPCMethod != nil:
+		m, err := newStringMatcher(cfg.RPCMethod)
+		return &serviceMatcher{value: m, method: true}, err
+	}
+	return nil, errors.New("permission has no rule")
+}
+
+func newPrincipals(cfgs []*v2.RBACPrincipal) (orMatcher, error) {
+	matchers := make(orMatcher, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		m, err := newPrincipal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func newPrincipal(cfg *v2.RBACPrincipal) (matcher, error) {
+	if cfg == nil {
+		return nil, errors.New("principal is empty")
+	}
+	switch {
+	case cfg.Any:
+		return anyMatcher{}, nil
+	case len(cfg.AndIDs) > 0:
+		ids, err := newPrincipals(cfg.AndIDs)
+		return andMatcher(ids), err
+	case len(cfg.OrIDs) > 0:
+		return newPrincipals(cfg.OrIDs)
+	case cfg.NotID != nil:
+		id, err := newPrincipal(cfg.NotID)
+		return notMatcher{id}, err
+	case cfg.Authenticated != nil:
+		m := &authenticatedMatcher{}
+		if cfg.Authenticated.PrincipalName != nil {
+			name, err := newStringMatcher(cfg.Authenticated.PrincipalName)
+			if err != nil {
+				return nil, err
+			}
+			m.name = name
+		}
+		return m, nil
+	case cfg.SourceIP != nil:
+		return newIPMatcher(cfg.SourceIP, true)
+	case cfg.Header != nil:
+		return newHeaderMatcher(cfg.Header)
+	case cfg.JwtClaim != nil:
+		if cfg.JwtClaim.Variable == "" {
+			return nil, errors.New("jwt claim variable is empty")
+		}
+		m := &jwtClaimMatcher{
+			variable: cfg.JwtClaim.Variable,
+		}
+		if cfg.JwtClaim.Value != nil {
+			value, err := newStringMatcher(cfg.JwtClaim.Value)
+			if err != nil {
+				return nil, err
+			}
+			m.value = value
+		}
+		return m, nil
+	}
+	return nil, errors.New("principal has no identifier")
+}
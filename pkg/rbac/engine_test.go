@@ -0,0 +1,251 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+const testJwtVariable = "test_rbac_jwt_sub"
+
+func init() {
+	variable.RegisterVariable(variable.NewIndexedVariable(testJwtVariable, nil, nil, variable.BasicSetter, 0))
+}
+
+func newTestRules(t *testing.T, s string) *v2.RBACRules {
+	rules := &v2.RBACRules{}
+	if err := json.Unmarshal([]byte(s), rules); err != nil {
+		t.Fatalf("unmarshal rules failed: %v", err)
+	}
+	return rules
+}
+
+func newHTTPHeaders(method, path string, kvs ...string) types.HeaderMap {
+	headers := protocol.CommonHeader{
+		protocol.MosnHeaderMethod:  method,
+		protocol.MosnHeaderPathKey: path,
+	}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		headers[kvs[i]] = kvs[i+1]
+	}
+	return headers
+}
+
+func TestEngineAllow(t *testing.T) {
+	rules := newTestRules(t, `{
+		"action": "ALLOW",
+		"policies": {
+			"admin": {
+				"permissions": [{"path": {"prefix": "/admin/"}}],
+				"principals": [{"and_ids": [
+					{"authenticated": {"principal_name": {"exact": "spiffe://cluster.local/ns/default/sa/admin"}}},
+					{"source_ip": {"address_prefix": "10.0.0.0", "prefix_len": 8}}
+				]}]
+			},
+			"public": {
+				"permissions": [{"and_rules": [
+					{"not_rule": {"path": {"prefix": "/admin/"}}},
+					{"method": "GET"}
+				]}],
+				"principals": [{"any": true}]
+			},
+			"rpc": {
+				"permissions": [{"and_rules": [
+					{"service": {"exact": "com.test.Echo"}},
+					{"rpc_method": {"prefix": "get"}}
+				]}],
+				"principals": [{"jwt_claim": {"variable": "test_rbac_jwt_sub", "value": {"exact": "alice"}}}]
+			},
+			"port": {
+				"permissions": [{"destination_port": 9090}],
+				"principals": [{"header": {"name": "x-caller", "exact_match": "ops"}}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(rules)
+	if err != nil {
+		t.Fatalf("create engine failed: %v", err)
+	}
+	const adminID = "spiffe://cluster.local/ns/default/sa/admin"
+	rpcHeaders := func(service, method string) types.HeaderMap {
+		return &mockRPCHeaders{
+			CommonHeader: protocol.CommonHeader{},
+			service:      service,
+			method:       method,
+		}
+	}
+	testCases := []struct {
+		name    string
+		conn    *mockConnection
+		headers types.HeaderMap
+		jwtSub  string
+		allowed bool
+		policy  string
+	}{
+		{"admin", newMockTLSConnection("10.1.1.1:1234", "127.0.0.1:8080", adminID), newHTTPHeaders("GET", "/admin/users"), "", true, "admin"},
+		{"admin from other network", newMockTLSConnection("192.168.1.1:1234", "127.0.0.1:8080", adminID), newHTTPHeaders("GET", "/admin/users"), "", false, ""},
+		{"admin with other identity", newMockTLSConnection("10.1.1.1:1234", "127.0.0.1:8080", "spiffe://cluster.local/ns/default/sa/guest"), newHTTPHeaders("GET", "/admin/users"), "", false, ""},
+		{"admin without tls", newMockConnection("10.1.1.1:1234", "127.0.0.1:8080"), newHTTPHeaders("GET", "/admin/users"), "", false, ""},
+		{"public", newMockConnection("192.168.1.1:1234", "127.0.0.1:8080"), newHTTPHeaders("GET", "/index.html"), "", true, "public"},
+		{"public with post", newMockConnection("192.168.1.1:1234", "127.0.0.1:8080"), newHTTPHeaders("POST", "/index.html"), "", false, ""},
+		{"rpc", newMockConnection("192.168.1.1:1234", "127.0.0.1:8080"), rpcHeaders("com.test.Echo", "getName"), "alice", true, "rpc"},
+		{"rpc with other claim", newMockConnection("192.168.1.1:1234", "127.0.0.1:8080"), rpcHeaders("com.test.Echo", "getName"), "bob", false, ""},
+		{"rpc with other method", newMockConnection("192.168.1.1:1234", "127.0.0.1:8080"), rpcHeaders("com.test.Echo", "setName"), "alice", false, ""},
+		{"port", newMockConnection("192.168.1.1:1234", "127.0.0.1:9090"), newHTTPHeaders("POST", "/ops", "x-caller", "ops"), "", true, "port"},
+		{"port without headers", newMockConnection("192.168.1.1:1234", "127.0.0.1:9090"), nil, "", false, ""},
+	}
+	for _, tc := range testCases {
+		ctx := variable.NewVariableContext(context.Background())
+		if tc.jwtSub != "" {
+			if err := variable.SetVariableValue(ctx, testJwtVariable, tc.jwtSub); err != nil {
+				t.Fatalf("set variable failed: %v", err)
+			}
+		}
+		allowed, policy := engine.Allowed(NewAttributes(ctx, tc.conn, tc.headers))
+		if allowed != tc.allowed || policy != tc.policy {
+			t.Errorf("case %s: expected %t %q, but got %t %q", tc.name, tc.allowed, tc.policy, allowed, policy)
+		}
+	}
+}
+
+func TestEngineDeny(t *testing.T) {
+	rules := newTestRules(t, `{
+		"action": "DENY",
+		"policies": {
+			"internal": {
+				"permissions": [{"any": true}],
+				"principals": [{"and_ids": [
+					{"source_ip": {"address_prefix": "10.0.0.0", "prefix_len": 8}},
+					{"not_id": {"source_ip": {"address_prefix": "10.1.0.0", "prefix_len": 16}}}
+				]}]
+			},
+			"debug": {
+				"permissions": [
+					{"header": {"name": ":path", "regex_match": "/debug/.*\\?.*"}},
+					{"header": {"name": "x-debug", "present_match": true}}
+				],
+				"principals": [{"any": true}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(rules)
+	if err != nil {
+		t.Fatalf("create engine failed: %v", err)
+	}
+	testCases := []struct {
+		remote  string
+		headers types.HeaderMap
+		allowed bool
+	}{
+		{"10.2.0.1:1234", newHTTPHeaders("GET", "/"), false},
+		{"10.1.0.1:1234", newHTTPHeaders("GET", "/"), true},
+		{"192.168.1.1:1234", newHTTPHeaders("GET", "/"), true},
+		{"192.168.1.1:1234", newHTTPHeaders("GET", "/debug/vars", protocol.MosnHeaderQueryStringKey, "a=b"), false},
+		{"192.168.1.1:1234", newHTTPHeaders("GET", "/debug/vars"), true},
+		{"192.168.1.1:1234", newHTTPHeaders("GET", "/", "x-debug", "1"), false},
+	}
+	for i, tc := range testCases {
+		attrs := NewAttributes(context.Background(), newMockConnection(tc.remote, "127.0.0.1:8080"), tc.headers)
+		if allowed, _ := engine.Allowed(attrs); allowed != tc.allowed {
+			t.Errorf("case %d: expected %t, but got %t", i, tc.allowed, allowed)
+		}
+	}
+}
+
+func TestStringMatcher(t *testing.T) {
+	testCases := []struct {
+		cfg     v2.RBACStringMatcher
+		value   string
+		matched bool
+	}{
+		{v2.RBACStringMatcher{Exact: "abc"}, "abc", true},
+		{v2.RBACStringMatcher{Exact: "abc"}, "ABC", false},
+		{v2.RBACStringMatcher{Exact: "abc", IgnoreCase: true}, "ABC", true},
+		{v2.RBACStringMatcher{Prefix: "/api"}, "/api/v1", true},
+		{v2.RBACStringMatcher{Suffix: ".json"}, "/a.json", true},
+		{v2.RBACStringMatcher{Suffix: ".JSON", IgnoreCase: true}, "/a.json", true},
+		{v2.RBACStringMatcher{Regex: "a+b"}, "aab", true},
+		{v2.RBACStringMatcher{Regex: "a+b"}, "aabc", false},
+		{v2.RBACStringMatcher{Regex: "A+B", IgnoreCase: true}, "aab", true},
+	}
+	for i, tc := range testCases {
+		m, err := newStringMatcher(&tc.cfg)
+		if err != nil {
+			t.Fatalf("case %d: create matcher failed: %v", i, err)
+		}
+		if m.match(tc.value) != tc.matched {
+			t.Errorf("case %d: expected %t", i, tc.matched)
+		}
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, s := range []string{
+		`{"action": "LOG"}`,
+		`{"policies": {"p": {"permissions": [{"any": true}]}}}`,
+		`{"policies": {"p": {"permissions": [{}], "principals": [{"any": true}]}}}`,
+		`{"policies": {"p": {"permissions": [{"any": true}], "principals": [{}]}}}`,
+		`{"policies": {"p": {"permissions": [{"path": {"regex": "("}}], "principals": [{"any": true}]}}}`,
+		`{"policies": {"p": {"permissions": [{"any": true}], "principals": [{"source_ip": {"address_prefix": "10.0.0", "prefix_len": 8}}]}}}`,
+		`{"policies": {"p": {"permissions": [{"any": true}], "principals": [{"source_ip": {"address_prefix": "10.0.0.0", "prefix_len": 33}}]}}}`,
+		`{"policies": {"p": {"permissions": [{"header": {"exact_match": "a"}}], "principals": [{"any": true}]}}}`,
+		`{"policies": {"p": {"permissions": [{"any": true}], "principals": [{"jwt_claim": {}}]}}}`,
+	} {
+		if _, err := NewEngine(newTestRules(t, s)); err == nil {
+			t.Errorf("rules %s should be invalid", s)
+		}
+	}
+}
+
+func TestAuthorizerShadow(t *testing.T) {
+	cfg, err := ParseConfig(map[string]interface{}{
+		"shadow_rules": map[string]interface{}{
+			"action": "DENY",
+			"policies": map[string]interface{}{
+				"all": map[string]interface{}{
+					"permissions": []interface{}{map[string]interface{}{"any": true}},
+					"principals":  []interface{}{map[string]interface{}{"any": true}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	authorizer, err := NewAuthorizer(cfg, "test_shadow")
+	if err != nil {
+		t.Fatalf("create authorizer failed: %v", err)
+	}
+	attrs := NewAttributes(context.Background(), newMockConnection("10.0.0.1:1234", "127.0.0.1:8080"), newHTTPHeaders("GET", "/"))
+	// the shadow rules are never enforced
+	if !authorizer.Authorize(context.Background(), attrs) {
+		t.Error("the request should be allowed in the shadow mode")
+	}
+	if authorizer.stats.shadowDenied.Count() != 1 || authorizer.stats.denied.Count() != 0 {
+		t.Errorf("unexpected stats, shadow denied %d, denied %d", authorizer.stats.shadowDenied.Count(), authorizer.stats.denied.Count())
+	}
+	if _, err := NewAuthorizer(&v2.RBAC{}, "test_empty"); err == nil {
+		t.Error("the config without rules should be invalid")
+	}
+}
@@ -0,0 +1,283 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/variable"
+)
+
+// matcher is the common interface of the permissions and the principals
+type matcher interface {
+	match(a *Attributes) bool
+}
+
+type anyMatcher struct{}
+
+func (anyMatcher) match(a *Attributes) bool {
+	return true
+}
+
+type andMatcher []matcher
+
+func (m andMatcher) match(a *Attributes) bool {
+	for _, sub := range m {
+		if !sub.match(a) {
+			return false
+		}
+	}
+	return true
+}
+
+type orMatcher []matcher
+
+func (m orMatcher) match(a *Attributes) bool {
+	for _, sub := range m {
+		if sub.match(a) {
+			return true
+		}
+	}
+	return false
+}
+
+type notMatcher struct {
+	matcher
+}
+
+func (m notMatcher) match(a *Attributes) bool {
+	return !m.matcher.match(a)
+}
+
+// headerMatcher matches a request header, the header only needs to be present if the value is nil
+type headerMatcher struct {
+	name   string
+	value  *stringMatcher
+	invert bool
+}
+
+func (m *headerMatcher) match(a *Attributes) bool {
+	value, ok := a.Header(m.name)
+	matched := ok
+	if ok && m.value != nil {
+		matched = m.value.match(value)
+	}
+	if m.invert {
+		return !matched
+	}
+	return matched
+}
+
+type pathMatcher struct {
+	*stringMatcher
+}
+
+func (m pathMatcher) match(a *Attributes) bool {
+	path, ok := a.Path()
+	return ok && m.stringMatcher.match(path)
+}
+
+type methodMatcher string
+
+func (m methodMatcher) match(a *Attributes) bool {
+	method, ok := a.Method()
+	return ok && strings.EqualFold(method, string(m))
+}
+
+// ipMatcher matches the source ip or the destination ip of the connection
+type ipMatcher struct {
+	ipNet  *net.IPNet
+	source bool
+}
+
+func (m *ipMatcher) match(a *Attributes) bool {
+	var ip net.IP
+	if m.source {
+		ip = a.SourceIP()
+	} else {
+		ip = a.DestinationIP()
+	}
+	return ip != nil && m.ipNet.Contains(ip)
+}
+
+type portMatcher int
+
+func (m portMatcher) match(a *Attributes) bool {
+	return a.DestinationPort() == int(m)
+}
+
+// serviceMatcher matches the service name or the method name of the rpc request
+type serviceMatcher struct {
+	value  *stringMatcher
+	method bool
+}
+
+func (m *serviceMatcher) match(a *Attributes) bool {
+	service, method, ok := a.Service()
+	if !ok {
+		return false
+	}
+	if m.method {
+		return m.value.match(method)
+	}
+	return m.value.match(service)
+}
+
+// authenticatedMatcher matches the peer certificate of the mTLS connection
+type authenticatedMatcher struct {
+	name *stringMatcher
+}
+
+func (m *authenticatedMatcher) match(a *Attributes) bool {
+	principal, ok := a.Principal()
+	if !ok {
+		return false
+	}
+	return m.name == nil || m.name.match(principal)
+}
+
+// jwtClaimMatcher matches the jwt claim that is stored in the variable by the jwt_authn filter
+type jwtClaimMatcher struct {
+	variable string
+	value    *stringMatcher
+}
+
+func (m *jwtClaimMatcher) match(a *Attributes) bool {
+	if a.Context == nil {
+		return false
+	}
+	value, err := variable.GetVariableValue(a.Context, m.variable)
+	if err != nil || value == "" {
+		return false
+	}
+	return m.value == nil || m.value.match(value)
+}
+
+type stringMatcher struct {
+	exact      string
+	prefix     string
+	suffix     string
+	regex      *regexp.Regexp
+	ignoreCase bool
+}
+
+func newStringMatcher(cfg *v2.RBACStringMatcher) (*stringMatcher, error) {
+	if cfg == nil {
+		return nil, errors.New("string matcher is empty")
+	}
+	m := &stringMatcher{
+		ignoreCase: cfg.IgnoreCase,
+	}
+	switch {
+	case cfg.Regex != "":
+		expr := cfg.Regex
+		if cfg.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		// the regex should match the whole string
+		regex, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, err
+		}
+		m.regex = regex
+	case cfg.Prefix != "":
+		m.prefix = cfg.Prefix
+	case cfg.Suffix != "":
+		m.suffix = cfg.Suffix
+	default:
+		m.exact = cfg.Exact
+	}
+	if m.ignoreCase {
+		m.exact = strings.ToLower(m.exact)
+		m.prefix = strings.ToLower(m.prefix)
+		m.suffix = strings.ToLower(m.suffix)
+	}
+	return m, nil
+}
+
+func (m *stringMatcher) match(value string) bool {
+	if m.regex != nil {
+		return m.regex.MatchString(value)
+	}
+	if m.ignoreCase {
+		value = strings.ToLower(value)
+	}
+	switch {
+	case m.prefix != "":
+		return strings.HasPrefix(value, m.prefix)
+	case m.suffix != "":
+		return strings.HasSuffix(value, m.suffix)
+	}
+	return value == m.exact
+}
+
+func newHeaderMatcher(cfg *v2.RBACHeaderMatcher) (*headerMatcher, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("header name is empty")
+	}
+	m := &headerMatcher{
+		name:   cfg.Name,
+		invert: cfg.InvertMatch,
+	}
+	var value *v2.RBACStringMatcher
+	switch {
+	case cfg.ExactMatch != "":
+		value = &v2.RBACStringMatcher{Exact: cfg.ExactMatch}
+	case cfg.PrefixMatch != "":
+		value = &v2.RBACStringMatcher{Prefix: cfg.PrefixMatch}
+	case cfg.SuffixMatch != "":
+		value = &v2.RBACStringMatcher{Suffix: cfg.SuffixMatch}
+	case cfg.RegexMatch != "":
+		value = &v2.RBACStringMatcher{Regex: cfg.RegexMatch}
+	}
+	if value != nil {
+		sm, err := newStringMatcher(value)
+		if err != nil {
+			return nil, err
+		}
+		m.value = sm
+	}
+	return m, nil
+}
+
+func newIPMatcher(cfg *v2.RBACCidrRange, source bool) (*ipMatcher, error) {
+	ip := net.ParseIP(cfg.AddressPrefix)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address prefix: %s", cfg.AddressPrefix)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 8 * net.IPv4len
+	}
+	if int(cfg.PrefixLen) > bits {
+		return nil, fmt.Errorf("invalid prefix length: %d", cfg.PrefixLen)
+	}
+	mask := net.CIDRMask(int(cfg.PrefixLen), bits)
+	return &ipMatcher{
+		ipNet: &net.IPNet{
+			IP:   ip.Mask(mask),
+			Mask: mask,
+		},
+		source: source,
+	}, nil
+}
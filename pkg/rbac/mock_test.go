@@ -0,0 +1,105 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rbac
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+// this file mocks the interface that used for test
+// only implement the function that used in test
+
+type mockConnection struct {
+	api.Connection
+	remote  net.Addr
+	local   net.Addr
+	rawConn net.Conn
+	closed  bool
+}
+
+func newMockConnection(remote, local string) *mockConnection {
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", remote)
+	localAddr, _ := net.ResolveTCPAddr("tcp", local)
+	return &mockConnection{
+		remote: remoteAddr,
+		local:  localAddr,
+	}
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *mockConnection) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *mockConnection) RawConn() net.Conn {
+	return c.rawConn
+}
+
+func (c *mockConnection) Close(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+	c.closed = true
+	return nil
+}
+
+// mockTLSConn returns the peer certificate
+type mockTLSConn struct {
+	net.Conn
+	cert *x509.Certificate
+}
+
+func (c *mockTLSConn) ConnectionState() tls.ConnectionState {
+	return tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{c.cert},
+	}
+}
+
+func newMockTLSConnection(remote, local, spiffeID string) *mockConnection {
+	conn := newMockConnection(remote, local)
+	cert := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "test"},
+	}
+	if spiffeID != "" {
+		u, _ := url.Parse(spiffeID)
+		cert.URIs = []*url.URL{u}
+	}
+	conn.rawConn = &mockTLSConn{cert: cert}
+	return conn
+}
+
+// mockRPCHeaders is a rpc request that provides the service name and the method name
+type mockRPCHeaders struct {
+	protocol.CommonHeader
+	service string
+	method  string
+}
+
+func (h *mockRPCHeaders) GetServiceName() string {
+	return h.service
+}
+
+func (h *mockRPCHeaders) GetMethodName() string {
+	return h.method
+}
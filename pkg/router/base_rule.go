@@ -18,7 +18,9 @@
 package router
 
 import (
+	"fmt"
 	"math/rand"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -57,6 +59,9 @@ type RouteRuleImplBase struct {
 	totalClusterWeight uint32
 	lock               sync.Mutex
 	randInstance       *rand.Rand
+	// the authorities allowed by the dynamic host tunnel
+	tunnelHosts map[string]struct{}
+	tunnelCIDRs []*net.IPNet
 }
 
 func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) (*RouteRuleImplBase, error) {
@@ -91,6 +96,20 @@ func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) (*RouteRuleI
 			numRetries:   route.Route.RetryPolicy.NumRetries,
 		}
 	}
+	// add tunnel allowed authorities
+	if config := route.Route.ConnectConfig; config != nil {
+		base.tunnelHosts = make(map[string]struct{}, len(config.AllowedHosts))
+		for _, host := range config.AllowedHosts {
+			base.tunnelHosts[strings.ToLower(host)] = struct{}{}
+		}
+		for _, cidr := range config.AllowedCIDRs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid connect allowed cidr %s: %v", cidr, err)
+			}
+			base.tunnelCIDRs = append(base.tunnelCIDRs, ipNet)
+		}
+	}
 	// add direct repsonse rule
 	if route.DirectResponse != nil {
 		base.directResponseRule = &directResponseImpl{
@@ -158,6 +177,35 @@ func (rri *RouteRuleImplBase) PerFilterConfig() map[string]interface{} {
 	return rri.perFilterConfig
 }
 
+// types.TunnelRouteRule
+func (rri *RouteRuleImplBase) ConnectConfig() *v2.ConnectConfig {
+	return rri.routerAction.ConnectConfig
+}
+
+// AllowTunnelAuthority matches the ip authority with the allowed cidrs,
+// and the host name authority with the allowed hosts.
+func (rri *RouteRuleImplBase) AllowTunnelAuthority(authority string) bool {
+	host, _, err := net.SplitHostPort(authority)
+	if err != nil {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		for _, ipNet := range rri.tunnelCIDRs {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := rri.tunnelHosts[strings.ToLower(host)]
+	return ok
+}
+
+// types.StreamTimeoutRouteRule
+func (rri *RouteRuleImplBase) StreamTimeoutConfig() v2.StreamTimeoutConfig {
+	return rri.routerAction.StreamTimeoutConfig
+}
+
 // matchRoute is a common matched for http
 func (rri *RouteRuleImplBase) matchRoute(headers api.HeaderMap, randomValue uint64) bool {
 	// 1. match headers' KV
@@ -51,6 +51,7 @@ func DefaultSofaRouterRuleFactory(base *RouteRuleImplBase, headers []v2.HeaderMa
 			return &SofaRouteRuleImpl{
 				RouteRuleImplBase: base,
 				matchValue:        header.Value,
+				extraHeaders:      getSofaExtraHeaders(headers),
 			}
 		}
 	}
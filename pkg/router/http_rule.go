@@ -18,7 +18,9 @@
 package router
 
 import (
+	"net"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"mosn.io/api"
@@ -150,3 +152,59 @@ func (rrei *RegexRouteRuleImpl) Match(headers api.HeaderMap, randomValue uint64)
 	log.DefaultLogger.Debugf(RouterLogFormat, "regex route rule", "failed match", headers)
 	return nil
 }
+
+// ConnectRouteRuleImpl used to match HTTP CONNECT requests
+type ConnectRouteRuleImpl struct {
+	*RouteRuleImplBase
+	ports []uint32
+}
+
+func (crri *ConnectRouteRuleImpl) PathMatchCriterion() api.PathMatchCriterion {
+	return crri
+}
+
+func (crri *ConnectRouteRuleImpl) RouteRule() api.RouteRule {
+	return crri
+}
+
+// types.PathMatchCriterion
+func (crri *ConnectRouteRuleImpl) Matcher() string {
+	return ""
+}
+
+func (crri *ConnectRouteRuleImpl) MatchType() api.PathMatchType {
+	return api.None
+}
+
+func (crri *ConnectRouteRuleImpl) Match(headers api.HeaderMap, randomValue uint64) api.Route {
+	if crri.matchRoute(headers, randomValue) {
+		if method, ok := headers.Get(protocol.MosnHeaderMethod); ok && method == protocol.MethodConnect {
+			authority, _ := headers.Get(protocol.MosnHeaderHostKey)
+			if crri.matchPort(authority) {
+				return crri
+			}
+		}
+	}
+	log.DefaultLogger.Debugf(RouterLogFormat, "connect route rule", "failed match", headers)
+	return nil
+}
+
+func (crri *ConnectRouteRuleImpl) matchPort(authority string) bool {
+	if len(crri.ports) == 0 {
+		return true
+	}
+	_, p, err := net.SplitHostPort(authority)
+	if err != nil {
+		return false
+	}
+	port, err := strconv.ParseUint(p, 10, 32)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range crri.ports {
+		if uint32(port) == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -24,6 +24,7 @@ import (
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
 )
 
 func TestPrefixRouteRuleImpl(t *testing.T) {
@@ -154,3 +155,97 @@ func TestRegexRouteRuleImpl(t *testing.T) {
 		}
 	}
 }
+
+func TestConnectRouteRuleImpl(t *testing.T) {
+	virtualHostImpl := &VirtualHostImpl{virtualHostName: "test"}
+	testCases := []struct {
+		ports     []uint32
+		method    string
+		authority string
+		expected  bool
+	}{
+		{nil, "CONNECT", "example.com:443", true},
+		{nil, "GET", "example.com:443", false},
+		{[]uint32{443}, "CONNECT", "example.com:443", true},
+		{[]uint32{443}, "CONNECT", "example.com:22", false},
+		{[]uint32{443}, "CONNECT", "example.com", false},
+	}
+	for i, tc := range testCases {
+		route := &v2.Router{
+			RouterConfig: v2.RouterConfig{
+				Match: v2.RouterMatch{
+					ConnectMatcher: &v2.ConnectMatcher{Ports: tc.ports},
+				},
+				Route: v2.RouteAction{
+					RouterActionConfig: v2.RouterActionConfig{
+						ClusterName:   "test",
+						ConnectConfig: &v2.ConnectConfig{},
+					},
+				},
+			},
+		}
+		base, _ := NewRouteRuleImplBase(virtualHostImpl, route)
+		rr := &ConnectRouteRuleImpl{base, route.Match.ConnectMatcher.Ports}
+		headers := protocol.CommonHeader(map[string]string{
+			protocol.MosnHeaderMethod:  tc.method,
+			protocol.MosnHeaderHostKey: tc.authority,
+		})
+		result := rr.Match(headers, 1)
+		if (result != nil) != tc.expected {
+			t.Errorf("#%d want matched %v, but get matched %v\n", i, tc.expected, result)
+		}
+		if result != nil {
+			if result.RouteRule().(types.TunnelRouteRule).ConnectConfig() == nil {
+				t.Errorf("#%d connect config is not expected", i)
+			}
+		}
+	}
+}
+
+func TestAllowTunnelAuthority(t *testing.T) {
+	virtualHostImpl := &VirtualHostImpl{virtualHostName: "test"}
+	route := &v2.Router{
+		RouterConfig: v2.RouterConfig{
+			Match: v2.RouterMatch{
+				ConnectMatcher: &v2.ConnectMatcher{},
+			},
+			Route: v2.RouteAction{
+				RouterActionConfig: v2.RouterActionConfig{
+					ClusterName: "test",
+					ConnectConfig: &v2.ConnectConfig{
+						DynamicHost:  true,
+						AllowedHosts: []string{"Example.com"},
+						AllowedCIDRs: []string{"10.0.0.0/8", "::1/128"},
+					},
+				},
+			},
+		},
+	}
+	base, err := NewRouteRuleImplBase(virtualHostImpl, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tc := range []struct {
+		authority string
+		expected  bool
+	}{
+		{"example.com:443", true},
+		{"EXAMPLE.COM:443", true},
+		{"10.1.2.3:8080", true},
+		{"[::1]:8080", true},
+		{"example.com", false},
+		{"www.example.com:443", false},
+		{"127.0.0.1:34901", false},
+		{"11.0.0.1:80", false},
+		{"localhost:80", false},
+	} {
+		if allowed := base.AllowTunnelAuthority(tc.authority); allowed != tc.expected {
+			t.Errorf("#%d %s expected allowed %v, but got %v", i, tc.authority, tc.expected, allowed)
+		}
+	}
+
+	route.Route.ConnectConfig.AllowedCIDRs = []string{"10.0.0.0"}
+	if _, err := NewRouteRuleImplBase(virtualHostImpl, route); err == nil {
+		t.Error("expected error for invalid cidr")
+	}
+}
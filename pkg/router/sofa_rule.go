@@ -28,8 +28,9 @@ func SofaRouterFactory(headers []v2.HeaderMatcher) RouteBase {
 	for _, header := range headers {
 		if header.Name == types.SofaRouteMatchKey {
 			return &SofaRouteRuleImpl{
-				matchName:  header.Name,
-				matchValue: header.Value,
+				matchName:    header.Name,
+				matchValue:   header.Value,
+				extraHeaders: getSofaExtraHeaders(headers),
 			}
 		}
 	}
@@ -41,6 +42,20 @@ type SofaRouteRuleImpl struct {
 	*RouteRuleImplBase
 	matchName  string
 	matchValue string
+	// extraHeaders are the configured headers except the service, such as
+	// the dubbo attachments exposed by the stream filters
+	extraHeaders []*types.HeaderData
+}
+
+// getSofaExtraHeaders returns the header matchers except the service one
+func getSofaExtraHeaders(headers []v2.HeaderMatcher) []*types.HeaderData {
+	var extra []v2.HeaderMatcher
+	for _, header := range headers {
+		if header.Name != types.SofaRouteMatchKey {
+			extra = append(extra, header)
+		}
+	}
+	return getRouterHeaders(extra)
 }
 
 func (srri *SofaRouteRuleImpl) PathMatchCriterion() api.PathMatchCriterion {
@@ -65,7 +80,9 @@ func (srri *SofaRouteRuleImpl) FinalizeRequestHeaders(headers api.HeaderMap, req
 func (srri *SofaRouteRuleImpl) Match(headers api.HeaderMap, randomValue uint64) api.Route {
 	if value, ok := headers.Get(types.SofaRouteMatchKey); ok {
 		if value == srri.matchValue || srri.matchValue == ".*" {
-			return srri
+			if ConfigUtilityInst.MatchHeaders(headers, srri.extraHeaders) {
+				return srri
+			}
 		}
 	}
 	log.DefaultLogger.Errorf(RouterLogFormat, "sofa rotue rule", "failed match", headers)
@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"testing"
+
+	"mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+func TestSofaRouteRuleExtraHeaders(t *testing.T) {
+	route := func(cluster string, headers ...v2.HeaderMatcher) v2.Router {
+		return v2.Router{
+			RouterConfig: v2.RouterConfig{
+				Match: v2.RouterMatch{Headers: headers},
+				Route: v2.RouteAction{
+					RouterActionConfig: v2.RouterActionConfig{
+						ClusterName: cluster,
+					},
+				},
+			},
+		}
+	}
+	vh, err := NewVirtualHostImpl(&v2.VirtualHost{
+		Domains: []string{"*"},
+		Routers: []v2.Router{
+			route("gray",
+				v2.HeaderMatcher{Name: types.SofaRouteMatchKey, Value: ".*"},
+				v2.HeaderMatcher{Name: "dubbo.tag", Value: "gray"},
+			),
+			route("vip",
+				v2.HeaderMatcher{Name: types.SofaRouteMatchKey, Value: "com.foo.UserService"},
+				v2.HeaderMatcher{Name: "x-arg-level", Value: "^(gold|platinum)$", Regex: true},
+			),
+			route("default",
+				v2.HeaderMatcher{Name: types.SofaRouteMatchKey, Value: ".*"},
+			),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tc := range []struct {
+		headers  map[string]string
+		expected string
+	}{
+		{map[string]string{types.SofaRouteMatchKey: "com.foo.UserService", "dubbo.tag": "gray"}, "gray"},
+		{map[string]string{types.SofaRouteMatchKey: "com.foo.UserService", "x-arg-level": "gold"}, "vip"},
+		{map[string]string{types.SofaRouteMatchKey: "com.foo.UserService", "x-arg-level": "silver"}, "default"},
+		{map[string]string{types.SofaRouteMatchKey: "com.foo.OrderService", "x-arg-level": "gold"}, "default"},
+		{map[string]string{types.SofaRouteMatchKey: "com.foo.OrderService"}, "default"},
+	} {
+		r := vh.GetRouteFromEntries(protocol.CommonHeader(tc.headers), 1)
+		if r == nil || r.RouteRule().ClusterName() != tc.expected {
+			t.Errorf("#%d expected %s, got %v", i, tc.expected, r)
+		}
+	}
+}
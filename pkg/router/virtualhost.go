@@ -47,7 +47,12 @@ func (vh *VirtualHostImpl) addRouteBase(route *v2.Router) error {
 		return err
 	}
 	var router RouteBase
-	if route.Match.Prefix != "" {
+	if route.Match.ConnectMatcher != nil {
+		router = &ConnectRouteRuleImpl{
+			RouteRuleImplBase: base,
+			ports:             route.Match.ConnectMatcher.Ports,
+		}
+	} else if route.Match.Prefix != "" {
 		router = &PrefixRouteRuleImpl{
 			RouteRuleImplBase: base,
 			prefix:            route.Match.Prefix,
@@ -0,0 +1,68 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/server/keeper"
+	"mosn.io/pkg/utils"
+)
+
+func init() {
+	keeper.AddSignalCallback(syscall.SIGUSR2, func() {
+		// drain the connections, and close them after the drain timeout
+		Drain()
+	})
+}
+
+var DrainTimeout = time.Second * 30 //default 30s
+
+var draining uint32
+
+// IsDraining returns true if the servers are draining the connections
+func IsDraining() bool {
+	return atomic.LoadUint32(&draining) == 1
+}
+
+// Drain drains the connections of all the servers gracefully, it is triggered by the signal or the admin api.
+// The connections are asked to go away by the protocols, such as the GOAWAY frame of HTTP/2, 'Connection: close'
+// of HTTP/1 and the goaway frame of the xprotocol sub-protocols implementing xprotocol.GoAwayer,
+// and the connections still alive are closed after the drain timeout.
+// It returns false if the servers are draining already.
+func Drain() bool {
+	return startDrain(true)
+}
+
+func startDrain(close bool) bool {
+	if !atomic.CompareAndSwapUint32(&draining, 0, 1) {
+		return false
+	}
+	log.DefaultLogger.Infof("[server] [drain] start draining connections, drain timeout: %s", DrainTimeout)
+	timeout := DrainTimeout
+	for _, server := range servers {
+		handler := server.handler
+		utils.GoWithRecover(func() {
+			handler.DrainConnections(timeout, close)
+		}, nil)
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"crypto/tls"
+	"io"
+	"testing"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// waitDrainFilter waits for the drain filter of the connection created
+func waitDrainFilter(t *testing.T, filters chan *mockDrainNetworkFilter) *mockDrainNetworkFilter {
+	select {
+	case filter := <-filters:
+		return filter
+	case <-time.After(3 * time.Second):
+		t.Fatal("the drain filter of the connection is not created")
+		return nil
+	}
+}
+
+// waitDrained waits for the drain notification of the filter
+func waitDrained(t *testing.T, filter *mockDrainNetworkFilter, timeout time.Duration) {
+	select {
+	case <-filter.drained:
+	case <-time.After(timeout):
+		t.Fatal("the connection is not drained")
+	}
+}
+
+func TestDrainConnections(t *testing.T) {
+	setup()
+	defer tearDown()
+
+	addrStr := "127.0.0.1:8084"
+	filters := make(chan *mockDrainNetworkFilter, 2)
+	listenerConfig := baseListenerConfig(addrStr, "listener_drain")
+	listenerConfig.FilterChains[0].Filters = []v2.Filter{
+		{
+			Type: "mock_drain_network",
+			Config: map[string]interface{}{
+				"filters": filters,
+			},
+		},
+	}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, listenerConfig); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+
+	dial := func() *tls.Conn {
+		conn, err := tls.Dial("tcp", addrStr, &tls.Config{
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			t.Fatalf("dial failed, %v", err)
+		}
+		return conn
+	}
+
+	conn := dial()
+	defer conn.Close()
+	filter := waitDrainFilter(t, filters)
+	select {
+	case <-filter.drained:
+		t.Fatal("the connection should not be drained before the handler drains")
+	default:
+	}
+
+	done := make(chan struct{})
+	go func() {
+		GetListenerAdapterInstance().defaultConnHandler.DrainConnections(2*time.Second, true)
+		close(done)
+	}()
+	// the connections are notified in the first half of the drain timeout
+	waitDrained(t, filter, time.Second)
+
+	// the connection accepted during draining is notified at once
+	newConn := dial()
+	defer newConn.Close()
+	waitDrained(t, waitDrainFilter(t, filters), time.Second)
+
+	// the connections are closed after the drain timeout
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("drain connections should be finished after the drain timeout")
+	}
+	for i, c := range []*tls.Conn{conn, newConn} {
+		c.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+			t.Errorf("#%d connection should be closed after the drain timeout, but got %v", i, err)
+		}
+	}
+}
@@ -41,6 +41,7 @@ import (
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/mtls"
 	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/utils"
 )
@@ -52,6 +53,8 @@ type connHandler struct {
 	numConnections int64
 	listeners      []*activeListener
 	clusterManager types.ClusterManager
+	// set when the handler starts draining the connections
+	draining uint32
 }
 
 // NewHandler
@@ -160,6 +163,7 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 		rawConfig.UseOriginalDst = lc.UseOriginalDst
 		al.listener.SetUseOriginalDst(lc.UseOriginalDst)
 		al.idleTimeout = lc.ConnectionIdleTimeout
+		rawConfig.StreamTimeoutConfig = lc.StreamTimeoutConfig
 
 		al.listener.SetConfig(rawConfig)
 
@@ -328,6 +332,50 @@ func (ch *connHandler) StopConnection() {
 	}
 }
 
+// DrainConnections notifies the connections one by one, the notifications are spread over the first half of the timeout
+// so that the clients will not reconnect at the same time, and the other half is left for the active streams to finish.
+func (ch *connHandler) DrainConnections(timeout time.Duration, close bool) {
+	atomic.StoreUint32(&ch.draining, 1)
+	deadline := time.Now().Add(timeout)
+
+	conns := ch.activeConnections()
+	log.DefaultLogger.Infof("[server] [conn handler] start draining %d connections, drain timeout: %s", len(conns), timeout)
+	if len(conns) > 0 {
+		interval := timeout / 2 / time.Duration(len(conns))
+		for _, ac := range conns {
+			ac.drain()
+			time.Sleep(interval)
+		}
+	}
+
+	if !close {
+		return
+	}
+	time.Sleep(time.Until(deadline))
+	conns = ch.activeConnections()
+	log.DefaultLogger.Infof("[server] [conn handler] drain timeout, close %d connections", len(conns))
+	for _, ac := range conns {
+		ac.conn.Close(api.FlushWrite, api.LocalClose)
+	}
+}
+
+func (ch *connHandler) isDraining() bool {
+	return atomic.LoadUint32(&ch.draining) == 1
+}
+
+// activeConnections returns the connections of all the listeners
+func (ch *connHandler) activeConnections() []*activeConnection {
+	var conns []*activeConnection
+	for _, l := range ch.listeners {
+		l.connsMux.RLock()
+		for e := l.conns.Front(); e != nil; e = e.Next() {
+			conns = append(conns, e.Value.(*activeConnection))
+		}
+		l.connsMux.RUnlock()
+	}
+	return conns
+}
+
 // ListenerEventListener
 type activeListener struct {
 	listener                    types.Listener
@@ -441,6 +489,7 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyNetworkFilterChainFactories, al.networkFiltersFactories)
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamFilterChainFactories, &al.streamFiltersFactoriesStore)
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyAccessLogs, al.accessLogs)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamTimeoutConfig, al.listener.Config().StreamTimeoutConfig)
 	if rawf != nil {
 		ctx = mosnctx.WithValue(ctx, types.ContextKeyConnectionFd, rawf)
 	}
@@ -465,6 +514,11 @@ func (al *activeListener) OnNewConnection(ctx context.Context, conn api.Connecti
 	}
 	filterManager.InitializeReadFilters()
 
+	// the connection may be closed by the filters, such as the fault inject filter
+	if conn.State() == api.ConnClosed {
+		return
+	}
+
 	if len(filterManager.ListReadFilter()) == 0 &&
 		len(filterManager.ListWriteFilters()) == 0 {
 		// no filter found, close connection
@@ -473,13 +527,19 @@ func (al *activeListener) OnNewConnection(ctx context.Context, conn api.Connecti
 	}
 	ac := newActiveConnection(al, conn)
 
+	// the connection is started with the lock held, so the connections closed by the
+	// drain of the handler in other goroutines are always started
 	al.connsMux.Lock()
-	e := al.conns.PushBack(ac)
-	al.connsMux.Unlock()
-	ac.element = e
+	defer al.connsMux.Unlock()
+	ac.element = al.conns.PushBack(ac)
 
 	atomic.AddInt64(&al.handler.numConnections, 1)
 
+	// the connections accepted during draining are drained at once
+	if al.handler.isDraining() {
+		ac.drain()
+	}
+
 	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 		log.DefaultLogger.Debugf("[server] [listener] accept connection from %s, condId= %d, remote addr:%s", al.listener.Addr().String(), conn.ID(), conn.RemoteAddr().String())
 	}
@@ -518,7 +578,7 @@ func (al *activeListener) newConnection(ctx context.Context, rawc net.Conn) {
 	}
 	newCtx := mosnctx.WithValue(ctx, types.ContextKeyConnectionID, conn.ID())
 
-	conn.SetBufferLimit(al.listener.PerConnBufferLimitBytes())
+	conn.SetBufferLimit(overload.BufferLimit(al.listener.PerConnBufferLimitBytes()))
 
 	al.OnNewConnection(newCtx, conn)
 }
@@ -676,6 +736,15 @@ func (ac *activeConnection) OnEvent(event api.ConnectionEvent) {
 	}
 }
 
+// drain notifies the network filters that can drain the connection gracefully
+func (ac *activeConnection) drain() {
+	for _, filter := range ac.conn.FilterManager().ListReadFilter() {
+		if listener, ok := filter.(types.DrainListener); ok {
+			listener.OnDrain()
+		}
+	}
+}
+
 func sendInheritListeners() (net.Conn, error) {
 	lf := ListListenersFile()
 	if lf == nil {
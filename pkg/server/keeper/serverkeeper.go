@@ -118,6 +118,12 @@ func catchSignalsCrossPlatform() {
 					}
 				}
 			case syscall.SIGUSR2:
+				// drain
+				if cbs, ok := signalCallback[syscall.SIGUSR2]; ok {
+					for _, cb := range cbs {
+						cb()
+					}
+				}
 			}
 		}
 	}, nil)
@@ -19,6 +19,8 @@ package server
 
 import (
 	"context"
+	"errors"
+	"sync"
 
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/types"
@@ -59,6 +61,42 @@ func CreateMockFilerFactory(conf map[string]interface{}) (api.NetworkFilterChain
 	return &mockNetworkFilterFactory{}, nil
 }
 
+// mockDrainNetworkFilter closes the drained channel when it is notified
+type mockDrainNetworkFilter struct {
+	mockNetworkFilter
+	drained chan struct{}
+	once    sync.Once
+}
+
+func (nf *mockDrainNetworkFilter) OnDrain() {
+	nf.once.Do(func() {
+		close(nf.drained)
+	})
+}
+
+// mockDrainNetworkFilterFactory sends the drain filters created to the channel in the config
+type mockDrainNetworkFilterFactory struct {
+	filters chan *mockDrainNetworkFilter
+}
+
+func (ff *mockDrainNetworkFilterFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	filter := &mockDrainNetworkFilter{
+		drained: make(chan struct{}),
+	}
+	callbacks.AddReadFilter(filter)
+	ff.filters <- filter
+}
+
+func CreateMockDrainFilerFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	filters, ok := conf["filters"].(chan *mockDrainNetworkFilter)
+	if !ok {
+		return nil, errors.New("filters channel is required")
+	}
+	return &mockDrainNetworkFilterFactory{
+		filters: filters,
+	}, nil
+}
+
 type mockStreamFilterFactory struct{}
 
 func (ff *mockStreamFilterFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
@@ -71,6 +109,7 @@ func CreateMockStreamFilterFactory(conf map[string]interface{}) (api.StreamFilte
 func init() {
 	api.RegisterNetwork("mock_network", CreateMockFilerFactory)
 	api.RegisterNetwork("mock_network2", CreateMockFilerFactory)
+	api.RegisterNetwork("mock_drain_network", CreateMockDrainFilerFactory)
 	api.RegisterStream("mock_stream", CreateMockStreamFilterFactory)
 	api.RegisterStream("mock_stream2", CreateMockStreamFilterFactory)
 
@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package server
+
+import (
+	"mosn.io/mosn/pkg/overload"
+)
+
+func init() {
+	overload.RegisterResourceMonitor(overload.ActiveConnections, numConnections)
+	overload.ShrinkBufferLimits.AddCallback(func(active bool) {
+		updateBufferLimits()
+	})
+}
+
+// numConnections returns the downstream connections of all the servers
+func numConnections() uint64 {
+	var num uint64
+	for _, server := range servers {
+		num += server.handler.NumConnections()
+	}
+	return num
+}
+
+// updateBufferLimits updates the buffer limits of the active connections when the limits are shrunk or restored
+func updateBufferLimits() {
+	for _, server := range servers {
+		ch, ok := server.handler.(*connHandler)
+		if !ok {
+			continue
+		}
+		for _, ac := range ch.activeConnections() {
+			ac.conn.SetBufferLimit(overload.BufferLimit(ac.listener.listener.PerConnBufferLimitBytes()))
+		}
+	}
+}
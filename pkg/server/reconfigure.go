@@ -100,6 +100,9 @@ func reconfigure(start bool) {
 	// Stop accepting requests
 	StopAccept()
 
+	// Ask the connections to go away, the connections left will be transferred or closed
+	startDrain(false)
+
 	// Wait for all connections to be finished
 	WaitConnectionsDone(GracefulTimeout)
 
@@ -58,6 +58,7 @@ func NewConfig(c *v2.ServerConfig) *Config {
 		LogLevel:        configmanager.ParseLogLevel(c.DefaultLogLevel),
 		LogRoller:       c.GlobalLogRoller,
 		GracefulTimeout: c.GracefulTimeout.Duration,
+		DrainTimeout:    c.DrainTimeout.Duration,
 		Processor:       c.Processor,
 		UseNetpollMode:  c.UseNetpollMode,
 	}
@@ -70,6 +71,10 @@ func NewServer(config *Config, cmFilter types.ClusterManagerFilter, clMng types.
 		if config.GracefulTimeout != 0 {
 			GracefulTimeout = config.GracefulTimeout
 		}
+		//drain timeout setting
+		if config.DrainTimeout != 0 {
+			DrainTimeout = config.DrainTimeout
+		}
 
 		network.UseNetpollMode = config.UseNetpollMode
 		if config.UseNetpollMode {
@@ -31,6 +31,7 @@ type Config struct {
 	LogLevel        log.Level
 	LogRoller       string
 	GracefulTimeout time.Duration
+	DrainTimeout    time.Duration
 	Processor       int
 	UseNetpollMode  bool
 }
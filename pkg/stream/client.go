@@ -19,6 +19,8 @@ package stream
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 
 	metrics "github.com/rcrowley/go-metrics"
 	"mosn.io/api"
@@ -30,13 +32,22 @@ import (
 // stream.Client
 // types.ReadFilter
 // types.StreamConnectionEventListener
+// types.WatermarkListener
 type client struct {
 	Protocol                      types.ProtocolName
 	Connection                    types.ClientConnection
 	Host                          types.Host
 	ClientStreamConnection        types.ClientStreamConnection
 	StreamConnectionEventListener types.StreamConnectionEventListener
-	ConnectedFlag                 bool
+	// Deprecated: ConnectedFlag is not synchronized with the read loop, use Connected and SetConnected instead
+	ConnectedFlag bool
+	// connected is set by the connect goroutine and read by the read loop, so it is accessed atomically
+	connected uint32
+
+	// the receivers of the active streams are notified when the connection crosses the watermarks
+	watermarkMutex     sync.Mutex
+	watermarkListeners map[*clientStreamReceiverWrapper]types.WatermarkListener
+	aboveHighWatermark bool
 }
 
 // NewStreamClient
@@ -123,9 +134,56 @@ func (c *client) NewStream(context context.Context, respReceiver types.StreamRec
 	streamSender := c.ClientStreamConnection.NewStream(context, wrapper)
 	wrapper.stream = streamSender.GetStream()
 
+	if listener, ok := respReceiver.(types.WatermarkListener); ok {
+		wrapper.client = c
+		wrapper.stream.AddEventListener(wrapper)
+		c.addWatermarkListener(wrapper, listener)
+	}
+
 	return streamSender
 }
 
+func (c *client) addWatermarkListener(wrapper *clientStreamReceiverWrapper, listener types.WatermarkListener) {
+	c.watermarkMutex.Lock()
+	defer c.watermarkMutex.Unlock()
+
+	if c.watermarkListeners == nil {
+		c.watermarkListeners = make(map[*clientStreamReceiverWrapper]types.WatermarkListener)
+	}
+	c.watermarkListeners[wrapper] = listener
+	// the new stream should not send more data if the connection is already above the high watermark
+	if c.aboveHighWatermark {
+		listener.OnAboveWriteBufferHighWatermark()
+	}
+}
+
+func (c *client) removeWatermarkListener(wrapper *clientStreamReceiverWrapper) {
+	c.watermarkMutex.Lock()
+	delete(c.watermarkListeners, wrapper)
+	c.watermarkMutex.Unlock()
+}
+
+// types.WatermarkListener
+func (c *client) OnAboveWriteBufferHighWatermark() {
+	c.watermarkMutex.Lock()
+	defer c.watermarkMutex.Unlock()
+
+	c.aboveHighWatermark = true
+	for _, listener := range c.watermarkListeners {
+		listener.OnAboveWriteBufferHighWatermark()
+	}
+}
+
+func (c *client) OnBelowWriteBufferLowWatermark() {
+	c.watermarkMutex.Lock()
+	defer c.watermarkMutex.Unlock()
+
+	c.aboveHighWatermark = false
+	for _, listener := range c.watermarkListeners {
+		listener.OnBelowWriteBufferLowWatermark()
+	}
+}
+
 func (c *client) Close() {
 	c.Connection.Close(api.NoFlush, api.LocalClose)
 }
@@ -138,17 +196,30 @@ func (c *client) OnGoAway() {
 // types.ConnectionEventListener
 // conn callbacks
 func (c *client) OnEvent(event api.ConnectionEvent) {
-	log.DefaultLogger.Debugf("client OnEvent %v, connected %v", event, c.ConnectedFlag)
-	switch event {
-	case api.Connected:
-		c.ConnectedFlag = true
+	if event == api.Connected {
+		c.SetConnected(true)
 	}
+	connected := c.Connected()
+	log.DefaultLogger.Debugf("client OnEvent %v, connected %v", event, connected)
 
-	if reason, ok := c.ClientStreamConnection.CheckReasonError(c.ConnectedFlag, event); !ok {
+	if reason, ok := c.ClientStreamConnection.CheckReasonError(connected, event); !ok {
 		c.ClientStreamConnection.Reset(reason)
 	}
 }
 
+// Connected returns true if the connection of the client has been connected
+func (c *client) Connected() bool {
+	return atomic.LoadUint32(&c.connected) == 1
+}
+
+func (c *client) SetConnected(connected bool) {
+	var v uint32
+	if connected {
+		v = 1
+	}
+	atomic.StoreUint32(&c.connected, v)
+}
+
 // types.ReadFilter
 // read filter, recv upstream data
 func (c *client) OnData(buffer buffer.IoBuffer) api.FilterStatus {
@@ -167,6 +238,9 @@ func (c *client) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {}
 type clientStreamReceiverWrapper struct {
 	stream         types.Stream
 	streamReceiver types.StreamReceiveListener
+
+	// client is set if the receiver listens the watermarks of the connection
+	client *client
 }
 
 func (w *clientStreamReceiverWrapper) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
@@ -177,3 +251,13 @@ func (w *clientStreamReceiverWrapper) OnReceive(ctx context.Context, headers typ
 func (w *clientStreamReceiverWrapper) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
 	w.streamReceiver.OnDecodeError(ctx, err, headers)
 }
+
+// types.StreamEventListener
+// the receiver is not notified by the watermarks after the stream is finished
+func (w *clientStreamReceiverWrapper) OnResetStream(reason types.StreamResetReason) {
+	w.client.removeWatermarkListener(w)
+}
+
+func (w *clientStreamReceiverWrapper) OnDestroyStream() {
+	w.client.removeWatermarkListener(w)
+}
@@ -0,0 +1,440 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http/httputil"
+	"strconv"
+	"sync"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+const (
+	defaultBodyReadSize    = 16 * 1024
+	defaultBodyBufferLimit = 1024 * 1024
+)
+
+var (
+	errBodyClosed = errors.New("http body closed")
+
+	strCRLF      = []byte("\r\n")
+	strLastChunk = []byte("0\r\n\r\n")
+)
+
+// useStream returns true if the http1 body should be streamed, it is configured in the proxy's extend config
+func useStream(ctx context.Context) bool {
+	if config, ok := mosnctx.Get(ctx, types.ContextKeyProxyGeneralConfig).(v2.ProxyGeneralExtendConfig); ok {
+		return config.Http1UseStream
+	}
+	return false
+}
+
+// streamBody is the body of a http1 stream in streaming mode.
+// The decoder writes the body into it while the receiver reads it, and the writing is blocked
+// if the unread data reaches the limit, so the decoder stops reading the connection.
+// Reading the whole body by Bytes, String or Clone waits for the end of the body, and the limit is ignored then.
+// All the methods of buffer.IoBuffer are wrapped with the lock, as the body is shared by the decoder and the receiver.
+type streamBody struct {
+	buf buffer.IoBuffer
+
+	mux        sync.Mutex
+	cond       *sync.Cond
+	limit      int
+	buffering  bool
+	readClosed bool
+	// err is set when the body is written completely (io.EOF) or failed
+	err error
+	// onActive is called when the body is written or read
+	onActive func()
+}
+
+func newStreamBody(limit uint32) *streamBody {
+	b := &streamBody{
+		buf:   buffer.NewIoBuffer(defaultBodyReadSize),
+		limit: int(limit),
+	}
+	if b.limit <= 0 {
+		b.limit = defaultBodyBufferLimit
+	}
+	b.cond = sync.NewCond(&b.mux)
+	return b
+}
+
+// Write is called by the decoder
+func (b *streamBody) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for !b.readClosed && !b.buffering && b.err == nil && b.buf.Len() >= b.limit {
+		b.cond.Wait()
+	}
+	if b.readClosed || b.err != nil {
+		return 0, errBodyClosed
+	}
+	n, err := b.buf.Write(p)
+	b.active()
+	b.cond.Broadcast()
+	return n, err
+}
+
+// Read blocks until some data is received, io.EOF is returned at the end of the body
+func (b *streamBody) Read(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for b.buf.Len() == 0 && b.err == nil {
+		b.cond.Wait()
+	}
+	if b.buf.Len() == 0 {
+		return 0, b.err
+	}
+	n, _ := b.buf.Read(p)
+	b.active()
+	b.cond.Broadcast()
+	return n, nil
+}
+
+// waitEOF waits for the whole body, it is called with the lock held
+func (b *streamBody) waitEOF() {
+	b.buffering = true
+	b.cond.Broadcast()
+	for b.err == nil && !b.readClosed {
+		b.cond.Wait()
+	}
+}
+
+func (b *streamBody) Bytes() []byte {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.waitEOF()
+	return b.buf.Bytes()
+}
+
+func (b *streamBody) String() string {
+	return string(b.Bytes())
+}
+
+func (b *streamBody) Clone() buffer.IoBuffer {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.waitEOF()
+	return b.buf.Clone()
+}
+
+func (b *streamBody) Len() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.buf.Len()
+}
+
+func (b *streamBody) Cap() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.buf.Cap()
+}
+
+func (b *streamBody) Peek(n int) []byte {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.buf.Peek(n)
+}
+
+func (b *streamBody) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+func (b *streamBody) WriteByte(p byte) error {
+	_, err := b.Write([]byte{p})
+	return err
+}
+
+func (b *streamBody) WriteUint16(p uint16) error {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, p)
+	_, err := b.Write(data)
+	return err
+}
+
+func (b *streamBody) WriteUint32(p uint32) error {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, p)
+	_, err := b.Write(data)
+	return err
+}
+
+func (b *streamBody) WriteUint64(p uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, p)
+	_, err := b.Write(data)
+	return err
+}
+
+func (b *streamBody) Append(data []byte) error {
+	_, err := b.Write(data)
+	return err
+}
+
+// ReadOnce reads r once into the body, the lock is not held while reading r
+func (b *streamBody) ReadOnce(r io.Reader) (int64, error) {
+	p := make([]byte, defaultBodyReadSize)
+	n, err := r.Read(p)
+	if n > 0 {
+		if _, werr := b.Write(p[:n]); werr != nil {
+			return 0, werr
+		}
+	}
+	if err == io.EOF {
+		b.SetEOF(true)
+	}
+	return int64(n), err
+}
+
+// ReadFrom reads r into the body until io.EOF
+func (b *streamBody) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		n, err := b.ReadOnce(r)
+		total += n
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// WriteTo writes the body to w until the end of the body
+func (b *streamBody) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	p := make([]byte, defaultBodyReadSize)
+	for {
+		n, err := b.Read(p)
+		if n > 0 {
+			m, werr := w.Write(p[:n])
+			total += int64(m)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+func (b *streamBody) Reset() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.buf.Reset()
+	b.cond.Broadcast()
+}
+
+func (b *streamBody) Alloc(size int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.buf.Alloc(size)
+}
+
+func (b *streamBody) Free() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.buf.Free()
+	b.cond.Broadcast()
+}
+
+func (b *streamBody) EOF() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.buf.EOF()
+}
+
+func (b *streamBody) SetEOF(eof bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.buf.SetEOF(eof)
+}
+
+func (b *streamBody) Drain(offset int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.buf.Drain(offset)
+	b.cond.Broadcast()
+}
+
+// Count always keeps a reference, the body is not a pooled buffer
+func (b *streamBody) Count(count int32) int32 {
+	return 1
+}
+
+// CloseWithError is called by the decoder, a nil error means the body is completed
+func (b *streamBody) CloseWithError(err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.err == nil {
+		b.err = err
+	}
+	b.cond.Broadcast()
+}
+
+// CloseRead implements types.StreamingBuffer
+func (b *streamBody) CloseRead() {
+	b.closeRead()
+}
+
+// SetActiveListener implements types.StreamingBuffer
+func (b *streamBody) SetActiveListener(listener func()) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.onActive = listener
+}
+
+// active calls the active listener, it is called with the lock held
+func (b *streamBody) active() {
+	if b.onActive != nil {
+		b.onActive()
+	}
+}
+
+// closeRead stops the decoder writing the body, and returns true if the body is received completely
+func (b *streamBody) closeRead() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.readClosed = true
+	b.onActive = nil
+	b.cond.Broadcast()
+	return b.err == io.EOF
+}
+
+// readBody reads the body from the connection into the stream body.
+// contentLength follows fasthttp: -1 means chunked and -2 means the body ends with the connection.
+func readBody(br *bufio.Reader, body *streamBody, contentLength int) error {
+	var r io.Reader
+	switch {
+	case contentLength >= 0:
+		r = io.LimitReader(br, int64(contentLength))
+	case contentLength == -1:
+		r = httputil.NewChunkedReader(br)
+	default:
+		r = br
+	}
+
+	b := make([]byte, defaultBodyReadSize)
+	read := 0
+	for {
+		n, err := r.Read(b)
+		read += n
+		if n > 0 {
+			if _, werr := body.Write(b[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			switch {
+			case contentLength >= 0 && read < contentLength:
+				err = io.ErrUnexpectedEOF
+			case contentLength == -1:
+				err = discardTrailer(br)
+			default:
+				err = nil
+			}
+			if err == nil {
+				body.CloseWithError(io.EOF)
+				return nil
+			}
+		}
+		if err != nil {
+			body.CloseWithError(err)
+			return err
+		}
+	}
+}
+
+// discardTrailer skips the trailer part of the chunked body, the trailers are not forwarded
+func discardTrailer(br *bufio.Reader) error {
+	for {
+		line, err := br.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		if len(line) <= len(strCRLF) {
+			return nil
+		}
+	}
+}
+
+// writeBody writes the body to w until the end of the body, the chunked encoding is used if chunked is true.
+func writeBody(w io.Writer, body io.Reader, chunked bool) error {
+	b := make([]byte, defaultBodyReadSize)
+	chunk := make([]byte, 0, defaultBodyReadSize+32)
+	for {
+		n, err := body.Read(b)
+		if n > 0 {
+			data := b[:n]
+			if chunked {
+				chunk = strconv.AppendInt(chunk[:0], int64(n), 16)
+				chunk = append(chunk, strCRLF...)
+				chunk = append(chunk, data...)
+				chunk = append(chunk, strCRLF...)
+				data = chunk
+			}
+			if _, werr := w.Write(data); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			if chunked {
+				_, err = w.Write(strLastChunk)
+				return err
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,225 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/types"
+)
+
+func TestUseStream(t *testing.T) {
+	if useStream(context.Background()) {
+		t.Error("stream should not be used without config")
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyProxyGeneralConfig, v2.ProxyGeneralExtendConfig{
+		Http1UseStream: true,
+	})
+	if !useStream(ctx) {
+		t.Error("stream should be used by config")
+	}
+}
+
+func TestStreamBodyFlowControl(t *testing.T) {
+	body := newStreamBody(4)
+	body.Write([]byte("1234"))
+
+	written := make(chan struct{})
+	go func() {
+		body.Write([]byte("5678"))
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("write should be blocked when the body reaches the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	b := make([]byte, 4)
+	if n, err := body.Read(b); err != nil || string(b[:n]) != "1234" {
+		t.Fatalf("read body unexpected: %s, %v", b[:n], err)
+	}
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("write should be continued after the body is read")
+	}
+
+	body.CloseWithError(nil)
+	data, err := ioutil.ReadAll(body)
+	if err != nil || string(data) != "5678" {
+		t.Fatalf("read body unexpected: %s, %v", data, err)
+	}
+}
+
+func TestStreamBodyBytes(t *testing.T) {
+	body := newStreamBody(4)
+	go func() {
+		// the limit is ignored when the whole body is read
+		for i := 0; i < 4; i++ {
+			body.Write([]byte("1234"))
+		}
+		body.CloseWithError(nil)
+	}()
+	if s := body.String(); s != strings.Repeat("1234", 4) {
+		t.Fatalf("whole body unexpected: %s", s)
+	}
+}
+
+func TestStreamBodyWrite(t *testing.T) {
+	body := newStreamBody(4)
+	body.WriteString("12")
+	body.Append([]byte("3"))
+	body.WriteByte('4')
+	if p := body.Peek(2); string(p) != "12" {
+		t.Errorf("peek body unexpected: %s", p)
+	}
+
+	// all the writing methods are limited
+	written := make(chan struct{})
+	go func() {
+		body.WriteUint16(0x3536)
+		body.CloseWithError(nil)
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("write should be blocked when the body reaches the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	w := &bytes.Buffer{}
+	if n, err := body.WriteTo(w); err != nil || n != 6 || w.String() != "123456" {
+		t.Fatalf("write body to writer unexpected: %s, %d, %v", w.String(), n, err)
+	}
+	<-written
+}
+
+func TestStreamBodyActiveListener(t *testing.T) {
+	body := newStreamBody(0)
+	active := 0
+	body.SetActiveListener(func() {
+		active++
+	})
+	body.Write([]byte("1234"))
+	body.Read(make([]byte, 4))
+	if active != 2 {
+		t.Errorf("the listener should be called when the body is written and read, but got %d", active)
+	}
+	body.closeRead()
+	body.Write([]byte("5678"))
+	if active != 2 {
+		t.Errorf("the listener should not be called after read closed, but got %d", active)
+	}
+}
+
+func TestStreamBodyCloseRead(t *testing.T) {
+	body := newStreamBody(4)
+	body.Write([]byte("1234"))
+
+	result := make(chan error)
+	go func() {
+		_, err := body.Write([]byte("5678"))
+		result <- err
+	}()
+	if body.closeRead() {
+		t.Error("the body is not completed")
+	}
+	select {
+	case err := <-result:
+		if err != errBodyClosed {
+			t.Errorf("write should be failed after read closed, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write should not be blocked after read closed")
+	}
+	if body.Count(-1) <= 0 {
+		t.Error("stream body should not be recycled")
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	testCases := []struct {
+		raw           string
+		contentLength int
+		body          string
+		rest          string
+	}{
+		{"hello worldGET", 11, "hello world", "GET"},
+		{"5\r\nhello\r\n6\r\n world\r\n0\r\n\r\nGET", -1, "hello world", "GET"},
+		{"5\r\nhello\r\n0\r\nX-Trailer: value\r\n\r\nGET", -1, "hello", "GET"},
+		{"hello world", -2, "hello world", ""},
+	}
+	for i, tc := range testCases {
+		br := bufio.NewReader(strings.NewReader(tc.raw))
+		body := newStreamBody(0)
+		if err := readBody(br, body, tc.contentLength); err != nil {
+			t.Fatalf("#%d read body failed: %v", i, err)
+		}
+		if s := body.String(); s != tc.body {
+			t.Errorf("#%d body unexpected: %s", i, s)
+		}
+		rest, _ := ioutil.ReadAll(br)
+		if string(rest) != tc.rest {
+			t.Errorf("#%d the rest of the connection unexpected: %s", i, rest)
+		}
+	}
+
+	// the connection is closed before the body completed
+	body := newStreamBody(0)
+	if err := readBody(bufio.NewReader(strings.NewReader("hello")), body, 11); err == nil {
+		t.Error("read incomplete body should be failed")
+	}
+	if _, err := ioutil.ReadAll(body); err == nil {
+		t.Error("the receiver should get the error of incomplete body")
+	}
+}
+
+func TestWriteBody(t *testing.T) {
+	data := strings.Repeat("a", defaultBodyReadSize+10)
+	for _, chunked := range []bool{true, false} {
+		body := newStreamBody(0)
+		body.Write([]byte(data))
+		body.CloseWithError(nil)
+
+		w := &bytes.Buffer{}
+		if err := writeBody(w, body, chunked); err != nil {
+			t.Fatalf("write body failed: %v", err)
+		}
+
+		contentLength := len(data)
+		if chunked {
+			contentLength = -1
+		}
+		decoded := newStreamBody(0)
+		if err := readBody(bufio.NewReader(w), decoded, contentLength); err != nil {
+			t.Fatalf("decode body failed: %v", err)
+		}
+		if decoded.String() != data {
+			t.Errorf("body unexpected, chunked: %v", chunked)
+		}
+	}
+}
@@ -34,6 +34,7 @@ import (
 	mbuffer "mosn.io/mosn/pkg/buffer"
 	mosnctx "mosn.io/mosn/pkg/context"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/protocol"
 	mosnhttp "mosn.io/mosn/pkg/protocol/http"
 	str "mosn.io/mosn/pkg/stream"
@@ -47,13 +48,18 @@ func init() {
 	str.Register(protocol.HTTP1, &streamConnFactory{})
 }
 
-const defaultMaxRequestBodySize = 4 * 1024 * 1024
+const (
+	defaultMaxRequestBodySize = 4 * 1024 * 1024
+	defaultTunnelReadSize     = 16 * 1024
+)
 
 var (
-	errConnClose = errors.New("connection closed")
+	errConnClose         = errors.New("connection closed")
+	errNotConnectRequest = errors.New("not a CONNECT request")
 
-	strResponseContinue = []byte("HTTP/1.1 100 Continue\r\n\r\n")
-	strErrorResponse    = []byte("HTTP/1.1 400 Bad Request\r\n\r\n")
+	strResponseContinue      = []byte("HTTP/1.1 100 Continue\r\n\r\n")
+	strErrorResponse         = []byte("HTTP/1.1 400 Bad Request\r\n\r\n")
+	strConnectionEstablished = []byte("HTTP/1.1 200 Connection Established\r\n\r\n")
 
 	HKConnection = []byte("Connection") // header key 'Connection'
 	HVKeepAlive  = []byte("keep-alive") // header value 'keep-alive'
@@ -192,6 +198,9 @@ type clientStreamConnection struct {
 	mutex                         sync.RWMutex
 	connectionEventListener       api.ConnectionEventListener
 	streamConnectionEventListener types.StreamConnectionEventListener
+
+	// bodyReading is done when the streaming body of the response is read, the next request waits for it
+	bodyReading sync.WaitGroup
 }
 
 func newClientStreamConnection(ctx context.Context, connection types.ClientConnection,
@@ -240,7 +249,13 @@ func (conn *clientStreamConnection) serve() {
 		}
 
 		// 1. blocking read using fasthttp.Response.Read
-		err := s.response.Read(conn.br)
+		var err error
+		var bodyLength int
+		if s.useStream {
+			bodyLength, err = conn.readResponseHeader(s.response)
+		} else {
+			err = s.response.Read(conn.br)
+		}
 		if err != nil {
 			if s != nil {
 				log.Proxy.Errorf(s.connection.context, "[stream] [http] client stream connection wait response error: %s", err)
@@ -263,18 +278,60 @@ func (conn *clientStreamConnection) serve() {
 			resetConn = true
 		}
 
-		// 3. local reset if header 'Connection: close' exists
-		if resetConn {
+		if bodyLength != 0 {
+			s.body = newStreamBody(conn.conn.BufferLimit())
+			conn.bodyReading.Add(1)
+		}
+		body := s.body
+
+		// 3. local reset if header 'Connection: close' exists.
+		// the connection streaming the body is closed after the body is read, as the connpool
+		// closes the connection once the stream is destroyed, which is earlier than the end of the body.
+		if resetConn && body == nil {
 			// goaway the connpool
 			s.connection.streamConnectionEventListener.OnGoAway()
 		}
 
-		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
-			s.handleResponse()
+		s.handleResponse()
+
+		// the streaming body is read after the response is received, and the connection is closed
+		// if the body is not read completely, as the rest of the body cannot be skipped.
+		if body != nil {
+			err := readBody(conn.br, body, bodyLength)
+			conn.bodyReading.Done()
+			if err != nil {
+				log.Proxy.Errorf(conn.context, "[stream] [http] client stream connection read response body error: %v", err)
+				conn.conn.Close(api.NoFlush, api.LocalClose)
+				return
+			}
+			if resetConn {
+				conn.conn.Close(api.NoFlush, api.LocalClose)
+				return
+			}
 		}
 	}
 }
 
+// readResponseHeader reads the response header in streaming mode, and returns the length of the body to be streamed.
+func (conn *clientStreamConnection) readResponseHeader(response *fasthttp.Response) (int, error) {
+	if err := response.Header.Read(conn.br); err != nil {
+		return 0, err
+	}
+	if response.Header.StatusCode() == fasthttp.StatusContinue {
+		// Read the next response according to http://www.w3.org/Protocols/rfc2616/rfc2616-sec8.html .
+		if err := response.Header.Read(conn.br); err != nil {
+			return 0, err
+		}
+	}
+
+	statusCode := response.Header.StatusCode()
+	if response.SkipBody || statusCode < fasthttp.StatusOK ||
+		statusCode == fasthttp.StatusNoContent || statusCode == fasthttp.StatusNotModified {
+		return 0, nil
+	}
+	return response.Header.ContentLength(), nil
+}
+
 func (conn *clientStreamConnection) GoAway() {}
 
 func (conn *clientStreamConnection) NewStream(ctx context.Context, receiver types.StreamReceiveListener) types.StreamSender {
@@ -282,10 +339,11 @@ func (conn *clientStreamConnection) NewStream(ctx context.Context, receiver type
 	buffers := httpBuffersByContext(ctx)
 	s := &buffers.clientStream
 	s.stream = stream{
-		id:       id,
-		ctx:      mosnctx.WithValue(ctx, types.ContextKeyStreamID, id),
-		request:  &buffers.clientRequest,
-		receiver: receiver,
+		id:        id,
+		ctx:       mosnctx.WithValue(ctx, types.ContextKeyStreamID, id),
+		request:   &buffers.clientRequest,
+		receiver:  receiver,
+		useStream: useStream(ctx),
 	}
 	s.connection = conn
 
@@ -325,9 +383,10 @@ func (conn *clientStreamConnection) CheckReasonError(connected bool, event api.C
 }
 
 func (conn *clientStreamConnection) Reset(reason types.StreamResetReason) {
+	// the reason is set before the channels closed, so that the reader gets the right error
+	conn.resetReason = reason
 	close(conn.bufChan)
 	close(conn.connClosed)
-	conn.resetReason = reason
 }
 
 // types.ServerStreamConnection
@@ -335,11 +394,19 @@ type serverStreamConnection struct {
 	streamConnection
 	contextManager *str.ContextManager
 
-	close bool
+	// close is set to 1 if the connection should be closed after the response, the response is sent
+	// with 'Connection: close' then
+	close uint32
 
 	stream                   *serverStream
 	mutex                    sync.RWMutex
 	serverStreamConnListener types.ServerStreamConnectionEventListener
+
+	// tunnel is set when the connection is switched to a tunnel by a CONNECT request
+	tunnel types.TunnelReceiver
+
+	// useStream is true if the request body is streamed
+	useStream bool
 }
 
 func newServerStreamConnection(ctx context.Context, connection api.Connection,
@@ -353,6 +420,7 @@ func newServerStreamConnection(ctx context.Context, connection api.Connection,
 		},
 		contextManager:           str.NewContextManager(ctx),
 		serverStreamConnListener: callbacks,
+		useStream:                useStream(ctx),
 	}
 
 	// init first context
@@ -366,7 +434,7 @@ func newServerStreamConnection(ctx context.Context, connection api.Connection,
 
 	// set not support transfer connection
 	ssc.conn.SetTransferEventListener(func() bool {
-		ssc.close = true
+		atomic.StoreUint32(&ssc.close, 1)
 		return false
 	})
 
@@ -394,8 +462,14 @@ func (conn *serverStreamConnection) serve() {
 		request.Header.DisableNormalizing()
 
 		// 2. blocking read using fasthttp.Request.Read
-		err := request.ReadLimitBody(conn.br, defaultMaxRequestBodySize)
-		if err == nil {
+		var err error
+		var bodyLength int
+		if conn.useStream {
+			bodyLength, err = conn.readRequestHeader(request)
+		} else {
+			err = request.ReadLimitBody(conn.br, defaultMaxRequestBodySize)
+		}
+		if err == nil && !conn.useStream {
 			// 3. 'Expect: 100-continue' request handling.
 			// See http://www.w3.org/Protocols/rfc2616/rfc2616-sec8.html for details.
 			if request.MayContinue() {
@@ -435,6 +509,9 @@ func (conn *serverStreamConnection) serve() {
 		s.connection = conn
 		s.responseDoneChan = make(chan bool, 1)
 		s.header = mosnhttp.RequestHeader{&s.request.Header, nil}
+		if bodyLength != 0 {
+			s.body = newStreamBody(conn.conn.BufferLimit())
+		}
 
 		var span types.Span
 		if trace.IsEnabled() {
@@ -455,8 +532,13 @@ func (conn *serverStreamConnection) serve() {
 		conn.stream = s
 		conn.mutex.Unlock()
 
-		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
-			s.handleRequest()
+		s.handleRequest()
+
+		// the streaming body is read after the request is received, the body is closed if the reading failed.
+		if s.body != nil {
+			if err := readBody(conn.br, s.body, bodyLength); err != nil && log.Proxy.GetLogLevel() >= log.DEBUG {
+				log.Proxy.Debugf(s.stream.ctx, "[stream] [http] read request body failed: %v, requestId = %v", err, s.stream.id)
+			}
 		}
 
 		// 5. wait for proxy done
@@ -466,10 +548,57 @@ func (conn *serverStreamConnection) serve() {
 			return
 		}
 
+		// 6. the connection is not http any more after the tunnel established
+		if conn.tunnel != nil {
+			conn.serveTunnel()
+			return
+		}
+
 		conn.contextManager.Next()
 	}
 }
 
+// readRequestHeader reads the request header in streaming mode, and returns the length of the body to be streamed.
+// the body of GET and HEAD request is still buffered, as the body length of them is ignored by fasthttp.
+func (conn *serverStreamConnection) readRequestHeader(request *fasthttp.Request) (int, error) {
+	if err := request.Header.Read(conn.br); err != nil {
+		return 0, err
+	}
+
+	if request.MayContinue() {
+		conn.conn.Write(buffer.NewIoBufferBytes(strResponseContinue))
+		request.Header.Del("Expect")
+	}
+
+	bodyLength := request.Header.ContentLength()
+	if bodyLength > 0 || bodyLength == -1 {
+		return bodyLength, nil
+	}
+	return 0, request.ContinueReadBody(conn.br, defaultMaxRequestBodySize)
+}
+
+// serveTunnel passes the raw data to the tunnel until the connection closed
+func (conn *serverStreamConnection) serveTunnel() {
+	b := make([]byte, defaultTunnelReadSize)
+	for {
+		n, err := conn.br.Read(b)
+		if n > 0 {
+			data := buffer.GetIoBuffer(n)
+			data.Write(b[:n])
+			conn.tunnel.OnTunnelData(data)
+		}
+		if err != nil {
+			conn.tunnel.OnTunnelClose()
+			return
+		}
+	}
+}
+
+// GoAway closes the connection after the current response, the response is sent with 'Connection: close'
+func (conn *serverStreamConnection) GoAway() {
+	atomic.StoreUint32(&conn.close, 1)
+}
+
 func (conn *serverStreamConnection) ActiveStreamsNum() int {
 	conn.mutex.RLock()
 	defer conn.mutex.RUnlock()
@@ -508,15 +637,20 @@ func (conn *serverStreamConnection) Reset(reason types.StreamResetReason) {
 type stream struct {
 	str.BaseStream
 
-	id               uint64
-	readDisableCount int32
-	ctx              context.Context
+	id  uint64
+	ctx context.Context
 
 	// NOTICE: fasthttp ctx and its member not allowed holding by others after request handle finished
 	request  *fasthttp.Request
 	response *fasthttp.Response
 
 	receiver types.StreamReceiveListener
+
+	// useStream is true if the body is streamed, body is the streaming body received
+	useStream bool
+	body      *streamBody
+	// sendBody is the streaming body to be sent
+	sendBody types.StreamingBuffer
 }
 
 // types.Stream
@@ -557,7 +691,15 @@ func (s *clientStream) AppendHeaders(context context.Context, headersIn types.He
 }
 
 func (s *clientStream) AppendData(context context.Context, data buffer.IoBuffer, endStream bool) error {
-	s.request.SetBody(data.Bytes())
+	if body, ok := data.(types.StreamingBuffer); ok {
+		s.sendBody = body
+		// keep the content length of the body if it is known
+		if s.request.Header.ContentLength() <= 0 {
+			s.request.Header.SetContentLength(-1)
+		}
+	} else {
+		s.request.SetBody(data.Bytes())
+	}
 
 	if endStream {
 		s.endStream()
@@ -591,20 +733,27 @@ func (s *clientStream) endStream() {
 	s.connection.requestSent <- true
 }
 
+// ReadDisable disables the connection, http1 runs one stream on the connection at a time
 func (s *clientStream) ReadDisable(disable bool) {
-	if disable {
-		atomic.AddInt32(&s.readDisableCount, 1)
-	} else {
-		newCount := atomic.AddInt32(&s.readDisableCount, -1)
-
-		if newCount <= 0 {
-			s.handleResponse()
-		}
-	}
+	s.connection.conn.SetReadDisable(disable)
 }
 
 func (s *clientStream) doSend() (err error) {
-	_, err = s.request.WriteTo(s.connection)
+	// the response of the previous request may still be streaming, the request is sent after that
+	s.connection.bodyReading.Wait()
+
+	if s.sendBody == nil {
+		_, err = s.request.WriteTo(s.connection)
+		return
+	}
+
+	if _, err = s.connection.Write(s.request.Header.Header()); err != nil {
+		return
+	}
+	if err = writeBody(s.connection, s.sendBody, s.request.Header.ContentLength() == -1); err != nil {
+		// the request is broken, so the connection should be closed
+		s.connection.conn.Close(api.NoFlush, api.LocalClose)
+	}
 	return
 }
 
@@ -626,7 +775,9 @@ func (s *clientStream) handleResponse() {
 		s.connection.stream = nil
 		s.connection.mutex.Unlock()
 
-		if hasData {
+		if s.body != nil {
+			s.receiver.OnReceive(s.ctx, header, s.body, nil)
+		} else if hasData {
 			s.receiver.OnReceive(s.ctx, header, buffer.NewIoBufferBytes(s.response.Body()), nil)
 		} else {
 			s.receiver.OnReceive(s.ctx, header, nil, nil)
@@ -688,7 +839,11 @@ func (s *serverStream) AppendHeaders(context context.Context, headersIn types.He
 }
 
 func (s *serverStream) AppendData(context context.Context, data buffer.IoBuffer, endStream bool) error {
-	s.response.SetBody(data.Bytes())
+	if body, ok := data.(types.StreamingBuffer); ok {
+		s.sendBody = body
+	} else {
+		s.response.SetBody(data.Bytes())
+	}
 
 	if endStream {
 		s.endStream()
@@ -712,7 +867,8 @@ func (s *serverStream) endStream() {
 	}
 
 	// check if we need close connection
-	if s.connection.close || s.request.Header.ConnectionClose() {
+	// the keep-alive is disabled when the process is overloaded
+	if atomic.LoadUint32(&s.connection.close) == 1 || s.request.Header.ConnectionClose() || overload.DisableHTTPKeepAlive.Active() {
 		s.response.SetConnectionClose()
 		resetConn = true
 	} else if !s.request.Header.IsHTTP11() {
@@ -721,6 +877,22 @@ func (s *serverStream) endStream() {
 		// connections are keep-alive by default.
 		s.response.Header.SetCanonical(HKConnection, HVKeepAlive)
 	}
+
+	// the rest of the request body is discarded, the connection cannot be reused if the body is not read completely
+	if s.body != nil && !s.body.closeRead() {
+		s.response.SetConnectionClose()
+		resetConn = true
+	}
+
+	// the length of the streaming body is unknown, use chunked encoding if the downstream supports it,
+	// otherwise the body ends with the connection.
+	if s.sendBody != nil && s.response.Header.ContentLength() == -2 {
+		if s.request.Header.IsHTTP11() {
+			s.response.Header.SetContentLength(-1)
+		} else {
+			resetConn = true
+		}
+	}
 	defer s.DestroyStream()
 
 	s.doSend()
@@ -737,20 +909,32 @@ func (s *serverStream) endStream() {
 	s.connection.mutex.Unlock()
 }
 
+// ReadDisable disables the connection, http1 runs one stream on the connection at a time
 func (s *serverStream) ReadDisable(disable bool) {
-	if disable {
-		atomic.AddInt32(&s.readDisableCount, 1)
-	} else {
-		newCount := atomic.AddInt32(&s.readDisableCount, -1)
+	s.connection.conn.SetReadDisable(disable)
+}
 
-		if newCount <= 0 {
-			s.handleRequest()
-		}
+// ResetStream closes the connection if the stream is still in processing,
+// as http1 cannot reset a stream without closing the connection
+func (s *serverStream) ResetStream(reason types.StreamResetReason) {
+	s.connection.mutex.Lock()
+	active := s.connection.stream == s
+	s.connection.mutex.Unlock()
+
+	s.stream.ResetStream(reason)
+	if active {
+		s.connection.conn.Close(api.NoFlush, api.LocalClose)
 	}
 }
 
 func (s *serverStream) doSend() {
-	if _, err := s.response.WriteTo(s.connection); err != nil {
+	var err error
+	if s.sendBody != nil {
+		err = s.sendStreamingResponse()
+	} else {
+		_, err = s.response.WriteTo(s.connection)
+	}
+	if err != nil {
 		log.Proxy.Errorf(s.stream.ctx, "[stream] [http] send server response error: %+v", err)
 	} else {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
@@ -759,6 +943,24 @@ func (s *serverStream) doSend() {
 	}
 }
 
+// sendStreamingResponse sends the response header, and then the body until the end of it
+func (s *serverStream) sendStreamingResponse() error {
+	defer s.sendBody.CloseRead()
+
+	if _, err := s.connection.Write(s.response.Header.Header()); err != nil {
+		return err
+	}
+	if s.response.SkipBody {
+		return nil
+	}
+	if err := writeBody(s.connection, s.sendBody, s.response.Header.ContentLength() == -1); err != nil {
+		// the response is broken, so the connection should be closed
+		s.connection.conn.Close(api.NoFlush, api.LocalClose)
+		return err
+	}
+	return nil
+}
+
 func (s *serverStream) handleRequest() {
 	if s.request != nil {
 		// set non-header info in request-line, like method, uri
@@ -769,7 +971,9 @@ func (s *serverStream) handleRequest() {
 			hasData = false
 		}
 
-		if hasData {
+		if s.body != nil {
+			s.receiver.OnReceive(s.ctx, s.header, s.body, nil)
+		} else if hasData {
 			s.receiver.OnReceive(s.ctx, s.header, buffer.NewIoBufferBytes(s.request.Body()), nil)
 		} else {
 			s.receiver.OnReceive(s.ctx, s.header, nil, nil)
@@ -781,8 +985,50 @@ func (s *serverStream) GetStream() types.Stream {
 	return s
 }
 
+// types.TunnelSender
+func (s *serverStream) StartTunnel(context context.Context, receiver types.TunnelReceiver) error {
+	if !s.request.Header.IsConnect() {
+		return errNotConnectRequest
+	}
+
+	if err := s.connection.conn.Write(buffer.NewIoBufferBytes(strConnectionEstablished)); err != nil {
+		return err
+	}
+
+	s.connection.tunnel = receiver
+	s.responseDoneChan <- true
+
+	return nil
+}
+
+func (s *serverStream) SendTunnelData(data buffer.IoBuffer) error {
+	return s.connection.conn.Write(data)
+}
+
+func (s *serverStream) ReadDisableTunnel(disable bool) {
+	s.connection.conn.SetReadDisable(disable)
+}
+
+func (s *serverStream) CloseTunnel() {
+	s.connection.conn.Close(api.FlushWrite, api.LocalClose)
+
+	s.connection.mutex.Lock()
+	s.connection.stream = nil
+	s.connection.mutex.Unlock()
+
+	s.DestroyStream()
+}
+
 // consider host, method, path are necessary, but check querystring
 func injectInternalHeaders(headers mosnhttp.RequestHeader, uri *fasthttp.URI) {
+	// the request-target of CONNECT is the authority of the tunnel, no path
+	if headers.IsConnect() {
+		authority := string(headers.RequestURI())
+		headers.Set(protocol.MosnHeaderHostKey, authority)
+		headers.Set(protocol.IstioHeaderHostKey, authority)
+		headers.Set(protocol.MosnHeaderMethod, protocol.MethodConnect)
+		return
+	}
 	// 1. host
 	headers.Set(protocol.MosnHeaderHostKey, string(uri.Host()))
 	// 2. :authority
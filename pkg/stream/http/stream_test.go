@@ -222,6 +222,27 @@ func Test_internal_header(t *testing.T) {
 	}
 }
 
+func Test_internal_header_connect(t *testing.T) {
+	header := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	uri := fasthttp.AcquireURI()
+
+	header.SetMethod("CONNECT")
+	header.SetRequestURI("example.com:443")
+	uri.Parse(nil, header.RequestURI())
+
+	injectInternalHeaders(header, uri)
+
+	if host, _ := header.Get(protocol.MosnHeaderHostKey); host != "example.com:443" {
+		t.Errorf("unexpected CONNECT authority: %s", host)
+	}
+	if method, _ := header.Get(protocol.MosnHeaderMethod); method != protocol.MethodConnect {
+		t.Errorf("unexpected CONNECT method: %s", method)
+	}
+	if _, ok := header.Get(protocol.MosnHeaderPathKey); ok {
+		t.Errorf("CONNECT request should have no path")
+	}
+}
+
 func Test_serverStream_handleRequest(t *testing.T) {
 	type fields struct {
 		stream           stream
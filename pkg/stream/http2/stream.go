@@ -25,6 +25,7 @@ import (
 	"net/url"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"mosn.io/api"
 	mbuffer "mosn.io/mosn/pkg/buffer"
@@ -215,6 +216,11 @@ func (conn *serverStreamConnection) Dispatch(buf types.IoBuffer) {
 	}
 }
 
+// GoAway sends the GOAWAY frame to the client, the client should not create new streams on the connection
+func (conn *serverStreamConnection) GoAway() {
+	conn.sc.GracefulShutdown()
+}
+
 func (conn *serverStreamConnection) ActiveStreamsNum() int {
 	conn.mutex.RLock()
 	defer conn.mutex.Unlock()
@@ -303,6 +309,10 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 
 		if endStream {
 			stream.receiver.OnReceive(ctx, header, nil, nil)
+		} else if h2s.Request.Method == http.MethodConnect {
+			// the data frames of CONNECT request are tunnel data, no need to wait for them
+			stream.header = header
+			stream.receiver.OnReceive(ctx, header, nil, nil)
 		} else {
 			stream.header = header
 		}
@@ -315,6 +325,12 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 		return
 	}
 
+	// tunnel
+	if stream.h2s.Request.Method == http.MethodConnect {
+		stream.onTunnelData(data, endStream)
+		return
+	}
+
 	// data
 	if data != nil {
 		log.DefaultLogger.Debugf("http2 server receive data: %d", id)
@@ -406,6 +422,16 @@ type serverStream struct {
 	stream
 	h2s *http2.MStream
 	sc  *serverStreamConnection
+
+	// tunnel of CONNECT request, the data received before the tunnel started are kept in tunnelData
+	tunnelMux  sync.Mutex
+	tunnel     types.TunnelReceiver
+	tunnelData []types.IoBuffer
+	tunnelEnd  bool
+	// the flow control window of the tunnel data is not returned while tunnelReadDisabled is set,
+	// tunnelUnconsumed is the data received but not returned yet
+	tunnelReadDisabled uint32
+	tunnelUnconsumed   int64
 }
 
 // types.StreamSender
@@ -496,6 +522,99 @@ func (s *serverStream) GetStream() types.Stream {
 	return s
 }
 
+func (s *serverStream) onTunnelData(data []byte, endStream bool) {
+	s.tunnelMux.Lock()
+	defer s.tunnelMux.Unlock()
+
+	if len(data) > 0 {
+		buf := buffer.NewIoBufferBytes(data).Clone()
+		if s.tunnel != nil {
+			s.tunnel.OnTunnelData(buf)
+			s.consumeTunnelData(len(data))
+		} else {
+			s.tunnelData = append(s.tunnelData, buf)
+		}
+	}
+
+	if endStream {
+		if s.tunnel != nil {
+			s.tunnel.OnTunnelClose()
+		} else {
+			s.tunnelEnd = true
+		}
+	}
+}
+
+// types.TunnelSender
+func (s *serverStream) StartTunnel(ctx context.Context, receiver types.TunnelReceiver) error {
+	s.h2s.Response = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+	}
+	if err := s.h2s.SendHeaders(); err != nil {
+		return err
+	}
+
+	log.Proxy.Debugf(s.ctx, "http2 server start tunnel id = %d", s.id)
+
+	s.tunnelMux.Lock()
+	defer s.tunnelMux.Unlock()
+
+	s.tunnel = receiver
+	for _, buf := range s.tunnelData {
+		n := buf.Len()
+		receiver.OnTunnelData(buf)
+		s.consumeTunnelData(n)
+	}
+	s.tunnelData = nil
+	if s.tunnelEnd {
+		receiver.OnTunnelClose()
+	}
+
+	return nil
+}
+
+func (s *serverStream) SendTunnelData(data buffer.IoBuffer) error {
+	err := s.h2s.SendDataFrame(data.Bytes(), false)
+	buffer.PutIoBuffer(data)
+	return err
+}
+
+// ReadDisableTunnel stops returning the flow control window of the tunnel data,
+// so the client stops sending the data when the window is used up
+func (s *serverStream) ReadDisableTunnel(disable bool) {
+	if disable {
+		atomic.StoreUint32(&s.tunnelReadDisabled, 1)
+		return
+	}
+	atomic.StoreUint32(&s.tunnelReadDisabled, 0)
+	s.consumeTunnelData(0)
+}
+
+// consumeTunnelData returns the flow control window of the tunnel data if the tunnel is not read disabled.
+// It does not hold the tunnel lock, as the tunnel is read disabled by the writing of the tunnel data.
+func (s *serverStream) consumeTunnelData(n int) {
+	atomic.AddInt64(&s.tunnelUnconsumed, int64(n))
+	if atomic.LoadUint32(&s.tunnelReadDisabled) == 1 {
+		return
+	}
+	if unconsumed := atomic.SwapInt64(&s.tunnelUnconsumed, 0); unconsumed > 0 {
+		s.h2s.ConsumeData(int(unconsumed))
+	}
+}
+
+func (s *serverStream) CloseTunnel() {
+	if err := s.h2s.SendDataFrame(nil, true); err != nil {
+		log.Proxy.Errorf(s.ctx, "http2 server close tunnel id = %d, error = %v", s.id, err)
+	}
+
+	s.sc.mutex.Lock()
+	delete(s.sc.streams, s.id)
+	s.sc.mutex.Unlock()
+
+	s.DestroyStream()
+}
+
 type clientStreamConnection struct {
 	streamConnection
 	mutex                         sync.RWMutex
@@ -60,3 +60,41 @@ func TestStreamEventListener(t *testing.T) {
 		base.ResetStream(types.StreamLocalReset)
 	}
 }
+
+type watermarkReceiver struct {
+	above bool
+}
+
+func (r *watermarkReceiver) OnAboveWriteBufferHighWatermark() {
+	r.above = true
+}
+
+func (r *watermarkReceiver) OnBelowWriteBufferLowWatermark() {
+	r.above = false
+}
+
+func TestClientWatermarkListener(t *testing.T) {
+	c := &client{}
+	r1 := &watermarkReceiver{}
+	w1 := &clientStreamReceiverWrapper{client: c}
+	c.addWatermarkListener(w1, r1)
+
+	c.OnAboveWriteBufferHighWatermark()
+	if !r1.above {
+		t.Fatal("the receiver should be notified above the high watermark")
+	}
+	// the new stream is notified immediately
+	r2 := &watermarkReceiver{}
+	w2 := &clientStreamReceiverWrapper{client: c}
+	c.addWatermarkListener(w2, r2)
+	if !r2.above {
+		t.Fatal("the new receiver should be notified if the connection is above the high watermark")
+	}
+
+	// the finished stream is not notified any more
+	w2.OnDestroyStream()
+	c.OnBelowWriteBufferLowWatermark()
+	if r1.above || !r2.above {
+		t.Fatalf("unexpected watermark notification, r1: %v, r2: %v", r1.above, r2.above)
+	}
+}
@@ -49,6 +49,7 @@ type streamConn struct {
 	protocol xprotocol.XProtocol
 
 	serverCallbacks types.ServerStreamConnectionEventListener // server side fields
+	serverStreamId  uint64
 
 	clientMutex     sync.RWMutex // client side fields
 	clientStreamId  uint64
@@ -203,9 +204,30 @@ func (sc *streamConn) Protocol() types.ProtocolName {
 	return protocol.Xprotocol
 }
 
+// GoAway sends the goaway frame to the client if the protocol implements xprotocol.GoAwayer,
+// the client should not send new requests on the connection then.
+// It does nothing for the protocols without goaway command, the connection is closed by the drain timeout.
 func (sc *streamConn) GoAway() {
-	// unsupported
-	// TODO: client-side conn pool go away
+	if sc.serverCallbacks == nil || sc.protocol == nil {
+		return
+	}
+	goAwayer, ok := sc.protocol.(xprotocol.GoAwayer)
+	if !ok {
+		return
+	}
+	frame := goAwayer.GoAway(atomic.AddUint64(&sc.clientStreamId, 1))
+	if frame == nil {
+		return
+	}
+	buf, err := sc.protocol.Encode(sc.ctx, frame)
+	if err != nil {
+		log.Proxy.Errorf(sc.ctx, "[stream] [xprotocol] conn %d, encode goaway frame failed: %v", sc.netConn.ID(), err)
+		return
+	}
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(sc.ctx, "[stream] [xprotocol] conn %d, send goaway", sc.netConn.ID())
+	}
+	sc.netConn.Write(buf)
 }
 
 func (sc *streamConn) ActiveStreamsNum() int {
@@ -243,7 +265,8 @@ func (sc *streamConn) handleError(ctx context.Context, frame interface{}, err er
 	// valid request frame with positive requestID, send exception response in this case
 	if frame != nil {
 		if xframe, ok := frame.(xprotocol.XFrame); ok && (xframe.GetStreamType() == xprotocol.Request) {
-			if requestId := xframe.GetRequestId(); requestId > 0 {
+			// the frames without request id are responded by order
+			if mux, ok := xframe.(xprotocol.Multiplexing); !ok || mux.GetRequestId() > 0 {
 
 				// TODO: to see some error handling if is necessary to passed to proxy level, or just handle it at stream level
 				stream := sc.newServerStream(ctx, xframe)
@@ -288,7 +311,7 @@ func (sc *streamConn) handleRequest(ctx context.Context, frame xprotocol.XFrame,
 	// 2. goaway process
 	if predicate, ok := frame.(xprotocol.GoAwayPredicate); ok && predicate.IsGoAwayFrame() && sc.clientCallbacks != nil {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
-			log.Proxy.Debugf(ctx, "[stream] [xprotocol] goaway received, requestId = %v", frame.GetRequestId())
+			log.Proxy.Debugf(ctx, "[stream] [xprotocol] goaway received, connection = %d", sc.netConn.ID())
 		}
 		sc.clientCallbacks.OnGoAway()
 		return
@@ -336,12 +359,11 @@ func (sc *streamConn) handleRequest(ctx context.Context, frame xprotocol.XFrame,
 }
 
 func (sc *streamConn) handleResponse(ctx context.Context, frame xprotocol.XFrame) {
-	requestId := frame.GetRequestId()
-
 	// for client stream, remove stream on response read
 	sc.clientMutex.Lock()
 	defer sc.clientMutex.Unlock()
 
+	requestId := sc.pairRequestId(frame)
 	if clientStream, ok := sc.clientStreams[requestId]; ok {
 		delete(sc.clientStreams, requestId)
 
@@ -356,13 +378,33 @@ func (sc *streamConn) handleResponse(ctx context.Context, frame xprotocol.XFrame
 	}
 }
 
+// pairRequestId returns the request id of the client stream that the response belongs to.
+// The response without request id is paired with the earliest pending request,
+// the client stream id is increased monotonically.
+func (sc *streamConn) pairRequestId(frame xprotocol.XFrame) uint64 {
+	if mux, ok := frame.(xprotocol.Multiplexing); ok {
+		return mux.GetRequestId()
+	}
+	var requestId uint64
+	for id := range sc.clientStreams {
+		if requestId == 0 || id < requestId {
+			requestId = id
+		}
+	}
+	return requestId
+}
+
 func (sc *streamConn) newServerStream(ctx context.Context, frame xprotocol.XFrame) *xStream {
 	//serverStream := &xStream{}
 
 	buffers := streamBuffersByContext(ctx)
 	serverStream := &buffers.serverStream
 
-	serverStream.id = frame.GetRequestId()
+	if mux, ok := frame.(xprotocol.Multiplexing); ok {
+		serverStream.id = mux.GetRequestId()
+	} else {
+		serverStream.id = atomic.AddUint64(&sc.serverStreamId, 1)
+	}
 	serverStream.direction = stream.ServerStream
 	serverStream.ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamID, serverStream.id)
 	serverStream.ctx = mosnctx.WithValue(ctx, types.ContextSubProtocol, string(sc.protocol.Name()))
@@ -23,11 +23,14 @@ import (
 	"sync/atomic"
 	"time"
 
+	gometrics "github.com/rcrowley/go-metrics"
 	"mosn.io/mosn/pkg/protocol/xprotocol"
 
 	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
 	mosnctx "mosn.io/mosn/pkg/context"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/protocol"
 	str "mosn.io/mosn/pkg/stream"
@@ -50,10 +53,14 @@ func init() {
 // activeClient used as connected client
 // host is the upstream
 type connPool struct {
-	activeClients sync.Map //sub protocol -> activeClient
+	activeClients sync.Map //sub protocol -> clientGroup
+	pingPongPools sync.Map //sub protocol -> pingPongPool
 	host          atomic.Value
 	mux           sync.Mutex
 	supportTLS    bool
+	config        v2.ConnPoolConfig
+	// connected is the number of connected multiplexed clients of all sub protocols
+	connected int64
 }
 
 // NewConnPool
@@ -61,10 +68,36 @@ func NewConnPool(host types.Host) types.ConnectionPool {
 	p := &connPool{
 		supportTLS: host.SupportTLS(),
 	}
+	if getter, ok := host.ClusterInfo().(types.ConnPoolConfigGetter); ok {
+		p.config = getter.ConnPoolConfig()
+	}
+	if p.config.ConnectionsPerHost == 0 {
+		p.config.ConnectionsPerHost = 1
+	}
 	p.host.Store(host)
 	return p
 }
 
+// clientGroup holds the multiplexed connections of a sub protocol, each slot of the clients
+// connects and reconnects independently, so a reconnecting client does not stall the others
+type clientGroup struct {
+	clients atomic.Value // []*activeClient, copied on write with the pool mutex held
+	// cursor is the start slot of the next assignment
+	cursor uint32
+}
+
+func (g *clientGroup) load() []*activeClient {
+	return g.clients.Load().([]*activeClient)
+}
+
+// replace replaces the client of the slot, the caller should hold the pool mutex
+func (g *clientGroup) replace(index int, client *activeClient) {
+	clients := make([]*activeClient, len(g.load()))
+	copy(clients, g.load())
+	clients[index] = client
+	g.clients.Store(clients)
+}
+
 func (p *connPool) SupportTLS() bool {
 	return p.supportTLS
 }
@@ -72,17 +105,22 @@ func (p *connPool) SupportTLS() bool {
 func (p *connPool) init(client *activeClient, sub types.ProtocolName) {
 	utils.GoWithRecover(func() {
 		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
-			log.DefaultLogger.Debugf("[stream] [sofarpc] [connpool] init host %s", p.Host().AddressString())
+			log.DefaultLogger.Debugf("[stream] [sofarpc] [connpool] init host %s, slot %d", p.Host().AddressString(), client.index)
 		}
 
+		group := p.clientGroup(sub)
+		newClient := newActiveClient(context.Background(), sub, client.index, p)
 		p.mux.Lock()
 		defer p.mux.Unlock()
-		client := newActiveClient(context.Background(), sub, p)
-		if client != nil {
-			client.state = Connected
-			p.activeClients.Store(sub, client)
+		// the connection may be closed before the lock is taken, the close event finds the
+		// placeholder in the slot and leaves it, so the slot is reset here
+		if newClient != nil && atomic.LoadUint32(&newClient.closed) == 0 {
+			newClient.state = Connected
+			group.replace(client.index, newClient)
+			p.Host().HostStats().UpstreamConnectionPoolConnected.Update(atomic.AddInt64(&p.connected, 1))
 		} else {
-			p.activeClients.Delete(sub)
+			// the slot is initialized again by the next CheckAndInit
+			atomic.StoreUint32(&client.state, Init)
 		}
 	}, nil)
 }
@@ -101,51 +139,98 @@ func (p *connPool) UpdateHost(h types.Host) {
 	p.host.Store(h)
 }
 
-func (p *connPool) CheckAndInit(ctx context.Context) bool {
-	var client *activeClient
+// clientGroup returns the client group of the sub protocol, the slots are not connected until CheckAndInit
+func (p *connPool) clientGroup(subProtocol types.ProtocolName) *clientGroup {
+	if v, ok := p.activeClients.Load(subProtocol); ok {
+		return v.(*clientGroup)
+	}
+	clients := make([]*activeClient, p.config.ConnectionsPerHost)
+	for i := range clients {
+		clients[i] = &activeClient{index: i, state: Init}
+	}
+	group := &clientGroup{}
+	group.clients.Store(clients)
+	v, _ := p.activeClients.LoadOrStore(subProtocol, group)
+	return v.(*clientGroup)
+}
 
+func (p *connPool) CheckAndInit(ctx context.Context) bool {
 	subProtocol := getSubProtocol(ctx)
 
-	v, ok := p.activeClients.Load(subProtocol)
-	if !ok {
-		fakeclient := &activeClient{}
-		fakeclient.state = Init
-		v, _ := p.activeClients.LoadOrStore(subProtocol, fakeclient)
-		client = v.(*activeClient)
-	} else {
-		client = v.(*activeClient)
-	}
-
-	if atomic.LoadUint32(&client.state) == Connected {
+	// the exclusive connections are created on demand
+	if xprotocol.GetPoolMode(subProtocol) == xprotocol.PingPong {
 		return true
 	}
 
-	if atomic.CompareAndSwapUint32(&client.state, Init, Connecting) {
-		p.init(client, subProtocol)
+	connected := false
+	for _, client := range p.clientGroup(subProtocol).load() {
+		if atomic.LoadUint32(&client.state) == Connected {
+			connected = true
+		} else if atomic.CompareAndSwapUint32(&client.state, Init, Connecting) {
+			p.init(client, subProtocol)
+		}
 	}
 
-	return false
+	return connected
 }
 
 func (p *connPool) Protocol() types.ProtocolName {
 	return protocol.Xprotocol
 }
 
+// pickClient assigns the stream to a connected client, the clients going away or reaching
+// the max streams are skipped. The reason is Overflow if all the connected clients are full.
+func (p *connPool) pickClient(group *clientGroup) (*activeClient, types.PoolFailureReason) {
+	clients := group.load()
+	n := uint32(len(clients))
+	start := atomic.AddUint32(&group.cursor, 1)
+	var picked *activeClient
+	var pickedStreams int
+	full := false
+	for i := uint32(0); i < n; i++ {
+		client := clients[(start+i)%n]
+		if atomic.LoadUint32(&client.state) != Connected || atomic.LoadUint32(&client.goaway) == 1 {
+			continue
+		}
+		streams := client.client.ActiveRequestsNum()
+		if p.config.MaxStreamsPerConnection > 0 && streams >= int(p.config.MaxStreamsPerConnection) {
+			full = true
+			continue
+		}
+		if p.config.Assignment == v2.RoundRobin {
+			return client, ""
+		}
+		// least streams, the ties are broken by the rotating start slot
+		if picked == nil || streams < pickedStreams {
+			picked, pickedStreams = client, streams
+		}
+	}
+	if picked != nil {
+		return picked, ""
+	}
+	if full {
+		return nil, types.Overflow
+	}
+	return nil, types.ConnectionFailure
+}
+
 func (p *connPool) NewStream(ctx context.Context,
 	responseDecoder types.StreamReceiveListener, listener types.PoolEventListener) {
 	subProtocol := getSubProtocol(ctx)
 
-	client, _ := p.activeClients.Load(subProtocol)
-	host := p.Host()
-
-	if client == nil {
-		listener.OnFailure(types.ConnectionFailure, host)
+	if xprotocol.GetPoolMode(subProtocol) == xprotocol.PingPong {
+		p.pingPongPool(subProtocol).NewStream(ctx, responseDecoder, listener)
 		return
 	}
 
-	activeClient := client.(*activeClient)
-	if atomic.LoadUint32(&activeClient.state) != Connected {
-		listener.OnFailure(types.ConnectionFailure, host)
+	host := p.Host()
+	activeClient, reason := p.pickClient(p.clientGroup(subProtocol))
+	if activeClient == nil {
+		if reason == types.Overflow {
+			host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
+			host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+		}
+		listener.OnFailure(reason, host)
 		return
 	}
 
@@ -165,10 +250,12 @@ func (p *connPool) NewStream(ctx context.Context,
 		} else {
 			streamEncoder = activeClient.client.NewStream(ctx, responseDecoder)
 			streamEncoder.GetStream().AddEventListener(activeClient)
+			activeClient.streams.Update(atomic.AddInt64(&activeClient.activeStreams, 1))
 
 			host.HostStats().UpstreamRequestActive.Inc(1)
 			host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
 			host.ClusterInfo().ResourceManager().Requests().Increase()
+			p.updateStreamStats()
 		}
 
 		listener.OnReady(streamEncoder, host)
@@ -179,29 +266,61 @@ func (p *connPool) NewStream(ctx context.Context,
 
 func (p *connPool) Close() {
 	f := func(k, v interface{}) bool {
-		ac, _ := v.(*activeClient)
-		if ac.client != nil {
-			ac.client.Close()
+		for _, ac := range v.(*clientGroup).load() {
+			if ac.client != nil {
+				ac.client.Close()
+			}
 		}
 		return true
 	}
 
 	p.activeClients.Range(f)
+
+	p.pingPongPools.Range(func(k, v interface{}) bool {
+		v.(*pingPongPool).Close()
+		return true
+	})
 }
 
 // Shutdown stop the keepalive, so the connection will be idle after requests finished
 func (p *connPool) Shutdown() {
 	f := func(k, v interface{}) bool {
-		ac, _ := v.(*activeClient)
-		if ac.keepAlive != nil {
-			ac.keepAlive.keepAlive.Stop()
+		for _, ac := range v.(*clientGroup).load() {
+			if ac.keepAlive != nil {
+				ac.keepAlive.keepAlive.Stop()
+			}
 		}
 		return true
 	}
 	p.activeClients.Range(f)
+
+	p.pingPongPools.Range(func(k, v interface{}) bool {
+		v.(*pingPongPool).Shutdown()
+		return true
+	})
+}
+
+// pingPongPool returns the pool of exclusive connections for the sub protocol
+func (p *connPool) pingPongPool(subProtocol types.ProtocolName) *pingPongPool {
+	if v, ok := p.pingPongPools.Load(subProtocol); ok {
+		return v.(*pingPongPool)
+	}
+	v, _ := p.pingPongPools.LoadOrStore(subProtocol, newPingPongPool(subProtocol, p))
+	return v.(*pingPongPool)
 }
 
 func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionEvent) {
+	p.connectionEventStats(event, client.closeWithActiveReq)
+	if event.IsClose() {
+		atomic.StoreUint32(&client.closed, 1)
+		p.removeClient(client)
+	} else if event == api.ConnectTimeout {
+		client.client.Close()
+	}
+}
+
+// connectionEventStats records the stats of the upstream connection events
+func (p *connPool) connectionEventStats(event api.ConnectionEvent, closeWithActiveReq bool) {
 	host := p.Host()
 	// event.ConnectFailure() contains types.ConnectTimeout and types.ConnectTimeout
 	if event.IsClose() {
@@ -216,7 +335,7 @@ func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionE
 			host.HostStats().UpstreamConnectionLocalClose.Inc(1)
 			host.ClusterInfo().Stats().UpstreamConnectionLocalClose.Inc(1)
 
-			if client.closeWithActiveReq {
+			if closeWithActiveReq {
 				host.HostStats().UpstreamConnectionLocalCloseWithActiveRequest.Inc(1)
 				host.ClusterInfo().Stats().UpstreamConnectionLocalCloseWithActiveRequest.Inc(1)
 			}
@@ -225,7 +344,7 @@ func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionE
 			host.HostStats().UpstreamConnectionRemoteClose.Inc(1)
 			host.ClusterInfo().Stats().UpstreamConnectionRemoteClose.Inc(1)
 
-			if client.closeWithActiveReq {
+			if closeWithActiveReq {
 				host.HostStats().UpstreamConnectionRemoteCloseWithActiveRequest.Inc(1)
 				host.ClusterInfo().Stats().UpstreamConnectionRemoteCloseWithActiveRequest.Inc(1)
 
@@ -233,20 +352,54 @@ func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionE
 		default:
 			// do nothing
 		}
-		p.mux.Lock()
-		p.activeClients.Delete(client.subProtocol)
-		p.mux.Unlock()
 	} else if event == api.ConnectTimeout {
 		host.HostStats().UpstreamRequestTimeout.Inc(1)
 		host.ClusterInfo().Stats().UpstreamRequestTimeout.Inc(1)
-		client.client.Close()
 	} else if event == api.ConnectFailed {
 		host.HostStats().UpstreamConnectionConFail.Inc(1)
 		host.ClusterInfo().Stats().UpstreamConnectionConFail.Inc(1)
 	}
 }
 
-func (p *connPool) onStreamDestroy(client *activeClient) {
+// removeClient resets the slot of the client, so the slot is reconnected by the next CheckAndInit.
+// The client stored may be a new one if the client is going away.
+func (p *connPool) removeClient(client *activeClient) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	v, ok := p.activeClients.Load(client.subProtocol)
+	if !ok {
+		return
+	}
+	group := v.(*clientGroup)
+	if group.load()[client.index] == client {
+		group.replace(client.index, &activeClient{index: client.index, state: Init})
+		p.Host().HostStats().UpstreamConnectionPoolConnected.Update(atomic.AddInt64(&p.connected, -1))
+		client.streams.Update(0)
+	}
+	p.updateStreamStats()
+}
+
+// updateStreamStats records the max and total active streams of the connections in the slots.
+// The streams are counted by the clients, as the stream connection may be locked by the caller.
+func (p *connPool) updateStreamStats() {
+	var max, total int64
+	p.activeClients.Range(func(k, v interface{}) bool {
+		for _, ac := range v.(*clientGroup).load() {
+			streams := atomic.LoadInt64(&ac.activeStreams)
+			total += streams
+			if streams > max {
+				max = streams
+			}
+		}
+		return true
+	})
+	stats := p.Host().HostStats()
+	stats.UpstreamConnectionPoolStreamsMax.Update(max)
+	stats.UpstreamConnectionPoolStreamsTotal.Update(total)
+}
+
+func (p *connPool) onStreamDestroy() {
 	host := p.Host()
 	host.HostStats().UpstreamRequestActive.Dec(1)
 	host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
@@ -254,11 +407,18 @@ func (p *connPool) onStreamDestroy(client *activeClient) {
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
+	if p.streamResetStats(reason) {
+		client.closeWithActiveReq = true
+	}
+}
+
+// streamResetStats records the stats of the stream reset, and returns true if the stream is reset by the connection
+func (p *connPool) streamResetStats(reason types.StreamResetReason) bool {
 	host := p.Host()
 	if reason == types.StreamConnectionTermination || reason == types.StreamConnectionFailed {
 		host.HostStats().UpstreamRequestFailureEject.Inc(1)
 		host.ClusterInfo().Stats().UpstreamRequestFailureEject.Inc(1)
-		client.closeWithActiveReq = true
+		return true
 	} else if reason == types.StreamLocalReset {
 		host.HostStats().UpstreamRequestLocalReset.Inc(1)
 		host.ClusterInfo().Stats().UpstreamRequestLocalReset.Inc(1)
@@ -266,6 +426,7 @@ func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetR
 		host.HostStats().UpstreamRequestRemoteReset.Inc(1)
 		host.ClusterInfo().Stats().UpstreamRequestRemoteReset.Inc(1)
 	}
+	return false
 }
 
 func (p *connPool) createStreamClient(context context.Context, connData types.CreateConnectionData) str.Client {
@@ -294,16 +455,28 @@ type activeClient struct {
 	host               types.CreateConnectionData
 	closeWithActiveReq bool
 	totalStream        uint64
+	activeStreams      int64
 	state              uint32
+	// goaway is set when the upstream asks the client to go away
+	goaway uint32
+	// closed is set when the connection is closed
+	closed uint32
+	// index is the slot of the client in the client group
+	index int
+	// streams is the active streams gauge of the slot
+	streams gometrics.Gauge
 }
 
-func newActiveClient(ctx context.Context, subProtocol types.ProtocolName, pool *connPool) *activeClient {
+func newActiveClient(ctx context.Context, subProtocol types.ProtocolName, index int, pool *connPool) *activeClient {
 	ac := &activeClient{
 		subProtocol: subProtocol,
 		pool:        pool,
+		index:       index,
 	}
 
 	host := pool.Host()
+	ac.streams = metrics.NewConnectionPoolStats(host.ClusterInfo().Name(), host.AddressString(), string(subProtocol), index).
+		Gauge(metrics.UpstreamConnectionPoolStreams)
 	data := host.CreateConnection(ctx)
 	connCtx := mosnctx.WithValue(ctx, types.ContextKeyConnectionID, data.Connection.ID())
 	connCtx = mosnctx.WithValue(ctx, types.ContextSubProtocol, string(subProtocol))
@@ -352,15 +525,40 @@ func (ac *activeClient) OnEvent(event api.ConnectionEvent) {
 
 // types.StreamEventListener
 func (ac *activeClient) OnDestroyStream() {
-	ac.pool.onStreamDestroy(ac)
+	ac.pool.onStreamDestroy()
+	ac.streams.Update(atomic.AddInt64(&ac.activeStreams, -1))
+	ac.pool.updateStreamStats()
+	ac.closeIfDrained()
 }
 
 func (ac *activeClient) OnResetStream(reason types.StreamResetReason) {
 	ac.pool.onStreamReset(ac, reason)
+	// the connection is closed already if the streams are reset by the connection
+	if reason != types.StreamConnectionTermination && reason != types.StreamConnectionFailed {
+		ac.closeIfDrained()
+	}
 }
 
 // types.StreamConnectionEventListener
-func (ac *activeClient) OnGoAway() {}
+// OnGoAway removes the client from the pool, so the new streams are sent on a new connection,
+// and the connection is closed after the active streams finished.
+func (ac *activeClient) OnGoAway() {
+	if !atomic.CompareAndSwapUint32(&ac.goaway, 0, 1) {
+		return
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[stream] [xprotocol] [connpool] client goaway, host %s, Connection = %d", ac.pool.Host().AddressString(), ac.client.ConnID())
+	}
+	ac.pool.removeClient(ac)
+	ac.closeIfDrained()
+}
+
+// closeIfDrained closes the connection if the client is going away and no streams are active
+func (ac *activeClient) closeIfDrained() {
+	if atomic.LoadUint32(&ac.goaway) == 1 && ac.client.ActiveRequestsNum() == 0 {
+		ac.client.Close()
+	}
+}
 
 func getSubProtocol(ctx context.Context) types.ProtocolName {
 	if ctx != nil {
@@ -0,0 +1,252 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package xprotocol
+
+import (
+	"context"
+	"sync"
+
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/log"
+	str "mosn.io/mosn/pkg/stream"
+	"mosn.io/mosn/pkg/types"
+)
+
+// pingPongPool holds the exclusive connections for the sub protocol which is not multiplexed.
+// Each connection handles one request at a time, and the connection is returned to the pool
+// after the response is received.
+type pingPongPool struct {
+	subProtocol types.ProtocolName
+	pool        *connPool
+
+	clientMux        sync.Mutex
+	availableClients []*pingPongClient // available clients
+	totalClientCount uint64            // total clients
+}
+
+func newPingPongPool(subProtocol types.ProtocolName, pool *connPool) *pingPongPool {
+	return &pingPongPool{
+		subProtocol: subProtocol,
+		pool:        pool,
+	}
+}
+
+func (pp *pingPongPool) NewStream(ctx context.Context, receiver types.StreamReceiveListener, listener types.PoolEventListener) {
+	host := pp.pool.Host()
+
+	if !host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+		listener.OnFailure(types.Overflow, host)
+		host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
+		host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+		return
+	}
+
+	c, reason := pp.getAvailableClient(ctx)
+	if c == nil {
+		listener.OnFailure(reason, host)
+		return
+	}
+
+	host.HostStats().UpstreamRequestTotal.Inc(1)
+	host.ClusterInfo().Stats().UpstreamRequestTotal.Inc(1)
+
+	var streamEncoder types.StreamSender
+	// oneway, no response is expected, so the connection can be used by the next request
+	if receiver == nil {
+		streamEncoder = c.client.NewStream(ctx, nil)
+		pp.putClient(c)
+	} else {
+		streamEncoder = c.client.NewStream(ctx, receiver)
+		streamEncoder.GetStream().AddEventListener(c)
+
+		host.HostStats().UpstreamRequestActive.Inc(1)
+		host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
+		host.ClusterInfo().ResourceManager().Requests().Increase()
+	}
+
+	listener.OnReady(streamEncoder, host)
+}
+
+func (pp *pingPongPool) getAvailableClient(ctx context.Context) (*pingPongClient, types.PoolFailureReason) {
+	pp.clientMux.Lock()
+	defer pp.clientMux.Unlock()
+
+	if n := len(pp.availableClients); n > 0 {
+		c := pp.availableClients[n-1]
+		pp.availableClients[n-1] = nil
+		pp.availableClients = pp.availableClients[:n-1]
+		return c, ""
+	}
+
+	host := pp.pool.Host()
+	// max conns is 0 means no limit
+	maxConns := host.ClusterInfo().ResourceManager().Connections().Max()
+	if maxConns != 0 && pp.totalClientCount >= maxConns {
+		host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
+		host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+		return nil, types.Overflow
+	}
+
+	c := newPingPongClient(ctx, pp)
+	if c == nil {
+		return nil, types.ConnectionFailure
+	}
+	pp.totalClientCount++
+	return c, ""
+}
+
+// putClient returns the client to the pool, or closes it if it can not be reused
+func (pp *pingPongPool) putClient(c *pingPongClient) {
+	pp.clientMux.Lock()
+	if c.closed {
+		pp.clientMux.Unlock()
+		return
+	}
+	if !c.closeConn {
+		pp.availableClients = append(pp.availableClients, c)
+		pp.clientMux.Unlock()
+		return
+	}
+	pp.clientMux.Unlock()
+
+	c.client.Close()
+}
+
+// Close closes the available connections, the connections in use are closed after the requests finished
+func (pp *pingPongPool) Close() {
+	pp.clientMux.Lock()
+	clients := pp.availableClients
+	pp.availableClients = nil
+	pp.clientMux.Unlock()
+
+	for _, c := range clients {
+		c.setCloseConn()
+		c.client.Close()
+	}
+}
+
+// Shutdown is the same as Close, the exclusive connections have no keepalive to stop,
+// so they are not kept after the requests finished
+func (pp *pingPongPool) Shutdown() {
+	pp.Close()
+}
+
+func (pp *pingPongPool) onConnectionEvent(c *pingPongClient, event api.ConnectionEvent) {
+	pp.pool.connectionEventStats(event, c.closeWithActiveReq)
+
+	if event.IsClose() {
+		pp.clientMux.Lock()
+		defer pp.clientMux.Unlock()
+
+		if c.closed {
+			return
+		}
+		c.closed = true
+		pp.totalClientCount--
+		for i, ac := range pp.availableClients {
+			if ac == c {
+				pp.availableClients[i] = nil
+				pp.availableClients = append(pp.availableClients[:i], pp.availableClients[i+1:]...)
+				break
+			}
+		}
+	} else if event == api.ConnectTimeout {
+		c.client.Close()
+	}
+}
+
+// types.StreamEventListener
+// types.ConnectionEventListener
+// types.StreamConnectionEventListener
+type pingPongClient struct {
+	pool               *pingPongPool
+	client             str.Client
+	host               types.CreateConnectionData
+	closeWithActiveReq bool
+	// closed is set when the connection is closed
+	closed bool
+	// closeConn is set when the connection can not be reused
+	closeConn bool
+}
+
+func newPingPongClient(ctx context.Context, pool *pingPongPool) *pingPongClient {
+	c := &pingPongClient{
+		pool: pool,
+	}
+
+	host := pool.pool.Host()
+	data := host.CreateConnection(ctx)
+	connCtx := mosnctx.WithValue(context.Background(), types.ContextKeyConnectionID, data.Connection.ID())
+	connCtx = mosnctx.WithValue(connCtx, types.ContextSubProtocol, string(pool.subProtocol))
+	codecClient := pool.pool.createStreamClient(connCtx, data)
+	codecClient.AddConnectionEventListener(c)
+	codecClient.SetStreamConnectionEventListener(c)
+
+	c.client = codecClient
+	c.host = data
+
+	if err := c.client.Connect(); err != nil {
+		return nil
+	}
+
+	// stats
+	host.HostStats().UpstreamConnectionTotal.Inc(1)
+	host.HostStats().UpstreamConnectionActive.Inc(1)
+	host.ClusterInfo().Stats().UpstreamConnectionTotal.Inc(1)
+	host.ClusterInfo().Stats().UpstreamConnectionActive.Inc(1)
+
+	// bytes total adds all connections data together
+	codecClient.SetConnectionCollector(host.ClusterInfo().Stats().UpstreamBytesReadTotal, host.ClusterInfo().Stats().UpstreamBytesWriteTotal)
+
+	return c
+}
+
+// types.ConnectionEventListener
+func (c *pingPongClient) OnEvent(event api.ConnectionEvent) {
+	c.pool.onConnectionEvent(c, event)
+}
+
+// setCloseConn marks the connection can not be reused
+func (c *pingPongClient) setCloseConn() {
+	c.pool.clientMux.Lock()
+	c.closeConn = true
+	c.pool.clientMux.Unlock()
+}
+
+// types.StreamEventListener
+func (c *pingPongClient) OnDestroyStream() {
+	c.pool.pool.onStreamDestroy()
+	c.pool.putClient(c)
+}
+
+// OnResetStream marks the connection not reusable, the response of the reset request may still arrive later,
+// which would be paired with the next request.
+func (c *pingPongClient) OnResetStream(reason types.StreamResetReason) {
+	if c.pool.pool.streamResetStats(reason) {
+		c.closeWithActiveReq = true
+	}
+	c.setCloseConn()
+}
+
+// types.StreamConnectionEventListener
+func (c *pingPongClient) OnGoAway() {
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[stream] [xprotocol] [connpool] ping-pong client goaway, host %s, Connection = %d", c.pool.pool.Host().AddressString(), c.client.ConnID())
+	}
+	c.setCloseConn()
+}
@@ -0,0 +1,353 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xprotocol
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+// waitActiveClient waits for the client of the pool connected
+func waitActiveClient(t *testing.T, pool *connPool, ctx context.Context) *activeClient {
+	for i := 0; i < 30; i++ {
+		if pool.CheckAndInit(ctx) {
+			for _, client := range pool.clientGroup(bolt.ProtocolName).load() {
+				if atomic.LoadUint32(&client.state) == Connected {
+					return client
+				}
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("connection pool init client failed")
+	return nil
+}
+
+func TestActiveClientGoAway(t *testing.T) {
+	srv, err := newMockServer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.GoServe()
+	defer srv.Close()
+
+	cl := cluster.NewCluster(v2.Cluster{
+		Name:        "test",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: srv.AddrString(),
+		},
+	}, cl.Snapshot().ClusterInfo())
+	pool := NewConnPool(host).(*connPool)
+	ctx := mosnctx.WithValue(context.Background(), types.ContextSubProtocol, string(bolt.ProtocolName))
+
+	client := waitActiveClient(t, pool, ctx)
+	// the upstream asks the client to go away
+	client.OnGoAway()
+	if pool.clientGroup(bolt.ProtocolName).load()[client.index] == client {
+		t.Fatal("the client going away should be removed from the pool")
+	}
+	// the connection is closed since no streams are active
+	if client.host.Connection.State() != api.ConnClosed {
+		t.Error("the connection should be closed after the client goes away")
+	}
+
+	// the new streams use a new connection
+	newClient := waitActiveClient(t, pool, ctx)
+	if newClient == client {
+		t.Error("a new client should be created after the client goes away")
+	}
+}
+
+func TestPingPongPool(t *testing.T) {
+	srv, err := newMockPingPongServer(100 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	cl := cluster.NewCluster(v2.Cluster{
+		Name:        "test",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: srv.AddrString(),
+		},
+	}, cl.Snapshot().ClusterInfo())
+	pool := NewConnPool(host).(*connPool)
+	defer pool.Close()
+	ctx := mosnctx.WithValue(context.Background(), types.ContextSubProtocol, string(mockPingPongProtocolName))
+
+	// the exclusive connections are created on demand
+	if !pool.CheckAndInit(ctx) {
+		t.Fatal("ping-pong pool should be ready without init")
+	}
+
+	send := func(payload string) *mockReceiver {
+		receiver := &mockReceiver{payloads: make(chan string, 1)}
+		listener := &mockPoolListener{payload: payload, failure: make(chan types.PoolFailureReason, 1)}
+		pool.NewStream(ctx, receiver, listener)
+		select {
+		case reason := <-listener.failure:
+			t.Fatalf("new stream failed: %v", reason)
+		default:
+		}
+		return receiver
+	}
+	wait := func(receiver *mockReceiver, expected string) {
+		select {
+		case payload := <-receiver.payloads:
+			if payload != expected {
+				t.Errorf("expected response %s, but got %s", expected, payload)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("wait response %s timeout", expected)
+		}
+	}
+
+	// concurrent requests use their own connections
+	r1 := send("first")
+	r2 := send("second")
+	wait(r1, "first")
+	wait(r2, "second")
+
+	pp := pool.pingPongPool(mockPingPongProtocolName)
+	pp.clientMux.Lock()
+	total, available := pp.totalClientCount, len(pp.availableClients)
+	pp.clientMux.Unlock()
+	if total != 2 || available != 2 {
+		t.Fatalf("expected 2 connections available after the responses, but got total: %d, available: %d", total, available)
+	}
+
+	// the connection is reused by the next request
+	wait(send("third"), "third")
+	pp.clientMux.Lock()
+	total = pp.totalClientCount
+	pp.clientMux.Unlock()
+	if total != 2 {
+		t.Errorf("expected the connections reused, but got %d connections", total)
+	}
+}
+
+// waitAllClients waits for all the clients of the pool connected
+func waitAllClients(t *testing.T, pool *connPool, ctx context.Context) []*activeClient {
+	for i := 0; i < 30; i++ {
+		pool.CheckAndInit(ctx)
+		clients := pool.clientGroup(bolt.ProtocolName).load()
+		connected := 0
+		for _, client := range clients {
+			if atomic.LoadUint32(&client.state) == Connected {
+				connected++
+			}
+		}
+		if connected == len(clients) {
+			return clients
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("connection pool init clients failed")
+	return nil
+}
+
+func newMultiplexPool(t *testing.T, addr string, config v2.ConnPoolConfig) *connPool {
+	cl := cluster.NewCluster(v2.Cluster{
+		Name:        "test_multiplex",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+		ConnPool:    config,
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: addr,
+		},
+	}, cl.Snapshot().ClusterInfo())
+	return NewConnPool(host).(*connPool)
+}
+
+// newBoltStream creates a stream on the pool, the mock server does not reply so the stream keeps active
+func newBoltStream(pool *connPool, ctx context.Context) types.PoolFailureReason {
+	listener := &mockBoltPoolListener{failure: make(chan types.PoolFailureReason, 1)}
+	pool.NewStream(ctx, &mockReceiver{payloads: make(chan string, 1)}, listener)
+	select {
+	case reason := <-listener.failure:
+		return reason
+	default:
+		return ""
+	}
+}
+
+func streamsOf(clients []*activeClient) []int {
+	streams := make([]int, len(clients))
+	for i, client := range clients {
+		streams[i] = client.client.ActiveRequestsNum()
+	}
+	return streams
+}
+
+func TestMultiConnectionPool(t *testing.T) {
+	srv, err := newMockServer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.GoServe()
+	defer srv.Close()
+	ctx := mosnctx.WithValue(context.Background(), types.ContextSubProtocol, string(bolt.ProtocolName))
+
+	// least streams
+	pool := newMultiplexPool(t, srv.AddrString(), v2.ConnPoolConfig{ConnectionsPerHost: 3})
+	clients := waitAllClients(t, pool, ctx)
+	if len(clients) != 3 {
+		t.Fatalf("expected 3 clients, but got %d", len(clients))
+	}
+	for i := 0; i < 6; i++ {
+		if reason := newBoltStream(pool, ctx); reason != "" {
+			t.Fatalf("new stream failed: %v", reason)
+		}
+	}
+	if streams := streamsOf(clients); streams[0] != 2 || streams[1] != 2 || streams[2] != 2 {
+		t.Errorf("expected the streams assigned to the least loaded connections, but got %v", streams)
+	}
+	stats := pool.Host().HostStats()
+	if connected := stats.UpstreamConnectionPoolConnected.Value(); connected != 3 {
+		t.Errorf("expected 3 connected clients in stats, but got %d", connected)
+	}
+	if max, total := stats.UpstreamConnectionPoolStreamsMax.Value(), stats.UpstreamConnectionPoolStreamsTotal.Value(); max != 2 || total != 6 {
+		t.Errorf("expected 2 max streams and 6 total streams in stats, but got %d, %d", max, total)
+	}
+	for _, client := range clients {
+		if streams := client.streams.Value(); streams != 2 {
+			t.Errorf("expected 2 streams in stats of slot %d, but got %d", client.index, streams)
+		}
+	}
+	pool.Close()
+
+	// round robin with max streams
+	pool = newMultiplexPool(t, srv.AddrString(), v2.ConnPoolConfig{
+		ConnectionsPerHost:      2,
+		Assignment:              v2.RoundRobin,
+		MaxStreamsPerConnection: 2,
+	})
+	defer pool.Close()
+	clients = waitAllClients(t, pool, ctx)
+	for i := 0; i < 4; i++ {
+		if reason := newBoltStream(pool, ctx); reason != "" {
+			t.Fatalf("new stream failed: %v", reason)
+		}
+	}
+	if streams := streamsOf(clients); streams[0] != 2 || streams[1] != 2 {
+		t.Errorf("expected the streams assigned in turn, but got %v", streams)
+	}
+	if reason := newBoltStream(pool, ctx); reason != types.Overflow {
+		t.Errorf("expected overflow when all the connections are full, but got %v", reason)
+	}
+}
+
+func TestMultiConnectionPoolReconnect(t *testing.T) {
+	srv, err := newMockServer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.GoServe()
+	defer srv.Close()
+	ctx := mosnctx.WithValue(context.Background(), types.ContextSubProtocol, string(bolt.ProtocolName))
+
+	pool := newMultiplexPool(t, srv.AddrString(), v2.ConnPoolConfig{ConnectionsPerHost: 2})
+	defer pool.Close()
+	clients := waitAllClients(t, pool, ctx)
+
+	// the closed connection is removed from its slot, the other connection keeps serving
+	clients[0].client.Close()
+	if current := pool.clientGroup(bolt.ProtocolName).load(); current[0] == clients[0] || current[1] != clients[1] {
+		t.Fatal("only the slot of the closed connection should be reset")
+	}
+	if connected := pool.Host().HostStats().UpstreamConnectionPoolConnected.Value(); connected != 1 {
+		t.Errorf("expected 1 connected client in stats, but got %d", connected)
+	}
+	if reason := newBoltStream(pool, ctx); reason != "" {
+		t.Fatalf("new stream failed: %v", reason)
+	}
+	if streams := clients[1].client.ActiveRequestsNum(); streams != 1 {
+		t.Errorf("expected the stream assigned to the connected client, but got %d streams", streams)
+	}
+	if total := pool.Host().HostStats().UpstreamConnectionPoolStreamsTotal.Value(); total != 1 {
+		t.Errorf("expected 1 total stream in stats, but got %d", total)
+	}
+
+	// the slot is reconnected independently
+	current := waitAllClients(t, pool, ctx)
+	if current[0] == clients[0] || current[1] != clients[1] {
+		t.Fatal("the closed slot should be reconnected with a new client")
+	}
+	if current[0].keepAlive == nil || current[0].keepAlive == clients[1].keepAlive {
+		t.Error("each connection should have its own keepalive")
+	}
+}
+
+func TestMultiConnectionPoolCloseAfterAccept(t *testing.T) {
+	srv, err := newMockServer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	// the first connections are closed by the server right after accepted
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := srv.ln.Accept()
+			if err != nil {
+				return
+			}
+			if i < 3 {
+				conn.Close()
+				continue
+			}
+			go srv.HandleConn(conn)
+		}
+	}()
+	ctx := mosnctx.WithValue(context.Background(), types.ContextSubProtocol, string(bolt.ProtocolName))
+
+	pool := newMultiplexPool(t, srv.AddrString(), v2.ConnPoolConfig{ConnectionsPerHost: 1})
+	defer pool.Close()
+	for i := 0; i < 3; i++ {
+		client := waitActiveClient(t, pool, ctx)
+		if atomic.LoadUint32(&client.closed) == 0 {
+			break
+		}
+		// the closed client should never be kept in the slot
+		time.Sleep(100 * time.Millisecond)
+		if pool.clientGroup(bolt.ProtocolName).load()[0] == client {
+			t.Fatal("the closed client is kept in the slot")
+		}
+	}
+	if reason := newBoltStream(pool, ctx); reason != "" {
+		t.Fatalf("new stream failed: %v", reason)
+	}
+}
@@ -18,11 +18,15 @@
 package xprotocol
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"net"
+	"strings"
 	"time"
 
 	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/protocol/xprotocol"
 	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
 	"mosn.io/mosn/pkg/types"
@@ -146,3 +150,191 @@ func (ci *mockClusterInfo) SourceAddress() net.Addr {
 func (ci *mockClusterInfo) ConnectTimeout() time.Duration {
 	return network.DefaultConnectTimeout
 }
+
+// a line based protocol without request id, used to test the ping-pong connections.
+// The request is 'Q payload\n' and the response is 'A payload\n'.
+const mockPingPongProtocolName types.ProtocolName = "mock_pingpong"
+
+func init() {
+	xprotocol.RegisterProtocol(mockPingPongProtocolName, &mockPingPongProtocol{})
+}
+
+type mockPingPongFrame struct {
+	protocol.CommonHeader
+	streamType xprotocol.StreamType
+	payload    types.IoBuffer
+}
+
+func (f *mockPingPongFrame) IsHeartbeatFrame() bool {
+	return false
+}
+
+func (f *mockPingPongFrame) GetStreamType() xprotocol.StreamType {
+	return f.streamType
+}
+
+func (f *mockPingPongFrame) GetHeader() types.HeaderMap {
+	return f
+}
+
+func (f *mockPingPongFrame) GetData() types.IoBuffer {
+	return f.payload
+}
+
+func (f *mockPingPongFrame) SetData(data types.IoBuffer) {
+	f.payload = data
+}
+
+func (f *mockPingPongFrame) GetStatusCode() uint32 {
+	return 0
+}
+
+type mockPingPongProtocol struct{}
+
+func (proto *mockPingPongProtocol) Name() types.ProtocolName {
+	return mockPingPongProtocolName
+}
+
+func (proto *mockPingPongProtocol) PoolMode() xprotocol.PoolMode {
+	return xprotocol.PingPong
+}
+
+func (proto *mockPingPongProtocol) Encode(ctx context.Context, model interface{}) (types.IoBuffer, error) {
+	frame := model.(*mockPingPongFrame)
+	flag := "Q "
+	if frame.streamType == xprotocol.Response {
+		flag = "A "
+	}
+	buf := buffer.NewIoBufferString(flag)
+	if frame.payload != nil {
+		buf.Write(frame.payload.Bytes())
+	}
+	buf.WriteString("\n")
+	return buf, nil
+}
+
+func (proto *mockPingPongProtocol) Decode(ctx context.Context, data types.IoBuffer) (interface{}, error) {
+	idx := bytes.IndexByte(data.Bytes(), '\n')
+	if idx < 0 {
+		return nil, nil
+	}
+	line := string(data.Bytes()[:idx])
+	data.Drain(idx + 1)
+	frame := &mockPingPongFrame{
+		CommonHeader: protocol.CommonHeader{},
+		streamType:   xprotocol.Request,
+		payload:      buffer.NewIoBufferString(line[2:]),
+	}
+	if line[0] == 'A' {
+		frame.streamType = xprotocol.Response
+	}
+	return frame, nil
+}
+
+func (proto *mockPingPongProtocol) Trigger(requestId uint64) xprotocol.XFrame {
+	return nil
+}
+
+func (proto *mockPingPongProtocol) Reply(request xprotocol.XFrame) xprotocol.XRespFrame {
+	return nil
+}
+
+func (proto *mockPingPongProtocol) Hijack(statusCode uint32) xprotocol.XRespFrame {
+	return nil
+}
+
+func (proto *mockPingPongProtocol) Mapping(httpStatusCode uint32) uint32 {
+	return httpStatusCode
+}
+
+// a mock server responds the mock ping-pong protocol requests by order
+type mockPingPongServer struct {
+	ln    net.Listener
+	delay time.Duration
+}
+
+func newMockPingPongServer(delay time.Duration) (*mockPingPongServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &mockPingPongServer{
+		ln:    ln,
+		delay: delay,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.HandleConn(conn)
+		}
+	}()
+	return s, nil
+}
+
+func (s *mockPingPongServer) AddrString() string {
+	return s.ln.Addr().String()
+}
+
+func (s *mockPingPongServer) Close() error {
+	return s.ln.Close()
+}
+
+func (s *mockPingPongServer) HandleConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		time.Sleep(s.delay)
+		conn.Write([]byte("A " + strings.TrimPrefix(line, "Q ")))
+	}
+}
+
+// a mock stream receiver records the payload of the response
+type mockReceiver struct {
+	payloads chan string
+}
+
+func (r *mockReceiver) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	r.payloads <- data.String()
+}
+
+func (r *mockReceiver) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
+
+// a mock pool event listener sends the request when the stream is ready
+type mockPoolListener struct {
+	payload string
+	failure chan types.PoolFailureReason
+}
+
+func (l *mockPoolListener) OnFailure(reason types.PoolFailureReason, host types.Host) {
+	l.failure <- reason
+}
+
+func (l *mockPoolListener) OnReady(sender types.StreamSender, host types.Host) {
+	frame := &mockPingPongFrame{
+		CommonHeader: protocol.CommonHeader{},
+		streamType:   xprotocol.Request,
+	}
+	sender.AppendHeaders(context.Background(), frame, false)
+	sender.AppendData(context.Background(), buffer.NewIoBufferString(l.payload), true)
+}
+
+// a mock pool event listener sends the bolt request when the stream is ready
+type mockBoltPoolListener struct {
+	failure chan types.PoolFailureReason
+}
+
+func (l *mockBoltPoolListener) OnFailure(reason types.PoolFailureReason, host types.Host) {
+	l.failure <- reason
+}
+
+func (l *mockBoltPoolListener) OnReady(sender types.StreamSender, host types.Host) {
+	sender.AppendHeaders(context.Background(), bolt.NewRpcRequest(0, protocol.CommonHeader{}, nil), true)
+}
@@ -85,7 +85,13 @@ func (s *xStream) buildHijackResp(header types.HeaderMap) (xprotocol.XFrame, err
 		header.Del(types.HeaderStatus)
 		statusCode, _ := strconv.Atoi(status)
 		proto := s.sc.protocol
-		return proto.Hijack(proto.Mapping(uint32(statusCode))), nil
+		code := proto.Mapping(uint32(statusCode))
+		if hijacker, ok := proto.(xprotocol.RequestHijacker); ok {
+			if request, ok := header.(xprotocol.XFrame); ok {
+				return hijacker.HijackRequest(request, code), nil
+			}
+		}
+		return proto.Hijack(code), nil
 	}
 
 	return nil, types.ErrNoStatusCodeForHijack
@@ -127,7 +133,9 @@ func (s *xStream) endStream() {
 
 	if s.frame != nil {
 		// replace requestID
-		s.frame.SetRequestId(s.id)
+		if mux, ok := s.frame.(xprotocol.Multiplexing); ok {
+			mux.SetRequestId(s.id)
+		}
 
 		// remove injected headers
 		if _, ok := s.frame.(xprotocol.ServiceAware); ok {
@@ -159,6 +167,11 @@ func (s *xStream) GetStream() types.Stream {
 	return s
 }
 
+// ReadDisable disables the whole connection, xprotocol has no stream level flow control
+func (s *xStream) ReadDisable(disable bool) {
+	s.sc.netConn.SetReadDisable(disable)
+}
+
 func (s *xStream) ResetStream(reason types.StreamResetReason) {
 	if s.direction == stream.ClientStream && !s.connReset {
 		s.sc.clientMutex.Lock()
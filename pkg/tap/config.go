@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+const defaultMaxBodyBytes = 1024
+
+// Config is the config of the tap filters
+type Config struct {
+	// ID identifies the traces of the tap, the admin api subscribes the traces by it
+	ID string `json:"id,omitempty"`
+	// Match selects the tapped traffic, all traffic is tapped if it is empty
+	Match MatchConfig `json:"match,omitempty"`
+	// MaxBodyBytes is the max captured bytes of a body, 1KB by default
+	MaxBodyBytes uint32 `json:"max_body_bytes,omitempty"`
+	// OutputPath is the file that the traces are written to as json lines.
+	// The traces are only streamed to the admin api subscribers if it is empty
+	OutputPath string `json:"output_path,omitempty"`
+}
+
+// MatchConfig is the conditions of the tapped traffic, all the conditions should be matched.
+// The network tap only supports the source ips and the sample rate.
+type MatchConfig struct {
+	// Headers matches the request headers
+	Headers []v2.HeaderMatcher `json:"headers,omitempty"`
+	// PathPrefix matches the prefix of the http request path
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// Clusters matches the cluster of the request route
+	Clusters []string `json:"clusters,omitempty"`
+	// SourceIPs matches the remote address of the downstream connection, which is an ip or a cidr
+	SourceIPs []string `json:"source_ips,omitempty"`
+	// SampleRate is the percentage of the matched traffic that is tapped, 100 by default
+	SampleRate *float64 `json:"sample_rate,omitempty"`
+}
+
+// ParseConfig parses the tap config of the filters
+func ParseConfig(conf map[string]interface{}) (*Config, error) {
+	cfg := &Config{}
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if rate := cfg.Match.SampleRate; rate != nil && (*rate < 0 || *rate > 100) {
+		return nil, errors.New("tap sample rate should be a percentage between 0 and 100")
+	}
+	if _, err := parseSourceIPs(cfg.Match.SourceIPs); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func parseSourceIPs(ips []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(ips))
+	for _, ip := range ips {
+		if !strings.Contains(ip, "/") {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+				ip += "/32"
+			} else {
+				ip += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tap source ip %s: %v", ip, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// the traces are dropped if the subscriber is slower than the traffic
+const subscriberBufferSize = 1024
+
+var (
+	subscribersMux sync.RWMutex
+	subscribers    = map[*Subscriber]struct{}{}
+)
+
+// Subscriber receives the traces of the taps
+type Subscriber struct {
+	id      string
+	traces  chan []byte
+	dropped uint64
+}
+
+// Subscribe subscribes the traces of the tap id, the traces of all taps are subscribed if the id is empty.
+// The subscriber should be unsubscribed after used.
+func Subscribe(id string) *Subscriber {
+	s := &Subscriber{
+		id:     id,
+		traces: make(chan []byte, subscriberBufferSize),
+	}
+	subscribersMux.Lock()
+	subscribers[s] = struct{}{}
+	subscribersMux.Unlock()
+	return s
+}
+
+// Unsubscribe stops receiving the traces
+func Unsubscribe(s *Subscriber) {
+	subscribersMux.Lock()
+	delete(subscribers, s)
+	subscribersMux.Unlock()
+}
+
+// Traces returns the channel of the traces, each trace is a json object
+func (s *Subscriber) Traces() <-chan []byte {
+	return s.traces
+}
+
+// Dropped returns the count of the traces dropped because the subscriber is slow
+func (s *Subscriber) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscriber) match(id string) bool {
+	return s.id == "" || s.id == id
+}
+
+func hasSubscriber(id string) bool {
+	subscribersMux.RLock()
+	defer subscribersMux.RUnlock()
+	for s := range subscribers {
+		if s.match(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func publish(id string, trace []byte) {
+	subscribersMux.RLock()
+	defer subscribersMux.RUnlock()
+	for s := range subscribers {
+		if !s.match(id) {
+			continue
+		}
+		select {
+		case s.traces <- trace:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net"
+	"strings"
+
+	"mosn.io/api"
+	mlog "mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/log"
+)
+
+// Tap selects the traffic by the match conditions, and outputs the traces to the file and the subscribers
+type Tap struct {
+	cfg      *Config
+	headers  []*types.HeaderData
+	clusters map[string]bool
+	sources  []*net.IPNet
+	logger   *log.Logger
+}
+
+// NewTap creates a tap by the config
+func NewTap(cfg *Config) (*Tap, error) {
+	sources, err := parseSourceIPs(cfg.Match.SourceIPs)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tap{
+		cfg:     cfg,
+		headers: router.GetRouterHeaders(cfg.Match.Headers),
+		sources: sources,
+	}
+	if len(cfg.Match.Clusters) > 0 {
+		t.clusters = make(map[string]bool, len(cfg.Match.Clusters))
+		for _, cluster := range cfg.Match.Clusters {
+			t.clusters[cluster] = true
+		}
+	}
+	if cfg.OutputPath != "" {
+		if t.logger, err = log.GetOrCreateLogger(cfg.OutputPath, nil); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// MaxBodyBytes returns the max captured bytes of a body
+func (t *Tap) MaxBodyBytes() uint32 {
+	return t.cfg.MaxBodyBytes
+}
+
+// Active reports whether there are outputs of the traces, the traffic need not be captured if it is false
+func (t *Tap) Active() bool {
+	return t.logger != nil || hasSubscriber(t.cfg.ID)
+}
+
+// MatchConnection reports whether the connection is tapped by the source ips and the sample rate
+func (t *Tap) MatchConnection(remote net.Addr) bool {
+	return t.matchSource(remote) && t.sample()
+}
+
+// MatchRequest reports whether the request is tapped by all the match conditions.
+// The cluster is the cluster of the request route, which is empty if there is no route
+func (t *Tap) MatchRequest(remote net.Addr, headers api.HeaderMap, cluster string) bool {
+	if !t.matchSource(remote) {
+		return false
+	}
+	if len(t.headers) > 0 && !router.ConfigUtilityInst.MatchHeaders(headers, t.headers) {
+		return false
+	}
+	if prefix := t.cfg.Match.PathPrefix; prefix != "" {
+		if path, ok := headers.Get(protocol.MosnHeaderPathKey); !ok || !strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	if t.clusters != nil && !t.clusters[cluster] {
+		return false
+	}
+	return t.sample()
+}
+
+func (t *Tap) matchSource(remote net.Addr) bool {
+	if len(t.sources) == 0 {
+		return true
+	}
+	var ip net.IP
+	switch addr := remote.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	default:
+		if remote == nil {
+			return false
+		}
+		host, _, err := net.SplitHostPort(remote.String())
+		if err != nil {
+			return false
+		}
+		ip = net.ParseIP(host)
+	}
+	for _, source := range t.sources {
+		if source.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tap) sample() bool {
+	rate := t.cfg.Match.SampleRate
+	return rate == nil || float64(rand.Intn(10000)) < *rate*100
+}
+
+// Submit outputs the trace to the file and the subscribers
+func (t *Tap) Submit(trace *Trace) {
+	trace.TapID = t.cfg.ID
+	data, err := json.Marshal(trace)
+	if err != nil {
+		mlog.DefaultLogger.Errorf("[tap] marshal trace failed: %v", err)
+		return
+	}
+	if t.logger != nil {
+		buf := buffer.GetIoBuffer(len(data) + 1)
+		buf.Write(data)
+		buf.WriteString("\n")
+		t.logger.Print(buf, true)
+	}
+	publish(t.cfg.ID, data)
+}
@@ -0,0 +1,214 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/protocol"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig(map[string]interface{}{
+		"id": "test",
+		"match": map[string]interface{}{
+			"headers":     []interface{}{map[string]interface{}{"name": "service", "value": "test"}},
+			"path_prefix": "/api",
+			"source_ips":  []interface{}{"127.0.0.1", "10.0.0.0/8", "::1"},
+			"sample_rate": 50,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ID != "test" || cfg.MaxBodyBytes != defaultMaxBodyBytes || *cfg.Match.SampleRate != 50 ||
+		len(cfg.Match.Headers) != 1 || cfg.Match.PathPrefix != "/api" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	for _, conf := range []map[string]interface{}{
+		{"match": map[string]interface{}{"sample_rate": -1}},
+		{"match": map[string]interface{}{"source_ips": []interface{}{"invalid"}}},
+	} {
+		if _, err := ParseConfig(conf); err == nil {
+			t.Fatalf("expected invalid config: %v", conf)
+		}
+	}
+}
+
+func newTap(t *testing.T, conf map[string]interface{}) *Tap {
+	cfg, err := ParseConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := NewTap(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tp
+}
+
+func TestMatchRequest(t *testing.T) {
+	tp := newTap(t, map[string]interface{}{
+		"match": map[string]interface{}{
+			"headers":     []interface{}{map[string]interface{}{"name": "service", "value": "^test.*", "regex": true}},
+			"path_prefix": "/api",
+			"clusters":    []interface{}{"up"},
+			"source_ips":  []interface{}{"10.0.0.0/8"},
+		},
+	})
+	remote := &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 10000}
+	headers := protocol.CommonHeader{"service": "testService", protocol.MosnHeaderPathKey: "/api/v1"}
+	if !tp.MatchRequest(remote, headers, "up") {
+		t.Fatal("expected request matched")
+	}
+	for i, c := range []struct {
+		remote  net.Addr
+		headers protocol.CommonHeader
+		cluster string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.1")}, headers, "up"},
+		{remote, protocol.CommonHeader{"service": "other", protocol.MosnHeaderPathKey: "/api/v1"}, "up"},
+		{remote, protocol.CommonHeader{"service": "testService", protocol.MosnHeaderPathKey: "/web"}, "up"},
+		{remote, protocol.CommonHeader{"service": "testService"}, "up"},
+		{remote, headers, "down"},
+	} {
+		if tp.MatchRequest(c.remote, c.headers, c.cluster) {
+			t.Fatalf("#%d expected request not matched", i)
+		}
+	}
+	if !tp.MatchConnection(remote) || tp.MatchConnection(&net.TCPAddr{IP: net.ParseIP("192.168.1.1")}) {
+		t.Fatal("unexpected connection match")
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	tp := newTap(t, map[string]interface{}{
+		"match": map[string]interface{}{"sample_rate": 0},
+	})
+	for i := 0; i < 100; i++ {
+		if tp.MatchConnection(&net.TCPAddr{}) {
+			t.Fatal("expected nothing sampled")
+		}
+	}
+	tp = newTap(t, map[string]interface{}{
+		"match": map[string]interface{}{"sample_rate": 50},
+	})
+	var matched int
+	for i := 0; i < 1000; i++ {
+		if tp.MatchConnection(&net.TCPAddr{}) {
+			matched++
+		}
+	}
+	if matched < 400 || matched > 600 {
+		t.Fatalf("unexpected sampled: %d", matched)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	c := NewCapture(8)
+	c.Write([]byte("hello"))
+	c.Write([]byte(" world"))
+	if body := c.Body(false); body.Data != "hello wo" || body.Base64 || body.Size != 11 || !body.Truncated {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+	if body := c.Body(true); body.Data != "aGVsbG8gd28=" || !body.Base64 {
+		t.Fatalf("unexpected binary body: %+v", body)
+	}
+	// the truncated rune is dropped
+	c = NewCapture(4)
+	c.Write([]byte("ab中文"))
+	if body := c.Body(false); body.Data != "ab" || body.Base64 {
+		t.Fatalf("unexpected truncated body: %+v", body)
+	}
+	// not valid utf8
+	c = NewCapture(4)
+	c.Write([]byte{0xff, 0xfe, 0x00})
+	if body := c.Body(false); !body.Base64 || body.Size != 3 || body.Truncated {
+		t.Fatalf("unexpected invalid utf8 body: %+v", body)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tp := newTap(t, map[string]interface{}{"id": "sub"})
+	if tp.Active() {
+		t.Fatal("expected tap inactive without outputs")
+	}
+	s := Subscribe("sub")
+	other := Subscribe("other")
+	all := Subscribe("")
+	defer Unsubscribe(all)
+	if !tp.Active() {
+		t.Fatal("expected tap active with subscriber")
+	}
+	tp.Submit(&Trace{Protocol: "tcp"})
+	select {
+	case data := <-s.Traces():
+		trace := &Trace{}
+		if err := json.Unmarshal(data, trace); err != nil || trace.TapID != "sub" || trace.Protocol != "tcp" {
+			t.Fatalf("unexpected trace: %s, %v", data, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected trace")
+	}
+	if len(all.Traces()) != 1 || len(other.Traces()) != 0 {
+		t.Fatal("unexpected traces of other subscribers")
+	}
+	Unsubscribe(s)
+	Unsubscribe(other)
+	// the slow subscriber drops the traces
+	for i := 0; i < subscriberBufferSize; i++ {
+		tp.Submit(&Trace{})
+	}
+	if all.Dropped() != 1 {
+		t.Fatalf("unexpected dropped: %d", all.Dropped())
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "tap.log")
+	tp := newTap(t, map[string]interface{}{"id": "file", "output_path": output})
+	if !tp.Active() {
+		t.Fatal("expected tap active with output file")
+	}
+	tp.Submit(&Trace{Protocol: "Http1"})
+	tp.Submit(&Trace{Protocol: "tcp"})
+	// the logger writes asynchronously
+	var lines []string
+	for i := 0; i < 20; i++ {
+		time.Sleep(50 * time.Millisecond)
+		data, _ := ioutil.ReadFile(output)
+		if lines = strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) == 2 {
+			break
+		}
+	}
+	if len(lines) != 2 || !strings.Contains(lines[0], `"protocol":"Http1"`) || !strings.Contains(lines[1], `"tap_id":"file"`) {
+		t.Fatalf("unexpected output: %v", lines)
+	}
+}
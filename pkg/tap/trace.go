@@ -0,0 +1,121 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"encoding/base64"
+	"time"
+	"unicode/utf8"
+)
+
+// Trace is the tapped traffic of a request or a connection, which is written as a json line
+type Trace struct {
+	TapID         string    `json:"tap_id,omitempty"`
+	Protocol      string    `json:"protocol"`
+	StartTime     time.Time `json:"start_time"`
+	Duration      string    `json:"duration"`
+	LocalAddress  string    `json:"local_address,omitempty"`
+	RemoteAddress string    `json:"remote_address,omitempty"`
+
+	// the stream tap traces
+	RequestReceivedDuration  string   `json:"request_received_duration,omitempty"`
+	ResponseReceivedDuration string   `json:"response_received_duration,omitempty"`
+	Request                  *Message `json:"request,omitempty"`
+	Response                 *Message `json:"response,omitempty"`
+
+	// the network tap traces
+	Read    *Body `json:"read,omitempty"`
+	Written *Body `json:"written,omitempty"`
+}
+
+// Message is a tapped request or response
+type Message struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    *Body             `json:"body,omitempty"`
+}
+
+// Body is the captured bytes, which is truncated to the max body bytes
+type Body struct {
+	Data string `json:"data"`
+	// Base64 is true if the data is binary and encoded in base64
+	Base64    bool `json:"base64,omitempty"`
+	Size      int  `json:"size"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Capture captures the prefix of the bytes
+type Capture struct {
+	max  int
+	size int
+	data []byte
+}
+
+// NewCapture creates a capture that keeps max bytes at most
+func NewCapture(max uint32) *Capture {
+	return &Capture{
+		max: int(max),
+	}
+}
+
+// Write captures the bytes
+func (c *Capture) Write(p []byte) {
+	c.size += len(p)
+	if left := c.max - len(c.data); left > 0 {
+		if len(p) > left {
+			p = p[:left]
+		}
+		c.data = append(c.data, p...)
+	}
+}
+
+// Size returns the total bytes size that is written
+func (c *Capture) Size() int {
+	return c.size
+}
+
+// Body returns the captured body, the data is encoded in base64 if it is binary or not valid utf8
+func (c *Capture) Body(binary bool) *Body {
+	body := &Body{
+		Size:      c.size,
+		Truncated: c.size > len(c.data),
+	}
+	if !binary {
+		if text, ok := validText(c.data, body.Truncated); ok {
+			body.Data = text
+			return body
+		}
+	}
+	body.Data = base64.StdEncoding.EncodeToString(c.data)
+	body.Base64 = true
+	return body
+}
+
+// validText returns the utf8 text of the data, a rune cut off by the truncation is dropped
+func validText(data []byte, truncated bool) (string, bool) {
+	if utf8.Valid(data) {
+		return string(data), true
+	}
+	if truncated {
+		for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+			if utf8.Valid(data[:len(data)-i]) {
+				return string(data[:len(data)-i]), true
+			}
+		}
+	}
+	return "", false
+}
@@ -66,6 +66,7 @@ const (
 	UnknownCode           = 2
 	DeserialExceptionCode = 3
 	SuccessCode           = 200
+	UnauthorizedCode      = 401
 	PermissionDeniedCode  = 403
 	RouterUnavailableCode = 404
 	NoHealthUpstreamCode  = 502
@@ -42,6 +42,8 @@ const (
 	ContextKeyTraceId
 	ContextKeyVariables
 	ContextKeyDownStreamProtocol
+	ContextKeyProxyGeneralConfig
+	ContextKeyStreamTimeoutConfig
 	ContextKeyEnd
 )
 
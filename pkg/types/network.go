@@ -171,6 +171,26 @@ type ConnectionStats struct {
 	WriteBuffered metrics.Gauge
 }
 
+// WatermarkListener is notified when the buffered data to be written of a connection crosses the watermarks,
+// the high watermark is the buffer limit of the connection, and the low watermark is half of it.
+// A ConnectionEventListener that implements it will be notified, it is also used by the stream layer to
+// notify the streams on the connection.
+type WatermarkListener interface {
+	// OnAboveWriteBufferHighWatermark is called when the buffered data goes above the high watermark
+	OnAboveWriteBufferHighWatermark()
+
+	// OnBelowWriteBufferLowWatermark is called when the buffered data drops below the low watermark
+	// after it has gone above the high watermark
+	OnBelowWriteBufferLowWatermark()
+}
+
+// DrainListener is implemented by the network filters that can drain the connection gracefully,
+// for example, the proxy asks the downstream to go away by the protocol.
+type DrainListener interface {
+	// OnDrain is called when the server starts draining the connection
+	OnDrain()
+}
+
 // ClientConnection is a wrapper of Connection
 type ClientConnection interface {
 	api.Connection
@@ -189,6 +209,9 @@ const (
 
 // ConnectionHandler contains the listeners for a mosn server
 type ConnectionHandler interface {
+	// NumConnections reports the connections that ConnectionHandler keeps.
+	NumConnections() uint64
+
 	// AddOrUpdateListener
 	// adds a listener into the ConnectionHandler or
 	// update a listener
@@ -218,6 +241,11 @@ type ConnectionHandler interface {
 
 	// StopConnection Stop Connection
 	StopConnection()
+
+	// DrainConnections drains the connections gracefully, the connections are notified in the drain timeout,
+	// and the connections accepted during draining are notified at once.
+	// The close indicates whether the connections still alive will be closed after the timeout.
+	DrainConnections(timeout time.Duration, close bool)
 }
 
 type FilterChainFactory interface {
@@ -84,6 +84,21 @@ type RouteHandler interface {
 	// Route returns handler's route
 	Route() api.Route
 }
+
+// TunnelRouteRule is a route rule that can accept HTTP CONNECT requests
+type TunnelRouteRule interface {
+	// ConnectConfig returns the tunnel config of the route rule, nil means the CONNECT request is not allowed
+	ConnectConfig() *v2.ConnectConfig
+	// AllowTunnelAuthority checks whether the dynamic host tunnel can connect to the authority (host:port)
+	AllowTunnelAuthority(authority string) bool
+}
+
+// StreamTimeoutRouteRule is a route rule that configures the stream timeouts
+type StreamTimeoutRouteRule interface {
+	// StreamTimeoutConfig returns the stream timeouts of the route rule, it overrides the listener's config
+	StreamTimeoutConfig() v2.StreamTimeoutConfig
+}
+
 type RouterWrapper interface {
 	// GetRouters returns the routers in the wrapper
 	GetRouters() Routers
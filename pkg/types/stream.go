@@ -109,6 +109,18 @@ const (
 	UpstreamReset               StreamResetReason = "UpstreamReset"
 	UpstreamGlobalTimeout       StreamResetReason = "UpstreamGlobalTimeout"
 	UpstreamPerTryTimeout       StreamResetReason = "UpstreamPerTryTimeout"
+	StreamIdleTimeout           StreamResetReason = "StreamIdleTimeout"
+	StreamMaxDurationTimeout    StreamResetReason = "StreamMaxDurationTimeout"
+)
+
+// The response flags not defined in mosn.io/api
+const (
+	StreamIdleTimeoutFlag api.ResponseFlag = 0x4000
+	StreamMaxDurationFlag api.ResponseFlag = 0x8000
+	// UnauthorizedFlag is set when the request is rejected by the authentication or authorization
+	UnauthorizedFlag api.ResponseFlag = 0x10000
+	// OverloadFlag is set when the request is rejected by the overload manager
+	OverloadFlag api.ResponseFlag = 0x20000
 )
 
 // Stream is a generic protocol stream, it is the core model in stream layer
@@ -129,6 +141,10 @@ type Stream interface {
 	// DestroyStream destroys stream, called after stream process in client/server cases.
 	// Any registered StreamEventListener.OnDestroyStream will be called.
 	DestroyStream()
+
+	// ReadDisable stops or resumes reading the data of the stream, it is used for flow control.
+	// The calls should be balanced, and the whole connection is disabled if the protocol has no stream level flow control.
+	ReadDisable(disable bool)
 }
 
 // StreamEventListener is a stream event listener
@@ -170,6 +186,59 @@ type StreamReceiveListener interface {
 	OnDecodeError(ctx context.Context, err error, headers api.HeaderMap)
 }
 
+// TunnelReceiver is called on the raw data of a tunnel established by a HTTP CONNECT request
+type TunnelReceiver interface {
+	// OnTunnelData is called with the data received from the tunnel's downstream
+	OnTunnelData(data buffer.IoBuffer)
+
+	// OnTunnelClose is called when the tunnel's downstream is closed
+	OnTunnelClose()
+}
+
+// TunnelSender is a StreamSender that can be switched to a raw tunnel,
+// it is implemented by the server streams that support HTTP CONNECT.
+type TunnelSender interface {
+	// StartTunnel sends a successful response of the CONNECT request, and switches the stream into tunnel mode.
+	// the data received after the CONNECT request will be passed to the receiver
+	StartTunnel(ctx context.Context, receiver TunnelReceiver) error
+
+	// SendTunnelData sends the data to the tunnel's downstream
+	SendTunnelData(data buffer.IoBuffer) error
+
+	// ReadDisableTunnel stops or resumes receiving the data from the tunnel's downstream,
+	// it is called when the tunnel's upstream crosses the write buffer watermarks
+	ReadDisableTunnel(disable bool)
+
+	// CloseTunnel closes the tunnel's downstream
+	CloseTunnel()
+}
+
+// StreamingBuffer is the body of a stream in streaming mode, it is read while the stream is still receiving it.
+// Reading the whole body, such as Bytes(), waits for the end of the body, as the buffered mode does.
+type StreamingBuffer interface {
+	buffer.IoBuffer
+
+	// CloseRead is called when the body will not be read any more, the rest of the body is discarded
+	CloseRead()
+
+	// SetActiveListener sets the listener called when the body is written or read, it is used to check whether the stream is idle
+	SetActiveListener(listener func())
+}
+
+// StreamFilterPause is returned by a receiver filter that waits for an asynchronous result, such as a response
+// of an external service. The filter chain is paused without holding the proxy goroutine, and the filter
+// must call ResumableStreamReceiverFilterHandler.ContinueReceiving once to resume it.
+const StreamFilterPause api.StreamFilterStatus = "Pause"
+
+// ResumableStreamReceiverFilterHandler is a StreamReceiverFilterHandler that supports StreamFilterPause
+type ResumableStreamReceiverFilterHandler interface {
+	api.StreamReceiverFilterHandler
+
+	// ContinueReceiving resumes the paused filter chain from the next filter.
+	// The filter can modify the request or call SendHijackReply before it.
+	ContinueReceiving()
+}
+
 // StreamConnection is a connection runs multiple streams
 type StreamConnection interface {
 	// Dispatch incoming data
@@ -170,6 +170,13 @@ type ClusterInfo interface {
 	LbConfig() v2.IsCluster_LbConfig
 }
 
+// ConnPoolConfigGetter is implemented by the ClusterInfo that has the config of the multiplexed
+// connections to each host, the connection pools keep one connection per host if not implemented
+type ConnPoolConfigGetter interface {
+	// ConnPoolConfig returns the config of the multiplexed connections to each host
+	ConnPoolConfig() v2.ConnPoolConfig
+}
+
 // ResourceManager manages different types of Resource
 type ResourceManager interface {
 	// Connections resource to count connections in pool. Only used by protocol which has a connection pool which has multiple connections.
@@ -217,6 +224,12 @@ type HostStats struct {
 	UpstreamRequestDurationTotal                   metrics.Counter
 	UpstreamResponseSuccess                        metrics.Counter
 	UpstreamResponseFailed                         metrics.Counter
+	// UpstreamConnectionPoolConnected is the connected multiplexed connections of the pool
+	UpstreamConnectionPoolConnected metrics.Gauge
+	// UpstreamConnectionPoolStreamsMax is the active streams of the busiest connection of the pool
+	UpstreamConnectionPoolStreamsMax metrics.Gauge
+	// UpstreamConnectionPoolStreamsTotal is the active streams of all the connections of the pool
+	UpstreamConnectionPoolStreamsTotal metrics.Gauge
 }
 
 // ClusterStats defines a cluster's statistics information
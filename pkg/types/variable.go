@@ -59,3 +59,10 @@ const (
 	VarPrefixHttpArg    = "http_arg_"
 	VarPrefixHttpCookie = "http_cookie_"
 )
+
+// [Filter]: request id
+const (
+	VarRequestID = "request_id"
+	// VarRequestIDSampled is "true" or "false" if the tracing sampling is decided by the request id
+	VarRequestIDSampled = "request_id_sampled"
+)
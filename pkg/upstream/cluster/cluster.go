@@ -56,6 +56,7 @@ func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
 		lbOriDstInfo:         NewLBOriDstInfo(&clusterConfig.LBOriDstConfig), // new oridst load balancer info
 		lbType:               types.LoadBalancerType(clusterConfig.LbType),
 		resourceManager:      NewResourceManager(clusterConfig.CirBreThresholds),
+		connPoolConfig:       clusterConfig.ConnPool,
 	}
 
 	// set ConnectTimeout
@@ -147,6 +148,7 @@ type clusterInfo struct {
 	tlsMng               types.TLSContextManager
 	connectTimeout       time.Duration
 	lbConfig             v2.IsCluster_LbConfig
+	connPoolConfig       v2.ConnPoolConfig
 }
 
 func (ci *clusterInfo) Name() string {
@@ -197,6 +199,10 @@ func (ci *clusterInfo) LbConfig() v2.IsCluster_LbConfig {
 	return ci.lbConfig
 }
 
+func (ci *clusterInfo) ConnPoolConfig() v2.ConnPoolConfig {
+	return ci.connPoolConfig
+}
+
 type clusterSnapshot struct {
 	info    types.ClusterInfo
 	hostSet types.HostSet
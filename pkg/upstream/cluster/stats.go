@@ -46,6 +46,9 @@ func newHostStats(clustername string, addr string) types.HostStats {
 		UpstreamRequestDurationTotal:                   s.Counter(metrics.UpstreamRequestDurationTotal),
 		UpstreamResponseSuccess:                        s.Counter(metrics.UpstreamResponseSuccess),
 		UpstreamResponseFailed:                         s.Counter(metrics.UpstreamResponseFailed),
+		UpstreamConnectionPoolConnected:                s.Gauge(metrics.UpstreamConnectionPoolConnected),
+		UpstreamConnectionPoolStreamsMax:               s.Gauge(metrics.UpstreamConnectionPoolStreamsMax),
+		UpstreamConnectionPoolStreamsTotal:             s.Gauge(metrics.UpstreamConnectionPoolStreamsTotal),
 	}
 }
 
@@ -80,7 +80,8 @@ func SetVariableValue(ctx context.Context, name, value string) error {
 // TODO: provide direct access to this function, so the cost of variable name finding could be optimized
 func getFlushedVariableValue(ctx context.Context, index uint32) (string, error) {
 	if variables := ctx.Value(types.ContextKeyVariables); variables != nil {
-		if values, ok := variables.([]IndexedValue); ok {
+		// the variables registered after the context created are not in the context
+		if values, ok := variables.([]IndexedValue); ok && int(index) < len(values) {
 			value := &values[index]
 			if value.Valid || value.NotFound {
 				if !value.noCacheable {
@@ -126,7 +127,7 @@ func getIndexedVariableValue(ctx context.Context, value *IndexedValue, index uin
 
 func setFlushedVariableValue(ctx context.Context, index uint32, value string) error {
 	if variables := ctx.Value(types.ContextKeyVariables); variables != nil {
-		if values, ok := variables.([]IndexedValue); ok {
+		if values, ok := variables.([]IndexedValue); ok && int(index) < len(values) {
 			variable := indexedVariables[index]
 			variableValue := &values[index]
 
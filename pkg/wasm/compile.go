@@ -0,0 +1,571 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package wasm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// instr is a compiled instruction, the immediates are decoded and the branch targets are resolved
+type instr struct {
+	op uint16
+	a  uint32
+	b  uint64
+}
+
+// branchTarget is the destination of a branch, the operands between the kept results
+// and the label height are dropped when the branch is taken.
+type branchTarget struct {
+	pc   uint32
+	drop uint32
+	keep uint32
+}
+
+// function is a compiled function or a host function
+type function struct {
+	typ       *FuncType
+	numParams int
+	// numLocals is the count of the parameters and the declared locals
+	numLocals int
+	code      []instr
+	targets   []branchTarget
+	// maxHeight is the max height of the operand stack
+	maxHeight int
+	host      *HostFunction
+}
+
+// ctrlFrame is a block, a loop, an if or the function body in the compiling
+type ctrlFrame struct {
+	op          byte
+	params      int
+	results     int
+	height      int
+	start       uint32
+	unreachable bool
+	ifInstr     int
+	// endTargets and endJumps are patched to the end of the block
+	endTargets []int
+	endJumps   []int
+}
+
+type compiler struct {
+	m      *Module
+	f      *function
+	r      *reader
+	frames []*ctrlFrame
+	height int
+}
+
+func (m *Module) compile() error {
+	if len(m.memories) > 0 && m.memories[0].Min > maxPages {
+		return errors.New("memory size must be at most 65536 pages")
+	}
+	for i, g := range m.globals {
+		if g.init.op == opGlobalGet && int(g.init.value) >= i {
+			return fmt.Errorf("global %d: unknown global", i)
+		}
+		if g.init.op == opRefFunc && int(g.init.value) >= len(m.funcTypes) {
+			return fmt.Errorf("global %d: unknown function", i)
+		}
+	}
+	for _, e := range m.exports {
+		if !m.validIndex(e.Kind, e.Index) {
+			return fmt.Errorf("export %s: unknown index %d", e.Name, e.Index)
+		}
+	}
+	if m.start >= 0 {
+		if int(m.start) >= len(m.funcTypes) {
+			return errors.New("unknown start function")
+		}
+		if t := m.funcTypes[m.start]; len(t.Params) != 0 || len(t.Results) != 0 {
+			return errors.New("start function must be [] -> []")
+		}
+	}
+	for i, e := range m.elements {
+		if e.mode == segmentActive && int(e.table) >= len(m.tables) {
+			return fmt.Errorf("element %d: unknown table", i)
+		}
+		if e.mode == segmentActive && !m.validConst(e.offset) {
+			return fmt.Errorf("element %d: unknown global", i)
+		}
+		for _, init := range e.inits {
+			if init.op == opRefFunc && int(init.value) >= len(m.funcTypes) {
+				return fmt.Errorf("element %d: unknown function", i)
+			}
+			if !m.validConst(init) {
+				return fmt.Errorf("element %d: unknown global", i)
+			}
+		}
+	}
+	for i, d := range m.datas {
+		if d.mode == segmentActive && len(m.memories) == 0 {
+			return fmt.Errorf("data %d: unknown memory", i)
+		}
+		if d.mode == segmentActive && !m.validConst(d.offset) {
+			return fmt.Errorf("data %d: unknown global", i)
+		}
+	}
+	m.compiled = make([]*function, len(m.codes))
+	for i, c := range m.codes {
+		f, err := m.compileFunction(m.types[m.funcs[i]], c)
+		if err != nil {
+			return fmt.Errorf("function %d: %v", m.numImport+i, err)
+		}
+		m.compiled[i] = f
+	}
+	return nil
+}
+
+// validConst checks the global referred by the constant expression
+func (m *Module) validConst(e constExpr) bool {
+	return e.op != opGlobalGet || int(e.value) < len(m.globals)
+}
+
+func (m *Module) validIndex(kind ExternalKind, idx uint32) bool {
+	switch kind {
+	case ExternalFunction:
+		return int(idx) < len(m.funcTypes)
+	case ExternalTable:
+		return int(idx) < len(m.tables)
+	case ExternalMemory:
+		return int(idx) < len(m.memories)
+	case ExternalGlobal:
+		return int(idx) < len(m.globals)
+	}
+	return false
+}
+
+func (m *Module) compileFunction(typ *FuncType, c *code) (*function, error) {
+	f := &function{
+		typ:       typ,
+		numParams: len(typ.Params),
+		numLocals: len(typ.Params) + len(c.locals),
+	}
+	cp := &compiler{
+		m: m,
+		f: f,
+		r: &reader{buf: c.body},
+	}
+	cp.frames = []*ctrlFrame{{
+		results: len(typ.Results),
+		ifInstr: -1,
+	}}
+	if err := cp.compile(); err != nil {
+		return nil, fmt.Errorf("at offset %d: %v", cp.r.pos, err)
+	}
+	return f, nil
+}
+
+func (c *compiler) emit(in instr) int {
+	c.f.code = append(c.f.code, in)
+	return len(c.f.code) - 1
+}
+
+func (c *compiler) pc() uint32 {
+	return uint32(len(c.f.code))
+}
+
+func (c *compiler) frame() *ctrlFrame {
+	return c.frames[len(c.frames)-1]
+}
+
+func (c *compiler) push(n int) {
+	c.height += n
+	if c.height > c.f.maxHeight {
+		c.f.maxHeight = c.height
+	}
+}
+
+func (c *compiler) pop(n int) error {
+	c.height -= n
+	if fr := c.frame(); c.height < fr.height {
+		if !fr.unreachable {
+			return errors.New("type mismatch: operand stack underflow")
+		}
+		c.height = fr.height
+	}
+	return nil
+}
+
+// setUnreachable marks the rest of the block is unreachable, the operand stack is polymorphic
+func (c *compiler) setUnreachable() {
+	fr := c.frame()
+	fr.unreachable = true
+	c.height = fr.height
+}
+
+func (c *compiler) blockType() (params, results int, err error) {
+	b := c.r.buf
+	if c.r.pos >= len(b) {
+		return 0, 0, errUnexpectedEnd
+	}
+	switch ValueType(b[c.r.pos]) {
+	case 0x40:
+		c.r.pos++
+		return 0, 0, nil
+	case ValueTypeI32, ValueTypeI64, ValueTypeF32, ValueTypeF64, ValueTypeFuncRef, ValueTypeExternRef:
+		c.r.pos++
+		return 0, 1, nil
+	}
+	idx, err := c.r.signed(33)
+	if err != nil {
+		return 0, 0, err
+	}
+	if idx < 0 || int(idx) >= len(c.m.types) {
+		return 0, 0, fmt.Errorf("unknown block type %d", idx)
+	}
+	t := c.m.types[idx]
+	return len(t.Params), len(t.Results), nil
+}
+
+// branchTarget creates the target of the label
+func (c *compiler) branchTarget(depth uint32) (int, error) {
+	if int(depth) >= len(c.frames) {
+		return 0, fmt.Errorf("unknown label %d", depth)
+	}
+	fr := c.frames[len(c.frames)-1-int(depth)]
+	idx := len(c.f.targets)
+	t := branchTarget{}
+	if fr.op == opLoop {
+		t.pc = fr.start
+		t.keep = uint32(fr.params)
+	} else {
+		t.keep = uint32(fr.results)
+		fr.endTargets = append(fr.endTargets, idx)
+	}
+	drop := c.height - int(t.keep) - fr.height
+	if drop < 0 {
+		if !c.frame().unreachable {
+			return 0, errors.New("type mismatch: not enough operands for the branch")
+		}
+		drop = 0
+	}
+	t.drop = uint32(drop)
+	c.f.targets = append(c.f.targets, t)
+	return idx, nil
+}
+
+func (c *compiler) memarg() (uint64, error) {
+	if len(c.m.memories) == 0 {
+		return 0, errors.New("unknown memory")
+	}
+	if _, err := c.r.u32(); err != nil {
+		return 0, err
+	}
+	offset, err := c.r.u32()
+	return uint64(offset), err
+}
+
+func (c *compiler) zeroByte() error {
+	b, err := c.r.byte()
+	if err != nil {
+		return err
+	}
+	if b != 0 {
+		return errors.New("zero byte expected")
+	}
+	if len(c.m.memories) == 0 {
+		return errors.New("unknown memory")
+	}
+	return nil
+}
+
+func (c *compiler) index(limit int, what string) (uint32, error) {
+	idx, err := c.r.u32()
+	if err != nil {
+		return 0, err
+	}
+	if int(idx) >= limit {
+		return 0, fmt.Errorf("unknown %s %d", what, idx)
+	}
+	return idx, nil
+}
+
+func (c *compiler) compile() error {
+	for {
+		b, err := c.r.byte()
+		if err != nil {
+			return err
+		}
+		op := uint16(b)
+		if b == opPrefixFC {
+			sub, err := c.r.u32()
+			if err != nil {
+				return err
+			}
+			if sub > 17 {
+				return fmt.Errorf("unknown instruction 0xfc %d", sub)
+			}
+			op = 0x100 + uint16(sub)
+		}
+		done, err := c.compileInstr(op)
+		if err != nil {
+			return err
+		}
+		if done {
+			if !c.r.eof() {
+				return errors.New("operators after the end of the function")
+			}
+			return nil
+		}
+	}
+}
+
+func (c *compiler) compileInstr(op uint16) (bool, error) {
+	m := c.m
+	switch op {
+	case opNop:
+	case opUnreachable:
+		c.emit(instr{op: op})
+		c.setUnreachable()
+	case opBlock, opLoop, opIf:
+		params, results, err := c.blockType()
+		if err != nil {
+			return false, err
+		}
+		if op == opIf {
+			if err := c.pop(1); err != nil {
+				return false, err
+			}
+		}
+		if err := c.pop(params); err != nil {
+			return false, err
+		}
+		fr := &ctrlFrame{
+			op:      byte(op),
+			params:  params,
+			results: results,
+			height:  c.height,
+			start:   c.pc(),
+			ifInstr: -1,
+		}
+		if op == opIf {
+			fr.ifInstr = c.emit(instr{op: opIf})
+		}
+		c.frames = append(c.frames, fr)
+		c.push(params)
+	case opElse:
+		fr := c.frame()
+		if fr.op != opIf || fr.ifInstr < 0 {
+			return false, errors.New("else must be in an if block")
+		}
+		if !fr.unreachable && c.height != fr.height+fr.results {
+			return false, errors.New("type mismatch in if true branch")
+		}
+		fr.endJumps = append(fr.endJumps, c.emit(instr{op: opJump}))
+		// the false branch starts after the jump
+		c.f.code[fr.ifInstr].a = c.pc()
+		fr.ifInstr = -1
+		fr.unreachable = false
+		c.height = fr.height
+		c.push(fr.params)
+	case opEnd:
+		fr := c.frame()
+		if !fr.unreachable && c.height != fr.height+fr.results {
+			return false, errors.New("type mismatch in block")
+		}
+		if len(c.frames) == 1 {
+			// the function body ends with the return
+			end := c.pc()
+			for _, t := range fr.endTargets {
+				c.f.targets[t].pc = end
+			}
+			c.emit(instr{op: opReturn})
+			return true, nil
+		}
+		if fr.ifInstr >= 0 {
+			if fr.params != fr.results {
+				return false, errors.New("type mismatch in if false branch")
+			}
+			c.f.code[fr.ifInstr].a = c.pc()
+		}
+		end := c.pc()
+		for _, t := range fr.endTargets {
+			c.f.targets[t].pc = end
+		}
+		for _, j := range fr.endJumps {
+			c.f.code[j].a = end
+		}
+		c.frames = c.frames[:len(c.frames)-1]
+		c.height = fr.height
+		c.push(fr.results)
+	case opBr, opBrIf:
+		depth, err := c.r.u32()
+		if err != nil {
+			return false, err
+		}
+		if op == opBrIf {
+			if err := c.pop(1); err != nil {
+				return false, err
+			}
+		}
+		t, err := c.branchTarget(depth)
+		if err != nil {
+			return false, err
+		}
+		c.emit(instr{op: op, a: uint32(t)})
+		if op == opBr {
+			c.setUnreachable()
+		}
+	case opBrTable:
+		n, err := c.r.vecLen()
+		if err != nil {
+			return false, err
+		}
+		if err := c.pop(1); err != nil {
+			return false, err
+		}
+		start := len(c.f.targets)
+		for i := 0; i <= n; i++ {
+			depth, err := c.r.u32()
+			if err != nil {
+				return false, err
+			}
+			if _, err := c.branchTarget(depth); err != nil {
+				return false, err
+			}
+		}
+		c.emit(instr{op: op, a: uint32(start), b: uint64(n)})
+		c.setUnreachable()
+	case opReturn:
+		if err := c.pop(len(c.f.typ.Results)); err != nil {
+			return false, err
+		}
+		c.emit(instr{op: op})
+		c.setUnreachable()
+	case opCall:
+		idx, err := c.index(len(m.funcTypes), "function")
+		if err != nil {
+			return false, err
+		}
+		t := m.funcTypes[idx]
+		if err := c.pop(len(t.Params)); err != nil {
+			return false, err
+		}
+		c.push(len(t.Results))
+		c.emit(instr{op: op, a: idx})
+	case opCallIndirect:
+		typeIdx, err := c.index(len(m.types), "type")
+		if err != nil {
+			return false, err
+		}
+		tableIdx, err := c.index(len(m.tables), "table")
+		if err != nil {
+			return false, err
+		}
+		t := m.types[typeIdx]
+		if err := c.pop(1 + len(t.Params)); err != nil {
+			return false, err
+		}
+		c.push(len(t.Results))
+		c.emit(instr{op: op, a: typeIdx, b: uint64(tableIdx)})
+	default:
+		return false, c.compileSimple(op)
+	}
+	return false, nil
+}
+
+// compileSimple compiles the instructions that have fixed stack effects
+func (c *compiler) compileSimple(op uint16) error {
+	m := c.m
+	pop, push, ok := stackEffect(op)
+	if !ok {
+		return fmt.Errorf("unknown instruction 0x%x", op)
+	}
+	in := instr{op: op}
+	var err error
+	switch {
+	case op == opSelectTyped:
+		n, err := c.r.vecLen()
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return errors.New("invalid result arity of select")
+		}
+		if _, err := c.r.valueType(); err != nil {
+			return err
+		}
+		in.op = opSelect
+	case op >= opLocalGet && op <= opLocalTee:
+		in.a, err = c.index(c.f.numLocals, "local")
+	case op == opGlobalGet:
+		in.a, err = c.index(len(m.globals), "global")
+	case op == opGlobalSet:
+		if in.a, err = c.index(len(m.globals), "global"); err == nil && !m.globals[in.a].mutable {
+			err = errors.New("global is immutable")
+		}
+	case op >= opI32Load && op <= opI64Store32:
+		in.b, err = c.memarg()
+	case op == opMemorySize || op == opMemoryGrow || op == opMemoryFill:
+		err = c.zeroByte()
+	case op == opMemoryCopy:
+		if err = c.zeroByte(); err == nil {
+			err = c.zeroByte()
+		}
+	case op == opI32Const:
+		var v int32
+		v, err = c.r.s32()
+		in.b = uint64(uint32(v))
+	case op == opI64Const:
+		var v int64
+		v, err = c.r.s64()
+		in.b = uint64(v)
+	case op == opF32Const:
+		var v uint32
+		v, err = c.r.f32()
+		in.b = uint64(v)
+	case op == opF64Const:
+		in.b, err = c.r.f64()
+	case op == opRefNull:
+		_, err = c.r.refType()
+	case op == opRefFunc:
+		in.a, err = c.index(len(m.funcTypes), "function")
+	case op == opTableGet || op == opTableSet || op == opTableGrow || op == opTableSize || op == opTableFill:
+		in.a, err = c.index(len(m.tables), "table")
+	case op == opMemoryInit:
+		if in.a, err = c.index(len(m.datas), "data"); err == nil {
+			err = c.zeroByte()
+		}
+	case op == opDataDrop:
+		in.a, err = c.index(len(m.datas), "data")
+	case op == opTableInit:
+		var table uint32
+		if in.a, err = c.index(len(m.elements), "element"); err == nil {
+			table, err = c.index(len(m.tables), "table")
+			in.b = uint64(table)
+		}
+	case op == opElemDrop:
+		in.a, err = c.index(len(m.elements), "element")
+	case op == opTableCopy:
+		var src uint32
+		if in.a, err = c.index(len(m.tables), "table"); err == nil {
+			src, err = c.index(len(m.tables), "table")
+			in.b = uint64(src)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.pop(pop); err != nil {
+		return err
+	}
+	c.push(push)
+	c.emit(in)
+	return nil
+}
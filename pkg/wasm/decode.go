@@ -0,0 +1,696 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	magic   = 0x6d736100
+	version = 1
+
+	// the limits protect the host from the malformed modules
+	maxVectorLength = 1 << 20
+	maxLocals       = 50000
+	maxPages        = 65536
+	maxTableSize    = 10000000
+)
+
+// section ids
+const (
+	sectionCustom    = 0
+	sectionType      = 1
+	sectionImport    = 2
+	sectionFunction  = 3
+	sectionTable     = 4
+	sectionMemory    = 5
+	sectionGlobal    = 6
+	sectionExport    = 7
+	sectionStart     = 8
+	sectionElement   = 9
+	sectionCode      = 10
+	sectionData      = 11
+	sectionDataCount = 12
+)
+
+var errUnexpectedEnd = errors.New("unexpected end")
+
+// reader reads the binary format
+type reader struct {
+	buf []byte
+	pos int
+}
+
+func (r *reader) eof() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, errUnexpectedEnd
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(r.buf)-r.pos {
+		return nil, errUnexpectedEnd
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) u32() (uint32, error) {
+	var result uint32
+	for shift := uint(0); ; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		if shift == 28 && b > 0x0f {
+			return 0, errors.New("integer too large")
+		}
+		result |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+	}
+}
+
+func (r *reader) s32() (int32, error) {
+	v, err := r.signed(32)
+	return int32(v), err
+}
+
+func (r *reader) s64() (int64, error) {
+	return r.signed(64)
+}
+
+func (r *reader) signed(size uint) (int64, error) {
+	var result int64
+	var shift uint
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		if shift >= size {
+			return 0, errors.New("integer too large")
+		}
+		result |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				result |= -1 << shift
+			}
+			return result, nil
+		}
+	}
+}
+
+func (r *reader) f32() (uint32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *reader) f64() (uint64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (r *reader) vecLen() (int, error) {
+	n, err := r.u32()
+	if err != nil {
+		return 0, err
+	}
+	// every entry takes at least one byte
+	if n > maxVectorLength || int(n) > len(r.buf)-r.pos {
+		return 0, fmt.Errorf("vector length %d is too large", n)
+	}
+	return int(n), nil
+}
+
+func (r *reader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", errors.New("invalid utf-8 name")
+	}
+	return string(b), nil
+}
+
+func (r *reader) valueType() (ValueType, error) {
+	b, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch t := ValueType(b); t {
+	case ValueTypeI32, ValueTypeI64, ValueTypeF32, ValueTypeF64, ValueTypeFuncRef, ValueTypeExternRef:
+		return t, nil
+	}
+	return 0, fmt.Errorf("unsupported value type 0x%x", b)
+}
+
+func (r *reader) refType() (ValueType, error) {
+	t, err := r.valueType()
+	if err == nil && t != ValueTypeFuncRef && t != ValueTypeExternRef {
+		err = fmt.Errorf("invalid reference type %s", t)
+	}
+	return t, err
+}
+
+func (r *reader) limits(max uint32) (Limits, error) {
+	var l Limits
+	flag, err := r.byte()
+	if err != nil {
+		return l, err
+	}
+	if flag > 1 {
+		return l, fmt.Errorf("unsupported limits flag 0x%x", flag)
+	}
+	if l.Min, err = r.u32(); err != nil {
+		return l, err
+	}
+	if flag == 1 {
+		l.HasMax = true
+		if l.Max, err = r.u32(); err != nil {
+			return l, err
+		}
+		if l.Max < l.Min {
+			return l, errors.New("size minimum must not be greater than maximum")
+		}
+	}
+	if l.Min > max || (l.HasMax && l.Max > max) {
+		return l, fmt.Errorf("size must be at most %d", max)
+	}
+	return l, nil
+}
+
+// constExpr reads a constant expression that ends with the end instruction
+func (r *reader) constExpr() (constExpr, error) {
+	var e constExpr
+	op, err := r.byte()
+	if err != nil {
+		return e, err
+	}
+	e.op = op
+	switch op {
+	case opI32Const:
+		v, err := r.s32()
+		if err != nil {
+			return e, err
+		}
+		e.value = uint64(uint32(v))
+	case opI64Const:
+		v, err := r.s64()
+		if err != nil {
+			return e, err
+		}
+		e.value = uint64(v)
+	case opF32Const:
+		v, err := r.f32()
+		if err != nil {
+			return e, err
+		}
+		e.value = uint64(v)
+	case opF64Const:
+		if e.value, err = r.f64(); err != nil {
+			return e, err
+		}
+	case opGlobalGet, opRefFunc:
+		v, err := r.u32()
+		if err != nil {
+			return e, err
+		}
+		e.value = uint64(v)
+	case opRefNull:
+		if _, err := r.refType(); err != nil {
+			return e, err
+		}
+	default:
+		return e, fmt.Errorf("unsupported constant expression 0x%x", op)
+	}
+	end, err := r.byte()
+	if err != nil {
+		return e, err
+	}
+	if end != opEnd {
+		return e, errors.New("constant expression must end with end")
+	}
+	return e, nil
+}
+
+// Decode decodes and compiles the binary format of a module
+func Decode(buf []byte) (*Module, error) {
+	m, err := decode(buf)
+	if err == ErrInvalidMagic || err == ErrInvalidVersion {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("wasm: decode module failed: %v", err)
+	}
+	if err := m.compile(); err != nil {
+		return nil, fmt.Errorf("wasm: compile module failed: %v", err)
+	}
+	return m, nil
+}
+
+func decode(buf []byte) (*Module, error) {
+	if len(buf) < 8 || binary.LittleEndian.Uint32(buf) != magic {
+		return nil, ErrInvalidMagic
+	}
+	if binary.LittleEndian.Uint32(buf[4:]) != version {
+		return nil, ErrInvalidVersion
+	}
+	m := &Module{
+		exports:   make(map[string]*Export),
+		start:     -1,
+		dataCount: -1,
+	}
+	r := &reader{buf: buf, pos: 8}
+	var last byte
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		content, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+		if id == sectionCustom {
+			continue
+		}
+		// the data count section is placed before the code section
+		order := id
+		if id == sectionDataCount {
+			order = sectionElement + 1
+		} else if id == sectionCode || id == sectionData {
+			order = id + 1
+		}
+		if order <= last {
+			return nil, fmt.Errorf("unexpected section %d", id)
+		}
+		last = order
+		sr := &reader{buf: content}
+		if err := m.decodeSection(id, sr); err != nil {
+			return nil, fmt.Errorf("section %d: %v", id, err)
+		}
+		if !sr.eof() {
+			return nil, fmt.Errorf("section %d: size mismatch", id)
+		}
+	}
+	if len(m.funcs) != len(m.codes) {
+		return nil, errors.New("function and code section have inconsistent lengths")
+	}
+	if m.dataCount >= 0 && int(m.dataCount) != len(m.datas) {
+		return nil, errors.New("data count and data section have inconsistent lengths")
+	}
+	return m, nil
+}
+
+func (m *Module) decodeSection(id byte, r *reader) error {
+	switch id {
+	case sectionType:
+		return m.decodeTypes(r)
+	case sectionImport:
+		return m.decodeImports(r)
+	case sectionFunction:
+		n, err := r.vecLen()
+		if err != nil {
+			return err
+		}
+		m.funcs = make([]uint32, n)
+		for i := range m.funcs {
+			if m.funcs[i], err = r.u32(); err != nil {
+				return err
+			}
+			if int(m.funcs[i]) >= len(m.types) {
+				return fmt.Errorf("unknown type %d", m.funcs[i])
+			}
+			m.funcTypes = append(m.funcTypes, m.types[m.funcs[i]])
+		}
+	case sectionTable:
+		n, err := r.vecLen()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			t := &table{}
+			if t.elemType, err = r.refType(); err != nil {
+				return err
+			}
+			if t.limits, err = r.limits(maxTableSize); err != nil {
+				return err
+			}
+			m.tables = append(m.tables, t)
+		}
+	case sectionMemory:
+		n, err := r.vecLen()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			l, err := r.limits(maxPages)
+			if err != nil {
+				return err
+			}
+			m.memories = append(m.memories, l)
+		}
+		if len(m.memories) > 1 {
+			return errors.New("multiple memories are not supported")
+		}
+	case sectionGlobal:
+		n, err := r.vecLen()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			g := &global{}
+			if g.typ, err = r.valueType(); err != nil {
+				return err
+			}
+			mut, err := r.byte()
+			if err != nil {
+				return err
+			}
+			g.mutable = mut == 1
+			if g.init, err = r.constExpr(); err != nil {
+				return err
+			}
+			m.globals = append(m.globals, g)
+		}
+	case sectionExport:
+		n, err := r.vecLen()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			e := &Export{}
+			if e.Name, err = r.name(); err != nil {
+				return err
+			}
+			kind, err := r.byte()
+			if err != nil {
+				return err
+			}
+			e.Kind = ExternalKind(kind)
+			if e.Index, err = r.u32(); err != nil {
+				return err
+			}
+			if _, ok := m.exports[e.Name]; ok {
+				return fmt.Errorf("duplicate export name %s", e.Name)
+			}
+			m.exports[e.Name] = e
+		}
+	case sectionStart:
+		idx, err := r.u32()
+		if err != nil {
+			return err
+		}
+		m.start = int64(idx)
+	case sectionElement:
+		return m.decodeElements(r)
+	case sectionCode:
+		n, err := r.vecLen()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			size, err := r.u32()
+			if err != nil {
+				return err
+			}
+			body, err := r.bytes(int(size))
+			if err != nil {
+				return err
+			}
+			c, err := decodeCode(body)
+			if err != nil {
+				return fmt.Errorf("function %d: %v", i, err)
+			}
+			m.codes = append(m.codes, c)
+		}
+	case sectionData:
+		return m.decodeDatas(r)
+	case sectionDataCount:
+		n, err := r.u32()
+		if err != nil {
+			return err
+		}
+		m.dataCount = int64(n)
+	default:
+		return fmt.Errorf("unknown section")
+	}
+	return nil
+}
+
+func (m *Module) decodeTypes(r *reader) error {
+	n, err := r.vecLen()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		form, err := r.byte()
+		if err != nil {
+			return err
+		}
+		if form != 0x60 {
+			return fmt.Errorf("unsupported type form 0x%x", form)
+		}
+		t := &FuncType{}
+		for _, types := range []*[]ValueType{&t.Params, &t.Results} {
+			count, err := r.vecLen()
+			if err != nil {
+				return err
+			}
+			for j := 0; j < count; j++ {
+				vt, err := r.valueType()
+				if err != nil {
+					return err
+				}
+				*types = append(*types, vt)
+			}
+		}
+		m.types = append(m.types, t)
+	}
+	return nil
+}
+
+func (m *Module) decodeImports(r *reader) error {
+	n, err := r.vecLen()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		imp := &Import{}
+		if imp.Module, err = r.name(); err != nil {
+			return err
+		}
+		if imp.Name, err = r.name(); err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		imp.Kind = ExternalKind(kind)
+		if imp.Kind != ExternalFunction {
+			// the tables, memories and globals are defined by the modules in practice
+			return fmt.Errorf("import %s.%s: only functions can be imported", imp.Module, imp.Name)
+		}
+		if imp.Type, err = r.u32(); err != nil {
+			return err
+		}
+		if int(imp.Type) >= len(m.types) {
+			return fmt.Errorf("import %s.%s: unknown type %d", imp.Module, imp.Name, imp.Type)
+		}
+		m.imports = append(m.imports, imp)
+		m.funcTypes = append(m.funcTypes, m.types[imp.Type])
+		m.numImport++
+	}
+	return nil
+}
+
+func (m *Module) decodeElements(r *reader) error {
+	n, err := r.vecLen()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		flag, err := r.u32()
+		if err != nil {
+			return err
+		}
+		if flag > 7 {
+			return fmt.Errorf("invalid element segment flag %d", flag)
+		}
+		e := &element{}
+		switch {
+		case flag&1 == 0:
+			e.mode = segmentActive
+		case flag&2 == 0:
+			e.mode = segmentPassive
+		default:
+			e.mode = segmentDeclarative
+		}
+		if flag&2 != 0 && flag&1 == 0 {
+			if e.table, err = r.u32(); err != nil {
+				return err
+			}
+		}
+		if e.mode == segmentActive {
+			if e.offset, err = r.constExpr(); err != nil {
+				return err
+			}
+		}
+		// the element kind or the reference type is omitted by the flag 0 and 4
+		if flag&3 != 0 {
+			if _, err := r.byte(); err != nil {
+				return err
+			}
+		}
+		count, err := r.vecLen()
+		if err != nil {
+			return err
+		}
+		for j := 0; j < count; j++ {
+			if flag&4 == 0 {
+				idx, err := r.u32()
+				if err != nil {
+					return err
+				}
+				e.inits = append(e.inits, constExpr{op: opRefFunc, value: uint64(idx)})
+			} else {
+				expr, err := r.constExpr()
+				if err != nil {
+					return err
+				}
+				if expr.op != opRefFunc && expr.op != opRefNull {
+					return errors.New("element must be a function reference")
+				}
+				e.inits = append(e.inits, expr)
+			}
+		}
+		m.elements = append(m.elements, e)
+	}
+	return nil
+}
+
+func (m *Module) decodeDatas(r *reader) error {
+	n, err := r.vecLen()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		flag, err := r.u32()
+		if err != nil {
+			return err
+		}
+		d := &data{}
+		switch flag {
+		case 0:
+		case 1:
+			d.mode = segmentPassive
+		case 2:
+			if idx, err := r.u32(); err != nil || idx != 0 {
+				return errors.New("invalid memory index")
+			}
+		default:
+			return fmt.Errorf("invalid data segment flag %d", flag)
+		}
+		if d.mode == segmentActive {
+			if d.offset, err = r.constExpr(); err != nil {
+				return err
+			}
+		}
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		if d.init, err = r.bytes(int(size)); err != nil {
+			return err
+		}
+		m.datas = append(m.datas, d)
+	}
+	return nil
+}
+
+func decodeCode(body []byte) (*code, error) {
+	r := &reader{buf: body}
+	n, err := r.vecLen()
+	if err != nil {
+		return nil, err
+	}
+	c := &code{}
+	var total uint64
+	for i := 0; i < n; i++ {
+		count, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		total += uint64(count)
+		if total > maxLocals {
+			return nil, errors.New("too many locals")
+		}
+		t, err := r.valueType()
+		if err != nil {
+			return nil, err
+		}
+		for j := uint32(0); j < count; j++ {
+			c.locals = append(c.locals, t)
+		}
+	}
+	c.body = body[r.pos:]
+	return c, nil
+}
+
+// evalConst evaluates the constant expression by the globals that are initialized
+func evalConst(e constExpr, globals []uint64) uint64 {
+	switch e.op {
+	case opGlobalGet:
+		return globals[e.value]
+	case opRefFunc:
+		return e.value + 1
+	case opRefNull:
+		return 0
+	}
+	return e.value
+}
@@ -0,0 +1,797 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package wasm
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// call executes the function, the arguments are in the stack from fp,
+// and the results are stored in the stack from fp when it returns.
+func (inst *Instance) call(f *function, fp int) {
+	if f.host != nil {
+		n := f.numParams
+		if r := len(f.typ.Results); r > n {
+			n = r
+		}
+		if fp+n > len(inst.stack) {
+			trap("call stack exhausted")
+		}
+		inst.sp = fp + n
+		f.host.Func(inst, inst.stack[fp:fp+n:fp+n])
+		return
+	}
+	inst.depth++
+	if inst.depth > maxCallDepth || fp+f.numLocals+f.maxHeight > len(inst.stack) {
+		trap("call stack exhausted")
+	}
+	inst.execute(f, fp)
+	inst.depth--
+}
+
+func (inst *Instance) execute(f *function, fp int) {
+	stack := inst.stack
+	for i := fp + f.numParams; i < fp+f.numLocals; i++ {
+		stack[i] = 0
+	}
+	sp := fp + f.numLocals
+	mem := inst.memory
+	code := f.code
+	pc := 0
+	for {
+		in := &code[pc]
+		pc++
+		switch in.op {
+		case opUnreachable:
+			trap("unreachable")
+		case opJump:
+			pc = int(in.a)
+		case opIf:
+			sp--
+			if uint32(stack[sp]) == 0 {
+				pc = int(in.a)
+			}
+		case opBr:
+			inst.step()
+			t := &f.targets[in.a]
+			sp = branch(stack, sp, t)
+			pc = int(t.pc)
+		case opBrIf:
+			sp--
+			if uint32(stack[sp]) != 0 {
+				inst.step()
+				t := &f.targets[in.a]
+				sp = branch(stack, sp, t)
+				pc = int(t.pc)
+			}
+		case opBrTable:
+			inst.step()
+			sp--
+			i := uint64(uint32(stack[sp]))
+			if i > in.b {
+				i = in.b
+			}
+			t := &f.targets[uint64(in.a)+i]
+			sp = branch(stack, sp, t)
+			pc = int(t.pc)
+		case opReturn:
+			n := len(f.typ.Results)
+			copy(stack[fp:fp+n], stack[sp-n:sp])
+			return
+		case opCall:
+			inst.step()
+			callee := inst.funcs[in.a]
+			sp -= callee.numParams
+			inst.call(callee, sp)
+			sp += len(callee.typ.Results)
+			mem = inst.memory
+		case opCallIndirect:
+			inst.step()
+			sp--
+			table := inst.tables[in.b]
+			i := uint32(stack[sp])
+			if uint64(i) >= uint64(len(table)) {
+				trap("undefined element")
+			}
+			ref := table[i]
+			if ref == 0 {
+				trap("uninitialized element")
+			}
+			callee := inst.funcs[ref-1]
+			if !callee.typ.Equal(inst.module.types[in.a]) {
+				trap("indirect call type mismatch")
+			}
+			sp -= callee.numParams
+			inst.call(callee, sp)
+			sp += len(callee.typ.Results)
+			mem = inst.memory
+
+		case opDrop:
+			sp--
+		case opSelect:
+			sp -= 2
+			if uint32(stack[sp+1]) == 0 {
+				stack[sp-1] = stack[sp]
+			}
+
+		case opLocalGet:
+			stack[sp] = stack[fp+int(in.a)]
+			sp++
+		case opLocalSet:
+			sp--
+			stack[fp+int(in.a)] = stack[sp]
+		case opLocalTee:
+			stack[fp+int(in.a)] = stack[sp-1]
+		case opGlobalGet:
+			stack[sp] = inst.globals[in.a]
+			sp++
+		case opGlobalSet:
+			sp--
+			inst.globals[in.a] = stack[sp]
+		case opTableGet:
+			table := inst.tables[in.a]
+			i := uint32(stack[sp-1])
+			if uint64(i) >= uint64(len(table)) {
+				trap("out of bounds table access")
+			}
+			stack[sp-1] = table[i]
+		case opTableSet:
+			sp -= 2
+			table := inst.tables[in.a]
+			i := uint32(stack[sp])
+			if uint64(i) >= uint64(len(table)) {
+				trap("out of bounds table access")
+			}
+			table[i] = stack[sp+1]
+
+		case opI32Load:
+			stack[sp-1] = uint64(binary.LittleEndian.Uint32(load(mem, stack[sp-1], in.b, 4)))
+		case opI64Load:
+			stack[sp-1] = binary.LittleEndian.Uint64(load(mem, stack[sp-1], in.b, 8))
+		case opF32Load:
+			stack[sp-1] = uint64(binary.LittleEndian.Uint32(load(mem, stack[sp-1], in.b, 4)))
+		case opF64Load:
+			stack[sp-1] = binary.LittleEndian.Uint64(load(mem, stack[sp-1], in.b, 8))
+		case opI32Load8S:
+			stack[sp-1] = uint64(uint32(int32(int8(load(mem, stack[sp-1], in.b, 1)[0]))))
+		case opI32Load8U:
+			stack[sp-1] = uint64(load(mem, stack[sp-1], in.b, 1)[0])
+		case opI32Load16S:
+			stack[sp-1] = uint64(uint32(int32(int16(binary.LittleEndian.Uint16(load(mem, stack[sp-1], in.b, 2))))))
+		case opI32Load16U:
+			stack[sp-1] = uint64(binary.LittleEndian.Uint16(load(mem, stack[sp-1], in.b, 2)))
+		case opI64Load8S:
+			stack[sp-1] = uint64(int64(int8(load(mem, stack[sp-1], in.b, 1)[0])))
+		case opI64Load8U:
+			stack[sp-1] = uint64(load(mem, stack[sp-1], in.b, 1)[0])
+		case opI64Load16S:
+			stack[sp-1] = uint64(int64(int16(binary.LittleEndian.Uint16(load(mem, stack[sp-1], in.b, 2)))))
+		case opI64Load16U:
+			stack[sp-1] = uint64(binary.LittleEndian.Uint16(load(mem, stack[sp-1], in.b, 2)))
+		case opI64Load32S:
+			stack[sp-1] = uint64(int64(int32(binary.LittleEndian.Uint32(load(mem, stack[sp-1], in.b, 4)))))
+		case opI64Load32U:
+			stack[sp-1] = uint64(binary.LittleEndian.Uint32(load(mem, stack[sp-1], in.b, 4)))
+		case opI32Store, opF32Store:
+			sp -= 2
+			binary.LittleEndian.PutUint32(load(mem, stack[sp], in.b, 4), uint32(stack[sp+1]))
+		case opI64Store, opF64Store:
+			sp -= 2
+			binary.LittleEndian.PutUint64(load(mem, stack[sp], in.b, 8), stack[sp+1])
+		case opI32Store8, opI64Store8:
+			sp -= 2
+			load(mem, stack[sp], in.b, 1)[0] = byte(stack[sp+1])
+		case opI32Store16, opI64Store16:
+			sp -= 2
+			binary.LittleEndian.PutUint16(load(mem, stack[sp], in.b, 2), uint16(stack[sp+1]))
+		case opI64Store32:
+			sp -= 2
+			binary.LittleEndian.PutUint32(load(mem, stack[sp], in.b, 4), uint32(stack[sp+1]))
+		case opMemorySize:
+			stack[sp] = uint64(len(mem) / pageSize)
+			sp++
+		case opMemoryGrow:
+			stack[sp-1] = uint64(uint32(inst.growMemory(uint32(stack[sp-1]))))
+			mem = inst.memory
+
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			stack[sp] = in.b
+			sp++
+
+		case opI32Eqz:
+			stack[sp-1] = b2u(uint32(stack[sp-1]) == 0)
+		case opI64Eqz:
+			stack[sp-1] = b2u(stack[sp-1] == 0)
+		case opRefIsNull:
+			stack[sp-1] = b2u(stack[sp-1] == 0)
+
+		default:
+			if in.op >= opI32Eq && in.op <= opI64Extend32S || in.op >= opI32TruncSatF32S && in.op <= opI64TruncSatF64U {
+				sp = numeric(in.op, stack, sp)
+				continue
+			}
+			sp = inst.executeMisc(in, stack, sp)
+			mem = inst.memory
+		}
+	}
+}
+
+// branch moves the kept operands to the label height
+func branch(stack []uint64, sp int, t *branchTarget) int {
+	if t.drop == 0 {
+		return sp
+	}
+	keep, drop := int(t.keep), int(t.drop)
+	copy(stack[sp-keep-drop:sp-drop], stack[sp-keep:sp])
+	return sp - drop
+}
+
+// load returns the bytes of the memory at the effective address
+func load(mem []byte, addr uint64, offset uint64, size uint64) []byte {
+	ea := uint64(uint32(addr)) + offset
+	if ea+size > uint64(len(mem)) {
+		trap("out of bounds memory access")
+	}
+	return mem[ea : ea+size]
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// executeMisc executes the instructions that are rarely used
+func (inst *Instance) executeMisc(in *instr, stack []uint64, sp int) int {
+	switch in.op {
+	case opRefNull:
+		stack[sp] = 0
+		return sp + 1
+	case opRefFunc:
+		stack[sp] = uint64(in.a) + 1
+		return sp + 1
+	case opMemoryInit:
+		sp -= 3
+		dst, src, n := uint64(uint32(stack[sp])), uint64(uint32(stack[sp+1])), uint64(uint32(stack[sp+2]))
+		var init []byte
+		if !inst.droppedDatas[in.a] {
+			init = inst.module.datas[in.a].init
+		}
+		if src+n > uint64(len(init)) || dst+n > uint64(len(inst.memory)) {
+			trap("out of bounds memory access")
+		}
+		copy(inst.memory[dst:dst+n], init[src:src+n])
+	case opDataDrop:
+		inst.droppedDatas[in.a] = true
+	case opMemoryCopy:
+		sp -= 3
+		dst, src, n := uint64(uint32(stack[sp])), uint64(uint32(stack[sp+1])), uint64(uint32(stack[sp+2]))
+		if src+n > uint64(len(inst.memory)) || dst+n > uint64(len(inst.memory)) {
+			trap("out of bounds memory access")
+		}
+		copy(inst.memory[dst:dst+n], inst.memory[src:src+n])
+	case opMemoryFill:
+		sp -= 3
+		dst, v, n := uint64(uint32(stack[sp])), byte(stack[sp+1]), uint64(uint32(stack[sp+2]))
+		if dst+n > uint64(len(inst.memory)) {
+			trap("out of bounds memory access")
+		}
+		mem := inst.memory[dst : dst+n]
+		for i := range mem {
+			mem[i] = v
+		}
+	case opTableInit:
+		sp -= 3
+		dst, src, n := uint64(uint32(stack[sp])), uint64(uint32(stack[sp+1])), uint64(uint32(stack[sp+2]))
+		var inits []constExpr
+		if !inst.droppedElements[in.a] {
+			inits = inst.module.elements[in.a].inits
+		}
+		table := inst.tables[in.b]
+		if src+n > uint64(len(inits)) || dst+n > uint64(len(table)) {
+			trap("out of bounds table access")
+		}
+		for i := uint64(0); i < n; i++ {
+			table[dst+i] = evalConst(inits[src+i], inst.globals)
+		}
+	case opElemDrop:
+		inst.droppedElements[in.a] = true
+	case opTableCopy:
+		sp -= 3
+		dst, src, n := uint64(uint32(stack[sp])), uint64(uint32(stack[sp+1])), uint64(uint32(stack[sp+2]))
+		dt, st := inst.tables[in.a], inst.tables[in.b]
+		if src+n > uint64(len(st)) || dst+n > uint64(len(dt)) {
+			trap("out of bounds table access")
+		}
+		copy(dt[dst:dst+n], st[src:src+n])
+	case opTableGrow:
+		sp--
+		table := inst.tables[in.a]
+		init, delta := stack[sp-1], uint64(uint32(stack[sp]))
+		old := uint64(len(table))
+		t := inst.module.tables[in.a]
+		max := uint64(maxTableSize)
+		if t.limits.HasMax {
+			max = uint64(t.limits.Max)
+		}
+		if old+delta > max {
+			stack[sp-1] = uint64(uint32(0xffffffff))
+			return sp
+		}
+		for i := uint64(0); i < delta; i++ {
+			table = append(table, init)
+		}
+		inst.tables[in.a] = table
+		stack[sp-1] = old
+	case opTableSize:
+		stack[sp] = uint64(len(inst.tables[in.a]))
+		return sp + 1
+	case opTableFill:
+		sp -= 3
+		table := inst.tables[in.a]
+		dst, v, n := uint64(uint32(stack[sp])), stack[sp+1], uint64(uint32(stack[sp+2]))
+		if dst+n > uint64(len(table)) {
+			trap("out of bounds table access")
+		}
+		for i := uint64(0); i < n; i++ {
+			table[dst+i] = v
+		}
+	default:
+		trap("unknown instruction")
+	}
+	return sp
+}
+
+// numeric executes the comparisons, the arithmetic and the conversions
+func numeric(op uint16, stack []uint64, sp int) int {
+	// the binary operations pop two operands
+	switch {
+	case op >= opI32Eq && op <= opI32GeU, op >= opI32Add && op <= opI32Rotr:
+		sp--
+		stack[sp-1] = i32Binary(op, uint32(stack[sp-1]), uint32(stack[sp]))
+	case op >= opI64Eq && op <= opI64GeU, op >= opI64Add && op <= opI64Rotr:
+		sp--
+		stack[sp-1] = i64Binary(op, stack[sp-1], stack[sp])
+	case op >= opF32Eq && op <= opF32Ge, op >= opF32Add && op <= opF32Copysign:
+		sp--
+		stack[sp-1] = f32Binary(op, uint32(stack[sp-1]), uint32(stack[sp]))
+	case op >= opF64Eq && op <= opF64Ge, op >= opF64Add && op <= opF64Copysign:
+		sp--
+		stack[sp-1] = f64Binary(op, stack[sp-1], stack[sp])
+	default:
+		stack[sp-1] = unary(op, stack[sp-1])
+	}
+	return sp
+}
+
+func i32Binary(op uint16, a, b uint32) uint64 {
+	switch op {
+	case opI32Eq:
+		return b2u(a == b)
+	case opI32Ne:
+		return b2u(a != b)
+	case opI32LtS:
+		return b2u(int32(a) < int32(b))
+	case opI32LtU:
+		return b2u(a < b)
+	case opI32GtS:
+		return b2u(int32(a) > int32(b))
+	case opI32GtU:
+		return b2u(a > b)
+	case opI32LeS:
+		return b2u(int32(a) <= int32(b))
+	case opI32LeU:
+		return b2u(a <= b)
+	case opI32GeS:
+		return b2u(int32(a) >= int32(b))
+	case opI32GeU:
+		return b2u(a >= b)
+	case opI32Add:
+		return uint64(a + b)
+	case opI32Sub:
+		return uint64(a - b)
+	case opI32Mul:
+		return uint64(a * b)
+	case opI32DivS:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(a) == math.MinInt32 && int32(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(uint32(int32(a) / int32(b)))
+	case opI32DivU:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return uint64(a / b)
+	case opI32RemS:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(b) == -1 {
+			return 0
+		}
+		return uint64(uint32(int32(a) % int32(b)))
+	case opI32RemU:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return uint64(a % b)
+	case opI32And:
+		return uint64(a & b)
+	case opI32Or:
+		return uint64(a | b)
+	case opI32Xor:
+		return uint64(a ^ b)
+	case opI32Shl:
+		return uint64(a << (b & 31))
+	case opI32ShrS:
+		return uint64(uint32(int32(a) >> (b & 31)))
+	case opI32ShrU:
+		return uint64(a >> (b & 31))
+	case opI32Rotl:
+		return uint64(bits.RotateLeft32(a, int(b&31)))
+	case opI32Rotr:
+		return uint64(bits.RotateLeft32(a, -int(b&31)))
+	}
+	trap("unknown instruction")
+	return 0
+}
+
+func i64Binary(op uint16, a, b uint64) uint64 {
+	switch op {
+	case opI64Eq:
+		return b2u(a == b)
+	case opI64Ne:
+		return b2u(a != b)
+	case opI64LtS:
+		return b2u(int64(a) < int64(b))
+	case opI64LtU:
+		return b2u(a < b)
+	case opI64GtS:
+		return b2u(int64(a) > int64(b))
+	case opI64GtU:
+		return b2u(a > b)
+	case opI64LeS:
+		return b2u(int64(a) <= int64(b))
+	case opI64LeU:
+		return b2u(a <= b)
+	case opI64GeS:
+		return b2u(int64(a) >= int64(b))
+	case opI64GeU:
+		return b2u(a >= b)
+	case opI64Add:
+		return a + b
+	case opI64Sub:
+		return a - b
+	case opI64Mul:
+		return a * b
+	case opI64DivS:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(int64(a) / int64(b))
+	case opI64DivU:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a / b
+	case opI64RemS:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(b) == -1 {
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	case opI64RemU:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a % b
+	case opI64And:
+		return a & b
+	case opI64Or:
+		return a | b
+	case opI64Xor:
+		return a ^ b
+	case opI64Shl:
+		return a << (b & 63)
+	case opI64ShrS:
+		return uint64(int64(a) >> (b & 63))
+	case opI64ShrU:
+		return a >> (b & 63)
+	case opI64Rotl:
+		return bits.RotateLeft64(a, int(b&63))
+	case opI64Rotr:
+		return bits.RotateLeft64(a, -int(b&63))
+	}
+	trap("unknown instruction")
+	return 0
+}
+
+func f32Binary(op uint16, ab, bb uint32) uint64 {
+	a, b := math.Float32frombits(ab), math.Float32frombits(bb)
+	switch op {
+	case opF32Eq:
+		return b2u(a == b)
+	case opF32Ne:
+		return b2u(a != b)
+	case opF32Lt:
+		return b2u(a < b)
+	case opF32Gt:
+		return b2u(a > b)
+	case opF32Le:
+		return b2u(a <= b)
+	case opF32Ge:
+		return b2u(a >= b)
+	case opF32Add:
+		return f32u(a + b)
+	case opF32Sub:
+		return f32u(a - b)
+	case opF32Mul:
+		return f32u(a * b)
+	case opF32Div:
+		return f32u(a / b)
+	case opF32Min:
+		return f32u(float32(fmin(float64(a), float64(b))))
+	case opF32Max:
+		return f32u(float32(fmax(float64(a), float64(b))))
+	case opF32Copysign:
+		return uint64(ab&^(1<<31) | bb&(1<<31))
+	}
+	trap("unknown instruction")
+	return 0
+}
+
+func f64Binary(op uint16, ab, bb uint64) uint64 {
+	a, b := math.Float64frombits(ab), math.Float64frombits(bb)
+	switch op {
+	case opF64Eq:
+		return b2u(a == b)
+	case opF64Ne:
+		return b2u(a != b)
+	case opF64Lt:
+		return b2u(a < b)
+	case opF64Gt:
+		return b2u(a > b)
+	case opF64Le:
+		return b2u(a <= b)
+	case opF64Ge:
+		return b2u(a >= b)
+	case opF64Add:
+		return math.Float64bits(a + b)
+	case opF64Sub:
+		return math.Float64bits(a - b)
+	case opF64Mul:
+		return math.Float64bits(a * b)
+	case opF64Div:
+		return math.Float64bits(a / b)
+	case opF64Min:
+		return math.Float64bits(fmin(a, b))
+	case opF64Max:
+		return math.Float64bits(fmax(a, b))
+	case opF64Copysign:
+		return ab&^(1<<63) | bb&(1<<63)
+	}
+	trap("unknown instruction")
+	return 0
+}
+
+func f32u(f float32) uint64 {
+	return uint64(math.Float32bits(f))
+}
+
+// fmin and fmax return NaN if any operand is NaN, and treat -0 as less than +0
+func fmin(a, b float64) float64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.NaN()
+	}
+	return math.Min(a, b)
+}
+
+func fmax(a, b float64) float64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.NaN()
+	}
+	return math.Max(a, b)
+}
+
+func unary(op uint16, v uint64) uint64 {
+	switch op {
+	case opI32Clz:
+		return uint64(bits.LeadingZeros32(uint32(v)))
+	case opI32Ctz:
+		return uint64(bits.TrailingZeros32(uint32(v)))
+	case opI32Popcnt:
+		return uint64(bits.OnesCount32(uint32(v)))
+	case opI64Clz:
+		return uint64(bits.LeadingZeros64(v))
+	case opI64Ctz:
+		return uint64(bits.TrailingZeros64(v))
+	case opI64Popcnt:
+		return uint64(bits.OnesCount64(v))
+
+	case opF32Abs:
+		return v &^ (1 << 31) & 0xffffffff
+	case opF32Neg:
+		return (v ^ (1 << 31)) & 0xffffffff
+	case opF32Ceil:
+		return f32u(float32(math.Ceil(float64(f32(v)))))
+	case opF32Floor:
+		return f32u(float32(math.Floor(float64(f32(v)))))
+	case opF32Trunc:
+		return f32u(float32(math.Trunc(float64(f32(v)))))
+	case opF32Nearest:
+		return f32u(float32(math.RoundToEven(float64(f32(v)))))
+	case opF32Sqrt:
+		return f32u(float32(math.Sqrt(float64(f32(v)))))
+	case opF64Abs:
+		return v &^ (1 << 63)
+	case opF64Neg:
+		return v ^ (1 << 63)
+	case opF64Ceil:
+		return math.Float64bits(math.Ceil(f64(v)))
+	case opF64Floor:
+		return math.Float64bits(math.Floor(f64(v)))
+	case opF64Trunc:
+		return math.Float64bits(math.Trunc(f64(v)))
+	case opF64Nearest:
+		return math.Float64bits(math.RoundToEven(f64(v)))
+	case opF64Sqrt:
+		return math.Float64bits(math.Sqrt(f64(v)))
+
+	case opI32WrapI64:
+		return uint64(uint32(v))
+	case opI32TruncF32S:
+		return uint64(uint32(int32(truncS(float64(f32(v)), -2147483904, 2147483648))))
+	case opI32TruncF32U:
+		return uint64(uint32(truncU(float64(f32(v)), 4294967296)))
+	case opI32TruncF64S:
+		return uint64(uint32(int32(truncS(f64(v), -2147483649, 2147483648))))
+	case opI32TruncF64U:
+		return uint64(uint32(truncU(f64(v), 4294967296)))
+	case opI64ExtendI32S:
+		return uint64(int64(int32(v)))
+	case opI64ExtendI32U:
+		return uint64(uint32(v))
+	case opI64TruncF32S:
+		return uint64(truncS(float64(f32(v)), -9223373136366403584, 9223372036854775808))
+	case opI64TruncF32U:
+		return truncU(float64(f32(v)), 18446744073709551616)
+	case opI64TruncF64S:
+		return uint64(truncS(f64(v), -9223372036854777856, 9223372036854775808))
+	case opI64TruncF64U:
+		return truncU(f64(v), 18446744073709551616)
+	case opF32ConvertI32S:
+		return f32u(float32(int32(v)))
+	case opF32ConvertI32U:
+		return f32u(float32(uint32(v)))
+	case opF32ConvertI64S:
+		return f32u(float32(int64(v)))
+	case opF32ConvertI64U:
+		return f32u(float32(v))
+	case opF32DemoteF64:
+		return f32u(float32(f64(v)))
+	case opF64ConvertI32S:
+		return math.Float64bits(float64(int32(v)))
+	case opF64ConvertI32U:
+		return math.Float64bits(float64(uint32(v)))
+	case opF64ConvertI64S:
+		return math.Float64bits(float64(int64(v)))
+	case opF64ConvertI64U:
+		return math.Float64bits(float64(v))
+	case opF64PromoteF32:
+		return math.Float64bits(float64(f32(v)))
+	case opI32ReinterpretF32, opF32ReinterpretI32:
+		return v & 0xffffffff
+	case opI64ReinterpretF64, opF64ReinterpretI64:
+		return v
+
+	case opI32Extend8S:
+		return uint64(uint32(int32(int8(v))))
+	case opI32Extend16S:
+		return uint64(uint32(int32(int16(v))))
+	case opI64Extend8S:
+		return uint64(int64(int8(v)))
+	case opI64Extend16S:
+		return uint64(int64(int16(v)))
+	case opI64Extend32S:
+		return uint64(int64(int32(v)))
+
+	case opI32TruncSatF32S:
+		return uint64(uint32(int32(satS(float64(f32(v)), math.MinInt32, math.MaxInt32))))
+	case opI32TruncSatF32U:
+		return uint64(uint32(satU(float64(f32(v)), math.MaxUint32)))
+	case opI32TruncSatF64S:
+		return uint64(uint32(int32(satS(f64(v), math.MinInt32, math.MaxInt32))))
+	case opI32TruncSatF64U:
+		return uint64(uint32(satU(f64(v), math.MaxUint32)))
+	case opI64TruncSatF32S:
+		return uint64(satS(float64(f32(v)), math.MinInt64, math.MaxInt64))
+	case opI64TruncSatF32U:
+		return satU(float64(f32(v)), math.MaxUint64)
+	case opI64TruncSatF64S:
+		return uint64(satS(f64(v), math.MinInt64, math.MaxInt64))
+	case opI64TruncSatF64U:
+		return satU(f64(v), math.MaxUint64)
+	}
+	trap("unknown instruction")
+	return 0
+}
+
+func f32(v uint64) float32 {
+	return math.Float32frombits(uint32(v))
+}
+
+func f64(v uint64) float64 {
+	return math.Float64frombits(v)
+}
+
+// truncS truncates the float to a signed integer, it traps if the value is not in (min, max)
+func truncS(f float64, min, max float64) int64 {
+	if math.IsNaN(f) {
+		trap("invalid conversion to integer")
+	}
+	if f <= min || f >= max {
+		trap("integer overflow")
+	}
+	return int64(f)
+}
+
+// truncU truncates the float to an unsigned integer, it traps if the value is not in (-1, max)
+func truncU(f float64, max float64) uint64 {
+	if math.IsNaN(f) {
+		trap("invalid conversion to integer")
+	}
+	if f <= -1 || f >= max {
+		trap("integer overflow")
+	}
+	if f < 0 {
+		return 0
+	}
+	return uint64(f)
+}
+
+func satS(f float64, min, max int64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f <= float64(min):
+		return min
+	case f >= float64(max):
+		return max
+	}
+	return int64(f)
+}
+
+func satU(f float64, max uint64) uint64 {
+	switch {
+	case math.IsNaN(f) || f <= 0:
+		return 0
+	case f >= float64(max):
+		return max
+	}
+	return uint64(f)
+}
@@ -0,0 +1,298 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+const (
+	pageSize = 65536
+
+	// DefaultStackSize is the default count of the values in the stack of an instance
+	DefaultStackSize = 1 << 16
+	// maxCallDepth protects the host from the infinite recursion
+	maxCallDepth = 10000
+)
+
+// HostFunction is a function implemented by the host.
+// The parameters are in the stack when it is called, and the results should be written to the stack
+// from the beginning. The length of the stack is the max of the count of the parameters and the results.
+type HostFunction struct {
+	Type *FuncType
+	Func func(inst *Instance, stack []uint64)
+}
+
+// Imports resolves the imported functions by the module name and the function name
+type Imports map[string]map[string]*HostFunction
+
+// Trap is the error that the execution is aborted
+type Trap struct {
+	Reason string
+}
+
+func (t *Trap) Error() string {
+	return "wasm trap: " + t.Reason
+}
+
+func trap(reason string) {
+	panic(&Trap{Reason: reason})
+}
+
+// step counts a branch or a call, it traps if the execution limit is exceeded
+func (inst *Instance) step() {
+	if inst.limit == 0 {
+		return
+	}
+	inst.steps++
+	if inst.steps > inst.limit {
+		trap("execution limit exceeded")
+	}
+}
+
+// Instance is an instance of a module.
+// An instance is not safe for concurrent use, the callers should use it in one goroutine at the same time.
+type Instance struct {
+	module  *Module
+	funcs   []*function
+	tables  [][]uint64
+	memory  []byte
+	maxMem  uint32
+	globals []uint64
+	stack   []uint64
+	sp      int
+	depth   int
+	// limit is the max count of the branches and calls executed by a call from the host, 0 means no limit
+	limit uint64
+	steps uint64
+
+	droppedDatas    []bool
+	droppedElements []bool
+}
+
+// Instantiate creates an instance of the module, the imports must contain all the imported functions
+func (m *Module) Instantiate(imports Imports) (*Instance, error) {
+	return m.InstantiateWithLimit(imports, 0)
+}
+
+// InstantiateWithLimit creates an instance whose calls trap if they execute more than limit branches and calls,
+// so a module that loops forever can not hang the host. The start function is limited too. 0 means no limit.
+func (m *Module) InstantiateWithLimit(imports Imports, limit uint64) (*Instance, error) {
+	inst := &Instance{
+		module:          m,
+		funcs:           make([]*function, 0, len(m.funcTypes)),
+		stack:           make([]uint64, DefaultStackSize),
+		limit:           limit,
+		droppedDatas:    make([]bool, len(m.datas)),
+		droppedElements: make([]bool, len(m.elements)),
+	}
+	for _, imp := range m.imports {
+		host, ok := imports[imp.Module][imp.Name]
+		if !ok || host == nil {
+			return nil, fmt.Errorf("wasm: unknown import %s.%s", imp.Module, imp.Name)
+		}
+		if !host.Type.Equal(m.types[imp.Type]) {
+			return nil, fmt.Errorf("wasm: incompatible import type of %s.%s, expected %s, got %s",
+				imp.Module, imp.Name, m.types[imp.Type], host.Type)
+		}
+		inst.funcs = append(inst.funcs, &function{
+			typ:       host.Type,
+			numParams: len(host.Type.Params),
+			host:      host,
+		})
+	}
+	inst.funcs = append(inst.funcs, m.compiled...)
+
+	inst.globals = make([]uint64, len(m.globals))
+	for i, g := range m.globals {
+		inst.globals[i] = evalConst(g.init, inst.globals)
+	}
+	if len(m.memories) > 0 {
+		l := m.memories[0]
+		inst.memory = make([]byte, int(l.Min)*pageSize)
+		inst.maxMem = maxPages
+		if l.HasMax {
+			inst.maxMem = l.Max
+		}
+	}
+	inst.tables = make([][]uint64, len(m.tables))
+	for i, t := range m.tables {
+		inst.tables[i] = make([]uint64, t.limits.Min)
+	}
+
+	for i, e := range m.elements {
+		if e.mode == segmentPassive {
+			continue
+		}
+		if e.mode == segmentActive {
+			offset := uint64(uint32(evalConst(e.offset, inst.globals)))
+			table := inst.tables[e.table]
+			if offset+uint64(len(e.inits)) > uint64(len(table)) {
+				return nil, errors.New("wasm: out of bounds table access")
+			}
+			for j, init := range e.inits {
+				table[offset+uint64(j)] = evalConst(init, inst.globals)
+			}
+		}
+		inst.droppedElements[i] = true
+	}
+	for i, d := range m.datas {
+		if d.mode != segmentActive {
+			continue
+		}
+		offset := uint64(uint32(evalConst(d.offset, inst.globals)))
+		if offset+uint64(len(d.init)) > uint64(len(inst.memory)) {
+			return nil, errors.New("wasm: out of bounds memory access")
+		}
+		copy(inst.memory[offset:], d.init)
+		inst.droppedDatas[i] = true
+	}
+	if m.start >= 0 {
+		if err := inst.invoke(inst.funcs[m.start], nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	return inst, nil
+}
+
+// Module returns the module of the instance
+func (inst *Instance) Module() *Module {
+	return inst.module
+}
+
+// HasFunction returns true if the function is exported
+func (inst *Instance) HasFunction(name string) bool {
+	e, ok := inst.module.exports[name]
+	return ok && e.Kind == ExternalFunction
+}
+
+// Call calls the exported function, the arguments and the results are the raw bits of the values.
+// The instance should not be used any more if a trap occurs, because the state may be inconsistent.
+func (inst *Instance) Call(name string, args ...uint64) ([]uint64, error) {
+	e, ok := inst.module.exports[name]
+	if !ok || e.Kind != ExternalFunction {
+		return nil, fmt.Errorf("wasm: function %s is not exported", name)
+	}
+	f := inst.funcs[e.Index]
+	if len(args) != f.numParams {
+		return nil, fmt.Errorf("wasm: function %s expects %d arguments, but got %d", name, f.numParams, len(args))
+	}
+	results := make([]uint64, len(f.typ.Results))
+	if err := inst.invoke(f, args, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// invoke calls the function on the top of the stack, so it can be called by the host functions reentrantly.
+// The reentrant calls share the execution limit of the outermost call.
+func (inst *Instance) invoke(f *function, args []uint64, results []uint64) (err error) {
+	base, depth := inst.sp, inst.depth
+	if depth == 0 {
+		inst.steps = 0
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			switch e := r.(type) {
+			case *Trap:
+				err = e
+			case runtime.Error:
+				err = &Trap{Reason: e.Error()}
+			default:
+				err = &Trap{Reason: fmt.Sprintf("%v", e)}
+			}
+		}
+		inst.sp, inst.depth = base, depth
+	}()
+	if base+len(args)+len(results) > len(inst.stack) {
+		trap("call stack exhausted")
+	}
+	copy(inst.stack[base:], args)
+	inst.call(f, base)
+	copy(results, inst.stack[base:])
+	return nil
+}
+
+// Memory returns the linear memory, the returned slice is invalid after the memory grows
+func (inst *Instance) Memory() []byte {
+	return inst.memory
+}
+
+// ReadBytes returns the bytes in the memory, false is returned if it is out of bounds.
+// The returned slice refers to the memory, it should be copied if it is used after calling the module.
+func (inst *Instance) ReadBytes(ptr, size uint32) ([]byte, bool) {
+	end := uint64(ptr) + uint64(size)
+	if end > uint64(len(inst.memory)) {
+		return nil, false
+	}
+	return inst.memory[ptr:end], true
+}
+
+// WriteBytes writes the bytes to the memory, false is returned if it is out of bounds
+func (inst *Instance) WriteBytes(ptr uint32, b []byte) bool {
+	if uint64(ptr)+uint64(len(b)) > uint64(len(inst.memory)) {
+		return false
+	}
+	copy(inst.memory[ptr:], b)
+	return true
+}
+
+// ReadUint32 reads a little endian uint32 from the memory
+func (inst *Instance) ReadUint32(ptr uint32) (uint32, bool) {
+	b, ok := inst.ReadBytes(ptr, 4)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(b), true
+}
+
+// WriteUint32 writes a little endian uint32 to the memory
+func (inst *Instance) WriteUint32(ptr uint32, v uint32) bool {
+	if uint64(ptr)+4 > uint64(len(inst.memory)) {
+		return false
+	}
+	binary.LittleEndian.PutUint32(inst.memory[ptr:], v)
+	return true
+}
+
+// WriteUint64 writes a little endian uint64 to the memory
+func (inst *Instance) WriteUint64(ptr uint32, v uint64) bool {
+	if uint64(ptr)+8 > uint64(len(inst.memory)) {
+		return false
+	}
+	binary.LittleEndian.PutUint64(inst.memory[ptr:], v)
+	return true
+}
+
+// growMemory grows the memory by the pages, and returns the previous pages or -1 if it fails
+func (inst *Instance) growMemory(delta uint32) int32 {
+	if len(inst.module.memories) == 0 {
+		return -1
+	}
+	pages := uint32(len(inst.memory) / pageSize)
+	if uint64(pages)+uint64(delta) > uint64(inst.maxMem) {
+		return -1
+	}
+	if delta > 0 {
+		// append grows the capacity exponentially, so the small steps are amortized
+		inst.memory = append(inst.memory, make([]byte, int(delta)*pageSize)...)
+	}
+	return int32(pages)
+}
@@ -0,0 +1,221 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package wasm is a WebAssembly runtime written in pure go, which interprets the modules
+// of the WebAssembly core specification 1.0 with the extensions that are commonly used by the
+// compilers: multi-value, sign-extension, non-trapping float-to-int conversion, bulk memory and
+// reference types. SIMD, threads and the other extensions are not supported.
+//
+// The functions are validated by the operand stack heights rather than the operand types,
+// all the values are stored in 64 bits slots, so an ill-typed module can not break the host.
+package wasm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValueType is the type of the WebAssembly values
+type ValueType byte
+
+// value types
+const (
+	ValueTypeI32       ValueType = 0x7f
+	ValueTypeI64       ValueType = 0x7e
+	ValueTypeF32       ValueType = 0x7d
+	ValueTypeF64       ValueType = 0x7c
+	ValueTypeFuncRef   ValueType = 0x70
+	ValueTypeExternRef ValueType = 0x6f
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case ValueTypeI32:
+		return "i32"
+	case ValueTypeI64:
+		return "i64"
+	case ValueTypeF32:
+		return "f32"
+	case ValueTypeF64:
+		return "f64"
+	case ValueTypeFuncRef:
+		return "funcref"
+	case ValueTypeExternRef:
+		return "externref"
+	}
+	return fmt.Sprintf("unknown(0x%x)", byte(t))
+}
+
+// FuncType is the signature of a function
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+// Equal returns true if the signatures are the same
+func (t *FuncType) Equal(o *FuncType) bool {
+	if len(t.Params) != len(o.Params) || len(t.Results) != len(o.Results) {
+		return false
+	}
+	for i := range t.Params {
+		if t.Params[i] != o.Params[i] {
+			return false
+		}
+	}
+	for i := range t.Results {
+		if t.Results[i] != o.Results[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *FuncType) String() string {
+	return fmt.Sprintf("%v -> %v", t.Params, t.Results)
+}
+
+// ExternalKind is the kind of the imports and the exports
+type ExternalKind byte
+
+// external kinds
+const (
+	ExternalFunction ExternalKind = 0
+	ExternalTable    ExternalKind = 1
+	ExternalMemory   ExternalKind = 2
+	ExternalGlobal   ExternalKind = 3
+)
+
+// Import is an entry of the import section
+type Import struct {
+	Module string
+	Name   string
+	Kind   ExternalKind
+	// Type is the type index of the imported function
+	Type uint32
+}
+
+// Export is an entry of the export section
+type Export struct {
+	Name  string
+	Kind  ExternalKind
+	Index uint32
+}
+
+// Limits is the size range of the memories and the tables
+type Limits struct {
+	Min    uint32
+	Max    uint32
+	HasMax bool
+}
+
+type table struct {
+	elemType ValueType
+	limits   Limits
+}
+
+type global struct {
+	typ     ValueType
+	mutable bool
+	init    constExpr
+}
+
+// constExpr is a constant expression, which is a const instruction, a global.get,
+// a ref.null or a ref.func
+type constExpr struct {
+	op    byte
+	value uint64
+}
+
+const (
+	segmentActive = iota
+	segmentPassive
+	segmentDeclarative
+)
+
+type element struct {
+	mode   int
+	table  uint32
+	offset constExpr
+	// inits are the function references, which are ref.func or ref.null
+	inits []constExpr
+}
+
+type data struct {
+	mode   int
+	offset constExpr
+	init   []byte
+}
+
+type code struct {
+	locals []ValueType
+	body   []byte
+}
+
+// Module is a decoded and compiled WebAssembly module.
+// A module is immutable, so it can be instantiated many times concurrently.
+type Module struct {
+	types     []*FuncType
+	imports   []*Import
+	funcs     []uint32
+	tables    []*table
+	memories  []Limits
+	globals   []*global
+	exports   map[string]*Export
+	start     int64
+	elements  []*element
+	codes     []*code
+	datas     []*data
+	dataCount int64
+
+	// funcTypes are the types of the functions in the function index space, which starts with the imports
+	funcTypes []*FuncType
+	numImport int
+	compiled  []*function
+}
+
+// Errors
+var (
+	ErrInvalidMagic   = errors.New("wasm: invalid magic number")
+	ErrInvalidVersion = errors.New("wasm: unsupported version")
+)
+
+// Imports returns the imports of the module
+func (m *Module) Imports() []*Import {
+	return m.imports
+}
+
+// ImportType returns the signature of the imported function
+func (m *Module) ImportType(imp *Import) *FuncType {
+	if imp.Kind != ExternalFunction || int(imp.Type) >= len(m.types) {
+		return nil
+	}
+	return m.types[imp.Type]
+}
+
+// Export returns the export by the name
+func (m *Module) Export(name string) (*Export, bool) {
+	e, ok := m.exports[name]
+	return e, ok
+}
+
+// FunctionType returns the signature of the exported function
+func (m *Module) FunctionType(name string) (*FuncType, bool) {
+	e, ok := m.exports[name]
+	if !ok || e.Kind != ExternalFunction {
+		return nil, false
+	}
+	return m.funcTypes[e.Index], true
+}
@@ -0,0 +1,474 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+// the helpers assemble the binary modules for the tests
+
+func uleb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func sleb(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func vec(items ...[]byte) []byte {
+	return cat(uleb(uint64(len(items))), cat(items...))
+}
+
+func str(s string) []byte {
+	return cat(uleb(uint64(len(s))), []byte(s))
+}
+
+func section(id byte, items ...[]byte) []byte {
+	payload := vec(items...)
+	return cat([]byte{id}, uleb(uint64(len(payload))), payload)
+}
+
+func funcType(params, results []byte) []byte {
+	return cat([]byte{0x60}, uleb(uint64(len(params))), params, uleb(uint64(len(results))), results)
+}
+
+func i32c(v int32) []byte {
+	return cat([]byte{opI32Const}, sleb(int64(v)))
+}
+
+func i64c(v int64) []byte {
+	return cat([]byte{opI64Const}, sleb(v))
+}
+
+func f64c(v float64) []byte {
+	b := make([]byte, 9)
+	b[0] = opF64Const
+	binary.LittleEndian.PutUint64(b[1:], math.Float64bits(v))
+	return b
+}
+
+// body creates a function body, the locals are declared one by one
+func body(locals []byte, instrs ...[]byte) []byte {
+	var decls [][]byte
+	for _, l := range locals {
+		decls = append(decls, []byte{1, l})
+	}
+	b := cat(vec(decls...), cat(instrs...), []byte{opEnd})
+	return cat(uleb(uint64(len(b))), b)
+}
+
+func exportFunc(name string, idx uint32) []byte {
+	return cat(str(name), []byte{byte(ExternalFunction)}, uleb(uint64(idx)))
+}
+
+func header() []byte {
+	return []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+}
+
+const (
+	tI32 = byte(ValueTypeI32)
+	tI64 = byte(ValueTypeI64)
+	tF64 = byte(ValueTypeF64)
+)
+
+func instantiate(t *testing.T, bin []byte, imports Imports) *Instance {
+	m, err := Decode(bin)
+	if err != nil {
+		t.Fatalf("decode module failed: %v", err)
+	}
+	inst, err := m.Instantiate(imports)
+	if err != nil {
+		t.Fatalf("instantiate module failed: %v", err)
+	}
+	return inst
+}
+
+func call(t *testing.T, inst *Instance, name string, args ...uint64) uint64 {
+	results, err := inst.Call(name, args...)
+	if err != nil {
+		t.Fatalf("call %s failed: %v", name, err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("call %s expected one result, but got %v", name, results)
+	}
+	return results[0]
+}
+
+func expectTrap(t *testing.T, inst *Instance, reason string, name string, args ...uint64) {
+	_, err := inst.Call(name, args...)
+	trap, ok := err.(*Trap)
+	if !ok || !strings.Contains(trap.Reason, reason) {
+		t.Errorf("call %s expected trap %s, but got %v", name, reason, err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode([]byte("\x00asn\x01\x00\x00\x00")); err != ErrInvalidMagic {
+		t.Errorf("expected invalid magic, but got %v", err)
+	}
+	if _, err := Decode([]byte("\x00asm\x02\x00\x00\x00")); err != ErrInvalidVersion {
+		t.Errorf("expected invalid version, but got %v", err)
+	}
+	// the function returns two values but declares one
+	bin := cat(header(),
+		section(sectionType, funcType(nil, []byte{tI32})),
+		section(sectionFunction, []byte{0}),
+		section(sectionCode, body(nil, i32c(1), i32c(2))),
+	)
+	if _, err := Decode(bin); err == nil {
+		t.Error("expected type mismatch error")
+	}
+	// the export refers to an unknown function
+	bin = cat(header(),
+		section(sectionType, funcType(nil, nil)),
+		section(sectionFunction, []byte{0}),
+		section(sectionExport, exportFunc("f", 1)),
+		section(sectionCode, body(nil)),
+	)
+	if _, err := Decode(bin); err == nil {
+		t.Error("expected unknown function error")
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	bin := cat(header(),
+		section(sectionType,
+			funcType([]byte{tI32, tI32}, []byte{tI32}),
+			funcType([]byte{tI64}, []byte{tI64}),
+			funcType([]byte{tF64}, []byte{tI32}),
+		),
+		section(sectionFunction, []byte{0}, []byte{0}, []byte{1}, []byte{2}, []byte{0}),
+		section(sectionExport,
+			exportFunc("add", 0),
+			exportFunc("div_s", 1),
+			exportFunc("fac", 2),
+			exportFunc("trunc", 3),
+			exportFunc("rotl", 4),
+		),
+		section(sectionCode,
+			body(nil, []byte{opLocalGet, 0, opLocalGet, 1, opI32Add}),
+			body(nil, []byte{opLocalGet, 0, opLocalGet, 1, opI32DivS}),
+			// fac(n) = n <= 1 ? 1 : n * fac(n-1)
+			body(nil,
+				[]byte{opLocalGet, 0}, i64c(1), []byte{opI64LeS},
+				[]byte{opIf, tI64}, i64c(1),
+				[]byte{opElse, opLocalGet, 0, opLocalGet, 0}, i64c(1), []byte{opI64Sub, opCall, 2, opI64Mul},
+				[]byte{opEnd},
+			),
+			body(nil, []byte{opLocalGet, 0, byte(opI32TruncF64S)}),
+			body(nil, []byte{opLocalGet, 0, opLocalGet, 1, opI32Rotl}),
+		),
+	)
+	inst := instantiate(t, bin, nil)
+	if v := call(t, inst, "add", 0xffffffff, 2); v != 1 {
+		t.Errorf("add expected 1, but got %d", v)
+	}
+	neg := uint64(uint32(0xfffffff9)) // -7
+	if v := call(t, inst, "div_s", neg, 2); int32(v) != -3 {
+		t.Errorf("div_s expected -3, but got %d", int32(v))
+	}
+	if v := call(t, inst, "fac", 20); v != 2432902008176640000 {
+		t.Errorf("fac expected 2432902008176640000, but got %d", v)
+	}
+	if v := call(t, inst, "trunc", math.Float64bits(-3.9)); int32(v) != -3 {
+		t.Errorf("trunc expected -3, but got %d", int32(v))
+	}
+	if v := call(t, inst, "rotl", 0x80000001, 33); v != 3 {
+		t.Errorf("rotl expected 3, but got %d", v)
+	}
+	expectTrap(t, inst, "integer divide by zero", "div_s", 1, 0)
+	expectTrap(t, inst, "integer overflow", "div_s", 0x80000000, 0xffffffff)
+	expectTrap(t, inst, "invalid conversion", "trunc", math.Float64bits(math.NaN()))
+	expectTrap(t, inst, "integer overflow", "trunc", math.Float64bits(1e10))
+	// the instance is still usable after the traps
+	if v := call(t, inst, "add", 1, 2); v != 3 {
+		t.Errorf("add expected 3, but got %d", v)
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	bin := cat(header(),
+		section(sectionType, funcType([]byte{tI32}, []byte{tI32})),
+		section(sectionFunction, []byte{0}, []byte{0}, []byte{0}),
+		section(sectionExport, exportFunc("switch", 0), exportFunc("sum", 1), exportFunc("recurse", 2)),
+		section(sectionCode,
+			// switch(n): case 0 -> 100, case 1 -> 101, default -> 102
+			body(nil,
+				[]byte{opBlock, 0x40, opBlock, 0x40, opBlock, 0x40},
+				[]byte{opLocalGet, 0, opBrTable, 2, 0, 1, 2},
+				[]byte{opEnd}, i32c(100), []byte{opReturn},
+				[]byte{opEnd}, i32c(101), []byte{opReturn},
+				[]byte{opEnd}, i32c(102),
+			),
+			// sum(n) = n + (n-1) + ... + 1, the loop keeps the result on the stack of the block
+			body([]byte{tI32},
+				[]byte{opBlock, tI32}, i32c(0), []byte{opLocalSet, 1},
+				[]byte{opLoop, 0x40},
+				[]byte{opLocalGet, 1, opLocalGet, 1, opLocalGet, 0, opI32Add, opLocalSet, 1},
+				[]byte{opLocalGet, 0, opI32Eqz, opBrIf, 1},
+				[]byte{opDrop},
+				[]byte{opLocalGet, 0}, i32c(1), []byte{opI32Sub, opLocalTee, 0, opBrIf, 0},
+				[]byte{opEnd},
+				[]byte{opLocalGet, 1},
+				[]byte{opEnd},
+			),
+			body(nil, []byte{opLocalGet, 0, opCall, 2}),
+		),
+	)
+	inst := instantiate(t, bin, nil)
+	for n, expected := range []uint64{100, 101, 102, 102} {
+		if v := call(t, inst, "switch", uint64(n)); v != expected {
+			t.Errorf("switch(%d) expected %d, but got %d", n, expected, v)
+		}
+	}
+	if v := call(t, inst, "sum", 100); v != 5050 {
+		t.Errorf("sum expected 5050, but got %d", v)
+	}
+	expectTrap(t, inst, "call stack exhausted", "recurse", 1)
+	if v := call(t, inst, "sum", 10); v != 55 {
+		t.Errorf("sum expected 55, but got %d", v)
+	}
+}
+
+func TestExecutionLimit(t *testing.T) {
+	funcs := section(sectionCode,
+		// loop() never returns
+		body(nil, []byte{opLoop, 0x40, opBr, 0, opEnd}),
+		// count(n) loops n times
+		body(nil,
+			[]byte{opLoop, 0x40},
+			[]byte{opLocalGet, 0}, i32c(1), []byte{opI32Sub, opLocalTee, 0, opBrIf, 0},
+			[]byte{opEnd},
+			[]byte{opLocalGet, 0},
+		),
+	)
+	bin := cat(header(),
+		section(sectionType, funcType(nil, nil), funcType([]byte{tI32}, []byte{tI32})),
+		section(sectionFunction, []byte{0}, []byte{1}),
+		section(sectionExport, exportFunc("loop", 0), exportFunc("count", 1)),
+		funcs,
+	)
+	m, err := Decode(bin)
+	if err != nil {
+		t.Fatalf("decode module failed: %v", err)
+	}
+	inst, err := m.InstantiateWithLimit(nil, 1000)
+	if err != nil {
+		t.Fatalf("instantiate module failed: %v", err)
+	}
+	expectTrap(t, inst, "execution limit exceeded", "loop")
+	// the limit applies to each call from the host
+	for i := 0; i < 3; i++ {
+		if v := call(t, inst, "count", 1000); v != 0 {
+			t.Errorf("count expected 0, but got %d", v)
+		}
+	}
+	expectTrap(t, inst, "execution limit exceeded", "count", 1002)
+	// the start function is limited too
+	bin = cat(header(),
+		section(sectionType, funcType(nil, nil), funcType([]byte{tI32}, []byte{tI32})),
+		section(sectionFunction, []byte{0}, []byte{1}),
+		[]byte{sectionStart, 1, 0},
+		funcs,
+	)
+	if m, err = Decode(bin); err != nil {
+		t.Fatalf("decode module failed: %v", err)
+	}
+	_, err = m.InstantiateWithLimit(nil, 1000)
+	if trap, ok := err.(*Trap); !ok || !strings.Contains(trap.Reason, "execution limit exceeded") {
+		t.Errorf("start expected trap, but got %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	bin := cat(header(),
+		section(sectionType, funcType([]byte{tI32}, []byte{tI32}), funcType([]byte{tI32, tI32}, nil)),
+		section(sectionFunction, []byte{0}, []byte{1}, []byte{0}),
+		section(sectionMemory, []byte{0x01, 1, 2}),
+		section(sectionExport,
+			exportFunc("load", 0),
+			exportFunc("store", 1),
+			exportFunc("grow", 2),
+			cat(str("memory"), []byte{byte(ExternalMemory), 0}),
+		),
+		section(sectionCode,
+			body(nil, []byte{opLocalGet, 0, opI32Load, 2, 0}),
+			body(nil, []byte{opLocalGet, 0, opLocalGet, 1, opI32Store16, 1, 4}),
+			body(nil, []byte{opLocalGet, 0, opMemoryGrow, 0}),
+		),
+		section(sectionData, cat([]byte{0}, i32c(16), []byte{opEnd}, str("wasm"))),
+	)
+	inst := instantiate(t, bin, nil)
+	if v := call(t, inst, "load", 16); v != 0x6d736177 {
+		t.Errorf("load expected data, but got %x", v)
+	}
+	if _, err := inst.Call("store", 28, 0x12345678); err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if b, ok := inst.ReadBytes(32, 4); !ok || !bytes.Equal(b, []byte{0x78, 0x56, 0, 0}) {
+		t.Errorf("store expected the low 16 bits at the offset, but got %v", b)
+	}
+	expectTrap(t, inst, "out of bounds memory access", "load", pageSize-2)
+	if v := call(t, inst, "grow", 1); v != 1 {
+		t.Errorf("grow expected 1, but got %d", v)
+	}
+	if len(inst.Memory()) != 2*pageSize {
+		t.Errorf("memory expected 2 pages, but got %d", len(inst.Memory()))
+	}
+	if v := call(t, inst, "load", pageSize-2); v != 0 {
+		t.Errorf("load expected 0, but got %d", v)
+	}
+	if v := call(t, inst, "grow", 1); int32(v) != -1 {
+		t.Errorf("grow over the max expected -1, but got %d", int32(v))
+	}
+}
+
+func TestCallIndirect(t *testing.T) {
+	bin := cat(header(),
+		section(sectionType, funcType(nil, []byte{tI32}), funcType([]byte{tI32}, []byte{tI32}), funcType(nil, []byte{tI64})),
+		section(sectionFunction, []byte{0}, []byte{0}, []byte{2}, []byte{1}),
+		section(sectionTable, []byte{byte(ValueTypeFuncRef), 0x00, 4}),
+		section(sectionExport, exportFunc("dispatch", 3)),
+		section(sectionElement, cat([]byte{0}, i32c(0), []byte{opEnd}, vec([]byte{0}, []byte{1}, []byte{2}))),
+		section(sectionCode,
+			body(nil, i32c(1)),
+			body(nil, i32c(2)),
+			body(nil, i64c(3)),
+			body(nil, []byte{opLocalGet, 0, opCallIndirect, 0, 0}),
+		),
+	)
+	inst := instantiate(t, bin, nil)
+	if v := call(t, inst, "dispatch", 0); v != 1 {
+		t.Errorf("dispatch(0) expected 1, but got %d", v)
+	}
+	if v := call(t, inst, "dispatch", 1); v != 2 {
+		t.Errorf("dispatch(1) expected 2, but got %d", v)
+	}
+	expectTrap(t, inst, "indirect call type mismatch", "dispatch", 2)
+	expectTrap(t, inst, "uninitialized element", "dispatch", 3)
+	expectTrap(t, inst, "undefined element", "dispatch", 4)
+}
+
+func TestHostFunction(t *testing.T) {
+	bin := cat(header(),
+		section(sectionType, funcType([]byte{tI32, tI32}, []byte{tI32}), funcType([]byte{tI32}, []byte{tI32})),
+		section(sectionImport, cat(str("env"), str("host"), []byte{byte(ExternalFunction), 0})),
+		section(sectionFunction, []byte{1}, []byte{1}),
+		section(sectionMemory, []byte{0x00, 1}),
+		section(sectionExport, exportFunc("run", 1), exportFunc("double", 2)),
+		section(sectionCode,
+			body(nil, []byte{opLocalGet, 0}, i32c(1), []byte{opCall, 0}, i32c(1), []byte{opI32Add}),
+			body(nil, []byte{opLocalGet, 0, opLocalGet, 0, opI32Add}),
+		),
+	)
+	m, err := Decode(bin)
+	if err != nil {
+		t.Fatalf("decode module failed: %v", err)
+	}
+	if imps := m.Imports(); len(imps) != 1 || imps[0].Module != "env" || imps[0].Name != "host" {
+		t.Fatalf("unexpected imports: %v", imps)
+	}
+	if _, err := m.Instantiate(nil); err == nil {
+		t.Error("expected unknown import error")
+	}
+	wrong := Imports{"env": {"host": {Type: &FuncType{Params: []ValueType{ValueTypeI32}}}}}
+	if _, err := m.Instantiate(wrong); err == nil {
+		t.Error("expected incompatible import error")
+	}
+	// the host function calls back into the module
+	host := &HostFunction{
+		Type: &FuncType{Params: []ValueType{ValueTypeI32, ValueTypeI32}, Results: []ValueType{ValueTypeI32}},
+		Func: func(inst *Instance, stack []uint64) {
+			results, err := inst.Call("double", stack[0])
+			if err != nil {
+				t.Fatalf("reentrant call failed: %v", err)
+			}
+			inst.WriteUint32(8, uint32(results[0]))
+			stack[0] = results[0] + stack[1]
+		},
+	}
+	inst, err := m.Instantiate(Imports{"env": {"host": host}})
+	if err != nil {
+		t.Fatalf("instantiate module failed: %v", err)
+	}
+	if v := call(t, inst, "run", 20); v != 42 {
+		t.Errorf("run expected 42, but got %d", v)
+	}
+	if v, _ := inst.ReadUint32(8); v != 40 {
+		t.Errorf("host expected to write 40, but got %d", v)
+	}
+	// the trap in the host function aborts the execution
+	host.Func = func(inst *Instance, stack []uint64) {
+		trap("host error")
+	}
+	expectTrap(t, inst, "host error", "run", 1)
+}
+
+func TestFloatAndSaturation(t *testing.T) {
+	bin := cat(header(),
+		section(sectionType, funcType([]byte{tF64}, []byte{tF64}), funcType([]byte{tF64}, []byte{tI32})),
+		section(sectionFunction, []byte{0}, []byte{1}, []byte{0}),
+		section(sectionExport, exportFunc("nearest", 0), exportFunc("sat", 1), exportFunc("min", 2)),
+		section(sectionCode,
+			body(nil, []byte{opLocalGet, 0, opF64Nearest}),
+			body(nil, []byte{opLocalGet, 0, opPrefixFC, byte(opI32TruncSatF64S - 0x100)}),
+			body(nil, []byte{opLocalGet, 0}, f64c(1), []byte{opF64Min}),
+		),
+	)
+	inst := instantiate(t, bin, nil)
+	for in, expected := range map[float64]float64{2.5: 2, 3.5: 4, -0.5: 0} {
+		if v := math.Float64frombits(call(t, inst, "nearest", math.Float64bits(in))); v != expected {
+			t.Errorf("nearest(%v) expected %v, but got %v", in, expected, v)
+		}
+	}
+	for in, expected := range map[float64]int32{math.NaN(): 0, 1e20: math.MaxInt32, -1e20: math.MinInt32, -7.9: -7} {
+		if v := call(t, inst, "sat", math.Float64bits(in)); int32(v) != expected {
+			t.Errorf("sat(%v) expected %v, but got %v", in, expected, int32(v))
+		}
+	}
+	if v := math.Float64frombits(call(t, inst, "min", math.Float64bits(math.NaN()))); !math.IsNaN(v) {
+		t.Errorf("min expected NaN, but got %v", v)
+	}
+}
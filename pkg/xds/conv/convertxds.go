@@ -59,6 +59,7 @@ var supportFilter = map[string]bool{
 	v2.RPC_PROXY:                  true,
 	v2.X_PROXY:                    true,
 	v2.MIXER:                      true,
+	IstioNetworkRBAC:              true,
 }
 
 var httpBaseConfig = map[string]bool{
@@ -376,6 +377,17 @@ func convertStreamFilter(name string, s *types.Struct) v2.Filter {
 				}
 			}
 		}
+	case v2.RBACStream, IstioRBAC:
+		// the filter does nothing without rules, so it is not added
+		if s == nil {
+			break
+		}
+		filter.Config, err = convertRBACConfig(s)
+		if err != nil {
+			log.DefaultLogger.Errorf("convert rbac config error: %v", err)
+			break
+		}
+		filter.Type = v2.RBACStream
 	default:
 	}
 
@@ -581,6 +593,15 @@ func convertFilterConfig(name string, s *types.Struct) map[string]map[string]int
 		}
 		filtersConfigParsed[v2.TCP_PROXY] = toMap(tcpProxyConfig)
 
+		return filtersConfigParsed
+	} else if name == IstioNetworkRBAC {
+		rbacConfig, err := convertRBACConfig(s)
+		if err != nil {
+			log.DefaultLogger.Errorf("convert rbac config error: %v", err)
+			return nil
+		}
+		filtersConfigParsed[v2.RBAC_NETWORK_FILTER] = rbacConfig
+
 		return filtersConfigParsed
 	} else if name == v2.MIXER {
 		// support later
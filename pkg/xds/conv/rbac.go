@@ -0,0 +1,326 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package conv
+
+import (
+	"errors"
+
+	gogojsonpb "github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/types"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+)
+
+// istio rbac filter names
+const (
+	IstioRBAC        = "envoy.filters.http.rbac"
+	IstioNetworkRBAC = "envoy.filters.network.rbac"
+)
+
+var errRBACUnsupported = errors.New("unsupported rbac rule")
+
+// the rbac protos are not vendored, so the config is decoded from the json of the struct,
+// which is the same as the envoy.config.rbac.v2 protos with the original field names.
+type xdsRBAC struct {
+	Rules       *xdsRBACRules `json:"rules,omitempty"`
+	ShadowRules *xdsRBACRules `json:"shadow_rules,omitempty"`
+}
+
+type xdsRBACRules struct {
+	Action   string                    `json:"action,omitempty"`
+	Policies map[string]*xdsRBACPolicy `json:"policies,omitempty"`
+}
+
+type xdsRBACPolicy struct {
+	Permissions []*xdsRBACPermission `json:"permissions,omitempty"`
+	Principals  []*xdsRBACPrincipal  `json:"principals,omitempty"`
+}
+
+type xdsRBACPermissionSet struct {
+	Rules []*xdsRBACPermission `json:"rules,omitempty"`
+}
+
+type xdsRBACPermission struct {
+	AndRules            *xdsRBACPermissionSet  `json:"and_rules,omitempty"`
+	OrRules             *xdsRBACPermissionSet  `json:"or_rules,omitempty"`
+	Any                 bool                   `json:"any,omitempty"`
+	Header              *xdsRBACHeaderMatcher  `json:"header,omitempty"`
+	URLPath             *xdsRBACPathMatcher    `json:"url_path,omitempty"`
+	DestinationIP       *v2.RBACCidrRange      `json:"destination_ip,omitempty"`
+	DestinationPort     uint32                 `json:"destination_port,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	NotRule             *xdsRBACPermission     `json:"not_rule,omitempty"`
+	RequestedServerName *xdsRBACStringMatcher  `json:"requested_server_name,omitempty"`
+}
+
+type xdsRBACPrincipalSet struct {
+	IDs []*xdsRBACPrincipal `json:"ids,omitempty"`
+}
+
+type xdsRBACPrincipal struct {
+	AndIDs         *xdsRBACPrincipalSet   `json:"and_ids,omitempty"`
+	OrIDs          *xdsRBACPrincipalSet   `json:"or_ids,omitempty"`
+	Any            bool                   `json:"any,omitempty"`
+	Authenticated  *xdsRBACAuthenticated  `json:"authenticated,omitempty"`
+	SourceIP       *v2.RBACCidrRange      `json:"source_ip,omitempty"`
+	DirectRemoteIP *v2.RBACCidrRange      `json:"direct_remote_ip,omitempty"`
+	Header         *xdsRBACHeaderMatcher  `json:"header,omitempty"`
+	URLPath        *xdsRBACPathMatcher    `json:"url_path,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	NotID          *xdsRBACPrincipal      `json:"not_id,omitempty"`
+}
+
+type xdsRBACAuthenticated struct {
+	PrincipalName *xdsRBACStringMatcher `json:"principal_name,omitempty"`
+}
+
+type xdsRBACPathMatcher struct {
+	Path *xdsRBACStringMatcher `json:"path,omitempty"`
+}
+
+type xdsRBACRegexMatcher struct {
+	Regex string `json:"regex,omitempty"`
+}
+
+type xdsRBACStringMatcher struct {
+	Exact      string               `json:"exact,omitempty"`
+	Prefix     string               `json:"prefix,omitempty"`
+	Suffix     string               `json:"suffix,omitempty"`
+	Regex      string               `json:"regex,omitempty"`
+	SafeRegex  *xdsRBACRegexMatcher `json:"safe_regex,omitempty"`
+	IgnoreCase bool                 `json:"ignore_case,omitempty"`
+}
+
+type xdsRBACHeaderMatcher struct {
+	Name           string                 `json:"name,omitempty"`
+	ExactMatch     string                 `json:"exact_match,omitempty"`
+	RegexMatch     string                 `json:"regex_match,omitempty"`
+	SafeRegexMatch *xdsRBACRegexMatcher   `json:"safe_regex_match,omitempty"`
+	RangeMatch     map[string]interface{} `json:"range_match,omitempty"`
+	PresentMatch   bool                   `json:"present_match,omitempty"`
+	PrefixMatch    string                 `json:"prefix_match,omitempty"`
+	SuffixMatch    string                 `json:"suffix_match,omitempty"`
+	InvertMatch    bool                   `json:"invert_match,omitempty"`
+}
+
+func convertRBACConfig(s *types.Struct) (map[string]interface{}, error) {
+	marshaler := gogojsonpb.Marshaler{OrigName: true}
+	str, err := marshaler.MarshalToString(s)
+	if err != nil {
+		return nil, err
+	}
+	xdsConfig := &xdsRBAC{}
+	if err := json.Unmarshal([]byte(str), xdsConfig); err != nil {
+		return nil, err
+	}
+	rbacConfig := &v2.RBAC{
+		Rules:       convertRBACRules(xdsConfig.Rules),
+		ShadowRules: convertRBACRules(xdsConfig.ShadowRules),
+	}
+	if rbacConfig.Rules == nil && rbacConfig.ShadowRules == nil {
+		return nil, errors.New("neither rules nor shadow rules is configured")
+	}
+	return makeJsonMap(rbacConfig)
+}
+
+// convertRBACRules converts the rules, a policy that contains the unsupported rules is
+// removed if the action is ALLOW, and it denies all the requests if the action is DENY,
+// so the unsupported rules never allow more requests than expected.
+func convertRBACRules(xdsRules *xdsRBACRules) *v2.RBACRules {
+	if xdsRules == nil {
+		return nil
+	}
+	rules := &v2.RBACRules{
+		Action:   xdsRules.Action,
+		Policies: make(map[string]*v2.RBACPolicy, len(xdsRules.Policies)),
+	}
+	if rules.Action == "" {
+		rules.Action = v2.RBACActionAllow
+	}
+	for name, xdsPolicy := range xdsRules.Policies {
+		policy, err := convertRBACPolicy(xdsPolicy)
+		if err != nil {
+			log.DefaultLogger.Errorf("convert rbac policy %s error: %v", name, err)
+			if rules.Action != v2.RBACActionDeny {
+				continue
+			}
+			policy = &v2.RBACPolicy{
+				Permissions: []*v2.RBACPermission{{Any: true}},
+				Principals:  []*v2.RBACPrincipal{{Any: true}},
+			}
+		}
+		rules.Policies[name] = policy
+	}
+	return rules
+}
+
+func convertRBACPolicy(xdsPolicy *xdsRBACPolicy) (*v2.RBACPolicy, error) {
+	if xdsPolicy == nil {
+		return nil, errRBACUnsupported
+	}
+	policy := &v2.RBACPolicy{}
+	for _, xdsPermission := range xdsPolicy.Permissions {
+		permission, err := convertRBACPermission(xdsPermission)
+		if err != nil {
+			return nil, err
+		}
+		policy.Permissions = append(policy.Permissions, permission)
+	}
+	for _, xdsPrincipal := range xdsPolicy.Principals {
+		principal, err := convertRBACPrincipal(xdsPrincipal)
+		if err != nil {
+			return nil, err
+		}
+		policy.Principals = append(policy.Principals, principal)
+	}
+	return policy, nil
+}
+
+func convertRBACPermission(xdsPermission *xdsRBACPermission) (*v2.RBACPermission, error) {
+	if xdsPermission == nil {
+		return nil, errRBACUnsupported
+	}
+	permission := &v2.RBACPermission{}
+	var err error
+	switch {
+	case xdsPermission.Any:
+		permission.Any = true
+	case xdsPermission.AndRules != nil:
+		permission.AndRules, err = convertRBACPermissions(xdsPermission.AndRules.Rules)
+	case xdsPermission.OrRules != nil:
+		permission.OrRules, err = convertRBACPermissions(xdsPermission.OrRules.Rules)
+	case xdsPermission.NotRule != nil:
+		permission.NotRule, err = convertRBACPermission(xdsPermission.NotRule)
+	case xdsPermission.Header != nil:
+		permission.Header, err = convertRBACHeaderMatcher(xdsPermission.Header)
+	case xdsPermission.URLPath != nil:
+		permission.Path, err = convertRBACStringMatcher(xdsPermission.URLPath.Path)
+	case xdsPermission.DestinationIP != nil:
+		permission.DestinationIP = xdsPermission.DestinationIP
+	case xdsPermission.DestinationPort != 0:
+		permission.DestinationPort = xdsPermission.DestinationPort
+	default:
+		// metadata and requested_server_name are not supported
+		err = errRBACUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return permission, nil
+}
+
+func convertRBACPermissions(xdsPermissions []*xdsRBACPermission) ([]*v2.RBACPermission, error) {
+	if len(xdsPermissions) == 0 {
+		return nil, errRBACUnsupported
+	}
+	permissions := make([]*v2.RBACPermission, 0, len(xdsPermissions))
+	for _, xdsPermission := range xdsPermissions {
+		permission, err := convertRBACPermission(xdsPermission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, nil
+}
+
+func convertRBACPrincipal(xdsPrincipal *xdsRBACPrincipal) (*v2.RBACPrincipal, error) {
+	if xdsPrincipal == nil {
+		return nil, errRBACUnsupported
+	}
+	principal := &v2.RBACPrincipal{}
+	var err error
+	switch {
+	case xdsPrincipal.Any:
+		principal.Any = true
+	case xdsPrincipal.AndIDs != nil:
+		principal.AndIDs, err = convertRBACPrincipals(xdsPrincipal.AndIDs.IDs)
+	case xdsPrincipal.OrIDs != nil:
+		principal.OrIDs, err = convertRBACPrincipals(xdsPrincipal.OrIDs.IDs)
+	case xdsPrincipal.NotID != nil:
+		principal.NotID, err = convertRBACPrincipal(xdsPrincipal.NotID)
+	case xdsPrincipal.Authenticated != nil:
+		principal.Authenticated = &v2.RBACAuthenticated{}
+		if xdsPrincipal.Authenticated.PrincipalName != nil {
+			principal.Authenticated.PrincipalName, err = convertRBACStringMatcher(xdsPrincipal.Authenticated.PrincipalName)
+		}
+	case xdsPrincipal.SourceIP != nil:
+		principal.SourceIP = xdsPrincipal.SourceIP
+	case xdsPrincipal.Header != nil:
+		principal.Header, err = convertRBACHeaderMatcher(xdsPrincipal.Header)
+	default:
+		// metadata (such as the istio request.auth.claims), url_path and direct_remote_ip are not supported,
+		// the direct_remote_ip is the downstream connection address, which is not the same as the source_ip
+		// if the connection is proxied
+		err = errRBACUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+func convertRBACPrincipals(xdsPrincipals []*xdsRBACPrincipal) ([]*v2.RBACPrincipal, error) {
+	if len(xdsPrincipals) == 0 {
+		return nil, errRBACUnsupported
+	}
+	principals := make([]*v2.RBACPrincipal, 0, len(xdsPrincipals))
+	for _, xdsPrincipal := range xdsPrincipals {
+		principal, err := convertRBACPrincipal(xdsPrincipal)
+		if err != nil {
+			return nil, err
+		}
+		principals = append(principals, principal)
+	}
+	return principals, nil
+}
+
+func convertRBACStringMatcher(xdsMatcher *xdsRBACStringMatcher) (*v2.RBACStringMatcher, error) {
+	if xdsMatcher == nil {
+		return nil, errRBACUnsupported
+	}
+	matcher := &v2.RBACStringMatcher{
+		Exact:      xdsMatcher.Exact,
+		Prefix:     xdsMatcher.Prefix,
+		Suffix:     xdsMatcher.Suffix,
+		Regex:      xdsMatcher.Regex,
+		IgnoreCase: xdsMatcher.IgnoreCase,
+	}
+	if xdsMatcher.SafeRegex != nil {
+		matcher.Regex = xdsMatcher.SafeRegex.Regex
+	}
+	return matcher, nil
+}
+
+func convertRBACHeaderMatcher(xdsMatcher *xdsRBACHeaderMatcher) (*v2.RBACHeaderMatcher, error) {
+	if xdsMatcher.RangeMatch != nil {
+		return nil, errRBACUnsupported
+	}
+	matcher := &v2.RBACHeaderMatcher{
+		Name:         xdsMatcher.Name,
+		ExactMatch:   xdsMatcher.ExactMatch,
+		PrefixMatch:  xdsMatcher.PrefixMatch,
+		SuffixMatch:  xdsMatcher.SuffixMatch,
+		RegexMatch:   xdsMatcher.RegexMatch,
+		PresentMatch: xdsMatcher.PresentMatch,
+		InvertMatch:  xdsMatcher.InvertMatch,
+	}
+	if xdsMatcher.SafeRegexMatch != nil {
+		matcher.RegexMatch = xdsMatcher.SafeRegexMatch.Regex
+	}
+	return matcher, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package conv
+
+import (
+	"reflect"
+	"testing"
+
+	gogojsonpb "github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/types"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// istioRBACConfig is the rbac config generated by istio for an AuthorizationPolicy
+const istioRBACConfig = `{
+	"rules": {
+		"policies": {
+			"ns[default]-policy[admin]-rule[0]": {
+				"permissions": [{"and_rules": {"rules": [
+					{"or_rules": {"rules": [{"url_path": {"path": {"prefix": "/admin/"}}}]}},
+					{"or_rules": {"rules": [{"header": {"name": ":method", "exact_match": "GET"}}]}}
+				]}}],
+				"principals": [{"and_ids": {"ids": [
+					{"or_ids": {"ids": [{"authenticated": {"principal_name": {"exact": "spiffe://cluster.local/ns/default/sa/admin"}}}]}},
+					{"or_ids": {"ids": [{"source_ip": {"address_prefix": "10.0.0.0", "prefix_len": 8}}]}}
+				]}}]
+			},
+			"ns[default]-policy[jwt]-rule[0]": {
+				"permissions": [{"any": true}],
+				"principals": [{"metadata": {"filter": "istio_authn", "path": [{"key": "request.auth.claims"}, {"key": "sub"}], "value": {"string_match": {"exact": "alice"}}}}]
+			}
+		}
+	},
+	"shadow_rules": {
+		"action": "DENY",
+		"policies": {
+			"ns[default]-policy[deny]-rule[0]": {
+				"permissions": [{"requested_server_name": {"exact": "test.com"}}],
+				"principals": [{"any": true}]
+			}
+		}
+	}
+}`
+
+func Test_convertRBACConfig(t *testing.T) {
+	s := &types.Struct{}
+	if err := gogojsonpb.UnmarshalString(istioRBACConfig, s); err != nil {
+		t.Fatalf("make rbac struct failed: %v", err)
+	}
+	expected := &v2.RBAC{
+		Rules: &v2.RBACRules{
+			Action: v2.RBACActionAllow,
+			Policies: map[string]*v2.RBACPolicy{
+				"ns[default]-policy[admin]-rule[0]": {
+					Permissions: []*v2.RBACPermission{{AndRules: []*v2.RBACPermission{
+						{OrRules: []*v2.RBACPermission{{Path: &v2.RBACStringMatcher{Prefix: "/admin/"}}}},
+						{OrRules: []*v2.RBACPermission{{Header: &v2.RBACHeaderMatcher{Name: ":method", ExactMatch: "GET"}}}},
+					}}},
+					Principals: []*v2.RBACPrincipal{{AndIDs: []*v2.RBACPrincipal{
+						{OrIDs: []*v2.RBACPrincipal{{Authenticated: &v2.RBACAuthenticated{
+							PrincipalName: &v2.RBACStringMatcher{Exact: "spiffe://cluster.local/ns/default/sa/admin"},
+						}}}},
+						{OrIDs: []*v2.RBACPrincipal{{SourceIP: &v2.RBACCidrRange{AddressPrefix: "10.0.0.0", PrefixLen: 8}}}},
+					}}},
+				},
+				// the allow policy with the unsupported metadata principal is removed
+			},
+		},
+		ShadowRules: &v2.RBACRules{
+			Action: v2.RBACActionDeny,
+			Policies: map[string]*v2.RBACPolicy{
+				// the deny policy with the unsupported permission denies all
+				"ns[default]-policy[deny]-rule[0]": {
+					Permissions: []*v2.RBACPermission{{Any: true}},
+					Principals:  []*v2.RBACPrincipal{{Any: true}},
+				},
+			},
+		},
+	}
+
+	streamFilter := convertStreamFilter(IstioRBAC, s)
+	if streamFilter.Type != v2.RBACStream {
+		t.Fatalf("convert to mosn stream filter not expected, want %s, got %s", v2.RBACStream, streamFilter.Type)
+	}
+	networkFilters := convertFilterConfig(IstioNetworkRBAC, s)
+	if len(networkFilters) != 1 || networkFilters[v2.RBAC_NETWORK_FILTER] == nil {
+		t.Fatalf("convert to mosn network filter not expected: %v", networkFilters)
+	}
+	for _, config := range []map[string]interface{}{streamFilter.Config, networkFilters[v2.RBAC_NETWORK_FILTER]} {
+		rbacConfig := &v2.RBAC{}
+		b, _ := json.Marshal(config)
+		if err := json.Unmarshal(b, rbacConfig); err != nil {
+			t.Fatalf("unexpected config for rbac: %v", err)
+		}
+		if !reflect.DeepEqual(rbacConfig, expected) {
+			got, _ := json.Marshal(rbacConfig)
+			t.Errorf("rbac config is not expected: %s", got)
+		}
+	}
+
+	// the filter without rules is not added
+	if filter := convertStreamFilter(IstioRBAC, nil); filter.Type != "" {
+		t.Errorf("rbac filter without config should not be added")
+	}
+	if filter := convertStreamFilter(IstioRBAC, &types.Struct{}); filter.Type != "" {
+		t.Errorf("rbac filter without rules should not be added")
+	}
+}
+
+func Test_convertRBACPrincipal(t *testing.T) {
+	cidr := &v2.RBACCidrRange{AddressPrefix: "10.0.0.0", PrefixLen: 8}
+	principal, err := convertRBACPrincipal(&xdsRBACPrincipal{SourceIP: cidr})
+	if err != nil || !reflect.DeepEqual(principal, &v2.RBACPrincipal{SourceIP: cidr}) {
+		t.Errorf("convert source_ip principal failed: %v, %v", principal, err)
+	}
+
+	// the direct_remote_ip is not the source_ip, it is not supported even if it is nested
+	for _, xdsPrincipal := range []*xdsRBACPrincipal{
+		{DirectRemoteIP: cidr},
+		{OrIDs: &xdsRBACPrincipalSet{IDs: []*xdsRBACPrincipal{{SourceIP: cidr}, {DirectRemoteIP: cidr}}}},
+		{NotID: &xdsRBACPrincipal{DirectRemoteIP: cidr}},
+	} {
+		if principal, err := convertRBACPrincipal(xdsPrincipal); err != errRBACUnsupported {
+			t.Errorf("direct_remote_ip principal should be unsupported, but got %v, %v", principal, err)
+		}
+	}
+}
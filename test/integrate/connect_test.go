@@ -0,0 +1,205 @@
+package integrate
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mosn"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	testutil "mosn.io/mosn/test/util"
+)
+
+func serveEcho(t *testing.T, conn net.Conn) {
+	io.Copy(conn, conn)
+}
+
+// serveUpstreamProxy accepts the CONNECT request as a http proxy, and echoes the tunnel data
+func serveUpstreamProxy(t *testing.T, conn net.Conn) {
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil || req.Method != http.MethodConnect {
+		conn.Close()
+		return
+	}
+	fmt.Fprint(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	io.Copy(conn, br)
+}
+
+type connectCase struct {
+	protocol  types.ProtocolName
+	config    *v2.ConnectConfig
+	appServer testutil.UpstreamServer
+	meshAddr  string
+	finish    chan bool
+}
+
+func newConnectCase(t *testing.T, proto types.ProtocolName, config *v2.ConnectConfig) *connectCase {
+	serve := serveEcho
+	if config != nil && config.UpstreamProxy {
+		serve = serveUpstreamProxy
+	}
+	return &connectCase{
+		protocol:  proto,
+		config:    config,
+		appServer: testutil.NewUpstreamServer(t, "127.0.0.1:8080", serve),
+		finish:    make(chan bool),
+	}
+}
+
+func (c *connectCase) Start() {
+	c.appServer.GoServe()
+	c.meshAddr = testutil.CurrentMeshAddr()
+	cfg := testutil.CreateConnectProxyConfig(c.meshAddr, []string{c.appServer.Addr()}, c.protocol, c.config)
+	mesh := mosn.NewMosn(cfg)
+	go mesh.Start()
+	go func() {
+		<-c.finish
+		c.appServer.Close()
+		mesh.Close()
+		c.finish <- true
+	}()
+	time.Sleep(5 * time.Second) //wait server and mesh start
+}
+
+func (c *connectCase) Finish() {
+	c.finish <- true
+	<-c.finish
+}
+
+// tunnel sends the CONNECT request, and returns the tunnel stream
+func (c *connectCase) tunnel(authority string) (io.ReadWriteCloser, error) {
+	switch c.protocol {
+	case protocol.HTTP1:
+		conn, err := net.Dial("tcp", c.meshAddr)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", authority, authority)
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("response status: %d", resp.StatusCode)
+		}
+		return conn, nil
+	case protocol.HTTP2:
+		tr := &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(netw, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(netw, addr)
+			},
+		}
+		pr, pw := io.Pipe()
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Scheme: "http", Host: c.meshAddr},
+			Host:   authority,
+			Header: make(http.Header),
+			Body:   pr,
+		}
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("response status: %d", resp.StatusCode)
+		}
+		return &h2Tunnel{Reader: resp.Body, WriteCloser: pw}, nil
+	}
+	return nil, fmt.Errorf("unsupported protocol %s", c.protocol)
+}
+
+type h2Tunnel struct {
+	io.Reader
+	io.WriteCloser
+}
+
+func (c *connectCase) RunCase(authority string) error {
+	tunnel, err := c.tunnel(authority)
+	if err != nil {
+		return err
+	}
+	defer tunnel.Close()
+	for i := 0; i < 3; i++ {
+		msg := fmt.Sprintf("hello tunnel %d", i)
+		if _, err := tunnel.Write([]byte(msg)); err != nil {
+			return err
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(tunnel, buf); err != nil {
+			return err
+		}
+		if string(buf) != msg {
+			return fmt.Errorf("tunnel echo %q, expected %q", buf, msg)
+		}
+	}
+	return nil
+}
+
+func TestConnectProxy(t *testing.T) {
+	testCases := []struct {
+		protocol  types.ProtocolName
+		config    *v2.ConnectConfig
+		authority string
+	}{
+		{protocol.HTTP1, &v2.ConnectConfig{}, "example.com:443"},
+		{protocol.HTTP1, &v2.ConnectConfig{DynamicHost: true, AllowedCIDRs: []string{"127.0.0.0/8"}}, "127.0.0.1:8080"},
+		{protocol.HTTP1, &v2.ConnectConfig{UpstreamProxy: true}, "example.com:443"},
+		{protocol.HTTP2, &v2.ConnectConfig{}, "example.com:443"},
+		{protocol.HTTP2, &v2.ConnectConfig{DynamicHost: true, AllowedHosts: []string{"localhost"}}, "localhost:8080"},
+		{protocol.HTTP2, &v2.ConnectConfig{UpstreamProxy: true}, "example.com:443"},
+	}
+	for i, tc := range testCases {
+		c := newConnectCase(t, tc.protocol, tc.config)
+		c.Start()
+		result := make(chan error, 1)
+		go func() {
+			result <- c.RunCase(tc.authority)
+		}()
+		select {
+		case err := <-result:
+			if err != nil {
+				t.Errorf("[ERROR MESSAGE] #%d connect proxy test failed, protocol: %s, error: %v\n", i, tc.protocol, err)
+			}
+		case <-time.After(15 * time.Second):
+			t.Errorf("[ERROR MESSAGE] #%d connect proxy hang, protocol: %s\n", i, tc.protocol)
+		}
+		c.Finish()
+	}
+}
+
+func TestConnectProxyNotAllowed(t *testing.T) {
+	for i, proto := range []types.ProtocolName{protocol.HTTP1, protocol.HTTP2} {
+		// the route without connect config does not allow the CONNECT request
+		c := newConnectCase(t, proto, nil)
+		c.Start()
+		if _, err := c.tunnel("example.com:443"); err == nil {
+			t.Errorf("#%d expected CONNECT request refused, protocol: %s", i, proto)
+		}
+		c.Finish()
+
+		// the dynamic host tunnel connects to the allowed authorities only
+		c = newConnectCase(t, proto, &v2.ConnectConfig{DynamicHost: true, AllowedCIDRs: []string{"10.0.0.0/8"}, AllowedHosts: []string{"example.com"}})
+		c.Start()
+		for _, authority := range []string{"127.0.0.1:8080", "localhost:8080"} {
+			if _, err := c.tunnel(authority); err == nil {
+				t.Errorf("#%d expected CONNECT to %s refused, protocol: %s", i, authority, proto)
+			}
+		}
+		c.Finish()
+	}
+}
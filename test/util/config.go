@@ -327,3 +327,23 @@ func CreateXWeightProxyMesh(addr string, proto types.ProtocolName, clusters []*W
 
 	return NewMOSNConfig([]v2.Listener{listener}, cmconfig)
 }
+
+// HTTP CONNECT Proxy
+func CreateConnectProxyConfig(meshaddr string, hosts []string, proto types.ProtocolName, config *v2.ConnectConfig) *v2.MOSNConfig {
+	clusterName := "cluster"
+	routers := []v2.Router{
+		NewConnectRouter(clusterName, config),
+	}
+	chains := []v2.FilterChain{
+		NewFilterChain("connect_router", proto, proto, routers),
+	}
+	cmconfig := v2.ClusterManagerConfig{
+		Clusters: []v2.Cluster{
+			NewBasicCluster(clusterName, hosts),
+		},
+	}
+	listener := NewListener("listener", meshaddr, chains)
+	return NewMOSNConfig([]v2.Listener{
+		listener,
+	}, cmconfig)
+}
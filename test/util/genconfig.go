@@ -250,3 +250,17 @@ T/Yriezreq8BhYukV8tT5QLq83hcw6kGPA==
 -----END EC PRIVATE KEY-----
 `
 )
+
+func NewConnectRouter(cluster string, config *v2.ConnectConfig) v2.Router {
+	return v2.Router{
+		RouterConfig: v2.RouterConfig{
+			Match: v2.RouterMatch{ConnectMatcher: &v2.ConnectMatcher{}},
+			Route: v2.RouteAction{
+				RouterActionConfig: v2.RouterActionConfig{
+					ClusterName:   cluster,
+					ConnectConfig: config,
+				},
+			},
+		},
+	}
+}
@@ -133,7 +133,7 @@ func (c *RPCClient) SendRequestWithData(in string) {
 
 func (c *RPCClient) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
 	if cmd, ok := headers.(xprotocol.XRespFrame); ok {
-		streamID := protocol.StreamIDConv(cmd.GetRequestId())
+		streamID := protocol.StreamIDConv(cmd.(xprotocol.Multiplexing).GetRequestId())
 
 		if _, ok := c.Waits.Load(streamID); ok {
 			c.t.Logf("RPC client receive streamId:%s \n", streamID)